@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// actionsSummaryPath returns the step summary file when running inside
+// GitHub Actions, or "".
+func actionsSummaryPath() string {
+	if os.Getenv("GITHUB_ACTIONS") != "true" {
+		return ""
+	}
+	return strings.TrimSpace(os.Getenv("GITHUB_STEP_SUMMARY"))
+}
+
+// writeActionsSummary appends the run's Markdown report to the step summary
+// and annotates each failed PR with an ::error:: command. Outside Actions it
+// does nothing. Annotations go to stderr; the runner reads commands from
+// both streams and stdout is reserved for the JSON output.
+func writeActionsSummary(out runOutput) {
+	path := actionsSummaryPath()
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[actions] can't open step summary: %v\n", err)
+		return
+	}
+	if _, err := io.WriteString(f, renderMarkdownReport(out)); err != nil {
+		fmt.Fprintf(os.Stderr, "[actions] can't write step summary: %v\n", err)
+	}
+	_ = f.Close()
+	writeActionsAnnotations(os.Stderr, out)
+}
+
+// writeActionsAnnotations emits an ::error:: workflow command per failed PR,
+// plus one for a run-level error or abort.
+func writeActionsAnnotations(w io.Writer, out runOutput) {
+	if out.Error != "" {
+		fmt.Fprintf(w, "::error title=PR pipeline run failed::%s\n", escapeActionsData(out.Error))
+	}
+	if out.Aborted != "" {
+		fmt.Fprintf(w, "::error title=PR pipeline run aborted::%s\n", escapeActionsData(out.Aborted))
+	}
+	for _, r := range out.Results {
+		if r.Action != "error" {
+			continue
+		}
+		msg := r.URL
+		if r.Reason != "" {
+			msg += ": " + r.Reason
+		}
+		fmt.Fprintf(w, "::error title=%s::%s\n", escapeActionsProperty(fmt.Sprintf("%s#%d", r.Repo, r.Number)), escapeActionsData(msg))
+	}
+}
+
+// escapeActionsData escapes a workflow command's message.
+func escapeActionsData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeActionsProperty escapes a workflow command property value.
+func escapeActionsProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// renderMarkdownReport renders the run as Markdown: a totals line, then a
+// table of every selected PR with its action and reason.
+func renderMarkdownReport(out runOutput) string {
+	merged, commented, skipped, errs := summarize(out.Results)
+	var b strings.Builder
+	title := "PR pipeline run"
+	if out.DryRun {
+		title += " (dry run)"
+	}
+	fmt.Fprintf(&b, "## %s\n\n", title)
+	fmt.Fprintf(&b, "Org `%s` · started %s · scanned %d\n\n", out.Org, out.StartedAt, out.Scanned)
+	fmt.Fprintf(&b, "**Merged** %d · **Commented** %d · **Skipped** %d · **Errors** %d\n\n", merged, commented, skipped, errs)
+	if out.Error != "" {
+		fmt.Fprintf(&b, "> **Run error:** %s\n\n", markdownCell(out.Error))
+	}
+	if out.Aborted != "" {
+		fmt.Fprintf(&b, "> **Run aborted:** %s\n\n", markdownCell(out.Aborted))
+	}
+	if out.Frozen != "" {
+		fmt.Fprintf(&b, "> **Frozen:** %s\n\n", markdownCell(out.Frozen))
+	}
+	if len(out.Results) == 0 {
+		b.WriteString("No PRs selected.\n")
+		return b.String()
+	}
+	b.WriteString("| PR | Author | Action | Reason |\n")
+	b.WriteString("|----|--------|--------|--------|\n")
+	for _, r := range out.Results {
+		pr := r.URL
+		if r.Repo != "" && r.Number > 0 {
+			pr = fmt.Sprintf("[%s#%d](%s)", r.Repo, r.Number, r.URL)
+		}
+		fmt.Fprintf(&b, "| %s | %s | `%s` | %s |\n", pr, markdownCell(r.Author), r.Action, markdownCell(r.Reason))
+	}
+	return b.String()
+}
+
+// markdownCell makes s safe to put in a Markdown table cell.
+func markdownCell(s string) string {
+	s = strings.NewReplacer("|", `\|`, "\r", "", "\n", " ").Replace(s)
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownReport(t *testing.T) {
+	out := runOutput{Org: "misty-step", StartedAt: "2026-01-02T03:04:05Z", Scanned: 7, Results: []prOutcome{
+		{Repo: "misty-step/a", Number: 1, URL: "https://github.com/misty-step/a/pull/1", Author: "kaylee", Action: "merged"},
+		{Repo: "misty-step/b", Number: 2, URL: "https://github.com/misty-step/b/pull/2", Author: "phaedrus", Action: "error", Reason: "gh failed | exit 1\nstderr"},
+	}}
+	md := renderMarkdownReport(out)
+	for _, want := range []string{
+		"**Merged** 1 · **Commented** 0 · **Skipped** 0 · **Errors** 1",
+		"| [misty-step/a#1](https://github.com/misty-step/a/pull/1) | kaylee | `merged` |  |",
+		`| gh failed \| exit 1 stderr |`,
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("missing %q in:\n%s", want, md)
+		}
+	}
+	if md := renderMarkdownReport(runOutput{}); !strings.Contains(md, "No PRs selected.") {
+		t.Errorf("empty run:\n%s", md)
+	}
+}
+
+func TestWriteActionsAnnotations(t *testing.T) {
+	var buf bytes.Buffer
+	writeActionsAnnotations(&buf, runOutput{Error: "scan failed", Results: []prOutcome{
+		{Repo: "misty-step/a", Number: 1, URL: "https://github.com/misty-step/a/pull/1", Action: "merged"},
+		{Repo: "misty-step/b", Number: 2, URL: "https://github.com/misty-step/b/pull/2", Action: "error", Reason: "100% broken\nsee logs"},
+	}})
+	want := "::error title=PR pipeline run failed::scan failed\n" +
+		"::error title=misty-step/b#2::https://github.com/misty-step/b/pull/2: 100%25 broken%0Asee logs\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteActionsSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", path)
+
+	t.Setenv("GITHUB_ACTIONS", "")
+	writeActionsSummary(runOutput{})
+	if _, err := os.Stat(path); err == nil {
+		t.Fatal("wrote a step summary outside Actions")
+	}
+
+	t.Setenv("GITHUB_ACTIONS", "true")
+	writeActionsSummary(runOutput{Org: "misty-step"})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "## PR pipeline run") {
+		t.Errorf("summary:\n%s", data)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// githubAPIURL is the REST API root used for app token minting.
+var githubAPIURL = "https://api.github.com"
+
+// ghTokenSource, when set (--app-id), supplies the GH_TOKEN for every gh
+// and git command runCmd starts.
+var ghTokenSource *appTokenSource
+
+// appTokenRefreshMargin is how long before expiry an installation token is
+// replaced, so a token never lapses mid-command.
+const appTokenRefreshMargin = 5 * time.Minute
+
+// appTokenSource mints and caches GitHub App installation tokens.
+type appTokenSource struct {
+	appID          int64
+	key            *rsa.PrivateKey
+	installationID int64
+	org            string
+	client         *http.Client
+	now            func() time.Time
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newAppTokenSource(appID int64, keyPath string, installationID int64, org string) (*appTokenSource, error) {
+	pemBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read app private key: %w", err)
+	}
+	key, err := parseAppPrivateKey(pemBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &appTokenSource{
+		appID:          appID,
+		key:            key,
+		installationID: installationID,
+		org:            org,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		now:            time.Now,
+	}, nil
+}
+
+// parseAppPrivateKey parses the PEM private key GitHub issues for an app
+// (PKCS#1), also accepting PKCS#8.
+func parseAppPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("app private key: no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("app private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("app private key: not an RSA key")
+	}
+	return key, nil
+}
+
+// appJWT returns the short-lived RS256 JWT that authenticates as the app
+// itself. iat is backdated a minute to allow for clock drift.
+func appJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign app jwt: %w", err)
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
+
+// Token returns a valid installation token, minting a new one when there is
+// none or the cached one is about to expire.
+func (s *appTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.token != "" && now.Add(appTokenRefreshMargin).Before(s.expires) {
+		return s.token, nil
+	}
+	jwt, err := appJWT(s.appID, s.key, now)
+	if err != nil {
+		return "", err
+	}
+	if s.installationID == 0 {
+		var inst struct {
+			ID int64 `json:"id"`
+		}
+		if err := s.do(ctx, "GET", "/orgs/"+s.org+"/installation", jwt, &inst); err != nil {
+			return "", fmt.Errorf("find app installation for %s: %w", s.org, err)
+		}
+		s.installationID = inst.ID
+	}
+	var tok struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := s.do(ctx, "POST", fmt.Sprintf("/app/installations/%d/access_tokens", s.installationID), jwt, &tok); err != nil {
+		return "", fmt.Errorf("mint installation token: %w", err)
+	}
+	if tok.Token == "" {
+		return "", errors.New("mint installation token: empty token in response")
+	}
+	s.token, s.expires = tok.Token, tok.ExpiresAt
+	addRedaction(s.token)
+	return s.token, nil
+}
+
+// do makes an app-authenticated API request and decodes the JSON response.
+func (s *appTokenSource) do(ctx context.Context, method string, path string, jwt string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, githubAPIURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return json.Unmarshal(body, v)
+}
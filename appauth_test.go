@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testAppKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func TestParseAppPrivateKey(t *testing.T) {
+	key := testAppKey(t)
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	for name, raw := range map[string][]byte{"pkcs1": pkcs1, "pkcs8": pkcs8} {
+		got, err := parseAppPrivateKey(raw)
+		if err != nil || !got.Equal(key) {
+			t.Errorf("%s: parseAppPrivateKey = %v", name, err)
+		}
+	}
+	if _, err := parseAppPrivateKey([]byte("not pem")); err == nil {
+		t.Error("expected error for non-PEM input")
+	}
+}
+
+func TestAppJWT(t *testing.T) {
+	key := testAppKey(t)
+	now := time.Unix(1700000000, 0)
+	jwt, err := appJWT(12345, key, now)
+	if err != nil {
+		t.Fatalf("appJWT: %v", err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("jwt has %d parts", len(parts))
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims struct {
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+		Iss string `json:"iss"`
+	}
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Iss != "12345" || claims.Iat != now.Unix()-60 || claims.Exp != now.Unix()+540 {
+		t.Errorf("claims = %+v", claims)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
+func TestAppTokenSource(t *testing.T) {
+	var minted atomic.Int32
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/orgs/misty-step/installation":
+			fmt.Fprint(w, `{"id":99}`)
+		case r.Method == "POST" && r.URL.Path == "/app/installations/99/access_tokens":
+			n := minted.Add(1)
+			fmt.Fprintf(w, `{"token":"ghs_%d","expires_at":%q}`, n, now.Add(time.Hour).Format(time.RFC3339))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	oldURL := githubAPIURL
+	githubAPIURL = srv.URL
+	defer func() { githubAPIURL = oldURL }()
+
+	keyPath := filepath.Join(t.TempDir(), "app.pem")
+	key := testAppKey(t)
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := newAppTokenSource(1, keyPath, 0, "misty-step")
+	if err != nil {
+		t.Fatalf("newAppTokenSource: %v", err)
+	}
+	clock := now
+	src.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	tok, err := src.Token(ctx)
+	if err != nil || tok != "ghs_1" {
+		t.Fatalf("first Token = %q, %v; want ghs_1", tok, err)
+	}
+	if src.installationID != 99 {
+		t.Errorf("installationID = %d; want 99 (looked up from the org)", src.installationID)
+	}
+
+	clock = now.Add(30 * time.Minute)
+	if tok, _ := src.Token(ctx); tok != "ghs_1" {
+		t.Errorf("Token mid-lifetime = %q; want cached ghs_1", tok)
+	}
+
+	clock = now.Add(56 * time.Minute)
+	if tok, _ := src.Token(ctx); tok != "ghs_2" {
+		t.Errorf("Token near expiry = %q; want refreshed ghs_2", tok)
+	}
+
+	bad := &appTokenSource{appID: 1, key: key, installationID: 5, client: srv.Client(), now: time.Now}
+	if _, err := bad.Token(ctx); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Token for unknown installation = %v; want a 404 error", err)
+	}
+}
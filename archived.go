@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type repoInfo struct {
+	Name          string `json:"name"`
+	NameWithOwner string `json:"nameWithOwner"`
+	IsArchived    bool   `json:"isArchived"`
+}
+
+// repoPage is one page of an owner's archived repositories.
+type repoPage struct {
+	Repos     []repoInfo
+	EndCursor string
+	HasNext   bool
+}
+
+const archivedReposQuery = `query($login: String!, $after: String) {
+  repositoryOwner(login: $login) {
+    repositories(first: 100, after: $after, isArchived: true) {
+      pageInfo { endCursor hasNextPage }
+      nodes { name nameWithOwner isArchived }
+    }
+  }
+}`
+
+// fetchArchivedRepos fetches every archived repo owned by org, paging until
+// the list is exhausted, and returns them as a set of names.
+func fetchArchivedRepos(ctx context.Context, org string) (map[string]bool, error) {
+	return collectArchivedRepos(ctx, func(after string) (repoPage, error) {
+		return ghArchivedReposPage(ctx, org, after)
+	})
+}
+
+// collectArchivedRepos calls fetch until there are no more pages.
+func collectArchivedRepos(ctx context.Context, fetch func(after string) (repoPage, error)) (map[string]bool, error) {
+	archived := make(map[string]bool)
+	after := ""
+	for {
+		page, err := RetryableWithResult(ctx, func() (repoPage, error) {
+			return fetch(after)
+		}, retryCfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page.Repos {
+			if r.IsArchived {
+				archived[r.NameWithOwner] = true
+			}
+		}
+		if !page.HasNext || page.EndCursor == "" {
+			return archived, nil
+		}
+		after = page.EndCursor
+	}
+}
+
+func ghArchivedReposPage(ctx context.Context, org string, after string) (repoPage, error) {
+	args := []string{
+		"api", "graphql",
+		"-f", "query=" + archivedReposQuery,
+		"-f", "login=" + org,
+	}
+	if after != "" {
+		args = append(args, "-f", "after="+after)
+	}
+	out, err := runGh(ctx, args...)
+	if err != nil {
+		return repoPage{}, err
+	}
+	return parseArchivedReposPage(out)
+}
+
+func parseArchivedReposPage(raw []byte) (repoPage, error) {
+	var resp struct {
+		Data struct {
+			RepositoryOwner *struct {
+				Repositories struct {
+					PageInfo struct {
+						EndCursor   string `json:"endCursor"`
+						HasNextPage bool   `json:"hasNextPage"`
+					} `json:"pageInfo"`
+					Nodes []repoInfo `json:"nodes"`
+				} `json:"repositories"`
+			} `json:"repositoryOwner"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return repoPage{}, fmt.Errorf("parse archived repos json: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return repoPage{}, fmt.Errorf("archived repos query failed: %s", resp.Errors[0].Message)
+	}
+	owner := resp.Data.RepositoryOwner
+	if owner == nil {
+		return repoPage{}, fmt.Errorf("archived repos query failed: owner not found")
+	}
+	return repoPage{
+		Repos:     owner.Repositories.Nodes,
+		EndCursor: owner.Repositories.PageInfo.EndCursor,
+		HasNext:   owner.Repositories.PageInfo.HasNextPage,
+	}, nil
+}
+
+// archivedCache is the on-disk copy of an org's archived repos.
+type archivedCache struct {
+	Org       string   `json:"org"`
+	FetchedAt string   `json:"fetchedAt"`
+	Repos     []string `json:"repos"`
+}
+
+// archivedCachePath returns where org's archived-repo cache lives, beside
+// the dedup state file.
+func archivedCachePath(statePath string, org string) string {
+	return filepath.Join(filepath.Dir(statePath), "archived-repos-"+org+".json")
+}
+
+// readArchivedCache returns the cached set for org if it is younger than ttl.
+func readArchivedCache(path string, org string, ttl time.Duration, now time.Time) (map[string]bool, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var c archivedCache
+	if err := json.Unmarshal(data, &c); err != nil || c.Org != org {
+		return nil, false
+	}
+	fetchedAt, err := time.Parse(time.RFC3339, c.FetchedAt)
+	if err != nil || now.Sub(fetchedAt) >= ttl {
+		return nil, false
+	}
+	archived := make(map[string]bool, len(c.Repos))
+	for _, r := range c.Repos {
+		archived[r] = true
+	}
+	return archived, true
+}
+
+func writeArchivedCache(path string, org string, archived map[string]bool, now time.Time) error {
+	c := archivedCache{Org: org, FetchedAt: now.UTC().Format(time.RFC3339), Repos: []string{}}
+	for r := range archived {
+		c.Repos = append(c.Repos, r)
+	}
+	sort.Strings(c.Repos)
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadArchivedRepos returns org's archived repos, from the cache at path when
+// ttl > 0 and it is fresh, otherwise from GitHub (refreshing the cache).
+func loadArchivedRepos(ctx context.Context, org string, path string, ttl time.Duration, now time.Time) (map[string]bool, error) {
+	if ttl <= 0 {
+		return fetchArchivedRepos(ctx, org)
+	}
+	if archived, ok := readArchivedCache(path, org, ttl, now); ok {
+		return archived, nil
+	}
+	archived, err := fetchArchivedRepos(ctx, org)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeArchivedCache(path, org, archived, now); err != nil {
+		fmt.Fprintf(os.Stderr, "[archived-repos] failed to save cache: %v\n", err)
+	}
+	return archived, nil
+}
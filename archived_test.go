@@ -2,7 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 // TestParseArchivedRepos tests parsing of gh repo list JSON output.
@@ -175,3 +178,64 @@ func TestArchivedReposIntegration(t *testing.T) {
 		t.Errorf("expected 2 processed, got %d", len(processed))
 	}
 }
+
+func TestParseArchivedReposPage(t *testing.T) {
+	raw := `{"data":{"repositoryOwner":{"repositories":{"pageInfo":{"endCursor":"abc","hasNextPage":true},"nodes":[{"name":"old","nameWithOwner":"m/old","isArchived":true}]}}}}`
+	page, err := parseArchivedReposPage([]byte(raw))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !page.HasNext || page.EndCursor != "abc" || len(page.Repos) != 1 || page.Repos[0].NameWithOwner != "m/old" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+	if _, err := parseArchivedReposPage([]byte(`{"data":{"repositoryOwner":null}}`)); err == nil {
+		t.Error("expected error for unknown owner")
+	}
+}
+
+// TestCollectArchivedRepos checks pagination runs to completion past 200 repos.
+func TestCollectArchivedRepos(t *testing.T) {
+	pages := map[string]repoPage{}
+	cursor := ""
+	for p := 0; p < 3; p++ {
+		var page repoPage
+		for i := 0; i < 100; i++ {
+			page.Repos = append(page.Repos, repoInfo{NameWithOwner: fmt.Sprintf("m/r%d", p*100+i), IsArchived: true})
+		}
+		next := fmt.Sprintf("p%d", p+1)
+		page.EndCursor, page.HasNext = next, p < 2
+		pages[cursor] = page
+		cursor = next
+	}
+	archived, err := collectArchivedRepos(t.Context(), func(after string) (repoPage, error) {
+		return pages[after], nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(archived) != 300 || !archived["m/r299"] {
+		t.Errorf("expected all 300 archived repos, got %d", len(archived))
+	}
+}
+
+func TestArchivedCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archived-repos.json")
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	if _, ok := readArchivedCache(path, "m", time.Hour, now); ok {
+		t.Fatal("missing cache should miss")
+	}
+	if err := writeArchivedCache(path, "m", map[string]bool{"m/old": true}, now); err != nil {
+		t.Fatalf("writeArchivedCache: %v", err)
+	}
+	got, ok := readArchivedCache(path, "m", time.Hour, now.Add(30*time.Minute))
+	if !ok || !got["m/old"] {
+		t.Errorf("fresh cache should hit, got %v %v", got, ok)
+	}
+	if _, ok := readArchivedCache(path, "m", time.Hour, now.Add(2*time.Hour)); ok {
+		t.Error("expired cache should miss")
+	}
+	if _, ok := readArchivedCache(path, "other-org", time.Hour, now); ok {
+		t.Error("cache for a different org should miss")
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditEntry is one mutating GitHub call in the audit log.
+type auditEntry struct {
+	Time time.Time `json:"time"`
+	// RequestID is unique to this call (the log's session plus a sequence
+	// number), to cite when tracing an incident.
+	RequestID string `json:"requestId"`
+	Action    string `json:"action"`
+	// Target is what the call acted on: a PR URL, a node ID, or an API path.
+	Target  string `json:"target,omitempty"`
+	PR      string `json:"pr,omitempty"`
+	HeadSHA string `json:"headSha,omitempty"`
+	// Actor identifies the credential the call was made with, never the
+	// credential itself.
+	Actor      string `json:"actor"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+// auditLog is the append-only record of every merge, comment, branch
+// update, review dismissal, and other change the pipeline makes on GitHub,
+// one JSON object per line. Entries are only ever appended, so the file
+// spans every run that shares it. A nil *auditLog records nothing.
+type auditLog struct {
+	path    string
+	session string
+	now     func() time.Time
+
+	mu  sync.Mutex
+	seq int
+}
+
+// auditLogPath returns the default log path, beside the dedup state file.
+func auditLogPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "audit.jsonl")
+}
+
+func newAuditLog(path string) *auditLog {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return &auditLog{path: path, session: hex.EncodeToString(b), now: time.Now}
+}
+
+// auditLogFile is where the audit log is kept: --audit-log, or beside the
+// state file.
+func (o *runOptions) auditLogFile() string {
+	if o.AuditLog != "" {
+		return o.AuditLog
+	}
+	return auditLogPath(resolveStatePath(o.StateFile))
+}
+
+// loadAuditLog reads the entries in the log at path, oldest first. A
+// missing file has none; a line that doesn't parse, such as one cut short
+// by a crash, is skipped with a warning.
+func loadAuditLog(path string) ([]auditEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []auditEntry
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var e auditEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			fmt.Fprintf(os.Stderr, "[audit] %s:%d: skipping unreadable entry: %v\n", path, i+1, err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// nextRequestID numbers the log's calls within the session.
+func (l *auditLog) nextRequestID() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	return fmt.Sprintf("%s-%d", l.session, l.seq)
+}
+
+// record appends e to the log. A write failure is logged, not returned:
+// the action it describes has already happened.
+func (l *auditLog) record(e auditEntry) {
+	e.Error = redact(e.Error)
+	line, err := json.Marshal(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[audit] encode %s %s: %v\n", e.Action, e.RequestID, err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "[audit] write %s: %v\n", l.path, err)
+		return
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[audit] write %s: %v\n", l.path, err)
+		return
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[audit] write %s: %v\n", l.path, err)
+	}
+}
+
+// track runs fn, a mutating call, and records it under action.
+func (l *auditLog) track(ctx context.Context, action string, target string, fn func(ctx context.Context) error) error {
+	e := &auditEntry{RequestID: l.nextRequestID(), Action: action, Target: target, Actor: ghActor("")}
+	if pr := auditPRFrom(ctx); pr != nil {
+		e.PR, e.HeadSHA = pr.url, pr.headSHA
+	}
+	start := l.now()
+	err := fn(context.WithValue(ctx, auditEntryKey{}, e))
+	e.Time = start.UTC()
+	e.DurationMS = l.now().Sub(start).Milliseconds()
+	e.OK = err == nil
+	if err != nil {
+		e.Error = err.Error()
+	}
+	l.record(*e)
+	return err
+}
+
+// wrap returns next with its mutating calls recorded to l, or next itself
+// when l is nil.
+func (l *auditLog) wrap(next GitHubClient) GitHubClient {
+	if l == nil {
+		return next
+	}
+	return &auditClient{next: next, log: l}
+}
+
+// auditClient passes every call through to next, recording the ones that
+// change something on GitHub.
+type auditClient struct {
+	next GitHubClient
+	log  *auditLog
+}
+
+func (c *auditClient) Run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	action, target, ok := auditAction(args)
+	if !ok {
+		return c.next.Run(ctx, stdin, args...)
+	}
+	var out []byte
+	err := c.log.track(ctx, action, target, func(ctx context.Context) error {
+		var err error
+		out, err = c.next.Run(ctx, stdin, args...)
+		return err
+	})
+	return out, err
+}
+
+// auditGit records a git command that changes GitHub (a rebased branch's
+// force-push) to the audit log of the client under ctx, if it has one.
+func auditGit(ctx context.Context, action string, target string, fn func(ctx context.Context) error) error {
+	if c, ok := githubClientFor(ctx).(*auditClient); ok {
+		return c.log.track(ctx, action, target, fn)
+	}
+	return fn(ctx)
+}
+
+// auditPR is the PR the calls under a context are made for.
+type auditPR struct {
+	url     string
+	headSHA string
+}
+
+type auditPRKey struct{}
+
+type auditEntryKey struct{}
+
+// withAuditPR returns ctx with the PR, at the head commit the pipeline
+// looked at, that audit entries for calls under it are about.
+func withAuditPR(ctx context.Context, url string, headSHA string) context.Context {
+	return context.WithValue(ctx, auditPRKey{}, &auditPR{url: url, headSHA: headSHA})
+}
+
+func auditPRFrom(ctx context.Context) *auditPR {
+	pr, _ := ctx.Value(auditPRKey{}).(*auditPR)
+	return pr
+}
+
+// noteAuditActor tells the audit entry for the call under ctx, if any, which
+// token the command ran with. A call retried with another pool token ends
+// up naming the last.
+func noteAuditActor(ctx context.Context, token string) {
+	if e, ok := ctx.Value(auditEntryKey{}).(*auditEntry); ok {
+		e.Actor = ghActor(token)
+	}
+}
+
+// ghActor names the credential a gh command runs with: a pool token, the
+// GitHub App, GH_TOKEN, or gh's own login. Tokens appear only as a short
+// SHA-256 fingerprint.
+func ghActor(token string) string {
+	switch {
+	case token != "" && ghTokenPool != nil:
+		return ghTokenPool.label(token) + " " + tokenFingerprint(token)
+	case token != "":
+		return "token " + tokenFingerprint(token)
+	case ghTokenSource != nil:
+		return fmt.Sprintf("app %d installation %d", ghTokenSource.appID, ghTokenSource.installationID)
+	}
+	for _, name := range []string{"GH_TOKEN", "GITHUB_TOKEN"} {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			return name + " " + tokenFingerprint(v)
+		}
+	}
+	return "gh login"
+}
+
+// tokenFingerprint identifies a token without revealing it.
+func tokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// auditPRCommands are the gh pr and gh issue subcommands that change
+// something.
+var auditPRCommands = map[string]bool{
+	"comment": true, "close": true, "reopen": true, "ready": true, "merge": true,
+	"edit": true, "review": true, "update-branch": true, "create": true,
+}
+
+// auditMutations names the GraphQL mutations the pipeline makes; any other
+// is logged under its own name.
+var auditMutations = map[string]string{
+	"mergePullRequest":           "merge",
+	"dismissPullRequestReview":   "dismiss_review",
+	"enablePullRequestAutoMerge": "enable_auto_merge",
+	"enqueuePullRequest":         "enqueue",
+	"revertPullRequest":          "revert",
+}
+
+var graphQLMutationRe = regexp.MustCompile(`^\s*mutation\b[^{]*\{\s*(\w+)`)
+
+// auditAction reports whether the gh command args changes something on
+// GitHub, and if so what it does and to what. REST calls count when their
+// method isn't GET; like gh, a call with fields and no -X is a POST.
+func auditAction(args []string) (action string, target string, ok bool) {
+	if len(args) < 2 {
+		return "", "", false
+	}
+	switch args[0] {
+	case "pr", "issue":
+		if !auditPRCommands[args[1]] {
+			return "", "", false
+		}
+		action = strings.ReplaceAll(args[1], "-", "_")
+		if args[0] == "issue" {
+			action = "issue_" + action
+		}
+		if len(args) > 2 && !strings.HasPrefix(args[2], "-") {
+			target = args[2]
+		}
+		return action, target, true
+	case "run":
+		// Re-running a workflow run's failed jobs.
+		if args[1] != "rerun" {
+			return "", "", false
+		}
+		if len(args) > 2 && !strings.HasPrefix(args[2], "-") {
+			target = args[2]
+		}
+		return "run_rerun", target, true
+	case "api":
+	default:
+		return "", "", false
+	}
+	if args[1] == "graphql" {
+		fields := map[string]string{}
+		for i := 2; i+1 < len(args); i++ {
+			if args[i] == "-f" || args[i] == "-F" {
+				k, v, _ := strings.Cut(args[i+1], "=")
+				fields[k] = v
+				i++
+			}
+		}
+		m := graphQLMutationRe.FindStringSubmatch(fields["query"])
+		if m == nil {
+			return "", "", false
+		}
+		action = m[1]
+		if name, ok := auditMutations[m[1]]; ok {
+			action = name
+		}
+		target = fields["pullRequestId"]
+		if target == "" {
+			target = fields["reviewId"]
+		}
+		return action, target, true
+	}
+	method, path, hasFields := "", "", false
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "-X", "--method":
+			if i+1 < len(args) {
+				method = strings.ToUpper(args[i+1])
+			}
+			i++
+		case "-f", "-F", "--field", "--raw-field", "--input":
+			hasFields = true
+			i++
+		case "-H", "--header", "--jq", "-q", "--template", "-t", "--cache", "--hostname":
+			i++
+		default:
+			if path == "" && !strings.HasPrefix(args[i], "-") {
+				path = args[i]
+			}
+		}
+	}
+	if method == "" && hasFields {
+		method = "POST"
+	}
+	if method == "" || method == "GET" || method == "HEAD" {
+		return "", "", false
+	}
+	return "api_" + strings.ToLower(method), path, true
+}
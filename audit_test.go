@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditAction(t *testing.T) {
+	tests := []struct {
+		args       []string
+		wantAction string
+		wantTarget string
+		wantOK     bool
+	}{
+		{[]string{"pr", "comment", "https://github.com/o/r/pull/1", "--body", "hi"}, "comment", "https://github.com/o/r/pull/1", true},
+		{[]string{"pr", "update-branch", "https://github.com/o/r/pull/1"}, "update_branch", "https://github.com/o/r/pull/1", true},
+		{[]string{"issue", "close", "7", "-R", "o/r"}, "issue_close", "7", true},
+		{[]string{"run", "rerun", "12345", "-R", "o/r", "--failed"}, "run_rerun", "12345", true},
+		{[]string{"run", "view", "12345", "-R", "o/r", "--json", "attempt"}, "", "", false},
+		{[]string{"pr", "view", "https://github.com/o/r/pull/1", "--json", "title"}, "", "", false},
+		{[]string{"api", "graphql", "-f", "query=mutation($pullRequestId: ID!) {\n  mergePullRequest(input: {}) { x }\n}", "-f", "pullRequestId=PR_1"}, "merge", "PR_1", true},
+		{[]string{"api", "graphql", "-f", "query=mutation($reviewId: ID!) { dismissPullRequestReview(input: {}) { x } }", "-f", "reviewId=R_1"}, "dismiss_review", "R_1", true},
+		{[]string{"api", "graphql", "-f", "query=mutation { addLabelsToLabelable(input: {}) { x } }"}, "addLabelsToLabelable", "", true},
+		{[]string{"api", "graphql", "-f", "query=query { viewer { login } }"}, "", "", false},
+		{[]string{"api", "-X", "PATCH", "repos/o/r/issues/comments/5", "-f", "body=x"}, "api_patch", "repos/o/r/issues/comments/5", true},
+		{[]string{"api", "repos/o/r/dispatches", "--input", "-"}, "api_post", "repos/o/r/dispatches", true},
+		{[]string{"api", "repos/o/r/pulls/1/files", "--paginate", "--jq", ".[].filename"}, "", "", false},
+		{[]string{"api", "-X", "GET", "search/issues", "-f", "q=is:pr"}, "", "", false},
+	}
+	for _, tt := range tests {
+		action, target, ok := auditAction(tt.args)
+		if action != tt.wantAction || target != tt.wantTarget || ok != tt.wantOK {
+			t.Errorf("auditAction(%q) = %q, %q, %t; want %q, %q, %t", tt.args, action, target, ok, tt.wantAction, tt.wantTarget, tt.wantOK)
+		}
+	}
+}
+
+func readAuditLog(t *testing.T, path string) []auditEntry {
+	t.Helper()
+	entries, err := loadAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestAuditLogRecordsRun(t *testing.T) {
+	green := fakePR("misty-step/api", 1)
+	failing := fakePR("misty-step/api", 2)
+	failing.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "lint", Status: "COMPLETED", Conclusion: "FAILURE"}}
+	failing.HeadRefOid = "def456"
+	fake := newFakeGitHub(green, failing)
+	useFakeGitHub(t, fake)
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+
+	dry := testPipelineOptions(t, "-audit-log", path, "-dry-run")
+	if _, err := newPipeline(dry).Run(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if entries := readAuditLog(t, path); len(entries) != 0 {
+		t.Fatalf("dry run logged %+v; want nothing", entries)
+	}
+
+	opts := testPipelineOptions(t, "-audit-log", path)
+	if _, err := newPipeline(opts).Run(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	entries := readAuditLog(t, path)
+	byPR := map[string][]auditEntry{}
+	ids := map[string]bool{}
+	for _, e := range entries {
+		byPR[e.PR] = append(byPR[e.PR], e)
+		if e.RequestID == "" || ids[e.RequestID] {
+			t.Errorf("entry %+v: request ID empty or repeated", e)
+		}
+		ids[e.RequestID] = true
+		if e.Time.IsZero() || e.Actor == "" {
+			t.Errorf("entry %+v: want a time and an actor", e)
+		}
+	}
+	merged := byPR[green.URL]
+	if len(merged) != 1 || merged[0].Action != "merge" || merged[0].HeadSHA != "abc123" || merged[0].Target != green.ID || !merged[0].OK {
+		t.Errorf("green PR entries = %+v; want one ok merge at abc123", merged)
+	}
+	commented := byPR[failing.URL]
+	if len(commented) != 1 || commented[0].Action != "comment" || commented[0].HeadSHA != "def456" {
+		t.Errorf("failing PR entries = %+v; want one comment at def456", commented)
+	}
+
+	// A failed call is logged too, and a second run appends.
+	fake.mergeErrors[green.ID] = errors.New("GH_TOKEN=ghp_" + strings.Repeat("x", 36) + " refused")
+	again := testPipelineOptions(t, "-audit-log", path)
+	if _, err := newPipeline(again).Run(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	after := readAuditLog(t, path)
+	if len(after) <= len(entries) {
+		t.Fatalf("second run left %d entries; want more than %d", len(after), len(entries))
+	}
+	var failed *auditEntry
+	for i := range after[len(entries):] {
+		if e := &after[len(entries)+i]; e.Action == "merge" {
+			failed = e
+		}
+	}
+	if failed == nil || failed.OK || !strings.Contains(failed.Error, redactedMark) || strings.Contains(failed.Error, "xxxx") {
+		t.Errorf("failed merge entry = %+v; want it logged, not ok, with the token redacted", failed)
+	}
+}
+
+func TestGHActor(t *testing.T) {
+	old := ghTokenPool
+	t.Cleanup(func() { ghTokenPool = old })
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "")
+
+	ghTokenPool = newTokenPool([]string{"tok-aaaaaaaa", "tok-bbbbbbbb"})
+	got := ghActor("tok-bbbbbbbb")
+	if !strings.HasPrefix(got, "token 2 sha256:") || strings.Contains(got, "bbbbbbbb") {
+		t.Errorf("ghActor(pool token) = %q; want token 2 and a fingerprint", got)
+	}
+	ghTokenPool = nil
+	if got := ghActor(""); got != "gh login" {
+		t.Errorf("ghActor() = %q; want gh login", got)
+	}
+	t.Setenv("GH_TOKEN", "tok-cccccccc")
+	if got := ghActor(""); !strings.HasPrefix(got, "GH_TOKEN sha256:") {
+		t.Errorf("ghActor() with GH_TOKEN = %q; want its fingerprint", got)
+	}
+}
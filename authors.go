@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Author modes control how the pipeline treats PRs from a given login.
+const (
+	// authorModeImmediate acts on the PR as soon as it is selected.
+	authorModeImmediate = "immediate"
+	// authorModeStale waits until the PR is untouched for StaleHours.
+	authorModeStale = "stale"
+	// authorModeCommentOnly never merges; it only comments with blockers.
+	authorModeCommentOnly = "comment-only"
+	// authorModeSkip ignores the author's PRs entirely.
+	authorModeSkip = "skip"
+	// authorModeDependency is for dependency bots: squash-merge patch and
+	// minor updates, hold major ones for a human.
+	authorModeDependency = "dependency"
+)
+
+// authorDefaultKey is the --authors/config key for logins with no entry.
+const authorDefaultKey = "*"
+
+// Author groups are keys that cover every author of a kind with no entry
+// of their own. They're checked before "*".
+const (
+	// authorGroupBots is GitHub App accounts, such as dependabot[bot].
+	authorGroupBots = "@bots"
+	// authorGroupHumans is every other account.
+	authorGroupHumans = "@humans"
+)
+
+// authorPolicy is the behavior profile for one PR author.
+type authorPolicy struct {
+	Mode       string `json:"mode"`
+	StaleHours int    `json:"staleHours,omitempty"`
+}
+
+// authorPolicies maps authorKey(login) -> policy; "*" is the fallback.
+type authorPolicies map[string]authorPolicy
+
+// authorKey normalizes a login for lookup. Bots show up as "dependabot",
+// "dependabot[bot]", or "app/dependabot" depending on the API, so all three
+// share a key.
+func authorKey(login string) string {
+	login = strings.ToLower(strings.TrimSpace(login))
+	return strings.TrimSuffix(strings.TrimPrefix(login, "app/"), "[bot]")
+}
+
+// policyFor returns the profile for login, falling back to its group
+// (bot says GitHub reported the account as an app), to "*", and then to
+// acting immediately.
+func (a authorPolicies) policyFor(login string, bot bool) authorPolicy {
+	if p, ok := a[authorKey(login)]; ok {
+		return p
+	}
+	group := authorGroupHumans
+	if bot || isBotLogin(login) {
+		group = authorGroupBots
+	}
+	if p, ok := a[group]; ok {
+		return p
+	}
+	if p, ok := a[authorDefaultKey]; ok {
+		return p
+	}
+	return authorPolicy{Mode: authorModeImmediate}
+}
+
+// isBotLogin reports whether login is written the way the REST API and gh
+// write app accounts. The GraphQL search writes them bare, so it reports
+// bots separately.
+func isBotLogin(login string) bool {
+	login = strings.ToLower(strings.TrimSpace(login))
+	return strings.HasSuffix(login, "[bot]") || strings.HasPrefix(login, "app/")
+}
+
+// validateAuthorKey rejects an author key starting with @ that isn't a
+// group; it would never match a login.
+func validateAuthorKey(key string) error {
+	if strings.HasPrefix(key, "@") && key != authorGroupBots && key != authorGroupHumans {
+		return fmt.Errorf("unknown author group %q (want %s or %s)", key, authorGroupBots, authorGroupHumans)
+	}
+	return nil
+}
+
+// staleAuthorPolicies turns the config's staleHours matrix (login or
+// group -> hours) into author profiles; 0 hours acts immediately.
+func staleAuthorPolicies(matrix map[string]int) map[string]authorPolicy {
+	out := make(map[string]authorPolicy, len(matrix))
+	for login, hours := range matrix {
+		if hours == 0 {
+			out[login] = authorPolicy{Mode: authorModeImmediate}
+		} else {
+			out[login] = authorPolicy{Mode: authorModeStale, StaleHours: hours}
+		}
+	}
+	return out
+}
+
+// merge overlays other onto a, returning a.
+func (a authorPolicies) merge(other map[string]authorPolicy) authorPolicies {
+	for login, p := range other {
+		a[authorKey(login)] = p
+	}
+	return a
+}
+
+// defaultAuthorPolicies reproduces the historical behavior: Phaedrus waits
+// for the stale threshold, Kaylee and everyone else act immediately.
+func defaultAuthorPolicies(phaedrus string, staleHours int, kaylee string) authorPolicies {
+	a := authorPolicies{}
+	if kaylee = strings.TrimSpace(kaylee); kaylee != "" {
+		a[authorKey(kaylee)] = authorPolicy{Mode: authorModeImmediate}
+	}
+	if phaedrus = strings.TrimSpace(phaedrus); phaedrus != "" {
+		a[authorKey(phaedrus)] = authorPolicy{Mode: authorModeStale, StaleHours: staleHours}
+	}
+	return a
+}
+
+// parseAuthorPolicies parses the --authors flag:
+//
+//	login=immediate,login=stale:72,login=comment-only,login=skip,login=dependency,*=immediate
+func parseAuthorPolicies(raw string) (map[string]authorPolicy, error) {
+	out := make(map[string]authorPolicy)
+	for _, entry := range splitList(raw) {
+		login, spec, ok := strings.Cut(entry, "=")
+		login = strings.TrimSpace(login)
+		if !ok || login == "" {
+			return nil, fmt.Errorf("bad --authors entry %q (want login=mode)", entry)
+		}
+		mode, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		p := authorPolicy{Mode: strings.ToLower(strings.TrimSpace(mode))}
+		if p.Mode == authorModeStale {
+			hours, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil || hours < 0 {
+				return nil, fmt.Errorf("bad --authors entry %q (want %s=stale:<hours>)", entry, login)
+			}
+			p.StaleHours = hours
+		} else if arg != "" {
+			return nil, fmt.Errorf("bad --authors entry %q (only stale takes an argument)", entry)
+		}
+		if err := validateAuthorKey(login); err != nil {
+			return nil, fmt.Errorf("--authors: %w", err)
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("--authors %s: %w", login, err)
+		}
+		out[strings.ToLower(login)] = p
+	}
+	return out, nil
+}
+
+func (p authorPolicy) validate() error {
+	switch p.Mode {
+	case authorModeImmediate, authorModeCommentOnly, authorModeSkip, authorModeDependency:
+		return nil
+	case authorModeStale:
+		if p.StaleHours < 0 {
+			return fmt.Errorf("staleHours must be >= 0")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown mode %q", p.Mode)
+	}
+}
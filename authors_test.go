@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseAuthorPolicies(t *testing.T) {
+	got, err := parseAuthorPolicies("Phrazzld=stale:48, kaylee-mistystep=immediate,renovate[bot]=skip,alice=comment-only,*=stale:24")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]authorPolicy{
+		"phrazzld":         {Mode: authorModeStale, StaleHours: 48},
+		"kaylee-mistystep": {Mode: authorModeImmediate},
+		"renovate[bot]":    {Mode: authorModeSkip},
+		"alice":            {Mode: authorModeCommentOnly},
+		"*":                {Mode: authorModeStale, StaleHours: 24},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d entries, got %d: %v", len(want), len(got), got)
+	}
+	for login, w := range want {
+		if got[login] != w {
+			t.Errorf("%s: got %+v, want %+v", login, got[login], w)
+		}
+	}
+}
+
+func TestParseAuthorPolicies_errors(t *testing.T) {
+	bad := []string{
+		"alice",
+		"=immediate",
+		"alice=stale",
+		"alice=stale:-1",
+		"alice=immediate:3",
+		"alice=yolo",
+	}
+	for _, raw := range bad {
+		if _, err := parseAuthorPolicies(raw); err == nil {
+			t.Errorf("parseAuthorPolicies(%q) expected error", raw)
+		}
+	}
+}
+
+func TestAuthorPolicies_policyFor(t *testing.T) {
+	a := defaultAuthorPolicies("phrazzld", 72, "kaylee-mistystep")
+	if p := a.policyFor("PHRAZZLD", false); p.Mode != authorModeStale || p.StaleHours != 72 {
+		t.Errorf("phaedrus should default to stale:72, got %+v", p)
+	}
+	if p := a.policyFor("kaylee-mistystep", false); p.Mode != authorModeImmediate {
+		t.Errorf("kaylee should act immediately, got %+v", p)
+	}
+	if p := a.policyFor("stranger", false); p.Mode != authorModeImmediate {
+		t.Errorf("unknown author should act immediately, got %+v", p)
+	}
+
+	a.merge(map[string]authorPolicy{"*": {Mode: authorModeSkip}, "Kaylee-MistyStep": {Mode: authorModeCommentOnly}})
+	if p := a.policyFor("stranger", false); p.Mode != authorModeSkip {
+		t.Errorf("fallback should apply to unknown author, got %+v", p)
+	}
+	if p := a.policyFor("kaylee-mistystep", false); p.Mode != authorModeCommentOnly {
+		t.Errorf("override should replace default, got %+v", p)
+	}
+}
+
+func TestAuthorPolicies_botLogins(t *testing.T) {
+	parsed, err := parseAuthorPolicies("dependabot=dependency,renovate[bot]=dependency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := authorPolicies{}.merge(parsed)
+	for _, login := range []string{"dependabot", "dependabot[bot]", "app/dependabot", "Renovate", "app/renovate"} {
+		if p := a.policyFor(login, false); p.Mode != authorModeDependency {
+			t.Errorf("%s: got %+v, want dependency mode", login, p)
+		}
+	}
+}
+
+func TestAuthorPolicies_groups(t *testing.T) {
+	a := authorPolicies{}.merge(map[string]authorPolicy{
+		"@bots":   {Mode: authorModeImmediate},
+		"@humans": {Mode: authorModeStale, StaleHours: 24},
+		"*":       {Mode: authorModeSkip},
+		"alice":   {Mode: authorModeCommentOnly},
+	})
+	tests := []struct {
+		login string
+		bot   bool
+		want  authorPolicy
+	}{
+		{"alice", false, authorPolicy{Mode: authorModeCommentOnly}},
+		{"bob", false, authorPolicy{Mode: authorModeStale, StaleHours: 24}},
+		{"dependabot", true, authorPolicy{Mode: authorModeImmediate}},
+		{"renovate[bot]", false, authorPolicy{Mode: authorModeImmediate}},
+		{"app/github-actions", false, authorPolicy{Mode: authorModeImmediate}},
+	}
+	for _, tt := range tests {
+		if got := a.policyFor(tt.login, tt.bot); got != tt.want {
+			t.Errorf("policyFor(%q, %t) = %+v; want %+v", tt.login, tt.bot, got, tt.want)
+		}
+	}
+	if _, err := parseAuthorPolicies("@robots=skip"); err == nil {
+		t.Error("parseAuthorPolicies accepted an unknown group")
+	}
+}
+
+func TestStaleHoursMatrix(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "config.json")
+	raw := `{"staleHours":{"phrazzld":48,"@humans":24,"@bots":0},"authors":{"alice":{"mode":"comment-only"}}}`
+	if err := os.WriteFile(p, []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts := registerRunFlags(fs)
+	if err := fs.Parse([]string{"-config", p, "-phaedrus-login", "phaedrus-two"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		login string
+		bot   bool
+		want  authorPolicy
+	}{
+		{"phrazzld", false, authorPolicy{Mode: authorModeStale, StaleHours: 48}},
+		// The matrix replaces the --phaedrus-login default.
+		{"phaedrus-two", false, authorPolicy{Mode: authorModeStale, StaleHours: 24}},
+		{"dependabot", true, authorPolicy{Mode: authorModeImmediate}},
+		{"kaylee-mistystep", false, authorPolicy{Mode: authorModeImmediate}},
+		{"alice", false, authorPolicy{Mode: authorModeCommentOnly}},
+	}
+	for _, tt := range tests {
+		if got := opts.authors.policyFor(tt.login, tt.bot); got != tt.want {
+			t.Errorf("policyFor(%q) = %+v; want %+v", tt.login, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`{"staleHours":{"@robots":1}}`, `{"staleHours":{"alice":-1}}`, `{"authors":{"@everyone":{"mode":"skip"}}}`} {
+		if err := os.WriteFile(p, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig(p); err == nil {
+			t.Errorf("loadConfig(%s) accepted it", bad)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAutoMergeCandidate(t *testing.T) {
+	pending := []statusRollupEntry{{Typename: "CheckRun", Status: "IN_PROGRESS"}}
+	tests := []struct {
+		name    string
+		pr      prView
+		policy  repoPolicy
+		want    bool
+		wantWhy reasonCode
+	}{
+		{"approved and pending", prView{Mergeable: "MERGEABLE", ReviewDecision: "APPROVED", StatusCheckRollup: pending}, repoPolicy{}, true, "checks_pending"},
+		{"no review needed and pending", prView{Mergeable: "MERGEABLE", StatusCheckRollup: pending}, repoPolicy{}, true, "checks_pending"},
+		{"approval required by policy", prView{Mergeable: "MERGEABLE", StatusCheckRollup: pending}, repoPolicy{RequireApproval: true}, false, "checks_pending"},
+		{"review required", prView{Mergeable: "MERGEABLE", ReviewDecision: "REVIEW_REQUIRED", StatusCheckRollup: pending}, repoPolicy{}, false, "checks_pending"},
+		{"changes requested", prView{Mergeable: "MERGEABLE", ReviewDecision: "CHANGES_REQUESTED", StatusCheckRollup: pending}, repoPolicy{}, false, "checks_pending"},
+		{"conflicting", prView{Mergeable: "CONFLICTING", ReviewDecision: "APPROVED", StatusCheckRollup: pending}, repoPolicy{}, false, "mergeable_conflicting"},
+		{"failing", prView{Mergeable: "MERGEABLE", ReviewDecision: "APPROVED", StatusCheckRollup: []statusRollupEntry{{Typename: "CheckRun", Status: "COMPLETED", Conclusion: "FAILURE"}}}, repoPolicy{}, false, "checks_failure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, reason := mergeAllowed(&tt.pr, tt.policy)
+			if reason != tt.wantWhy {
+				t.Fatalf("mergeAllowed reason = %q; want %q", reason, tt.wantWhy)
+			}
+			if got := autoMergeCandidate(&tt.pr, tt.policy, reason); got != tt.want {
+				t.Errorf("autoMergeCandidate() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAutoMergeUnavailableError(t *testing.T) {
+	if !isAutoMergeUnavailableError(errors.New("Auto merge is not allowed for this repository")) {
+		t.Error("repo setting error should be recognized")
+	}
+	if !isAutoMergeUnavailableError(errors.New("Pull request Protected branch rules not configured for this branch")) {
+		t.Error("unprotected branch error should be recognized")
+	}
+	if isAutoMergeUnavailableError(errors.New("HTTP 502: Bad Gateway")) {
+		t.Error("server errors are not an unavailable auto-merge")
+	}
+}
+
+func TestMergeAllowedBranchBehind(t *testing.T) {
+	green := []statusRollupEntry{{Typename: "CheckRun", Name: "ci", Status: "COMPLETED", Conclusion: "SUCCESS"}}
+	pr := &prView{Mergeable: "MERGEABLE", MergeStateStatus: "BEHIND", StatusCheckRollup: green}
+	if ok, reason := mergeAllowed(pr, repoPolicy{}); ok || reason != "branch_behind" {
+		t.Errorf("behind: got %v %q; want false branch_behind", ok, reason)
+	}
+	// A review blocker is reported ahead of being behind.
+	pr.ReviewDecision = "CHANGES_REQUESTED"
+	if _, reason := mergeAllowed(pr, repoPolicy{}); reason != "review_changes_requested" {
+		t.Errorf("behind with changes requested: reason %q", reason)
+	}
+	pr.ReviewDecision, pr.MergeStateStatus = "", "CLEAN"
+	if ok, _ := mergeAllowed(pr, repoPolicy{}); !ok {
+		t.Error("clean PR should be mergeable")
+	}
+}
+
+func TestPipelineSkipsAutoMergePending(t *testing.T) {
+	green := fakePR("misty-step/api", 1)
+	failing := fakePR("misty-step/api", 2)
+	failing.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "test", Status: "COMPLETED", Conclusion: "FAILURE"}}
+	for _, pr := range []*prView{green, failing} {
+		pr.AutoMergeRequest = &struct {
+			EnabledAt   string `json:"enabledAt"`
+			MergeMethod string `json:"mergeMethod"`
+			EnabledBy   struct {
+				Login string `json:"login"`
+			} `json:"enabledBy"`
+		}{EnabledAt: "2025-01-15T10:00:00Z", MergeMethod: "REBASE"}
+		pr.AutoMergeRequest.EnabledBy.Login = "phrazzld"
+	}
+	fake := newFakeGitHub(green, failing)
+
+	p := newPipeline(testPipelineOptions(t))
+	p.client = fake
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range out.Results {
+		if r.Action != "skipped" || r.Reason != "auto_merge_pending" {
+			t.Errorf("%s: %s/%s; want skipped/auto_merge_pending", r.URL, r.Action, r.Reason)
+		}
+	}
+	if len(fake.merged) > 0 || len(fake.posted) > 0 || len(fake.unhandled) > 0 {
+		t.Errorf("merged %v, posted %v, other calls %v; want no writes", fake.merged, fake.posted, fake.unhandled)
+	}
+}
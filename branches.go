@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Branch deletion results reported in prOutcome.BranchDeletion.
+const (
+	branchDeleted          = "deleted"
+	branchAlreadyDeleted   = "already_deleted"
+	branchSkippedFork      = "skipped_fork"
+	branchSkippedProtected = "skipped_protected"
+)
+
+// deleteMergedBranch deletes a merged PR's head branch unless it lives on a
+// fork or is protected. Returns the status to report; failures are reported
+// as "failed: <err>" rather than failing the merge.
+func deleteMergedBranch(ctx context.Context, repo string, pr *prView) string {
+	if pr.IsCrossRepository {
+		return branchSkippedFork
+	}
+	branch := strings.TrimSpace(pr.HeadRefName)
+	if branch == "" {
+		return "failed: unknown head branch"
+	}
+	protected, err := RetryableWithResult(ctx, func() (bool, error) {
+		return ghBranchProtected(ctx, repo, branch)
+	}, retryCfg)
+	if err != nil {
+		if isRefGoneError(err) {
+			// Deleted already, e.g. by the repo's auto-delete setting.
+			return branchAlreadyDeleted
+		}
+		return "failed: " + err.Error()
+	}
+	if protected {
+		return branchSkippedProtected
+	}
+	err = Retryable(ctx, func() error {
+		return ghDeleteBranch(ctx, repo, branch)
+	}, retryCfg)
+	if err != nil {
+		if isRefGoneError(err) {
+			return branchAlreadyDeleted
+		}
+		return "failed: " + err.Error()
+	}
+	return branchDeleted
+}
+
+// isRefGoneError reports whether a branch lookup or delete failed because the
+// branch no longer exists.
+func isRefGoneError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "branch not found") ||
+		strings.Contains(msg, "reference does not exist") ||
+		strings.Contains(msg, "http 404")
+}
+
+func ghBranchProtected(ctx context.Context, repo string, branch string) (bool, error) {
+	stdout, err := runGh(ctx, "api", fmt.Sprintf("repos/%s/branches/%s", repo, url.PathEscape(branch)))
+	if err != nil {
+		return false, err
+	}
+	var b struct {
+		Protected bool `json:"protected"`
+	}
+	if err := json.Unmarshal(stdout, &b); err != nil {
+		return false, fmt.Errorf("parse branch json: %w", err)
+	}
+	return b.Protected, nil
+}
+
+func ghDeleteBranch(ctx context.Context, repo string, branch string) error {
+	_, err := runGh(ctx, "api", "-X", "DELETE", fmt.Sprintf("repos/%s/git/refs/heads/%s", repo, branch))
+	return err
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestIsRefGoneError(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{"gh api repos/o/r/branches/x: Branch not found (HTTP 404)", true},
+		{"gh api -X DELETE repos/o/r/git/refs/heads/x: Reference does not exist (HTTP 422)", true},
+		{"gh api -X DELETE repos/o/r/git/refs/heads/x: Resource not accessible by integration (HTTP 403)", false},
+	}
+	for _, tt := range tests {
+		if got := isRefGoneError(errors.New(tt.msg)); got != tt.want {
+			t.Errorf("isRefGoneError(%q) = %v; want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteMergedBranch_skips(t *testing.T) {
+	ctx := context.Background()
+	if got := deleteMergedBranch(ctx, "o/r", &prView{IsCrossRepository: true, HeadRefName: "patch-1"}); got != branchSkippedFork {
+		t.Errorf("fork branch: got %q; want %q", got, branchSkippedFork)
+	}
+	if got := deleteMergedBranch(ctx, "o/r", &prView{}); got != "failed: unknown head branch" {
+		t.Errorf("missing head ref: got %q", got)
+	}
+}
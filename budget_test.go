@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountActions(t *testing.T) {
+	results := []prOutcome{
+		{Repo: "org/a", Action: "merged"},
+		{Repo: "org/a", Action: "skipped"},
+		{Repo: "org/b", Action: "commented"},
+		{Repo: "org/b", Action: "error"},
+		{Repo: "org/c", Action: "dry_run_merge"},
+	}
+	if got := countActions(results); got != 3 {
+		t.Errorf("countActions = %d; want 3", got)
+	}
+	if got := countActions(nil); got != 0 {
+		t.Errorf("countActions(nil) = %d; want 0", got)
+	}
+}
+
+func TestPrepareRejectsNegativeMaxActions(t *testing.T) {
+	o := &runOptions{Org: "misty-step", MaxPRs: 5, MaxActions: -1}
+	if err := o.prepare(); err == nil {
+		t.Error("expected an error for --max-actions -1")
+	}
+}
+
+func TestRenderDiscordEmbedsBudget(t *testing.T) {
+	out := runOutput{Ok: true, Org: "misty-step", MaxPRs: 5}
+	if desc := renderDiscordEmbeds(out, 0, 0, 0, 0)[0].Description; !strings.Contains(desc, "maxPRs: `5`") {
+		t.Errorf("summary without -max-actions should show maxPRs:\n%s", desc)
+	}
+	out.MaxActions = 3
+	if desc := renderDiscordEmbeds(out, 0, 0, 0, 0)[0].Description; !strings.Contains(desc, "maxActions: `3`") || strings.Contains(desc, "maxPRs") {
+		t.Errorf("summary with -max-actions should show maxActions:\n%s", desc)
+	}
+}
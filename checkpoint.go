@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// runCheckpoint is a run in progress: the outcomes of the PRs it has
+// processed so far. It's saved after every PR and removed when the run
+// finishes, so one left behind means the run was killed partway.
+type runCheckpoint struct {
+	StartedAt string      `json:"startedAt"`
+	SavedAt   string      `json:"savedAt"`
+	Org       string      `json:"org"`
+	Results   []prOutcome `json:"results"`
+}
+
+// checkpointPath returns where the checkpoint is kept, beside the dedup
+// state file.
+func checkpointPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "checkpoint.json")
+}
+
+// saveCheckpoint writes cp to path through a temp file, so a run killed
+// mid-write leaves the previous checkpoint rather than half of one.
+func saveCheckpoint(path string, cp runCheckpoint) {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path+".tmp", data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[checkpoint] failed to save %s: %v\n", path, err)
+	}
+}
+
+// loadCheckpoint returns the checkpoint at path if a run of org left one
+// within window of now, else nil. Checkpoints that can't be used are
+// logged, so a killed run doesn't go unnoticed.
+func loadCheckpoint(path string, org string, now time.Time, window time.Duration) *runCheckpoint {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	var cp runCheckpoint
+	if err == nil {
+		err = json.Unmarshal(data, &cp)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[checkpoint] ignoring unreadable %s: %v\n", path, err)
+		return nil
+	}
+	saved, err := time.Parse(time.RFC3339, cp.SavedAt)
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "[checkpoint] ignoring %s: bad savedAt %q\n", path, cp.SavedAt)
+		return nil
+	case cp.Org != org:
+		fmt.Fprintf(os.Stderr, "[checkpoint] ignoring the checkpoint for org %s\n", cp.Org)
+		return nil
+	case now.Sub(saved) > window:
+		fmt.Fprintf(os.Stderr, "[checkpoint] ignoring the checkpoint saved %s, older than --resume-window %s\n", cp.SavedAt, window)
+		return nil
+	}
+	return &cp
+}
+
+// removeCheckpoint deletes the checkpoint once a run has finished.
+func removeCheckpoint(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "[checkpoint] failed to remove %s: %v\n", path, err)
+	}
+}
+
+// resumeFrom drops the PRs cp already processed from selected.
+func resumeFrom(cp *runCheckpoint, selected []searchPR) []searchPR {
+	done := make(map[string]bool, len(cp.Results))
+	for _, r := range cp.Results {
+		done[r.URL] = true
+	}
+	var rest []searchPR
+	for _, pr := range selected {
+		if !done[pr.URL] {
+			rest = append(rest, pr)
+		}
+	}
+	return rest
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	if cp := loadCheckpoint(path, "misty-step", now, time.Hour); cp != nil {
+		t.Fatalf("missing file: %+v", cp)
+	}
+	saveCheckpoint(path, runCheckpoint{
+		StartedAt: "2025-06-01T11:00:00Z",
+		SavedAt:   "2025-06-01T11:30:00Z",
+		Org:       "misty-step",
+		Results:   []prOutcome{{URL: "u1", Action: "merged"}},
+	})
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temp file left behind: %v", err)
+	}
+
+	cp := loadCheckpoint(path, "misty-step", now, time.Hour)
+	if cp == nil || cp.StartedAt != "2025-06-01T11:00:00Z" || len(cp.Results) != 1 || cp.Results[0].URL != "u1" {
+		t.Fatalf("checkpoint = %+v", cp)
+	}
+	if cp := loadCheckpoint(path, "misty-step", now, 20*time.Minute); cp != nil {
+		t.Error("a checkpoint older than the window was used")
+	}
+	if cp := loadCheckpoint(path, "other-org", now, time.Hour); cp != nil {
+		t.Error("another org's checkpoint was used")
+	}
+
+	removeCheckpoint(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint not removed: %v", err)
+	}
+	removeCheckpoint(path) // already gone: no error
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cp := loadCheckpoint(path, "misty-step", now, time.Hour); cp != nil {
+		t.Error("an unreadable checkpoint was used")
+	}
+}
+
+func TestPipelineSavesCheckpointPerPR(t *testing.T) {
+	first, second := fakePR("misty-step/api", 1), fakePR("misty-step/api", 2)
+	fake := newFakeGitHub(first, second)
+	opts := testPipelineOptions(t)
+	path := checkpointPath(opts.StateFile)
+
+	var viewed []string
+	var seen [][]string // the checkpoint's PRs as each PR is viewed
+	p := newPipeline(opts)
+	p.client = ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+		if len(args) > 2 && args[0] == "pr" && args[1] == "view" && !slices.Contains(viewed, args[2]) {
+			viewed = append(viewed, args[2])
+			var urls []string
+			if cp := loadCheckpoint(path, opts.Org, time.Now(), time.Hour); cp != nil {
+				for _, r := range cp.Results {
+					urls = append(urls, r.URL)
+				}
+			}
+			seen = append(seen, urls)
+		}
+		return fake.Run(ctx, stdin, args...)
+	})
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(viewed) != 2 || len(seen[0]) != 0 || !slices.Equal(seen[1], viewed[:1]) {
+		t.Errorf("viewed %v; checkpoints seen %v; want the first PR checkpointed before the second", viewed, seen)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint left after a finished run: %v", err)
+	}
+}
+
+func TestPipelineResumesFromCheckpoint(t *testing.T) {
+	done, todo := fakePR("misty-step/api", 1), fakePR("misty-step/api", 2)
+	for _, resume := range []bool{false, true} {
+		fake := newFakeGitHub(done, todo)
+		useFakeGitHub(t, fake)
+		args := []string{"-max-prs", "2"}
+		if resume {
+			args = append(args, "-resume")
+		}
+		opts := testPipelineOptions(t, args...)
+		saveCheckpoint(checkpointPath(opts.StateFile), runCheckpoint{
+			StartedAt: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+			SavedAt:   time.Now().UTC().Format(time.RFC3339),
+			Org:       opts.Org,
+			Results:   []prOutcome{{URL: done.URL, Repo: "misty-step/api", Number: 1, Action: "merged"}},
+		})
+
+		out, err := newPipeline(opts).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{done.ID, todo.ID}
+		if resume {
+			want = []string{todo.ID}
+		}
+		slices.Sort(fake.merged)
+		if !slices.Equal(fake.merged, want) {
+			t.Errorf("resume=%v: merged %v; want %v", resume, fake.merged, want)
+		}
+		if len(out.Results) != 2 {
+			t.Errorf("resume=%v: results = %+v; want both PRs", resume, out.Results)
+		}
+		if _, err := os.Stat(checkpointPath(opts.StateFile)); !os.IsNotExist(err) {
+			t.Errorf("resume=%v: checkpoint left after a finished run: %v", resume, err)
+		}
+	}
+}
+
+func TestPipelineDryRunSkipsCheckpoint(t *testing.T) {
+	useFakeGitHub(t, newFakeGitHub(fakePR("misty-step/api", 1)))
+	opts := testPipelineOptions(t, "-dry-run")
+	path := checkpointPath(opts.StateFile)
+	cp := runCheckpoint{StartedAt: "x", SavedAt: time.Now().UTC().Format(time.RFC3339), Org: opts.Org}
+	saveCheckpoint(path, cp)
+	if _, err := newPipeline(opts).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadCheckpoint(path, opts.Org, time.Now(), time.Hour); got == nil || got.StartedAt != "x" {
+		t.Errorf("a dry run touched the checkpoint: %+v", got)
+	}
+}
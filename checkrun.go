@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// pipelineCheckName is the check run the pipeline reports its own decision
+// under. It is left out of the checks state the pipeline acts on.
+const pipelineCheckName = "kaylee-pipeline"
+
+// isPipelineCheck reports whether a rollup entry is the pipeline's own check run.
+func isPipelineCheck(e statusRollupEntry) bool {
+	return strings.TrimSpace(e.Typename) == "CheckRun" && e.Name == pipelineCheckName
+}
+
+// pipelineCheckResult summarizes a PR outcome as a check run conclusion,
+// title, and markdown summary. The conclusion is never "failure": the check
+// reports the pipeline's decision, and must not itself block the PR.
+func pipelineCheckResult(o prOutcome) (conclusion string, title string, summary string) {
+	conclusion = "neutral"
+	switch {
+	case o.Action == "merged":
+		conclusion, title = "success", "Merged"
+	case o.Action == "enqueued":
+		conclusion, title = "success", "Added to the merge queue"
+	case o.Action == "auto_merge_enabled":
+		conclusion, title = "success", "Auto-merge enabled; waiting on checks"
+	case o.Action == "marked_ready":
+		conclusion, title = "success", "Marked ready for review"
+	case o.Action == "review_dismissed":
+		title = "Stale review dismissed; waiting on review"
+	case o.Action == "branch_updated":
+		title = "Branch updated; waiting on checks"
+	case o.ReasonCode == reasonMergeQueued || o.ReasonCode == reasonMergeQueuePosition:
+		conclusion, title = "success", "In the merge queue"
+	case o.ReasonCode == reasonAuthorCommentOnly:
+		title = "Would merge (author is comment-only)"
+	case o.ReasonCode == reasonHoldLabel:
+		title = "Would merge (on hold)"
+	case o.Action == "error":
+		title = "Pipeline error"
+	default:
+		reason := o.blocker()
+		if reason == "" {
+			reason = o.Action
+		}
+		title = "Blocked: " + reason
+	}
+
+	lines := []string{fmt.Sprintf("- action: `%s`", o.Action)}
+	if o.Reason != "" {
+		lines = append(lines, fmt.Sprintf("- reason: `%s`", o.Reason))
+	}
+	if o.ChecksState != "" {
+		lines = append(lines, fmt.Sprintf("- checks: `%s`", o.ChecksState))
+	}
+	if o.ReviewDecision != "" {
+		lines = append(lines, fmt.Sprintf("- reviewDecision: `%s`", o.ReviewDecision))
+	}
+	return conclusion, title, strings.Join(lines, "\n")
+}
+
+// reportPipelineChecks writes each outcome to the pipeline check run on its
+// PR's head commit. Failures are logged and otherwise ignored.
+func reportPipelineChecks(ctx context.Context, results []prOutcome, now time.Time) {
+	for _, r := range results {
+		if r.HeadSHA == "" {
+			// Never fetched (e.g. skipped before the PR view).
+			continue
+		}
+		conclusion, title, summary := pipelineCheckResult(r)
+		err := Retryable(ctx, func() error {
+			return ghUpsertCheckRun(ctx, r.Repo, r.HeadSHA, conclusion, title, summary, now)
+		}, retryCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[check-run] %s: %v\n", r.URL, err)
+		}
+	}
+}
+
+// ghUpsertCheckRun updates the pipeline check run on sha, creating it if the
+// commit doesn't have one yet. Writing check runs requires a GitHub App token.
+func ghUpsertCheckRun(ctx context.Context, repo string, sha string, conclusion string, title string, summary string, now time.Time) error {
+	body, err := json.Marshal(map[string]any{
+		"name":         pipelineCheckName,
+		"head_sha":     sha,
+		"status":       "completed",
+		"conclusion":   conclusion,
+		"completed_at": now.UTC().Format(time.RFC3339),
+		"output": map[string]string{
+			"title":   title,
+			"summary": summary,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	stdout, err := runGh(ctx, "api", fmt.Sprintf("repos/%s/commits/%s/check-runs?check_name=%s", repo, sha, pipelineCheckName))
+	if err != nil {
+		return err
+	}
+	id, err := parseCheckRunID(stdout)
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		_, err = runGhInput(ctx, body, "api", "-X", "PATCH", fmt.Sprintf("repos/%s/check-runs/%d", repo, id), "--input", "-")
+		return err
+	}
+	_, err = runGhInput(ctx, body, "api", "-X", "POST", fmt.Sprintf("repos/%s/check-runs", repo), "--input", "-")
+	return err
+}
+
+// parseCheckRunID returns the ID of the first check run in a list-check-runs
+// response, or 0 if there is none.
+func parseCheckRunID(raw []byte) (int64, error) {
+	var resp struct {
+		CheckRuns []struct {
+			ID int64 `json:"id"`
+		} `json:"check_runs"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return 0, fmt.Errorf("parse check runs json: %w", err)
+	}
+	if len(resp.CheckRuns) == 0 {
+		return 0, nil
+	}
+	return resp.CheckRuns[0].ID, nil
+}
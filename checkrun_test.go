@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOverallChecksState_ignoresPipelineCheck(t *testing.T) {
+	own := statusRollupEntry{Typename: "CheckRun", Name: pipelineCheckName, Status: "IN_PROGRESS"}
+	build := statusRollupEntry{Typename: "CheckRun", Name: "build", Status: "COMPLETED", Conclusion: "SUCCESS"}
+
+	if got := overallChecksState([]statusRollupEntry{build, own}); got != "SUCCESS" {
+		t.Errorf("overallChecksState = %q; want SUCCESS", got)
+	}
+	if got := overallChecksState([]statusRollupEntry{own}); got != "" {
+		t.Errorf("overallChecksState with only the pipeline check = %q; want empty", got)
+	}
+}
+
+func TestPipelineCheckResult(t *testing.T) {
+	tests := []struct {
+		outcome    prOutcome
+		conclusion string
+		title      string
+	}{
+		{prOutcome{Action: "merged"}, "success", "Merged"},
+		{prOutcome{Action: "enqueued"}, "success", "Added to the merge queue"},
+		{prOutcome{Action: "skipped", Reason: "merge_queued"}, "success", "In the merge queue"},
+		{prOutcome{Action: "enqueued", Reason: "merge_queue_position_3"}, "success", "Added to the merge queue"},
+		{prOutcome{Action: "commented", Reason: "author_comment_only"}, "neutral", "Would merge (author is comment-only)"},
+		{prOutcome{Action: "commented", Reason: "checks_failure"}, "neutral", "Blocked: checks_failure"},
+		{prOutcome{Action: "skipped", Reason: "checks_failure_already_commented"}, "neutral", "Blocked: checks_failure"},
+		{prOutcome{Action: "skipped", Reason: "draft"}, "neutral", "Blocked: draft"},
+		{prOutcome{Action: "error", Reason: "merge failed (permanent): boom"}, "neutral", "Pipeline error"},
+	}
+	for _, tt := range tests {
+		conclusion, title, _ := pipelineCheckResult(tt.outcome.withReasonCode())
+		if conclusion != tt.conclusion || title != tt.title {
+			t.Errorf("pipelineCheckResult(%s/%s) = %q, %q; want %q, %q",
+				tt.outcome.Action, tt.outcome.Reason, conclusion, title, tt.conclusion, tt.title)
+		}
+	}
+
+	_, _, summary := pipelineCheckResult(prOutcome{Action: "commented", Reason: "review_required", ChecksState: "SUCCESS", ReviewDecision: "REVIEW_REQUIRED"}.withReasonCode())
+	for _, want := range []string{"- action: `commented`", "- reason: `review_required`", "- checks: `SUCCESS`", "- reviewDecision: `REVIEW_REQUIRED`"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary missing %q; got:\n%s", want, summary)
+		}
+	}
+}
+
+func TestParseCheckRunID(t *testing.T) {
+	id, err := parseCheckRunID([]byte(`{"total_count":1,"check_runs":[{"id":42,"name":"kaylee-pipeline"}]}`))
+	if err != nil || id != 42 {
+		t.Errorf("parseCheckRunID = %d, %v; want 42", id, err)
+	}
+	id, err = parseCheckRunID([]byte(`{"total_count":0,"check_runs":[]}`))
+	if err != nil || id != 0 {
+		t.Errorf("parseCheckRunID (none) = %d, %v; want 0", id, err)
+	}
+	if _, err := parseCheckRunID([]byte(`nope`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestFlakyRerunRunIDs(t *testing.T) {
+	const (
+		run1 = "https://github.com/o/r/actions/runs/111/job/1"
+		run2 = "https://github.com/o/r/actions/runs/222/job/2"
+	)
+	tests := []struct {
+		name    string
+		entries []statusRollupEntry
+		flakyRe *regexp.Regexp
+		want    []string
+	}{
+		{
+			name: "cancelled and timed out",
+			entries: []statusRollupEntry{
+				{Typename: "CheckRun", Name: "build", Conclusion: "SUCCESS", DetailsURL: run1},
+				{Typename: "CheckRun", Name: "test", Conclusion: "CANCELLED", DetailsURL: run1},
+				{Typename: "CheckRun", Name: "e2e", Conclusion: "TIMED_OUT", DetailsURL: run2},
+			},
+			want: []string{"111", "222"},
+		},
+		{
+			name: "same run deduplicated",
+			entries: []statusRollupEntry{
+				{Typename: "CheckRun", Name: "a", Conclusion: "cancelled", DetailsURL: run1},
+				{Typename: "CheckRun", Name: "b", Conclusion: "timed_out", DetailsURL: run1},
+			},
+			want: []string{"111"},
+		},
+		{
+			name: "real failure blocks rerun",
+			entries: []statusRollupEntry{
+				{Typename: "CheckRun", Name: "e2e", Conclusion: "CANCELLED", DetailsURL: run1},
+				{Typename: "CheckRun", Name: "unit", Conclusion: "FAILURE", DetailsURL: run2},
+			},
+			want: nil,
+		},
+		{
+			name: "regex marks failure flaky",
+			entries: []statusRollupEntry{
+				{Typename: "CheckRun", Name: "integration (flaky)", Conclusion: "FAILURE", DetailsURL: run2},
+			},
+			flakyRe: regexp.MustCompile("(?i)flaky"),
+			want:    []string{"222"},
+		},
+		{
+			name: "missing run id",
+			entries: []statusRollupEntry{
+				{Typename: "CheckRun", Name: "ext", Conclusion: "CANCELLED", DetailsURL: "https://ci.example.com/1"},
+			},
+			want: nil,
+		},
+		{
+			name: "failed status context",
+			entries: []statusRollupEntry{
+				{Typename: "CheckRun", Name: "e2e", Conclusion: "CANCELLED", DetailsURL: run1},
+				{Typename: "StatusContext", Context: "ci/legacy", State: "FAILURE"},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := flakyRerunRunIDs(tt.entries, tt.flakyRe)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flakyRerunRunIDs() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunIDFromDetailsURL(t *testing.T) {
+	if got := runIDFromDetailsURL("https://github.com/o/r/actions/runs/98765/job/4321"); got != "98765" {
+		t.Errorf("expected run id 98765, got %q", got)
+	}
+	if got := runIDFromDetailsURL("https://circleci.com/gh/o/r/1"); got != "" {
+		t.Errorf("expected empty run id for non-Actions URL, got %q", got)
+	}
+}
+
+func TestSummarize_ci_rerun(t *testing.T) {
+	_, commented, _, _ := summarize([]prOutcome{{Action: "ci_rerun"}})
+	if commented != 1 {
+		t.Errorf("expected commented=1, got %d", commented)
+	}
+}
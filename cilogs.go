@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ciDiagnosis is what we could learn about a failing check beyond its name:
+// a category, an optional detail (e.g. the lint rule), a log excerpt, and
+// the source location when the matching line names one.
+type ciDiagnosis struct {
+	Check    string
+	Category string
+	Detail   string
+	Excerpt  string
+	File     string
+	Line     int
+}
+
+// ciLogPattern maps a log line to a failure category. If the regexp has a
+// capture group, the first group becomes the diagnosis detail.
+type ciLogPattern struct {
+	category string
+	label    string
+	re       *regexp.Regexp
+}
+
+// ciLogPatterns are tried in order against each line; the first line that
+// matches any pattern decides the category.
+var ciLogPatterns = []ciLogPattern{
+	// Lint rule IDs.
+	{"lint", "golangci-lint rule", regexp.MustCompile(`^\S+\.go:\d+(?::\d+)?: .+ \(([a-z][a-z0-9]+)\)$`)},
+	{"lint", "eslint rule", regexp.MustCompile(`^\s*\d+:\d+\s+error\s+.+?\s{2,}(@?[a-z][\w/-]*)$`)},
+	{"lint", "python lint rule", regexp.MustCompile(`^\S+\.py:\d+:\d+: ([A-Z]{1,3}\d{3,4})\b`)},
+	// Compile errors.
+	{"build", "TypeScript error", regexp.MustCompile(`\berror (TS\d+):`)},
+	{"build", "Rust error", regexp.MustCompile(`^error\[(E\d{4})\]`)},
+	{"build", "Go compile error", regexp.MustCompile(`^\S+\.go:\d+:\d+: (undefined: \S+|syntax error|cannot use|.+ declared and not used)`)},
+	{"build", "", regexp.MustCompile(`(?i)(cannot find symbol|compilation failed|build failed|SyntaxError:)`)},
+	// Test assertion failures.
+	{"test", "failing test", regexp.MustCompile(`^--- FAIL: (\S+)`)},
+	{"test", "failing test", regexp.MustCompile(`^FAILED (\S+::\S+)`)},
+	{"test", "failing test", regexp.MustCompile(`^\s*● (.+)$`)},
+	{"test", "", regexp.MustCompile(`(AssertionError|assertion failed|Expected:|expected .+ to )`)},
+}
+
+// ciLocationRe matches a "path/to/file.ext:line" prefix on a log line.
+var ciLocationRe = regexp.MustCompile(`^\s*([\w./-]+\.\w+):(\d+)(?::\d+)?\b`)
+
+// actionsLogTimestampRe matches the timestamp Actions prefixes to every log line.
+var actionsLogTimestampRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?Z `)
+
+// actionsJobIDRe extracts the job ID from an Actions check's details URL; for
+// Actions, the job ID is also the check run ID.
+var actionsJobIDRe = regexp.MustCompile(`/actions/runs/\d+/jobs?/(\d+)`)
+
+// jobIDFromDetailsURL returns the Actions job (check run) ID for a check, or
+// "" when the check isn't an Actions job.
+func jobIDFromDetailsURL(detailsURL string) string {
+	m := actionsJobIDRe.FindStringSubmatch(detailsURL)
+	if len(m) == 2 {
+		return m[1]
+	}
+	return ""
+}
+
+// classifyCILog scans log lines for a known failure signature. Returns the
+// category ("" if nothing matched), a detail, and the index of the matching line.
+func classifyCILog(lines []string) (category string, detail string, idx int) {
+	for i, line := range lines {
+		for _, p := range ciLogPatterns {
+			m := p.re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			detail = p.label
+			if len(m) > 1 && m[1] != "" {
+				if detail != "" {
+					detail += " "
+				}
+				detail += strings.TrimSpace(m[1])
+			}
+			return p.category, detail, i
+		}
+	}
+	return "", "", -1
+}
+
+// newCIDiagnosis builds the diagnosis for lines[idx], picking up the file
+// and line when the matching line starts with one.
+func newCIDiagnosis(check string, category string, detail string, lines []string, idx int) *ciDiagnosis {
+	d := &ciDiagnosis{Check: check, Category: category, Detail: detail, Excerpt: ciExcerpt(lines, idx)}
+	if m := ciLocationRe.FindStringSubmatch(lines[idx]); m != nil {
+		d.File = m[1]
+		d.Line, _ = strconv.Atoi(m[2])
+	}
+	return d
+}
+
+// ciExcerpt returns a few lines around idx, trimmed to fit a PR comment.
+func ciExcerpt(lines []string, idx int) string {
+	const before, after, maxLen = 2, 8, 1000
+	start := max(0, idx-before)
+	end := min(len(lines), idx+after+1)
+	excerpt := strings.Join(lines[start:end], "\n")
+	if len(excerpt) > maxLen {
+		excerpt = excerpt[:maxLen] + "\n…"
+	}
+	return excerpt
+}
+
+// cleanLogLines splits a raw Actions log, dropping timestamps and blank lines.
+func cleanLogLines(raw string) []string {
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimRight(actionsLogTimestampRe.ReplaceAllString(line, ""), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// tailLines returns the last n lines.
+func tailLines(lines []string, n int) []string {
+	if n > 0 && len(lines) > n {
+		return lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// diagnoseCIFailure inspects failing Actions checks whose names didn't give
+// the failure away: first their failure annotations, then the last logLines
+// lines of the job log. Returns nil if nothing recognizable turned up.
+func diagnoseCIFailure(ctx context.Context, repo string, entries []statusRollupEntry, logLines int) *ciDiagnosis {
+	for _, e := range entries {
+		if strings.ToUpper(strings.TrimSpace(e.Conclusion)) != "FAILURE" {
+			continue
+		}
+		jobID := jobIDFromDetailsURL(e.DetailsURL)
+		if jobID == "" {
+			continue
+		}
+
+		annotations, err := RetryableWithResult(ctx, func() ([]string, error) {
+			return ghCheckRunAnnotations(ctx, repo, jobID)
+		}, retryCfg)
+		if err == nil {
+			if cat, detail, idx := classifyCILog(annotations); cat != "" {
+				return newCIDiagnosis(e.Name, cat, detail, annotations, idx)
+			}
+		}
+
+		raw, err := RetryableWithResult(ctx, func() (string, error) {
+			return ghJobLog(ctx, repo, jobID)
+		}, retryCfg)
+		if err != nil {
+			continue
+		}
+		lines := tailLines(cleanLogLines(raw), logLines)
+		if cat, detail, idx := classifyCILog(lines); cat != "" {
+			return newCIDiagnosis(e.Name, cat, detail, lines, idx)
+		}
+	}
+	return nil
+}
+
+// commentSection renders the diagnosis for the not-merged PR comment.
+func (d *ciDiagnosis) commentSection() string {
+	header := fmt.Sprintf("CI diagnosis for `%s`: %s", d.Check, d.Category)
+	if d.Detail != "" {
+		header += " (" + d.Detail + ")"
+	}
+	lines := []string{"", header}
+	if d.Excerpt != "" {
+		lines = append(lines, "```text", strings.ReplaceAll(d.Excerpt, "```", "'''"), "```")
+	}
+	switch d.Category {
+	case "lint":
+		lines = append(lines, "🧹 Lint-fix subagent dispatched via Discord for batch dispatch.")
+	case "test":
+		lines = append(lines, "🧪 Test-fix subagent dispatched for the failing test jobs.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+type checkRunAnnotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Message         string `json:"message"`
+}
+
+// ghCheckRunAnnotations returns a check run's failure annotations, one
+// "path:line: message" line per annotation.
+func ghCheckRunAnnotations(ctx context.Context, repo string, checkRunID string) ([]string, error) {
+	stdout, err := runGh(ctx, "api", fmt.Sprintf("repos/%s/check-runs/%s/annotations", repo, checkRunID))
+	if err != nil {
+		return nil, err
+	}
+	return parseAnnotations(stdout)
+}
+
+func parseAnnotations(raw []byte) ([]string, error) {
+	var annotations []checkRunAnnotation
+	if err := json.Unmarshal(raw, &annotations); err != nil {
+		return nil, fmt.Errorf("parse check run annotations: %w", err)
+	}
+	var lines []string
+	for _, a := range annotations {
+		if a.AnnotationLevel != "failure" {
+			continue
+		}
+		for _, msg := range strings.Split(strings.TrimSpace(a.Message), "\n") {
+			if a.Path != "" && a.Path != ".github" {
+				msg = fmt.Sprintf("%s:%d: %s", a.Path, a.StartLine, msg)
+			}
+			lines = append(lines, msg)
+		}
+	}
+	return lines, nil
+}
+
+// ghJobLog downloads the plain-text log of an Actions job.
+func ghJobLog(ctx context.Context, repo string, jobID string) (string, error) {
+	stdout, err := runGh(ctx, "api", fmt.Sprintf("repos/%s/actions/jobs/%s/logs", repo, jobID))
+	if err != nil {
+		return "", err
+	}
+	return string(stdout), nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifyCILog(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		wantCat    string
+		wantDetail string
+		wantIdx    int
+	}{
+		{
+			name:    "nothing recognizable",
+			lines:   []string{"Run make check", "Process completed with exit code 2."},
+			wantCat: "", wantIdx: -1,
+		},
+		{
+			name:       "golangci-lint rule",
+			lines:      []string{"Running golangci-lint", "internal/x/y.go:12:2: Error return value of `f.Close` is not checked (errcheck)"},
+			wantCat:    "lint",
+			wantDetail: "golangci-lint rule errcheck",
+			wantIdx:    1,
+		},
+		{
+			name:       "eslint rule",
+			lines:      []string{"/app/src/a.ts", "  3:7  error  'x' is assigned a value but never used  @typescript-eslint/no-unused-vars"},
+			wantCat:    "lint",
+			wantDetail: "eslint rule @typescript-eslint/no-unused-vars",
+			wantIdx:    1,
+		},
+		{
+			name:       "ruff rule",
+			lines:      []string{"app/main.py:4:1: F401 [*] `os` imported but unused"},
+			wantCat:    "lint",
+			wantDetail: "python lint rule F401",
+			wantIdx:    0,
+		},
+		{
+			name:       "typescript compile error",
+			lines:      []string{"src/a.ts(3,7): error TS2322: Type 'string' is not assignable to type 'number'."},
+			wantCat:    "build",
+			wantDetail: "TypeScript error TS2322",
+		},
+		{
+			name:       "go compile error",
+			lines:      []string{"# example.com/app", "./main.go:10:2: undefined: foo"},
+			wantCat:    "build",
+			wantDetail: "Go compile error undefined: foo",
+			wantIdx:    1,
+		},
+		{
+			name:       "go test failure",
+			lines:      []string{"=== RUN   TestParse", "--- FAIL: TestParse (0.00s)", "    parse_test.go:12: got 1, want 2"},
+			wantCat:    "test",
+			wantDetail: "failing test TestParse",
+			wantIdx:    1,
+		},
+		{
+			name:       "pytest failure",
+			lines:      []string{"FAILED tests/test_app.py::test_login - AssertionError"},
+			wantCat:    "test",
+			wantDetail: "failing test tests/test_app.py::test_login",
+		},
+		{
+			name:       "bare assertion",
+			lines:      []string{"noise", "AssertionError: 1 != 2"},
+			wantCat:    "test",
+			wantDetail: "AssertionError",
+			wantIdx:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cat, detail, idx := classifyCILog(tt.lines)
+			if cat != tt.wantCat || detail != tt.wantDetail || idx != tt.wantIdx {
+				t.Errorf("classifyCILog() = (%q, %q, %d); want (%q, %q, %d)", cat, detail, idx, tt.wantCat, tt.wantDetail, tt.wantIdx)
+			}
+		})
+	}
+}
+
+func TestCleanLogLines(t *testing.T) {
+	raw := "2025-01-10T12:00:00.1234567Z ##[group]Run go test\r\n\n2025-01-10T12:00:01.0000000Z --- FAIL: TestX (0.00s)\n"
+	got := cleanLogLines(raw)
+	want := []string{"##[group]Run go test", "--- FAIL: TestX (0.00s)"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("cleanLogLines() = %q; want %q", got, want)
+	}
+	if tail := tailLines(got, 1); len(tail) != 1 || tail[0] != want[1] {
+		t.Errorf("tailLines() = %q", tail)
+	}
+}
+
+func TestJobIDFromDetailsURL(t *testing.T) {
+	if got := jobIDFromDetailsURL("https://github.com/o/r/actions/runs/123/job/456"); got != "456" {
+		t.Errorf("got %q; want 456", got)
+	}
+	if got := jobIDFromDetailsURL("https://ci.example.com/build/9"); got != "" {
+		t.Errorf("non-Actions URL should yield no job ID, got %q", got)
+	}
+}
+
+func TestParseAnnotations(t *testing.T) {
+	raw := `[
+		{"path":"pkg/a.go","start_line":7,"annotation_level":"failure","message":"Error return value is not checked (errcheck)"},
+		{"path":".github","start_line":1,"annotation_level":"failure","message":"Process completed with exit code 1."},
+		{"path":"pkg/b.go","start_line":3,"annotation_level":"warning","message":"deprecated"}
+	]`
+	lines, err := parseAnnotations([]byte(raw))
+	if err != nil {
+		t.Fatalf("parseAnnotations: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != "pkg/a.go:7: Error return value is not checked (errcheck)" || lines[1] != "Process completed with exit code 1." {
+		t.Errorf("unexpected lines: %q", lines)
+	}
+	if cat, detail, _ := classifyCILog(lines); cat != "lint" || detail != "golangci-lint rule errcheck" {
+		t.Errorf("annotation should classify as lint, got %q %q", cat, detail)
+	}
+}
+
+func TestCIDiagnosisCommentSection(t *testing.T) {
+	d := &ciDiagnosis{Check: "ci", Category: "test", Detail: "failing test TestX", Excerpt: "--- FAIL: TestX\n```inner```"}
+	got := d.commentSection()
+	if !strings.Contains(got, "CI diagnosis for `ci`: test (failing test TestX)") {
+		t.Errorf("missing header: %s", got)
+	}
+	if strings.Count(got, "```") != 2 {
+		t.Errorf("excerpt fences should not be broken by log content: %s", got)
+	}
+	if strings.Contains(got, "Lint-fix") {
+		t.Errorf("non-lint diagnosis should not mention lint dispatch")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// breakerStateMaxAge is how long a saved breaker entry outlives the last
+// change to it. PRs that merge or close stop being looked at, and their
+// entries would otherwise stay in the file forever.
+const breakerStateMaxAge = 14 * 24 * time.Hour
+
+// breakerEntry is one key's saved breaker state.
+type breakerEntry struct {
+	Failures       int       `json:"failures,omitempty"`
+	SkipsRemaining int       `json:"skipsRemaining,omitempty"`
+	Penalty        int       `json:"penalty,omitempty"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// breakerStatePath returns where the breaker named name is saved, beside
+// the dedup state file (circuit-breaker.json, repo-circuit-breaker.json).
+func breakerStatePath(statePath, name string) string {
+	return filepath.Join(filepath.Dir(statePath), name+".json")
+}
+
+// Load replaces the breaker's state with what was saved at path, dropping
+// entries unchanged for longer than breakerStateMaxAge. A missing or
+// unreadable file leaves the breaker empty. Each run is otherwise a fresh
+// process (or a fresh Pipeline under --serve), so this is what lets
+// failures, skips, and penalties span runs.
+func (cb *CircuitBreaker) Load(path string, now time.Time) {
+	var entries map[string]breakerEntry
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] ignoring unreadable %s: %v\n", cb.name, path, err)
+			entries = nil
+		}
+	}
+	cb.restore(entries, now)
+}
+
+// restore replaces the breaker's state with entries, dropping those
+// unchanged for longer than breakerStateMaxAge.
+func (cb *CircuitBreaker) restore(entries map[string]breakerEntry, now time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	clear(cb.failures)
+	clear(cb.skipsRemaining)
+	clear(cb.penalty)
+	cb.saved = map[string]breakerEntry{}
+	for key, e := range entries {
+		if now.Sub(e.UpdatedAt) > breakerStateMaxAge {
+			continue
+		}
+		if e.Failures > 0 {
+			cb.failures[key] = e.Failures
+		}
+		if e.SkipsRemaining > 0 {
+			cb.skipsRemaining[key] = e.SkipsRemaining
+		}
+		if e.Penalty > 0 {
+			cb.penalty[key] = min(e.Penalty, cbMaxDoublings)
+		}
+		cb.saved[key] = e
+	}
+}
+
+// Save writes the breaker's state to path, logging failures.
+func (cb *CircuitBreaker) Save(path string, now time.Time) {
+	data, err := json.MarshalIndent(cb.snapshot(now), "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] failed to save %s: %v\n", cb.name, path, err)
+	}
+}
+
+// snapshot returns the breaker's state to save. Entries that changed since
+// the last restore or snapshot are stamped with now; the rest keep their
+// age.
+func (cb *CircuitBreaker) snapshot(now time.Time) map[string]breakerEntry {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	entries := make(map[string]breakerEntry)
+	for _, m := range []map[string]int{cb.failures, cb.skipsRemaining, cb.penalty} {
+		for key := range m {
+			e := breakerEntry{Failures: cb.failures[key], SkipsRemaining: cb.skipsRemaining[key], Penalty: cb.penalty[key]}
+			if prev, ok := cb.saved[key]; ok && prev.Failures == e.Failures && prev.SkipsRemaining == e.SkipsRemaining && prev.Penalty == e.Penalty {
+				e.UpdatedAt = prev.UpdatedAt
+			} else {
+				e.UpdatedAt = now.UTC()
+			}
+			entries[key] = e
+		}
+	}
+	cb.saved = entries
+	return entries
+}
+
+// loadBreaker restores cb from the history DB h, or from its file beside
+// statePath when there's no DB.
+func loadBreaker(h *historyDB, cb *CircuitBreaker, statePath string, now time.Time) {
+	if h == nil {
+		cb.Load(breakerStatePath(statePath, cb.name), now)
+		return
+	}
+	entries, err := h.LoadBreaker(cb.name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] failed to load from the history db: %v\n", cb.name, err)
+	}
+	cb.restore(entries, now)
+}
+
+// saveBreaker saves cb to the history DB h, or to its file beside
+// statePath when there's no DB.
+func saveBreaker(h *historyDB, cb *CircuitBreaker, statePath string, now time.Time) {
+	if h == nil {
+		cb.Save(breakerStatePath(statePath, cb.name), now)
+		return
+	}
+	if err := h.SaveBreaker(cb.name, cb.snapshot(now)); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] failed to save to the history db: %v\n", cb.name, err)
+	}
+}
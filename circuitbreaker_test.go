@@ -1,7 +1,10 @@
 package main
 
 import (
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestCircuitBreaker(t *testing.T) {
@@ -135,6 +138,105 @@ func TestCircuitBreaker(t *testing.T) {
 	})
 }
 
+// skipsUntilClosed opens url's circuit and counts the runs it skips.
+func skipsUntilClosed(cb *CircuitBreaker, url string) int {
+	for i := 0; i < cb.failureThreshold; i++ {
+		cb.RecordFailure(url)
+	}
+	n := 0
+	for cb.IsOpen(url) {
+		n++
+	}
+	return n
+}
+
+func TestCircuitBreakerAdaptiveSkip(t *testing.T) {
+	t.Run("Repeat openings double the skip, capped", func(t *testing.T) {
+		cb := NewCircuitBreaker(2, 3)
+		url := "https://github.com/test/repo/pull/1"
+		var got []int
+		for i := 0; i < 7; i++ {
+			got = append(got, skipsUntilClosed(cb, url))
+		}
+		want := []int{3, 6, 12, 24, 48, 48, 48}
+		if !slices.Equal(got, want) {
+			t.Errorf("skips per opening = %v; want %v", got, want)
+		}
+	})
+
+	t.Run("Successes decay the penalty", func(t *testing.T) {
+		cb := NewCircuitBreaker(1, 2)
+		url := "https://github.com/test/repo/pull/1"
+		skipsUntilClosed(cb, url) // 2
+		skipsUntilClosed(cb, url) // 4
+		skipsUntilClosed(cb, url) // 8
+		cb.RecordSuccess(url)
+		if n := skipsUntilClosed(cb, url); n != 8 {
+			t.Errorf("after one success, skip = %d; want 8 (16 halved)", n)
+		}
+		for i := 0; i < 10; i++ {
+			cb.RecordSuccess(url)
+		}
+		if n := skipsUntilClosed(cb, url); n != 2 {
+			t.Errorf("after a run of successes, skip = %d; want back to 2", n)
+		}
+	})
+
+	t.Run("RecordFailure reports the opening", func(t *testing.T) {
+		cb := NewCircuitBreaker(2, 1)
+		url := "https://github.com/test/repo/pull/1"
+		if cb.RecordFailure(url) {
+			t.Error("first failure should not open the circuit")
+		}
+		if !cb.RecordFailure(url) {
+			t.Error("second failure should report opening the circuit")
+		}
+		if cb.RecordFailure(url) {
+			t.Error("a failure while already open should not report opening again")
+		}
+	})
+
+	t.Run("Penalty is per PR", func(t *testing.T) {
+		cb := NewCircuitBreaker(1, 2)
+		skipsUntilClosed(cb, "https://github.com/test/repo/pull/1")
+		if n := skipsUntilClosed(cb, "https://github.com/test/repo/pull/2"); n != 2 {
+			t.Errorf("other PR skip = %d; want 2", n)
+		}
+	})
+}
+
+func TestNewRepoCircuitBreaker(t *testing.T) {
+	if newRepoCircuitBreaker(0, 3) != nil {
+		t.Error("a zero threshold should disable the repo breaker")
+	}
+	if cb := newRepoCircuitBreaker(2, 3); cb == nil || cb.name != "repo-circuit-breaker" {
+		t.Errorf("repo breaker = %+v", cb)
+	}
+}
+
+func TestCircuitBreakerLoadSave(t *testing.T) {
+	path := breakerStatePath(filepath.Join(t.TempDir(), "state.json"), "circuit-breaker")
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	open, stale := "https://github.com/test/repo/pull/1", "https://github.com/test/repo/pull/2"
+
+	cb := NewCircuitBreaker(1, 2)
+	cb.Load(path, now)
+	cb.RecordFailure(open)
+	cb.RecordFailure(stale)
+	cb.Save(path, now.Add(-breakerStateMaxAge))
+	cb.IsOpen(open)
+	cb.Save(path, now)
+
+	next := NewCircuitBreaker(1, 2)
+	next.Load(path, now.Add(time.Hour))
+	if next.skipsRemaining[open] != 1 || next.penalty[open] != 1 {
+		t.Errorf("loaded %s: skips %d, penalty %d; want 1, 1", open, next.skipsRemaining[open], next.penalty[open])
+	}
+	if _, ok := next.penalty[stale]; ok {
+		t.Errorf("%s was unchanged past breakerStateMaxAge and should have been dropped", stale)
+	}
+}
+
 func TestCircuitBreakerConcurrency(t *testing.T) {
 	cb := NewCircuitBreaker(3, 5)
 	url := "https://github.com/test/repo/pull/1"
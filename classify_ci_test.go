@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -84,4 +85,61 @@ func TestClassifyCIFailure(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestFailingChecks(t *testing.T) {
+	entries := []statusRollupEntry{
+		{Typename: "CheckRun", Name: "ci", Status: "COMPLETED", Conclusion: "SUCCESS"},
+		{Typename: "CheckRun", Name: "unit tests", Status: "COMPLETED", Conclusion: "FAILURE", DetailsURL: "https://github.com/o/r/actions/runs/1/job/2"},
+		{Typename: "CheckRun", Name: "deploy", Status: "IN_PROGRESS"},
+		{Typename: "CheckRun", Name: "e2e", Status: "COMPLETED", Conclusion: "TIMED_OUT"},
+		{Typename: "StatusContext", Context: "ci/circleci", State: "ERROR", TargetURL: "https://circleci.com/gh/o/r/9"},
+		{Typename: "StatusContext", Context: "codecov", State: "PENDING"},
+	}
+	failing := failingChecks(entries)
+	if len(failing) != 3 {
+		t.Fatalf("expected 3 failing checks, got %d: %+v", len(failing), failing)
+	}
+
+	body := buildCommentBody(&prView{Mergeable: "MERGEABLE", StatusCheckRollup: entries}, "checks_failure")
+	for _, want := range []string{
+		"Failing checks:",
+		"- `unit tests`: `FAILURE` ([details](https://github.com/o/r/actions/runs/1/job/2))",
+		"- `e2e`: `TIMED_OUT`\n",
+		"- `ci/circleci`: `ERROR` ([details](https://circleci.com/gh/o/r/9))",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("comment missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "deploy") || strings.Contains(body, "codecov") {
+		t.Errorf("pending checks should not be listed:\n%s", body)
+	}
+}
+
+func TestBuildCommentBody_truncatesFailingChecks(t *testing.T) {
+	var failing []statusRollupEntry
+	for i := 0; i < maxListedChecks+3; i++ {
+		failing = append(failing, statusRollupEntry{Typename: "CheckRun", Name: "job", Conclusion: "FAILURE"})
+	}
+	body := buildCommentBody(&prView{StatusCheckRollup: failing}, "checks_failure")
+	if n := strings.Count(body, "- `job`: `FAILURE`"); n != maxListedChecks || !strings.Contains(body, "\n- …and 3 more\n") {
+		t.Errorf("listed %d checks:\n%s", n, body)
+	}
+}
+
+func TestBuildCommentBody_testFailure(t *testing.T) {
+	entries := []statusRollupEntry{
+		{Typename: "CheckRun", Name: "unit tests", Status: "COMPLETED", Conclusion: "FAILURE"},
+		{Typename: "StatusContext", Context: "integration-tests", State: "FAILURE"},
+	}
+	if got := failingCheckNames(entries); strings.Join(got, ",") != "unit tests,integration-tests" {
+		t.Errorf("failingCheckNames() = %q", got)
+	}
+	body := buildCommentBody(&prView{StatusCheckRollup: entries}, "checks_failure")
+	if !strings.Contains(body, "Test-fix subagent dispatched") {
+		t.Errorf("test failures should mention the test-fix dispatch:\n%s", body)
+	}
+	if strings.Contains(body, "Lint-fix") {
+		t.Errorf("test failures should not mention lint dispatch:\n%s", body)
+	}
+}
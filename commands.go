@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, `Usage: fab-pr-pipeline [command] [flags]
+
+Commands:
+  run      scan the org and merge/comment on selected PRs (default)
+  scan     print the PRs a run would select, without fetching or acting on them
+  plan     dry-run and save the intended actions to a plan file for review
+  apply    carry out a saved plan, skipping PRs that changed since planning
+  report   re-post the last run's summary to Discord
+  doctor   check gh, GitHub auth, and Discord configuration
+  history  query past outcomes from the history database
+  digest   post a report of the org's open PRs (oldest, stuck, conflicting) without acting on them
+  explain  dry-run one PR (explain <pr-url>) and print each gate checked and the decision
+  revert   open a PR reverting one the pipeline merged (revert <pr-url>) and link it from the original
+
+Run "fab-pr-pipeline <command> -h" for the command's flags.
+`)
+}
+
+// scanOutput is the JSON printed by the scan subcommand.
+type scanOutput struct {
+	Ok        bool        `json:"ok"`
+	StartedAt string      `json:"startedAt"`
+	Org       string      `json:"org"`
+	MaxPRs    int         `json:"maxPRs"`
+	Scanned   int         `json:"scanned"`
+	Selected  []scanEntry `json:"selected"`
+	// Filtered are the scanned PRs the selection policy left out.
+	Filtered []filteredPR `json:"filtered,omitempty"`
+}
+
+type scanEntry struct {
+	URL       string `json:"url"`
+	Repo      string `json:"repo"`
+	Number    int    `json:"number"`
+	Author    string `json:"author"`
+	UpdatedAt string `json:"updatedAt"`
+	IsDraft   bool   `json:"isDraft,omitempty"`
+}
+
+// runScanCommand implements `fab-pr-pipeline scan`: selection only, with no
+// per-PR gh pr view calls and no mutations.
+func runScanCommand(args []string) int {
+	opts, code := parseRunFlags("scan", args)
+	if opts == nil {
+		return code
+	}
+	out := scanOutput{
+		Ok:        true,
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+		Org:       opts.Org,
+		MaxPRs:    opts.MaxPRs,
+		Selected:  []scanEntry{},
+	}
+	ctx, cancel := opts.runContext()
+	defer cancel()
+	selected, filtered, err := newPipeline(opts).scanPRs(ctx, time.Now())
+	if err != nil {
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return 1
+	}
+	out.Scanned = len(selected) + len(filtered)
+	out.Filtered = filtered
+	for _, pr := range selected {
+		out.Selected = append(out.Selected, scanEntry{
+			URL:       pr.URL,
+			Repo:      pr.Repository.NameWithOwner,
+			Number:    pr.Number,
+			Author:    pr.Author.Login,
+			UpdatedAt: pr.UpdatedAt.UTC().Format(time.RFC3339),
+			IsDraft:   pr.IsDraft,
+		})
+	}
+	emitJSON(out)
+	return 0
+}
+
+// runReportCommand implements `fab-pr-pipeline report`: re-post the last
+// saved run to Discord, bypassing the dedup window.
+func runReportCommand(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	var (
+		stateFile  = fs.String("state-file", "", "path to state file (the last run is stored beside it)")
+		reportTo   = fs.String("discord-report-to", "", "Discord report destination (e.g. channel:<id> or raw id). Requires DISCORD_BOT_TOKEN.")
+		alertsTo   = fs.String("discord-alerts-to", "", "Discord alerts destination (e.g. channel:<id> or raw id). Requires DISCORD_BOT_TOKEN.")
+		postEmpty  = fs.Bool("post-empty", false, "post a report even when the last run acted on no PRs")
+		postDryRun = fs.Bool("post-dry-run", false, "allow posting when the last run was a dry run")
+	)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	path := lastRunPath(resolveStatePath(*stateFile))
+	last, err := loadLastRun(path)
+	if err != nil {
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return 1
+	}
+	d := &discordOut{
+		ReportTo: normalizeDiscordTarget(*reportTo),
+		AlertsTo: normalizeDiscordTarget(*alertsTo),
+	}
+	if d.ReportTo == "" && d.AlertsTo == "" {
+		emitJSON(map[string]any{"ok": false, "error": "report: --discord-report-to or --discord-alerts-to is required"})
+		return 2
+	}
+	if err := maybePostDiscord(context.Background(), last, *reportTo, *alertsTo, *postEmpty, *postDryRun); err != nil {
+		d.Error = err.Error()
+		emitJSON(map[string]any{"ok": false, "startedAt": last.StartedAt, "discord": d})
+		return 1
+	}
+	d.Posted = true
+	emitJSON(map[string]any{"ok": true, "startedAt": last.StartedAt, "discord": d})
+	return 0
+}
+
+// doctorCheck is one preflight check result.
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Ok     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// doctorReport is the JSON printed by the doctor subcommand.
+type doctorReport struct {
+	Ok     bool          `json:"ok"`
+	Checks []doctorCheck `json:"checks"`
+}
+
+func (r *doctorReport) add(name string, err error, okDetail string) {
+	c := doctorCheck{Name: name, Ok: err == nil, Detail: okDetail}
+	if err != nil {
+		c.Detail = err.Error()
+		r.Ok = false
+	}
+	r.Checks = append(r.Checks, c)
+}
+
+// requiredGitHubScopes are the classic-token scopes the pipeline needs:
+// repo to merge/comment, read:org to search and list org repos.
+var requiredGitHubScopes = []string{"repo", "read:org"}
+
+// impliedScopes lists broader scopes that grant a required scope.
+var impliedScopes = map[string][]string{
+	"read:org": {"write:org", "admin:org"},
+}
+
+// checkGitHubScopes reads the token's OAuth scopes from the X-OAuth-Scopes
+// header and reports any required scope that is missing. Fine-grained and
+// app tokens don't send the header; those pass with a note.
+func checkGitHubScopes(ctx context.Context) (string, error) {
+	out, err := runGh(ctx, "api", "--include", "user")
+	if err != nil {
+		return "", err
+	}
+	scopes, ok := parseOAuthScopes(string(out))
+	if !ok {
+		return "scopes not reported (fine-grained or app token); skipped", nil
+	}
+	if missing := missingScopes(scopes, requiredGitHubScopes); len(missing) > 0 {
+		return "", fmt.Errorf("token missing scopes: %s (has: %s)", strings.Join(missing, ", "), strings.Join(scopes, ", "))
+	}
+	return strings.Join(scopes, ", "), nil
+}
+
+// parseOAuthScopes extracts scopes from a raw HTTP response (headers first).
+// ok is false when the header is absent.
+func parseOAuthScopes(raw string) ([]string, bool) {
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			// End of headers.
+			break
+		}
+		name, value, found := strings.Cut(line, ":")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "X-OAuth-Scopes") {
+			continue
+		}
+		return splitList(value), true
+	}
+	return nil, false
+}
+
+// missingScopes returns the required scopes not granted by have.
+func missingScopes(have []string, required []string) []string {
+	granted := make(map[string]bool, len(have))
+	for _, s := range have {
+		granted[strings.ToLower(s)] = true
+	}
+	var missing []string
+	for _, req := range required {
+		ok := granted[req]
+		for _, broader := range impliedScopes[req] {
+			ok = ok || granted[broader]
+		}
+		if !ok {
+			missing = append(missing, req)
+		}
+	}
+	return missing
+}
+
+// runDoctorCommand implements `fab-pr-pipeline doctor`: verifies gh is
+// installed and authenticated with the needed scopes, and that the Discord
+// token can see the configured channels. Exits non-zero if any check fails.
+func runDoctorCommand(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	var (
+		reportTo = fs.String("discord-report-to", "", "Discord report destination to validate")
+		alertsTo = fs.String("discord-alerts-to", "", "Discord alerts destination to validate")
+	)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	ctx := context.Background()
+	report := doctorReport{Ok: true}
+
+	ghPath, err := exec.LookPath("gh")
+	report.add("gh_cli", err, ghPath)
+	if err == nil {
+		_, authErr := runGh(ctx, "auth", "status")
+		report.add("gh_auth", authErr, "authenticated")
+		if authErr == nil {
+			detail, scopeErr := checkGitHubScopes(ctx)
+			report.add("gh_token_scopes", scopeErr, detail)
+		}
+	}
+
+	targets := map[string]string{}
+	if t := normalizeDiscordTarget(*reportTo); t != "" {
+		targets["discord_report_channel"] = t
+	}
+	if t := normalizeDiscordTarget(*alertsTo); t != "" {
+		targets["discord_alerts_channel"] = t
+	}
+	if len(targets) > 0 {
+		token := strings.TrimSpace(discordBotToken())
+		if token == "" {
+			report.add("discord_token", errors.New("DISCORD_BOT_TOKEN missing (needed for Discord posting)"), "")
+		} else {
+			botName, tokenErr := discordCheckToken(ctx, token)
+			report.add("discord_token", tokenErr, botName)
+			if tokenErr == nil {
+				for _, name := range []string{"discord_report_channel", "discord_alerts_channel"} {
+					if ch, ok := targets[name]; ok {
+						channelName, chErr := discordCheckChannel(ctx, token, ch)
+						report.add(name, chErr, channelName)
+					}
+				}
+			}
+		}
+	}
+
+	writeJSON(stdout, report)
+	if !report.Ok {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRunOptions(t *testing.T, args ...string) *runOptions {
+	t.Helper()
+	opts, code := parseRunFlags("run", args)
+	if opts == nil {
+		t.Fatalf("parseRunFlags(%v) failed with exit code %d", args, code)
+	}
+	return opts
+}
+
+func makeSearchPR(url string, repo string, author string, updatedAt time.Time) searchPR {
+	pr := searchPR{URL: url, UpdatedAt: updatedAt}
+	pr.Author.Login = author
+	pr.Repository.NameWithOwner = repo
+	return pr
+}
+
+func TestSelectPRs(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	opts := testRunOptions(t, "--skip-repos", "org/skipped")
+
+	draft := makeSearchPR("draft", "org/a", "kaylee-mistystep", now.Add(-time.Hour))
+	draft.IsDraft = true
+	dnt := makeSearchPR("dnt", "org/a", "kaylee-mistystep", now.Add(-time.Hour))
+	dnt.Labels = []label{{Name: "Do Not Touch"}}
+
+	prs := []searchPR{
+		makeSearchPR("older", "org/a", "kaylee-mistystep", now.Add(-5*time.Hour)),
+		makeSearchPR("newer", "org/a", "someone", now.Add(-time.Hour)),
+		makeSearchPR("phaedrus-fresh", "org/a", "phrazzld", now.Add(-time.Hour)),
+		makeSearchPR("phaedrus-stale", "org/a", "phrazzld", now.Add(-100*time.Hour)),
+		makeSearchPR("skipped-repo", "org/skipped", "kaylee-mistystep", now),
+		makeSearchPR("no-author", "org/a", "", now),
+		draft,
+		dnt,
+	}
+
+	got := selectPRs(opts, prs, now)
+	var urls []string
+	for _, pr := range got {
+		urls = append(urls, pr.URL)
+	}
+	want := "newer,older,phaedrus-stale"
+	if strings.Join(urls, ",") != want {
+		t.Errorf("selectPRs() = %v; want %s", urls, want)
+	}
+}
+
+func TestParseRunFlags_invalid(t *testing.T) {
+	if opts, code := parseRunFlags("run", []string{"--no-such-flag"}); opts != nil || code != 2 {
+		t.Errorf("unknown flag: expected exit 2, got opts=%v code=%d", opts, code)
+	}
+	if opts, code := parseRunFlags("run", []string{"--flaky-check-regex", "("}); opts != nil || code != 1 {
+		t.Errorf("bad regex: expected exit 1, got opts=%v code=%d", opts, code)
+	}
+}
+
+func TestLastRunRoundtrip(t *testing.T) {
+	path := lastRunPath(filepath.Join(t.TempDir(), "state.json"))
+	if _, err := loadLastRun(path); err == nil {
+		t.Error("expected error when no run has been saved")
+	}
+	out := runOutput{Ok: true, StartedAt: "2025-01-15T10:30:00Z", Org: "misty-step", Results: []prOutcome{{URL: "u", Action: "merged"}}}
+	if err := saveLastRun(path, out); err != nil {
+		t.Fatalf("saveLastRun failed: %v", err)
+	}
+	got, err := loadLastRun(path)
+	if err != nil {
+		t.Fatalf("loadLastRun failed: %v", err)
+	}
+	if got.StartedAt != out.StartedAt || len(got.Results) != 1 || got.Results[0].Action != "merged" {
+		t.Errorf("roundtrip mismatch: got %+v", got)
+	}
+}
+
+func TestRunDoctorCommand_missingGh(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("DISCORD_BOT_TOKEN", "")
+	t.Setenv("DISCORD_BOT_TOKEN_AMOS", "")
+
+	var stdout bytes.Buffer
+	code := runDoctorCommand([]string{"--discord-report-to", "channel:1"}, &stdout)
+	if code != 1 {
+		t.Errorf("expected exit 1, got %d", code)
+	}
+	var report doctorReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, stdout.String())
+	}
+	if report.Ok {
+		t.Error("report should not be ok without gh")
+	}
+	failed := map[string]bool{}
+	for _, c := range report.Checks {
+		if !c.Ok {
+			failed[c.Name] = true
+		}
+	}
+	if !failed["gh_cli"] || !failed["discord_token"] {
+		t.Errorf("expected gh_cli and discord_token to fail, got %+v", report.Checks)
+	}
+}
+
+func TestPrintUsage(t *testing.T) {
+	var buf bytes.Buffer
+	printUsage(&buf)
+	for _, cmd := range []string{"run", "scan", "report", "doctor", "history"} {
+		if !strings.Contains(buf.String(), "  "+cmd+" ") {
+			t.Errorf("usage missing command %q", cmd)
+		}
+	}
+}
+
+func TestParseOAuthScopes(t *testing.T) {
+	raw := "HTTP/2.0 200 OK\r\nContent-Type: application/json\r\nX-Oauth-Scopes: repo, read:org, gist\r\n\r\n{\"login\":\"x\"}"
+	scopes, ok := parseOAuthScopes(raw)
+	if !ok {
+		t.Fatal("expected scopes header to be found")
+	}
+	if strings.Join(scopes, ",") != "repo,read:org,gist" {
+		t.Errorf("unexpected scopes %v", scopes)
+	}
+
+	// Header appearing in the body must not count.
+	if _, ok := parseOAuthScopes("HTTP/2.0 200 OK\r\n\r\nX-OAuth-Scopes: repo"); ok {
+		t.Error("scopes in body should be ignored")
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	tests := []struct {
+		have []string
+		want string
+	}{
+		{[]string{"repo", "read:org"}, ""},
+		{[]string{"repo", "admin:org"}, ""},
+		{[]string{"repo"}, "read:org"},
+		{[]string{"public_repo", "read:org"}, "repo"},
+		{nil, "repo,read:org"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(missingScopes(tt.have, requiredGitHubScopes), ","); got != tt.want {
+			t.Errorf("missingScopes(%v) = %q; want %q", tt.have, got, tt.want)
+		}
+	}
+}
+
+func TestDiscordChecks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message": "401: Unauthorized"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/users/@me":
+			_, _ = w.Write([]byte(`{"username":"amos"}`))
+		case "/channels/123":
+			_, _ = w.Write([]byte(`{"name":"pipeline"}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message": "Missing Access"}`))
+		}
+	}))
+	defer srv.Close()
+	old := discordAPIBase
+	discordAPIBase = srv.URL
+	defer func() { discordAPIBase = old }()
+
+	if name, err := discordCheckToken(context.Background(), "good"); err != nil || name != "amos" {
+		t.Errorf("discordCheckToken(good) = %q, %v", name, err)
+	}
+	if _, err := discordCheckToken(context.Background(), "bad"); err == nil {
+		t.Error("expected error for bad token")
+	}
+	if name, err := discordCheckChannel(context.Background(), "good", "123"); err != nil || name != "#pipeline" {
+		t.Errorf("discordCheckChannel(123) = %q, %v", name, err)
+	}
+	if _, err := discordCheckChannel(context.Background(), "good", "999"); err == nil || !strings.Contains(err.Error(), "Missing Access") {
+		t.Errorf("expected Missing Access error, got %v", err)
+	}
+}
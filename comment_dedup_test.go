@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildCommentBody_notMergedMarker(t *testing.T) {
+	pr := &prView{
+		Mergeable: "MERGEABLE",
+		StatusCheckRollup: []statusRollupEntry{
+			{Typename: "CheckRun", Name: "build", Status: "COMPLETED", Conclusion: "FAILURE"},
+		},
+	}
+	body := buildCommentBody(pr, "checks_failure")
+	want := notMergedMarker("checks_failure", "FAILURE")
+	if !strings.Contains(body, "\n"+want+"\n") {
+		t.Errorf("body missing marker line %q; got:\n%s", want, body)
+	}
+	if !hasNotMergedComment([]string{body}, "checks_failure", "FAILURE") {
+		t.Error("hasNotMergedComment should recognize the comment buildCommentBody produced")
+	}
+}
+
+func TestHasNotMergedComment(t *testing.T) {
+	failing := notMergedMarker("checks_failure", "FAILURE") + "\nPR pipeline: not merged automatically."
+	pending := notMergedMarker("checks_pending", "PENDING") + "\nPR pipeline: not merged automatically."
+
+	tests := []struct {
+		name     string
+		comments []string
+		reason   string
+		checks   string
+		want     bool
+	}{
+		{"no comments", nil, "checks_failure", "FAILURE", false},
+		{"same blocker", []string{"LGTM", failing}, "checks_failure", "FAILURE", true},
+		{"checks state changed", []string{failing}, "checks_failure", "PENDING", false},
+		{"reason changed", []string{failing}, "review_required", "FAILURE", false},
+		{"only newest pipeline comment counts", []string{pending, failing}, "checks_failure", "FAILURE", false},
+		{"legacy marker without key", []string{"<!-- pr-pipeline -->\nPR pipeline: not merged automatically."}, "checks_failure", "FAILURE", false},
+		{"conflict comment ignored", []string{"<!-- kaylee-pr-pipeline -->\n⚠️ This PR has " + conflictCommentMarker + ".", failing}, "checks_failure", "FAILURE", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasNotMergedComment(tt.comments, tt.reason, tt.checks); got != tt.want {
+				t.Errorf("hasNotMergedComment = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
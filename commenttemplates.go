@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Comment templates, one file each. The embedded templates/<tone>
+// directories hold the built-in tone profiles; --comment-template-dir
+// replaces their files or adds tones of its own.
+const (
+	notMergedTemplate = "not_merged.tmpl"
+	conflictTemplate  = "conflict.tmpl"
+)
+
+var commentTemplateNames = []string{notMergedTemplate, conflictTemplate}
+
+// defaultCommentTone is the tone used unless the config picks another.
+const defaultCommentTone = "default"
+
+//go:embed templates
+var embeddedTemplates embed.FS
+
+// commentTemplates renders the pipeline's PR comments in each tone. A nil
+// *commentTemplates renders the embedded defaults.
+type commentTemplates struct {
+	tones map[string]*template.Template
+}
+
+// defaultCommentTemplates is the embedded tone profiles, parsed once.
+var defaultCommentTemplates = sync.OnceValue(func() *commentTemplates {
+	c := &commentTemplates{tones: map[string]*template.Template{}}
+	if err := c.parseTones(embeddedTemplates, "templates"); err != nil {
+		panic(fmt.Sprintf("embedded comment templates: %v", err))
+	}
+	return c
+})
+
+// parseTones parses each subdirectory of root in fsys as the tone it's
+// named for, default first, since a tone new to c starts from the default
+// one.
+func (c *commentTemplates) parseTones(fsys fs.FS, root string) error {
+	entries, err := fs.ReadDir(fsys, root)
+	if err != nil {
+		return err
+	}
+	var tones []string
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != defaultCommentTone {
+			tones = append(tones, e.Name())
+		}
+	}
+	if slices.ContainsFunc(entries, func(e fs.DirEntry) bool { return e.IsDir() && e.Name() == defaultCommentTone }) {
+		tones = append([]string{defaultCommentTone}, tones...)
+	}
+	for _, tone := range tones {
+		if err := c.parseTone(fsys, path.Join(root, tone), tone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseTone parses the templates in dir into tone, replacing its existing
+// templates file by file.
+func (c *commentTemplates) parseTone(fsys fs.FS, dir string, tone string) error {
+	t := template.New(tone)
+	if base := cmp.Or(c.tones[tone], c.tones[defaultCommentTone]); base != nil {
+		var err error
+		if t, err = base.Clone(); err != nil {
+			return err
+		}
+	}
+	for _, name := range commentTemplateNames {
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := t.New(name).Parse(string(data)); err != nil {
+			return fmt.Errorf("%s: %w", path.Join(dir, name), err)
+		}
+	}
+	for _, name := range commentTemplateNames {
+		if t.Lookup(name) == nil {
+			return fmt.Errorf("%s: missing %s", dir, name)
+		}
+	}
+	c.tones[tone] = t
+	return nil
+}
+
+// loadCommentTemplates returns the embedded tones with dir's templates
+// swapped in: files directly in dir replace the default tone's, and each
+// subdirectory replaces (or adds) the tone it's named for. Every template
+// is test-rendered so a typo'd field fails at startup rather than on the
+// first PR that needs it.
+func loadCommentTemplates(dir string) (*commentTemplates, error) {
+	if strings.TrimSpace(dir) == "" {
+		return defaultCommentTemplates(), nil
+	}
+	top, _ := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	nested, _ := filepath.Glob(filepath.Join(dir, "*", "*.tmpl"))
+	if len(top)+len(nested) == 0 {
+		return nil, fmt.Errorf("--comment-template-dir %s: no templates (want %s, or a directory of them per tone)", dir, strings.Join(commentTemplateNames, " or "))
+	}
+	c := &commentTemplates{tones: maps.Clone(defaultCommentTemplates().tones)}
+	fsys := os.DirFS(dir)
+	if err := c.parseTone(fsys, ".", defaultCommentTone); err != nil {
+		return nil, fmt.Errorf("--comment-template-dir: %w", err)
+	}
+	if err := c.parseTones(fsys, "."); err != nil {
+		return nil, fmt.Errorf("--comment-template-dir: %w", err)
+	}
+	for tone, t := range c.tones {
+		for _, name := range commentTemplateNames {
+			if err := t.ExecuteTemplate(io.Discard, name, sampleCommentData()); err != nil {
+				return nil, fmt.Errorf("--comment-template-dir: tone %s: %w", tone, err)
+			}
+		}
+	}
+	return c, nil
+}
+
+// hasTone reports whether tone is defined ("" means the default).
+func (c *commentTemplates) hasTone(tone string) bool {
+	if c == nil {
+		c = defaultCommentTemplates()
+	}
+	return tone == "" || c.tones[tone] != nil
+}
+
+// commentData is what a comment template can use.
+type commentData struct {
+	URL            string
+	Title          string
+	Author         string
+	Reason         string
+	Mergeable      string
+	Checks         string // overall checks state, e.g. FAILURE
+	ReviewDecision string
+	// ReviewSummary is the review state in words, e.g. "changes requested,
+	// 2 unresolved review threads".
+	ReviewSummary string
+	// FailingChecks lists up to maxListedChecks failing checks;
+	// MoreFailingChecks counts the rest. Likewise for OptionalChecks.
+	FailingChecks      []commentCheck
+	MoreFailingChecks  int
+	OptionalChecks     []commentCheck
+	MoreOptionalChecks int
+	UnresolvedThreads  []commentThread
+	Additions          int
+	Deletions          int
+	ChangedFiles       int
+	ProtectedFiles     []string
+	UntrustedCommits   []string
+	SecretFindings     []string
+	// CIFailureType is lint or test when a failing-checks reason was
+	// handed to a fix-up agent, else "".
+	CIFailureType string
+	// MergeCondition and MergeConditionExpr name the config merge
+	// condition that held the PR back, else "".
+	MergeCondition     string
+	MergeConditionExpr string
+}
+
+type commentCheck struct {
+	Name   string
+	Result string // conclusion or status state, upper case
+	URL    string
+}
+
+type commentThread struct {
+	Location string // path:line
+	Author   string
+	Body     string
+}
+
+func newCommentData(pr *prView, reason string) commentData {
+	d := commentData{
+		URL:              pr.URL,
+		Title:            pr.Title,
+		Author:           pr.Author.Login,
+		Reason:           reason,
+		Mergeable:        pr.Mergeable,
+		Checks:           overallChecksState(pr.StatusCheckRollup),
+		ReviewDecision:   pr.ReviewDecision,
+		ReviewSummary:    reviewSummary(pr),
+		Additions:        pr.Additions,
+		Deletions:        pr.Deletions,
+		ChangedFiles:     pr.ChangedFiles,
+		ProtectedFiles:   pr.ProtectedFiles,
+		UntrustedCommits: pr.UntrustedCommits,
+		SecretFindings:   pr.SecretFindings,
+	}
+	if c := pr.FailedCondition; c != nil {
+		d.MergeCondition, d.MergeConditionExpr = c.Name, c.Expr
+	}
+	d.FailingChecks, d.MoreFailingChecks = commentChecks(failingChecks(pr.StatusCheckRollup))
+	d.OptionalChecks, d.MoreOptionalChecks = commentChecks(failingChecks(pr.OptionalChecks))
+	for _, t := range pr.UnresolvedThreads {
+		d.UnresolvedThreads = append(d.UnresolvedThreads, commentThread{Location: t.location(), Author: t.Author, Body: t.Body})
+	}
+	if strings.HasPrefix(reason, "checks_") {
+		switch ci := classifyCIFailure(pr.StatusCheckRollup); ci {
+		case "lint", "test":
+			d.CIFailureType = ci
+		}
+	}
+	return d
+}
+
+// commentChecks converts failing rollup entries for a template, keeping
+// the first maxListedChecks and counting the rest.
+func commentChecks(failing []statusRollupEntry) ([]commentCheck, int) {
+	var checks []commentCheck
+	for i, e := range failing {
+		if i == maxListedChecks {
+			return checks, len(failing) - maxListedChecks
+		}
+		c := commentCheck{Name: e.Name, Result: e.Conclusion, URL: e.DetailsURL}
+		if strings.TrimSpace(e.Typename) == "StatusContext" {
+			c = commentCheck{Name: e.Context, Result: e.State, URL: e.TargetURL}
+		}
+		c.Result = strings.ToUpper(strings.TrimSpace(c.Result))
+		checks = append(checks, c)
+	}
+	return checks, 0
+}
+
+// reviewSummary puts the PR's review state in words.
+func reviewSummary(pr *prView) string {
+	summary := "no review decision"
+	if d := strings.TrimSpace(pr.ReviewDecision); d != "" {
+		summary = strings.ToLower(strings.ReplaceAll(d, "_", " "))
+	}
+	switch n := len(pr.UnresolvedThreads); n {
+	case 0:
+	case 1:
+		summary += ", 1 unresolved review thread"
+	default:
+		summary += fmt.Sprintf(", %d unresolved review threads", n)
+	}
+	return summary
+}
+
+// sampleCommentData fills every field, for test-rendering templates.
+func sampleCommentData() commentData {
+	pr := &prView{
+		URL:               "https://github.com/owner/repo/pull/1",
+		Title:             "Sample",
+		Mergeable:         "MERGEABLE",
+		ReviewDecision:    "CHANGES_REQUESTED",
+		StatusCheckRollup: []statusRollupEntry{{Typename: "CheckRun", Name: "test", Status: "COMPLETED", Conclusion: "FAILURE"}},
+		UnresolvedThreads: []reviewThread{{Path: "main.go", Line: 1, Author: "reviewer", Body: "Fix this."}},
+		ProtectedFiles:    []string{"infra/main.tf"},
+		UntrustedCommits:  []string{"abc1234"},
+		SecretFindings:    []string{"main.go:1"},
+	}
+	pr.OptionalChecks = pr.StatusCheckRollup
+	return newCommentData(pr, "checks_failure")
+}
+
+// render executes the named template in tone ("" for the default).
+func (c *commentTemplates) render(tone string, name string, data commentData) (string, error) {
+	if c == nil {
+		c = defaultCommentTemplates()
+	}
+	if tone == "" {
+		tone = defaultCommentTone
+	}
+	t := c.tones[tone]
+	if t == nil {
+		return "", fmt.Errorf("unknown comment tone %q", tone)
+	}
+	var b bytes.Buffer
+	if err := t.ExecuteTemplate(&b, name, data); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// body renders the comment in tone for a PR not merged for reason, after
+// the hidden markers that dedup depends on (which templates can't change).
+// A template that fails falls back to the built-in default.
+func (c *commentTemplates) body(pr *prView, reason string, tone string) string {
+	name, header := notMergedTemplate, stickyCommentMarker+"\n"+notMergedMarker(reason, overallChecksState(pr.StatusCheckRollup))
+	if reason == "mergeable_conflicting" {
+		name, header = conflictTemplate, stickyCommentMarker
+	}
+	data := newCommentData(pr, reason)
+	text, err := c.render(tone, name, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[comment-template] %s: %v; using the default\n", name, err)
+		if text, err = defaultCommentTemplates().render("", name, data); err != nil {
+			text = "PR pipeline: not merged automatically.\n\n- reason: `" + reason + "`"
+		}
+	}
+	return header + "\n" + text
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCommentTemplate(t *testing.T, dir string, name string, text string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadCommentTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeCommentTemplate(t, dir, notMergedTemplate, "Not merged ({{.Reason}}): {{.ReviewSummary}}.\n{{range .FailingChecks}}* {{.Name}}\n{{end}}")
+	c, err := loadCommentTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr := fakePR("misty-step/api", 1)
+	pr.ReviewDecision = "CHANGES_REQUESTED"
+	pr.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "lint", Status: "COMPLETED", Conclusion: "FAILURE"}}
+	pr.UnresolvedThreads = []reviewThread{{Path: "main.go", Line: 3}, {Path: "go.mod"}}
+	want := stickyCommentMarker + "\n" + notMergedMarker("checks_failure", "FAILURE") + "\n" +
+		"Not merged (checks_failure): changes requested, 2 unresolved review threads.\n* lint"
+	if got := c.body(pr, "checks_failure", ""); got != want {
+		t.Errorf("body =\n%s\nwant\n%s", got, want)
+	}
+	// The conflict comment keeps the default wording.
+	if got, want := c.body(pr, "mergeable_conflicting", ""), buildCommentBody(pr, "mergeable_conflicting"); got != want {
+		t.Errorf("conflict body = %q; want the default %q", got, want)
+	}
+
+	for name, text := range map[string]string{
+		"unparsable":    "{{if .Reason}}",
+		"unknown field": "{{.Reviewers}}",
+	} {
+		writeCommentTemplate(t, dir, conflictTemplate, text)
+		if _, err := loadCommentTemplates(dir); err == nil || !strings.Contains(err.Error(), "conflict.tmpl") {
+			t.Errorf("%s template: err = %v", name, err)
+		}
+	}
+	if _, err := loadCommentTemplates(t.TempDir()); err == nil {
+		t.Error("a directory with no templates should be rejected")
+	}
+}
+
+func TestPipelineUsesCommentTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeCommentTemplate(t, dir, notMergedTemplate, "Blocked on {{.Reason}}; checks {{.Checks}}.")
+	pr := fakePR("misty-step/api", 1)
+	pr.ReviewDecision = "REVIEW_REQUIRED"
+	fake := newFakeGitHub(pr)
+
+	p := newPipeline(testPipelineOptions(t, "-comment-template-dir", dir))
+	p.client = fake
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	posted := fake.posted[pr.URL]
+	if len(posted) != 1 || !strings.Contains(posted[0], "\nBlocked on review_required; checks SUCCESS.\n") {
+		t.Errorf("posted = %q", posted)
+	}
+}
+
+func TestCommentTones(t *testing.T) {
+	pr := fakePR("misty-step/api", 1)
+	pr.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "lint", Status: "COMPLETED", Conclusion: "FAILURE"}}
+	c := defaultCommentTemplates()
+	for _, tone := range []string{"default", "terse-machine", "verbose-human", "emoji-free"} {
+		for _, reason := range []string{"checks_failure", "mergeable_conflicting"} {
+			body := c.body(pr, reason, tone)
+			if !strings.HasPrefix(body, stickyCommentMarker+"\n") || strings.Contains(body, "[comment-template]") {
+				t.Errorf("%s %s: body =\n%s", tone, reason, body)
+			}
+		}
+	}
+	if got := c.body(pr, "checks_failure", "terse-machine"); !strings.Contains(got, "\nnot merged: checks_failure\nmergeable=MERGEABLE checks=FAILURE review=APPROVED\nfail: lint FAILURE\ndispatched: lint-fix") {
+		t.Errorf("terse-machine body =\n%s", got)
+	}
+	if got := c.body(pr, "checks_failure", "verbose-human"); !strings.Contains(got, "Hi @kaylee-mistystep!") || !strings.Contains(got, "lint-fix agent") {
+		t.Errorf("verbose-human body =\n%s", got)
+	}
+	for _, reason := range []string{"checks_failure", "mergeable_conflicting"} {
+		if got := c.body(pr, reason, "emoji-free"); strings.ContainsAny(got, "⚠️🧹🧪") {
+			t.Errorf("emoji-free %s body has emoji:\n%s", reason, got)
+		}
+	}
+}
+
+func TestLoadCommentTemplatesTones(t *testing.T) {
+	dir := t.TempDir()
+	writeCommentTemplate(t, dir, notMergedTemplate, "custom default {{.Reason}}")
+	for _, tone := range []string{"friendly", "terse-machine"} {
+		if err := os.Mkdir(filepath.Join(dir, tone), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCommentTemplate(t, dir, filepath.Join("friendly", conflictTemplate), "Conflicts, sorry!")
+	writeCommentTemplate(t, dir, filepath.Join("terse-machine", conflictTemplate), "conflict")
+	c, err := loadCommentTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr := fakePR("misty-step/api", 1)
+	for _, tt := range []struct{ tone, reason, want string }{
+		// A new tone starts from the (customized) default.
+		{"friendly", "mergeable_conflicting", "Conflicts, sorry!"},
+		{"friendly", "review_required", "custom default review_required"},
+		// A built-in tone keeps the files the directory doesn't replace.
+		{"terse-machine", "mergeable_conflicting", "conflict"},
+		{"terse-machine", "review_required", "not merged: review_required"},
+	} {
+		if got := c.body(pr, tt.reason, tt.tone); !strings.HasSuffix(got, "\n"+tt.want) && !strings.Contains(got, "\n"+tt.want+"\n") {
+			t.Errorf("%s %s: body =\n%s\nwant %q", tt.tone, tt.reason, got, tt.want)
+		}
+	}
+	if !c.hasTone("friendly") || defaultCommentTemplates().hasTone("friendly") {
+		t.Error("the custom tone leaked into the embedded set, or is missing")
+	}
+}
+
+func TestCommentToneFor(t *testing.T) {
+	cfg := &pipelineConfig{
+		CommentTone: "terse-machine",
+		Repos:       map[string]repoPolicy{"misty-step/docs": {CommentTone: "verbose-human"}},
+	}
+	if got := cfg.commentToneFor("misty-step/docs"); got != "verbose-human" {
+		t.Errorf("repo tone = %q", got)
+	}
+	if got := cfg.commentToneFor("misty-step/api"); got != "terse-machine" {
+		t.Errorf("top-level tone = %q", got)
+	}
+	if got := (*pipelineConfig)(nil).commentToneFor("misty-step/api"); got != "" {
+		t.Errorf("no config: %q", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"repos": {"misty-step/api": {"commentTone": "pirate"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts := registerRunFlags(fs)
+	if err := fs.Parse([]string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.prepare(); err == nil || !strings.Contains(err.Error(), `"pirate"`) {
+		t.Errorf("prepare with an unknown tone: %v", err)
+	}
+}
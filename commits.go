@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// prCommit is one commit on a PR, from the REST commits API. Author and
+// Committer are the GitHub accounts the commit's emails map to; they're
+// empty when an email isn't linked to any account.
+type prCommit struct {
+	SHA       string
+	Author    string
+	Committer string
+	Verified  bool
+}
+
+// ghPRCommits lists the PR's commits, oldest first.
+func ghPRCommits(ctx context.Context, repo string, number int) ([]prCommit, error) {
+	if strings.TrimSpace(repo) == "" {
+		return nil, errors.New("repo required")
+	}
+	stdout, err := runGh(ctx, "api", "--paginate", fmt.Sprintf("repos/%s/pulls/%d/commits?per_page=100", repo, number))
+	if err != nil {
+		return nil, err
+	}
+	var commits []prCommit
+	dec := json.NewDecoder(bytes.NewReader(stdout))
+	for {
+		var page []struct {
+			SHA    string `json:"sha"`
+			Author *struct {
+				Login string `json:"login"`
+			} `json:"author"`
+			Committer *struct {
+				Login string `json:"login"`
+			} `json:"committer"`
+			Commit struct {
+				Verification struct {
+					Verified bool `json:"verified"`
+				} `json:"verification"`
+			} `json:"commit"`
+		}
+		if err := dec.Decode(&page); err != nil {
+			if errors.Is(err, io.EOF) {
+				return commits, nil
+			}
+			return nil, fmt.Errorf("parse pr commits json: %w", err)
+		}
+		for _, c := range page {
+			pc := prCommit{SHA: c.SHA, Verified: c.Commit.Verification.Verified}
+			if c.Author != nil {
+				pc.Author = c.Author.Login
+			}
+			if c.Committer != nil {
+				pc.Committer = c.Committer.Login
+			}
+			commits = append(commits, pc)
+		}
+	}
+}
+
+// webFlowCommitter is the account GitHub commits as for changes made in
+// its UI or API (merges, "Update branch"), which it signs itself.
+const webFlowCommitter = "web-flow"
+
+// untrustedCommits describes each commit that isn't signed, or whose author
+// or committer is neither the PR author nor in trusted, as
+// "<short sha> (<why>)". An empty result means every commit passes.
+func untrustedCommits(commits []prCommit, prAuthor string, trusted []string) []string {
+	known := func(login string) bool {
+		return login != "" && (strings.EqualFold(login, prAuthor) || hasLogin(trusted, login))
+	}
+	var bad []string
+	for _, c := range commits {
+		var why []string
+		if !c.Verified {
+			why = append(why, "unsigned")
+		}
+		switch {
+		case c.Author == "":
+			why = append(why, "author has no GitHub account")
+		case !known(c.Author):
+			why = append(why, "author "+c.Author)
+		}
+		switch {
+		case c.Committer == "":
+			why = append(why, "committer has no GitHub account")
+		case c.Committer != webFlowCommitter && !known(c.Committer):
+			why = append(why, "committer "+c.Committer)
+		}
+		if len(why) > 0 {
+			bad = append(bad, fmt.Sprintf("%s (%s)", shortSHA(c.SHA), strings.Join(why, ", ")))
+		}
+	}
+	return bad
+}
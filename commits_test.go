@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestGhPRCommits(t *testing.T) {
+	pages := `[{"sha":"aaaaaaa111","author":{"login":"kaylee"},"committer":{"login":"web-flow"},"commit":{"verification":{"verified":true}}}]
+[{"sha":"bbbbbbb222","author":null,"committer":null,"commit":{"verification":{"verified":false}}}]`
+	ctx := withGitHubClient(context.Background(), ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+		return []byte(pages), nil
+	}))
+	commits, err := ghPRCommits(ctx, "misty-step/api", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []prCommit{
+		{SHA: "aaaaaaa111", Author: "kaylee", Committer: "web-flow", Verified: true},
+		{SHA: "bbbbbbb222"},
+	}
+	if !reflect.DeepEqual(commits, want) {
+		t.Errorf("commits = %+v; want %+v", commits, want)
+	}
+}
+
+func TestUntrustedCommits(t *testing.T) {
+	trusted := []string{"dependabot[bot]"}
+	tests := []struct {
+		name   string
+		commit prCommit
+		want   []string
+	}{
+		{"signed by the author", prCommit{SHA: "a1", Author: "Kaylee", Committer: "kaylee", Verified: true}, nil},
+		{"made in the GitHub UI", prCommit{SHA: "a2", Author: "kaylee", Committer: "web-flow", Verified: true}, nil},
+		{"trusted bot", prCommit{SHA: "a3", Author: "dependabot", Committer: "dependabot[bot]", Verified: true}, nil},
+		{"unsigned", prCommit{SHA: "a4", Author: "kaylee", Committer: "kaylee"}, []string{"a4 (unsigned)"}},
+		{"unknown committer", prCommit{SHA: "0123456789", Author: "kaylee", Committer: "mallory", Verified: true}, []string{"0123456 (committer mallory)"}},
+		{"unlinked emails", prCommit{SHA: "a6", Verified: true}, []string{"a6 (author has no GitHub account, committer has no GitHub account)"}},
+		{"web-flow can't author", prCommit{SHA: "a7", Author: "web-flow", Committer: "web-flow", Verified: true}, []string{"a7 (author web-flow)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := untrustedCommits([]prCommit{tt.commit}, "kaylee", trusted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("untrustedCommits = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
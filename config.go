@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// pipelineConfig is the optional JSON config file passed via --config.
+// Flags cover org-wide behavior; the config carries per-repo overrides.
+type pipelineConfig struct {
+	// Repos maps "owner/repo" (or a glob such as "owner/fab-*") to overrides.
+	// An exact key wins over globs; among globs the longest pattern wins.
+	Repos map[string]repoPolicy `json:"repos,omitempty"`
+	// Authors maps a login (or "*" for everyone else) to a behavior profile.
+	// Entries here override the defaults; --authors overrides these.
+	Authors map[string]authorPolicy `json:"authors,omitempty"`
+	// StaleHours maps a login, author group (@bots, @humans), or "*" to
+	// how long its PRs wait untouched before the pipeline acts on them;
+	// 0 acts at once. It replaces the --phaedrus-login/--stale-hours
+	// default, and Authors entries override it.
+	StaleHours map[string]int `json:"staleHours,omitempty"`
+	// DiscordUsers maps a GitHub login to a Discord user ID, so alerts
+	// about that author's PRs can mention or DM them.
+	DiscordUsers map[string]string `json:"discordUsers,omitempty"`
+	// IgnorableReviewers are reviewer logins (typically review bots such as
+	// coderabbitai) whose CHANGES_REQUESTED doesn't block a merge once a
+	// human has approved.
+	IgnorableReviewers []string `json:"ignorableReviewers,omitempty"`
+	// AutoDismissReviewers are reviewer logins whose CHANGES_REQUESTED
+	// review is dismissed once the PR has commits newer than the review.
+	AutoDismissReviewers []string `json:"autoDismissReviewers,omitempty"`
+	// ProtectedPaths are CODEOWNERS-style patterns; a PR touching a
+	// matching file is never merged automatically. Per-repo entries add to
+	// these.
+	ProtectedPaths []string `json:"protectedPaths,omitempty"`
+	// MergeWindow limits when PRs are merged; outside it merges wait for
+	// a later run. Per-repo windows replace it.
+	MergeWindow *mergeWindow `json:"mergeWindow,omitempty"`
+	// CommentTone picks the tone profile for PR comments (default,
+	// terse-machine, verbose-human, emoji-free, or one from
+	// --comment-template-dir). Per-repo tones replace it.
+	CommentTone string `json:"commentTone,omitempty"`
+	// MergeRules names the merge rules to run, in order (see mergeRules);
+	// required rules left out run first, and empty runs them all. Per-repo
+	// lists replace it.
+	MergeRules []string `json:"mergeRules,omitempty"`
+	// MergeConditions are expressions a PR must satisfy to be merged (the
+	// conditions merge rule). Per-repo entries add to these.
+	MergeConditions []mergeCondition `json:"mergeConditions,omitempty"`
+}
+
+// repoPolicy overrides pipeline behavior for a single repo.
+type repoPolicy struct {
+	// Exclude opts the repo out of the pipeline entirely.
+	Exclude bool `json:"exclude,omitempty"`
+	// RequireApproval refuses to merge unless reviewDecision is APPROVED,
+	// even when the repo has no required reviews (empty reviewDecision).
+	RequireApproval bool `json:"requireApproval,omitempty"`
+	// MaxActions caps merges/comments in this repo per run (0 =
+	// --repo-max-actions).
+	MaxActions int `json:"maxActions,omitempty"`
+	// MaxActionsPerHour caps merges/comments in this repo per hour, across
+	// runs (0 = --repo-max-actions-per-hour).
+	MaxActionsPerHour int `json:"maxActionsPerHour,omitempty"`
+	// MergeMethod is MERGE, SQUASH, or REBASE (default MERGE).
+	MergeMethod string `json:"mergeMethod,omitempty"`
+	// Reviewers is the pool --request-reviews picks from (logins or
+	// org/team slugs); empty falls back to --reviewer-pool, then CODEOWNERS.
+	Reviewers []string `json:"reviewers,omitempty"`
+	// ProtectedPaths add to the top-level protectedPaths for this repo.
+	ProtectedPaths []string `json:"protectedPaths,omitempty"`
+	// MergeWindow replaces the top-level mergeWindow for this repo.
+	MergeWindow *mergeWindow `json:"mergeWindow,omitempty"`
+	// CommentTone replaces the top-level commentTone for this repo.
+	CommentTone string `json:"commentTone,omitempty"`
+	// MergeRules replaces the top-level mergeRules for this repo.
+	MergeRules []string `json:"mergeRules,omitempty"`
+	// MergeConditions add to the top-level mergeConditions for this repo.
+	MergeConditions []mergeCondition `json:"mergeConditions,omitempty"`
+}
+
+// loadConfig reads the config file. An empty path yields an empty config.
+func loadConfig(p string) (*pipelineConfig, error) {
+	cfg := &pipelineConfig{}
+	if strings.TrimSpace(p) == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", p, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("config %s: %w", p, err)
+	}
+	return cfg, nil
+}
+
+func (c *pipelineConfig) validate() error {
+	for key, pol := range c.Repos {
+		if _, err := path.Match(key, ""); err != nil {
+			return fmt.Errorf("repos[%q]: bad pattern: %w", key, err)
+		}
+		if pol.MaxActions < 0 {
+			return fmt.Errorf("repos[%q]: maxActions must be >= 0", key)
+		}
+		if pol.MaxActionsPerHour < 0 {
+			return fmt.Errorf("repos[%q]: maxActionsPerHour must be >= 0", key)
+		}
+		switch strings.ToUpper(pol.MergeMethod) {
+		case "", "MERGE", "SQUASH", "REBASE":
+		default:
+			return fmt.Errorf("repos[%q]: unknown mergeMethod %q", key, pol.MergeMethod)
+		}
+		if err := validatePathPatterns(pol.ProtectedPaths); err != nil {
+			return fmt.Errorf("repos[%q]: protectedPaths: %w", key, err)
+		}
+		if pol.MergeWindow != nil {
+			if err := pol.MergeWindow.validate(); err != nil {
+				return fmt.Errorf("repos[%q]: mergeWindow: %w", key, err)
+			}
+		}
+		if err := validateMergeRules(pol.MergeRules); err != nil {
+			return fmt.Errorf("repos[%q]: mergeRules: %w", key, err)
+		}
+		if err := compileMergeConditions(pol.MergeConditions); err != nil {
+			return fmt.Errorf("repos[%q]: mergeConditions%w", key, err)
+		}
+	}
+	if err := validatePathPatterns(c.ProtectedPaths); err != nil {
+		return fmt.Errorf("protectedPaths: %w", err)
+	}
+	if c.MergeWindow != nil {
+		if err := c.MergeWindow.validate(); err != nil {
+			return fmt.Errorf("mergeWindow: %w", err)
+		}
+	}
+	if err := validateMergeRules(c.MergeRules); err != nil {
+		return fmt.Errorf("mergeRules: %w", err)
+	}
+	if err := compileMergeConditions(c.MergeConditions); err != nil {
+		return fmt.Errorf("mergeConditions%w", err)
+	}
+	for login, pol := range c.Authors {
+		if err := validateAuthorKey(login); err != nil {
+			return fmt.Errorf("authors: %w", err)
+		}
+		if err := pol.validate(); err != nil {
+			return fmt.Errorf("authors[%q]: %w", login, err)
+		}
+	}
+	for login, hours := range c.StaleHours {
+		if err := validateAuthorKey(login); err != nil {
+			return fmt.Errorf("staleHours: %w", err)
+		}
+		if strings.TrimSpace(login) == "" {
+			return fmt.Errorf("staleHours: empty login")
+		}
+		if hours < 0 {
+			return fmt.Errorf("staleHours[%q]: must be >= 0", login)
+		}
+	}
+	for login, id := range c.DiscordUsers {
+		if _, err := strconv.ParseUint(strings.TrimSpace(id), 10, 64); err != nil {
+			return fmt.Errorf("discordUsers[%q]: %q is not a Discord user ID", login, id)
+		}
+	}
+	return nil
+}
+
+// validatePathPatterns rejects a malformed pattern up front; one that can
+// never match would quietly let protected files through.
+func validatePathPatterns(patterns []string) error {
+	for _, p := range patterns {
+		if strings.Trim(p, "/") == "" {
+			return fmt.Errorf("empty pattern %q", p)
+		}
+		for _, seg := range strings.Split(p, "/") {
+			if _, err := path.Match(seg, ""); err != nil {
+				return fmt.Errorf("bad pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// protectedPathsFor returns the protected path patterns for repo: the
+// top-level list plus the repo policy's.
+func (c *pipelineConfig) protectedPathsFor(repo string) []string {
+	if c == nil {
+		return nil
+	}
+	return append(append([]string{}, c.ProtectedPaths...), c.repoPolicyFor(repo).ProtectedPaths...)
+}
+
+// discordUserFor returns the Discord user ID mapped to login, or "".
+func (c *pipelineConfig) discordUserFor(login string) string {
+	if c == nil {
+		return ""
+	}
+	login = strings.TrimSpace(login)
+	for l, id := range c.DiscordUsers {
+		if strings.EqualFold(l, login) {
+			return strings.TrimSpace(id)
+		}
+	}
+	return ""
+}
+
+// ignorableReviewer reports whether login is listed in IgnorableReviewers.
+func (c *pipelineConfig) ignorableReviewer(login string) bool {
+	return c != nil && hasLogin(c.IgnorableReviewers, login)
+}
+
+// autoDismissReviewer reports whether login is listed in AutoDismissReviewers.
+func (c *pipelineConfig) autoDismissReviewer(login string) bool {
+	return c != nil && hasLogin(c.AutoDismissReviewers, login)
+}
+
+// hasLogin reports whether login is in logins, ignoring case. A "[bot]"
+// suffix is ignored on either side, since GitHub reports bot logins both
+// with and without it.
+func hasLogin(logins []string, login string) bool {
+	login = strings.TrimSuffix(strings.TrimSpace(login), "[bot]")
+	for _, l := range logins {
+		if strings.EqualFold(strings.TrimSuffix(strings.TrimSpace(l), "[bot]"), login) {
+			return true
+		}
+	}
+	return false
+}
+
+// repoPolicyFor returns the overrides that apply to repo ("owner/name").
+func (c *pipelineConfig) repoPolicyFor(repo string) repoPolicy {
+	if c == nil || len(c.Repos) == 0 {
+		return repoPolicy{}
+	}
+	if pol, ok := c.Repos[repo]; ok {
+		return pol
+	}
+	best := ""
+	for key := range c.Repos {
+		if ok, _ := path.Match(key, repo); !ok {
+			continue
+		}
+		if len(key) > len(best) || (len(key) == len(best) && key < best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return repoPolicy{}
+	}
+	return c.Repos[best]
+}
+
+// commentToneFor returns the comment tone for repo: the repo policy's if
+// it has one, else the top-level one ("" for the default).
+func (c *pipelineConfig) commentToneFor(repo string) string {
+	if c == nil {
+		return ""
+	}
+	if tone := strings.TrimSpace(c.repoPolicyFor(repo).CommentTone); tone != "" {
+		return tone
+	}
+	return strings.TrimSpace(c.CommentTone)
+}
+
+// mergeRulesFor returns the merge rule names for repo: the repo policy's
+// if it has any, else the top-level ones (empty for every rule).
+func (c *pipelineConfig) mergeRulesFor(repo string) []string {
+	if c == nil {
+		return nil
+	}
+	if rules := c.repoPolicyFor(repo).MergeRules; len(rules) > 0 {
+		return rules
+	}
+	return c.MergeRules
+}
+
+// mergeConditionsFor returns the merge conditions for repo: the top-level
+// list plus the repo policy's.
+func (c *pipelineConfig) mergeConditionsFor(repo string) []mergeCondition {
+	if c == nil {
+		return nil
+	}
+	return append(append([]mergeCondition{}, c.MergeConditions...), c.repoPolicyFor(repo).MergeConditions...)
+}
+
+// commentTones returns every tone the config names, for checking against
+// the loaded templates.
+func (c *pipelineConfig) commentTones() []string {
+	if c == nil {
+		return nil
+	}
+	tones := []string{strings.TrimSpace(c.CommentTone)}
+	for _, pol := range c.Repos {
+		tones = append(tones, strings.TrimSpace(pol.CommentTone))
+	}
+	return tones
+}
+
+// mergeMethod returns the GraphQL PullRequestMergeMethod for this policy.
+func (p repoPolicy) mergeMethod() string {
+	if m := strings.ToUpper(strings.TrimSpace(p.MergeMethod)); m != "" {
+		return m
+	}
+	return "MERGE"
+}
+
+// matchRepoPattern matches repo ("owner/name") against a glob. Patterns
+// without a slash match the repo name alone, so "fab-*" works across owners.
+func matchRepoPattern(pattern string, repo string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return false
+	}
+	target := repo
+	if !strings.Contains(pattern, "/") {
+		if _, name, ok := strings.Cut(repo, "/"); ok {
+			target = name
+		}
+	}
+	ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(target))
+	return ok
+}
+
+// repoInScope applies --only-repos and --skip-repos. An empty only list
+// admits every repo; skip always wins over only.
+func repoInScope(repo string, only []string, skip []string) bool {
+	for _, p := range skip {
+		if matchRepoPattern(p, repo) {
+			return false
+		}
+	}
+	if len(only) == 0 {
+		return true
+	}
+	for _, p := range only {
+		if matchRepoPattern(p, repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRepoPatterns rejects malformed globs up front rather than having
+// them silently match nothing.
+func validateRepoPatterns(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("bad repo pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("empty path yields empty config", func(t *testing.T) {
+		cfg, err := loadConfig("")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cfg.Repos) != 0 {
+			t.Errorf("expected no repo policies, got %v", cfg.Repos)
+		}
+	})
+
+	t.Run("parses repo policies", func(t *testing.T) {
+		p := filepath.Join(t.TempDir(), "config.json")
+		raw := `{"repos":{"org/a":{"exclude":true},"org/fab-*":{"maxActions":2,"mergeMethod":"squash"}}}`
+		if err := os.WriteFile(p, []byte(raw), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := loadConfig(p)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !cfg.repoPolicyFor("org/a").Exclude {
+			t.Error("expected org/a to be excluded")
+		}
+		if got := cfg.repoPolicyFor("org/fab-x").mergeMethod(); got != "SQUASH" {
+			t.Errorf("expected SQUASH, got %q", got)
+		}
+	})
+
+	t.Run("rejects unknown merge method", func(t *testing.T) {
+		p := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(p, []byte(`{"repos":{"org/a":{"mergeMethod":"octopus"}}}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig(p); err == nil {
+			t.Error("expected error for unknown merge method")
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := loadConfig("/nonexistent/config.json"); err == nil {
+			t.Error("expected error for missing file")
+		}
+	})
+}
+
+func TestRepoPolicyFor(t *testing.T) {
+	cfg := &pipelineConfig{Repos: map[string]repoPolicy{
+		"org/*":       {MaxActions: 5},
+		"org/fab-*":   {MaxActions: 2},
+		"org/fab-cli": {MaxActions: 1},
+	}}
+	tests := []struct {
+		repo string
+		want int
+	}{
+		{"org/fab-cli", 1},
+		{"org/fab-pipeline", 2},
+		{"org/other", 5},
+		{"elsewhere/repo", 0},
+	}
+	for _, tt := range tests {
+		if got := cfg.repoPolicyFor(tt.repo).MaxActions; got != tt.want {
+			t.Errorf("repoPolicyFor(%q).MaxActions = %d; want %d", tt.repo, got, tt.want)
+		}
+	}
+
+	var nilCfg *pipelineConfig
+	if got := nilCfg.repoPolicyFor("org/a"); !reflect.DeepEqual(got, repoPolicy{}) {
+		t.Errorf("nil config should yield zero policy, got %+v", got)
+	}
+}
+
+func TestMergeAllowed_requireApproval(t *testing.T) {
+	pr := &prView{
+		Mergeable: "MERGEABLE",
+		StatusCheckRollup: []statusRollupEntry{
+			{Typename: "CheckRun", Status: "COMPLETED", Conclusion: "SUCCESS"},
+		},
+	}
+	if ok, _ := mergeAllowed(pr, repoPolicy{}); !ok {
+		t.Error("empty reviewDecision should merge by default")
+	}
+	ok, reason := mergeAllowed(pr, repoPolicy{RequireApproval: true})
+	if ok || reason != "review_required" {
+		t.Errorf("requireApproval should block with review_required, got ok=%v reason=%q", ok, reason)
+	}
+	pr.ReviewDecision = "APPROVED"
+	if ok, _ := mergeAllowed(pr, repoPolicy{RequireApproval: true}); !ok {
+		t.Error("APPROVED should satisfy requireApproval")
+	}
+}
+
+func TestEffectiveReviewDecision(t *testing.T) {
+	cfg := &pipelineConfig{IgnorableReviewers: []string{"coderabbitai[bot]", "Copilot-Pull-Request-Reviewer"}}
+	review := func(login, state string) prReview {
+		var r prReview
+		r.Author.Login = login
+		r.State = state
+		return r
+	}
+	tests := []struct {
+		name     string
+		decision string
+		reviews  []prReview
+		cfg      *pipelineConfig
+		want     string
+	}{
+		{"bot change request with human approval", "CHANGES_REQUESTED",
+			[]prReview{review("coderabbitai", "CHANGES_REQUESTED"), review("phaedrus", "APPROVED")}, cfg, "APPROVED"},
+		{"bot login case and suffix", "CHANGES_REQUESTED",
+			[]prReview{review("copilot-pull-request-reviewer[bot]", "CHANGES_REQUESTED"), review("phaedrus", "APPROVED")}, cfg, "APPROVED"},
+		{"no human approval", "CHANGES_REQUESTED",
+			[]prReview{review("coderabbitai", "CHANGES_REQUESTED"), review("phaedrus", "COMMENTED")}, cfg, "CHANGES_REQUESTED"},
+		{"human change request still blocks", "CHANGES_REQUESTED",
+			[]prReview{review("coderabbitai", "CHANGES_REQUESTED"), review("phaedrus", "APPROVED"), review("kaylee", "CHANGES_REQUESTED")}, cfg, "CHANGES_REQUESTED"},
+		{"bot approval doesn't count", "CHANGES_REQUESTED",
+			[]prReview{review("coderabbitai", "CHANGES_REQUESTED"), review("copilot-pull-request-reviewer", "APPROVED")}, cfg, "CHANGES_REQUESTED"},
+		{"no ignorable reviewers configured", "CHANGES_REQUESTED",
+			[]prReview{review("coderabbitai", "CHANGES_REQUESTED"), review("phaedrus", "APPROVED")}, nil, "CHANGES_REQUESTED"},
+		{"other decisions pass through", "REVIEW_REQUIRED",
+			[]prReview{review("coderabbitai", "CHANGES_REQUESTED")}, cfg, "REVIEW_REQUIRED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &prView{ReviewDecision: tt.decision, LatestReviews: tt.reviews}
+			if got := effectiveReviewDecision(pr, tt.cfg); got != tt.want {
+				t.Errorf("effectiveReviewDecision = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaleChangeRequests(t *testing.T) {
+	cfg := &pipelineConfig{AutoDismissReviewers: []string{"phaedrus"}, IgnorableReviewers: []string{"coderabbitai"}}
+	review := func(id, login, state, oid string) prReview {
+		r := prReview{ID: id, State: state}
+		r.Author.Login = login
+		r.Commit.Oid = oid
+		return r
+	}
+	tests := []struct {
+		name    string
+		reviews []prReview
+		want    []string
+	}{
+		{"stale review by listed reviewer", []prReview{review("R1", "phaedrus", "CHANGES_REQUESTED", "old")}, []string{"R1"}},
+		{"review on the current head", []prReview{review("R1", "phaedrus", "CHANGES_REQUESTED", "head")}, nil},
+		{"unlisted reviewer", []prReview{review("R1", "kaylee", "CHANGES_REQUESTED", "old")}, nil},
+		{"unlisted reviewer still blocks", []prReview{
+			review("R1", "phaedrus", "CHANGES_REQUESTED", "old"),
+			review("R2", "kaylee", "CHANGES_REQUESTED", "old"),
+		}, nil},
+		{"ignorable reviewer doesn't block", []prReview{
+			review("R1", "phaedrus", "CHANGES_REQUESTED", "old"),
+			review("R2", "coderabbitai", "CHANGES_REQUESTED", "head"),
+			review("R3", "kaylee", "APPROVED", "old"),
+		}, []string{"R1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &prView{ReviewDecision: "CHANGES_REQUESTED", HeadRefOid: "head", LatestReviews: tt.reviews}
+			var got []string
+			for _, r := range staleChangeRequests(pr, cfg) {
+				got = append(got, r.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("staleChangeRequests = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountRepoActions(t *testing.T) {
+	results := []prOutcome{
+		{Repo: "org/a", Action: "merged"},
+		{Repo: "org/a", Action: "skipped"},
+		{Repo: "org/a", Action: "commented"},
+		{Repo: "org/a", Action: "error"},
+		{Repo: "org/b", Action: "merged"},
+	}
+	if got := countRepoActions(results, "org/a"); got != 2 {
+		t.Errorf("expected 2 actions for org/a, got %d", got)
+	}
+}
+
+func TestRepoInScope(t *testing.T) {
+	tests := []struct {
+		name string
+		repo string
+		only []string
+		skip []string
+		want bool
+	}{
+		{"no filters", "misty-step/app", nil, nil, true},
+		{"only exact", "misty-step/app", []string{"misty-step/app"}, nil, true},
+		{"only glob miss", "misty-step/app", []string{"misty-step/fab-*"}, nil, false},
+		{"only glob hit", "misty-step/fab-cli", []string{"misty-step/fab-*"}, nil, true},
+		{"bare name pattern", "other-org/fab-cli", []string{"fab-*"}, nil, true},
+		{"skip wins over only", "misty-step/fab-cli", []string{"misty-step/*"}, []string{"misty-step/fab-cli"}, false},
+		{"skip glob", "misty-step/fab-cli", nil, []string{"fab-*"}, false},
+		{"case insensitive", "Misty-Step/App", []string{"misty-step/app"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := repoInScope(tt.repo, tt.only, tt.skip); got != tt.want {
+				t.Errorf("repoInScope(%q) = %v; want %v", tt.repo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRepoPatterns(t *testing.T) {
+	if err := validateRepoPatterns([]string{"org/*", "fab-?"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateRepoPatterns([]string{"org/[abc"}); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+}
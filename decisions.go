@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// decisionMaxAge is how long a decision stands with --skip-unchanged; each
+// PR is looked at in full at least this often, to catch what the search
+// can't show (a base branch moving, a changed config).
+const decisionMaxAge = 24 * time.Hour
+
+// settledBlockers are the blockers that only a change to the PR itself can
+// clear: a push, a review, a label, or its checks. Blockers that also turn
+// on the base branch (conflicts, branch_behind) or the clock aren't
+// settled.
+var settledBlockers = []reasonCode{
+	reasonChecksFailure, reasonChecksPending,
+	reasonReviewRequired, reasonReviewChangesRequested, reasonReviewThreadsUnresolved,
+	reasonPRTooLarge, reasonProtectedPaths, reasonUntrustedCommits, reasonPossibleSecret,
+	reasonHoldLabel, reasonAuthorCommentOnly, reasonForkCommentOnly, reasonDependencyMajor, reasonDependencyUnknown,
+}
+
+// prDecision is the blocker a run found on a PR, and what the PR looked
+// like in the search at the time.
+type prDecision struct {
+	HeadSHA   string    `json:"headSha"`
+	Checks    string    `json:"checks"`
+	Reason    string    `json:"reason"`
+	DecidedAt time.Time `json:"decidedAt"`
+}
+
+// decisionLog is the last settled decision per PR URL.
+type decisionLog map[string]prDecision
+
+// decisionsPath returns where the log is kept, beside the dedup state file.
+func decisionsPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "decisions.json")
+}
+
+// loadDecisions reads the log at path, dropping decisions older than
+// decisionMaxAge. A missing or unreadable file is an empty log.
+func loadDecisions(path string, now time.Time) decisionLog {
+	log := decisionLog{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return log
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		fmt.Fprintf(os.Stderr, "[skip-unchanged] ignoring unreadable %s: %v\n", path, err)
+		return decisionLog{}
+	}
+	for url, d := range log {
+		if now.Sub(d.DecidedAt) > decisionMaxAge {
+			delete(log, url)
+		}
+	}
+	return log
+}
+
+// unchanged returns the blocker decided for pr last time if nothing about
+// it has changed since: the same head commit and checks state, and no
+// update (a review, a label, a comment) after the decision.
+func (l decisionLog) unchanged(pr searchPR) (string, bool) {
+	d, ok := l[pr.URL]
+	if !ok || pr.HeadRefOid == "" || pr.UpdatedAt.IsZero() {
+		return "", false
+	}
+	if d.HeadSHA != pr.HeadRefOid || d.Checks != pr.ChecksState || pr.UpdatedAt.After(d.DecidedAt) {
+		return "", false
+	}
+	return d.Reason, true
+}
+
+// record keeps the outcome's blocker for pr if it's settled, and forgets
+// the PR otherwise. A PR skipped as unchanged keeps its decision, and its
+// age.
+func (l decisionLog) record(pr searchPR, o prOutcome, now time.Time) {
+	if o.ReasonCode == reasonUnchangedSinceLastRun {
+		return
+	}
+	reason, ok := blockerReason(o)
+	if !ok {
+		return
+	}
+	switch o.Action {
+	case "commented", "skipped", "lint_dispatched", "test_dispatched", "review_dispatched":
+	default:
+		reason = "" // acted on, or errored: look again next run
+	}
+	if reason == "" || pr.HeadRefOid == "" || !slices.Contains(settledBlockers, reasonCode(reason)) {
+		delete(l, pr.URL)
+		return
+	}
+	l[pr.URL] = prDecision{HeadSHA: pr.HeadRefOid, Checks: pr.ChecksState, Reason: reason, DecidedAt: now.UTC()}
+}
+
+// saveDecisions writes the log to path, logging failures.
+func saveDecisions(path string, l decisionLog) {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[skip-unchanged] failed to save %s: %v\n", path, err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecisionLog(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	pr := searchPR{URL: "u1", UpdatedAt: now.Add(-time.Hour), HeadRefOid: "sha1", ChecksState: "SUCCESS"}
+	l := decisionLog{}
+	// record fills in the reason code from the Reason the cases are written with.
+	record := func(pr searchPR, o prOutcome, now time.Time) {
+		l.record(pr, o.withReasonCode(), now)
+	}
+	record(pr, prOutcome{Action: "commented", Reason: "review_required"}, now)
+
+	if reason, ok := l.unchanged(pr); !ok || reason != "review_required" {
+		t.Errorf("unchanged = %q, %v; want review_required", reason, ok)
+	}
+	for name, changed := range map[string]searchPR{
+		"new commit":   {URL: "u1", UpdatedAt: pr.UpdatedAt, HeadRefOid: "sha2", ChecksState: "SUCCESS"},
+		"checks moved": {URL: "u1", UpdatedAt: pr.UpdatedAt, HeadRefOid: "sha1", ChecksState: "FAILURE"},
+		"updated":      {URL: "u1", UpdatedAt: now.Add(time.Minute), HeadRefOid: "sha1", ChecksState: "SUCCESS"},
+		"no head SHA":  {URL: "u1", UpdatedAt: pr.UpdatedAt, ChecksState: "SUCCESS"},
+		"other PR":     {URL: "u2", UpdatedAt: pr.UpdatedAt, HeadRefOid: "sha1", ChecksState: "SUCCESS"},
+	} {
+		if _, ok := l.unchanged(changed); ok {
+			t.Errorf("%s: counted as unchanged", name)
+		}
+	}
+
+	// Skips that say nothing about the PR leave the decision alone.
+	record(pr, prOutcome{Action: "skipped", Reason: "circuit_breaker"}, now)
+	record(pr, prOutcome{Action: "skipped", Reason: "unchanged_since_last_run", ReasonDetail: "review_required"}, now.Add(time.Hour))
+	if d := l[pr.URL]; d.Reason != "review_required" || !d.DecidedAt.Equal(now) {
+		t.Errorf("decision = %+v; want the original one", d)
+	}
+	record(pr, prOutcome{Action: "skipped", Reason: "checks_failure_already_commented"}, now)
+	if d := l[pr.URL]; d.Reason != "checks_failure" {
+		t.Errorf("already commented: decision = %+v", d)
+	}
+
+	// Progress, errors, and blockers that turn on more than the PR end it.
+	for _, o := range []prOutcome{
+		{Action: "merged"},
+		{Action: "error", Reason: "pr view failed (permanent): boom"},
+		{Action: "commented", Reason: "mergeable_conflicting"},
+		{Action: "branch_updated", Reason: "branch_behind"},
+	} {
+		record(pr, prOutcome{Action: "commented", Reason: "review_required"}, now)
+		record(pr, o, now)
+		if _, ok := l[pr.URL]; ok {
+			t.Errorf("%s/%s: decision kept", o.Action, o.Reason)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "decisions.json")
+	record(pr, prOutcome{Action: "commented", Reason: "review_required"}, now)
+	saveDecisions(path, l)
+	if got := loadDecisions(path, now.Add(time.Hour)); got[pr.URL].Reason != "review_required" {
+		t.Errorf("loaded = %+v", got)
+	}
+	if got := loadDecisions(path, now.Add(decisionMaxAge+time.Minute)); len(got) != 0 {
+		t.Errorf("a decision older than decisionMaxAge was kept: %+v", got)
+	}
+}
+
+func TestPipelineSkipsUnchangedPRs(t *testing.T) {
+	unreviewed := fakePR("misty-step/api", 1)
+	unreviewed.ReviewDecision = "REVIEW_REQUIRED"
+	fake := newFakeGitHub(unreviewed)
+	fake.updatedAt[unreviewed.URL] = time.Now().Add(-time.Hour)
+	useFakeGitHub(t, fake)
+	opts := testPipelineOptions(t, "-skip-unchanged")
+
+	run := func() prOutcome {
+		t.Helper()
+		fake.calls = nil
+		out, err := newPipeline(opts).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Results) != 1 {
+			t.Fatalf("results = %+v", out.Results)
+		}
+		return out.Results[0]
+	}
+	viewed := func() bool {
+		for _, c := range fake.calls {
+			if strings.HasPrefix(c, "pr view") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if r := run(); r.Action != "commented" || r.Reason != "review_required" {
+		t.Fatalf("first run: %s/%s", r.Action, r.Reason)
+	}
+	r := run()
+	if r.Action != "skipped" || r.ReasonCode != reasonUnchangedSinceLastRun || r.ReasonDetail != "review_required" {
+		t.Errorf("second run: %s/%s (%s)", r.Action, r.Reason, r.ReasonDetail)
+	}
+	if viewed() {
+		t.Errorf("the unchanged PR was viewed: %q", fake.calls)
+	}
+
+	// A push changes the head SHA, and the PR gets looked at again.
+	unreviewed.HeadRefOid = "def456"
+	if r := run(); r.ReasonCode != reasonReviewRequired || !viewed() {
+		t.Errorf("after a push: %s/%s, viewed %v", r.Action, r.Reason, viewed())
+	}
+}
+
+func TestPipelineSkipsUnchangedTargetedPRs(t *testing.T) {
+	unreviewed := fakePR("misty-step/api", 1)
+	unreviewed.ReviewDecision = "REVIEW_REQUIRED"
+	fake := newFakeGitHub(unreviewed)
+	fake.updatedAt[unreviewed.URL] = time.Now().Add(-time.Hour)
+	useFakeGitHub(t, fake)
+	opts := testPipelineOptions(t, "-skip-unchanged")
+	opts.targets = []webhookTarget{{Repo: "misty-step/api", Number: 1, RepoURL: "https://github.com/misty-step/api"}}
+
+	var got []string
+	for range 2 {
+		out, err := newPipeline(opts).Run(context.Background())
+		if err != nil || len(out.Results) != 1 {
+			t.Fatalf("Run() = %+v, %v", out.Results, err)
+		}
+		got = append(got, out.Results[0].Action+"/"+string(out.Results[0].ReasonCode))
+	}
+	if want := []string{"commented/review_required", "skipped/unchanged_since_last_run"}; !slices.Equal(got, want) {
+		t.Errorf("runs = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultDenyFor is how long a PR skipped from Discord stays skipped.
+const defaultDenyFor = 24 * time.Hour
+
+// denylist maps PR URLs to when the pipeline may touch them again.
+type denylist map[string]time.Time
+
+// denylistPath returns where the denylist lives, beside the dedup state file.
+func denylistPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "denylist.json")
+}
+
+// loadDenylist reads the denylist, dropping entries that expired by now.
+// A missing or unreadable file is an empty denylist.
+func loadDenylist(path string, now time.Time) denylist {
+	d := denylist{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return d
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		fmt.Fprintf(os.Stderr, "[denylist] ignoring unreadable %s: %v\n", path, err)
+		return denylist{}
+	}
+	for url, until := range d {
+		if !until.After(now) {
+			delete(d, url)
+		}
+	}
+	return d
+}
+
+// has reports whether url is denylisted at now.
+func (d denylist) has(url string, now time.Time) bool {
+	until, ok := d[url]
+	return ok && until.After(now)
+}
+
+// denyPR adds url to the denylist at path until now+dur and saves it.
+func denyPR(path string, url string, dur time.Duration, now time.Time) (time.Time, error) {
+	ref, ok := parsePRURL(url, githubHosts())
+	if !ok {
+		return time.Time{}, fmt.Errorf("%q is not a GitHub pull request URL", url)
+	}
+	url = ref.url()
+	if dur <= 0 {
+		return time.Time{}, errors.New("skip duration must be positive")
+	}
+	d := loadDenylist(path, now)
+	until := now.Add(dur).UTC()
+	d[url] = until
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return time.Time{}, err
+	}
+	return until, os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Update kinds parsed from a dependency bot's PR title.
+const (
+	dependencyPatch   = "patch"
+	dependencyMinor   = "minor"
+	dependencyMajor   = "major"
+	dependencyUnknown = "unknown"
+)
+
+var (
+	// dependabot: "Bump lodash from 4.17.20 to 4.17.21".
+	depFromToRe = regexp.MustCompile(`(?i)\bfrom v?(\d+(?:\.\d+)*)\S* to v?(\d+(?:\.\d+)*)`)
+	// renovate: "Update dependency foo to v1.2.4", "... to v2 (major)".
+	depToRe     = regexp.MustCompile(`(?i)\bto v?(\d+(?:\.\d+)*)`)
+	depMarkerRe = regexp.MustCompile(`(?i)\((major|minor|patch)\)\s*$`)
+)
+
+// dependencyUpdateKind classifies a Dependabot or Renovate PR title as a
+// patch, minor, or major update. A "from X to Y" title compares the two
+// versions. Renovate leaves out the old version: its "(major)" suffix wins,
+// and a bare major target ("to v2") is how it titles major updates. Titles
+// it can't read (grouped updates, say) are unknown.
+func dependencyUpdateKind(title string) string {
+	if m := depMarkerRe.FindStringSubmatch(title); m != nil {
+		return strings.ToLower(m[1])
+	}
+	if m := depFromToRe.FindStringSubmatch(title); m != nil {
+		from, to := strings.Split(m[1], "."), strings.Split(m[2], ".")
+		switch {
+		case from[0] != to[0]:
+			return dependencyMajor
+		case from[0] == "0" && len(from) > 1 && len(to) > 1 && from[1] != to[1]:
+			// Semver: below 1.0 a minor bump may break the API.
+			return dependencyMajor
+		case len(from) > 1 && len(to) > 1 && from[1] != to[1]:
+			return dependencyMinor
+		default:
+			return dependencyPatch
+		}
+	}
+	if m := depToRe.FindStringSubmatch(title); m != nil {
+		if !strings.Contains(m[1], ".") {
+			return dependencyMajor
+		}
+		return dependencyMinor
+	}
+	return dependencyUnknown
+}
+
+// dependencyAlertsPath returns where the alerted major bumps are kept,
+// beside the dedup state file.
+func dependencyAlertsPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "dependency-alerts.json")
+}
+
+// alertDependencyBumps sends one alert for the run's dependency updates that
+// were held for a human (major or unknown), leaving out PRs already alerted
+// at the same head commit. The alerted PRs are saved at path.
+func alertDependencyBumps(ctx context.Context, n Notifier, path string, results []prOutcome) {
+	alerted := map[string]string{} // PR URL -> head SHA
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &alerted); err != nil {
+			fmt.Fprintf(os.Stderr, "[dependency] ignoring unreadable %s: %v\n", path, err)
+			alerted = map[string]string{}
+		}
+	}
+	var lines []string
+	for _, r := range results {
+		if r.ReasonCode != reasonDependencyMajor && r.ReasonCode != reasonDependencyUnknown {
+			continue
+		}
+		if alerted[r.URL] == r.HeadSHA {
+			continue
+		}
+		alerted[r.URL] = r.HeadSHA
+		lines = append(lines, fmt.Sprintf("- %s#%d (%s): %s", r.Repo, r.Number, r.DependencyUpdate, r.URL))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	notifyAlert(ctx, n, fmt.Sprintf("📦 Dependency updates held for a human merge (%d):\n%s", len(lines), strings.Join(lines, "\n")))
+	data, err := json.MarshalIndent(alerted, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[dependency] failed to save %s: %v\n", path, err)
+	}
+}
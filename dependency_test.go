@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDependencyUpdateKind(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Bump lodash from 4.17.20 to 4.17.21", dependencyPatch},
+		{"Bump express from 4.17.1 to 4.18.0 in /api", dependencyMinor},
+		{"chore(deps): bump golang.org/x/net from 0.17.0 to 0.23.0", dependencyMajor},
+		{"build(deps): bump actions/checkout from 3 to 4", dependencyMajor},
+		{"Bump rails from v7.0.8 to v7.0.8.1", dependencyPatch},
+		{"Bump the npm_and_yarn group with 3 updates", dependencyUnknown},
+		{"Update dependency eslint to v8.57.0", dependencyMinor},
+		{"Update dependency react to v19", dependencyMajor},
+		{"chore(deps): update module github.com/stretchr/testify to v1.9.0 (patch)", dependencyPatch},
+		{"Update typescript-eslint monorepo to v8.0.0 (major)", dependencyMajor},
+		{"Fix things", dependencyUnknown},
+	}
+	for _, tt := range tests {
+		if got := dependencyUpdateKind(tt.title); got != tt.want {
+			t.Errorf("dependencyUpdateKind(%q) = %q; want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestAlertDependencyBumps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dependency-alerts.json")
+	results := []prOutcome{
+		{URL: "https://github.com/o/a/pull/1", Repo: "o/a", Number: 1, HeadSHA: "s1", ReasonCode: reasonDependencyMajor, DependencyUpdate: dependencyMajor},
+		{URL: "https://github.com/o/a/pull/2", Repo: "o/a", Number: 2, HeadSHA: "s2", ReasonCode: reasonDependencyUnknown, DependencyUpdate: dependencyUnknown},
+		{URL: "https://github.com/o/a/pull/3", Repo: "o/a", Number: 3, HeadSHA: "s3", Action: "merged", DependencyUpdate: dependencyPatch},
+	}
+	n := &fakeNotifier{}
+	alertDependencyBumps(context.Background(), n, path, results)
+	if len(n.alerts) != 1 || !strings.Contains(n.alerts[0], "(2)") || !strings.Contains(n.alerts[0], "o/a#1 (major)") || strings.Contains(n.alerts[0], "#3") {
+		t.Fatalf("alerts = %q", n.alerts)
+	}
+
+	// Same PRs at the same heads: nothing new to say.
+	alertDependencyBumps(context.Background(), n, path, results)
+	if len(n.alerts) != 1 {
+		t.Fatalf("repeat run alerted again: %q", n.alerts)
+	}
+
+	// A new push to a held PR is worth another look.
+	results[0].HeadSHA = "s1b"
+	alertDependencyBumps(context.Background(), n, path, results)
+	if len(n.alerts) != 2 || !strings.Contains(n.alerts[1], "(1)") || !strings.Contains(n.alerts[1], "o/a#1") {
+		t.Errorf("alerts = %q", n.alerts)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// digestPRsQuery is searchPRsQuery plus what the digest reports on.
+const digestPRsQuery = `query($q: String!, $first: Int!, $after: String) {
+  search(query: $q, type: ISSUE, first: $first, after: $after) {
+    issueCount
+    pageInfo { endCursor hasNextPage }
+    nodes {
+      ... on PullRequest {
+        url title body updatedAt isDraft number
+        createdAt mergeable reviewDecision
+        author { login }
+        repository { nameWithOwner }
+        labels(first: 50) { nodes { name } }
+        latestReviews(first: 20) { nodes { state submittedAt author { login } } }
+      }
+    }
+  }
+}`
+
+// digestOutput is the JSON printed by the digest subcommand.
+type digestOutput struct {
+	Ok               bool         `json:"ok"`
+	Error            string       `json:"error,omitempty"`
+	StartedAt        string       `json:"startedAt"`
+	Org              string       `json:"org"`
+	Open             int          `json:"open"`
+	Repos            []digestRepo `json:"repos"`
+	Oldest           []digestPR   `json:"oldest"`
+	ChangesRequested []digestPR   `json:"changesRequested"`
+	Conflicting      []digestPR   `json:"conflicting"`
+	Discord          *discordOut  `json:"discord,omitempty"`
+}
+
+type digestRepo struct {
+	Repo string `json:"repo"`
+	Open int    `json:"open"`
+}
+
+// digestPR is a PR listed in the digest. Days is how long it has been open,
+// or for ChangesRequested how long ago the changes were requested.
+type digestPR struct {
+	URL    string `json:"url"`
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	Days   int    `json:"days"`
+	Draft  bool   `json:"draft,omitempty"`
+}
+
+// runDigestCommand implements `fab-pr-pipeline digest`: summarize the org's
+// open PRs for humans and post the report to Discord, acting on none of
+// them.
+func runDigestCommand(args []string) int {
+	opts, code := parseRunFlags("digest", args)
+	if opts == nil {
+		return code
+	}
+	if opts.digestOldest < 0 || opts.digestStuckDays < 0 {
+		emitJSON(map[string]any{"ok": false, "error": "--oldest and --changes-requested-days must not be negative"})
+		return 2
+	}
+	ctx, cancel := opts.runContext()
+	defer cancel()
+	now := time.Now()
+	out, err := buildDigest(ctx, opts, now)
+	if err != nil {
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return 1
+	}
+	if reportTo := normalizeDiscordTarget(opts.DiscordReportTo); reportTo != "" && !opts.DryRun {
+		out.Discord = &discordOut{ReportTo: reportTo}
+		token := strings.TrimSpace(discordBotToken())
+		err := errors.New("DISCORD_BOT_TOKEN missing (needed for Discord posting)")
+		if token != "" {
+			err = discordSendEmbeds(ctx, token, reportTo, renderDigestEmbeds(out, opts.digestStuckDays))
+		}
+		if err != nil {
+			out.Ok = false
+			out.Error = err.Error()
+			out.Discord.Error = err.Error()
+		} else {
+			out.Discord.Posted = true
+		}
+	}
+	emitJSON(out)
+	if !out.Ok {
+		return 1
+	}
+	return 0
+}
+
+// buildDigest scans every org's open PRs in scope (--only-repos,
+// --skip-repos, and excluded repos apply; the merge selection policy
+// doesn't) and groups them for the digest. An org whose scan fails is left
+// out unless every org fails.
+func buildDigest(ctx context.Context, opts *runOptions, now time.Time) (digestOutput, error) {
+	out := digestOutput{
+		Ok:               true,
+		StartedAt:        now.UTC().Format(time.RFC3339),
+		Org:              opts.Org,
+		Repos:            []digestRepo{},
+		Oldest:           []digestPR{},
+		ChangesRequested: []digestPR{},
+		Conflicting:      []digestPR{},
+	}
+	var prs []searchPR
+	var scanErr error
+	for _, org := range opts.orgs {
+		res, err := searchOpenPRs(ctx, digestPRsQuery, org, opts.ScanLimit)
+		if err != nil {
+			scanErr = fmt.Errorf("scan of %s failed: %w", org, err)
+			fmt.Fprintf(os.Stderr, "[digest] %v\n", scanErr)
+			continue
+		}
+		if res.Truncated() {
+			fmt.Fprintf(os.Stderr, "[digest] scanned %d of %d open PRs in %s (raise --scan-limit to see the rest)\n", len(res.PRs), res.Total, org)
+		}
+		prs = append(prs, res.PRs...)
+	}
+	if scanErr != nil && prs == nil {
+		return out, scanErr
+	}
+	prs = slices.DeleteFunc(prs, func(pr searchPR) bool {
+		repo := pr.Repository.NameWithOwner
+		return !repoInScope(repo, opts.onlyRepos, opts.skipRepos) || opts.config.repoPolicyFor(repo).Exclude
+	})
+
+	open := map[string]int{}
+	for _, pr := range prs {
+		open[pr.Repository.NameWithOwner]++
+	}
+	for repo, n := range open {
+		out.Repos = append(out.Repos, digestRepo{Repo: repo, Open: n})
+	}
+	slices.SortFunc(out.Repos, func(a, b digestRepo) int {
+		return cmp.Or(b.Open-a.Open, strings.Compare(a.Repo, b.Repo))
+	})
+	out.Open = len(prs)
+
+	// Oldest first throughout.
+	slices.SortStableFunc(prs, func(a, b searchPR) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	for _, pr := range prs {
+		if len(out.Oldest) < opts.digestOldest {
+			out.Oldest = append(out.Oldest, newDigestPR(pr, now.Sub(pr.CreatedAt)))
+		}
+		if strings.EqualFold(pr.Mergeable, "CONFLICTING") {
+			out.Conflicting = append(out.Conflicting, newDigestPR(pr, now.Sub(pr.CreatedAt)))
+		}
+		if strings.EqualFold(pr.ReviewDecision, "CHANGES_REQUESTED") {
+			waited := now.Sub(changesRequestedAt(pr))
+			if waited > time.Duration(opts.digestStuckDays)*24*time.Hour {
+				out.ChangesRequested = append(out.ChangesRequested, newDigestPR(pr, waited))
+			}
+		}
+	}
+	slices.SortStableFunc(out.ChangesRequested, func(a, b digestPR) int { return b.Days - a.Days })
+	return out, nil
+}
+
+// changesRequestedAt returns when the PR's latest change request was
+// submitted, or when the PR was opened if no review says.
+func changesRequestedAt(pr searchPR) time.Time {
+	var at time.Time
+	for _, r := range pr.LatestReviews {
+		if strings.EqualFold(r.State, "CHANGES_REQUESTED") && r.SubmittedAt.After(at) {
+			at = r.SubmittedAt
+		}
+	}
+	if at.IsZero() {
+		return pr.CreatedAt
+	}
+	return at
+}
+
+func newDigestPR(pr searchPR, age time.Duration) digestPR {
+	return digestPR{
+		URL:    pr.URL,
+		Repo:   pr.Repository.NameWithOwner,
+		Number: pr.Number,
+		Title:  pr.Title,
+		Author: pr.Author.Login,
+		Days:   int(age.Hours() / 24),
+		Draft:  pr.IsDraft,
+	}
+}
+
+// renderDigestEmbeds renders the digest: totals, open PRs per repo, then
+// the oldest, stuck, and conflicting PRs as links.
+func renderDigestEmbeds(out digestOutput, stuckDays int) []discordEmbed {
+	head := discordEmbed{
+		Title:       "Open PR digest",
+		Description: fmt.Sprintf("org: `%s` | %d open PRs across %d repos", out.Org, out.Open, len(out.Repos)),
+		Color:       discordColorBlue,
+		Timestamp:   out.StartedAt,
+		Fields: []discordEmbedField{
+			{Name: "Open", Value: fmt.Sprint(out.Open), Inline: true},
+			{Name: "Changes requested", Value: fmt.Sprint(len(out.ChangesRequested)), Inline: true},
+			{Name: "Conflicting", Value: fmt.Sprint(len(out.Conflicting)), Inline: true},
+		},
+	}
+	if out.Open == 0 {
+		head.Description += "\n\nNo open PRs."
+		return []discordEmbed{head}
+	}
+	repos := embedSection{Name: "Open PRs by repo"}
+	for _, r := range out.Repos {
+		repos.Lines = append(repos.Lines, fmt.Sprintf("`%s` %d", r.Repo, r.Open))
+	}
+	sections := []embedSection{
+		repos,
+		digestSection("Oldest open PRs", out.Oldest, "open %dd"),
+		digestSection(fmt.Sprintf("Changes requested over %dd ago", stuckDays), out.ChangesRequested, "requested %dd ago"),
+		digestSection("Merge conflicts", out.Conflicting, "open %dd"),
+	}
+	return packEmbeds(head, sections)
+}
+
+// digestSection renders PRs one linked line each; days formats pr.Days.
+func digestSection(name string, prs []digestPR, days string) embedSection {
+	s := embedSection{Name: name}
+	for _, pr := range prs {
+		line := fmt.Sprintf("[%s#%d](%s) %s · %s · @%s", pr.Repo, pr.Number, pr.URL, pr.Title, fmt.Sprintf(days, pr.Days), pr.Author)
+		if pr.Draft {
+			line += " · draft"
+		}
+		s.Lines = append(s.Lines, truncateEmbedLine(line))
+	}
+	return s
+}
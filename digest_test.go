@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildDigest(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(d int) time.Time { return now.Add(-time.Duration(d) * 24 * time.Hour) }
+
+	old := fakePR("misty-step/api", 1)
+	old.CreatedAt = daysAgo(40)
+	conflicting := fakePR("misty-step/api", 2)
+	conflicting.CreatedAt, conflicting.Mergeable = daysAgo(12), "CONFLICTING"
+	stuck := fakePR("misty-step/web", 3)
+	stuck.CreatedAt, stuck.ReviewDecision = daysAgo(20), "CHANGES_REQUESTED"
+	stuck.LatestReviews = []prReview{{State: "CHANGES_REQUESTED", SubmittedAt: daysAgo(5)}, {State: "APPROVED", SubmittedAt: daysAgo(1)}}
+	recent := fakePR("misty-step/web", 4)
+	recent.CreatedAt, recent.ReviewDecision = daysAgo(30), "CHANGES_REQUESTED"
+	recent.LatestReviews = []prReview{{State: "CHANGES_REQUESTED", SubmittedAt: daysAgo(1)}}
+	excluded := fakePR("misty-step/infra", 5)
+	excluded.CreatedAt = daysAgo(90)
+	useFakeGitHub(t, newFakeGitHub(old, conflicting, stuck, recent, excluded))
+
+	opts := testPipelineOptions(t, "-skip-repos", "misty-step/infra")
+	opts.digestOldest, opts.digestStuckDays = 2, 3
+	out, err := buildDigest(context.Background(), opts, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Open != 4 {
+		t.Errorf("open = %d; want 4", out.Open)
+	}
+	if want := []digestRepo{{"misty-step/api", 2}, {"misty-step/web", 2}}; !slices.Equal(out.Repos, want) {
+		t.Errorf("repos = %v; want %v", out.Repos, want)
+	}
+	numbers := func(prs []digestPR) (n []int) {
+		for _, pr := range prs {
+			n = append(n, pr.Number)
+		}
+		return n
+	}
+	if got := numbers(out.Oldest); !slices.Equal(got, []int{1, 4}) {
+		t.Errorf("oldest = %v; want [1 4]", got)
+	}
+	if got := numbers(out.Conflicting); !slices.Equal(got, []int{2}) {
+		t.Errorf("conflicting = %v; want [2]", got)
+	}
+	if len(out.ChangesRequested) != 1 || out.ChangesRequested[0].Number != 3 || out.ChangesRequested[0].Days != 5 {
+		t.Errorf("changes requested = %+v; want #3 waiting 5 days", out.ChangesRequested)
+	}
+}
+
+func TestRenderDigestEmbeds(t *testing.T) {
+	out := digestOutput{
+		Org:              "misty-step",
+		Open:             3,
+		Repos:            []digestRepo{{"misty-step/api", 2}, {"misty-step/web", 1}},
+		Oldest:           []digestPR{{URL: "https://github.com/misty-step/api/pull/1", Repo: "misty-step/api", Number: 1, Title: "Old", Author: "kaylee", Days: 40, Draft: true}},
+		ChangesRequested: []digestPR{{URL: "https://github.com/misty-step/web/pull/3", Repo: "misty-step/web", Number: 3, Title: "Stuck", Author: "phrazzld", Days: 5}},
+	}
+	embeds := renderDigestEmbeds(out, 3)
+	fields := map[string]string{}
+	for _, f := range embeds[0].Fields {
+		fields[f.Name] = f.Value
+	}
+	if v := fields["Open PRs by repo (2)"]; v != "`misty-step/api` 2\n`misty-step/web` 1" {
+		t.Errorf("repos field = %q", v)
+	}
+	if v := fields["Oldest open PRs (1)"]; v != "[misty-step/api#1](https://github.com/misty-step/api/pull/1) Old · open 40d · @kaylee · draft" {
+		t.Errorf("oldest field = %q", v)
+	}
+	if v := fields["Changes requested over 3d ago (1)"]; !strings.Contains(v, "requested 5d ago · @phrazzld") {
+		t.Errorf("changes requested field = %q", v)
+	}
+	if _, ok := fields["Merge conflicts (0)"]; ok {
+		t.Error("empty section rendered")
+	}
+
+	if e := renderDigestEmbeds(digestOutput{Org: "misty-step"}, 3); len(e) != 1 || !strings.Contains(e[0].Description, "No open PRs.") {
+		t.Errorf("empty digest = %+v", e)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Discord app settings for slash commands in server mode: the public key
+// interactions are signed with, and the application to register commands on.
+const (
+	discordPublicKeyEnv = "DISCORD_PUBLIC_KEY"
+	discordAppIDEnv     = "DISCORD_APPLICATION_ID"
+)
+
+// Interaction and response types from Discord's interactions API.
+const (
+	interactionPing          = 1
+	interactionCommand       = 2
+	interactionResponsePong  = 1
+	interactionResponseReply = 4
+)
+
+// discordManageGuild is the MANAGE_GUILD permission bit, as a string.
+const discordManageGuild = "32"
+
+// discordCommands is the /pipeline command with its run, skip, and status
+// subcommands. Only members who can manage the server see it by default.
+var discordCommands = []map[string]any{{
+	"name":                       "pipeline",
+	"description":                "Control the PR pipeline",
+	"default_member_permissions": discordManageGuild,
+	"options": []map[string]any{
+		{"type": 1, "name": "run", "description": "Start a pipeline run now", "options": []map[string]any{
+			{"type": 3, "name": "repo", "description": "Only this repo (owner/name or glob)"},
+		}},
+		{"type": 1, "name": "skip", "description": "Keep the pipeline away from a PR for a while", "options": []map[string]any{
+			{"type": 3, "name": "pr-url", "description": "GitHub pull request URL", "required": true},
+			{"type": 4, "name": "hours", "description": "How long to skip it (default 24)", "min_value": 1},
+		}},
+		{"type": 1, "name": "status", "description": "Show whether a run is in progress and the last run's results"},
+	},
+}}
+
+// discordInteraction is the subset of an interaction payload we read.
+type discordInteraction struct {
+	Type int `json:"type"`
+	Data struct {
+		Name    string                     `json:"name"`
+		Options []discordInteractionOption `json:"options"`
+	} `json:"data"`
+}
+
+type discordInteractionOption struct {
+	Name    string                     `json:"name"`
+	Value   json.RawMessage            `json:"value"`
+	Options []discordInteractionOption `json:"options"`
+}
+
+// interactionOption returns the named option's value as a string ("" if absent).
+func interactionOption(opts []discordInteractionOption, name string) string {
+	for _, o := range opts {
+		if o.Name != name {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(o.Value, &s); err == nil {
+			return s
+		}
+		return string(o.Value)
+	}
+	return ""
+}
+
+// parseDiscordPublicKey decodes the hex public key from the app's settings.
+func parseDiscordPublicKey(raw string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(raw))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%s must be a %d-byte hex key", discordPublicKeyEnv, ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// verifyDiscordSignature checks an interaction's Ed25519 signature over
+// timestamp+body.
+func verifyDiscordSignature(key ed25519.PublicKey, body []byte, timestamp string, signature string) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(key) != ed25519.PublicKeySize || timestamp == "" {
+		return false
+	}
+	return ed25519.Verify(key, append([]byte(timestamp), body...), sig)
+}
+
+// handleDiscordInteraction answers /pipeline slash commands. Discord
+// requires a reply within three seconds, so runs are only started here.
+func (s *pipelineServer) handleDiscordInteraction(w http.ResponseWriter, r *http.Request) {
+	if s.discordKey == nil {
+		writeJSONStatus(w, http.StatusServiceUnavailable, map[string]any{"ok": false, "error": discordPublicKeyEnv + " not set"})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]any{"ok": false, "error": err.Error()})
+		return
+	}
+	if !verifyDiscordSignature(s.discordKey, body, r.Header.Get("X-Signature-Timestamp"), r.Header.Get("X-Signature-Ed25519")) {
+		writeJSONStatus(w, http.StatusUnauthorized, map[string]any{"ok": false, "error": "invalid request signature"})
+		return
+	}
+	var in discordInteraction
+	if err := json.Unmarshal(body, &in); err != nil {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]any{"ok": false, "error": err.Error()})
+		return
+	}
+	switch in.Type {
+	case interactionPing:
+		writeJSONStatus(w, http.StatusOK, map[string]any{"type": interactionResponsePong})
+	case interactionCommand:
+		reply := s.runDiscordCommand(in, time.Now())
+		writeJSONStatus(w, http.StatusOK, map[string]any{"type": interactionResponseReply, "data": map[string]any{"content": reply}})
+	default:
+		writeJSONStatus(w, http.StatusBadRequest, map[string]any{"ok": false, "error": fmt.Sprintf("unsupported interaction type %d", in.Type)})
+	}
+}
+
+// runDiscordCommand carries out a /pipeline subcommand and returns the reply.
+func (s *pipelineServer) runDiscordCommand(in discordInteraction, now time.Time) string {
+	if in.Data.Name != "pipeline" || len(in.Data.Options) == 0 {
+		return "Unknown command."
+	}
+	sub := in.Data.Options[0]
+	switch sub.Name {
+	case "run":
+		repo := interactionOption(sub.Options, "repo")
+		opts, err := s.scopedOptions(repo)
+		if err != nil {
+			return "Can't run: " + err.Error()
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.running {
+			return "A run is already in progress."
+		}
+		s.startLocked(opts)
+		if repo != "" {
+			return fmt.Sprintf("Started a run for `%s`.", repo)
+		}
+		return "Started a run."
+	case "skip":
+		hours := defaultDenyFor
+		if h := interactionOption(sub.Options, "hours"); h != "" {
+			var n int
+			if _, err := fmt.Sscan(h, &n); err != nil || n <= 0 {
+				return "Can't skip: hours must be a positive number."
+			}
+			hours = time.Duration(n) * time.Hour
+		}
+		url := interactionOption(sub.Options, "pr-url")
+		until, err := denyPR(denylistPath(resolveStatePath(s.opts.StateFile)), url, hours, now)
+		if err != nil {
+			return "Can't skip: " + err.Error()
+		}
+		return fmt.Sprintf("Skipping %s until %s.", strings.TrimSuffix(strings.TrimSpace(url), "/"), until.Format(time.RFC3339))
+	case "status":
+		s.mu.Lock()
+		running, last, lastErr := s.running, s.last, s.lastErr
+		s.mu.Unlock()
+		lines := []string{"Idle."}
+		if running {
+			lines[0] = "A run is in progress."
+		}
+		if lastErr != "" {
+			lines = append(lines, "Last run failed: "+lastErr)
+		}
+		if last != nil {
+			merged, commented, skipped, errs := summarize(last.Results)
+			lines = append(lines, fmt.Sprintf("Last run %s: merged=`%d` commented=`%d` skipped=`%d` errors=`%d`", last.StartedAt, merged, commented, skipped, errs))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return "Unknown subcommand."
+}
+
+// registerDiscordCommands installs the /pipeline command on the app,
+// replacing any commands registered before.
+func registerDiscordCommands(ctx context.Context, token string, appID string) error {
+	b, err := json.Marshal(discordCommands)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "PUT", discordAPIBase+"/applications/"+strings.TrimSpace(appID)+"/commands", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+strings.TrimSpace(token))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "misty-step/factory/pr-pipeline")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		msg := strings.TrimSpace(string(raw))
+		if msg == "" {
+			msg = resp.Status
+		}
+		return fmt.Errorf("discord command registration failed (%d): %s", resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// setupDiscordCommands registers the slash commands at server start when
+// the app ID and bot token are configured. Failures are logged; the
+// endpoint still answers commands registered earlier.
+func setupDiscordCommands(ctx context.Context) {
+	appID := strings.TrimSpace(os.Getenv(discordAppIDEnv))
+	token := discordBotToken()
+	if appID == "" || token == "" {
+		return
+	}
+	if err := registerDiscordCommands(ctx, token, appID); err != nil {
+		fmt.Fprintf(os.Stderr, "[discord] %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[discord] registered /pipeline commands\n")
+}
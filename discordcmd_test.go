@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedInteraction posts body to the interactions endpoint, signed with priv.
+func signedInteraction(t *testing.T, h http.Handler, priv ed25519.PrivateKey, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	ts := "1717243200"
+	req := httptest.NewRequest("POST", "/discord/interactions", strings.NewReader(body))
+	req.Header.Set("X-Signature-Timestamp", ts)
+	req.Header.Set("X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(priv, []byte(ts+body))))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// interactionReply decodes a channel-message response's content.
+func interactionReply(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp struct {
+		Type int `json:"type"`
+		Data struct {
+			Content string `json:"content"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Type != interactionResponseReply {
+		t.Fatalf("response %d %s: %v", rec.Code, rec.Body, err)
+	}
+	return resp.Data.Content
+}
+
+func newDiscordTestServer(t *testing.T, runFn func(*runOptions) (runOutput, error)) (*pipelineServer, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, runFn)
+	s.discordKey = pub
+	return s, priv
+}
+
+func TestDiscordInteractionSignature(t *testing.T) {
+	s, priv := newDiscordTestServer(t, nil)
+	h := s.handler()
+
+	rec := signedInteraction(t, h, priv, `{"type":1}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"type":1`) {
+		t.Errorf("ping = %d %s; want a pong", rec.Code, rec.Body)
+	}
+
+	_, other, _ := ed25519.GenerateKey(nil)
+	if rec := signedInteraction(t, h, other, `{"type":1}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrongly signed ping = %d; want 401", rec.Code)
+	}
+
+	s.discordKey = nil
+	if rec := signedInteraction(t, h, priv, `{"type":1}`); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without a key = %d; want 503", rec.Code)
+	}
+}
+
+func TestDiscordCommandRun(t *testing.T) {
+	gotRepos := make(chan []string, 1)
+	s, priv := newDiscordTestServer(t, func(opts *runOptions) (runOutput, error) {
+		gotRepos <- opts.onlyRepos
+		return runOutput{Ok: true, StartedAt: "2025-06-01T12:00:00Z"}, nil
+	})
+	h := s.handler()
+
+	body := `{"type":2,"data":{"name":"pipeline","options":[{"name":"run","type":1,"options":[{"name":"repo","type":3,"value":"misty-step/fab-cli"}]}]}}`
+	if got := interactionReply(t, signedInteraction(t, h, priv, body)); got != "Started a run for `misty-step/fab-cli`." {
+		t.Errorf("reply = %q", got)
+	}
+	if repos := <-gotRepos; len(repos) != 1 || repos[0] != "misty-step/fab-cli" {
+		t.Errorf("run scoped to %v", repos)
+	}
+	waitIdle(t, s)
+
+	status := `{"type":2,"data":{"name":"pipeline","options":[{"name":"status","type":1}]}}`
+	if got := interactionReply(t, signedInteraction(t, h, priv, status)); !strings.Contains(got, "Idle.") || !strings.Contains(got, "Last run 2025-06-01T12:00:00Z") {
+		t.Errorf("status reply = %q", got)
+	}
+}
+
+func TestDiscordCommandSkip(t *testing.T) {
+	s, priv := newDiscordTestServer(t, nil)
+	h := s.handler()
+
+	body := `{"type":2,"data":{"name":"pipeline","options":[{"name":"skip","type":1,"options":[{"name":"pr-url","type":3,"value":"https://github.com/misty-step/app/pull/7/"},{"name":"hours","type":4,"value":2}]}]}}`
+	if got := interactionReply(t, signedInteraction(t, h, priv, body)); !strings.HasPrefix(got, "Skipping https://github.com/misty-step/app/pull/7 until ") {
+		t.Errorf("reply = %q", got)
+	}
+	d := loadDenylist(denylistPath(s.opts.StateFile), time.Now())
+	if !d.has("https://github.com/misty-step/app/pull/7", time.Now().Add(time.Hour)) {
+		t.Errorf("PR not denylisted for the next hour: %v", d)
+	}
+	if d.has("https://github.com/misty-step/app/pull/7", time.Now().Add(3*time.Hour)) {
+		t.Errorf("PR denylisted past the requested 2 hours: %v", d)
+	}
+
+	bad := `{"type":2,"data":{"name":"pipeline","options":[{"name":"skip","type":1,"options":[{"name":"pr-url","type":3,"value":"not a url"}]}]}}`
+	if got := interactionReply(t, signedInteraction(t, h, priv, bad)); !strings.HasPrefix(got, "Can't skip:") {
+		t.Errorf("reply = %q", got)
+	}
+}
+
+func TestDenylist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.json")
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := denyPR(path, "https://github.com/o/r/pull/1", time.Hour, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := denyPR(path, "https://github.com/o/r/pull/2", 3*time.Hour, now); err != nil {
+		t.Fatal(err)
+	}
+
+	later := now.Add(2 * time.Hour)
+	d := loadDenylist(path, later)
+	if d.has("https://github.com/o/r/pull/1", later) || len(d) != 1 {
+		t.Errorf("expired entry kept: %v", d)
+	}
+
+	opts := &runOptions{denied: d, authors: defaultAuthorPolicies("", 0, "")}
+	mk := func(url string) searchPR {
+		pr := searchPR{URL: url, UpdatedAt: now}
+		pr.Author.Login = "someone"
+		pr.Repository.NameWithOwner = "o/r"
+		return pr
+	}
+	got := selectPRs(opts, []searchPR{mk("https://github.com/o/r/pull/1"), mk("https://github.com/o/r/pull/2")}, later)
+	if len(got) != 1 || got[0].URL != "https://github.com/o/r/pull/1" {
+		t.Errorf("selected %v; want only the unlisted PR", got)
+	}
+
+	if _, err := denyPR(path, "https://github.com/o/r/issues/3", time.Hour, now); err == nil {
+		t.Error("expected an error for an issue URL")
+	}
+	if d := loadDenylist(filepath.Join(t.TempDir(), "missing.json"), now); len(d) != 0 {
+		t.Errorf("missing file: %v", d)
+	}
+}
+
+func TestRegisterDiscordCommands(t *testing.T) {
+	var got []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/applications/42/commands" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	old := discordAPIBase
+	discordAPIBase = srv.URL
+	defer func() { discordAPIBase = old }()
+
+	if err := registerDiscordCommands(t.Context(), "tok", "42"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0]["name"] != "pipeline" {
+		t.Errorf("registered %v", got)
+	}
+	if err := registerDiscordCommands(t.Context(), "tok", "7"); err == nil {
+		t.Error("expected an error for a failed registration")
+	}
+}
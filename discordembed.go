@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// discordMessage is the body of a Discord create-message request.
+type discordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Discord's embed limits. discordEmbedMaxChars stays under the documented
+// 6000 to leave room for titles we add when splitting.
+const (
+	discordEmbedMaxFields     = 25
+	discordEmbedMaxChars      = 5500
+	discordFieldValueMaxChars = 1024
+	discordEmbedLineMaxChars  = 300
+)
+
+// Embed colors by run health.
+const (
+	discordColorGreen = 0x2ecc71
+	discordColorBlue  = 0x3498db
+	discordColorGrey  = 0x95a5a6
+	discordColorRed   = 0xe74c3c
+)
+
+// runColor picks the summary color: red if anything failed, grey for a dry
+// run, green if something merged, blue otherwise.
+func runColor(out runOutput, merged int, errs int) int {
+	switch {
+	case !out.Ok || errs > 0:
+		return discordColorRed
+	case out.DryRun:
+		return discordColorGrey
+	case merged > 0:
+		return discordColorGreen
+	}
+	return discordColorBlue
+}
+
+// outcomeBucket returns the summary bucket for an action: merged,
+// commented, skipped, error, or "" for actions counted in none of them.
+func outcomeBucket(action string) string {
+	switch action {
+	case "merged", "enqueued":
+		return "merged"
+	case "commented", "branch_updated", "conflict_resolved", "rebased", "review_dispatched", "review_requested", "review_dismissed", "lint_dispatched", "test_dispatched", "ci_rerun", "workflows_approved", "closed_stale", "auto_merge_enabled", "marked_ready":
+		return "commented"
+	case "skipped":
+		return "skipped"
+	case "error":
+		return "error"
+	}
+	return ""
+}
+
+// embedSection is a titled list of lines, rendered as one or more fields.
+type embedSection struct {
+	Name  string
+	Lines []string
+}
+
+// renderDiscordEmbeds renders the run summary as embeds: run totals first,
+// then the PRs grouped by outcome with links. Long runs spill into further
+// embeds, each posted as its own message.
+func renderDiscordEmbeds(out runOutput, merged int, commented int, skipped int, errs int) []discordEmbed {
+	title := "PR pipeline run"
+	if out.DryRun {
+		title += " (dry run)"
+	}
+	head := discordEmbed{
+		Title:       title,
+		Description: fmt.Sprintf("org: `%s` | %s | staleHours(phaedrus-only): `%d`", out.Org, runBudget(out), out.StaleHours),
+		Color:       runColor(out, merged, errs),
+		Timestamp:   out.StartedAt,
+		Fields: []discordEmbedField{
+			{Name: "Merged", Value: fmt.Sprint(merged), Inline: true},
+			{Name: "Commented", Value: fmt.Sprint(commented), Inline: true},
+			{Name: "Skipped", Value: fmt.Sprint(skipped), Inline: true},
+			{Name: "Errors", Value: fmt.Sprint(errs), Inline: true},
+		},
+	}
+	if out.Error != "" {
+		head.Fields = append(head.Fields, discordEmbedField{Name: "Run error", Value: truncateEmbedLine(out.Error)})
+	}
+	if out.Aborted != "" {
+		head.Fields = append(head.Fields, discordEmbedField{Name: "Run aborted", Value: truncateEmbedLine(out.Aborted)})
+	}
+	if out.Frozen != "" {
+		head.Fields = append(head.Fields, discordEmbedField{Name: "Frozen", Value: truncateEmbedLine(out.Frozen)})
+	}
+	for _, o := range out.Orgs {
+		head.Fields = append(head.Fields, discordEmbedField{
+			Name:   o.Org,
+			Value:  fmt.Sprintf("merged %d · commented %d · skipped %d · errors %d", o.Merged, o.Commented, o.Skipped, o.Errors),
+			Inline: true,
+		})
+	}
+	if out.Stats != nil && (out.Stats.Outcomes > 0 || out.Stats.Week != nil) {
+		head.Fields = append(head.Fields, discordEmbedField{Name: "Stats", Value: strings.Join(statsLines(*out.Stats), "\n")})
+	}
+	if len(out.Results) == 0 {
+		head.Description += "\n\nNo PRs selected."
+		return []discordEmbed{head}
+	}
+
+	sections := []embedSection{{Name: "Merged"}, {Name: "Commented"}, {Name: "Errors"}, {Name: "Other actions"}, {Name: "Skipped"}}
+	index := map[string]int{"merged": 0, "commented": 1, "error": 2, "": 3, "skipped": 4}
+	for _, r := range out.Results {
+		i := index[outcomeBucket(r.Action)]
+		sections[i].Lines = append(sections[i].Lines, discordEmbedLine(r))
+	}
+	return packEmbeds(head, sections)
+}
+
+// renderDiscordAlertEmbeds renders the error alert: one line per failed PR.
+func renderDiscordAlertEmbeds(out runOutput, errs int) []discordEmbed {
+	head := discordEmbed{
+		Title:     "PR pipeline: errors detected",
+		Color:     discordColorRed,
+		Timestamp: out.StartedAt,
+		Fields:    []discordEmbedField{{Name: "Errors", Value: fmt.Sprint(errs), Inline: true}},
+	}
+	section := embedSection{Name: "Error PRs"}
+	for _, r := range out.Results {
+		if r.Action == "error" {
+			section.Lines = append(section.Lines, discordEmbedLine(r))
+		}
+	}
+	return packEmbeds(head, []embedSection{section})
+}
+
+// discordEmbedLine renders one PR outcome as a linked line.
+func discordEmbedLine(r prOutcome) string {
+	ref := r.URL
+	if r.Repo != "" && r.Number > 0 {
+		ref = fmt.Sprintf("[%s#%d](%s)", r.Repo, r.Number, r.URL)
+	}
+	line := fmt.Sprintf("%s `%s`", ref, r.Action)
+	if r.Reason != "" {
+		line += " " + r.Reason
+	}
+	if r.Action == "merged" && r.MergeCommitOID != "" {
+		line += " commit:" + r.MergeCommitOID
+	}
+	return truncateEmbedLine(line)
+}
+
+// truncateEmbedLine caps a line so one long error can't fill a field.
+func truncateEmbedLine(s string) string {
+	if len(s) <= discordEmbedLineMaxChars {
+		return s
+	}
+	return s[:discordEmbedLineMaxChars-len("…")] + "…"
+}
+
+// packEmbeds appends each section to head as fields of at most
+// discordFieldValueMaxChars, starting a continuation embed whenever the
+// current one would pass Discord's field or size limits.
+func packEmbeds(head discordEmbed, sections []embedSection) []discordEmbed {
+	embeds := []discordEmbed{head}
+	cur := &embeds[0]
+	add := func(f discordEmbedField) {
+		if len(cur.Fields) >= discordEmbedMaxFields || embedChars(*cur)+len(f.Name)+len(f.Value) > discordEmbedMaxChars {
+			embeds = append(embeds, discordEmbed{Title: head.Title + " (cont.)", Color: head.Color, Timestamp: head.Timestamp})
+			cur = &embeds[len(embeds)-1]
+		}
+		cur.Fields = append(cur.Fields, f)
+	}
+	for _, s := range sections {
+		if len(s.Lines) == 0 {
+			continue
+		}
+		name := fmt.Sprintf("%s (%d)", s.Name, len(s.Lines))
+		var value []string
+		size := 0
+		for _, line := range s.Lines {
+			if size > 0 && size+1+len(line) > discordFieldValueMaxChars {
+				add(discordEmbedField{Name: name, Value: strings.Join(value, "\n")})
+				name = s.Name + " (cont.)"
+				value, size = nil, 0
+			}
+			if size > 0 {
+				size++
+			}
+			value = append(value, line)
+			size += len(line)
+		}
+		add(discordEmbedField{Name: name, Value: strings.Join(value, "\n")})
+	}
+	return embeds
+}
+
+// embedChars counts the characters Discord charges against an embed's limit.
+func embedChars(e discordEmbed) int {
+	n := len(e.Title) + len(e.Description)
+	for _, f := range e.Fields {
+		n += len(f.Name) + len(f.Value)
+	}
+	return n
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunColor(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    runOutput
+		merged int
+		errs   int
+		want   int
+	}{
+		{"errors", runOutput{Ok: true}, 2, 1, discordColorRed},
+		{"run failed", runOutput{Ok: false}, 0, 0, discordColorRed},
+		{"dry run", runOutput{Ok: true, DryRun: true}, 2, 0, discordColorGrey},
+		{"merged", runOutput{Ok: true}, 1, 0, discordColorGreen},
+		{"nothing merged", runOutput{Ok: true}, 0, 0, discordColorBlue},
+	}
+	for _, tt := range tests {
+		if got := runColor(tt.out, tt.merged, tt.errs); got != tt.want {
+			t.Errorf("%s: runColor = %#x; want %#x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderDiscordEmbeds(t *testing.T) {
+	out := runOutput{
+		Ok:        true,
+		StartedAt: "2025-06-01T12:00:00Z",
+		Org:       "misty-step",
+		MaxPRs:    5,
+		Results: []prOutcome{
+			{URL: "https://github.com/misty-step/a/pull/1", Repo: "misty-step/a", Number: 1, Action: "merged", MergeCommitOID: "abc123"},
+			{URL: "https://github.com/misty-step/a/pull/2", Repo: "misty-step/a", Number: 2, Action: "skipped", Reason: "draft"},
+			{URL: "https://github.com/misty-step/b/pull/3", Repo: "misty-step/b", Number: 3, Action: "rebased", Reason: "mergeable_conflicting"},
+			{URL: "https://github.com/misty-step/b/pull/4", Repo: "misty-step/b", Number: 4, Action: "branch_updated", Reason: "branch_behind"},
+			{URL: "https://github.com/misty-step/b/pull/5", Repo: "misty-step/b", Number: 5, Action: "conflict_resolved", Reason: "mergeable_conflicting"},
+		},
+	}
+	embeds := renderDiscordEmbeds(out, 1, 3, 1, 0)
+	if len(embeds) != 1 {
+		t.Fatalf("got %d embeds; want 1", len(embeds))
+	}
+	e := embeds[0]
+	if e.Color != discordColorGreen || e.Timestamp != out.StartedAt {
+		t.Errorf("color=%#x timestamp=%q", e.Color, e.Timestamp)
+	}
+	fields := map[string]string{}
+	for _, f := range e.Fields {
+		fields[f.Name] = f.Value
+	}
+	if fields["Merged"] != "1" || fields["Skipped"] != "1" {
+		t.Errorf("totals fields = %v", fields)
+	}
+	if got := fields["Merged (1)"]; got != "[misty-step/a#1](https://github.com/misty-step/a/pull/1) `merged` commit:abc123" {
+		t.Errorf("merged section = %q", got)
+	}
+	got := fields["Commented (3)"]
+	for _, want := range []string{"`rebased` mergeable_conflicting", "`branch_updated` branch_behind", "`conflict_resolved` mergeable_conflicting"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("commented section missing %q; got %q", want, got)
+		}
+	}
+	for name := range fields {
+		if strings.HasPrefix(name, "Other actions") {
+			t.Errorf("%s: branch updates should count as commented", name)
+		}
+	}
+	if _, ok := fields["Errors (0)"]; ok {
+		t.Error("empty sections should be left out")
+	}
+
+	empty := renderDiscordEmbeds(runOutput{Ok: true}, 0, 0, 0, 0)
+	if len(empty) != 1 || !strings.Contains(empty[0].Description, "No PRs selected.") {
+		t.Errorf("empty run = %+v", empty)
+	}
+}
+
+func TestRenderDiscordEmbedsLongRun(t *testing.T) {
+	out := runOutput{Ok: true, Org: "misty-step"}
+	for i := range 400 {
+		out.Results = append(out.Results, prOutcome{
+			URL:    fmt.Sprintf("https://github.com/misty-step/repo/pull/%d", i),
+			Repo:   "misty-step/repo",
+			Number: i,
+			Action: "skipped",
+			Reason: "checks_pending",
+		})
+	}
+	embeds := renderDiscordEmbeds(out, 0, 0, 400, 0)
+	if len(embeds) < 2 {
+		t.Fatalf("got %d embeds; want the run split across several", len(embeds))
+	}
+	lines := 0
+	for _, e := range embeds {
+		if len(e.Fields) > discordEmbedMaxFields || embedChars(e) > discordEmbedMaxChars {
+			t.Errorf("embed over limits: %d fields, %d chars", len(e.Fields), embedChars(e))
+		}
+		for _, f := range e.Fields {
+			if len(f.Value) > discordFieldValueMaxChars {
+				t.Errorf("field %q is %d chars", f.Name, len(f.Value))
+			}
+			if strings.HasPrefix(f.Name, "Skipped") && f.Name != "Skipped" {
+				lines += strings.Count(f.Value, "\n") + 1
+			}
+		}
+	}
+	if lines != 400 {
+		t.Errorf("listed %d PRs; want all 400", lines)
+	}
+}
+
+func TestDiscordEmbedLineTruncates(t *testing.T) {
+	r := prOutcome{URL: "u", Repo: "o/r", Number: 1, Action: "error", Reason: strings.Repeat("x", 1000)}
+	if got := discordEmbedLine(r); len(got) > discordEmbedLineMaxChars || !strings.HasSuffix(got, "…") {
+		t.Errorf("line not truncated: %d chars", len(got))
+	}
+}
+
+func TestDiscordSendEmbeds(t *testing.T) {
+	var posted []discordMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m discordMessage
+		_ = json.NewDecoder(r.Body).Decode(&m)
+		posted = append(posted, m)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	old := discordAPIBase
+	discordAPIBase = srv.URL
+	defer func() { discordAPIBase = old }()
+
+	embeds := []discordEmbed{{Title: "one"}, {Title: "two"}}
+	if err := discordSendEmbeds(context.Background(), "tok", "123", embeds); err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 2 || posted[0].Embeds[0].Title != "one" || posted[1].Embeds[0].Title != "two" {
+		t.Errorf("posted %+v", posted)
+	}
+}
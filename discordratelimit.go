@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discord answers 429 with how long to back off. We retry a message up to
+// discordRateLimitRetries times, but give up rather than wait longer than
+// discordMaxRetryAfter (a global or shared-bucket limit that long won't
+// clear within a run).
+const (
+	discordRateLimitRetries = 3
+	discordMaxRetryAfter    = time.Minute
+)
+
+// discordRateLimitSleep waits out a rate limit, returning early if ctx ends
+// (replaced in tests).
+var discordRateLimitSleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// discordRetryAfter reads how long a 429 asks us to wait: retry_after
+// (seconds, possibly fractional) from the body, else the Retry-After header.
+// Anything unparseable waits one second.
+func discordRetryAfter(h http.Header, body []byte) time.Duration {
+	var rl struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if err := json.Unmarshal(body, &rl); err == nil && rl.RetryAfter > 0 {
+		return secondsDuration(rl.RetryAfter)
+	}
+	if s, err := strconv.ParseFloat(strings.TrimSpace(h.Get("Retry-After")), 64); err == nil && s > 0 {
+		return secondsDuration(s)
+	}
+	return time.Second
+}
+
+// discordBucketPause returns how long to wait before the next request when
+// the response used up the route's rate-limit bucket, or 0.
+func discordBucketPause(h http.Header) time.Duration {
+	if strings.TrimSpace(h.Get("X-RateLimit-Remaining")) != "0" {
+		return 0
+	}
+	s, err := strconv.ParseFloat(strings.TrimSpace(h.Get("X-RateLimit-Reset-After")), 64)
+	if err != nil || s <= 0 {
+		return 0
+	}
+	return min(secondsDuration(s), discordMaxRetryAfter)
+}
+
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
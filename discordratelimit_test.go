@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiscordRetryAfter(t *testing.T) {
+	h := http.Header{}
+	if got := discordRetryAfter(h, []byte(`{"message":"You are being rate limited.","retry_after":0.25,"global":false}`)); got != 250*time.Millisecond {
+		t.Errorf("body retry_after = %s; want 250ms", got)
+	}
+	h.Set("Retry-After", "2")
+	if got := discordRetryAfter(h, []byte("not json")); got != 2*time.Second {
+		t.Errorf("header Retry-After = %s; want 2s", got)
+	}
+	if got := discordRetryAfter(http.Header{}, nil); got != time.Second {
+		t.Errorf("fallback = %s; want 1s", got)
+	}
+}
+
+func TestDiscordBucketPause(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-RateLimit-Remaining", "1")
+	h.Set("X-RateLimit-Reset-After", "1.5")
+	if got := discordBucketPause(h); got != 0 {
+		t.Errorf("bucket not exhausted: pause = %s", got)
+	}
+	h.Set("X-RateLimit-Remaining", "0")
+	if got := discordBucketPause(h); got != 1500*time.Millisecond {
+		t.Errorf("pause = %s; want 1.5s", got)
+	}
+}
+
+// stubDiscordSleep records waits instead of sleeping.
+func stubDiscordSleep(t *testing.T) *[]time.Duration {
+	var waits []time.Duration
+	old := discordRateLimitSleep
+	discordRateLimitSleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	t.Cleanup(func() { discordRateLimitSleep = old })
+	return &waits
+}
+
+func TestDiscordPostRetriesOn429(t *testing.T) {
+	waits := stubDiscordSleep(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"retry_after":0.5}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	old := discordAPIBase
+	discordAPIBase = srv.URL
+	t.Cleanup(func() { discordAPIBase = old })
+
+	if err := discordSendMessage(context.Background(), "tok", "123", "hi"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d; want 2", calls.Load())
+	}
+	if len(*waits) != 1 || (*waits)[0] != 500*time.Millisecond {
+		t.Errorf("waits = %v; want [500ms]", *waits)
+	}
+}
+
+func TestDiscordPostGivesUpAfterRetries(t *testing.T) {
+	stubDiscordSleep(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"retry_after":1}`))
+	}))
+	defer srv.Close()
+	old := discordAPIBase
+	discordAPIBase = srv.URL
+	t.Cleanup(func() { discordAPIBase = old })
+
+	err := discordSendMessage(context.Background(), "tok", "123", "hi")
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("err = %v; want a 429 error", err)
+	}
+	if got := calls.Load(); got != discordRateLimitRetries+1 {
+		t.Errorf("calls = %d; want %d", got, discordRateLimitRetries+1)
+	}
+}
+
+func TestDiscordSendEmbedsPacesAndKeepsGoing(t *testing.T) {
+	waits := stubDiscordSleep(t)
+	var posted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := new(strings.Builder)
+		_, _ = io.Copy(buf, r.Body)
+		if strings.Contains(buf.String(), "part two") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		posted = append(posted, buf.String())
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset-After", "2")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	old := discordAPIBase
+	discordAPIBase = srv.URL
+	t.Cleanup(func() { discordAPIBase = old })
+
+	embeds := []discordEmbed{{Title: "part one"}, {Title: "part two"}, {Title: "part three"}}
+	err := discordSendEmbeds(context.Background(), "tok", "123", embeds)
+	if err == nil || !strings.Contains(err.Error(), "part 2/3") {
+		t.Errorf("err = %v; want the failed part reported", err)
+	}
+	if len(posted) != 2 || !strings.Contains(posted[1], "part three") {
+		t.Errorf("posted %d parts; want parts one and three", len(posted))
+	}
+	if len(*waits) != 1 || (*waits)[0] != 2*time.Second {
+		t.Errorf("waits = %v; want one 2s pause between parts", *waits)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// discordContentMaxChars is Discord's limit on a message's content.
+const discordContentMaxChars = 2000
+
+// discordPartPrefixMaxChars is room kept in each part for its "(i/n) "
+// sequence prefix.
+const discordPartPrefixMaxChars = len("(999/999) ")
+
+// splitDiscordContent splits content into parts of at most limit bytes,
+// breaking between lines where it can and inside a line (on a rune
+// boundary) only when the line alone is too long. Content that fits is
+// returned as is.
+func splitDiscordContent(content string, limit int) []string {
+	if len(content) <= limit {
+		return []string{content}
+	}
+	var parts []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			parts = append(parts, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if cur.Len()+len(line) > limit {
+			flush()
+		}
+		for len(line) > limit {
+			cut := limit
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			parts = append(parts, line[:cut])
+			line = line[cut:]
+		}
+		cur.WriteString(line)
+	}
+	flush()
+	kept := parts[:0]
+	for _, p := range parts {
+		if p = strings.TrimRight(p, "\n"); p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// discordSendMessage posts content to channelID. Content over Discord's
+// limit goes out as several messages, in order and numbered "(i/n)", so
+// long review feedback isn't cut off or rejected. A part that fails doesn't
+// stop the parts after it.
+func discordSendMessage(ctx context.Context, token string, channelID string, content string) error {
+	if len(content) <= discordContentMaxChars {
+		return discordPostMessage(ctx, token, channelID, discordMessage{Content: content})
+	}
+	parts := splitDiscordContent(content, discordContentMaxChars-discordPartPrefixMaxChars)
+	var errs []error
+	for i, part := range parts {
+		pause, err := discordPost(ctx, token, channelID, discordMessage{Content: fmt.Sprintf("(%d/%d) %s", i+1, len(parts), part)})
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			errs = append(errs, fmt.Errorf("part %d/%d: %w", i+1, len(parts), err))
+			continue
+		}
+		if pause > 0 && i < len(parts)-1 {
+			if err := discordRateLimitSleep(ctx, pause); err != nil {
+				return err
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitDiscordContent(t *testing.T) {
+	tests := []struct {
+		content string
+		limit   int
+		want    []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"aaaa\nbbbb\ncccc", 10, []string{"aaaa\nbbbb", "cccc"}},
+		{"aaaa\n\n\nbbbbbbbbbbbbbbbbbbbbbbb\ncc", 10, []string{"aaaa", "bbbbbbbbbb", "bbbbbbbbbb", "bbb\ncc"}},
+		{"ééééééé", 5, []string{"éé", "éé", "éé", "é"}}, // é is two bytes
+	}
+	for _, tt := range tests {
+		got := splitDiscordContent(tt.content, tt.limit)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("splitDiscordContent(%q, %d) = %q; want %q", tt.content, tt.limit, got, tt.want)
+		}
+		for _, p := range got {
+			if len(p) > tt.limit {
+				t.Errorf("part %q is over %d bytes", p, tt.limit)
+			}
+		}
+	}
+}
+
+func TestDiscordSendMessageSplitsLongContent(t *testing.T) {
+	f := newFakeDiscord(t)
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, "- `main.go` "+strings.Repeat("x", 40))
+	}
+	content := strings.Join(lines, "\n")
+	if err := discordSendMessage(context.Background(), "tok", "999", content); err != nil {
+		t.Fatal(err)
+	}
+	got := f.messages["999"]
+	if len(got) != 3 {
+		t.Fatalf("sent %d messages; want 3", len(got))
+	}
+	var rejoined []string
+	for i, msg := range got {
+		if len(msg) > discordContentMaxChars {
+			t.Errorf("message %d is %d chars", i+1, len(msg))
+		}
+		prefix := "(" + string(rune('1'+i)) + "/3) "
+		body, ok := strings.CutPrefix(msg, prefix)
+		if !ok {
+			t.Errorf("message %d = %.20q…; want prefix %q", i+1, msg, prefix)
+		}
+		rejoined = append(rejoined, body)
+	}
+	if strings.Join(rejoined, "\n") != content {
+		t.Error("the parts don't add back up to the content")
+	}
+
+	if err := discordSendMessage(context.Background(), "tok", "888", strings.Repeat("y", discordContentMaxChars)); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.messages["888"]; len(got) != 1 || strings.HasPrefix(got[0], "(") {
+		t.Errorf("content at the limit was split: %d messages", len(got))
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// fixDispatchPayload is the client_payload of a repository_dispatch asking a
+// fix-up agent (lint, tests) to take over a failing PR.
+type fixDispatchPayload struct {
+	PRURL         string          `json:"pr_url"`
+	Repo          string          `json:"repo"`
+	Number        int             `json:"number"`
+	BaseRef       string          `json:"base_ref,omitempty"`
+	FailureType   string          `json:"failure_type"`
+	FailingChecks []dispatchCheck `json:"failing_checks"`
+}
+
+type dispatchCheck struct {
+	Name       string `json:"name"`
+	Conclusion string `json:"conclusion"`
+	DetailsURL string `json:"details_url,omitempty"`
+}
+
+func newFixDispatchPayload(pr *prView, repo string, number int, failureType string) fixDispatchPayload {
+	payload := fixDispatchPayload{
+		PRURL:         pr.URL,
+		Repo:          repo,
+		Number:        number,
+		BaseRef:       pr.BaseRefName,
+		FailureType:   failureType,
+		FailingChecks: []dispatchCheck{},
+	}
+	for _, e := range failingChecks(pr.StatusCheckRollup) {
+		c := dispatchCheck{Name: e.Name, Conclusion: strings.ToUpper(e.Conclusion), DetailsURL: e.DetailsURL}
+		if strings.TrimSpace(e.Typename) == "StatusContext" {
+			c = dispatchCheck{Name: e.Context, Conclusion: strings.ToUpper(e.State), DetailsURL: e.TargetURL}
+		}
+		payload.FailingChecks = append(payload.FailingChecks, c)
+	}
+	return payload
+}
+
+// sendFixDispatch fires eventType on repo for a failing PR. Returns the event
+// type if it was sent, or "" when disabled or on failure (which is only
+// logged; the PR comment already covers the hand-off).
+func sendFixDispatch(ctx context.Context, eventType string, pr *prView, repo string, number int, failureType string) string {
+	if eventType == "" {
+		return ""
+	}
+	payload := newFixDispatchPayload(pr, repo, number, failureType)
+	if err := Retryable(ctx, func() error {
+		return ghRepositoryDispatch(ctx, repo, eventType, payload)
+	}, retryCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "[dispatch] %s %s failed: %v\n", eventType, pr.URL, err)
+		return ""
+	}
+	return eventType
+}
+
+// ghRepositoryDispatch fires a repository_dispatch event on repo.
+func ghRepositoryDispatch(ctx context.Context, repo string, eventType string, payload any) error {
+	body, err := json.Marshal(map[string]any{
+		"event_type":     eventType,
+		"client_payload": payload,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = runGhInput(ctx, body, "api", "-X", "POST", "repos/"+repo+"/dispatches", "--input", "-")
+	return err
+}
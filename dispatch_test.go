@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewFixDispatchPayload(t *testing.T) {
+	pr := &prView{
+		URL:         "https://github.com/misty-step/app/pull/12",
+		BaseRefName: "main",
+		StatusCheckRollup: []statusRollupEntry{
+			{Typename: "CheckRun", Name: "golangci-lint", Status: "COMPLETED", Conclusion: "failure", DetailsURL: "https://github.com/misty-step/app/actions/runs/1/job/2"},
+			{Typename: "CheckRun", Name: "build", Status: "COMPLETED", Conclusion: "SUCCESS"},
+			{Typename: "StatusContext", Context: "lint/markdown", State: "FAILURE", TargetURL: "https://ci.example.com/3"},
+		},
+	}
+	payload := newFixDispatchPayload(pr, "misty-step/app", 12, "lint")
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	got := string(raw)
+	for _, want := range []string{
+		`"pr_url":"https://github.com/misty-step/app/pull/12"`,
+		`"repo":"misty-step/app"`,
+		`"number":12`,
+		`"base_ref":"main"`,
+		`"failure_type":"lint"`,
+		`{"name":"golangci-lint","conclusion":"FAILURE","details_url":"https://github.com/misty-step/app/actions/runs/1/job/2"}`,
+		`{"name":"lint/markdown","conclusion":"FAILURE","details_url":"https://ci.example.com/3"}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("payload missing %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `"build"`) {
+		t.Errorf("passing checks should not be in the payload: %s", got)
+	}
+}
+
+func TestNewFixDispatchPayload_noFailures(t *testing.T) {
+	payload := newFixDispatchPayload(&prView{URL: "u"}, "o/r", 1, "lint")
+	raw, _ := json.Marshal(payload)
+	if !strings.Contains(string(raw), `"failing_checks":[]`) {
+		t.Errorf("failing_checks should be an empty array, got %s", raw)
+	}
+}
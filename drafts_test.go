@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPromotableDraftAuthor(t *testing.T) {
+	opts := &runOptions{Kaylee: "kaylee-mistystep", PromoteDrafts: true}
+	if !promotableDraftAuthor(opts, "Kaylee-MistyStep") {
+		t.Error("kaylee's drafts should be promotable")
+	}
+	if promotableDraftAuthor(opts, "someone") {
+		t.Error("other authors' drafts should not be promotable")
+	}
+	opts.PromoteDrafts = false
+	if promotableDraftAuthor(opts, "kaylee-mistystep") {
+		t.Error("promotion is off without --promote-drafts")
+	}
+	opts = &runOptions{PromoteDrafts: true}
+	if promotableDraftAuthor(opts, "") {
+		t.Error("an empty --kaylee-login matches nobody")
+	}
+}
+
+func TestSelectPRsPromotableDrafts(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	mk := func(url string, author string) searchPR {
+		pr := searchPR{URL: url, UpdatedAt: now.Add(-time.Hour), IsDraft: true}
+		pr.Author.Login = author
+		pr.Repository.NameWithOwner = "misty-step/app"
+		return pr
+	}
+	prs := []searchPR{mk("bot", "kaylee-mistystep"), mk("human", "someone")}
+	opts := &runOptions{Kaylee: "kaylee-mistystep", authors: defaultAuthorPolicies("", 0, "kaylee-mistystep")}
+	if got := selectPRs(opts, prs, now); len(got) != 0 {
+		t.Errorf("without --promote-drafts selected %d drafts", len(got))
+	}
+	opts.PromoteDrafts = true
+	got := selectPRs(opts, prs, now)
+	if len(got) != 1 || got[0].URL != "bot" {
+		t.Errorf("selected %v; want only the bot draft", got)
+	}
+}
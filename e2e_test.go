@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The end-to-end tests run the pipeline against a fake gh executable: the
+// test binary itself, symlinked as "gh" at the front of PATH. When it's
+// started with fakeGhFixtureEnv set it answers that one gh command from the
+// fixture and exits instead of running tests. Each testdata/e2e/<case>.json
+// fixture is compared against testdata/e2e/<case>.golden.json; run
+//
+//	go test -run TestEndToEnd -update
+//
+// to rewrite the golden files after an intended change.
+
+const fakeGhFixtureEnv = "FAKE_GH_FIXTURE"
+
+var updateGolden = flag.Bool("update", false, "rewrite the end-to-end golden files")
+
+func TestMain(m *testing.M) {
+	if path := os.Getenv(fakeGhFixtureEnv); path != "" {
+		os.Exit(fakeGhMain(path, os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// e2eFixture is a pipeline run to replay: the run flags and the PRs the
+// fake gh serves, in `gh pr view --json` form.
+type e2eFixture struct {
+	Args []string  `json:"args"`
+	PRs  []*prView `json:"prs"`
+	// MergeErrors fails the merge mutation for these PR node IDs.
+	MergeErrors map[string]string `json:"mergeErrors"`
+}
+
+func loadE2EFixture(path string) (*e2eFixture, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fx e2eFixture
+	if err := json.Unmarshal(raw, &fx); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &fx, nil
+}
+
+// fakeGhMain answers one gh command from the fixture, like gh would: the
+// response on stdout, or the error on stderr with exit status 1.
+func fakeGhMain(path string, args []string) int {
+	fx, err := loadE2EFixture(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	f := newFakeGitHub(fx.PRs...)
+	for id, msg := range fx.MergeErrors {
+		f.mergeErrors[id] = errors.New(msg)
+	}
+	stdin, _ := io.ReadAll(os.Stdin)
+	out, err := f.Run(context.Background(), stdin, args...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	_, _ = os.Stdout.Write(out)
+	return 0
+}
+
+// installFakeGh puts a "gh" that serves fixture first on PATH.
+func installFakeGh(t *testing.T, fixture string) {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(exe, filepath.Join(dir, "gh")); err != nil {
+		t.Skipf("can't symlink the fake gh: %v", err)
+	}
+	abs, err := filepath.Abs(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv(fakeGhFixtureEnv, abs)
+}
+
+func TestEndToEnd(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "e2e", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases int
+	for _, fixture := range fixtures {
+		if strings.HasSuffix(fixture, ".golden.json") {
+			continue
+		}
+		cases++
+		name := strings.TrimSuffix(filepath.Base(fixture), ".json")
+		t.Run(name, func(t *testing.T) {
+			fx, err := loadE2EFixture(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			installFakeGh(t, fixture)
+
+			out, err := newPipeline(testPipelineOptions(t, fx.Args...)).Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			out.StartedAt = ""
+			got, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(fixture, ".json") + ".golden.json"
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("run output differs from %s (run with -update if intended)\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+	if cases == 0 {
+		t.Fatal("no fixtures in testdata/e2e")
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// SMTP settings for --email-to, read from the environment so credentials
+// stay out of the command line.
+const (
+	smtpHostEnv     = "SMTP_HOST"
+	smtpPortEnv     = "SMTP_PORT"
+	smtpUsernameEnv = "SMTP_USERNAME"
+	smtpPasswordEnv = "SMTP_PASSWORD"
+	smtpFromEnv     = "SMTP_FROM"
+)
+
+// smtpConfig is where and as whom report emails are sent.
+type smtpConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// smtpConfigFromEnv reads the SMTP settings. SMTP_HOST and SMTP_FROM are
+// required; the port defaults to 587 and auth is skipped without a username.
+func smtpConfigFromEnv() (smtpConfig, error) {
+	c := smtpConfig{
+		Host:     strings.TrimSpace(os.Getenv(smtpHostEnv)),
+		Port:     strings.TrimSpace(os.Getenv(smtpPortEnv)),
+		Username: strings.TrimSpace(os.Getenv(smtpUsernameEnv)),
+		Password: os.Getenv(smtpPasswordEnv),
+		From:     strings.TrimSpace(os.Getenv(smtpFromEnv)),
+	}
+	if c.Port == "" {
+		c.Port = "587"
+	}
+	if c.Host == "" || c.From == "" {
+		return c, fmt.Errorf("--email-to requires %s and %s", smtpHostEnv, smtpFromEnv)
+	}
+	return c, nil
+}
+
+// emailNotifier sends run summaries and alerts by email.
+type emailNotifier struct {
+	to  []string
+	cfg smtpConfig
+}
+
+func newEmailNotifier(o *runOptions) (Notifier, error) {
+	to := splitList(o.EmailTo)
+	if len(to) == 0 {
+		return nil, nil
+	}
+	cfg, err := smtpConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return &emailNotifier{to: to, cfg: cfg}, nil
+}
+
+// smtpSend delivers one message (replaced in tests).
+var smtpSend = sendSMTP
+
+// Post emails the run summary as HTML.
+func (n *emailNotifier) Post(ctx context.Context, out runOutput) error {
+	merged, commented, skipped, errs := summarize(out.Results)
+	subject := fmt.Sprintf("PR pipeline: %d merged, %d commented, %d errors", merged, commented, errs)
+	if out.DryRun {
+		subject += " (dry run)"
+	}
+	body, err := renderEmailHTML(out, merged, commented, skipped, errs)
+	if err != nil {
+		return err
+	}
+	return smtpSend(ctx, n.cfg, n.to, buildEmail(n.cfg.From, n.to, subject, body, time.Now()))
+}
+
+// Alert emails a one-line alert.
+func (n *emailNotifier) Alert(ctx context.Context, msg string) error {
+	body := "<p>PR pipeline error: " + template.HTMLEscapeString(msg) + "</p>"
+	return smtpSend(ctx, n.cfg, n.to, buildEmail(n.cfg.From, n.to, "PR pipeline error", body, time.Now()))
+}
+
+// buildEmail assembles an HTML message with its headers.
+func buildEmail(from string, to []string, subject string, html string, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(html, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// emailSection is a titled group of PR outcomes in the summary email.
+type emailSection struct {
+	Name    string
+	Results []prOutcome
+}
+
+var emailTemplate = template.Must(template.New("email").Parse(`<html><body style="font-family: sans-serif">
+<h2>PR pipeline run{{if .Out.DryRun}} (dry run){{end}}</h2>
+<p>Org: <code>{{.Out.Org}}</code> · started {{.Out.StartedAt}}</p>
+<table cellpadding="4">
+<tr><th align="left">Merged</th><td>{{.Merged}}</td></tr>
+<tr><th align="left">Commented</th><td>{{.Commented}}</td></tr>
+<tr><th align="left">Skipped</th><td>{{.Skipped}}</td></tr>
+<tr><th align="left">Errors</th><td>{{.Errors}}</td></tr>
+</table>
+{{if .Out.Error}}<p style="color: #e74c3c"><b>Run error:</b> {{.Out.Error}}</p>
+{{end}}{{if .Out.Aborted}}<p style="color: #e74c3c"><b>Run aborted:</b> {{.Out.Aborted}}</p>
+{{end}}{{if .Out.Frozen}}<p style="color: #e74c3c"><b>Frozen:</b> {{.Out.Frozen}}</p>
+{{end}}{{if not .Out.Results}}<p>No PRs selected.</p>
+{{end}}{{range .Sections}}{{if .Results}}<h3>{{.Name}} ({{len .Results}})</h3>
+<ul>
+{{range .Results}}<li><a href="{{.URL}}">{{if .Repo}}{{.Repo}}#{{.Number}}{{else}}{{.URL}}{{end}}</a> <code>{{.Action}}</code>{{if .Reason}} {{.Reason}}{{end}}</li>
+{{end}}</ul>
+{{end}}{{end}}</body></html>
+`))
+
+// renderEmailHTML renders the run summary email, with PRs grouped the same
+// way as the Discord summary.
+func renderEmailHTML(out runOutput, merged int, commented int, skipped int, errs int) (string, error) {
+	sections := []emailSection{{Name: "Merged"}, {Name: "Commented"}, {Name: "Errors"}, {Name: "Other actions"}, {Name: "Skipped"}}
+	index := map[string]int{"merged": 0, "commented": 1, "error": 2, "": 3, "skipped": 4}
+	for _, r := range out.Results {
+		i := index[outcomeBucket(r.Action)]
+		sections[i].Results = append(sections[i].Results, r)
+	}
+	var b strings.Builder
+	err := emailTemplate.Execute(&b, map[string]any{
+		"Out":       out,
+		"Merged":    merged,
+		"Commented": commented,
+		"Skipped":   skipped,
+		"Errors":    errs,
+		"Sections":  sections,
+	})
+	return b.String(), err
+}
+
+// sendSMTP delivers msg over SMTP, upgrading to TLS with STARTTLS when the
+// server offers it (port 465 connects over TLS from the start).
+func sendSMTP(ctx context.Context, cfg smtpConfig, to []string, msg []byte) error {
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	tlsConfig := &tls.Config{ServerName: cfg.Host}
+	var conn net.Conn
+	var err error
+	if cfg.Port == "465" {
+		d := &tls.Dialer{Config: tlsConfig}
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp dial %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("smtp %s: %w", addr, err)
+	}
+	defer func() { _ = c.Close() }()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(cfg.From); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	return c.Quit()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSMTPConfigFromEnv(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("SMTP_FROM", "")
+	t.Setenv("SMTP_PORT", "")
+	if _, err := smtpConfigFromEnv(); err == nil {
+		t.Error("expected an error without SMTP_HOST and SMTP_FROM")
+	}
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_FROM", "pipeline@example.com")
+	cfg, err := smtpConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "587" {
+		t.Errorf("port = %q; want default 587", cfg.Port)
+	}
+}
+
+func TestRenderEmailHTML(t *testing.T) {
+	out := runOutput{Ok: true, Org: "misty-step", StartedAt: "2026-01-02T03:04:05Z", Results: []prOutcome{
+		{Repo: "misty-step/a", Number: 1, URL: "https://github.com/misty-step/a/pull/1", Action: "merged"},
+		{Repo: "misty-step/b", Number: 2, URL: "https://github.com/misty-step/b/pull/2", Action: "error", Reason: "<boom>"},
+	}}
+	html, err := renderEmailHTML(out, 1, 0, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<h3>Merged (1)</h3>",
+		"<h3>Errors (1)</h3>",
+		`<a href="https://github.com/misty-step/a/pull/1">misty-step/a#1</a>`,
+		"&lt;boom&gt;",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("missing %q in:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "Skipped (") {
+		t.Errorf("empty sections should be left out:\n%s", html)
+	}
+}
+
+func TestEmailNotifierPost(t *testing.T) {
+	var sent []string
+	old := smtpSend
+	smtpSend = func(ctx context.Context, cfg smtpConfig, to []string, msg []byte) error {
+		sent = append(sent, string(msg))
+		return nil
+	}
+	t.Cleanup(func() { smtpSend = old })
+
+	n := &emailNotifier{to: []string{"team@example.com"}, cfg: smtpConfig{From: "pipeline@example.com"}}
+	if err := n.Post(context.Background(), runOutput{Ok: true, Results: []prOutcome{{Action: "merged"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("sent %d emails; want 1", len(sent))
+	}
+	for _, want := range []string{"To: team@example.com\r\n", "Subject: PR pipeline: 1 merged, 0 commented, 0 errors\r\n", "Content-Type: text/html"} {
+		if !strings.Contains(sent[0], want) {
+			t.Errorf("missing %q in:\n%s", want, sent[0])
+		}
+	}
+}
+
+// fakeSMTPServer accepts one session without STARTTLS or auth and returns
+// the DATA it received.
+func fakeSMTPServer(t *testing.T) (addr string, data <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake ESMTP")
+		var body strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					body.WriteString(l)
+				}
+				ch <- body.String()
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestSendSMTP(t *testing.T) {
+	addr, data := fakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	cfg := smtpConfig{Host: host, Port: port, From: "pipeline@example.com"}
+	msg := buildEmail(cfg.From, []string{"team@example.com"}, "hello", "<p>hi</p>", time.Now())
+	if err := sendSMTP(context.Background(), cfg, []string{"team@example.com"}, msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	select {
+	case got := <-data:
+		if !strings.Contains(got, "Subject: hello") || !strings.Contains(got, "<p>hi</p>") {
+			t.Errorf("unexpected DATA:\n%s", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server never got DATA")
+	}
+}
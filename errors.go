@@ -1,9 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ErrorKind classifies errors as transient, permanent, or unknown.
@@ -30,6 +41,9 @@ func (k ErrorKind) String() string {
 }
 
 // classifies the error based on the error message and type.
+// GitHub's own verdict comes first: the HTTP status and GraphQL error types
+// of a failed gh call. Message heuristics are the fallback for errors that
+// carry neither.
 // It's a best-effort classification - unknown errors default to Transient
 // to avoid skipping potentially recoverable errors.
 func classifyError(err error) ErrorKind {
@@ -37,23 +51,36 @@ func classifyError(err error) ErrorKind {
 		return Unknown
 	}
 
-	msg := strings.ToLower(err.Error())
+	// The run was cancelled or hit --timeout; nothing will succeed on retry.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return Permanent
+	}
+
+	if kind := classifyGitHubError(err); kind != Unknown {
+		return kind
+	}
+
+	// A rate limit GitHub reported no status for.
+	if isRateLimitError(err) {
+		return Transient
+	}
+
+	msg := strings.ToLower(errorOutput(err))
 
 	// Permanent errors - don't retry these.
 	permanentIndicators := []string{
 		"not found",
-		"404",
 		" archived ",
 		"is archived",
 		"read-only",
 		"issue is locked",
 		"issues are disabled",
 		"permission denied",
-		"403",
 		"unauthorized",
 		"already merged",
 		"merge conflict",
 		"closed pull request",
+		"head branch was modified", // expectedHeadOid mismatch
 		"ref not found",
 		"no such file or directory", // gh CLI not installed
 		"command not found",
@@ -75,10 +102,6 @@ func classifyError(err error) ErrorKind {
 		"timeout",
 		"temporary failure",
 		"server error",
-		"500",
-		"502",
-		"503",
-		"504",
 		"connection refused",
 		"connection reset",
 		"network",
@@ -100,6 +123,128 @@ func classifyError(err error) ErrorKind {
 	return Transient
 }
 
+// cmdError is a failed command with what it printed, so GitHub's error
+// details can be read from gh's output rather than guessed from the message.
+// headers are the response's, for the `gh api` calls they're asked for on.
+type cmdError struct {
+	msg     string
+	stdout  []byte
+	stderr  string
+	headers http.Header
+}
+
+func (e *cmdError) Error() string {
+	return e.msg
+}
+
+// wantsResponseHeaders reports whether a gh call with args should be run
+// with --include, so a failure carries GitHub's rate limit headers: any
+// `gh api` call but a paginated one (which prints headers between pages)
+// or one already asking for them.
+func wantsResponseHeaders(args []string) bool {
+	if len(args) == 0 || args[0] != "api" {
+		return false
+	}
+	for _, a := range args[1:] {
+		switch a {
+		case "--paginate", "--include", "-i":
+			return false
+		}
+	}
+	return true
+}
+
+// splitResponseHeaders splits what `gh api --include` printed into the
+// response headers and the body. Output without a status line (gh failed
+// before a response) is all body.
+func splitResponseHeaders(out []byte) (http.Header, []byte) {
+	if !bytes.HasPrefix(out, []byte("HTTP/")) {
+		return nil, out
+	}
+	src := bytes.NewReader(out)
+	br := bufio.NewReader(src)
+	r := textproto.NewReader(br)
+	if _, err := r.ReadLine(); err != nil {
+		return nil, out
+	}
+	h, err := r.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, out
+	}
+	return http.Header(h), out[len(out)-br.Buffered()-src.Len():]
+}
+
+// httpStatusRe matches gh's "HTTP 404" in "gh: Not Found (HTTP 404)".
+var httpStatusRe = regexp.MustCompile(`\bHTTP (\d{3})\b`)
+
+// githubErrorBody is the JSON error gh prints to stdout for a failed API
+// call: a REST error ("message" and, on newer responses, "status") or a
+// GraphQL response with typed errors.
+type githubErrorBody struct {
+	Message string          `json:"message"`
+	Status  json.RawMessage `json:"status"`
+	Errors  []struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// githubErrorDetails returns the HTTP status and GraphQL error types of a
+// failed gh call (0 and nil when it reported neither). A command's JSON
+// error body and stderr are used when available; other errors (fakes,
+// replays) are read for gh's "(HTTP 404)" suffix.
+func githubErrorDetails(err error) (status int, types []string) {
+	text := err.Error()
+	var ce *cmdError
+	if errors.As(err, &ce) {
+		text = ce.stderr
+		var body githubErrorBody
+		if json.Unmarshal(ce.stdout, &body) == nil {
+			for _, e := range body.Errors {
+				if e.Type != "" {
+					types = append(types, e.Type)
+				}
+			}
+			if s, convErr := strconv.Atoi(strings.Trim(string(body.Status), `"`)); convErr == nil {
+				status = s
+			}
+		}
+	}
+	if status == 0 {
+		if m := httpStatusRe.FindStringSubmatch(text); m != nil {
+			status, _ = strconv.Atoi(m[1])
+		}
+	}
+	return status, types
+}
+
+// classifyGitHubError classifies on the status and GraphQL error types
+// GitHub reported, or returns Unknown when it reported neither.
+func classifyGitHubError(err error) ErrorKind {
+	status, types := githubErrorDetails(err)
+	for _, t := range types {
+		switch t {
+		case "RATE_LIMITED", "SERVICE_UNAVAILABLE", "INTERNAL", "TIMEOUT":
+			return Transient
+		case "NOT_FOUND", "FORBIDDEN", "INSUFFICIENT_SCOPES", "UNPROCESSABLE":
+			return Permanent
+		}
+	}
+	switch {
+	case status == 0:
+		return Unknown
+	case status == 403 && isRateLimitError(err):
+		// Secondary and exhausted primary limits say 403 but clear on
+		// their own.
+		return Transient
+	case status == 408 || status == 429 || status >= 500:
+		return Transient
+	case status >= 400:
+		return Permanent
+	}
+	return Unknown
+}
+
 // IsTransient returns true if the error is classified as transient.
 func IsTransient(err error) bool {
 	return classifyError(err) == Transient
@@ -182,91 +327,151 @@ type RetryConfig struct {
 	MaxAttempts int
 	BaseDelay   int // milliseconds
 	MaxDelay    int // milliseconds
+	// MaxRetryAfter caps how long we'll honor a rate-limit Retry-After
+	// (milliseconds). Longer waits give up instead of wedging the run.
+	MaxRetryAfter int
 }
 
 var defaultRetryConfig = RetryConfig{
-	MaxAttempts: 3,
-	BaseDelay:   500,
-	MaxDelay:    5000,
+	MaxAttempts:   3,
+	BaseDelay:     500,
+	MaxDelay:      5000,
+	MaxRetryAfter: 120000,
 }
 
-// Retryable runs the given function with retry logic for transient errors.
-// It returns the last error if all attempts fail or if the error is permanent.
-func Retryable(fn func() error, cfg ...RetryConfig) error {
-	config := defaultRetryConfig
-	if len(cfg) > 0 {
-		config = cfg[0]
+// retrySleep waits between attempts, returning early if ctx ends
+// (replaced in tests).
+var retrySleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
 	}
+}
 
-	var lastErr error
-	for attempt := 1; attempt <= config.MaxAttempts; attempt++ {
-		err := fn()
-		if err == nil {
-			return nil
+// secondaryRateLimitWait is GitHub's documented minimum wait after a
+// secondary rate limit response that carries no Retry-After header.
+const secondaryRateLimitWait = time.Minute
+
+// errorOutput is the text to classify err by: what a failed command
+// printed, so its arguments (PR bodies, comments) can't be mistaken for
+// GitHub's answer, or the message for other errors.
+func errorOutput(err error) string {
+	var ce *cmdError
+	if errors.As(err, &ce) {
+		if out := strings.TrimSpace(ce.stderr + "\n" + string(ce.stdout)); out != "" {
+			return out
 		}
+	}
+	return err.Error()
+}
 
-		kind := classifyError(err)
-
-		if kind == Permanent {
-			// Don't retry permanent errors.
-			return err
+// isRateLimitError reports whether the error is a GitHub primary or
+// secondary rate limit: a 429 or RATE_LIMITED error, or a 403 (or
+// status-less failure) with Retry-After, no quota remaining, or output
+// that says so. These are transient even though they may carry a
+// 403 status.
+func isRateLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	status, types := githubErrorDetails(err)
+	if status == 429 || slices.Contains(types, "RATE_LIMITED") {
+		return true
+	}
+	if status != 0 && status != 403 {
+		return false
+	}
+	var ce *cmdError
+	if errors.As(err, &ce) && (ce.headers.Get("Retry-After") != "" || ce.headers.Get("X-Ratelimit-Remaining") == "0") {
+		return true
+	}
+	msg := strings.ToLower(errorOutput(err))
+	indicators := []string{
+		"rate limit",
+		"abuse detection",
+		"too many requests",
+	}
+	for _, indicator := range indicators {
+		if strings.Contains(msg, indicator) {
+			return true
 		}
+	}
+	return false
+}
 
-		lastErr = err
-
-		// Check if we should retry.
-		if attempt < config.MaxAttempts {
-			// Exponential backoff: base * 2^(attempt-1), capped at maxDelay.
-			delay := config.BaseDelay * (1 << (attempt - 1))
-			if delay > config.MaxDelay {
-				delay = config.MaxDelay
+// retryAfter returns how long GitHub asked us to wait before retrying a
+// rate-limited request, from the Retry-After or x-ratelimit-reset header of
+// the failed response (see wantsResponseHeaders). Secondary limits without
+// a header get a one-minute wait. Returns 0 for errors that aren't rate
+// limits.
+func retryAfter(err error, now time.Time) time.Duration {
+	if !isRateLimitError(err) {
+		return 0
+	}
+	var ce *cmdError
+	if errors.As(err, &ce) && ce.headers != nil {
+		if secs, convErr := strconv.Atoi(ce.headers.Get("Retry-After")); convErr == nil {
+			return time.Duration(secs) * time.Second
+		}
+		if ce.headers.Get("X-Ratelimit-Remaining") == "0" {
+			if epoch, convErr := strconv.ParseInt(ce.headers.Get("X-Ratelimit-Reset"), 10, 64); convErr == nil {
+				return max(0, time.Unix(epoch, 0).Sub(now))
 			}
-			// Simple retry after delay - in production, consider using a proper backoff library.
-			// For now, we just return the error to let the caller decide.
-			// Actually, let's just continue - this is a simple implementation.
-			_ = delay // Could implement actual sleep here if needed
 		}
 	}
-
-	return lastErr
+	if strings.Contains(strings.ToLower(err.Error()), "secondary rate limit") {
+		return secondaryRateLimitWait
+	}
+	return 0
 }
 
-// ClassifyAndRetry attempts the operation, classifying errors and retrying transient ones.
-// Returns (result, error) where error is nil on success, or permanent/last transient error on failure.
-func ClassifyAndRetry[T any](fn func() (T, error)) (T, error) {
-	var zero T
-	var lastErr error
-
-	for attempt := 1; attempt <= defaultRetryConfig.MaxAttempts; attempt++ {
-		result, err := fn()
-		if err == nil {
-			return result, nil
-		}
-
-		kind := classifyError(err)
-
-		if kind == Permanent {
-			// Don't retry permanent errors.
-			return zero, err
+// retryDelay returns how long to wait before the next attempt, and false if
+// the error asks for a longer wait than the config allows.
+func retryDelay(err error, attempt int, config RetryConfig) (time.Duration, bool) {
+	if wait := retryAfter(err, time.Now()); wait > 0 {
+		if config.MaxRetryAfter > 0 && wait > time.Duration(config.MaxRetryAfter)*time.Millisecond {
+			return 0, false
 		}
+		return wait, true
+	}
+	// Exponential backoff: base * 2^(attempt-1), capped at maxDelay.
+	delay := config.BaseDelay * (1 << (attempt - 1))
+	if config.MaxDelay > 0 && delay > config.MaxDelay {
+		delay = config.MaxDelay
+	}
+	return time.Duration(delay) * time.Millisecond, true
+}
 
-		lastErr = err
-
-		// Transient error - will retry if attempts remain.
-		// In a real implementation, we'd add backoff here.
-		if attempt < defaultRetryConfig.MaxAttempts {
-			// Backoff could be added here; skipping for now as retry is handled by re-execution
-			continue
-		}
+// Retryable runs the given function with retry logic for transient errors.
+// It returns the last error if all attempts fail or if the error is permanent.
+func Retryable(ctx context.Context, fn func() error, cfg ...RetryConfig) error {
+	config := defaultRetryConfig
+	if len(cfg) > 0 {
+		config = cfg[0]
 	}
+	_, err := RetryableWithResult(ctx, func() (struct{}, error) {
+		return struct{}{}, fn()
+	}, config)
+	return err
+}
 
-	return zero, lastErr
+// ClassifyAndRetry attempts the operation, classifying errors and retrying transient ones.
+// Returns (result, error) where error is nil on success, or permanent/last transient error on failure.
+func ClassifyAndRetry[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	return RetryableWithResult(ctx, fn, defaultRetryConfig)
 }
 
 // RetryableWithResult wraps a function that returns a result and error,
 // retrying on transient errors up to MaxAttempts times.
+// Waits between attempts with exponential backoff, or for as long as a
+// rate-limit response asked (up to MaxRetryAfter). A wait cut short by ctx
+// ending returns the last error.
 // Returns the result on success, or the final error (which may be permanent).
-func RetryableWithResult[T any](fn func() (T, error), cfg RetryConfig) (T, error) {
+func RetryableWithResult[T any](ctx context.Context, fn func() (T, error), cfg RetryConfig) (T, error) {
 	var zero T
 	var lastErr error
 
@@ -285,9 +490,17 @@ func RetryableWithResult[T any](fn func() (T, error), cfg RetryConfig) (T, error
 
 		lastErr = err
 
-		// Transient error - will retry if attempts remain.
-		// Note: In production, add sleep here for backoff.
-		_ = attempt < cfg.MaxAttempts // Silence linter; backoff can be added here
+		// Transient error - wait and retry if attempts remain.
+		if attempt < cfg.MaxAttempts {
+			delay, ok := retryDelay(err, attempt, cfg)
+			if !ok {
+				// Rate limit resets too far out; don't block the run waiting.
+				return zero, err
+			}
+			if retrySleep(ctx, delay) != nil {
+				return zero, err
+			}
+		}
 	}
 
 	return zero, lastErr
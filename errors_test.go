@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// noSleep replaces retrySleep for the duration of a test and records waits.
+func noSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	old := retrySleep
+	retrySleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	t.Cleanup(func() { retrySleep = old })
+	return &waits
+}
+
+func TestClassifyError_rateLimits(t *testing.T) {
+	tests := []error{
+		errors.New("gh: You have exceeded a secondary rate limit. Please wait a few minutes before you try again. (HTTP 403)"),
+		errors.New("HTTP 429: Too Many Requests"),
+		errors.New("API rate limit exceeded for user ID 1. (HTTP 403)"),
+	}
+	for _, err := range tests {
+		if kind := classifyError(err); kind != Transient {
+			t.Errorf("classifyError(%q) = %s; want transient", err, kind)
+		}
+	}
+	if kind := classifyError(errors.New("HTTP 403: Resource not accessible by integration")); kind != Permanent {
+		t.Errorf("plain 403 should stay permanent, got %s", kind)
+	}
+
+	// A rate limit mentioned in the command's arguments isn't one.
+	quoted := &cmdError{
+		msg:    "gh pr comment https://github.com/o/r/pull/1 --body hit the rate limit again: gh: Not Found (HTTP 404)",
+		stderr: "gh: Not Found (HTTP 404)",
+	}
+	if isRateLimitError(quoted) {
+		t.Errorf("isRateLimitError(%q) = true; want false", quoted)
+	}
+	if kind := classifyError(quoted); kind != Permanent {
+		t.Errorf("classifyError(%q) = %s; want permanent", quoted, kind)
+	}
+	if !isRateLimitError(apiError("gh: Forbidden (HTTP 403)", "X-Ratelimit-Remaining", "0")) {
+		t.Error("403 with no quota remaining should be a rate limit")
+	}
+}
+
+func TestClassifyError_githubStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{
+			// A PR number is not a status code.
+			"number in message",
+			errors.New("gh pr comment https://github.com/o/r/pull/4030 --body x: connection reset by peer"),
+			Transient,
+		},
+		{
+			"status in stderr, not args",
+			&cmdError{msg: "gh pr comment https://github.com/o/r/pull/1 --body see HTTP 404: gh: Bad Gateway (HTTP 502)", stderr: "gh: Bad Gateway (HTTP 502)"},
+			Transient,
+		},
+		{
+			"REST error body",
+			&cmdError{msg: "gh api repos/o/r: gh: Server Error", stdout: []byte(`{"message":"Server Error","status":"503"}`), stderr: "gh: Server Error"},
+			Transient,
+		},
+		{
+			"validation failure",
+			&cmdError{msg: "gh api repos/o/r/pulls: gh: Validation Failed (HTTP 422)", stderr: "gh: Validation Failed (HTTP 422)"},
+			Permanent,
+		},
+		{
+			"GraphQL NOT_FOUND",
+			&cmdError{
+				msg:    "gh api graphql: gh: Could not resolve to a Repository with the name 'o/gone'.",
+				stdout: []byte(`{"data":{"repository":null},"errors":[{"type":"NOT_FOUND","message":"Could not resolve to a Repository with the name 'o/gone'."}]}`),
+			},
+			Permanent,
+		},
+		{
+			"GraphQL RATE_LIMITED",
+			&cmdError{msg: "gh api graphql: gh: API rate limit exceeded", stdout: []byte(`{"errors":[{"type":"RATE_LIMITED","message":"API rate limit exceeded"}]}`)},
+			Transient,
+		},
+		{
+			"GraphQL FORBIDDEN",
+			&cmdError{msg: "gh api graphql: gh: Resource not accessible by integration", stdout: []byte(`{"errors":[{"type":"FORBIDDEN","message":"Resource not accessible by integration"}]}`)},
+			Permanent,
+		},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("%s: classifyError = %s; want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// apiError is a failed `gh api --include` call: gh's stderr and the
+// response headers, given as name/value pairs.
+func apiError(stderr string, header ...string) *cmdError {
+	h := http.Header{}
+	for i := 0; i+1 < len(header); i += 2 {
+		h.Set(header[i], header[i+1])
+	}
+	return &cmdError{msg: "gh api x: " + stderr, stderr: stderr, headers: h}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	reset := func(d int64) string { return strconv.FormatInt(now.Unix()+d, 10) }
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{"not a rate limit", errors.New("connection reset"), 0},
+		{"retry-after header", apiError("gh: too many requests (HTTP 429)", "Retry-After", "30"), 30 * time.Second},
+		{"reset header", apiError("gh: API rate limit exceeded (HTTP 403)", "X-Ratelimit-Remaining", "0", "X-Ratelimit-Reset", reset(90)), 90 * time.Second},
+		{"reset in the past", apiError("gh: API rate limit exceeded (HTTP 403)", "X-Ratelimit-Remaining", "0", "X-Ratelimit-Reset", reset(-5)), 0},
+		{"reset with quota left", apiError("gh: too many requests (HTTP 429)", "X-Ratelimit-Remaining", "4000", "X-Ratelimit-Reset", reset(90)), 0},
+		{"headers in the message only", errors.New("HTTP 429: too many requests\nRetry-After: 30"), 0},
+		{"secondary without header", errors.New("You have exceeded a secondary rate limit (HTTP 403)"), secondaryRateLimitWait},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.err, now); got != tt.want {
+				t.Errorf("retryAfter() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitResponseHeaders(t *testing.T) {
+	out := "HTTP/2.0 429 Too Many Requests\nRetry-After: 7\r\nX-Ratelimit-Remaining: 0\r\n\r\n{\"message\":\"slow down\"}"
+	h, body := splitResponseHeaders([]byte(out))
+	if h.Get("Retry-After") != "7" || h.Get("X-Ratelimit-Remaining") != "0" {
+		t.Errorf("headers = %v", h)
+	}
+	if string(body) != `{"message":"slow down"}` {
+		t.Errorf("body = %q", body)
+	}
+	if h, body := splitResponseHeaders([]byte(`{"a":1}`)); h != nil || string(body) != `{"a":1}` {
+		t.Errorf("no status line: headers %v, body %q", h, body)
+	}
+
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"api", "repos/o/r"}, true},
+		{[]string{"api", "graphql", "-f", "query=q"}, true},
+		{[]string{"api", "--paginate", "repos/o/r/pulls"}, false},
+		{[]string{"api", "--include", "user"}, false},
+		{[]string{"pr", "view", "1"}, false},
+	}
+	for _, tt := range tests {
+		if got := wantsResponseHeaders(tt.args); got != tt.want {
+			t.Errorf("wantsResponseHeaders(%v) = %v; want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRetrySleep_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	start := time.Now()
+	if err := retrySleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("retrySleep = %v; want context.Canceled", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("retrySleep waited out the delay after cancel")
+	}
+}
+
+func TestRetryableWithResult_backoff(t *testing.T) {
+	waits := noSleep(t)
+	calls := 0
+	_, err := RetryableWithResult(t.Context(), func() (int, error) {
+		calls++
+		return 0, errors.New("connection reset by peer")
+	}, RetryConfig{MaxAttempts: 3, BaseDelay: 100, MaxDelay: 150})
+	if err == nil {
+		t.Fatal("expected error after exhausting attempts")
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+	want := []time.Duration{100 * time.Millisecond, 150 * time.Millisecond}
+	if fmt.Sprint(*waits) != fmt.Sprint(want) {
+		t.Errorf("waits = %v; want %v", *waits, want)
+	}
+}
+
+func TestRetryableWithResult_honorsRetryAfter(t *testing.T) {
+	waits := noSleep(t)
+	calls := 0
+	got, err := RetryableWithResult(t.Context(), func() (string, error) {
+		calls++
+		if calls == 1 {
+			return "", apiError("gh: slow down (HTTP 429)", "Retry-After", "7")
+		}
+		return "ok", nil
+	}, retryCfg)
+	if err != nil || got != "ok" {
+		t.Fatalf("expected success on retry, got %q, %v", got, err)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 7*time.Second {
+		t.Errorf("expected a single 7s wait, got %v", *waits)
+	}
+}
+
+func TestRetryableWithResult_retryAfterTooLong(t *testing.T) {
+	waits := noSleep(t)
+	calls := 0
+	_, err := RetryableWithResult(t.Context(), func() (int, error) {
+		calls++
+		return 0, apiError("gh: slow down (HTTP 429)", "Retry-After", "3600")
+	}, retryCfg)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 || len(*waits) != 0 {
+		t.Errorf("expected to give up immediately, got %d calls and waits %v", calls, *waits)
+	}
+}
+
+func TestRetryable_permanentNotRetried(t *testing.T) {
+	waits := noSleep(t)
+	calls := 0
+	err := Retryable(t.Context(), func() error {
+		calls++
+		return errors.New("HTTP 404: Not Found")
+	})
+	if err == nil || calls != 1 || len(*waits) != 0 {
+		t.Errorf("permanent errors must not retry: err=%v calls=%d waits=%v", err, calls, *waits)
+	}
+}
+
+func TestClassifyError_contextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := fmt.Errorf("gh pr view: %w", ctx.Err())
+	if !IsPermanent(err) {
+		t.Errorf("cancelled run should be permanent, got %s", classifyError(err))
+	}
+}
+
+func TestRunCmd_timeouts(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+	old := callTimeout
+	t.Cleanup(func() { callTimeout = old })
+
+	t.Run("per-call timeout is transient", func(t *testing.T) {
+		callTimeout = 50 * time.Millisecond
+		_, err := runCmd(context.Background(), "sleep", "5")
+		if err == nil || !strings.Contains(err.Error(), "timed out after") {
+			t.Fatalf("expected per-call timeout, got %v", err)
+		}
+		if !IsTransient(err) {
+			t.Errorf("per-call timeout should be transient")
+		}
+	})
+
+	t.Run("run deadline is permanent", func(t *testing.T) {
+		callTimeout = 0
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := runCmd(ctx, "sleep", "5")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+		if !IsPermanent(err) {
+			t.Errorf("run deadline should be permanent")
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// blockedStreakTTL is how long a PR missing from the runs keeps its streak;
+// past it the PR is assumed merged or closed and forgotten.
+const blockedStreakTTL = 7 * 24 * time.Hour
+
+// blockedStreak is how many consecutive runs a PR has been blocked on
+// Reason.
+type blockedStreak struct {
+	Reason    string `json:"reason"`
+	Runs      int    `json:"runs"`
+	Since     string `json:"since"`
+	LastSeen  string `json:"lastSeen"`
+	Escalated bool   `json:"escalated,omitempty"`
+}
+
+// blockedStreaksPath returns where the streaks are kept, beside the dedup
+// state file.
+func blockedStreaksPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "blocked-prs.json")
+}
+
+// blockerReason returns what blocked the PR this run. An empty reason
+// means the PR made progress (or errored, which is alerted separately) and
+// its streak ends. ok is false for skips that say nothing about the PR
+// itself (a timeout, the action budget, a dry run), which leave the streak
+// as it was.
+func blockerReason(r prOutcome) (reason string, ok bool) {
+	switch r.Action {
+	case "merged", "enqueued", "auto_merge_enabled", "branch_updated", "conflict_resolved", "rebased",
+		"marked_ready", "closed_stale", "review_dismissed", "error":
+		return "", true
+	case "skipped":
+		switch {
+		case r.alreadyCommented():
+			// Already told the author; still the same blocker.
+			return r.blocker(), true
+		case r.ReasonCode == reasonReviewAlreadyRequested:
+			return string(reasonReviewRequired), true
+		case r.ReasonCode == reasonUnchangedSinceLastRun:
+			// Skipped unseen; the detail is the blocker it had last time.
+			return r.ReasonDetail, r.ReasonDetail != ""
+		}
+		return "", false
+	}
+	// Comments and dispatches (lint, test, review, CI rerun) all name the
+	// blocker they respond to.
+	reason = r.blocker()
+	return reason, reason != ""
+}
+
+var discordIDRe = regexp.MustCompile(`^\d{17,20}$`)
+
+// escalationMention turns --escalate-mention ("role:<id>", "user:<id>", or
+// a bare user ID) into Discord mention markup.
+func escalationMention(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	kind, id, ok := strings.Cut(raw, ":")
+	if !ok {
+		kind, id = "user", raw
+	}
+	if !discordIDRe.MatchString(id) {
+		return "", fmt.Errorf("--escalate-mention %q: %q is not a Discord ID", raw, id)
+	}
+	switch kind {
+	case "role":
+		return "<@&" + id + ">", nil
+	case "user":
+		return "<@" + id + ">", nil
+	}
+	return "", fmt.Errorf("--escalate-mention %q: want role:<id> or user:<id>", raw)
+}
+
+// trackBlockedPRs updates the saved streaks at path with the run's
+// results and returns the streaks by PR URL. A PR's streak grows while it
+// stays blocked on the same reason and starts over when the reason
+// changes; progress ends it.
+func trackBlockedPRs(path string, results []prOutcome, now time.Time) map[string]*blockedStreak {
+	streaks := map[string]*blockedStreak{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &streaks); err != nil {
+			fmt.Fprintf(os.Stderr, "[escalate] ignoring unreadable %s: %v\n", path, err)
+			streaks = map[string]*blockedStreak{}
+		}
+	}
+	stamp := now.UTC().Format(time.RFC3339)
+	for _, r := range results {
+		reason, ok := blockerReason(r)
+		if !ok {
+			continue
+		}
+		if reason == "" {
+			delete(streaks, r.URL)
+			continue
+		}
+		s := streaks[r.URL]
+		if s == nil || s.Reason != reason {
+			s = &blockedStreak{Reason: reason, Since: stamp}
+			streaks[r.URL] = s
+		}
+		s.Runs++
+		s.LastSeen = stamp
+	}
+	for url, s := range streaks {
+		if seen, err := time.Parse(time.RFC3339, s.LastSeen); err != nil || now.Sub(seen) > blockedStreakTTL {
+			delete(streaks, url)
+		}
+	}
+	return streaks
+}
+
+// saveBlockedStreaks writes the streaks to path, logging failures.
+func saveBlockedStreaks(path string, streaks map[string]*blockedStreak) {
+	data, err := json.MarshalIndent(streaks, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[escalate] failed to save %s: %v\n", path, err)
+	}
+}
+
+// escalateBlockedPRs records how long each PR in results has been blocked
+// (BlockedRuns) and, once a PR reaches --escalate-after runs on the same
+// reason, sends one escalation alert through n mentioning
+// --escalate-mention. A PR is escalated again only after its blocker
+// changes or clears.
+func escalateBlockedPRs(ctx context.Context, n Notifier, opts *runOptions, results []prOutcome, now time.Time) {
+	path := blockedStreaksPath(resolveStatePath(opts.StateFile))
+	streaks := trackBlockedPRs(path, results, now)
+	for i, r := range results {
+		s := streaks[r.URL]
+		if s == nil {
+			continue
+		}
+		if reason, _ := blockerReason(r); reason != s.Reason {
+			continue // not counted this run
+		}
+		results[i].BlockedRuns = s.Runs
+		if s.Runs < opts.EscalateAfter || s.Escalated {
+			continue
+		}
+		msg := fmt.Sprintf("🚨 Escalation: PR %s (%s#%d) has been blocked on `%s` for %d consecutive runs (since %s) and needs a human.",
+			r.URL, r.Repo, r.Number, s.Reason, s.Runs, s.Since)
+		if opts.escalateMention != "" {
+			msg = opts.escalateMention + " " + msg
+		}
+		fmt.Fprintf(os.Stderr, "[escalate] %s\n", msg)
+		if notifyAlert(ctx, n, msg) {
+			s.Escalated = true
+		}
+	}
+	saveBlockedStreaks(path, streaks)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscalationMention(t *testing.T) {
+	for raw, want := range map[string]string{
+		"":                           "",
+		"role:123456789012345678":    "<@&123456789012345678>",
+		"user:123456789012345678":    "<@123456789012345678>",
+		" 123456789012345678 ":       "<@123456789012345678>",
+		"channel:123456789012345678": "error",
+		"role:ops":                   "error",
+	} {
+		got, err := escalationMention(raw)
+		if err != nil {
+			got = "error"
+		}
+		if got != want {
+			t.Errorf("escalationMention(%q) = %q (%v); want %q", raw, got, err, want)
+		}
+	}
+}
+
+func TestTrackBlockedPRs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocked-prs.json")
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	run := func(results ...prOutcome) map[string]*blockedStreak {
+		t.Helper()
+		for i := range results {
+			results[i] = results[i].withReasonCode()
+		}
+		streaks := trackBlockedPRs(path, results, now)
+		saveBlockedStreaks(path, streaks)
+		now = now.Add(time.Hour)
+		return streaks
+	}
+	run(prOutcome{URL: "a", Action: "commented", Reason: "checks_failure"}, prOutcome{URL: "b", Action: "commented", Reason: "mergeable_conflicting"})
+	s := run(prOutcome{URL: "a", Action: "skipped", Reason: "checks_failure_already_commented"}, prOutcome{URL: "b", Action: "conflict_resolved", Reason: "mergeable_conflicting"})
+	if s["a"] == nil || s["a"].Runs != 2 || s["a"].Since != "2025-01-15T12:00:00Z" {
+		t.Errorf("a after 2 runs = %+v; want a 2-run streak since the first", s["a"])
+	}
+	if s["b"] != nil {
+		t.Errorf("b = %+v; an unblocked PR should drop its streak", s["b"])
+	}
+
+	s = run(prOutcome{URL: "a", Action: "commented", Reason: "review_changes_requested"})
+	if s["a"].Runs != 1 || s["a"].Reason != "review_changes_requested" {
+		t.Errorf("a after a new reason = %+v; want a fresh streak", s["a"])
+	}
+
+	// A PR skipped as unchanged is still blocked on the same reason.
+	s = run(prOutcome{URL: "a", Action: "skipped", Reason: "unchanged_since_last_run", ReasonDetail: "review_changes_requested"})
+	if s["a"].Runs != 2 {
+		t.Errorf("a after an unchanged skip = %+v; want the streak counted", s["a"])
+	}
+
+	// A skip that isn't about the PR leaves the streak alone.
+	s = run(prOutcome{URL: "a", Action: "skipped", Reason: "rate_limit_budget"}, prOutcome{URL: "c", Action: "test_dispatched", Reason: "checks_failure"})
+	if s["a"].Runs != 2 || s["c"].Runs != 1 {
+		t.Errorf("streaks = a %+v, c %+v; want a unchanged and c started", s["a"], s["c"])
+	}
+
+	// PRs that stop showing up are forgotten after blockedStreakTTL.
+	now = now.Add(blockedStreakTTL)
+	if s := run(); len(s) != 0 {
+		t.Errorf("streaks after the TTL = %v; want none", s)
+	}
+}
+
+func TestPipelineEscalatesBlockedPR(t *testing.T) {
+	discord := newFakeDiscord(t)
+	pr := fakePR("misty-step/api", 1)
+	pr.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "test", Status: "COMPLETED", Conclusion: "FAILURE"}}
+	fake := newFakeGitHub(pr)
+
+	args := []string{"-escalate-after", "3", "-escalate-mention", "role:123456789012345678", "-discord-alerts-to", "channel:999"}
+	opts := testPipelineOptions(t, args...)
+	var got []int
+	for i := 0; i < 4; i++ {
+		p := newPipeline(opts)
+		p.client = fake
+		out, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, out.Results[0].BlockedRuns)
+	}
+	if want := []int{1, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("blockedRuns per run = %v; want %v", got, want)
+	}
+
+	var escalations []string
+	for _, m := range discord.messages["999"] {
+		if strings.Contains(m, "Escalation") {
+			escalations = append(escalations, m)
+		}
+	}
+	if len(escalations) != 1 {
+		t.Fatalf("escalations = %q; want exactly one", escalations)
+	}
+	if want := "<@&123456789012345678> 🚨 Escalation: PR " + pr.URL; !strings.Contains(escalations[0], want) || !strings.Contains(escalations[0], "for 3 consecutive runs") {
+		t.Errorf("escalation = %q", escalations[0])
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+// --fail-on policies: whether PR-level errors make the process exit
+// non-zero. A failed run (scan failure, failed post) always exits 1.
+const (
+	failOnNone     = "none"
+	failOnErrors   = "errors"
+	failOnAnyError = "any-error"
+)
+
+// exitDegraded is the exit code for a run that completed but whose PR
+// errors trip --fail-on, or that --max-run-errors cut short.
+const exitDegraded = 3
+
+func validFailOn(policy string) bool {
+	switch policy {
+	case failOnNone, failOnErrors, failOnAnyError:
+		return true
+	}
+	return false
+}
+
+// runExitCode maps a finished run to the process exit code under policy:
+// "errors" fails when PRs errored and none were merged or commented on,
+// "any-error" fails on a single PR error, and "none" never fails on PR
+// errors. A run aborted by --max-run-errors is degraded under any policy.
+func runExitCode(policy string, out runOutput) int {
+	if !out.Ok {
+		return 1
+	}
+	if out.Aborted != "" {
+		return exitDegraded
+	}
+	merged, commented, _, errs := summarize(out.Results)
+	switch policy {
+	case failOnErrors:
+		if errs > 0 && merged+commented == 0 {
+			return exitDegraded
+		}
+	case failOnAnyError:
+		if errs > 0 {
+			return exitDegraded
+		}
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestRunExitCode(t *testing.T) {
+	allErrors := runOutput{Ok: true, Results: []prOutcome{{Action: "error"}, {Action: "skipped"}}}
+	mixed := runOutput{Ok: true, Results: []prOutcome{{Action: "error"}, {Action: "merged"}}}
+	clean := runOutput{Ok: true, Results: []prOutcome{{Action: "merged"}}}
+	failed := runOutput{Ok: false}
+	aborted := runOutput{Ok: true, Aborted: "error budget exhausted", Results: []prOutcome{{Action: "error"}, {Action: "merged"}}}
+
+	cases := []struct {
+		policy string
+		out    runOutput
+		want   int
+	}{
+		{failOnNone, allErrors, 0},
+		{failOnNone, failed, 1},
+		{failOnErrors, allErrors, exitDegraded},
+		{failOnErrors, mixed, 0},
+		{failOnAnyError, mixed, exitDegraded},
+		{failOnAnyError, clean, 0},
+		{failOnAnyError, failed, 1},
+		{failOnNone, aborted, exitDegraded},
+		{failOnErrors, aborted, exitDegraded},
+	}
+	for _, c := range cases {
+		if got := runExitCode(c.policy, c.out); got != c.want {
+			t.Errorf("runExitCode(%s, %+v) = %d; want %d", c.policy, c.out.Results, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// explainTrace writes what the pipeline saw and decided for one PR, gate by
+// gate, for the explain command. A nil *explainTrace (every other run)
+// writes nothing.
+type explainTrace struct {
+	w io.Writer
+}
+
+// note records something the pipeline looked at.
+func (t *explainTrace) note(name string, format string, args ...any) {
+	if t == nil {
+		return
+	}
+	fmt.Fprintf(t.w, "  %-21s %s\n", name+":", fmt.Sprintf(format, args...))
+}
+
+// gate records a check that can hold a PR back: whether it passed, and
+// what it saw.
+func (t *explainTrace) gate(name string, pass bool, seen string) {
+	if t == nil {
+		return
+	}
+	mark := "pass"
+	if !pass {
+		mark = "BLOCK"
+	}
+	if seen == "" {
+		seen = "ok"
+	}
+	fmt.Fprintf(t.w, "  %-5s %-15s %s\n", mark, name, seen)
+}
+
+// runExplainCommand implements `fab-pr-pipeline explain <pr-url>`: the run's
+// full decision logic on one PR, as a dry run, with a trace of every gate
+// and the decision it came to. It takes the run flags, so a PR can be
+// explained under the same policy the cron job uses.
+func runExplainCommand(args []string) int {
+	var prURL string
+	var flags []string
+	for _, arg := range args {
+		if _, ok := parsePRURL(arg, githubHosts()); ok && prURL == "" {
+			prURL = arg
+			continue
+		}
+		flags = append(flags, arg)
+	}
+	opts, code := parseRunFlags("explain", flags)
+	if opts == nil {
+		return code
+	}
+	if prURL == "" {
+		fmt.Fprintln(os.Stderr, "usage: fab-pr-pipeline explain <pr-url> [flags]")
+		return 2
+	}
+	ref, _ := parsePRURL(prURL, githubHosts())
+	return explainPR(opts, ref, os.Stdout)
+}
+
+// explainPR dry-runs the pipeline on the PR and writes the trace to w.
+func explainPR(opts *runOptions, ref prRef, w io.Writer) int {
+	opts.DryRun = true
+	opts.SkipUnchanged = false // always take a full look
+	opts.MaxPRs, opts.MaxActions = 1, 0
+	opts.targets = []webhookTarget{{Repo: ref.nameWithOwner(), Number: ref.Number, RepoURL: "https://" + ref.Host + "/" + ref.nameWithOwner()}}
+	opts.explain = &explainTrace{w: w}
+
+	fmt.Fprintf(w, "%s\n", ref.url())
+	ctx, cancel := opts.runContext()
+	defer cancel()
+	out, err := newPipeline(opts).Run(ctx)
+	if err != nil {
+		fmt.Fprintf(w, "decision: couldn't run: %v\n", err)
+		return 1
+	}
+	switch {
+	case len(out.Results) > 0:
+		fmt.Fprintf(w, "decision: %s\n", explainDecision(out.Results[0]))
+	case len(out.Filtered) > 0:
+		fmt.Fprintf(w, "decision: not selected (%s)\n", out.Filtered[0].Reason)
+	default:
+		fmt.Fprintln(w, "decision: not processed; the PR isn't open, or couldn't be fetched (see stderr)")
+		return 1
+	}
+	return 0
+}
+
+// explainDecision describes a dry run's outcome as what a real run would do.
+func explainDecision(o prOutcome) string {
+	code, detail := string(o.ReasonCode), o.ReasonDetail
+	if d, ok := o.dryRun(); ok {
+		detail = d
+	}
+	why := code
+	if detail != "" {
+		why += " (" + detail + ")"
+	}
+	if action := plannedAction(o); action != "" {
+		return fmt.Sprintf("would %s: %s", strings.ReplaceAll(action, "_", " "), why)
+	}
+	if why == "" {
+		return o.Action
+	}
+	return o.Action + ": " + why
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExplainPR(t *testing.T) {
+	green := fakePR("misty-step/api", 1)
+	held := fakePR("misty-step/api", 2)
+	held.Labels = []label{{Name: "hold"}}
+	fork := fakePR("misty-step/api", 3)
+	fork.IsCrossRepository = true
+	draft := fakePR("misty-step/api", 4)
+	draft.IsDraft = true
+
+	tests := []struct {
+		pr   *prView
+		want []string
+	}{
+		{green, []string{"pass  checks", "pass  merge_window", "decision: would merge: mergeable"}},
+		{held, []string{"BLOCK hold_label", `"hold" present: true`, "decision: would comment: hold_label"}},
+		{fork, []string{"BLOCK fork_policy", "--fork-policy comment-only", "fork_comment_only"}},
+		{draft, []string{"BLOCK selection", "decision: not selected (draft)"}},
+	}
+	for _, tt := range tests {
+		fake := newFakeGitHub(tt.pr)
+		useFakeGitHub(t, fake)
+		ref, _ := parsePRURL(tt.pr.URL, githubHosts())
+
+		var buf bytes.Buffer
+		if code := explainPR(testPipelineOptions(t, "-hold-label", "hold"), ref, &buf); code != 0 {
+			t.Fatalf("%s: exit %d\n%s", tt.pr.URL, code, buf.String())
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(buf.String(), w) {
+				t.Errorf("%s: trace missing %q:\n%s", tt.pr.URL, w, buf.String())
+			}
+		}
+		if len(fake.merged) > 0 || len(fake.posted) > 0 {
+			t.Errorf("%s: explain acted: merged %v, posted %v", tt.pr.URL, fake.merged, fake.posted)
+		}
+	}
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A small expression language for merge conditions, in the style of CEL:
+//
+//	pr.labels.contains('automerge') && pr.additions < 500
+//	pr.author in ['dependabot', 'renovate'] || pr.title.startsWith('docs:')
+//
+// Values are ints, strings, bools, and lists. Operators are || && ! == !=
+// < <= > >= + - and in; strings and lists have contains, size, and (for
+// strings) startsWith, endsWith, and matches. There are no side effects,
+// loops, or user functions, so an expression always finishes.
+
+// exprNode is a parsed expression.
+type exprNode interface {
+	eval(env map[string]any) (any, error)
+}
+
+type (
+	exprLit   struct{ v any }
+	exprIdent struct{ name string }
+	exprList  struct{ items []exprNode }
+	exprField struct {
+		x    exprNode
+		name string
+	}
+	exprCall struct {
+		x      exprNode
+		method string
+		args   []exprNode
+	}
+	exprUnary struct {
+		op string
+		x  exprNode
+	}
+	exprBinary struct {
+		op   string
+		x, y exprNode
+	}
+)
+
+// parseExpr parses src.
+func parseExpr(src string) (exprNode, error) {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+	}
+	return n, nil
+}
+
+// evalBool evaluates n, which must come out true or false.
+func evalBool(n exprNode, env map[string]any) (bool, error) {
+	v, err := n.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("want true or false, got %s", exprTypeName(v))
+	}
+	return b, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokInt
+	tokString
+	tokIdent
+	tokOp
+)
+
+type exprToken struct {
+	kind tokKind
+	text string // the op, ident, or literal's value
+	pos  int
+}
+
+func lexExpr(src string) ([]exprToken, error) {
+	var toks []exprToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+			toks = append(toks, exprToken{tokInt, src[i:j], i})
+			i = j
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+					switch src[j] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					default:
+						b.WriteByte(src[j])
+					}
+					continue
+				}
+				b.WriteByte(src[j])
+			}
+			if j == len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			toks = append(toks, exprToken{tokString, b.String(), i})
+			i = j + 1
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, exprToken{tokIdent, src[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "(", ")", "[", "]", ".", ","} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+			toks = append(toks, exprToken{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, exprToken{kind: tokEOF, text: "end of expression", pos: len(src)}), nil
+}
+
+type exprParser struct {
+	toks []exprToken
+	i    int
+}
+
+func (p *exprParser) peek() exprToken { return p.toks[p.i] }
+
+func (p *exprParser) next() exprToken {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it's the operator or keyword s.
+func (p *exprParser) accept(s string) bool {
+	if t := p.peek(); (t.kind == tokOp || t.kind == tokIdent) && t.text == s {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(s string) error {
+	if !p.accept(s) {
+		t := p.peek()
+		return fmt.Errorf("want %q at offset %d, got %q", s, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *exprParser) or() (exprNode, error) {
+	x, err := p.and()
+	for err == nil && p.accept("||") {
+		var y exprNode
+		if y, err = p.and(); err == nil {
+			x = exprBinary{"||", x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) and() (exprNode, error) {
+	x, err := p.comparison()
+	for err == nil && p.accept("&&") {
+		var y exprNode
+		if y, err = p.comparison(); err == nil {
+			x = exprBinary{"&&", x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) comparison() (exprNode, error) {
+	x, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+		if p.accept(op) {
+			y, err := p.sum()
+			if err != nil {
+				return nil, err
+			}
+			return exprBinary{op, x, y}, nil
+		}
+	}
+	return x, nil
+}
+
+func (p *exprParser) sum() (exprNode, error) {
+	x, err := p.unary()
+	for err == nil {
+		op := p.peek().text
+		if p.peek().kind != tokOp || (op != "+" && op != "-") {
+			break
+		}
+		p.next()
+		var y exprNode
+		if y, err = p.unary(); err == nil {
+			x = exprBinary{op, x, y}
+		}
+	}
+	return x, err
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	for _, op := range []string{"!", "-"} {
+		if p.accept(op) {
+			x, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+			return exprUnary{op, x}, nil
+		}
+	}
+	return p.postfix()
+}
+
+func (p *exprParser) postfix() (exprNode, error) {
+	x, err := p.primary()
+	for err == nil && p.accept(".") {
+		t := p.next()
+		if t.kind != tokIdent {
+			return nil, fmt.Errorf("want a name after '.' at offset %d", t.pos)
+		}
+		if !p.accept("(") {
+			x = exprField{x, t.text}
+			continue
+		}
+		var args []exprNode
+		if args, err = p.list(")"); err == nil {
+			x = exprCall{x, t.text, args}
+		}
+	}
+	return x, err
+}
+
+// list parses comma-separated expressions up to the closing token.
+func (p *exprParser) list(closing string) ([]exprNode, error) {
+	var items []exprNode
+	if p.accept(closing) {
+		return items, nil
+	}
+	for {
+		item, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.accept(closing) {
+			return items, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *exprParser) primary() (exprNode, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokInt:
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q at offset %d", t.text, t.pos)
+		}
+		return exprLit{n}, nil
+	case t.kind == tokString:
+		return exprLit{t.text}, nil
+	case t.kind == tokIdent && t.text == "true":
+		return exprLit{true}, nil
+	case t.kind == tokIdent && t.text == "false":
+		return exprLit{false}, nil
+	case t.kind == tokIdent:
+		return exprIdent{t.text}, nil
+	case t.kind == tokOp && t.text == "(":
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case t.kind == tokOp && t.text == "[":
+		items, err := p.list("]")
+		return exprList{items}, err
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
+
+func (n exprLit) eval(map[string]any) (any, error) { return n.v, nil }
+
+func (n exprIdent) eval(env map[string]any) (any, error) {
+	v, ok := env[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown name %q", n.name)
+	}
+	return v, nil
+}
+
+func (n exprList) eval(env map[string]any) (any, error) {
+	items := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+func (n exprField) eval(env map[string]any) (any, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := x.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf(".%s on %s", n.name, exprTypeName(x))
+	}
+	v, ok := m[n.name]
+	if !ok {
+		return nil, fmt.Errorf("no field %q", n.name)
+	}
+	return v, nil
+}
+
+func (n exprCall) eval(env map[string]any) (any, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	var args []any
+	for _, a := range n.args {
+		v, err := a.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	want := map[string]int{"size": 0, "contains": 1, "startsWith": 1, "endsWith": 1, "matches": 1}
+	nargs, known := want[n.method]
+	if !known {
+		return nil, fmt.Errorf("unknown method %s()", n.method)
+	}
+	if len(args) != nargs {
+		return nil, fmt.Errorf("%s() takes %d arguments, got %d", n.method, nargs, len(args))
+	}
+	if list, ok := x.([]any); ok {
+		switch n.method {
+		case "size":
+			return int64(len(list)), nil
+		case "contains":
+			return exprContains("contains()", list, args[0])
+		}
+		return nil, fmt.Errorf("%s() on a list", n.method)
+	}
+	s, ok := x.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s() on %s", n.method, exprTypeName(x))
+	}
+	if n.method == "size" {
+		return int64(len(s)), nil
+	}
+	arg, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s() wants a string, got %s", n.method, exprTypeName(args[0]))
+	}
+	switch n.method {
+	case "contains":
+		return strings.Contains(s, arg), nil
+	case "startsWith":
+		return strings.HasPrefix(s, arg), nil
+	case "endsWith":
+		return strings.HasSuffix(s, arg), nil
+	}
+	re, err := regexp.Compile(arg)
+	if err != nil {
+		return nil, fmt.Errorf("matches(): %w", err)
+	}
+	return re.MatchString(s), nil
+}
+
+// exprContains reports whether list holds x. Lists don't compare, as
+// with ==, so looking for one is a type error rather than a panic.
+func exprContains(op string, list []any, x any) (bool, error) {
+	if _, isList := x.([]any); isList {
+		return false, fmt.Errorf("%s with a list", op)
+	}
+	return slices.ContainsFunc(list, func(v any) bool { return v == x }), nil
+}
+
+func (n exprUnary) eval(env map[string]any) (any, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch v := x.(type) {
+	case bool:
+		if n.op == "!" {
+			return !v, nil
+		}
+	case int64:
+		if n.op == "-" {
+			return -v, nil
+		}
+	}
+	return nil, fmt.Errorf("%s on %s", n.op, exprTypeName(x))
+}
+
+func (n exprBinary) eval(env map[string]any) (any, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	// && and || short-circuit, as in CEL.
+	if n.op == "&&" || n.op == "||" {
+		xb, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s on %s", n.op, exprTypeName(x))
+		}
+		if xb == (n.op == "||") {
+			return xb, nil
+		}
+		y, err := n.y.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := y.(bool); !ok {
+			return nil, fmt.Errorf("%s on %s", n.op, exprTypeName(y))
+		}
+		return y, nil
+	}
+	y, err := n.y.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "in":
+		list, ok := y.([]any)
+		if !ok {
+			return nil, fmt.Errorf("in on %s", exprTypeName(y))
+		}
+		return exprContains("in", list, x)
+	case "==", "!=":
+		if exprTypeName(x) != exprTypeName(y) {
+			return nil, fmt.Errorf("%s between %s and %s", n.op, exprTypeName(x), exprTypeName(y))
+		}
+		if _, isList := x.([]any); isList {
+			return nil, fmt.Errorf("%s between lists", n.op)
+		}
+		return (x == y) == (n.op == "=="), nil
+	}
+	switch xv := x.(type) {
+	case int64:
+		yv, ok := y.(int64)
+		if !ok {
+			break
+		}
+		switch n.op {
+		case "<":
+			return xv < yv, nil
+		case "<=":
+			return xv <= yv, nil
+		case ">":
+			return xv > yv, nil
+		case ">=":
+			return xv >= yv, nil
+		case "+":
+			return xv + yv, nil
+		case "-":
+			return xv - yv, nil
+		}
+	case string:
+		yv, ok := y.(string)
+		if !ok {
+			break
+		}
+		switch n.op {
+		case "<":
+			return xv < yv, nil
+		case "<=":
+			return xv <= yv, nil
+		case ">":
+			return xv > yv, nil
+		case ">=":
+			return xv >= yv, nil
+		case "+":
+			return xv + yv, nil
+		}
+	}
+	return nil, fmt.Errorf("%s between %s and %s", n.op, exprTypeName(x), exprTypeName(y))
+}
+
+func exprTypeName(v any) string {
+	switch v.(type) {
+	case int64:
+		return "int"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// mergeCondition is a config merge condition: a named expression over the
+// PR that must come out true for the pipeline to merge it.
+type mergeCondition struct {
+	// Name goes in the blocker reason (merge_condition_<name>), so it's
+	// lowercase letters, digits, and underscores.
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+
+	prog exprNode
+}
+
+var mergeConditionNameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// compileMergeConditions parses each condition's expression and tries it
+// on an empty PR, so unknown fields and type mismatches show up at config
+// load rather than on the first PR.
+func compileMergeConditions(conds []mergeCondition) error {
+	seen := map[string]bool{}
+	for i := range conds {
+		c := &conds[i]
+		if !mergeConditionNameRe.MatchString(c.Name) {
+			return fmt.Errorf("[%d]: name %q must be lowercase letters, digits, and underscores", i, c.Name)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("[%d]: %q listed twice", i, c.Name)
+		}
+		seen[c.Name] = true
+		prog, err := parseExpr(c.Expr)
+		if err != nil {
+			return fmt.Errorf("[%d] %s: %w", i, c.Name, err)
+		}
+		if _, err := evalBool(prog, conditionEnv(&prView{}, "", time.Time{})); err != nil {
+			return fmt.Errorf("[%d] %s: %w", i, c.Name, err)
+		}
+		c.prog = prog
+	}
+	return nil
+}
+
+// conditionEnv is what a merge condition sees: the PR as pr.
+func conditionEnv(view *prView, repo string, now time.Time) map[string]any {
+	labels := []any{}
+	for _, l := range view.Labels {
+		labels = append(labels, l.Name)
+	}
+	var ageHours int64
+	if !view.CreatedAt.IsZero() {
+		ageHours = int64(now.Sub(view.CreatedAt) / time.Hour)
+	}
+	return map[string]any{"pr": map[string]any{
+		"repo":              repo,
+		"title":             view.Title,
+		"body":              view.Body,
+		"author":            view.Author.Login,
+		"labels":            labels,
+		"additions":         int64(view.Additions),
+		"deletions":         int64(view.Deletions),
+		"changedFiles":      int64(view.ChangedFiles),
+		"isDraft":           view.IsDraft,
+		"isCrossRepository": view.IsCrossRepository,
+		"baseRefName":       view.BaseRefName,
+		"headRefName":       view.HeadRefName,
+		"mergeable":         view.Mergeable,
+		"reviewDecision":    view.ReviewDecision,
+		"mergeStateStatus":  view.MergeStateStatus,
+		"checksState":       overallChecksState(view.StatusCheckRollup),
+		"ageHours":          ageHours,
+	}}
+}
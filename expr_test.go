@@ -0,0 +1,156 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvalExpr(t *testing.T) {
+	view := fakePR("misty-step/api", 1)
+	view.Title = "docs: fix typo"
+	view.Labels = []label{{Name: "automerge"}}
+	view.Additions, view.Deletions = 120, 30
+	view.CreatedAt = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	env := conditionEnv(view, "misty-step/api", time.Date(2025, 6, 2, 6, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"pr.labels.contains('automerge') && pr.additions < 500", true},
+		{`pr.labels.contains("wip")`, false},
+		{"pr.additions + pr.deletions <= 150", true},
+		{"pr.additions - pr.deletions == 90", true},
+		{"pr.title.startsWith('docs:') || pr.changedFiles > 10", true},
+		{"pr.title.endsWith('typo') && !pr.isDraft", true},
+		{"pr.title.matches('^(docs|chore):')", true},
+		{"pr.title.size() == 14 && pr.labels.size() == 1", true},
+		{"pr.author in ['kaylee-mistystep', 'renovate']", true},
+		{"pr.repo != 'misty-step/api'", false},
+		{"pr.checksState == 'SUCCESS' && pr.reviewDecision == 'APPROVED'", true},
+		{"pr.ageHours >= 30", true},
+		{"(true || false) && -1 < 0", true},
+		{"false && pr.nope", false},
+		{"true || 1", true},
+	}
+	for _, tt := range tests {
+		prog, err := parseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("parseExpr(%q): %v", tt.expr, err)
+			continue
+		}
+		got, err := evalBool(prog, env)
+		if err != nil || got != tt.want {
+			t.Errorf("%s = %t, %v; want %t", tt.expr, got, err, tt.want)
+		}
+	}
+}
+
+func TestEvalExprErrors(t *testing.T) {
+	env := conditionEnv(fakePR("misty-step/api", 1), "misty-step/api", time.Now())
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"pr.additions <", "unexpected"},
+		{"pr.title == 'x", "unterminated string"},
+		{"pr.additions < 5 5", `unexpected "5"`},
+		{"pr.additions @ 5", `unexpected '@'`},
+		{"pr.nope", `no field "nope"`},
+		{"issue.title", `unknown name "issue"`},
+		{"pr.additions", "want true or false, got int"},
+		{"pr.additions < '5'", "< between int and string"},
+		{"pr.title.shout()", "unknown method shout()"},
+		{"pr.labels.startsWith('a')", "startsWith() on a list"},
+		{"pr.title.contains(1)", "contains() wants a string, got int"},
+		{"pr.title.matches('(')", "matches()"},
+		{"pr.isDraft || 1", "|| on int"},
+		{"pr.labels in [pr.labels]", "in with a list"},
+		{"[['a']].contains(['a'])", "contains() with a list"},
+	}
+	for _, tt := range tests {
+		prog, err := parseExpr(tt.expr)
+		if err == nil {
+			_, err = evalBool(prog, env)
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v; want %q", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCompileMergeConditions(t *testing.T) {
+	cfg := &pipelineConfig{
+		MergeConditions: []mergeCondition{{Name: "small", Expr: "pr.additions < 500"}},
+		Repos: map[string]repoPolicy{"misty-step/*": {
+			MergeConditions: []mergeCondition{{Name: "labelled", Expr: "pr.labels.contains('automerge')"}},
+		}},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	conds := cfg.mergeConditionsFor("misty-step/api")
+	if len(conds) != 2 || conds[0].Name != "small" || conds[1].Name != "labelled" || conds[1].prog == nil {
+		t.Errorf("misty-step/api conditions = %+v; want small then labelled, compiled", conds)
+	}
+
+	for _, bad := range []mergeCondition{
+		{Name: "Small", Expr: "true"},
+		{Name: "typo", Expr: "pr.aditions < 500"},
+		{Name: "syntax", Expr: "pr.additions <"},
+		{Name: "not_bool", Expr: "pr.title"},
+		{Name: "list_in_list", Expr: "pr.labels in [pr.labels]"},
+	} {
+		cfg := &pipelineConfig{Repos: map[string]repoPolicy{"o/r": {MergeConditions: []mergeCondition{bad}}}}
+		if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "mergeConditions[0]") {
+			t.Errorf("%+v: validate() = %v; want a mergeConditions error", bad, err)
+		}
+	}
+	dup := &pipelineConfig{MergeConditions: []mergeCondition{{Name: "a", Expr: "true"}, {Name: "a", Expr: "false"}}}
+	if err := dup.validate(); err == nil || !strings.Contains(err.Error(), "listed twice") {
+		t.Errorf("validate() = %v; want the repeated name rejected", err)
+	}
+}
+
+func TestMergeConditionHoldsPR(t *testing.T) {
+	unlabelled := fakePR("misty-step/api", 1)
+	labelled := fakePR("misty-step/api", 2)
+	labelled.Labels = []label{{Name: "automerge"}}
+	fake := newFakeGitHub(unlabelled, labelled)
+	useFakeGitHub(t, fake)
+
+	opts := testPipelineOptions(t)
+	opts.config = &pipelineConfig{MergeConditions: []mergeCondition{
+		{Name: "opted_in", Expr: "pr.labels.contains('automerge') && pr.additions < 500"},
+	}}
+	if err := opts.config.validate(); err != nil {
+		t.Fatal(err)
+	}
+	out, err := newPipeline(opts).Run(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 2 {
+		t.Fatalf("results = %+v; want 2", out.Results)
+	}
+	held := out.Results[0]
+	if held.URL == labelled.URL {
+		held = out.Results[1]
+	}
+	if held.Action == "merged" || held.ReasonCode != reasonMergeCondition || held.ReasonDetail != "opted_in" {
+		t.Errorf("unlabelled PR = %+v; want it held by merge_condition opted_in", held)
+	}
+	merged := 0
+	for _, r := range out.Results {
+		if r.Action == "merged" {
+			merged++
+		}
+	}
+	if merged != 1 {
+		t.Errorf("merged %d PRs; want only the labelled one", merged)
+	}
+	if body := strings.Join(fake.posted[unlabelled.URL], "\n"); !strings.Contains(body, "opted_in") || !strings.Contains(body, "pr.labels.contains('automerge')") {
+		t.Errorf("comment = %q; want the condition's name and expression", body)
+	}
+}
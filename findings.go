@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ciFinding is one CI failure diagnosed from logs, tied to its PR.
+type ciFinding struct {
+	Repo    string
+	Number  int
+	URL     string
+	HeadSHA string
+	Diag    ciDiagnosis
+}
+
+// SARIF 2.1.0, trimmed to the fields we fill in.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations,omitempty"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifRules are the failure categories classifyCILog can produce.
+var sarifRules = []sarifRule{
+	{ID: "lint", ShortDescription: sarifMessage{Text: "Lint failure"}},
+	{ID: "build", ShortDescription: sarifMessage{Text: "Build or compile failure"}},
+	{ID: "test", ShortDescription: sarifMessage{Text: "Test failure"}},
+}
+
+// buildSARIF turns the run's findings into a SARIF log. Each result carries
+// the PR, head commit, check, and log excerpt as properties, and a location
+// when the log line named a file.
+func buildSARIF(findings []ciFinding) sarifLog {
+	results := []sarifResult{}
+	for _, f := range findings {
+		text := fmt.Sprintf("%s failed (%s)", f.Diag.Check, f.Diag.Category)
+		if f.Diag.Detail != "" {
+			text += ": " + f.Diag.Detail
+		}
+		r := sarifResult{
+			RuleID:  f.Diag.Category,
+			Level:   "error",
+			Message: sarifMessage{Text: text},
+			Properties: map[string]any{
+				"repo":     f.Repo,
+				"number":   f.Number,
+				"url":      f.URL,
+				"headSha":  f.HeadSHA,
+				"check":    f.Diag.Check,
+				"category": f.Diag.Category,
+				"detail":   f.Diag.Detail,
+				"excerpt":  f.Diag.Excerpt,
+			},
+		}
+		if f.Diag.File != "" {
+			loc := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: f.Diag.File}}
+			if f.Diag.Line > 0 {
+				loc.Region = &sarifRegion{StartLine: f.Diag.Line}
+			}
+			r.Locations = []sarifLocation{{PhysicalLocation: loc}}
+		}
+		results = append(results, r)
+	}
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "fab-pr-pipeline", Rules: sarifRules}},
+			Results: results,
+		}},
+	}
+}
+
+// writeFindings saves the run's findings as SARIF to path. The file is
+// rewritten every run, empty when nothing was diagnosed, so consumers never
+// read a previous run's failures.
+func writeFindings(path string, findings []ciFinding) error {
+	data, err := json.MarshalIndent(buildSARIF(findings), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewCIDiagnosisLocation(t *testing.T) {
+	lines := []string{"Run golangci-lint", "internal/api/handler.go:42:7: Error return value is not checked (errcheck)"}
+	d := newCIDiagnosis("lint", "lint", "golangci-lint rule errcheck", lines, 1)
+	if d.File != "internal/api/handler.go" || d.Line != 42 {
+		t.Errorf("location = %s:%d; want internal/api/handler.go:42", d.File, d.Line)
+	}
+	d = newCIDiagnosis("test", "test", "failing test TestFoo", []string{"--- FAIL: TestFoo (0.01s)"}, 0)
+	if d.File != "" || d.Line != 0 {
+		t.Errorf("no location expected, got %s:%d", d.File, d.Line)
+	}
+}
+
+func TestWriteFindings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "findings.sarif")
+	findings := []ciFinding{
+		{Repo: "misty-step/api", Number: 7, URL: "https://github.com/misty-step/api/pull/7", HeadSHA: "abc123",
+			Diag: ciDiagnosis{Check: "lint", Category: "lint", Detail: "golangci-lint rule errcheck", Excerpt: "x.go:3: bad", File: "x.go", Line: 3}},
+		{Repo: "misty-step/web", Number: 9, URL: "https://github.com/misty-step/web/pull/9",
+			Diag: ciDiagnosis{Check: "ci", Category: "test", Detail: "failing test TestFoo"}},
+	}
+	if err := writeFindings(path, findings); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got sarifLog
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != "2.1.0" || len(got.Runs) != 1 || len(got.Runs[0].Results) != 2 {
+		t.Fatalf("unexpected SARIF:\n%s", data)
+	}
+	first := got.Runs[0].Results[0]
+	if first.RuleID != "lint" || first.Message.Text != "lint failed (lint): golangci-lint rule errcheck" {
+		t.Errorf("first result = %+v", first)
+	}
+	if len(first.Locations) != 1 || first.Locations[0].PhysicalLocation.Region.StartLine != 3 {
+		t.Errorf("first location = %+v", first.Locations)
+	}
+	if first.Properties["headSha"] != "abc123" || first.Properties["url"] != "https://github.com/misty-step/api/pull/7" {
+		t.Errorf("properties = %v", first.Properties)
+	}
+	if len(got.Runs[0].Results[1].Locations) != 0 {
+		t.Error("a finding without a file should have no location")
+	}
+
+	// An empty run still rewrites the file.
+	if err := writeFindings(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	got = sarifLog{}
+	_ = json.Unmarshal(data, &got)
+	if len(got.Runs) != 1 || len(got.Runs[0].Results) != 0 {
+		t.Errorf("empty findings:\n%s", data)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+// --fork-policy values: how far the pipeline acts on a PR whose head branch
+// is in a fork. Updating a fork's branch pushes to someone else's repo (and
+// fails outright when they don't allow maintainer edits), and auto-merge
+// would merge whatever they push next, so neither happens unless forks are
+// allowed.
+const (
+	// forkPolicyAllow treats fork PRs like any other.
+	forkPolicyAllow = "allow"
+	// forkPolicyMergeOnly merges fork PRs that pass every gate, but never
+	// enables auto-merge or updates their branch.
+	forkPolicyMergeOnly = "merge-only"
+	// forkPolicyCommentOnly never merges fork PRs; it comments with their
+	// blockers, or with fork_comment_only when there are none.
+	forkPolicyCommentOnly = "comment-only"
+)
+
+func validForkPolicy(policy string) bool {
+	switch policy {
+	case forkPolicyAllow, forkPolicyMergeOnly, forkPolicyCommentOnly:
+		return true
+	}
+	return false
+}
+
+// forkRestricted reports whether the PR is from a fork and --fork-policy
+// keeps the pipeline from updating its branch or enabling auto-merge.
+func forkRestricted(opts *runOptions, pr *prView) bool {
+	return pr.IsCrossRepository && opts.ForkPolicy != forkPolicyAllow
+}
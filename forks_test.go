@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestPrepareForkPolicy(t *testing.T) {
+	for policy, ok := range map[string]bool{"comment-only": true, "merge-only": true, "allow": true, "never": false} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		opts := registerRunFlags(fs)
+		if err := fs.Parse([]string{"-fork-policy", policy}); err != nil {
+			t.Fatal(err)
+		}
+		if err := opts.prepare(); (err == nil) != ok {
+			t.Errorf("prepare(--fork-policy %s) = %v; want ok=%v", policy, err, ok)
+		}
+	}
+}
+
+func TestPipelineForkPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		behind bool
+		want   string // action/reason
+	}{
+		{"comment-only", false, "commented/fork_comment_only"},
+		{"merge-only", false, "merged/"},
+		{"allow", false, "merged/"},
+		{"comment-only", true, "commented/branch_behind"},
+		{"merge-only", true, "commented/branch_behind"},
+		{"allow", true, "branch_updated/branch_behind"},
+	}
+	for _, tt := range tests {
+		fork := fakePR("misty-step/api", 1)
+		fork.IsCrossRepository = true
+		if tt.behind {
+			fork.MergeStateStatus = "BEHIND"
+		}
+		fake := newFakeGitHub(fork)
+		useFakeGitHub(t, fake)
+
+		out, err := newPipeline(testPipelineOptions(t, "-fork-policy", tt.policy)).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Results) != 1 {
+			t.Fatalf("results = %+v", out.Results)
+		}
+		got := out.Results[0].Action + "/" + out.Results[0].Reason
+		if got != tt.want {
+			t.Errorf("%s, behind=%v: %s; want %s", tt.policy, tt.behind, got, tt.want)
+		}
+		if updated := fake.called("pr update-branch"); updated != strings.HasPrefix(tt.want, "branch_updated") {
+			t.Errorf("%s, behind=%v: branch updated = %v", tt.policy, tt.behind, updated)
+		}
+	}
+
+	// The same PR from a branch in the repo isn't held back.
+	local := fakePR("misty-step/api", 1)
+	useFakeGitHub(t, newFakeGitHub(local))
+	out, err := newPipeline(testPipelineOptions(t)).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 1 || out.Results[0].Action != "merged" {
+		t.Errorf("non-fork PR: results = %+v; want merged", out.Results)
+	}
+}
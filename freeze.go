@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// freezeEnv freezes every run when set to anything but "", "0", or
+// "false"; its value is reported as the reason.
+const freezeEnv = "PIPELINE_FREEZE"
+
+// freezeFileRe matches --freeze-file: owner/repo:path/to/file.
+var freezeFileRe = regexp.MustCompile(`^([\w.-]+/[\w.-]+):(.+)$`)
+
+// freezeIssueRe matches --freeze-issue: owner/repo#N.
+var freezeIssueRe = regexp.MustCompile(`^([\w.-]+/[\w.-]+)#(\d+)$`)
+
+// freezeSwitch is the org-wide kill switch: while any of its sources is
+// set, runs act on nothing and only report what they would have done.
+type freezeSwitch struct {
+	fileRepo string
+	filePath string
+	issue    *issueRef
+	label    string
+}
+
+// parseFreezeSwitch validates --freeze-file and --freeze-issue.
+func parseFreezeSwitch(file string, issue string, label string) (freezeSwitch, error) {
+	fs := freezeSwitch{label: strings.TrimSpace(label)}
+	if file = strings.TrimSpace(file); file != "" {
+		m := freezeFileRe.FindStringSubmatch(file)
+		if m == nil {
+			return fs, fmt.Errorf("--freeze-file %q: want owner/repo:path", file)
+		}
+		fs.fileRepo, fs.filePath = m[1], strings.TrimPrefix(m[2], "/")
+	}
+	if issue = strings.TrimSpace(issue); issue != "" {
+		m := freezeIssueRe.FindStringSubmatch(issue)
+		if m == nil {
+			return fs, fmt.Errorf("--freeze-issue %q: want owner/repo#N", issue)
+		}
+		n, _ := strconv.Atoi(m[2])
+		fs.issue = &issueRef{Repo: m[1], Number: n}
+		if fs.label == "" {
+			return fs, fmt.Errorf("--freeze-issue needs a --freeze-label")
+		}
+	}
+	return fs, nil
+}
+
+// check returns why the pipeline is frozen, or "" if it isn't. The
+// environment is checked first, then the file, then the control issue. A
+// source that can't be read counts as set: during an incident it's safer
+// to stop merging than to guess.
+func (fs freezeSwitch) check(ctx context.Context) string {
+	if v := strings.TrimSpace(os.Getenv(freezeEnv)); v != "" && v != "0" && !strings.EqualFold(v, "false") {
+		return freezeEnv + ": " + v
+	}
+	if fs.fileRepo != "" {
+		body, found, err := ghFreezeFile(ctx, fs.fileRepo, fs.filePath)
+		switch {
+		case err != nil:
+			return fmt.Sprintf("freeze file %s:%s unreadable: %v", fs.fileRepo, fs.filePath, err)
+		case found:
+			reason := fmt.Sprintf("freeze file %s:%s present", fs.fileRepo, fs.filePath)
+			if line, _, _ := strings.Cut(strings.TrimSpace(body), "\n"); line != "" {
+				reason += ": " + line
+			}
+			return reason
+		}
+	}
+	if fs.issue != nil {
+		labels, err := ghIssueLabels(ctx, *fs.issue)
+		if err != nil {
+			return fmt.Sprintf("freeze issue %s unreadable: %v", fs.issue, err)
+		}
+		if hasLabel(labels, fs.label) {
+			return fmt.Sprintf("freeze issue %s labeled %q", fs.issue, fs.label)
+		}
+	}
+	return ""
+}
+
+// ghFreezeFile returns the file at path on repo's default branch and
+// whether it exists.
+func ghFreezeFile(ctx context.Context, repo string, path string) (string, bool, error) {
+	stdout, err := runGh(ctx, "api", "-H", "Accept: application/vnd.github.raw", fmt.Sprintf("repos/%s/contents/%s", repo, path))
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return string(stdout), true, nil
+}
+
+func ghIssueLabels(ctx context.Context, ref issueRef) ([]label, error) {
+	stdout, err := runGh(ctx, "issue", "view", strconv.Itoa(ref.Number), "-R", ref.Repo, "--json", "labels")
+	if err != nil {
+		return nil, err
+	}
+	var v struct {
+		Labels []label `json:"labels"`
+	}
+	if err := json.Unmarshal(stdout, &v); err != nil {
+		return nil, fmt.Errorf("parse gh issue view json: %w", err)
+	}
+	return v.Labels, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseFreezeSwitch(t *testing.T) {
+	fs, err := parseFreezeSwitch("misty-step/ops:/FREEZE", "misty-step/ops#12", "freeze")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs.fileRepo != "misty-step/ops" || fs.filePath != "FREEZE" || fs.issue == nil || *fs.issue != (issueRef{Repo: "misty-step/ops", Number: 12}) {
+		t.Errorf("parseFreezeSwitch = %+v", fs)
+	}
+	for _, tt := range []struct{ file, issue, label string }{
+		{"misty-step/ops", "", "freeze"},
+		{"", "misty-step/ops/12", "freeze"},
+		{"", "misty-step/ops#12", ""},
+	} {
+		if _, err := parseFreezeSwitch(tt.file, tt.issue, tt.label); err == nil {
+			t.Errorf("parseFreezeSwitch(%q, %q, %q) = nil error", tt.file, tt.issue, tt.label)
+		}
+	}
+}
+
+func TestFreezeSwitchCheck(t *testing.T) {
+	fs, err := parseFreezeSwitch("misty-step/ops:FREEZE", "misty-step/ops#12", "freeze")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file, labels string
+	var fileErr error
+	ctx := withGitHubClient(context.Background(), ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+		joined := strings.Join(args, " ")
+		switch {
+		case strings.Contains(joined, "repos/misty-step/ops/contents/FREEZE"):
+			if fileErr != nil {
+				return nil, fileErr
+			}
+			if file == "" {
+				return nil, errors.New("gh: Not Found (HTTP 404)")
+			}
+			return []byte(file), nil
+		case strings.HasPrefix(joined, "issue view 12 -R misty-step/ops"):
+			return []byte(`{"labels":[` + labels + `]}`), nil
+		}
+		t.Errorf("unexpected gh %s", joined)
+		return nil, errors.New("unexpected")
+	}))
+
+	t.Setenv(freezeEnv, "false")
+	if got := fs.check(ctx); got != "" {
+		t.Errorf("nothing set: frozen %q", got)
+	}
+	labels = `{"name":"incident"},{"name":"Freeze"}`
+	if got := fs.check(ctx); !strings.Contains(got, `misty-step/ops#12 labeled "freeze"`) {
+		t.Errorf("labeled issue: %q", got)
+	}
+	file = "deploy incident, see #ops\nmore detail"
+	if got := fs.check(ctx); got != "freeze file misty-step/ops:FREEZE present: deploy incident, see #ops" {
+		t.Errorf("file: %q", got)
+	}
+	fileErr = errors.New("HTTP 502")
+	if got := fs.check(ctx); !strings.Contains(got, "unreadable") {
+		t.Errorf("unreadable file should freeze, got %q", got)
+	}
+	t.Setenv(freezeEnv, "db migration")
+	if got := fs.check(ctx); got != "PIPELINE_FREEZE: db migration" {
+		t.Errorf("env: %q", got)
+	}
+}
+
+func TestPipelineFrozenActsOnNothing(t *testing.T) {
+	t.Setenv(freezeEnv, "incident")
+	fake := newFakeGitHub(fakePR("misty-step/api", 1))
+	opts := testPipelineOptions(t)
+	p := newPipeline(opts)
+	p.client = fake
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Frozen != "PIPELINE_FREEZE: incident" || !out.DryRun {
+		t.Errorf("frozen = %q, dryRun = %v", out.Frozen, out.DryRun)
+	}
+	if len(out.Results) != 1 || out.Results[0].Action != "skipped" {
+		t.Errorf("results = %+v", out.Results)
+	}
+	if len(fake.merged) > 0 || len(fake.posted) > 0 {
+		t.Errorf("frozen run merged %v, posted %v", fake.merged, fake.posted)
+	}
+	if opts.DryRun {
+		t.Error("freezing one run changed the shared options")
+	}
+	if !shouldReport(out, false, false) {
+		t.Error("a frozen run should still be reported")
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ghRecording is one gh call saved by --record: the command and what it
+// returned. A failed call also has its error message and stderr, so the
+// replayed error classifies the same way.
+type ghRecording struct {
+	Args   []string `json:"args"`
+	Stdin  string   `json:"stdin,omitempty"`
+	Stdout string   `json:"stdout"`
+	Stderr string   `json:"stderr,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// ghRecordingKey identifies a gh call by its arguments and stdin. The same
+// call made several times is told apart by its sequence number.
+func ghRecordingKey(stdin []byte, args []string) string {
+	h := sha256.New()
+	for _, a := range args {
+		h.Write([]byte(a))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{0})
+	h.Write(stdin)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func ghRecordingPath(dir string, key string, seq int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s-%d.json", key, seq))
+}
+
+// ghCallCounter numbers repeated calls so each gets its own recording.
+type ghCallCounter struct {
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+func (c *ghCallCounter) next(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen == nil {
+		c.seen = map[string]int{}
+	}
+	c.seen[key]++
+	return c.seen[key]
+}
+
+// recordingClient passes every call through to next and saves the call and
+// its result to dir.
+type recordingClient struct {
+	next  GitHubClient
+	dir   string
+	calls ghCallCounter
+}
+
+func newRecordingClient(next GitHubClient, dir string) (*recordingClient, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("--record: %w", err)
+	}
+	return &recordingClient{next: next, dir: dir}, nil
+}
+
+func (c *recordingClient) Run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	out, err := c.next.Run(ctx, stdin, args...)
+	if ctx.Err() != nil {
+		// A cancelled call says nothing about GitHub; don't save it.
+		return out, err
+	}
+	rec := ghRecording{Args: args, Stdin: string(stdin), Stdout: string(out)}
+	if err != nil {
+		rec.Error = err.Error()
+		var ce *cmdError
+		if errors.As(err, &ce) {
+			rec.Stdout, rec.Stderr = string(ce.stdout), ce.stderr
+		}
+	}
+	key := ghRecordingKey(stdin, args)
+	data, merr := json.MarshalIndent(rec, "", "  ")
+	if merr == nil {
+		merr = os.WriteFile(ghRecordingPath(c.dir, key, c.calls.next(key)), append(data, '\n'), 0644)
+	}
+	if merr != nil {
+		fmt.Fprintf(os.Stderr, "[record] failed to save gh %s: %v\n", strings.Join(args, " "), merr)
+	}
+	return out, err
+}
+
+// replayClient answers calls from the recordings in dir without running gh.
+// The nth identical call gets the nth recording; once they run out, the last
+// one is repeated (a run that polls may call more often than the recorded
+// one did).
+type replayClient struct {
+	dir   string
+	calls ghCallCounter
+}
+
+func newReplayClient(dir string) (*replayClient, error) {
+	st, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("--replay: %w", err)
+	}
+	if !st.IsDir() {
+		return nil, fmt.Errorf("--replay: %s is not a directory", dir)
+	}
+	return &replayClient{dir: dir}, nil
+}
+
+func (c *replayClient) Run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	key := ghRecordingKey(stdin, args)
+	var raw []byte
+	var err error
+	for seq := c.calls.next(key); seq > 0; seq-- {
+		raw, err = os.ReadFile(ghRecordingPath(c.dir, key, seq))
+		if !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// "not found" makes the call fail permanently instead of retrying.
+			return nil, fmt.Errorf("gh %s: recording not found in %s", strings.Join(args, " "), c.dir)
+		}
+		return nil, err
+	}
+	var rec ghRecording
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return nil, fmt.Errorf("replay %s: %w", key, err)
+	}
+	if rec.Error != "" {
+		return nil, &cmdError{msg: rec.Error, stdout: []byte(rec.Stdout), stderr: rec.Stderr}
+	}
+	return []byte(rec.Stdout), nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// scriptedClient returns its responses in order, one per call.
+type scriptedClient struct {
+	outs []string
+	errs []error
+}
+
+func (c *scriptedClient) Run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	out, err := c.outs[0], c.errs[0]
+	c.outs, c.errs = c.outs[1:], c.errs[1:]
+	return []byte(out), err
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	next := &scriptedClient{
+		outs: []string{`{"n":1}`, `{"n":2}`, ""},
+		errs: []error{nil, nil, errors.New("gh pr merge: HTTP 405: merge conflict")},
+	}
+	rec, err := newRecordingClient(next, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	_, _ = rec.Run(ctx, nil, "pr", "view", "u")
+	_, _ = rec.Run(ctx, nil, "pr", "view", "u")
+	_, _ = rec.Run(ctx, []byte("body"), "pr", "merge")
+
+	rep, err := newReplayClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{`{"n":1}`, `{"n":2}`, `{"n":2}`} {
+		out, err := rep.Run(ctx, nil, "pr", "view", "u")
+		if err != nil || string(out) != want {
+			t.Errorf("view #%d = %q, %v; want %q", i+1, out, err, want)
+		}
+	}
+	if _, err := rep.Run(ctx, []byte("body"), "pr", "merge"); err == nil || err.Error() != "gh pr merge: HTTP 405: merge conflict" {
+		t.Errorf("merge err = %v; want the recorded error", err)
+	}
+	// Failed calls replay with gh's output, so they classify the same.
+	rec.next = &scriptedClient{outs: []string{""}, errs: []error{&cmdError{
+		msg:    "gh api repos/o/r: gh: Server Error (HTTP 502)",
+		stdout: []byte(`{"message":"Server Error","status":"502"}`),
+		stderr: "gh: Server Error (HTTP 502)",
+	}}}
+	_, _ = rec.Run(ctx, nil, "api", "repos/o/r")
+	if _, err := rep.Run(ctx, nil, "api", "repos/o/r"); classifyError(err) != Transient {
+		t.Errorf("replayed 502 = %v (%v); want transient", err, classifyError(err))
+	}
+	// Different stdin is a different call.
+	_, err = rep.Run(ctx, []byte("other"), "pr", "merge")
+	if err == nil || classifyError(err) != Permanent {
+		t.Errorf("unrecorded call err = %v (%v); want a permanent error", err, classifyError(err))
+	}
+}
+
+func TestRecordSkipsCancelledCalls(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := newRecordingClient(&scriptedClient{outs: []string{""}, errs: []error{context.Canceled}}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _ = rec.Run(ctx, nil, "pr", "view", "u")
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("recorded %d files for a cancelled call", len(entries))
+	}
+}
+
+func TestReplayReproducesRun(t *testing.T) {
+	failing := fakePR("misty-step/api", 2)
+	failing.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "lint", Status: "COMPLETED", Conclusion: "FAILURE"}}
+	fake := newFakeGitHub(fakePR("misty-step/api", 1), failing)
+
+	dir := t.TempDir()
+	rec, err := newRecordingClient(fake, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useFakeGitHub(t, fake)
+	githubClient = rec
+	recorded, err := newPipeline(testPipelineOptions(t)).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := newReplayClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	githubClient = rep
+	replayed, err := newPipeline(testPipelineOptions(t)).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed.Results, recorded.Results) {
+		t.Errorf("replayed results = %+v\nrecorded = %+v", replayed.Results, recorded.Results)
+	}
+}
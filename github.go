@@ -0,0 +1,52 @@
+package main
+
+import "context"
+
+// GitHubClient runs gh CLI commands. Every GitHub call the pipeline makes
+// goes through it, so tests can swap in a fake and exercise the decision
+// logic without a gh binary.
+type GitHubClient interface {
+	// Run runs gh with args, feeding it stdin when non-nil, and returns stdout.
+	Run(ctx context.Context, stdin []byte, args ...string) ([]byte, error)
+}
+
+// ghCLI is the real client: it execs gh, with the per-call timeout and
+// GitHub App token handled by runCmdInput.
+type ghCLI struct{}
+
+func (ghCLI) Run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	return runCmdInput(ctx, stdin, "gh", args...)
+}
+
+// githubClient is the client gh calls use unless their context carries
+// another (replaced in tests).
+var githubClient GitHubClient = ghCLI{}
+
+type githubClientKey struct{}
+
+// withGitHubClient returns ctx with c as the client for gh calls made
+// under it. A Pipeline runs with its own client this way.
+func withGitHubClient(ctx context.Context, c GitHubClient) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, githubClientKey{}, c)
+}
+
+// githubClientFor returns the client for gh calls made under ctx.
+func githubClientFor(ctx context.Context) GitHubClient {
+	if c, ok := ctx.Value(githubClientKey{}).(GitHubClient); ok {
+		return c
+	}
+	return githubClient
+}
+
+// runGh runs a gh command through the context's client.
+func runGh(ctx context.Context, args ...string) ([]byte, error) {
+	return githubClientFor(ctx).Run(ctx, nil, args...)
+}
+
+// runGhInput is runGh with stdin (e.g. a JSON body for `gh api --input -`).
+func runGhInput(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	return githubClientFor(ctx).Run(ctx, stdin, args...)
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGitHub is an in-memory GitHubClient holding a set of open PRs. It
+// answers the gh commands the pipeline issues for them and records the
+// writes (merges, comments, branch updates) for tests to inspect.
+type fakeGitHub struct {
+	mu        sync.Mutex
+	prs       []*prView
+	updatedAt map[string]time.Time
+	comments  map[string][]issueComment // by PR URL
+	// mergeErrors makes the merge mutation fail for these PR node IDs.
+	mergeErrors map[string]error
+	threads     map[string][]reviewThread // unresolved, by PR URL
+	files       map[string][]string       // changed paths, by PR URL
+	commits     map[string][]prCommit     // by PR URL; default one signed commit by the author
+	diffs       map[string]string         // by PR URL
+
+	calls     []string
+	merged    []string // PR node IDs
+	dismissed []string // review node IDs
+	posted    map[string][]string
+	unhandled []string
+}
+
+func newFakeGitHub(prs ...*prView) *fakeGitHub {
+	return &fakeGitHub{
+		prs:         prs,
+		updatedAt:   map[string]time.Time{},
+		comments:    map[string][]issueComment{},
+		posted:      map[string][]string{},
+		mergeErrors: map[string]error{},
+		threads:     map[string][]reviewThread{},
+		files:       map[string][]string{},
+		commits:     map[string][]prCommit{},
+		diffs:       map[string]string{},
+	}
+}
+
+// useFakeGitHub swaps f in as the GitHubClient for the test.
+func useFakeGitHub(t *testing.T, f *fakeGitHub) {
+	t.Helper()
+	old := githubClient
+	githubClient = f
+	t.Cleanup(func() { githubClient = old })
+}
+
+func (f *fakeGitHub) pr(url string) *prView {
+	for _, p := range f.prs {
+		if p.URL == url {
+			return p
+		}
+	}
+	return nil
+}
+
+func (f *fakeGitHub) prByID(id string) *prView {
+	for _, p := range f.prs {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+var (
+	fakeCommentsPathRe = regexp.MustCompile(`^repos/([^/]+/[^/]+)/issues/(\d+)/comments`)
+	fakeEditPathRe     = regexp.MustCompile(`^repos/[^/]+/[^/]+/issues/comments/(\d+)$`)
+	fakeFilesPathRe    = regexp.MustCompile(`^repos/([^/]+/[^/]+)/pulls/(\d+)/files`)
+	fakeCommitsPathRe  = regexp.MustCompile(`^repos/([^/]+/[^/]+)/pulls/(\d+)/commits`)
+)
+
+func (f *fakeGitHub) Run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, strings.Join(args, " "))
+	out, err, ok := f.handle(args)
+	if !ok {
+		f.unhandled = append(f.unhandled, strings.Join(args, " "))
+		return nil, fmt.Errorf("gh %s: fake has no handler (HTTP 404)", strings.Join(args, " "))
+	}
+	return out, err
+}
+
+func (f *fakeGitHub) handle(args []string) ([]byte, error, bool) {
+	switch {
+	case len(args) >= 2 && args[0] == "api" && args[1] == "graphql":
+		return f.graphql(ghFields(args))
+	case len(args) >= 3 && args[0] == "pr" && args[1] == "view":
+		p := f.pr(args[2])
+		if p == nil {
+			return nil, fmt.Errorf("no pull requests found for %s (HTTP 404)", args[2]), true
+		}
+		if slices.Contains(args, "reviews") {
+			return nil, nil, true
+		}
+		if slices.ContainsFunc(args, func(a string) bool { return strings.Contains(a, "state") }) {
+			// A webhook target lookup: every PR the fake holds is open.
+			b, err := json.Marshal(struct {
+				*prView
+				State     string    `json:"state"`
+				UpdatedAt time.Time `json:"updatedAt"`
+			}{p, "OPEN", f.updatedAt[p.URL]})
+			return b, err, true
+		}
+		b, err := json.Marshal(p)
+		return b, err, true
+	case len(args) >= 3 && args[0] == "pr" && args[1] == "diff":
+		return []byte(f.diffs[args[2]]), nil, true
+	case len(args) >= 5 && args[0] == "pr" && args[1] == "comment":
+		f.posted[args[2]] = append(f.posted[args[2]], args[4])
+		return nil, nil, true
+	case len(args) >= 3 && args[0] == "pr" && (args[1] == "update-branch" || args[1] == "ready" || args[1] == "close"):
+		return nil, nil, true
+	case len(args) >= 2 && args[0] == "api":
+		return f.rest(args[1:])
+	}
+	return nil, nil, false
+}
+
+func (f *fakeGitHub) graphql(fields map[string]string) ([]byte, error, bool) {
+	query := fields["query"]
+	switch {
+	case strings.Contains(query, "search(query"):
+		var nodes []map[string]any
+		for _, p := range f.prs {
+			repo, _, _ := strings.Cut(strings.TrimPrefix(p.URL, "https://github.com/"), "/pull/")
+			number, _ := strconv.Atoi(p.URL[strings.LastIndex(p.URL, "/")+1:])
+			nodes = append(nodes, map[string]any{
+				"url": p.URL, "title": p.Title, "body": p.Body, "isDraft": p.IsDraft, "number": number,
+				"updatedAt":  f.updatedAt[p.URL],
+				"author":     map[string]string{"login": p.Author.Login},
+				"repository": map[string]string{"nameWithOwner": repo},
+				"labels":     map[string]any{"nodes": p.Labels},
+				"createdAt":  p.CreatedAt, "mergeable": p.Mergeable, "reviewDecision": p.ReviewDecision,
+				"latestReviews": map[string]any{"nodes": p.LatestReviews},
+				"headRefOid":    p.HeadRefOid,
+				"commits": map[string]any{"nodes": []map[string]any{{"commit": map[string]any{
+					"statusCheckRollup": map[string]string{"state": overallChecksState(p.StatusCheckRollup)},
+				}}}},
+			})
+		}
+		b, err := json.Marshal(map[string]any{"data": map[string]any{"search": map[string]any{
+			"issueCount": len(nodes), "pageInfo": map[string]any{"hasNextPage": false}, "nodes": nodes,
+		}}})
+		return b, err, true
+	case strings.Contains(query, "repositoryOwner"):
+		return []byte(`{"data":{"repositoryOwner":{"repositories":{"pageInfo":{"hasNextPage":false},"nodes":[]}}}}`), nil, true
+	case strings.Contains(query, "mergeQueue("):
+		return []byte(`{"data":{"repository":{"mergeQueue":null}}}`), nil, true
+	case strings.Contains(query, "reviewThreads("):
+		url := fmt.Sprintf("https://github.com/%s/%s/pull/%s", fields["owner"], fields["name"], fields["number"])
+		var nodes []map[string]any
+		for _, t := range f.threads[url] {
+			nodes = append(nodes, map[string]any{
+				"isResolved": false, "path": t.Path, "line": t.Line,
+				"comments": map[string]any{"nodes": []map[string]any{{"body": t.Body, "author": map[string]string{"login": t.Author}}}},
+			})
+		}
+		b, err := json.Marshal(map[string]any{"data": map[string]any{"repository": map[string]any{"pullRequest": map[string]any{
+			"reviewThreads": map[string]any{"nodes": nodes},
+		}}}})
+		return b, err, true
+	case strings.Contains(query, "dismissPullRequestReview("):
+		f.dismissed = append(f.dismissed, fields["reviewId"])
+		return []byte(`{"data":{"dismissPullRequestReview":{"pullRequestReview":{"state":"DISMISSED"}}}}`), nil, true
+	case strings.Contains(query, "mergePullRequest("):
+		id := fields["pullRequestId"]
+		if f.prByID(id) == nil {
+			return nil, fmt.Errorf("could not resolve pull request %s (HTTP 404)", id), true
+		}
+		if err := f.mergeErrors[id]; err != nil {
+			return nil, err, true
+		}
+		if head := fields["expectedHeadOid"]; head != "" && head != f.prByID(id).HeadRefOid {
+			return nil, errors.New("gh: Head branch was modified. Review and try the merge again."), true
+		}
+		f.merged = append(f.merged, id)
+		return []byte(`{"data":{"mergePullRequest":{"pullRequest":{"merged":true,"mergeCommit":{"oid":"merge-` + id + `"}}}}}`), nil, true
+	}
+	return nil, nil, false
+}
+
+func (f *fakeGitHub) rest(args []string) ([]byte, error, bool) {
+	method := "GET"
+	var path string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-X":
+			method = args[i+1]
+			i++
+		case "--paginate":
+		case "-f", "-H":
+			i++
+		default:
+			if path == "" {
+				path = args[i]
+			}
+		}
+	}
+	switch {
+	case strings.Contains(path, "/rules/branches/"):
+		return []byte(`[]`), nil, true
+	case strings.Contains(path, "/branches/"):
+		return []byte(`{"name":"main","protection":{"enabled":false}}`), nil, true
+	case method == "GET" && fakeCommentsPathRe.MatchString(path):
+		m := fakeCommentsPathRe.FindStringSubmatch(path)
+		url := fmt.Sprintf("https://github.com/%s/pull/%s", m[1], m[2])
+		b, err := json.Marshal(f.comments[url])
+		return b, err, true
+	case method == "GET" && fakeFilesPathRe.MatchString(path):
+		m := fakeFilesPathRe.FindStringSubmatch(path)
+		files := []map[string]string{}
+		for _, name := range f.files[fmt.Sprintf("https://github.com/%s/pull/%s", m[1], m[2])] {
+			files = append(files, map[string]string{"filename": name})
+		}
+		b, err := json.Marshal(files)
+		return b, err, true
+	case method == "GET" && fakeCommitsPathRe.MatchString(path):
+		m := fakeCommitsPathRe.FindStringSubmatch(path)
+		url := fmt.Sprintf("https://github.com/%s/pull/%s", m[1], m[2])
+		commits, ok := f.commits[url]
+		if p := f.pr(url); !ok && p != nil {
+			commits = []prCommit{{SHA: p.HeadRefOid, Author: p.Author.Login, Committer: p.Author.Login, Verified: true}}
+		}
+		account := func(login string) any {
+			if login == "" {
+				return nil
+			}
+			return map[string]string{"login": login}
+		}
+		page := []map[string]any{}
+		for _, c := range commits {
+			page = append(page, map[string]any{
+				"sha": c.SHA, "author": account(c.Author), "committer": account(c.Committer),
+				"commit": map[string]any{"verification": map[string]any{"verified": c.Verified}},
+			})
+		}
+		b, err := json.Marshal(page)
+		return b, err, true
+	case method == "PATCH" && fakeEditPathRe.MatchString(path):
+		return nil, nil, true
+	}
+	return nil, nil, false
+}
+
+// called reports whether any recorded gh call starts with prefix.
+func (f *fakeGitHub) called(prefix string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.calls {
+		if strings.HasPrefix(c, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fakePR returns a mergeable PR by the default immediate-merge author with
+// green checks and an approval; tests adjust it from there.
+func fakePR(repo string, number int) *prView {
+	p := &prView{
+		ID:               fmt.Sprintf("PR_%s_%d", strings.ReplaceAll(repo, "/", "_"), number),
+		URL:              fmt.Sprintf("https://github.com/%s/pull/%d", repo, number),
+		Title:            "Fix things",
+		Mergeable:        "MERGEABLE",
+		ReviewDecision:   "APPROVED",
+		MergeStateStatus: "CLEAN",
+		BaseRefName:      "main",
+		HeadRefName:      "fix-things",
+		HeadRefOid:       "abc123",
+		StatusCheckRollup: []statusRollupEntry{
+			{Typename: "CheckRun", Name: "test", Status: "COMPLETED", Conclusion: "SUCCESS"},
+		},
+	}
+	p.Author.Login = "kaylee-mistystep"
+	return p
+}
+
+// testPipelineOptions parses args on top of the defaults, with state kept in
+// a temp dir and no rate-limit lookups.
+func testPipelineOptions(t *testing.T, args ...string) *runOptions {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts := registerRunFlags(fs)
+	base := []string{"-state-file", filepath.Join(t.TempDir(), "state.json"), "-rate-limit-floor", "0", "-ci-log-lines", "0"}
+	if err := fs.Parse(append(base, args...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestRunPipelineWithFakeGitHub(t *testing.T) {
+	green := fakePR("misty-step/api", 1)
+
+	failing := fakePR("misty-step/api", 2)
+	failing.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "lint", Status: "COMPLETED", Conclusion: "FAILURE"}}
+
+	unreviewed := fakePR("misty-step/web", 3)
+	unreviewed.ReviewDecision = "REVIEW_REQUIRED"
+
+	held := fakePR("misty-step/web", 4)
+	held.Labels = []label{{Name: "do not touch"}}
+
+	draft := fakePR("misty-step/web", 5)
+	draft.IsDraft = true
+
+	fake := newFakeGitHub(green, failing, unreviewed, held, draft)
+	now := time.Now()
+	for i, p := range fake.prs {
+		fake.updatedAt[p.URL] = now.Add(-time.Duration(i) * time.Minute)
+	}
+	useFakeGitHub(t, fake)
+
+	out, err := newPipeline(testPipelineOptions(t, "-max-prs", "10")).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, r := range out.Results {
+		got[r.URL] = r.Action + "/" + r.Reason
+	}
+	want := map[string]string{
+		green.URL:      "merged/",
+		failing.URL:    "lint_dispatched/checks_failure",
+		unreviewed.URL: "commented/review_required",
+	}
+	for url, w := range want {
+		if got[url] != w {
+			t.Errorf("%s: got %q; want %q", url, got[url], w)
+		}
+	}
+	if len(out.Results) != len(want) {
+		t.Errorf("results = %v; the held and draft PRs should not be selected", got)
+	}
+	if out.Scanned != 5 {
+		t.Errorf("scanned = %d; want 5", out.Scanned)
+	}
+	if !slices.Equal(fake.merged, []string{green.ID}) {
+		t.Errorf("merged = %v; want only %s", fake.merged, green.ID)
+	}
+	if len(fake.posted[failing.URL]) != 1 || !strings.Contains(fake.posted[failing.URL][0], "lint") {
+		t.Errorf("failing PR comments = %q", fake.posted[failing.URL])
+	}
+	if len(fake.unhandled) > 0 {
+		t.Errorf("unhandled gh calls:\n%s", strings.Join(fake.unhandled, "\n"))
+	}
+}
+
+func TestRunPipelineDryRunMakesNoWrites(t *testing.T) {
+	failing := fakePR("misty-step/api", 2)
+	failing.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "build", Status: "COMPLETED", Conclusion: "FAILURE"}}
+	fake := newFakeGitHub(fakePR("misty-step/api", 1), failing)
+	useFakeGitHub(t, fake)
+
+	out, err := newPipeline(testPipelineOptions(t, "-dry-run")).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range out.Results {
+		if r.Action != "skipped" || !strings.HasPrefix(r.Reason, "dry_run_") {
+			t.Errorf("%s: %s/%s; want a dry_run_ skip", r.URL, r.Action, r.Reason)
+		}
+	}
+	if len(fake.merged) > 0 || len(fake.posted) > 0 || fake.called("pr update-branch") {
+		t.Errorf("dry run wrote to GitHub: merged=%v posted=%v", fake.merged, fake.posted)
+	}
+}
+
+func TestRunPipelineHoldLeavesConflictingBranch(t *testing.T) {
+	pr := fakePR("misty-step/api", 1)
+	pr.Mergeable = "CONFLICTING"
+	pr.Labels = []label{{Name: "hold"}}
+	fake := newFakeGitHub(pr)
+	useFakeGitHub(t, fake)
+
+	out, err := newPipeline(testPipelineOptions(t, "-attempt-rebase")).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 1 || out.Results[0].Action != "commented" {
+		t.Errorf("results = %+v; want the conflict comment only", out.Results)
+	}
+	if fake.called("pr update-branch") {
+		t.Error("updated the branch of a held PR")
+	}
+}
+
+func TestRunPipelineMergeFailureIsError(t *testing.T) {
+	pr := fakePR("misty-step/api", 1)
+	fake := newFakeGitHub(pr)
+	fake.mergeErrors[pr.ID] = errors.New("Pull request is not mergeable: base branch policy prohibits the merge (HTTP 403)")
+	useFakeGitHub(t, fake)
+
+	out, err := newPipeline(testPipelineOptions(t)).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 1 || out.Results[0].Action != "error" || !strings.Contains(out.Results[0].Reason, "merge failed") {
+		t.Errorf("results = %+v", out.Results)
+	}
+}
+
+func TestRunPipelineMergePinsHeadSHA(t *testing.T) {
+	for _, pushed := range []bool{false, true} {
+		pr := fakePR("misty-step/api", 1)
+		fake := newFakeGitHub(pr)
+		p := newPipeline(testPipelineOptions(t))
+		var merges int
+		p.client = ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+			if strings.Contains(strings.Join(args, " "), "mergePullRequest(") {
+				merges++
+				if !slices.Contains(args, "expectedHeadOid=abc123") {
+					t.Errorf("merge not pinned to the viewed head: %q", args)
+				}
+				if pushed {
+					pr.HeadRefOid = "def456" // a push lands between the view and the merge
+				}
+			}
+			return fake.Run(ctx, stdin, args...)
+		})
+
+		out, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Results) != 1 {
+			t.Fatalf("results = %+v", out.Results)
+		}
+		got := out.Results[0].Action + "/" + out.Results[0].Reason
+		switch {
+		case pushed && (got != "skipped/head_moved" || len(fake.merged) != 0 || merges != 1):
+			t.Errorf("head moved: %s, merged %v after %d attempts; want one attempt, deferred", got, fake.merged, merges)
+		case !pushed && (got != "merged/" || len(fake.merged) != 1):
+			t.Errorf("head unchanged: %s, merged %v", got, fake.merged)
+		}
+	}
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go SQLite driver, registered as "sqlite"
+)
+
+// historySchema creates the run history tables. Timestamps are stored as
+// RFC 3339 UTC text so they sort and compare lexically.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at  TEXT    NOT NULL,
+	finished_at TEXT    NOT NULL,
+	org         TEXT    NOT NULL,
+	dry_run     INTEGER NOT NULL,
+	ok          INTEGER NOT NULL,
+	error       TEXT    NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS outcomes (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id           INTEGER NOT NULL REFERENCES runs(id),
+	recorded_at      TEXT    NOT NULL,
+	url              TEXT    NOT NULL,
+	repo             TEXT    NOT NULL,
+	number           INTEGER NOT NULL,
+	author           TEXT    NOT NULL,
+	action           TEXT    NOT NULL,
+	reason           TEXT    NOT NULL DEFAULT '',
+	merge_commit_oid TEXT    NOT NULL DEFAULT '',
+	checks_state     TEXT    NOT NULL DEFAULT '',
+	mergeable        TEXT    NOT NULL DEFAULT '',
+	review_decision  TEXT    NOT NULL DEFAULT '',
+	ci_failure_type  TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS outcomes_recorded_at ON outcomes(recorded_at);
+CREATE INDEX IF NOT EXISTS outcomes_url ON outcomes(url);
+CREATE TABLE IF NOT EXISTS dedup_state (
+	org            TEXT PRIMARY KEY,
+	hash           TEXT NOT NULL,
+	last_posted_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS breaker_state (
+	breaker         TEXT    NOT NULL,
+	key             TEXT    NOT NULL,
+	failures        INTEGER NOT NULL DEFAULT 0,
+	skips_remaining INTEGER NOT NULL DEFAULT 0,
+	penalty         INTEGER NOT NULL DEFAULT 0,
+	updated_at      TEXT    NOT NULL,
+	PRIMARY KEY (breaker, key)
+);
+`
+
+// historyMigrations add the columns introduced after the first schema.
+// Each runs on every open; one that fails because the column already
+// exists is skipped.
+var historyMigrations = []string{
+	`ALTER TABLE outcomes ADD COLUMN time_to_merge_seconds INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE runs ADD COLUMN outcomes INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE runs ADD COLUMN merged INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE runs ADD COLUMN errors INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE runs ADD COLUMN merge_rate REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE runs ADD COLUMN error_rate REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE runs ADD COLUMN median_time_to_merge_seconds INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE outcomes ADD COLUMN reason_code TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE outcomes ADD COLUMN reason_detail TEXT NOT NULL DEFAULT ''`,
+}
+
+// historyDB persists every run and its per-PR outcomes to a local SQLite
+// file so past pipeline activity can be queried after the fact. When it's
+// configured it is also the one store for the report dedup state, the
+// circuit breakers, and the hourly per-repo action counts, in place of
+// the files beside the state file.
+type historyDB struct {
+	db *sql.DB
+}
+
+// openHistoryDB opens (creating if needed) the history database at path.
+func openHistoryDB(path string) (*historyDB, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create history dir: %w", err)
+		}
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open history db: %w", err)
+	}
+	// SQLite serializes writers; a single connection avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("init history db: %w", err)
+	}
+	for _, m := range historyMigrations {
+		if _, err := db.Exec(m); err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			_ = db.Close()
+			return nil, fmt.Errorf("migrate history db: %w", err)
+		}
+	}
+	return &historyDB{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (h *historyDB) Close() error {
+	return h.db.Close()
+}
+
+// RecordRun stores the run and all of its outcomes in one transaction.
+func (h *historyDB) RecordRun(out runOutput, finishedAt time.Time) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	finished := finishedAt.UTC().Format(time.RFC3339)
+	stats := computeRunStats(out.Results)
+	res, err := tx.Exec(
+		`INSERT INTO runs (
+			started_at, finished_at, org, dry_run, ok, error,
+			outcomes, merged, errors, merge_rate, error_rate, median_time_to_merge_seconds
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		out.StartedAt, finished, out.Org, out.DryRun, out.Ok, out.Error,
+		stats.Outcomes, stats.Merged, stats.Errors, stats.MergeRate, stats.ErrorRate, stats.MedianTimeToMergeSeconds,
+	)
+	if err != nil {
+		return fmt.Errorf("insert run: %w", err)
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO outcomes (
+		run_id, recorded_at, url, repo, number, author, action, reason,
+		merge_commit_oid, checks_state, mergeable, review_decision, ci_failure_type, time_to_merge_seconds,
+		reason_code, reason_detail
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+	for _, r := range out.Results {
+		if _, err := stmt.Exec(
+			runID, finished, r.URL, r.Repo, r.Number, r.Author, r.Action, r.Reason,
+			r.MergeCommitOID, r.ChecksState, r.Mergeable, r.ReviewDecision, r.CIFailureType, r.TimeToMergeSeconds,
+			r.ReasonCode, r.ReasonDetail,
+		); err != nil {
+			return fmt.Errorf("insert outcome: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// CountActions returns how many outcomes with the given action were
+// recorded at or after since (e.g. merges this week).
+func (h *historyDB) CountActions(action string, since time.Time) (int, error) {
+	var n int
+	err := h.db.QueryRow(
+		`SELECT COUNT(*) FROM outcomes WHERE action = ? AND recorded_at >= ?`,
+		strings.TrimSpace(action), since.UTC().Format(time.RFC3339),
+	).Scan(&n)
+	return n, err
+}
+
+// WeekTrend compares the 7 days before now with the 7 days before that,
+// over non-dry runs, adding current (the run not yet recorded) to this
+// week.
+func (h *historyDB) WeekTrend(now time.Time, current []prOutcome) (weekTrend, error) {
+	weekAgo := now.Add(-7 * 24 * time.Hour)
+	merged, errs, ttm, err := h.windowActivity(weekAgo, now)
+	if err != nil {
+		return weekTrend{}, err
+	}
+	prevMerged, prevErrs, prevTTM, err := h.windowActivity(weekAgo.Add(-7*24*time.Hour), weekAgo)
+	if err != nil {
+		return weekTrend{}, err
+	}
+	for _, r := range current {
+		switch outcomeBucket(r.Action) {
+		case "merged":
+			merged++
+			if r.TimeToMergeSeconds > 0 {
+				ttm = append(ttm, r.TimeToMergeSeconds)
+			}
+		case "error":
+			errs[r.URL] = true
+		}
+	}
+	return weekTrend{
+		Merged:                       merged,
+		PrevMerged:                   prevMerged,
+		Errors:                       len(errs),
+		PrevErrors:                   len(prevErrs),
+		MedianTimeToMergeSeconds:     median(ttm),
+		PrevMedianTimeToMergeSeconds: median(prevTTM),
+	}, nil
+}
+
+// windowActivity returns the merges, the set of PRs with errors, and the
+// merged PRs' times to merge recorded in [from, to) by non-dry runs.
+func (h *historyDB) windowActivity(from time.Time, to time.Time) (int, map[string]bool, []int64, error) {
+	rows, err := h.db.Query(
+		`SELECT o.url, o.action, o.time_to_merge_seconds FROM outcomes o JOIN runs r ON r.id = o.run_id
+		WHERE r.dry_run = 0 AND o.recorded_at >= ? AND o.recorded_at < ?`,
+		from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	merged, errs, ttm := 0, map[string]bool{}, []int64{}
+	for rows.Next() {
+		var url, action string
+		var secs int64
+		if err := rows.Scan(&url, &action, &secs); err != nil {
+			return 0, nil, nil, err
+		}
+		switch outcomeBucket(action) {
+		case "merged":
+			merged++
+			if secs > 0 {
+				ttm = append(ttm, secs)
+			}
+		case "error":
+			errs[url] = true
+		}
+	}
+	return merged, errs, ttm, rows.Err()
+}
+
+// recordHistory writes the run to the history DB at path, if configured.
+// Failures are logged, never fatal: history is an audit aid, not a gate.
+func recordHistory(path string, out runOutput) {
+	if strings.TrimSpace(path) == "" {
+		return
+	}
+	h, err := openHistoryDB(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[history] %v\n", err)
+		return
+	}
+	defer func() { _ = h.Close() }()
+	if err := h.RecordRun(out, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "[history] record run failed: %v\n", err)
+	}
+}
+
+// openRunHistory opens the history DB at path for a run to read and save
+// its state in. It returns nil, logging why, when path is empty or the DB
+// can't be opened; the run then keeps its state in files.
+func openRunHistory(path string) *historyDB {
+	if strings.TrimSpace(path) == "" {
+		return nil
+	}
+	h, err := openHistoryDB(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[history] %v; keeping state in files\n", err)
+		return nil
+	}
+	return h
+}
+
+// LoadState returns org's report dedup state; none saved is the zero
+// state.
+func (h *historyDB) LoadState(org string) (runState, error) {
+	var state runState
+	err := h.db.QueryRow(`SELECT hash, last_posted_at FROM dedup_state WHERE org = ?`, org).
+		Scan(&state.Hash, &state.LastPostedAt)
+	if err == sql.ErrNoRows {
+		return runState{}, nil
+	}
+	return state, err
+}
+
+// SaveState records that org's report with hash was posted at now.
+func (h *historyDB) SaveState(org, hash string, now time.Time) error {
+	_, err := h.db.Exec(
+		`INSERT INTO dedup_state (org, hash, last_posted_at) VALUES (?, ?, ?)
+		ON CONFLICT (org) DO UPDATE SET hash = excluded.hash, last_posted_at = excluded.last_posted_at`,
+		org, hash, now.UTC().Format(time.RFC3339),
+	)
+	return err
+}
+
+// LoadBreaker returns the saved entries of the breaker named name.
+func (h *historyDB) LoadBreaker(name string) (map[string]breakerEntry, error) {
+	rows, err := h.db.Query(
+		`SELECT key, failures, skips_remaining, penalty, updated_at FROM breaker_state WHERE breaker = ?`, name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	entries := map[string]breakerEntry{}
+	for rows.Next() {
+		var key, updated string
+		var e breakerEntry
+		if err := rows.Scan(&key, &e.Failures, &e.SkipsRemaining, &e.Penalty, &updated); err != nil {
+			return nil, err
+		}
+		if e.UpdatedAt, err = time.Parse(time.RFC3339, updated); err != nil {
+			continue
+		}
+		entries[key] = e
+	}
+	return entries, rows.Err()
+}
+
+// SaveBreaker replaces the saved entries of the breaker named name.
+func (h *historyDB) SaveBreaker(name string, entries map[string]breakerEntry) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(`DELETE FROM breaker_state WHERE breaker = ?`, name); err != nil {
+		return err
+	}
+	for key, e := range entries {
+		if _, err := tx.Exec(
+			`INSERT INTO breaker_state (breaker, key, failures, skips_remaining, penalty, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			name, key, e.Failures, e.SkipsRemaining, e.Penalty, e.UpdatedAt.UTC().Format(time.RFC3339),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RecentRepoActions returns when real runs acted (anything but a skip or
+// an error) in each repo within repoActionWindow of now.
+func (h *historyDB) RecentRepoActions(now time.Time) (repoActionLog, error) {
+	rows, err := h.db.Query(
+		`SELECT o.repo, o.recorded_at FROM outcomes o JOIN runs r ON r.id = o.run_id
+		WHERE r.dry_run = 0 AND o.action NOT IN ('skipped', 'error') AND o.recorded_at > ?`,
+		now.Add(-repoActionWindow).UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	log := repoActionLog{}
+	for rows.Next() {
+		var repo, recorded string
+		if err := rows.Scan(&repo, &recorded); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, recorded)
+		if err != nil {
+			continue
+		}
+		log[repo] = append(log[repo], t)
+	}
+	return log, rows.Err()
+}
+
+// historyFilter narrows a history query. Zero values match everything.
+type historyFilter struct {
+	Since  time.Time
+	Action string
+	Repo   string
+	Limit  int
+}
+
+// historyEntry is one persisted outcome plus when it was recorded.
+type historyEntry struct {
+	RecordedAt   string `json:"recordedAt"`
+	RunStartedAt string `json:"runStartedAt"`
+	prOutcome
+}
+
+// QueryOutcomes returns matching outcomes, newest first.
+func (h *historyDB) QueryOutcomes(f historyFilter) ([]historyEntry, error) {
+	query := `SELECT o.recorded_at, r.started_at, o.url, o.repo, o.number, o.author, o.action, o.reason,
+		o.merge_commit_oid, o.checks_state, o.mergeable, o.review_decision, o.ci_failure_type,
+		o.reason_code, o.reason_detail
+		FROM outcomes o JOIN runs r ON r.id = o.run_id WHERE 1 = 1`
+	var args []any
+	if !f.Since.IsZero() {
+		query += ` AND o.recorded_at >= ?`
+		args = append(args, f.Since.UTC().Format(time.RFC3339))
+	}
+	if f.Action != "" {
+		query += ` AND o.action = ?`
+		args = append(args, f.Action)
+	}
+	if f.Repo != "" {
+		query += ` AND o.repo = ?`
+		args = append(args, f.Repo)
+	}
+	query += ` ORDER BY o.recorded_at DESC, o.id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	entries := []historyEntry{}
+	for rows.Next() {
+		var e historyEntry
+		if err := rows.Scan(
+			&e.RecordedAt, &e.RunStartedAt, &e.URL, &e.Repo, &e.Number, &e.Author, &e.Action, &e.Reason,
+			&e.MergeCommitOID, &e.ChecksState, &e.Mergeable, &e.ReviewDecision, &e.CIFailureType,
+			&e.ReasonCode, &e.ReasonDetail,
+		); err != nil {
+			return nil, err
+		}
+		if e.ReasonCode == "" {
+			// Recorded before outcomes carried codes.
+			e.prOutcome = e.withReasonCode()
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// parseSince accepts a relative window ("7d", "12h", "30m") or an absolute
+// date ("2025-01-08" or RFC 3339) and returns the cutoff time.
+func parseSince(raw string, now time.Time) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		var n int
+		if _, err := fmt.Sscanf(days, "%d", &n); err == nil && n >= 0 && fmt.Sprint(n) == days {
+			return now.Add(-time.Duration(n) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(raw); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (want e.g. 7d, 12h, or 2025-01-08)", raw)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runHistoryCommand implements `fab-pr-pipeline history`, printing past
+// outcomes from the history DB for audit purposes. Returns the exit code.
+func runHistoryCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		dbPath = fs.String("history-db", "", "path to the SQLite history database (required)")
+		since  = fs.String("since", "7d", "only show outcomes recorded within this window (e.g. 7d, 12h) or since a date (2025-01-08)")
+		action = fs.String("action", "", "only show outcomes with this action (e.g. merged, commented, error)")
+		repo   = fs.String("repo", "", "only show outcomes for this owner/repo")
+		limit  = fs.Int("limit", 0, "maximum rows to print (0 = no limit)")
+		format = fs.String("format", "table", "output format: table or json")
+	)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if strings.TrimSpace(*dbPath) == "" {
+		fmt.Fprintln(stderr, "history: --history-db is required")
+		return 2
+	}
+	if _, err := os.Stat(*dbPath); err != nil {
+		fmt.Fprintf(stderr, "history: %v\n", err)
+		return 1
+	}
+	cutoff, err := parseSince(*since, time.Now())
+	if err != nil {
+		fmt.Fprintf(stderr, "history: %v\n", err)
+		return 2
+	}
+
+	h, err := openHistoryDB(*dbPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "history: %v\n", err)
+		return 1
+	}
+	defer func() { _ = h.Close() }()
+
+	entries, err := h.QueryOutcomes(historyFilter{
+		Since:  cutoff,
+		Action: strings.TrimSpace(*action),
+		Repo:   strings.TrimSpace(*repo),
+		Limit:  *limit,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "history: query failed: %v\n", err)
+		return 1
+	}
+
+	switch strings.ToLower(strings.TrimSpace(*format)) {
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			fmt.Fprintf(stderr, "history: %v\n", err)
+			return 1
+		}
+	case "table":
+		writeHistoryTable(stdout, entries)
+	default:
+		fmt.Fprintf(stderr, "history: unknown --format %q (want table or json)\n", *format)
+		return 2
+	}
+	return 0
+}
+
+func writeHistoryTable(w io.Writer, entries []historyEntry) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RECORDED\tACTION\tREPO\tPR\tAUTHOR\tREASON")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t#%d\t%s\t%s\n", e.RecordedAt, e.Action, e.Repo, e.Number, e.Author, e.Reason)
+	}
+	_ = tw.Flush()
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "(no matching outcomes)")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func seedHistory(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "history.db")
+	h, err := openHistoryDB(path)
+	if err != nil {
+		t.Fatalf("openHistoryDB failed: %v", err)
+	}
+	defer func() { _ = h.Close() }()
+	now := time.Now()
+	runs := []struct {
+		at      time.Time
+		results []prOutcome
+	}{
+		{now.Add(-10 * 24 * time.Hour), []prOutcome{{URL: "u1", Repo: "m/a", Number: 1, Action: "merged"}}},
+		{now.Add(-time.Hour), []prOutcome{
+			{URL: "u2", Repo: "m/a", Number: 2, Action: "merged"},
+			{URL: "u3", Repo: "m/b", Number: 3, Action: "commented", Reason: "checks_failure"},
+		}},
+	}
+	for _, r := range runs {
+		out := runOutput{StartedAt: r.at.UTC().Format(time.RFC3339), Org: "m", Results: r.results}
+		if err := h.RecordRun(out, r.at); err != nil {
+			t.Fatalf("RecordRun failed: %v", err)
+		}
+	}
+	return path
+}
+
+func TestRunHistoryCommand_json(t *testing.T) {
+	path := seedHistory(t)
+	var stdout, stderr bytes.Buffer
+	code := runHistoryCommand([]string{"--history-db", path, "--since", "7d", "--action", "merged", "--format", "json"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+	var entries []historyEntry
+	if err := json.Unmarshal(stdout.Bytes(), &entries); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, stdout.String())
+	}
+	if len(entries) != 1 || entries[0].URL != "u2" {
+		t.Errorf("expected only the recent merge u2, got %+v", entries)
+	}
+}
+
+func TestRunHistoryCommand_table(t *testing.T) {
+	path := seedHistory(t)
+	var stdout, stderr bytes.Buffer
+	code := runHistoryCommand([]string{"--history-db", path, "--since", "30d"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"RECORDED", "checks_failure", "m/b", "#1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunHistoryCommand_errors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runHistoryCommand(nil, &stdout, &stderr); code != 2 {
+		t.Errorf("missing --history-db: expected exit 2, got %d", code)
+	}
+	if code := runHistoryCommand([]string{"--history-db", filepath.Join(t.TempDir(), "missing.db")}, &stdout, &stderr); code != 1 {
+		t.Errorf("missing db file: expected exit 1, got %d", code)
+	}
+	path := seedHistory(t)
+	if code := runHistoryCommand([]string{"--history-db", path, "--since", "yesterday"}, &stdout, &stderr); code != 2 {
+		t.Errorf("bad --since: expected exit 2, got %d", code)
+	}
+	if code := runHistoryCommand([]string{"--history-db", path, "--format", "xml"}, &stdout, &stderr); code != 2 {
+		t.Errorf("bad --format: expected exit 2, got %d", code)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		raw     string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"7d", now.Add(-7 * 24 * time.Hour), false},
+		{"12h", now.Add(-12 * time.Hour), false},
+		{"2025-01-08", time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC), false},
+		{"2025-01-08T06:00:00Z", time.Date(2025, 1, 8, 6, 0, 0, 0, time.UTC), false},
+		{"xd", time.Time{}, true},
+		{"last week", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.raw, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSince(%q) err = %v; wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v; want %v", tt.raw, got, tt.want)
+		}
+	}
+}
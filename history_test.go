@@ -0,0 +1,176 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryDB_RecordRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "history.db")
+	h, err := openHistoryDB(path)
+	if err != nil {
+		t.Fatalf("openHistoryDB failed: %v", err)
+	}
+	defer func() { _ = h.Close() }()
+
+	out := runOutput{
+		Ok:        true,
+		StartedAt: "2025-01-15T10:30:00Z",
+		Org:       "misty-step",
+		Results: []prOutcome{
+			{URL: "https://github.com/m/a/pull/1", Repo: "m/a", Number: 1, Author: "kaylee", Action: "merged", MergeCommitOID: "abc"},
+			{URL: "https://github.com/m/a/pull/2", Repo: "m/a", Number: 2, Author: "kaylee", Action: "commented", Reason: "checks_failure"},
+			{URL: "https://github.com/m/b/pull/3", Repo: "m/b", Number: 3, Author: "phrazzld", Action: "merged"},
+		},
+	}
+	finished := time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)
+	if err := h.RecordRun(out, finished); err != nil {
+		t.Fatalf("RecordRun failed: %v", err)
+	}
+
+	n, err := h.CountActions("merged", finished.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("CountActions failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 merges, got %d", n)
+	}
+
+	n, err = h.CountActions("merged", finished.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("CountActions failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("expected 0 merges after the run, got %d", n)
+	}
+}
+
+func TestHistoryDB_reasonCodes(t *testing.T) {
+	h, err := openHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("openHistoryDB failed: %v", err)
+	}
+	defer func() { _ = h.Close() }()
+
+	coded := prOutcome{URL: "https://github.com/m/a/pull/1", Action: "enqueued"}
+	coded.setReason(reasonMergeQueuePosition, "2")
+	// Recorded before outcomes carried codes: only the reason.
+	legacy := prOutcome{URL: "https://github.com/m/a/pull/2", Action: "skipped", Reason: "dry_run_mergeable"}
+	out := runOutput{StartedAt: "2025-01-15T10:30:00Z", Org: "m", Results: []prOutcome{coded, legacy}}
+	if err := h.RecordRun(out, time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("RecordRun failed: %v", err)
+	}
+	entries, err := h.QueryOutcomes(historyFilter{})
+	if err != nil {
+		t.Fatalf("QueryOutcomes failed: %v", err)
+	}
+	got := map[string]string{}
+	for _, e := range entries {
+		got[e.URL] = string(e.ReasonCode) + "/" + e.ReasonDetail
+	}
+	if got[coded.URL] != "merge_queue_position/2" || got[legacy.URL] != "mergeable/dry run" {
+		t.Errorf("codes = %v", got)
+	}
+}
+
+func TestHistoryDB_reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	for i := 0; i < 2; i++ {
+		recordHistory(path, runOutput{StartedAt: "2025-01-15T10:30:00Z", Org: "o", Results: []prOutcome{{URL: "u", Action: "merged"}}})
+	}
+	h, err := openHistoryDB(path)
+	if err != nil {
+		t.Fatalf("openHistoryDB failed: %v", err)
+	}
+	defer func() { _ = h.Close() }()
+	n, err := h.CountActions("merged", time.Time{})
+	if err != nil {
+		t.Fatalf("CountActions failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected outcomes from both runs to persist, got %d", n)
+	}
+}
+
+func TestRecordHistory_disabled(t *testing.T) {
+	// No path configured: must be a no-op rather than creating a file.
+	recordHistory("", runOutput{})
+}
+
+func TestHistoryDB_state(t *testing.T) {
+	h, err := openHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = h.Close() }()
+	now := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	if state, err := h.LoadState("misty-step"); err != nil || state.Hash != "" {
+		t.Errorf("LoadState before any save = %+v, %v; want empty", state, err)
+	}
+	for _, hash := range []string{"first", "second"} {
+		if err := h.SaveState("misty-step", hash, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state, err := h.LoadState("misty-step")
+	if err != nil || state.Hash != "second" || state.LastPostedAt == "" {
+		t.Errorf("LoadState = %+v, %v; want the second hash", state, err)
+	}
+	if should, _ := shouldPostState(state, "second"); should {
+		t.Error("shouldPostState with the saved hash = true; want a skip")
+	}
+
+	entries := map[string]breakerEntry{
+		"https://github.com/m/a/pull/1": {Failures: 2, UpdatedAt: now},
+		"https://github.com/m/a/pull/2": {SkipsRemaining: 3, Penalty: 1, UpdatedAt: now},
+	}
+	if err := h.SaveBreaker("circuit-breaker", entries); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SaveBreaker("circuit-breaker", map[string]breakerEntry{"https://github.com/m/a/pull/2": entries["https://github.com/m/a/pull/2"]}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := h.LoadBreaker("circuit-breaker")
+	if err != nil || len(got) != 1 || got["https://github.com/m/a/pull/2"] != entries["https://github.com/m/a/pull/2"] {
+		t.Errorf("LoadBreaker = %+v, %v; want only the last save's entry", got, err)
+	}
+	if other, err := h.LoadBreaker("repo-circuit-breaker"); err != nil || len(other) != 0 {
+		t.Errorf("LoadBreaker(repo-circuit-breaker) = %+v, %v; want empty", other, err)
+	}
+}
+
+func TestHistoryDB_RecentRepoActions(t *testing.T) {
+	h, err := openHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = h.Close() }()
+	now := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	results := []prOutcome{
+		{URL: "https://github.com/m/a/pull/1", Repo: "m/a", Action: "merged"},
+		{URL: "https://github.com/m/a/pull/2", Repo: "m/a", Action: "commented"},
+		{URL: "https://github.com/m/a/pull/3", Repo: "m/a", Action: "skipped"},
+		{URL: "https://github.com/m/b/pull/4", Repo: "m/b", Action: "error"},
+	}
+	for _, run := range []struct {
+		out runOutput
+		at  time.Time
+	}{
+		{runOutput{Org: "m", Results: results}, now.Add(-10 * time.Minute)},
+		{runOutput{Org: "m", Results: results}, now.Add(-2 * time.Hour)},
+		{runOutput{Org: "m", DryRun: true, Results: results}, now.Add(-5 * time.Minute)},
+	} {
+		if err := h.RecordRun(run.out, run.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log, err := h.RecentRepoActions(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.recent("m/a") != 2 || log.recent("m/b") != 0 {
+		t.Errorf("recent actions = %v; want the real run's merge and comment in m/a from the last hour", log)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// intentMaxAge is how long an unfinished intent is kept. Past it the PR has
+// almost certainly moved on, and its head SHA with it.
+const intentMaxAge = 7 * 24 * time.Hour
+
+// Intent actions.
+const (
+	intentMerge   = "merge"
+	intentComment = "comment" // plus ":" and the reason commented on
+)
+
+// actionIntent is a merge or comment the pipeline started on a PR.
+type actionIntent struct {
+	URL       string    `json:"url"`
+	HeadSHA   string    `json:"headSha"`
+	Action    string    `json:"action"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// intentLog is the merges and comments started but not seen to finish,
+// across runs. Each is saved before the pipeline acts and removed once the
+// call returns, so one still there means a run died in between, and the
+// action may or may not have happened. A nil *intentLog (dry runs) records
+// nothing.
+type intentLog struct {
+	path    string
+	pending map[string]actionIntent
+}
+
+// intentsPath returns where the log is kept, beside the dedup state file.
+func intentsPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "intents.json")
+}
+
+// intentKey identifies an action on a PR at one head commit.
+func intentKey(url string, headSHA string, action string) string {
+	return url + "@" + headSHA + " " + action
+}
+
+// loadIntents reads the log at path, dropping intents older than
+// intentMaxAge. A missing or unreadable file is an empty log.
+func loadIntents(path string, now time.Time) *intentLog {
+	l := &intentLog{path: path, pending: map[string]actionIntent{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return l
+	}
+	if err := json.Unmarshal(data, &l.pending); err != nil {
+		fmt.Fprintf(os.Stderr, "[intent] ignoring unreadable %s: %v\n", path, err)
+		l.pending = map[string]actionIntent{}
+		return l
+	}
+	for key, in := range l.pending {
+		if now.Sub(in.StartedAt) > intentMaxAge {
+			delete(l.pending, key)
+		}
+	}
+	return l
+}
+
+// unfinished reports whether an earlier run started the action and never
+// saw it finish.
+func (l *intentLog) unfinished(url string, headSHA string, action string) bool {
+	if l == nil {
+		return false
+	}
+	_, ok := l.pending[intentKey(url, headSHA, action)]
+	return ok
+}
+
+// start records the action before it's attempted.
+func (l *intentLog) start(url string, headSHA string, action string, now time.Time) {
+	if l == nil {
+		return
+	}
+	l.pending[intentKey(url, headSHA, action)] = actionIntent{URL: url, HeadSHA: headSHA, Action: action, StartedAt: now.UTC()}
+	l.save()
+}
+
+// finish clears the action once its outcome is known, success or not.
+func (l *intentLog) finish(url string, headSHA string, action string) {
+	if l == nil {
+		return
+	}
+	key := intentKey(url, headSHA, action)
+	if _, ok := l.pending[key]; !ok {
+		return
+	}
+	delete(l.pending, key)
+	l.save()
+}
+
+func (l *intentLog) save() {
+	data, err := json.MarshalIndent(l.pending, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(l.path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(l.path, data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[intent] failed to save %s: %v\n", l.path, err)
+	}
+}
+
+// ghPRState returns the PR's state: OPEN, CLOSED, or MERGED.
+func ghPRState(ctx context.Context, prURL string) (string, error) {
+	stdout, err := runGh(ctx, "pr", "view", prURL, "--json", "state")
+	if err != nil {
+		return "", err
+	}
+	var v struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(stdout, &v); err != nil {
+		return "", fmt.Errorf("parse gh pr view json: %w", err)
+	}
+	return v.State, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntentLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intents.json")
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	l := loadIntents(path, now)
+	l.start("u1", "sha1", intentMerge, now)
+	l.start("u2", "sha2", intentComment+":review_required", now.Add(-8*24*time.Hour))
+
+	l = loadIntents(path, now)
+	if !l.unfinished("u1", "sha1", intentMerge) {
+		t.Error("the merge intent wasn't saved")
+	}
+	if l.unfinished("u1", "sha2", intentMerge) || l.unfinished("u1", "sha1", intentComment+":review_required") {
+		t.Error("an intent matched another head SHA or action")
+	}
+	if l.unfinished("u2", "sha2", intentComment+":review_required") {
+		t.Error("an intent older than intentMaxAge was kept")
+	}
+
+	l.finish("u1", "sha1", intentMerge)
+	if loadIntents(path, now).unfinished("u1", "sha1", intentMerge) {
+		t.Error("a finished intent is still pending")
+	}
+
+	var none *intentLog
+	none.start("u1", "sha1", intentMerge, now)
+	none.finish("u1", "sha1", intentMerge)
+	if none.unfinished("u1", "sha1", intentMerge) {
+		t.Error("a nil log recorded an intent")
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if l := loadIntents(path, now); len(l.pending) != 0 {
+		t.Errorf("unreadable log = %+v", l.pending)
+	}
+}
+
+// startedIntent writes an unfinished intent, as a run that crashed mid-action
+// would leave, into opts' state dir.
+func startedIntent(opts *runOptions, pr *prView, action string) string {
+	path := intentsPath(resolveStatePath(opts.StateFile))
+	loadIntents(path, time.Now()).start(pr.URL, pr.HeadRefOid, action, time.Now())
+	return path
+}
+
+func TestPipelineConfirmsUnfinishedMerge(t *testing.T) {
+	for _, state := range []string{"MERGED", "OPEN"} {
+		green := fakePR("misty-step/api", 1)
+		fake := newFakeGitHub(green)
+		opts := testPipelineOptions(t)
+		path := startedIntent(opts, green, intentMerge)
+
+		p := newPipeline(opts)
+		p.client = ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+			if slices.Equal(args, []string{"pr", "view", green.URL, "--json", "state"}) {
+				return []byte(`{"state":"` + state + `"}`), nil
+			}
+			return fake.Run(ctx, stdin, args...)
+		})
+		out, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Results) != 1 || out.Results[0].Action != "merged" {
+			t.Fatalf("%s: results = %+v", state, out.Results)
+		}
+		if state == "MERGED" && (out.Results[0].Reason != "intent_confirmed" || len(fake.merged) != 0) {
+			t.Errorf("already merged: reason %q, merged %v; want it confirmed, not merged again", out.Results[0].Reason, fake.merged)
+		}
+		if state == "OPEN" && !slices.Equal(fake.merged, []string{green.ID}) {
+			t.Errorf("still open: merged %v; want it merged", fake.merged)
+		}
+		if loadIntents(path, time.Now()).unfinished(green.URL, green.HeadRefOid, intentMerge) {
+			t.Errorf("%s: the intent is still pending", state)
+		}
+	}
+}
+
+func TestPipelineSkipsUnconfirmableComment(t *testing.T) {
+	for _, crashed := range []bool{false, true} {
+		unreviewed := fakePR("misty-step/api", 1)
+		unreviewed.ReviewDecision = "REVIEW_REQUIRED"
+		fake := newFakeGitHub(unreviewed)
+		opts := testPipelineOptions(t)
+		path := intentsPath(resolveStatePath(opts.StateFile))
+		if crashed {
+			startedIntent(opts, unreviewed, intentComment+":review_required")
+		}
+
+		p := newPipeline(opts)
+		p.client = ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+			if len(args) > 2 && args[0] == "api" && args[1] == "--paginate" && strings.Contains(args[2], "/comments") {
+				return nil, errors.New("gh: Resource not accessible by integration (HTTP 403)")
+			}
+			return fake.Run(ctx, stdin, args...)
+		})
+		out, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Results) != 1 {
+			t.Fatalf("results = %+v", out.Results)
+		}
+		got := out.Results[0].Action + "/" + out.Results[0].Reason
+		switch {
+		case crashed && (got != "skipped/intent_unconfirmed" || len(fake.posted[unreviewed.URL]) != 0):
+			t.Errorf("after a crash: %s, posted %q; want it skipped without commenting", got, fake.posted[unreviewed.URL])
+		case !crashed && (got != "commented/review_required" || len(fake.posted[unreviewed.URL]) != 1):
+			t.Errorf("no crash: %s, posted %q; want one comment", got, fake.posted[unreviewed.URL])
+		}
+		if !crashed && len(loadIntents(path, time.Now()).pending) != 0 {
+			t.Error("the comment's intent is still pending after it was posted")
+		}
+	}
+}
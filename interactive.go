@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-isatty"
+)
+
+// operatorAction is what the operator chose for a PR in --interactive mode.
+type operatorAction int
+
+const (
+	// operatorAccept lets the pipeline do what it decided.
+	operatorAccept operatorAction = iota
+	// operatorMerge merges even though the pipeline wouldn't.
+	operatorMerge
+	// operatorComment posts the operator's own comment.
+	operatorComment
+	// operatorSkip leaves the PR alone.
+	operatorSkip
+	// operatorQuit leaves this and every remaining PR alone.
+	operatorQuit
+)
+
+func (a operatorAction) String() string {
+	switch a {
+	case operatorAccept:
+		return "accept"
+	case operatorMerge:
+		return "merge"
+	case operatorComment:
+		return "comment"
+	case operatorSkip:
+		return "skip"
+	default:
+		return "quit"
+	}
+}
+
+// operatorChoice is an operator decision, with the comment text for
+// operatorComment.
+type operatorChoice struct {
+	Action  operatorAction
+	Comment string
+}
+
+// isTerminal reports whether f is a terminal the UI can read keys from.
+func isTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// triageWindow is how many queued PRs the UI lists around the current one.
+const triageWindow = 10
+
+// triageItem is a selected PR in the operator's queue, with what the
+// operator chose for it once they have.
+type triageItem struct {
+	URL    string
+	Label  string
+	Title  string
+	Choice string
+}
+
+// operatorPrompt asks the operator what to do with each PR in a terminal
+// UI. It reads keys from in and draws on out (stderr in practice, so stdout
+// stays the run JSON).
+type operatorPrompt struct {
+	in    io.Reader
+	out   io.Writer
+	queue []triageItem
+	quit  bool
+}
+
+func newOperatorPrompt(in io.Reader, out io.Writer) *operatorPrompt {
+	return &operatorPrompt{in: in, out: out}
+}
+
+// setQueue lists the run's selected PRs, in the order they'll be asked
+// about.
+func (p *operatorPrompt) setQueue(prs []searchPR) {
+	p.queue = p.queue[:0]
+	for _, pr := range prs {
+		p.queue = append(p.queue, triageItem{
+			URL:   pr.URL,
+			Label: fmt.Sprintf("%s#%d", pr.Repository.NameWithOwner, pr.Number),
+			Title: pr.Title,
+		})
+	}
+}
+
+// choose shows the queue, the PR, and the pipeline's decision, and returns
+// the operator's choice. A UI that fails or is interrupted counts as quit.
+func (p *operatorPrompt) choose(ctx context.Context, pr *prView, o prOutcome, mergeOK bool, mergeReason string) operatorChoice {
+	if p.quit {
+		return operatorChoice{Action: operatorQuit}
+	}
+	prog := tea.NewProgram(newTriageModel(p.queue, pr, o, mergeOK, mergeReason),
+		tea.WithContext(ctx), tea.WithInput(p.in), tea.WithOutput(p.out))
+	choice := operatorChoice{Action: operatorQuit}
+	final, err := prog.Run()
+	if err != nil {
+		fmt.Fprintf(p.out, "[interactive] %v\n", err)
+	} else if m, ok := final.(triageModel); ok && m.done {
+		choice = m.choice
+	}
+	if choice.Action == operatorQuit {
+		p.quit = true
+	}
+	for i := range p.queue {
+		if p.queue[i].URL == pr.URL {
+			p.queue[i].Choice = choice.Action.String()
+		}
+	}
+	return choice
+}
+
+// triageModel is the UI for one PR: the queue around it, its state, and
+// what the pipeline decided, with a text input for operatorComment.
+type triageModel struct {
+	queue    []triageItem
+	pr       *prView
+	outcome  prOutcome
+	decision string
+	failing  []string
+
+	commenting bool
+	input      textinput.Model
+	note       string
+
+	choice operatorChoice
+	done   bool
+}
+
+func newTriageModel(queue []triageItem, pr *prView, o prOutcome, mergeOK bool, mergeReason string) triageModel {
+	decision := "would merge"
+	if !mergeOK {
+		decision = "blocked: " + mergeReason
+	}
+	input := textinput.New()
+	input.Prompt = "comment: "
+	input.Placeholder = "enter to post, esc or empty to cancel"
+	return triageModel{
+		queue:    queue,
+		pr:       pr,
+		outcome:  o,
+		decision: decision,
+		failing:  failingCheckNames(pr.StatusCheckRollup),
+		input:    input,
+	}
+}
+
+func (m triageModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m triageModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if m.commenting {
+		if ok {
+			switch key.Type {
+			case tea.KeyCtrlC, tea.KeyCtrlD:
+				return m.decide(operatorChoice{Action: operatorQuit})
+			case tea.KeyEsc:
+				return m.cancelComment(), nil
+			case tea.KeyEnter:
+				text := strings.TrimSpace(m.input.Value())
+				if text == "" {
+					return m.cancelComment(), nil
+				}
+				return m.decide(operatorChoice{Action: operatorComment, Comment: text})
+			}
+		}
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return m, cmd
+	}
+	if !ok {
+		return m, nil
+	}
+	m.note = ""
+	switch strings.ToLower(key.String()) {
+	case "a", "enter":
+		return m.decide(operatorChoice{Action: operatorAccept})
+	case "m":
+		return m.decide(operatorChoice{Action: operatorMerge})
+	case "s":
+		return m.decide(operatorChoice{Action: operatorSkip})
+	case "q", "ctrl+c", "ctrl+d":
+		return m.decide(operatorChoice{Action: operatorQuit})
+	case "c":
+		m.commenting = true
+		return m, m.input.Focus()
+	default:
+		m.note = fmt.Sprintf("unrecognized key %q", key.String())
+	}
+	return m, nil
+}
+
+func (m triageModel) decide(c operatorChoice) (tea.Model, tea.Cmd) {
+	m.choice, m.done, m.commenting = c, true, false
+	return m, tea.Quit
+}
+
+func (m triageModel) cancelComment() triageModel {
+	m.commenting = false
+	m.input.Reset()
+	m.input.Blur()
+	return m
+}
+
+// View draws the UI. Once the operator has chosen, it shrinks to one line
+// so the terminal keeps a short record of each decision.
+func (m triageModel) View() string {
+	if m.done {
+		return fmt.Sprintf("%s: %s\n", m.pr.URL, m.choice.Action)
+	}
+	var b strings.Builder
+	m.writeQueue(&b)
+	o := m.outcome
+	fmt.Fprintf(&b, "%s\n  %s\n  author: %s  mergeable: %s  checks: %s  review: %s\n  pipeline: %s\n",
+		m.pr.URL, m.pr.Title, o.Author, o.Mergeable, o.ChecksState, o.ReviewDecision, m.decision)
+	if len(m.failing) > 0 {
+		fmt.Fprintf(&b, "  failing: %s\n", strings.Join(m.failing, ", "))
+	}
+	b.WriteString("\n")
+	if m.commenting {
+		b.WriteString(m.input.View() + "\n")
+	} else {
+		b.WriteString("a/enter accept · m merge · c comment · s skip · q quit\n")
+	}
+	if m.note != "" {
+		b.WriteString(m.note + "\n")
+	}
+	return b.String()
+}
+
+// writeQueue lists the queued PRs around the current one, marking those
+// already decided with the operator's choice.
+func (m triageModel) writeQueue(b *strings.Builder) {
+	cur := -1
+	for i, item := range m.queue {
+		if item.URL == m.pr.URL {
+			cur = i
+		}
+	}
+	if cur < 0 {
+		return
+	}
+	from := max(0, cur-triageWindow/2)
+	to := min(len(m.queue), from+triageWindow)
+	fmt.Fprintf(b, "PR %d of %d\n", cur+1, len(m.queue))
+	if from > 0 {
+		fmt.Fprintf(b, "    … %d before\n", from)
+	}
+	for i := from; i < to; i++ {
+		item := m.queue[i]
+		mark := " "
+		switch {
+		case i == cur:
+			mark = "▸"
+		case item.Choice != "":
+			mark = "✓"
+		}
+		fmt.Fprintf(b, "  %s %s  %s", mark, item.Label, item.Title)
+		if item.Choice != "" {
+			fmt.Fprintf(b, "  [%s]", item.Choice)
+		}
+		b.WriteString("\n")
+	}
+	if to < len(m.queue) {
+		fmt.Fprintf(b, "    … %d more\n", len(m.queue)-to)
+	}
+	b.WriteString("\n")
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strconv"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// triageKeys feeds keys to m: "enter" and "esc" are those keys, anything
+// else is typed.
+func triageKeys(m triageModel, keys ...string) triageModel {
+	for _, k := range keys {
+		var msg tea.KeyMsg
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		next, _ := m.Update(msg)
+		m = next.(triageModel)
+		if m.done {
+			break
+		}
+	}
+	return m
+}
+
+func TestTriageModel(t *testing.T) {
+	pr := &prView{
+		URL:   "https://github.com/o/r/pull/1",
+		Title: "Fix the thing",
+		StatusCheckRollup: []statusRollupEntry{
+			{Typename: "CheckRun", Name: "unit tests", Status: "COMPLETED", Conclusion: "FAILURE"},
+		},
+	}
+	o := prOutcome{Author: "alice", Mergeable: "MERGEABLE", ChecksState: "FAILURE"}
+
+	tests := []struct {
+		name    string
+		keys    []string
+		want    operatorAction
+		comment string
+	}{
+		{"enter accepts", []string{"enter"}, operatorAccept, ""},
+		{"merge", []string{"m"}, operatorMerge, ""},
+		{"skip", []string{"s"}, operatorSkip, ""},
+		{"quit", []string{"Q"}, operatorQuit, ""},
+		{"comment", []string{"c", "please rebase", "enter"}, operatorComment, "please rebase"},
+		{"empty comment cancels", []string{"c", "enter", "s"}, operatorSkip, ""},
+		{"esc cancels a comment", []string{"c", "nope", "esc", "m"}, operatorMerge, ""},
+		{"unknown then merge", []string{"x", "m"}, operatorMerge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := triageKeys(newTriageModel(nil, pr, o, false, "checks_failure"), tt.keys...)
+			if !m.done || m.choice.Action != tt.want || m.choice.Comment != tt.comment {
+				t.Errorf("choice = %+v (done %v); want action %s comment %q", m.choice, m.done, tt.want, tt.comment)
+			}
+		})
+	}
+
+	m := newTriageModel(nil, pr, o, false, "checks_failure")
+	for _, want := range []string{pr.URL, "Fix the thing", "pipeline: blocked: checks_failure", "failing: unit tests", "m merge"} {
+		if !strings.Contains(m.View(), want) {
+			t.Errorf("view missing %q; got:\n%s", want, m.View())
+		}
+	}
+	if view := triageKeys(m, "x").View(); !strings.Contains(view, `unrecognized key "x"`) {
+		t.Errorf("view after an unknown key:\n%s", view)
+	}
+	if view := triageKeys(m, "c").View(); !strings.Contains(view, "comment: ") {
+		t.Errorf("view while commenting:\n%s", view)
+	}
+}
+
+func TestTriageModel_queue(t *testing.T) {
+	p := newOperatorPrompt(nil, nil)
+	var prs []searchPR
+	for n := 1; n <= 14; n++ {
+		var pr searchPR
+		pr.URL = "https://github.com/o/r/pull/" + strconv.Itoa(n)
+		pr.Number = n
+		pr.Title = "Change " + strconv.Itoa(n)
+		pr.Repository.NameWithOwner = "o/r"
+		prs = append(prs, pr)
+	}
+	p.setQueue(prs)
+	p.queue[7].Choice = "merge"
+
+	view := newTriageModel(p.queue, &prView{URL: prs[8].URL}, prOutcome{}, true, "").View()
+	for _, want := range []string{"PR 9 of 14", "… 3 before", "✓ o/r#8  Change 8  [merge]", "▸ o/r#9  Change 9", "… 1 more", "pipeline: would merge"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view missing %q; got:\n%s", want, view)
+		}
+	}
+}
+
+func TestOperatorPrompt_choose(t *testing.T) {
+	var out bytes.Buffer
+	p := newOperatorPrompt(strings.NewReader("m"), &out)
+	var pr searchPR
+	pr.URL = "https://github.com/o/r/pull/1"
+	p.setQueue([]searchPR{pr})
+
+	got := p.choose(context.Background(), &prView{URL: pr.URL, Title: "Fix the thing"}, prOutcome{}, false, "checks_failure")
+	if got.Action != operatorMerge {
+		t.Errorf("choose = %+v; want merge", got)
+	}
+	if p.queue[0].Choice != "merge" {
+		t.Errorf("queue = %+v; want the PR marked merge", p.queue)
+	}
+	if !strings.Contains(out.String(), pr.URL+": merge") {
+		t.Errorf("output missing the decision line; got:\n%q", out.String())
+	}
+}
+
+func TestOperatorPrompt_quitSticks(t *testing.T) {
+	p := newOperatorPrompt(strings.NewReader("q"), &bytes.Buffer{})
+	pr := &prView{URL: "u"}
+	if got := p.choose(context.Background(), pr, prOutcome{}, true, ""); got.Action != operatorQuit {
+		t.Fatalf("first choice = %+v; want quit", got)
+	}
+	if got := p.choose(context.Background(), pr, prOutcome{}, true, ""); got.Action != operatorQuit {
+		t.Errorf("after quit, choice = %+v; want quit without reading input", got)
+	}
+}
+
+func TestOperatorPrompt_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := newOperatorPrompt(strings.NewReader(""), &bytes.Buffer{})
+	if got := p.choose(ctx, &prView{URL: "u"}, prOutcome{}, true, ""); got.Action != operatorQuit || !p.quit {
+		t.Errorf("choose after cancel = %+v; want quit", got)
+	}
+}
+
+func TestInteractiveNeedsTerminal(t *testing.T) {
+	// go test's stdin isn't a terminal.
+	for _, args := range [][]string{
+		{"-org", "misty-step", "-interactive"},
+		{"-org", "misty-step", "-interactive", "-serve", "127.0.0.1:0"},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		opts := registerRunFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		if err := opts.prepare(); err == nil || !strings.Contains(err.Error(), "--interactive") {
+			t.Errorf("%v: prepare() = %v; want --interactive rejected", args, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// issueRef identifies an issue, possibly in another repo.
+type issueRef struct {
+	Repo   string
+	Number int
+}
+
+func (r issueRef) String() string {
+	return fmt.Sprintf("%s#%d", r.Repo, r.Number)
+}
+
+// closingRefRe matches GitHub's closing keywords followed by an issue
+// reference: #N, owner/repo#N, or a full issue URL.
+var closingRefRe = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?)\s*:?\s+(?:https://github\.com/([\w.-]+/[\w.-]+)/issues/(\d+)|([\w.-]+/[\w.-]+)?#(\d+))`)
+
+// parseClosingRefs returns the issues a PR body says it closes, resolved
+// against repo for bare #N references, without duplicates.
+func parseClosingRefs(body string, repo string) []issueRef {
+	var refs []issueRef
+	seen := make(map[issueRef]bool)
+	for _, m := range closingRefRe.FindAllStringSubmatch(body, -1) {
+		ref := issueRef{Repo: repo}
+		num := m[4]
+		switch {
+		case m[1] != "":
+			ref.Repo, num = m[1], m[2]
+		case m[3] != "":
+			ref.Repo = m[3]
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil || n <= 0 {
+			continue
+		}
+		ref.Number = n
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// linkedIssueSettle is how long closeLinkedIssues waits after the merge
+// before checking issues, so GitHub's own auto-close lands first and isn't
+// raced with a second close and comment.
+const linkedIssueSettle = 10 * time.Second
+
+// linkedIssueSleep waits out linkedIssueSettle, returning early if ctx ends
+// (replaced in tests).
+var linkedIssueSleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// closeLinkedIssues checks each issue the merged PR claims to close and closes
+// any GitHub left open. Issues in the PR's own repo are only closed for a
+// merge into the default branch: a merge elsewhere (a release branch, say)
+// hasn't shipped the fix. Returns every linked issue and the ones closed
+// here.
+func closeLinkedIssues(ctx context.Context, repo string, pr *prView) (linked []string, closed []string) {
+	intoDefault := sync.OnceValue(func() bool {
+		branch, err := RetryableWithResult(ctx, func() (string, error) {
+			return ghDefaultBranch(ctx, repo)
+		}, retryCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[linked-issues] %s: default branch lookup failed: %v\n", repo, err)
+			return false
+		}
+		return pr.BaseRefName == branch
+	})
+	var refs []issueRef
+	for _, ref := range parseClosingRefs(pr.Body, repo) {
+		linked = append(linked, ref.String())
+		if !strings.EqualFold(ref.Repo, repo) || intoDefault() {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == 0 || linkedIssueSleep(ctx, linkedIssueSettle) != nil {
+		return linked, closed
+	}
+	for _, ref := range refs {
+		state, err := RetryableWithResult(ctx, func() (string, error) {
+			return ghIssueState(ctx, ref)
+		}, retryCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[linked-issues] %s: lookup failed: %v\n", ref, err)
+			continue
+		}
+		if !strings.EqualFold(state, "OPEN") {
+			continue
+		}
+		if err := Retryable(ctx, func() error {
+			return ghIssueClose(ctx, ref, pr.URL)
+		}, retryCfg); err != nil {
+			fmt.Fprintf(os.Stderr, "[linked-issues] %s: close failed: %v\n", ref, err)
+			continue
+		}
+		closed = append(closed, ref.String())
+	}
+	return linked, closed
+}
+
+// ghDefaultBranch returns the name of repo's default branch.
+func ghDefaultBranch(ctx context.Context, repo string) (string, error) {
+	stdout, err := runGh(ctx, "repo", "view", repo, "--json", "defaultBranchRef")
+	if err != nil {
+		return "", err
+	}
+	var v struct {
+		DefaultBranchRef struct {
+			Name string `json:"name"`
+		} `json:"defaultBranchRef"`
+	}
+	if err := json.Unmarshal(stdout, &v); err != nil {
+		return "", fmt.Errorf("parse gh repo view json: %w", err)
+	}
+	return v.DefaultBranchRef.Name, nil
+}
+
+func ghIssueState(ctx context.Context, ref issueRef) (string, error) {
+	stdout, err := runGh(ctx, "issue", "view", strconv.Itoa(ref.Number), "-R", ref.Repo, "--json", "state")
+	if err != nil {
+		return "", err
+	}
+	var v struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(stdout, &v); err != nil {
+		return "", fmt.Errorf("parse gh issue view json: %w", err)
+	}
+	return v.State, nil
+}
+
+func ghIssueClose(ctx context.Context, ref issueRef, prURL string) error {
+	comment := "<!-- kaylee-pr-pipeline -->\nClosed by " + prURL + " (merged), which references this issue."
+	_, err := runGh(ctx, "issue", "close", strconv.Itoa(ref.Number), "-R", ref.Repo, "--reason", "completed", "--comment", comment)
+	return err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseClosingRefs(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"none", "Refactor the parser. See #4 for context.", "[]"},
+		{"bare ref", "Closes #12", "[misty-step/app#12]"},
+		{"keywords and case", "fixes #1\nRESOLVED: #2\nfix #3", "[misty-step/app#1 misty-step/app#2 misty-step/app#3]"},
+		{"cross repo", "Closes misty-step/lib#7", "[misty-step/lib#7]"},
+		{"issue url", "Resolves https://github.com/misty-step/infra/issues/30", "[misty-step/infra#30]"},
+		{"duplicates", "Closes #5, closes #5", "[misty-step/app#5]"},
+		{"keyword must be a word", "encloses #9", "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs := parseClosingRefs(tt.body, "misty-step/app")
+			if got := fmt.Sprint(refs); got != tt.want {
+				t.Errorf("parseClosingRefs(%q) = %s; want %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseLinkedIssues(t *testing.T) {
+	old, oldSleep := githubClient, linkedIssueSleep
+	t.Cleanup(func() { githubClient, linkedIssueSleep = old, oldSleep })
+	var waits []time.Duration
+	linkedIssueSleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	pr := &prView{
+		URL:         "https://github.com/misty-step/app/pull/9",
+		Body:        "Closes #1, fixes #2, resolves misty-step/lib#3",
+		BaseRefName: "release-1.2",
+	}
+	githubClient = ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+		switch cmd := strings.Join(args, " "); {
+		case strings.HasPrefix(cmd, "repo view misty-step/app"):
+			return []byte(`{"defaultBranchRef":{"name":"main"}}`), nil
+		case strings.HasPrefix(cmd, "issue view 2 "):
+			// GitHub closed this one on the merge.
+			return []byte(`{"state":"CLOSED"}`), nil
+		case strings.HasPrefix(cmd, "issue view"):
+			return []byte(`{"state":"OPEN"}`), nil
+		case strings.HasPrefix(cmd, "issue close"):
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unexpected gh %s (HTTP 404)", strings.Join(args, " "))
+	})
+
+	// Into a release branch, only the other repo's issue is closed.
+	linked, closed := closeLinkedIssues(context.Background(), "misty-step/app", pr)
+	if fmt.Sprint(linked) != "[misty-step/app#1 misty-step/app#2 misty-step/lib#3]" || fmt.Sprint(closed) != "[misty-step/lib#3]" {
+		t.Errorf("release branch: linked %v, closed %v", linked, closed)
+	}
+
+	// Into the default branch, what GitHub left open is closed after the
+	// settle delay.
+	pr.BaseRefName = "main"
+	_, closed = closeLinkedIssues(context.Background(), "misty-step/app", pr)
+	if fmt.Sprint(closed) != "[misty-step/app#1 misty-step/lib#3]" {
+		t.Errorf("default branch: closed %v", closed)
+	}
+	if len(waits) != 2 || waits[0] != linkedIssueSettle {
+		t.Errorf("waits = %v; want the settle delay before each check", waits)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHasLabel(t *testing.T) {
+	labels := []label{{Name: "bug"}, {Name: " Priority "}}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"priority", true},
+		{"PRIORITY", true},
+		{"bug", true},
+		{"hold", false},
+		{"", false},
+		{"  ", false},
+	}
+	for _, tt := range tests {
+		if got := hasLabel(labels, tt.name); got != tt.want {
+			t.Errorf("hasLabel(%q) = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSelectPRsPriorityFirst(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	mk := func(url string, age time.Duration, labels ...string) searchPR {
+		pr := searchPR{URL: url, UpdatedAt: now.Add(-age)}
+		pr.Author.Login = "someone"
+		pr.Repository.NameWithOwner = "misty-step/app"
+		for _, l := range labels {
+			pr.Labels = append(pr.Labels, label{Name: l})
+		}
+		return pr
+	}
+	opts := &runOptions{PriorityLabel: "priority", authors: defaultAuthorPolicies("", 0, "")}
+	prs := []searchPR{
+		mk("fresh", time.Hour),
+		mk("old-priority", 48*time.Hour, "Priority"),
+		mk("older", 24*time.Hour),
+	}
+	got := selectPRs(opts, prs, now)
+	want := []string{"old-priority", "fresh", "older"}
+	if len(got) != len(want) {
+		t.Fatalf("selected %d PRs; want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].URL != w {
+			t.Errorf("selected[%d] = %s; want %s", i, got[i].URL, w)
+		}
+	}
+
+	opts.PriorityLabel = ""
+	if got := selectPRs(opts, prs, now); got[0].URL != "fresh" {
+		t.Errorf("with the label disabled, first = %s; want fresh", got[0].URL)
+	}
+}
+
+func TestPipelineCheckResultHold(t *testing.T) {
+	o := prOutcome{Action: "commented"}
+	o.setReason(reasonHoldLabel, "")
+	conclusion, title, _ := pipelineCheckResult(o)
+	if conclusion != "neutral" || title != "Would merge (on hold)" {
+		t.Errorf("got %q %q", conclusion, title)
+	}
+	if got := outcomeState(o); got != "mergeable" {
+		t.Errorf("outcomeState = %q; want mergeable", got)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -9,12 +10,15 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +26,9 @@ import (
 
 // CircuitBreaker tracks per-PR failures and skips PRs that repeatedly fail.
 // After N consecutive failures, the circuit opens and the PR is skipped for M runs.
+// Each time the same PR's circuit opens again, the skip doubles (up to
+// cbMaxDoublings times); each success halves the next window again.
+// Pipeline.Run loads and saves the state, so all of this spans runs.
 // This prevents one bad PR from consuming the entire error budget.
 type CircuitBreaker struct {
 	mu sync.RWMutex
@@ -30,25 +37,51 @@ type CircuitBreaker struct {
 	failures map[string]int
 	// prURL -> remaining skip runs when circuit is open
 	skipsRemaining map[string]int
+	// prURL -> doublings applied to the next opening's skip
+	penalty map[string]int
+	// saved is the state as last loaded, so Save can tell what changed.
+	saved map[string]breakerEntry
 
 	// Config
 	failureThreshold int // N: failures before opening circuit
 	skipRuns         int // M: runs to skip when circuit is open
+	// name tags the log lines ("circuit-breaker" or "repo-circuit-breaker").
+	name string
 }
 
+// cbMaxDoublings caps the skip growth for chronically failing PRs at
+// 2^cbMaxDoublings times -cb-skip-runs.
+const cbMaxDoublings = 4
+
 // NewCircuitBreaker creates a new circuit breaker with the given thresholds.
 func NewCircuitBreaker(failureThreshold, skipRuns int) *CircuitBreaker {
 	return &CircuitBreaker{
 		failures:         make(map[string]int),
 		skipsRemaining:   make(map[string]int),
+		penalty:          make(map[string]int),
 		failureThreshold: failureThreshold,
 		skipRuns:         skipRuns,
+		name:             "circuit-breaker",
+	}
+}
+
+// newRepoCircuitBreaker returns a breaker keyed by repo instead of PR, for
+// failures that hit every PR in a repo (permissions, API outages). Nil
+// (disabled) when failureThreshold is 0.
+func newRepoCircuitBreaker(failureThreshold, skipRuns int) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		return nil
 	}
+	cb := NewCircuitBreaker(failureThreshold, skipRuns)
+	cb.name = "repo-circuit-breaker"
+	return cb
 }
 
 // RecordFailure increments the failure count for a PR.
-// If failures reach the threshold, the circuit opens.
-func (cb *CircuitBreaker) RecordFailure(prURL string) {
+// If failures reach the threshold, the circuit opens for the PR's current
+// skip window, and the next opening's window doubles. Reports whether this
+// failure opened the circuit.
+func (cb *CircuitBreaker) RecordFailure(prURL string) bool {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
@@ -56,14 +89,20 @@ func (cb *CircuitBreaker) RecordFailure(prURL string) {
 	if cb.failures[prURL] >= cb.failureThreshold {
 		// Circuit opens - only log on transition
 		if cb.skipsRemaining[prURL] == 0 {
-			cb.skipsRemaining[prURL] = cb.skipRuns
-			fmt.Fprintf(os.Stderr, "[circuit-breaker] OPENED for %s (after %d consecutive failures, skipping for %d runs)\n", prURL, cb.failures[prURL], cb.skipRuns)
+			skip := cb.skipRuns << cb.penalty[prURL]
+			cb.skipsRemaining[prURL] = skip
+			if cb.penalty[prURL] < cbMaxDoublings {
+				cb.penalty[prURL]++
+			}
+			fmt.Fprintf(os.Stderr, "[%s] OPENED for %s (after %d consecutive failures, skipping for %d runs)\n", cb.name, prURL, cb.failures[prURL], skip)
+			return true
 		}
 	}
+	return false
 }
 
-// RecordSuccess clears the failure count for a PR.
-// If the circuit was open, logs recovery.
+// RecordSuccess clears the failure count for a PR and halves its next
+// skip window. If the circuit was open, logs recovery.
 func (cb *CircuitBreaker) RecordSuccess(prURL string) {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
@@ -71,9 +110,15 @@ func (cb *CircuitBreaker) RecordSuccess(prURL string) {
 	if cb.failures[prURL] > 0 {
 		delete(cb.failures, prURL)
 	}
+	if cb.penalty[prURL] > 0 {
+		cb.penalty[prURL]--
+		if cb.penalty[prURL] == 0 {
+			delete(cb.penalty, prURL)
+		}
+	}
 	if cb.skipsRemaining[prURL] > 0 {
 		delete(cb.skipsRemaining, prURL)
-		fmt.Fprintf(os.Stderr, "[circuit-breaker] CLOSED for %s (recovered after success)\n", prURL)
+		fmt.Fprintf(os.Stderr, "[%s] CLOSED for %s (recovered after success)\n", cb.name, prURL)
 	}
 }
 
@@ -88,7 +133,7 @@ func (cb *CircuitBreaker) IsOpen(prURL string) bool {
 		if cb.skipsRemaining[prURL] == 0 {
 			// Circuit will close after this skip - reset failures so next error doesn't immediately reopen
 			delete(cb.failures, prURL)
-			fmt.Fprintf(os.Stderr, "[circuit-breaker] CLOSED for %s (skip period expired, will retry)\n", prURL)
+			fmt.Fprintf(os.Stderr, "[%s] CLOSED for %s (skip period expired, will retry)\n", cb.name, prURL)
 		}
 		return true
 	}
@@ -104,11 +149,22 @@ type searchPR struct {
 	Number    int       `json:"number"`
 	Author    struct {
 		Login string `json:"login"`
+		// IsBot is set for GitHub App accounts (is_bot in gh pr view).
+		IsBot bool `json:"is_bot,omitempty"`
 	} `json:"author"`
 	Repository struct {
 		NameWithOwner string `json:"nameWithOwner"`
 	} `json:"repository"`
 	Labels []label `json:"labels"`
+	// HeadRefOid and ChecksState, the head commit's checks rollup state,
+	// are what --skip-unchanged compares with the last decision.
+	HeadRefOid  string `json:"headRefOid"`
+	ChecksState string `json:"checksState"`
+	// Set only by the digest's search.
+	CreatedAt      time.Time  `json:"createdAt"`
+	Mergeable      string     `json:"mergeable"`
+	ReviewDecision string     `json:"reviewDecision"`
+	LatestReviews  []prReview `json:"latestReviews"`
 }
 
 type label struct {
@@ -116,19 +172,72 @@ type label struct {
 }
 
 type prView struct {
-	ID                string              `json:"id"`
-	URL               string              `json:"url"`
-	Title             string              `json:"title"`
-	Body              string              `json:"body"`
-	IsDraft           bool                `json:"isDraft"`
-	Mergeable         string              `json:"mergeable"`
-	ReviewDecision    string              `json:"reviewDecision"`
-	MergeStateStatus  string              `json:"mergeStateStatus"`
+	ID                string `json:"id"`
+	URL               string `json:"url"`
+	Title             string `json:"title"`
+	Body              string `json:"body"`
+	IsDraft           bool   `json:"isDraft"`
+	Mergeable         string `json:"mergeable"`
+	ReviewDecision    string `json:"reviewDecision"`
+	MergeStateStatus  string `json:"mergeStateStatus"`
+	BaseRefName       string `json:"baseRefName"`
+	HeadRefName       string `json:"headRefName"`
+	HeadRefOid        string `json:"headRefOid"`
+	IsCrossRepository bool   `json:"isCrossRepository"`
+	ReviewRequests    []struct {
+		Login string `json:"login"`
+		Slug  string `json:"slug"`
+	} `json:"reviewRequests"`
+	AutoMergeRequest *struct {
+		EnabledAt   string `json:"enabledAt"`
+		MergeMethod string `json:"mergeMethod"`
+		EnabledBy   struct {
+			Login string `json:"login"`
+		} `json:"enabledBy"`
+	} `json:"autoMergeRequest"`
 	StatusCheckRollup []statusRollupEntry `json:"statusCheckRollup"`
 	Author            struct {
 		Login string `json:"login"`
 	} `json:"author"`
 	Labels []label `json:"labels"`
+	// Additions, Deletions, and ChangedFiles size the diff for the
+	// --max-merge-lines and --max-merge-files gate.
+	Additions    int `json:"additions"`
+	Deletions    int `json:"deletions"`
+	ChangedFiles int `json:"changedFiles"`
+	// LatestReviews is each reviewer's most recent review.
+	LatestReviews []prReview `json:"latestReviews"`
+	// CreatedAt is when the PR was opened, for time-to-merge.
+	CreatedAt time.Time `json:"createdAt"`
+	// OptionalChecks are the rollup entries moved out of StatusCheckRollup
+	// because branch protection doesn't require them.
+	OptionalChecks []statusRollupEntry `json:"-"`
+	// UnresolvedThreads are set when --require-threads-resolved looked
+	// them up.
+	UnresolvedThreads []reviewThread `json:"-"`
+	// ProtectedFiles are the changed files under the config's protected
+	// paths, when they were looked up.
+	ProtectedFiles []string `json:"-"`
+	// UntrustedCommits are set when --verify-commits found commits it
+	// doesn't trust.
+	UntrustedCommits []string `json:"-"`
+	// SecretFindings are set when --scan-secrets found likely credentials.
+	SecretFindings []string `json:"-"`
+	// FailedCondition is the config merge condition that held the PR
+	// back, if one did.
+	FailedCondition *mergeCondition `json:"-"`
+}
+
+type prReview struct {
+	ID     string `json:"id"`
+	Author struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	State  string `json:"state"`
+	Commit struct {
+		Oid string `json:"oid"`
+	} `json:"commit"` // the head the review was left on
+	SubmittedAt time.Time `json:"submittedAt"`
 }
 
 type statusRollupEntry struct {
@@ -137,19 +246,35 @@ type statusRollupEntry struct {
 	Context    string `json:"context"`
 	Status     string `json:"status"`     // CheckRun
 	Conclusion string `json:"conclusion"` // CheckRun
+	DetailsURL string `json:"detailsUrl"` // CheckRun
 	State      string `json:"state"`      // StatusContext
+	TargetURL  string `json:"targetUrl"`  // StatusContext
 }
 
 type runOutput struct {
+	// SchemaVersion is runOutputSchemaVersion, for consumers to check
+	// before relying on the format.
+	SchemaVersion int `json:"schemaVersion"`
+
 	Ok         bool        `json:"ok"`
 	Error      string      `json:"error,omitempty"`
+	Aborted    string      `json:"aborted,omitempty"` // why the run stopped early (--max-run-errors)
+	Frozen     string      `json:"frozen,omitempty"`  // why the kill switch made this a dry run
 	StartedAt  string      `json:"startedAt"`
 	Org        string      `json:"org"`
 	MaxPRs     int         `json:"maxPRs"`
+	MaxActions int         `json:"maxActions,omitempty"`
 	StaleHours int         `json:"staleHours"`
 	DryRun     bool        `json:"dryRun"`
+	Scanned    int         `json:"scanned"`
 	Discord    *discordOut `json:"discord,omitempty"`
+	Diff       *runDiff    `json:"diff,omitempty"`
+	Orgs       []orgTotals `json:"orgs,omitempty"`
+	Stats      *runStats   `json:"stats,omitempty"`
 	Results    []prOutcome `json:"results"`
+
+	// Filtered are the scanned PRs this run didn't process, and why.
+	Filtered []filteredPR `json:"filtered,omitempty"`
 }
 
 type discordOut struct {
@@ -160,18 +285,52 @@ type discordOut struct {
 }
 
 type prOutcome struct {
-	URL            string `json:"url"`
-	Repo           string `json:"repo"`
-	Number         int    `json:"number"`
-	Author         string `json:"author"`
-	Action         string `json:"action"` // merged|commented|skipped|error
-	Reason         string `json:"reason,omitempty"`
-	MergeCommitOID string `json:"mergeCommitOid,omitempty"`
-	ChecksState    string `json:"checksState,omitempty"`
-	Mergeable      string `json:"mergeable,omitempty"`
-	ReviewDecision string `json:"reviewDecision,omitempty"`
-	ReviewComments string `json:"reviewComments,omitempty"`
-	CIFailureType  string `json:"ciFailureType,omitempty"`
+	URL            string   `json:"url"`
+	Repo           string   `json:"repo"`
+	Number         int      `json:"number"`
+	Author         string   `json:"author"`
+	HeadSHA        string   `json:"headSha,omitempty"`
+	Action         string   `json:"action"` // merged|enqueued|auto_merge_enabled|commented|review_requested|review_dismissed|branch_updated|marked_ready|rebased|lint_dispatched|test_dispatched|ci_rerun|workflows_approved|closed_stale|skipped|error
+	Reason         string   `json:"reason,omitempty"`
+	MergeCommitOID string   `json:"mergeCommitOid,omitempty"`
+	ChecksState    string   `json:"checksState,omitempty"`
+	Mergeable      string   `json:"mergeable,omitempty"`
+	ReviewDecision string   `json:"reviewDecision,omitempty"`
+	ReviewComments string   `json:"reviewComments,omitempty"`
+	CIFailureType  string   `json:"ciFailureType,omitempty"`
+	DispatchEvent  string   `json:"dispatchEvent,omitempty"`
+	BranchDeletion string   `json:"branchDeletion,omitempty"`
+	LinkedIssues   []string `json:"linkedIssues,omitempty"`
+	ClosedIssues   []string `json:"closedIssues,omitempty"`
+	Reviewer       string   `json:"reviewer,omitempty"`
+	// DismissedReviews are the logins whose stale reviews were dismissed.
+	DismissedReviews []string `json:"dismissedReviews,omitempty"`
+	// ReviewThreads are the unresolved inline review threads.
+	ReviewThreads []reviewThread `json:"reviewThreads,omitempty"`
+	// ProtectedFiles are the changed files that kept the PR from merging.
+	ProtectedFiles []string `json:"protectedFiles,omitempty"`
+	// UntrustedCommits are the commits --verify-commits rejected.
+	UntrustedCommits []string `json:"untrustedCommits,omitempty"`
+	// SecretFindings are where --scan-secrets found likely credentials.
+	SecretFindings []string `json:"secretFindings,omitempty"`
+	// DependencyUpdate is patch, minor, major, or unknown for PRs by
+	// authors in dependency mode.
+	DependencyUpdate string `json:"dependencyUpdate,omitempty"`
+	// TimeToMergeSeconds is how long a merged PR was open.
+	TimeToMergeSeconds int64 `json:"timeToMergeSeconds,omitempty"`
+	// BlockedRuns is how many consecutive runs the PR has been blocked on
+	// this reason, with --escalate-after.
+	BlockedRuns int `json:"blockedRuns,omitempty"`
+	// ReviewArtifact is where --review-artifact saved the review feedback:
+	// a file path or gist URL.
+	ReviewArtifact string `json:"reviewArtifact,omitempty"`
+	// OptionalFailures are failing checks that didn't block the merge.
+	OptionalFailures []string `json:"optionalFailures,omitempty"`
+	// ReasonCode is Reason as a stable code, and ReasonDetail what Reason
+	// says beyond it (an error message, a queue position). Tooling should
+	// switch on the code; Reason's wording may change.
+	ReasonCode   reasonCode `json:"reasonCode,omitempty"`
+	ReasonDetail string     `json:"reasonDetail,omitempty"`
 }
 
 // runState tracks the hash of the last run's results and when we last posted to Discord.
@@ -184,6 +343,19 @@ type runState struct {
 // dedupWindow is the minimum time between identical Discord posts.
 const dedupWindow = 2 * time.Hour
 
+type enqueueMutationResponse struct {
+	Data struct {
+		EnqueuePullRequest struct {
+			MergeQueueEntry struct {
+				Position int `json:"position"`
+			} `json:"mergeQueueEntry"`
+		} `json:"enqueuePullRequest"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
 type mergeMutationResponse struct {
 	Data struct {
 		MergePullRequest struct {
@@ -203,437 +375,1909 @@ type mergeMutationResponse struct {
 
 // retryConfig for transient error retries.
 var retryCfg = RetryConfig{
-	MaxAttempts: 3,
-	BaseDelay:   500,
-	MaxDelay:    5000,
+	MaxAttempts:   3,
+	BaseDelay:     500,
+	MaxDelay:      5000,
+	MaxRetryAfter: 120000,
 }
 
 func main() {
-	var (
-		org                = flag.String("org", "misty-step", "GitHub org/owner to scan")
-		maxPRs             = flag.Int("max-prs", 5, "max PRs to act on per run (bounded)")
-		staleHours         = flag.Int("stale-hours", 72, "stale threshold (hours) applied only to Phaedrus-authored PRs")
-		phaedrus           = flag.String("phaedrus-login", "phrazzld", "GitHub login for Phaedrus (stale threshold applies only to this author)")
-		kaylee             = flag.String("kaylee-login", "kaylee-mistystep", "GitHub login for Kaylee (act immediately for this author)")
-		doNotTouchLabel    = flag.String("do-not-touch-label", "do not touch", "label name that marks a PR as do-not-touch (case-insensitive)")
-		dryRun             = flag.Bool("dry-run", false, "do not merge or comment; only report what would happen")
-		discordReportTo    = flag.String("discord-report-to", "", "Discord report destination (e.g. channel:<id> or raw id). Requires DISCORD_BOT_TOKEN.")
-		discordAlertsTo    = flag.String("discord-alerts-to", "", "Discord alerts destination (e.g. channel:<id> or raw id). Requires DISCORD_BOT_TOKEN.")
-		postEmpty          = flag.Bool("post-empty", false, "post a report even when no PRs were acted on")
-		postDryRun         = flag.Bool("post-dry-run", false, "allow posting a report when --dry-run is set")
-		cbFailureThreshold = flag.Int("cb-failures", 3, "circuit breaker: consecutive failures before skipping a PR")
-		cbSkipRuns         = flag.Int("cb-skip-runs", 5, "circuit breaker: number of runs to skip after opening")
-		stateFile          = flag.String("state-file", "", "path to state file for deduplication (default: ~/.config/fab-pr-pipeline/state.json)")
-	)
-	flag.Parse()
-
-	startedAt := time.Now().UTC().Format(time.RFC3339)
-	out := runOutput{
-		Ok:         true,
-		StartedAt:  startedAt,
-		Org:        *org,
-		MaxPRs:     *maxPRs,
-		StaleHours: *staleHours,
-		DryRun:     *dryRun,
-		Results:    []prOutcome{},
+	flush := redactStderr()
+	code := runCommand(os.Args[1:])
+	flush()
+	os.Exit(code)
+}
+
+// runCommand runs the subcommand args name (run by default) and returns
+// the exit code.
+func runCommand(args []string) int {
+	cmd := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "run":
+		return runRunCommand(args)
+	case "scan":
+		return runScanCommand(args)
+	case "plan":
+		return runPlanCommand(args)
+	case "apply":
+		return runApplyCommand(args)
+	case "report":
+		return runReportCommand(args)
+	case "doctor":
+		return runDoctorCommand(args, os.Stdout)
+	case "history":
+		return runHistoryCommand(args, os.Stdout, os.Stderr)
+	case "digest":
+		return runDigestCommand(args)
+	case "explain":
+		return runExplainCommand(args)
+	case "revert":
+		return runRevertCommand(args)
+	case "help":
+		printUsage(os.Stdout)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", cmd)
+		printUsage(os.Stderr)
+		return 2
 	}
+}
 
-	// Initialize circuit breaker for per-PR error handling
-	cb := NewCircuitBreaker(*cbFailureThreshold, *cbSkipRuns)
+// runOptions holds the flags shared by the run and scan subcommands plus the
+// values derived from them by prepare.
+type runOptions struct {
+	Org                 string
+	MaxPRs              int
+	MaxActions          int
+	MaxRunErrors        int
+	MaxMergeLines       int
+	MaxMergeFiles       int
+	RepoMaxActions      int
+	RepoActionsPerHour  int
+	StaleHours          int
+	Phaedrus            string
+	Kaylee              string
+	DoNotTouchLabel     string
+	AutoMergeLabel      string
+	HoldLabel           string
+	NoWeekendLabel      string
+	PriorityLabel       string
+	DryRun              bool
+	DiscordReportTo     string
+	DiscordAlertsTo     string
+	EmailTo             string
+	Output              string
+	PrintSchema         bool
+	FailOn              string
+	Stream              bool
+	StreamFile          string
+	SlackReport         bool
+	NotifyWebhook       string
+	NotifyLog           bool
+	PostEmpty           bool
+	PostDryRun          bool
+	CBFailures          int
+	CBSkipRuns          int
+	RepoCBFailures      int
+	RepoCBSkipRuns      int
+	StateFile           string
+	RerunFlaky          bool
+	FlakyCheckRegex     string
+	RerunMaxAttempts    int
+	CloseStaleDays      int
+	CloseStaleAuthors   string
+	ConfigPath          string
+	OnlyRepos           string
+	SkipRepos           string
+	HistoryDB           string
+	Authors             string
+	RateLimitFloor      int
+	Timeout             time.Duration
+	ScanLimit           int
+	ArchivedCacheTTL    time.Duration
+	Resume              bool
+	ResumeWindow        time.Duration
+	CILogLines          int
+	FindingsFile        string
+	ReviewArtifact      string
+	CommentTemplateDir  string
+	LintDispatchEvent   string
+	TestDispatchEvent   string
+	AttemptRebase       bool
+	DeleteBranchOnMerge bool
+	CloseLinkedIssues   bool
+	RequiredChecksOnly  bool
+	PromoteDrafts       bool
+	DiscordDMAuthors    bool
+	EnableAutoMerge     bool
+	RequestReviews      bool
+	RequireResolved     bool
+	VerifyCommits       bool
+	TrustedCommitters   string
+	ApproveWorkflowsFor string
+	ForkPolicy          string
+	ScanSecrets         bool
+	SkipUnchanged       bool
+	GroupMajorBumps     bool
+	EscalateAfter       int
+	EscalateMention     string
+	ReportCheckRun      bool
+	DryRunDiff          bool
+	Interactive         bool
+	Serve               string
+	AppID               int64
+	AppKeyFile          string
+	AppInstallationID   int64
+	GHTokenFile         string
+	AuditLog            string
+	ReviewerPool        string
+	PerCallTimeout      time.Duration
+	PRTimeout           time.Duration
+	RecheckAfterUpdate  time.Duration
+	Record              string
+	Replay              string
+	Simulate            string
+	FreezeFile          string
+	FreezeIssue         string
+	FreezeLabel         string
+
+	config       *pipelineConfig
+	authors      authorPolicies
+	flakyRe      *regexp.Regexp
+	onlyRepos    []string
+	skipRepos    []string
+	staleAuthors []string
+	reviewerPool []string
+	orgs         []string
+	notifiers    fanoutNotifier
+	stream       *outcomeStream
+	// denied is the Discord /pipeline skip denylist, loaded at run start.
+	denied   denylist
+	planFile string
+	plan     *planFile
+	operator *operatorPrompt
+	targets  []webhookTarget
+	trusted  []string // --trusted-committers
+	comments *commentTemplates
+	// workflowAuthors are the --approve-workflows-for logins, as authorKeys.
+	workflowAuthors []string
+	// escalateMention is --escalate-mention as Discord mention markup.
+	escalateMention string
+	// freeze is the kill switch from --freeze-file and --freeze-issue.
+	freeze freezeSwitch
+	// explain traces the decision for the explain command; nil otherwise.
+	explain *explainTrace
+	// simulation is the loaded --simulate fixtures file.
+	simulation *simulation
+	// ghTokens are the token pool's tokens, from GH_TOKENS and
+	// --gh-token-file.
+	ghTokens []string
+	// audit records every mutating GitHub call; nil under --simulate and
+	// --replay, which change nothing.
+	audit *auditLog
+	// digest command flags.
+	digestOldest    int
+	digestStuckDays int
+	// revert command flags.
+	revertReason string
+	revertDraft  bool
+}
 
-	prs, err := RetryableWithResult(func() ([]searchPR, error) {
-		return ghSearchPRs(*org, 200)
-	}, retryCfg)
+// registerRunFlags defines the pipeline flags on fs.
+func registerRunFlags(fs *flag.FlagSet) *runOptions {
+	o := &runOptions{}
+	fs.StringVar(&o.Org, "org", "misty-step", "GitHub org/owner to scan (comma-separated to scan several in one run)")
+	fs.IntVar(&o.MaxPRs, "max-prs", 5, "max PRs to act on per run (bounded)")
+	fs.IntVar(&o.MaxActions, "max-actions", 0, "stop after this many merges/comments, working past skipped PRs (0 = stop after -max-prs PRs)")
+	fs.IntVar(&o.MaxMergeLines, "max-merge-lines", 0, "don't merge PRs changing more than this many lines (additions + deletions); comment that a human merge is needed instead (0 = no limit)")
+	fs.IntVar(&o.MaxMergeFiles, "max-merge-files", 0, "don't merge PRs touching more than this many files; comment that a human merge is needed instead (0 = no limit)")
+	fs.IntVar(&o.RepoMaxActions, "repo-max-actions", 0, "cap merges/comments in each repo per run; a repo's maxActions policy overrides it (0 = no cap)")
+	fs.IntVar(&o.RepoActionsPerHour, "repo-max-actions-per-hour", 0, "cap merges/comments in each repo per hour, across runs; a repo's maxActionsPerHour policy overrides it (0 = no cap)")
+	fs.IntVar(&o.MaxRunErrors, "max-run-errors", 0, "stop the run, alert, and exit 3 once more than this many PRs have errored (0 disables)")
+	fs.IntVar(&o.StaleHours, "stale-hours", 72, "stale threshold (hours) applied only to Phaedrus-authored PRs (unless overridden by --authors or the config's staleHours)")
+	fs.StringVar(&o.Phaedrus, "phaedrus-login", "phrazzld", "GitHub login for Phaedrus (stale threshold applies only to this author)")
+	fs.StringVar(&o.Kaylee, "kaylee-login", "kaylee-mistystep", "GitHub login for Kaylee (act immediately for this author)")
+	fs.StringVar(&o.DoNotTouchLabel, "do-not-touch-label", "do not touch", "label name that marks a PR as do-not-touch (case-insensitive)")
+	fs.StringVar(&o.AutoMergeLabel, "automerge-label", "automerge", "label that lets a PR merge without a review decision, even where approval is required (empty disables)")
+	fs.StringVar(&o.HoldLabel, "hold-label", "hold", "label that keeps a PR from merging; the pipeline only comments (empty disables)")
+	fs.StringVar(&o.NoWeekendLabel, "no-weekend-label", "no-weekend-merge", "label that keeps a PR from merging on Saturdays and Sundays (empty disables)")
+	fs.StringVar(&o.PriorityLabel, "priority-label", "priority", "label that moves a PR to the front of the run (empty disables)")
+	fs.StringVar(&o.Output, "output", outputJSON, "how to print the run result: json, markdown, or table")
+	fs.BoolVar(&o.PrintSchema, "print-schema", false, "print the JSON Schema of the run result and exit")
+	fs.StringVar(&o.FailOn, "fail-on", failOnNone, "exit 3 when PRs error: errors (only errors, nothing merged or commented), any-error, or none")
+	fs.BoolVar(&o.Stream, "stream", false, "print each PR outcome to stdout as a JSON line as soon as it's decided, ahead of the run result")
+	fs.StringVar(&o.StreamFile, "stream-file", "", "append each PR outcome as a JSON line to this file instead of stdout")
+	fs.BoolVar(&o.DryRun, "dry-run", false, "do not merge or comment; only report what would happen")
+	fs.Int64Var(&o.AppID, "app-id", 0, "authenticate as this GitHub App instead of the gh user (requires --app-key-file)")
+	fs.StringVar(&o.AppKeyFile, "app-key-file", "", "path to the GitHub App's PEM private key")
+	fs.Int64Var(&o.AppInstallationID, "app-installation-id", 0, "GitHub App installation ID (default: the app's installation on --org)")
+	fs.StringVar(&o.GHTokenFile, "gh-token-file", "", "file of GitHub tokens, one per line, to rotate through (with any in GH_TOKENS), failing over on rate limits and 401s")
+	fs.StringVar(&o.Serve, "serve", "", "listen on this address (e.g. :8080) and run the pipeline on POST /run instead of once")
+	fs.BoolVar(&o.Interactive, "interactive", false, "triage each selected PR in a terminal UI: accept the pipeline's decision, merge, comment, skip, or quit (turns off --timeout and --pr-timeout)")
+	fs.BoolVar(&o.DryRunDiff, "dry-run-diff", false, "dry run, and report what changed since the previous run (newly mergeable, newly conflicting, recovered)")
+	fs.StringVar(&o.DiscordReportTo, "discord-report-to", "", "Discord report destination (e.g. channel:<id> or raw id). Requires DISCORD_BOT_TOKEN.")
+	fs.StringVar(&o.DiscordAlertsTo, "discord-alerts-to", "", "Discord alerts destination (e.g. channel:<id> or raw id). Requires DISCORD_BOT_TOKEN.")
+	fs.StringVar(&o.EmailTo, "email-to", "", "comma-separated addresses to email run summaries and error alerts to. Requires SMTP_HOST and SMTP_FROM.")
+	fs.BoolVar(&o.SlackReport, "slack-report", false, "post run summaries and error alerts to the Slack incoming webhook in SLACK_WEBHOOK_URL")
+	fs.StringVar(&o.NotifyWebhook, "notify-webhook", "", "POST each run's JSON output and error alerts to this URL")
+	fs.BoolVar(&o.NotifyLog, "notify-log", false, "write a plain-text run summary and error alerts to stderr")
+	fs.BoolVar(&o.PostEmpty, "post-empty", false, "post a report even when no PRs were acted on")
+	fs.BoolVar(&o.PostDryRun, "post-dry-run", false, "allow posting a report when --dry-run is set")
+	fs.IntVar(&o.CBFailures, "cb-failures", 3, "circuit breaker: consecutive failures before skipping a PR")
+	fs.IntVar(&o.CBSkipRuns, "cb-skip-runs", 5, "circuit breaker: number of runs to skip after opening")
+	fs.IntVar(&o.RepoCBFailures, "repo-cb-failures", 3, "repo circuit breaker: back-to-back PR errors in one repo before skipping the whole repo (0 disables)")
+	fs.IntVar(&o.RepoCBSkipRuns, "repo-cb-skip-runs", 3, "repo circuit breaker: number of runs to skip the repo after opening")
+	fs.StringVar(&o.StateFile, "state-file", "", "path to state file for deduplication (default: ~/.config/fab-pr-pipeline/state.json)")
+	fs.BoolVar(&o.RerunFlaky, "rerun-flaky", true, "re-run failed jobs instead of commenting when every failing check looks flaky (cancelled, timed out, or matches --flaky-check-regex)")
+	fs.StringVar(&o.FlakyCheckRegex, "flaky-check-regex", "", "regexp matched against failing check names to treat them as flaky (case-insensitive)")
+	fs.IntVar(&o.RerunMaxAttempts, "rerun-max-attempts", 2, "do not re-run a workflow run once it has reached this many attempts")
+	fs.IntVar(&o.CloseStaleDays, "close-stale-days", 0, "close PRs from --close-stale-authors untouched for this many days (0 disables)")
+	fs.StringVar(&o.CloseStaleAuthors, "close-stale-authors", "kaylee-mistystep", "comma-separated bot logins whose stale PRs may be closed")
+	fs.StringVar(&o.ConfigPath, "config", "", "path to JSON config file with per-repo policy overrides")
+	fs.StringVar(&o.OnlyRepos, "only-repos", "", "comma-separated repos or globs to restrict the run to (e.g. misty-step/fab-*)")
+	fs.StringVar(&o.SkipRepos, "skip-repos", "", "comma-separated repos or globs to exclude from the run")
+	fs.StringVar(&o.AuditLog, "audit-log", "", "append-only JSONL log of every change made on GitHub: merges, comments, branch updates, review dismissals (default: audit.jsonl beside the state file)")
+	fs.StringVar(&o.HistoryDB, "history-db", "", "path to SQLite database recording every run and per-PR outcome (empty disables)")
+	fs.StringVar(&o.Authors, "authors", "", "comma-separated login=mode author profiles (modes: immediate, stale:<hours>, comment-only, skip; login * sets the default)")
+	fs.IntVar(&o.RateLimitFloor, "rate-limit-floor", 200, "stop acting on PRs once remaining GitHub core or GraphQL quota drops below this (0 disables)")
+	fs.IntVar(&o.ScanLimit, "scan-limit", searchResultCap, "max open PRs to scan, most recently updated first (GitHub search caps this at 1000)")
+	fs.IntVar(&o.CILogLines, "ci-log-lines", 200, "when failing check names don't reveal the failure type, classify from annotations and this many trailing job log lines (0 disables)")
+	fs.StringVar(&o.CommentTemplateDir, "comment-template-dir", "", "directory of Go text/template files (not_merged.tmpl, conflict.tmpl) replacing the built-in PR comment wording")
+	fs.StringVar(&o.ReviewArtifact, "review-artifact", "", "save the full review feedback on PRs with changes requested as JSON for fixer agents, and link it in the alert: a directory, or \"gist\" for a secret gist (empty disables)")
+	fs.StringVar(&o.FindingsFile, "findings-file", "", "write CI failures diagnosed from logs to this file as SARIF (check, category, excerpt, file/line) for fixer agents")
+	fs.StringVar(&o.LintDispatchEvent, "lint-dispatch-event", "", "repository_dispatch event type sent to the PR's repo on lint failures, with the PR and failing checks as client_payload (empty disables)")
+	fs.StringVar(&o.TestDispatchEvent, "test-dispatch-event", "", "repository_dispatch event type sent to the PR's repo on test failures (empty disables)")
+	fs.BoolVar(&o.AttemptRebase, "attempt-rebase", false, "when update-branch can't merge the base in, rebase the PR branch in a shallow clone and force-push it if clean")
+	fs.BoolVar(&o.DeleteBranchOnMerge, "delete-branch-on-merge", false, "delete the head branch after a direct merge (never on forks or protected branches)")
+	fs.BoolVar(&o.RequiredChecksOnly, "required-checks-only", true, "gate merging only on the checks the base branch's protection or rulesets require; other failures are reported but don't block")
+	fs.BoolVar(&o.CloseLinkedIssues, "close-linked-issues", false, "after a direct merge, close issues the PR body says it closes (\"Closes #N\") if GitHub left them open")
+	fs.BoolVar(&o.EnableAutoMerge, "enable-auto-merge", false, "enable GitHub auto-merge on approved, mergeable PRs whose checks are still pending instead of commenting")
+	fs.BoolVar(&o.DiscordDMAuthors, "discord-dm-authors", false, "DM changes-requested and conflict alerts to authors mapped in the config's discordUsers instead of mentioning them in --discord-alerts-to")
+	fs.BoolVar(&o.PromoteDrafts, "promote-drafts", false, "mark draft PRs by --kaylee-login ready for review once all their checks pass, instead of skipping them")
+	fs.BoolVar(&o.RequestReviews, "request-reviews", false, "on review_required, request a review from the repo's reviewer pool or CODEOWNERS instead of commenting")
+	fs.BoolVar(&o.RequireResolved, "require-threads-resolved", false, "don't merge a PR with unresolved review threads, even when approved")
+	fs.BoolVar(&o.VerifyCommits, "verify-commits", false, "don't merge a PR unless every commit is signed and authored and committed by the PR author or a --trusted-committers login")
+	fs.StringVar(&o.TrustedCommitters, "trusted-committers", "", "comma-separated logins (e.g. bots) allowed to author or commit on any PR under --verify-commits")
+	fs.StringVar(&o.ApproveWorkflowsFor, "approve-workflows-for", "", "comma-separated PR authors whose workflow runs awaiting approval (usually on forks) the pipeline approves, instead of commenting workflows_awaiting_approval")
+	fs.StringVar(&o.ForkPolicy, "fork-policy", forkPolicyCommentOnly, "how to treat PRs from forks: comment-only (never merge, update, or enable auto-merge), merge-only (merge, but never update or enable auto-merge), or allow (like any PR)")
+	fs.BoolVar(&o.ScanSecrets, "scan-secrets", false, "scan a PR's added lines for credentials (built-in patterns, plus gitleaks if on PATH) before merging; block and alert on a hit")
+	fs.BoolVar(&o.SkipUnchanged, "skip-unchanged", false, "skip a PR without looking at it when its head commit, checks, and update time match the run that last found it blocked")
+	fs.BoolVar(&o.GroupMajorBumps, "group-major-bumps", false, "for authors in dependency mode, send one alert per run listing held major updates instead of commenting on each PR")
+	fs.IntVar(&o.EscalateAfter, "escalate-after", 0, "after a PR has been blocked on the same reason for this many consecutive runs, send an escalation alert to the alert notifiers (0 disables)")
+	fs.StringVar(&o.EscalateMention, "escalate-mention", "", "who escalation alerts mention: role:<id>, user:<id>, or a Discord user ID")
+	fs.StringVar(&o.ReviewerPool, "reviewer-pool", "", "comma-separated default reviewer logins (or org/team slugs) for --request-reviews; config repos.<repo>.reviewers overrides")
+	fs.BoolVar(&o.ReportCheckRun, "report-check-run", false, "create or update a kaylee-pipeline check run on each PR's head commit summarizing the decision (needs a GitHub App token)")
+	fs.DurationVar(&o.ArchivedCacheTTL, "archived-cache-ttl", 0, "reuse the archived-repo list saved beside the state file for this long (0 fetches every run)")
+	fs.BoolVar(&o.Resume, "resume", false, "pick up a run that was killed partway, skipping the PRs its checkpoint says it already processed")
+	fs.DurationVar(&o.ResumeWindow, "resume-window", 6*time.Hour, "how recent a checkpoint --resume will pick up")
+	fs.DurationVar(&o.Timeout, "timeout", 15*time.Minute, "overall deadline for scanning and acting on PRs; remaining PRs are skipped once it passes (0 disables)")
+	fs.DurationVar(&o.PerCallTimeout, "per-call-timeout", defaultCallTimeout, "deadline for each gh command or Discord request (0 disables)")
+	fs.DurationVar(&o.PRTimeout, "pr-timeout", 5*time.Minute, "deadline for acting on one PR; a PR that runs over is reported as an error and the run moves on (0 disables)")
+	fs.StringVar(&o.FreezeFile, "freeze-file", "", "kill switch file (owner/repo:path); while it exists every run is a dry run")
+	fs.StringVar(&o.FreezeIssue, "freeze-issue", "", "kill switch control issue (owner/repo#N); while it has --freeze-label every run is a dry run")
+	fs.StringVar(&o.FreezeLabel, "freeze-label", "freeze", "label on --freeze-issue that freezes the pipeline")
+	fs.DurationVar(&o.RecheckAfterUpdate, "recheck-after-update", 0, "after updating a PR's branch, wait up to this long for CI on the new head and merge in the same run if it passes (0 leaves it for the next run)")
+	fs.StringVar(&o.Record, "record", "", "save every gh command and its response to this directory, for --replay")
+	fs.StringVar(&o.Replay, "replay", "", "answer gh commands from recordings in this directory instead of calling GitHub")
+	fs.StringVar(&o.Simulate, "simulate", "", "run against the PR snapshots in this fixtures file instead of GitHub, and print what the run would have done")
+	return o
+}
+
+// prepare validates the flags and computes derived values.
+func (o *runOptions) prepare() error {
+	o.orgs = splitList(o.Org)
+	if len(o.orgs) == 0 {
+		return errors.New("--org is required")
+	}
+	if o.DryRunDiff {
+		o.DryRun = true
+	}
+	if o.Output == "" {
+		o.Output = outputJSON
+	}
+	if !validOutput(o.Output) {
+		return fmt.Errorf("--output must be json, markdown, or table, got %q", o.Output)
+	}
+	if o.FailOn == "" {
+		o.FailOn = failOnNone
+	}
+	if !validFailOn(o.FailOn) {
+		return fmt.Errorf("--fail-on must be errors, any-error, or none, got %q", o.FailOn)
+	}
+	if o.ForkPolicy == "" {
+		o.ForkPolicy = forkPolicyCommentOnly
+	}
+	if !validForkPolicy(o.ForkPolicy) {
+		return fmt.Errorf("--fork-policy must be comment-only, merge-only, or allow, got %q", o.ForkPolicy)
+	}
+	if o.MaxActions < 0 {
+		return errors.New("--max-actions must be >= 0")
+	}
+	if o.RepoMaxActions < 0 || o.RepoActionsPerHour < 0 {
+		return errors.New("--repo-max-actions and --repo-max-actions-per-hour must be >= 0")
+	}
+	if o.MaxRunErrors < 0 {
+		return errors.New("--max-run-errors must be >= 0")
+	}
+	if o.MaxMergeLines < 0 || o.MaxMergeFiles < 0 {
+		return errors.New("--max-merge-lines and --max-merge-files must be >= 0")
+	}
+	if o.Resume && o.ResumeWindow <= 0 {
+		return errors.New("--resume-window must be > 0")
+	}
+	o.onlyRepos = splitList(o.OnlyRepos)
+	o.skipRepos = splitList(o.SkipRepos)
+	if err := validateRepoPatterns(append(append([]string{}, o.onlyRepos...), o.skipRepos...)); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(o.ConfigPath)
+	if err != nil {
+		return err
+	}
+	o.config = cfg
+
+	authorFlag, err := parseAuthorPolicies(o.Authors)
+	if err != nil {
+		return err
+	}
+	phaedrus := o.Phaedrus
+	if len(cfg.StaleHours) > 0 {
+		// The config's stale matrix replaces the Phaedrus special case.
+		phaedrus = ""
+	}
+	o.authors = defaultAuthorPolicies(phaedrus, o.StaleHours, o.Kaylee).
+		merge(staleAuthorPolicies(cfg.StaleHours)).merge(cfg.Authors).merge(authorFlag)
+
+	if strings.TrimSpace(o.FlakyCheckRegex) != "" {
+		re, err := regexp.Compile("(?i)" + o.FlakyCheckRegex)
+		if err != nil {
+			return fmt.Errorf("invalid --flaky-check-regex: %w", err)
+		}
+		o.flakyRe = re
+	}
+	o.staleAuthors = splitList(o.CloseStaleAuthors)
+	if o.notifiers, err = buildNotifiers(o); err != nil {
+		return err
+	}
+	if o.stream, err = newOutcomeStream(o.Stream, o.StreamFile); err != nil {
+		return err
+	}
+	o.reviewerPool = splitList(o.ReviewerPool)
+	o.trusted = splitList(o.TrustedCommitters)
+	for _, login := range splitList(o.ApproveWorkflowsFor) {
+		o.workflowAuthors = append(o.workflowAuthors, authorKey(login))
+	}
+	if (o.AppID != 0) != (o.AppKeyFile != "") {
+		return errors.New("--app-id and --app-key-file must be set together")
+	}
+	if o.AppID != 0 && len(o.orgs) > 1 {
+		// An installation token is scoped to a single org.
+		return errors.New("--app-id supports a single --org")
+	}
+	if o.ghTokens, err = loadGHTokens(os.Getenv("GH_TOKENS"), o.GHTokenFile); err != nil {
+		return err
+	}
+	if o.AppID != 0 && len(o.ghTokens) > 0 {
+		return errors.New("--app-id can't be combined with a token pool (GH_TOKENS or --gh-token-file)")
+	}
+	if o.Timeout < 0 || o.PerCallTimeout < 0 || o.PRTimeout < 0 {
+		return errors.New("--timeout, --per-call-timeout, and --pr-timeout must not be negative")
+	}
+	if o.Interactive {
+		if o.Serve != "" {
+			return errors.New("--interactive can't be combined with --serve")
+		}
+		if !isTerminal(os.Stdin) {
+			return errors.New("--interactive needs a terminal on stdin")
+		}
+		// The operator's think time isn't the PR's: a deadline running
+		// through the prompt would fail the choice they made as a timeout.
+		o.Timeout, o.PRTimeout = 0, 0
+		o.operator = newOperatorPrompt(os.Stdin, os.Stderr)
+	}
+	if o.EscalateAfter < 0 {
+		return errors.New("--escalate-after must not be negative")
+	}
+	mention, err := escalationMention(o.EscalateMention)
+	if err != nil {
+		return err
+	}
+	o.escalateMention = mention
+	if o.RecheckAfterUpdate < 0 {
+		return errors.New("--recheck-after-update must not be negative")
+	}
+	if o.RecheckAfterUpdate > 0 && o.PRTimeout > 0 && o.RecheckAfterUpdate >= o.PRTimeout {
+		return fmt.Errorf("--recheck-after-update (%s) must be shorter than --pr-timeout (%s)", o.RecheckAfterUpdate, o.PRTimeout)
+	}
+	if o.comments, err = loadCommentTemplates(o.CommentTemplateDir); err != nil {
+		return err
+	}
+	for _, tone := range cfg.commentTones() {
+		if !o.comments.hasTone(tone) {
+			return fmt.Errorf("config: unknown commentTone %q", tone)
+		}
+	}
+	if o.freeze, err = parseFreezeSwitch(o.FreezeFile, o.FreezeIssue, o.FreezeLabel); err != nil {
+		return err
+	}
+	if o.Record != "" && o.Replay != "" {
+		return errors.New("--record and --replay can't be combined")
+	}
+	if o.Simulate != "" && (o.Record != "" || o.Replay != "" || o.Serve != "") {
+		return errors.New("--simulate can't be combined with --record, --replay, or --serve")
+	}
+	if o.Simulate == "" && o.Replay == "" {
+		o.audit = newAuditLog(o.auditLogFile())
+	}
+	return nil
+}
+
+// runContext returns the context bounding a run by --timeout.
+func (o *runOptions) runContext() (context.Context, context.CancelFunc) {
+	if o.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), o.Timeout)
+}
+
+// parseRunFlags parses args for a subcommand that takes the pipeline flags.
+// Returns nil options and the exit code to use when parsing fails.
+func parseRunFlags(name string, args []string) (*runOptions, int) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	opts := registerRunFlags(fs)
+	if name == "plan" || name == "apply" {
+		fs.StringVar(&opts.planFile, "plan-file", "", "path to the plan file (default: plan.json beside the state file)")
+	}
+	if name == "digest" {
+		fs.IntVar(&opts.digestOldest, "oldest", 10, "how many of the oldest open PRs to list")
+		fs.IntVar(&opts.digestStuckDays, "changes-requested-days", 3, "list PRs whose changes were requested more than this many days ago")
+	}
+	if name == "revert" {
+		fs.StringVar(&opts.revertReason, "reason", "", "why the merge is being undone, for the revert PR and the comment on the original")
+		fs.BoolVar(&opts.revertDraft, "draft", false, "open the revert PR as a draft")
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, 0
+		}
+		return nil, 2
+	}
+	if opts.PrintSchema {
+		_, _ = os.Stdout.Write(runOutputSchema)
+		return nil, 0
+	}
+	if err := opts.prepare(); err != nil {
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return nil, 1
+	}
+	callTimeout = opts.PerCallTimeout
+	if opts.AppID != 0 && opts.Simulate == "" {
+		src, err := newAppTokenSource(opts.AppID, opts.AppKeyFile, opts.AppInstallationID, opts.Org)
+		if err != nil {
+			emitJSON(map[string]any{"ok": false, "error": err.Error()})
+			return nil, 1
+		}
+		ghTokenSource = src
+	}
+	if len(opts.ghTokens) > 0 && opts.Simulate == "" {
+		ghTokenPool = newTokenPool(opts.ghTokens)
+	}
+	switch {
+	case opts.Record != "":
+		c, err := newRecordingClient(githubClient, opts.Record)
+		if err != nil {
+			emitJSON(map[string]any{"ok": false, "error": err.Error()})
+			return nil, 1
+		}
+		githubClient = c
+	case opts.Replay != "":
+		c, err := newReplayClient(opts.Replay)
+		if err != nil {
+			emitJSON(map[string]any{"ok": false, "error": err.Error()})
+			return nil, 1
+		}
+		githubClient = c
+	case opts.Simulate != "":
+		if name != "run" {
+			emitJSON(map[string]any{"ok": false, "error": "--simulate only works with run"})
+			return nil, 2
+		}
+		sim, err := loadSimulation(opts.Simulate)
+		if err != nil {
+			emitJSON(map[string]any{"ok": false, "error": err.Error()})
+			return nil, 1
+		}
+		opts.simulation = sim
+		githubClient = newSimulatedClient(sim)
+	}
+	return opts, 0
+}
+
+// runRunCommand implements `fab-pr-pipeline run` (the default command):
+// scan, act on selected PRs, report, and print the run JSON.
+func runRunCommand(args []string) int {
+	opts, code := parseRunFlags("run", args)
+	if opts == nil {
+		return code
+	}
+	if opts.Serve != "" {
+		return runServer(opts)
+	}
+	if opts.simulation != nil {
+		return runSimulation(opts)
+	}
+
+	statePath := resolveStatePath(opts.StateFile)
+	var prev runOutput
+	if opts.DryRunDiff {
+		// Read before this run overwrites it.
+		var loadErr error
+		if prev, loadErr = loadLastRun(lastRunPath(statePath)); loadErr != nil {
+			fmt.Fprintf(os.Stderr, "[dry-run-diff] %v; every PR counts as new\n", loadErr)
+		}
+	}
+
+	ctx, cancel := opts.runContext()
+	out, err := newPipeline(opts).Run(ctx)
+	cancel()
 	if err != nil {
-		if IsPermanent(err) {
-			// Permanent error - don't retry further
-			msg := "scan failed (permanent): " + err.Error()
-			postDiscordAlertIfConfigured(*discordAlertsTo, msg)
-			fatalJSON(errors.New(msg))
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return 1
+	}
+	if opts.DryRunDiff {
+		diff := diffRuns(prev.Results, out.Results)
+		diff.Since = prev.StartedAt
+		writeRunDiff(os.Stderr, diff)
+		out.Diff = &diff
+	}
+	return finishRun(opts, out)
+}
+
+// finishRun saves, reports, records, and prints a completed run, returning
+// the exit code.
+func finishRun(opts *runOptions, out runOutput) int {
+	out = recordRun(opts, out)
+	writeRunOutput(os.Stdout, opts.Output, out)
+	return runExitCode(opts.FailOn, out)
+}
+
+// recordRun saves the run as last-run.json, writes the Actions step summary,
+// posts it to the notifiers (subject to dedup), and records it in the history
+// DB. A failed post marks the returned output not ok.
+func recordRun(opts *runOptions, out runOutput) runOutput {
+	statePath := resolveStatePath(opts.StateFile)
+	out.Stats = runStatsFor(opts.HistoryDB, out, time.Now())
+
+	// Post run summary + alerts if configured.
+	// First, check if we should skip due to deduplication.
+	if err := saveLastRun(lastRunPath(statePath), out); err != nil {
+		fmt.Fprintf(os.Stderr, "[last-run] failed to save: %v\n", err)
+	}
+	writeActionsSummary(out)
+	currentHash := hashResults(out.Results)
+	hist := openRunHistory(opts.HistoryDB)
+	if hist != nil {
+		defer func() { _ = hist.Close() }()
+	}
+	shouldPost, skipReason := shouldPostRun(hist, out.Org, statePath, currentHash)
+
+	if !shouldPost {
+		fmt.Fprintf(os.Stderr, "[dedup] skipping report: %s\n", skipReason)
+	} else if shouldReport(out, opts.PostEmpty, opts.PostDryRun) {
+		if err := opts.notifiers.Post(context.Background(), out); err != nil {
+			out.Ok = false
+			out.Error = redact(err.Error())
+			recordHistory(opts.HistoryDB, out)
+			return out
+		}
+		// Update state file after successful post
+		if err := saveRunState(hist, out.Org, statePath, currentHash); err != nil {
+			fmt.Fprintf(os.Stderr, "[dedup] failed to save state: %v\n", err)
+			// Don't fail the run, just log
 		}
-		// Transient error - we've already retried, report failure
-		msg := "scan failed (after retries): " + err.Error()
-		postDiscordAlertIfConfigured(*discordAlertsTo, msg)
-		fatalJSON(errors.New(msg))
 	}
 
-	selected := make([]searchPR, 0, len(prs))
-	for _, pr := range prs {
-		if pr.IsDraft {
+	recordHistory(opts.HistoryDB, out)
+	return out
+}
+
+// scanPRs searches the org for open PRs and applies the selection policy.
+// Returns the selected PRs and the scanned PRs it left out.
+// Scan failures are alerted to the notifier and returned.
+// With several orgs, an org whose scan fails is alerted and left out; the
+// run fails only if every org does.
+func (p *Pipeline) scanPRs(ctx context.Context, now time.Time) ([]searchPR, []filteredPR, error) {
+	ctx = withGitHubClient(ctx, p.client)
+	opts := p.opts
+	var prs []searchPR
+	var scanErr error
+	for _, org := range opts.orgs {
+		// Pages are retried individually inside ghSearchPRs.
+		res, err := ghSearchPRs(ctx, org, opts.ScanLimit)
+		if err != nil {
+			prefix := "scan failed"
+			if len(opts.orgs) > 1 {
+				prefix = "scan of " + org + " failed"
+			}
+			if IsPermanent(err) {
+				// Permanent error - don't retry further
+				scanErr = errors.New(prefix + " (permanent): " + err.Error())
+			} else {
+				// Transient error - we've already retried, report failure
+				scanErr = errors.New(prefix + " (after retries): " + err.Error())
+			}
+			notifyAlert(ctx, p.notifier, scanErr.Error())
 			continue
 		}
-		if isDoNotTouch(*doNotTouchLabel, pr.Title, pr.Body, pr.Labels) {
-			continue
+		if res.Truncated() {
+			fmt.Fprintf(os.Stderr, "[scan] scanned %d of %d open PRs in %s (raise --scan-limit to see the rest)\n", len(res.PRs), res.Total, org)
 		}
-		author := strings.TrimSpace(pr.Author.Login)
-		if author == "" {
+		prs = append(prs, res.PRs...)
+	}
+	if scanErr != nil && prs == nil {
+		return nil, nil, scanErr
+	}
+	selected, filtered := partitionPRs(opts, prs, now)
+	return selected, filtered, nil
+}
+
+// selectPRs filters search results down to the PRs the pipeline should act
+// on, ordered by processing priority.
+func selectPRs(opts *runOptions, prs []searchPR, now time.Time) []searchPR {
+	selected, _ := partitionPRs(opts, prs, now)
+	return selected
+}
+
+// partitionPRs splits search results into the PRs to act on, ordered by
+// processing priority, and the rest, with why each was left out.
+func partitionPRs(opts *runOptions, prs []searchPR, now time.Time) ([]searchPR, []filteredPR) {
+	selected := make([]searchPR, 0, len(prs))
+	var filtered []filteredPR
+	for _, pr := range prs {
+		if reason := exclusionReason(opts, pr, now); reason != "" {
+			opts.explain.gate("selection", false, reason)
+			filtered = append(filtered, newFilteredPR(pr, reason))
 			continue
 		}
-		if strings.EqualFold(author, *phaedrus) {
-			age := time.Since(pr.UpdatedAt)
-			if age < time.Duration(*staleHours)*time.Hour {
-				continue
-			}
-		}
-		// Kaylee-authored: act immediately (no stale wait)
-		// Everyone else: act immediately (no stale wait), per spec.
-		_ = kaylee // kept for clarity and future tuning.
+		opts.explain.gate("selection", true, "author "+pr.Author.Login+", "+opts.authors.policyFor(pr.Author.Login, pr.Author.IsBot).Mode)
 		selected = append(selected, pr)
 	}
 
 	// Process most-recently-updated PRs first — they're more likely
 	// to have fresh CI results and be merge-ready.
 	sortByUpdatedAtDesc(selected)
+	// Stale closures sort last by age; pull them forward so they aren't
+	// starved by the maxPRs window and the backlog actually drains.
+	sort.SliceStable(selected, func(i, j int) bool {
+		iStale := isCloseStaleCandidate(selected[i].Author.Login, selected[i].UpdatedAt, opts.staleAuthors, opts.CloseStaleDays, now)
+		jStale := isCloseStaleCandidate(selected[j].Author.Login, selected[j].UpdatedAt, opts.staleAuthors, opts.CloseStaleDays, now)
+		return iStale && !jStale
+	})
+	// Maintainer-flagged PRs go ahead of everything else.
+	sort.SliceStable(selected, func(i, j int) bool {
+		return hasLabel(selected[i].Labels, opts.PriorityLabel) && !hasLabel(selected[j].Labels, opts.PriorityLabel)
+	})
+	return selected, filtered
+}
+
+// Pipeline is one pipeline run and what it depends on: the flags and loaded
+// config, the GitHub client, where run-level alerts go, the clock, and the
+// circuit breakers. newPipeline wires in the real ones; tests swap fields.
+type Pipeline struct {
+	opts     *runOptions
+	client   GitHubClient
+	notifier Notifier
+	now      func() time.Time
+	breaker  *CircuitBreaker
+	// repoBreaker skips a whole repo after back-to-back PR errors (nil disables).
+	repoBreaker *CircuitBreaker
+}
+
+func newPipeline(opts *runOptions) *Pipeline {
+	return &Pipeline{
+		opts:        opts,
+		client:      githubClient,
+		notifier:    opts.notifiers,
+		now:         time.Now,
+		breaker:     NewCircuitBreaker(opts.CBFailures, opts.CBSkipRuns),
+		repoBreaker: newRepoCircuitBreaker(opts.RepoCBFailures, opts.RepoCBSkipRuns),
+	}
+}
+
+// pipelineRun is the per-run state shared across PRs: lookups cached once
+// per run and the CI diagnoses collected for --findings-file.
+type pipelineRun struct {
+	now           time.Time
+	archivedRepos map[string]bool
+	budget        *rateLimitBudget
+	// repo@base -> merge queue enabled, looked up lazily once per run.
+	mergeQueues map[string]bool
+	// repo@base -> required check names, looked up lazily once per run.
+	requiredChecks map[string][]string
+	findings       []ciFinding
+	// repo -> the repo circuit is open this run, checked once per repo so
+	// the breaker's skip counts runs rather than PRs.
+	repoOpen map[string]bool
+	// PR URL -> already rechecked after a branch update this run.
+	rechecked map[string]bool
+	// repoActions is what earlier runs did in each repo over the past
+	// hour, for the hourly per-repo limit.
+	repoActions repoActionLog
+	// intents are merges and comments started but not seen to finish;
+	// nil in a dry run.
+	intents *intentLog
+	// decisions are the blockers earlier runs settled on, with
+	// --skip-unchanged.
+	decisions decisionLog
+}
+
+// Run scans the org and acts on each selected PR, returning the run output.
+// An error means the run could not start (e.g. the scan failed).
+func (p *Pipeline) Run(ctx context.Context) (runOutput, error) {
+	ctx = withGitHubClient(ctx, p.opts.audit.wrap(p.client))
+	// The kill switch is checked on every run, so it also stops a --serve
+	// pipeline without a restart.
+	var frozen string
+	if !p.opts.DryRun {
+		if frozen = p.opts.freeze.check(ctx); frozen != "" {
+			fmt.Fprintf(os.Stderr, "[freeze] %s; acting on nothing this run\n", frozen)
+			frozenOpts := *p.opts
+			frozenOpts.DryRun = true
+			frozenPipeline := *p
+			frozenPipeline.opts = &frozenOpts
+			p = &frozenPipeline
+		}
+	}
+	opts := p.opts
+	now := p.now()
+	out := runOutput{
+		SchemaVersion: runOutputSchemaVersion,
+		Ok:            true,
+		StartedAt:     now.UTC().Format(time.RFC3339),
+		Org:           opts.Org,
+		MaxPRs:        opts.MaxPRs,
+		MaxActions:    opts.MaxActions,
+		StaleHours:    opts.StaleHours,
+		DryRun:        opts.DryRun,
+		Frozen:        frozen,
+		Results:       []prOutcome{},
+	}
+
+	opts.denied = loadDenylist(denylistPath(resolveStatePath(opts.StateFile)), now)
+	var selected []searchPR
+	if opts.plan != nil {
+		// apply: act on exactly the planned PRs.
+		selected = opts.plan.searchPRs()
+	} else if len(opts.targets) > 0 {
+		// Webhook delivery: just the PRs it was about.
+		selected, out.Filtered = targetPRs(ctx, opts, now)
+	} else {
+		var err error
+		selected, out.Filtered, err = p.scanPRs(ctx, now)
+		if err != nil {
+			return out, err
+		}
+		out.Scanned = len(selected) + len(out.Filtered)
+	}
 
 	// Batch-fetch all archived repos upfront to avoid N per-PR API calls.
-	archivedRepos, archFetchErr := fetchArchivedRepos(*org)
+	// With --archived-cache-ttl the set is reused across runs until it expires.
+	var archivedRepos map[string]bool
+	var archFetchErr error
+	for _, org := range opts.orgs {
+		var orgArchived map[string]bool
+		orgArchived, archFetchErr = loadArchivedRepos(ctx, org, archivedCachePath(resolveStatePath(opts.StateFile), org), opts.ArchivedCacheTTL, now)
+		if archFetchErr != nil {
+			break
+		}
+		if archivedRepos == nil {
+			archivedRepos = make(map[string]bool)
+		}
+		maps.Copy(archivedRepos, orgArchived)
+	}
 	if archFetchErr != nil {
 		// Log error but continue - will fall back to per-PR checking.
 		fmt.Fprintf(os.Stderr, "[archived-repos] batch fetch failed: %v (falling back to per-PR checks)\n", archFetchErr)
 		archivedRepos = nil
-	} else if *dryRun {
-		// Count archived repos for dry-run output.
-		archivedCount := 0
-		for _, v := range archivedRepos {
-			if v {
-				archivedCount++
+	} else if opts.DryRun {
+		fmt.Fprintf(os.Stderr, "[archived-repos] batch-checked org, %d archived\n", len(archivedRepos))
+	}
+
+	// The breakers pick up where the last run left off; a dry run reads
+	// them but doesn't save its changes. With a history DB they, and the
+	// hourly repo action counts, are kept there instead of in files.
+	hist := openRunHistory(opts.HistoryDB)
+	if hist != nil {
+		defer func() { _ = hist.Close() }()
+	}
+	loadBreaker(hist, p.breaker, resolveStatePath(opts.StateFile), now)
+	if p.repoBreaker != nil {
+		loadBreaker(hist, p.repoBreaker, resolveStatePath(opts.StateFile), now)
+	}
+
+	run := &pipelineRun{
+		now:            now,
+		archivedRepos:  archivedRepos,
+		budget:         newRateLimitBudget(ctx, opts.RateLimitFloor),
+		mergeQueues:    make(map[string]bool),
+		requiredChecks: make(map[string][]string),
+		repoOpen:       make(map[string]bool),
+		rechecked:      make(map[string]bool),
+		repoActions:    loadRunRepoActions(hist, resolveStatePath(opts.StateFile), now),
+	}
+	if !opts.DryRun {
+		run.intents = loadIntents(intentsPath(resolveStatePath(opts.StateFile)), now)
+	}
+	if opts.SkipUnchanged {
+		run.decisions = loadDecisions(decisionsPath(resolveStatePath(opts.StateFile)), now)
+	}
+
+	// A run that acts saves a checkpoint after every PR; a dry run is
+	// cheap to redo and doesn't.
+	var cpPath string
+	cpStarted := out.StartedAt
+	if !opts.DryRun {
+		cpPath = checkpointPath(resolveStatePath(opts.StateFile))
+		if cp := loadCheckpoint(cpPath, opts.Org, now, opts.ResumeWindow); cp != nil {
+			if opts.Resume {
+				fmt.Fprintf(os.Stderr, "[checkpoint] resuming the run started %s: %d PRs already processed\n", cp.StartedAt, len(cp.Results))
+				out.Results = append(out.Results, cp.Results...)
+				selected = resumeFrom(cp, selected)
+				cpStarted = cp.StartedAt
+			} else {
+				fmt.Fprintf(os.Stderr, "[checkpoint] the run started %s stopped after %d PRs; pass --resume to pick up where it left off\n", cp.StartedAt, len(cp.Results))
 			}
 		}
-		fmt.Fprintf(os.Stderr, "[archived-repos] batch-checked %d repos, %d archived\n", len(archivedRepos), archivedCount)
 	}
 
-	acted := 0
+	if opts.operator != nil {
+		opts.operator.setQueue(selected)
+	}
+	acted := len(out.Results)
+	processed := 0
 	for _, pr := range selected {
-		if acted >= *maxPRs {
+		if opts.MaxActions > 0 {
+			// Skips and errors are free; only actions spend the budget.
+			if countActions(out.Results) >= opts.MaxActions {
+				break
+			}
+		} else if acted >= opts.MaxPRs {
 			break
 		}
 		acted++
+		processed++
+		outcome := opts.stream.emit(p.processRepoPR(ctx, run, out.Results, pr).redacted())
+		out.Results = append(out.Results, outcome)
+		if run.decisions != nil {
+			run.decisions.record(pr, outcome, p.now())
+		}
+		if cpPath != "" {
+			saveCheckpoint(cpPath, runCheckpoint{StartedAt: cpStarted, SavedAt: p.now().UTC().Format(time.RFC3339), Org: opts.Org, Results: out.Results})
+		}
+
+		// Errors piling up usually mean something systemic (an expired
+		// token, a GitHub outage); stop before failing on every PR.
+		if errs := countErrors(out.Results); opts.MaxRunErrors > 0 && errs > opts.MaxRunErrors {
+			out.Aborted = fmt.Sprintf("error budget exhausted: %d PRs errored (--max-run-errors %d)", errs, opts.MaxRunErrors)
+			fmt.Fprintf(os.Stderr, "[error-budget] %s; stopping the run\n", out.Aborted)
+			notifyAlert(ctx, p.notifier, fmt.Sprintf("🚨 run aborted: %s. The last error: %s", out.Aborted, out.Results[len(out.Results)-1].Reason))
+			break
+		}
+	}
+	// Selected PRs the loop didn't get to.
+	for _, pr := range selected[processed:] {
+		reason := filterLimit
+		if out.Aborted != "" {
+			reason = filterRunAborted
+		}
+		out.Filtered = append(out.Filtered, newFilteredPR(pr, reason))
+	}
 
+	if cpPath != "" {
+		removeCheckpoint(cpPath)
+	}
+	if !opts.DryRun {
+		saveBreaker(hist, p.breaker, resolveStatePath(opts.StateFile), p.now())
+		if p.repoBreaker != nil {
+			saveBreaker(hist, p.repoBreaker, resolveStatePath(opts.StateFile), p.now())
+		}
+	}
+	if run.decisions != nil && !opts.DryRun {
+		saveDecisions(decisionsPath(resolveStatePath(opts.StateFile)), run.decisions)
+	}
+	if !opts.DryRun && hist == nil {
+		run.repoActions.record(out.Results, now)
+		saveRepoActions(repoActionsPath(resolveStatePath(opts.StateFile)), run.repoActions)
+	}
+	if opts.ReportCheckRun && !opts.DryRun {
+		reportPipelineChecks(ctx, out.Results, p.now())
+	}
+	if opts.GroupMajorBumps && !opts.DryRun {
+		alertDependencyBumps(ctx, p.notifier, dependencyAlertsPath(resolveStatePath(opts.StateFile)), out.Results)
+	}
+	if opts.EscalateAfter > 0 && !opts.DryRun {
+		escalateBlockedPRs(ctx, p.notifier, opts, out.Results, p.now())
+	}
+	if opts.FindingsFile != "" {
+		if err := writeFindings(opts.FindingsFile, run.findings); err != nil {
+			fmt.Fprintf(os.Stderr, "[findings] failed to write %s: %v\n", opts.FindingsFile, err)
+		}
+	}
+	if len(opts.orgs) > 1 {
+		out.Orgs = totalsByOrg(opts.orgs, out.Results)
+	}
+
+	return out, nil
+}
+
+// countErrors counts the error outcomes.
+func countErrors(results []prOutcome) int {
+	n := 0
+	for _, r := range results {
+		if r.Action == "error" {
+			n++
+		}
+	}
+	return n
+}
+
+// processRepoPR is processPRWithTimeout behind the repo circuit breaker:
+// PRs in a repo whose circuit is open are skipped, and each PR's result
+// feeds the repo's failure streak (skips don't count either way).
+func (p *Pipeline) processRepoPR(ctx context.Context, run *pipelineRun, results []prOutcome, pr searchPR) prOutcome {
+	if p.repoBreaker == nil {
+		return p.processPRWithTimeout(ctx, run, results, pr)
+	}
+	repo := pr.Repository.NameWithOwner
+	open, checked := run.repoOpen[repo]
+	if !checked {
+		open = p.repoBreaker.IsOpen(repo)
+		run.repoOpen[repo] = open
+	}
+	if open {
 		outcome := prOutcome{
 			URL:    pr.URL,
-			Repo:   pr.Repository.NameWithOwner,
+			Repo:   repo,
 			Number: pr.Number,
 			Author: pr.Author.Login,
+			Action: "skipped",
+		}
+		outcome.setReason(reasonRepoCircuitBreaker, "")
+		return outcome
+	}
+	outcome := p.processPRWithTimeout(ctx, run, results, pr)
+	switch outcome.Action {
+	case "error":
+		if p.repoBreaker.RecordFailure(repo) {
+			run.repoOpen[repo] = true
+		}
+	case "skipped":
+	default:
+		p.repoBreaker.RecordSuccess(repo)
+	}
+	return outcome
+}
+
+// processPRWithTimeout is processPR under --pr-timeout. A PR that fails
+// because it ran out of time is reported as a timeout and counts against
+// its circuit breaker; the run's own deadline is left to processPR.
+func (p *Pipeline) processPRWithTimeout(ctx context.Context, run *pipelineRun, results []prOutcome, pr searchPR) prOutcome {
+	if p.opts.PRTimeout <= 0 {
+		return p.processPR(ctx, run, results, pr)
+	}
+	prCtx, cancel := context.WithTimeout(ctx, p.opts.PRTimeout)
+	defer cancel()
+	outcome := p.processPR(prCtx, run, results, pr)
+	if outcome.Action == "error" && prCtx.Err() != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "[pr-timeout] %s: %s\n", pr.URL, outcome.Reason)
+		outcome.setReason(reasonPRTimeout, fmt.Sprintf("no result within %s", p.opts.PRTimeout))
+		p.breaker.RecordFailure(pr.URL)
+	}
+	return outcome
+}
+
+// processPR decides what to do with one selected PR, does it, and returns
+// the outcome. results are the outcomes so far in this run.
+func (p *Pipeline) processPR(ctx context.Context, run *pipelineRun, results []prOutcome, pr searchPR) prOutcome {
+	opts, cb := p.opts, p.breaker
+	outcome := prOutcome{
+		URL:    pr.URL,
+		Repo:   pr.Repository.NameWithOwner,
+		Number: pr.Number,
+		Author: pr.Author.Login,
+	}
+	policy := opts.config.repoPolicyFor(pr.Repository.NameWithOwner)
+
+	if ctx.Err() != nil {
+		outcome.Action = "skipped"
+		outcome.setReason(reasonRunTimeout, "")
+		return outcome
+	}
+	if opts.operator != nil && opts.operator.quit {
+		outcome.Action = "skipped"
+		outcome.setReason(reasonOperatorQuit, "")
+		return outcome
+	}
+
+	if run.budget.Exhausted() {
+		outcome.Action = "skipped"
+		outcome.setReason(reasonRateLimitBudget, "")
+		return outcome
+	}
+
+	maxActions, perHour := opts.RepoMaxActions, opts.RepoActionsPerHour
+	if policy.MaxActions > 0 {
+		maxActions = policy.MaxActions
+	}
+	if policy.MaxActionsPerHour > 0 {
+		perHour = policy.MaxActionsPerHour
+	}
+	if maxActions > 0 && countRepoActions(results, outcome.Repo) >= maxActions {
+		outcome.Action = "skipped"
+		outcome.setReason(reasonRepoActionCap, "")
+		return outcome
+	}
+	// The hourly limit counts earlier runs' actions too, so a repo with a
+	// pile of bot PRs is worked through a few at a time.
+	if perHour > 0 && run.repoActions.recent(outcome.Repo)+countRepoActions(results, outcome.Repo) >= perHour {
+		outcome.Action = "skipped"
+		outcome.setReason(reasonRepoRateLimited, "")
+		return outcome
+	}
+
+	// Circuit breaker check: skip if this PR is in circuit-open state
+	if cb.IsOpen(pr.URL) {
+		outcome.Action = "skipped"
+		outcome.setReason(reasonCircuitBreaker, "")
+		return outcome
+	}
+
+	// Nothing has happened to the PR since the last run settled on why it
+	// can't merge, so it still can't; don't spend the calls to find out.
+	if blocker, ok := run.decisions.unchanged(pr); ok {
+		outcome.Action = "skipped"
+		outcome.setReason(reasonUnchangedSinceLastRun, blocker)
+		return outcome
+	}
+
+	view, viewErr := RetryableWithResult(ctx, func() (*prView, error) {
+		return ghPRView(ctx, pr.URL)
+	}, retryCfg)
+	if viewErr != nil {
+		if IsPermanent(viewErr) {
+			// Permanent errors - don't use circuit breaker, just skip with permanent flag
+			outcome.Action = "error"
+			outcome.setFailure(reasonPRViewFailed, "permanent", viewErr)
+		} else {
+			outcome.Action = "error"
+			outcome.setFailure(reasonPRViewFailed, "after retries", viewErr)
+			cb.RecordFailure(pr.URL)
+		}
+		return outcome
+	}
+	if mergeableUnknown(view) {
+		view = awaitMergeable(ctx, view, func() (*prView, error) {
+			return RetryableWithResult(ctx, func() (*prView, error) {
+				return ghPRView(ctx, pr.URL)
+			}, retryCfg)
+		})
+	}
+	outcome.HeadSHA = view.HeadRefOid
+	ctx = withAuditPR(ctx, view.URL, view.HeadRefOid)
+	if opts.RequiredChecksOnly {
+		key := pr.Repository.NameWithOwner + "@" + view.BaseRefName
+		required, known := run.requiredChecks[key]
+		if !known {
+			var reqErr error
+			required, reqErr = RetryableWithResult(ctx, func() ([]string, error) {
+				return ghRequiredChecks(ctx, pr.Repository.NameWithOwner, view.BaseRefName)
+			}, retryCfg)
+			if reqErr != nil {
+				// Gate on every check, as if none were marked required.
+				fmt.Fprintf(os.Stderr, "[required-checks] lookup failed for %s: %v\n", key, reqErr)
+			}
+			run.requiredChecks[key] = required
+		}
+		view.StatusCheckRollup, view.OptionalChecks = splitRequiredChecks(view.StatusCheckRollup, required)
+		outcome.OptionalFailures = failingCheckNames(view.OptionalChecks)
+	}
+	outcome.ChecksState = overallChecksState(view.StatusCheckRollup)
+	outcome.Mergeable = strings.TrimSpace(view.Mergeable)
+	if decision := effectiveReviewDecision(view, opts.config); decision != view.ReviewDecision {
+		fmt.Fprintf(os.Stderr, "[reviews] %s: ignoring changes requested by %s; a human approved\n", pr.URL, strings.Join(ignoredChangeRequests(view, opts.config), ", "))
+		view.ReviewDecision = decision
+	}
+	outcome.ReviewDecision = strings.TrimSpace(view.ReviewDecision)
+	opts.explain.note("head", "%s (cross-repo %t)", view.HeadRefOid, view.IsCrossRepository)
+
+	// apply: refuse to act on a PR that moved since it was planned, and
+	// (at each action below) to take any action but the planned one.
+	if opts.plan != nil {
+		if step := opts.plan.step(pr.URL); step == nil || !step.matches(outcome) {
+			outcome.Action = "skipped"
+			outcome.setReason(reasonPlanStale, "")
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+	}
+
+	closeStale := isCloseStaleCandidate(pr.Author.Login, pr.UpdatedAt, opts.staleAuthors, opts.CloseStaleDays, run.now)
+
+	// Re-check hard stops at point-of-act.
+	if view.IsDraft && !closeStale && !promotableDraftAuthor(opts, pr.Author.Login) {
+		outcome.Action = "skipped"
+		outcome.setReason(reasonDraft, "")
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
+	if isDoNotTouch(opts.DoNotTouchLabel, view.Title, view.Body, view.Labels) {
+		outcome.Action = "skipped"
+		outcome.setReason(reasonDoNotTouch, "")
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
+
+	if closeStale {
+		if opts.DryRun {
+			outcome.Action = "skipped"
+			outcome.setDryRunReason(reasonClosedStale, "")
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		if opts.plan.refuses(&outcome, "close") {
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		closeErr := Retryable(ctx, func() error {
+			return ghPRClose(ctx, view.URL, buildStaleCloseComment(opts.CloseStaleDays))
+		}, retryCfg)
+		if closeErr != nil {
+			if IsArchivedError(closeErr) {
+				outcome.Action = "skipped"
+				outcome.setReason(reasonRepoArchived, "")
+			} else if IsPermanent(closeErr) {
+				outcome.Action = "error"
+				outcome.setFailure(reasonCloseFailed, "permanent", closeErr)
+			} else {
+				outcome.Action = "error"
+				outcome.setFailure(reasonCloseFailed, "after retries", closeErr)
+				cb.RecordFailure(pr.URL)
+			}
+			return outcome
+		}
+		outcome.Action = "closed_stale"
+		outcome.setReason(reasonUntouched, fmt.Sprintf("%dd", opts.CloseStaleDays))
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
+
+	// A bot's draft with every check green is ready for a human to look at.
+	if view.IsDraft {
+		if overallChecksState(append(slices.Clone(view.StatusCheckRollup), view.OptionalChecks...)) != "SUCCESS" {
+			outcome.Action = "skipped"
+			outcome.setReason(reasonDraft, "")
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		if opts.DryRun {
+			outcome.Action = "skipped"
+			outcome.setDryRunReason(reasonMarkedReady, "")
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		if opts.plan.refuses(&outcome, "mark_ready") {
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		readyErr := Retryable(ctx, func() error {
+			return ghPRReady(ctx, view.URL)
+		}, retryCfg)
+		if readyErr != nil {
+			if IsPermanent(readyErr) {
+				outcome.Action = "error"
+				outcome.setFailure(reasonMarkReadyFailed, "permanent", readyErr)
+			} else {
+				outcome.Action = "error"
+				outcome.setFailure(reasonMarkReadyFailed, "after retries", readyErr)
+				cb.RecordFailure(pr.URL)
+			}
+			return outcome
+		}
+		outcome.Action = "marked_ready"
+		outcome.setReason(reasonChecksSuccess, "")
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
+
+	// Already waiting in the merge queue; GitHub will merge it.
+	if strings.EqualFold(strings.TrimSpace(view.MergeStateStatus), "QUEUED") {
+		outcome.Action = "skipped"
+		outcome.setReason(reasonMergeQueued, "")
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
+
+	// Auto-merge is already on, usually turned on by a human: GitHub merges
+	// it with their chosen method once it's ready. Merging it ourselves
+	// would override that method, and commenting is noise.
+	if am := view.AutoMergeRequest; am != nil {
+		fmt.Fprintf(os.Stderr, "[auto-merge] %s: auto-merge (%s) enabled by %s; leaving the merge to GitHub\n",
+			view.URL, strings.ToLower(am.MergeMethod), am.EnabledBy.Login)
+		outcome.Action = "skipped"
+		outcome.setReason(reasonAutoMergePending, "")
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
+
+	if hasLabel(view.Labels, opts.AutoMergeLabel) {
+		// The label stands in for the approval; changes requested still block.
+		policy.RequireApproval = false
+	}
+	hold := hasLabel(view.Labels, opts.HoldLabel)
+	// Dependency bots: squash-merge patch and minor updates; anything else
+	// waits for a human.
+	var heldUpdate reasonCode
+	if opts.authors.policyFor(pr.Author.Login, pr.Author.IsBot).Mode == authorModeDependency {
+		policy.MergeMethod = "SQUASH"
+		outcome.DependencyUpdate = dependencyUpdateKind(view.Title)
+		if outcome.DependencyUpdate != dependencyPatch && outcome.DependencyUpdate != dependencyMinor {
+			heldUpdate = reasonDependencyUnknown
+			if outcome.DependencyUpdate == dependencyMajor {
+				heldUpdate = reasonDependencyMajor
+			}
+		}
+	}
+	in := &ruleInput{
+		ctx: ctx, opts: opts, pr: pr, view: view, policy: policy, outcome: &outcome,
+		now: p.now(), heldUpdate: heldUpdate,
+	}
+	chain := runMergeRules(in, mergeRulesNamed(opts.config.mergeRulesFor(pr.Repository.NameWithOwner), in))
+	if chain.err != nil {
+		// Don't merge past a gate we couldn't check.
+		outcome.Action = "error"
+		if IsPermanent(chain.err) {
+			outcome.setFailure(chain.failed.lookupFailed, "permanent", chain.err)
+		} else {
+			outcome.setFailure(chain.failed.lookupFailed, "after retries", chain.err)
+			cb.RecordFailure(pr.URL)
+		}
+		return outcome
+	}
+	mergeOK, mergeCode, mergeDetail := chain.ok, chain.code, chain.detail
+	// mergeReason is the blocker as Reason words it, for comments.
+	mergeReason := reasonText(mergeCode, mergeDetail)
+	if opts.operator != nil {
+		choice := opts.operator.choose(ctx, view, outcome, mergeOK, mergeReason)
+		switch choice.Action {
+		case operatorMerge:
+			mergeOK = true
+		case operatorSkip, operatorQuit:
+			outcome.Action = "skipped"
+			outcome.setReason(reasonOperatorSkip, "")
+			if choice.Action == operatorQuit {
+				outcome.setReason(reasonOperatorQuit, "")
+			}
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		case operatorComment:
+			if opts.DryRun {
+				outcome.Action = "skipped"
+				outcome.setDryRunReason(reasonOperatorComment, "")
+			} else if opts.plan.refuses(&outcome, "comment") {
+				cb.RecordSuccess(pr.URL)
+				return outcome
+			} else if commentErr := Retryable(ctx, func() error {
+				return ghPRComment(ctx, view.URL, choice.Comment)
+			}, retryCfg); commentErr != nil {
+				outcome.Action = "error"
+				outcome.setFailure(reasonOperatorCommentFailed, "", commentErr)
+			} else {
+				outcome.Action = "commented"
+				outcome.setReason(reasonOperatorComment, "")
+			}
+			if outcome.Action != "error" {
+				cb.RecordSuccess(pr.URL)
+			}
+			return outcome
+		}
+	}
+	// Outside the merge window there's nothing wrong to tell the author
+	// about; a run inside the window merges it.
+	if !mergeOK && mergeCode == reasonOutsideMergeWindow {
+		outcome.Action = "skipped"
+		outcome.setReason(mergeCode, mergeDetail)
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
+	// Held dependency updates go into the run's grouped alert instead.
+	if !mergeOK && mergeCode == heldUpdate && opts.GroupMajorBumps {
+		outcome.Action = "skipped"
+		outcome.setReason(mergeCode, mergeDetail)
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
+	if mergeOK {
+		if opts.DryRun {
+			outcome.Action = "skipped"
+			outcome.setDryRunReason(reasonMergeable, "")
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		if opts.plan.refuses(&outcome, "merge") {
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+
+		queueKey := pr.Repository.NameWithOwner + "@" + view.BaseRefName
+		queued, known := run.mergeQueues[queueKey]
+		if !known {
+			var queueErr error
+			queued, queueErr = RetryableWithResult(ctx, func() (bool, error) {
+				return ghMergeQueueEnabled(ctx, pr.Repository.NameWithOwner, view.BaseRefName)
+			}, retryCfg)
+			if queueErr != nil {
+				// Fall back to a direct merge; a queue-protected branch rejects it below.
+				fmt.Fprintf(os.Stderr, "[merge-queue] lookup failed for %s: %v\n", queueKey, queueErr)
+			}
+			run.mergeQueues[queueKey] = queued
+		}
+
+		// A merge an earlier run started and never saw finish may have
+		// gone through; GitHub's search can lag, so ask the PR itself.
+		if run.intents.unfinished(view.URL, view.HeadRefOid, intentMerge) {
+			state, stateErr := ghPRState(ctx, view.URL)
+			if stateErr == nil && strings.EqualFold(state, "MERGED") {
+				fmt.Fprintf(os.Stderr, "[intent] %s: an earlier run's merge went through\n", view.URL)
+				run.intents.finish(view.URL, view.HeadRefOid, intentMerge)
+				outcome.Action = "merged"
+				outcome.setReason(reasonIntentConfirmed, "")
+				cb.RecordSuccess(pr.URL)
+				return outcome
+			}
+			fmt.Fprintf(os.Stderr, "[intent] %s: an earlier run's merge didn't go through; merging again\n", view.URL)
+		}
+		run.intents.start(view.URL, view.HeadRefOid, intentMerge, p.now())
+		defer run.intents.finish(view.URL, view.HeadRefOid, intentMerge)
+
+		var oid string
+		var mergeErr error
+		if !queued {
+			oid, mergeErr = RetryableWithResult(ctx, func() (string, error) {
+				return ghMergePR(ctx, view.ID, policy.mergeMethod(), view.HeadRefOid)
+			}, retryCfg)
+			// A branch can require the queue even if the lookup missed it.
+			if mergeErr != nil && isMergeQueueRequiredError(mergeErr) {
+				queued = true
+				run.mergeQueues[queueKey] = true
+			}
+		}
+		if queued {
+			position, enqueueErr := RetryableWithResult(ctx, func() (int, error) {
+				return ghEnqueuePR(ctx, view.ID)
+			}, retryCfg)
+			if enqueueErr != nil {
+				if IsPermanent(enqueueErr) {
+					outcome.Action = "error"
+					outcome.setFailure(reasonEnqueueFailed, "permanent", enqueueErr)
+				} else {
+					outcome.Action = "error"
+					outcome.setFailure(reasonEnqueueFailed, "after retries", enqueueErr)
+					cb.RecordFailure(pr.URL)
+				}
+				return outcome
+			}
+			outcome.Action = "enqueued"
+			outcome.setReason(reasonMergeQueuePosition, strconv.Itoa(position))
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		if isHeadMovedError(mergeErr) {
+			// Pushed to since it was viewed: what was checked isn't what
+			// would merge. The next run looks at the new head.
+			fmt.Fprintf(os.Stderr, "[merge] %s: head moved past %s; deferring to the next run\n", view.URL, view.HeadRefOid)
+			outcome.Action = "skipped"
+			outcome.setReason(reasonHeadMoved, "")
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		if mergeErr != nil {
+			if IsPermanent(mergeErr) {
+				outcome.Action = "error"
+				outcome.setFailure(reasonMergeFailed, "permanent", mergeErr)
+			} else {
+				outcome.Action = "error"
+				outcome.setFailure(reasonMergeFailed, "after retries", mergeErr)
+				cb.RecordFailure(pr.URL)
+			}
+			return outcome
+		}
+		outcome.Action = "merged"
+		outcome.MergeCommitOID = oid
+		if !view.CreatedAt.IsZero() {
+			outcome.TimeToMergeSeconds = int64(p.now().Sub(view.CreatedAt).Seconds())
+		}
+		if opts.DeleteBranchOnMerge {
+			outcome.BranchDeletion = deleteMergedBranch(ctx, pr.Repository.NameWithOwner, view)
+		}
+		if opts.CloseLinkedIssues {
+			outcome.LinkedIssues, outcome.ClosedIssues = closeLinkedIssues(ctx, pr.Repository.NameWithOwner, view)
+		}
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
+
+	// Approved and mergeable, only waiting on CI: let GitHub merge it when green.
+	if opts.EnableAutoMerge && autoMergeCandidate(view, policy, mergeCode) && !chain.autoMergeBlocked && !forkRestricted(opts, view) {
+		if opts.DryRun {
+			outcome.Action = "skipped"
+			outcome.setDryRunReason(reasonAutoMerge, "")
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		if opts.plan.refuses(&outcome, "enable_auto_merge") {
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		autoErr := Retryable(ctx, func() error {
+			return ghEnableAutoMerge(ctx, view.ID, policy.mergeMethod())
+		}, retryCfg)
+		if autoErr == nil {
+			outcome.Action = "auto_merge_enabled"
+			outcome.setReason(mergeCode, mergeDetail)
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		if !isAutoMergeUnavailableError(autoErr) {
+			outcome.Action = "error"
+			if IsPermanent(autoErr) {
+				outcome.setFailure(reasonEnableAutoMergeFailed, "permanent", autoErr)
+			} else {
+				outcome.setFailure(reasonEnableAutoMergeFailed, "after retries", autoErr)
+				cb.RecordFailure(pr.URL)
+			}
+			return outcome
 		}
+		// Repo doesn't allow auto-merge; fall back to the pending-checks comment.
+		fmt.Fprintf(os.Stderr, "[auto-merge] %s: %v\n", view.URL, autoErr)
+	}
 
-		// Circuit breaker check: skip if this PR is in circuit-open state
-		if cb.IsOpen(pr.URL) {
+	// Otherwise mergeable but out of date with a strict base: merge the base
+	// in; a later run merges once checks pass on the new head.
+	if mergeCode == reasonBranchBehind && !hold && !forkRestricted(opts, view) {
+		if opts.DryRun {
 			outcome.Action = "skipped"
-			outcome.Reason = "circuit_breaker"
-			out.Results = append(out.Results, outcome)
-			continue
+			outcome.setDryRunReason(mergeCode, mergeDetail)
+			cb.RecordSuccess(pr.URL)
+			return outcome
 		}
-
-		view, viewErr := RetryableWithResult(func() (*prView, error) {
-			return ghPRView(pr.URL)
+		if opts.plan.refuses(&outcome, "update_branch") {
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		updateErr := Retryable(ctx, func() error {
+			return ghPRUpdateBranch(ctx, view.URL)
 		}, retryCfg)
-		if viewErr != nil {
-			if IsPermanent(viewErr) {
-				// Permanent errors - don't use circuit breaker, just skip with permanent flag
+		if updateErr != nil {
+			if IsPermanent(updateErr) {
 				outcome.Action = "error"
-				outcome.Reason = "pr view failed (permanent): " + viewErr.Error()
+				outcome.setFailure(reasonUpdateBranchFailed, "permanent", updateErr)
 			} else {
 				outcome.Action = "error"
-				outcome.Reason = "pr view failed (after retries): " + viewErr.Error()
+				outcome.setFailure(reasonUpdateBranchFailed, "after retries", updateErr)
 				cb.RecordFailure(pr.URL)
 			}
-			out.Results = append(out.Results, outcome)
-			continue
+			return outcome
 		}
-		outcome.ChecksState = overallChecksState(view.StatusCheckRollup)
-		outcome.Mergeable = strings.TrimSpace(view.Mergeable)
-		outcome.ReviewDecision = strings.TrimSpace(view.ReviewDecision)
+		outcome.Action = "branch_updated"
+		outcome.setReason(mergeCode, mergeDetail)
+		cb.RecordSuccess(pr.URL)
+		return p.recheckAfterUpdate(ctx, run, results, pr, outcome, view.HeadRefOid)
+	}
 
-		// Re-check hard stops at point-of-act.
-		if view.IsDraft {
+	// Handle CONFLICTING mergeable state: try auto-update, then post dedup'd comment.
+	if mergeCode == reasonMergeableConflicting {
+		if opts.DryRun {
 			outcome.Action = "skipped"
-			outcome.Reason = "draft"
-			out.Results = append(out.Results, outcome)
+			outcome.setDryRunReason(mergeCode, mergeDetail)
 			cb.RecordSuccess(pr.URL)
-			continue
+			return outcome
+		}
+		if opts.plan.refuses(&outcome, "resolve_conflict") {
+			cb.RecordSuccess(pr.URL)
+			return outcome
 		}
-		if isDoNotTouch(*doNotTouchLabel, view.Title, view.Body, view.Labels) {
+
+		// Check for an existing conflict comment BEFORE calling update-branch.
+		// This avoids a redundant update-branch call on every pipeline loop once
+		// we've already flagged the conflict and are awaiting manual resolution.
+		comments, commentsErr := RetryableWithResult(ctx, func() ([]issueComment, error) {
+			return ghIssueComments(ctx, pr.Repository.NameWithOwner, pr.Number)
+		}, retryCfg)
+		if commentsErr == nil && hasConflictComment(commentBodies(comments)) {
 			outcome.Action = "skipped"
-			outcome.Reason = "do_not_touch"
-			out.Results = append(out.Results, outcome)
+			outcome.setAlreadyCommentedReason(mergeCode, mergeDetail)
 			cb.RecordSuccess(pr.URL)
-			continue
+			return outcome
 		}
 
-		mergeOK, mergeReason := mergeAllowed(view)
-		if mergeOK {
-			if *dryRun {
-				outcome.Action = "skipped"
-				outcome.Reason = "dry_run_mergeable"
-				out.Results = append(out.Results, outcome)
+		// No existing conflict comment — attempt to auto-resolve by merging base into PR branch.
+		if forkRestricted(opts, view) {
+			fmt.Fprintf(os.Stderr, "[fork] %s: head is on a fork, not updating it (--fork-policy %s)\n", view.URL, opts.ForkPolicy)
+		} else if hold {
+			fmt.Fprintf(os.Stderr, "[hold] %s: labeled %q, not updating the branch\n", view.URL, opts.HoldLabel)
+		} else if updateErr := ghPRUpdateBranch(ctx, view.URL); updateErr == nil {
+			// Success! Branch updated, conflicts may be resolved.
+			outcome.Action = "conflict_resolved"
+			outcome.setReason(mergeCode, mergeDetail)
+			cb.RecordSuccess(pr.URL)
+			return p.recheckAfterUpdate(ctx, run, results, pr, outcome, view.HeadRefOid)
+		}
+
+		// Merge-in failed; a rebase can still apply cleanly (e.g. when the
+		// conflict is with commits the branch already contains).
+		if opts.AttemptRebase && !hold && !forkRestricted(opts, view) {
+			if view.IsCrossRepository {
+				fmt.Fprintf(os.Stderr, "[rebase] %s: head is on a fork, not rebasing\n", view.URL)
+			} else if remote, ok := repoCloneURL(view.URL); !ok {
+				fmt.Fprintf(os.Stderr, "[rebase] %s: not on a known GitHub host, not rebasing\n", view.URL)
+			} else if rebaseErr := rebasePRBranch(ctx, remote, view.BaseRefName, view.HeadRefName); rebaseErr != nil {
+				fmt.Fprintf(os.Stderr, "[rebase] %s: %v\n", view.URL, rebaseErr)
+			} else {
+				outcome.Action = "rebased"
+				outcome.setReason(mergeCode, mergeDetail)
 				cb.RecordSuccess(pr.URL)
-				continue
+				return outcome
 			}
+		}
 
-			oid, mergeErr := RetryableWithResult(func() (string, error) {
-				return ghMergePR(view.ID)
-			}, retryCfg)
-			if mergeErr != nil {
-				if IsPermanent(mergeErr) {
-					outcome.Action = "error"
-					outcome.Reason = "merge failed (permanent): " + mergeErr.Error()
-				} else {
-					outcome.Action = "error"
-					outcome.Reason = "merge failed (after retries): " + mergeErr.Error()
-					cb.RecordFailure(pr.URL)
-				}
-				out.Results = append(out.Results, outcome)
-				continue
+		// Update failed — post a conflict comment.
+		intent := intentComment + ":" + mergeReason
+		if commentsErr != nil && run.intents.unfinished(view.URL, view.HeadRefOid, intent) {
+			fmt.Fprintf(os.Stderr, "[intent] %s: an earlier run may have commented, and the comments can't be checked (%v); skipping\n", view.URL, commentsErr)
+			outcome.Action = "skipped"
+			outcome.setReason(reasonIntentUnconfirmed, "")
+			return outcome
+		}
+		commentBody := opts.comments.body(view, mergeReason, opts.config.commentToneFor(pr.Repository.NameWithOwner))
+		sticky := findStickyComment(comments)
+		run.intents.start(view.URL, view.HeadRefOid, intent, p.now())
+		commentErr := Retryable(ctx, func() error {
+			return upsertStickyComment(ctx, view.URL, pr.Repository.NameWithOwner, sticky, commentBody, p.now())
+		}, retryCfg)
+		run.intents.finish(view.URL, view.HeadRefOid, intent)
+		if commentErr != nil {
+			if IsArchivedError(commentErr) {
+				outcome.Action = "skipped"
+				outcome.setReason(reasonRepoArchived, "")
+			} else if IsPermanent(commentErr) {
+				outcome.Action = "error"
+				outcome.setFailure(reasonConflictCommentFailed, "permanent", commentErr)
+			} else {
+				outcome.Action = "error"
+				outcome.setFailure(reasonConflictCommentFailed, "after retries", commentErr)
+				cb.RecordFailure(pr.URL)
 			}
-			outcome.Action = "merged"
-			outcome.MergeCommitOID = oid
-			out.Results = append(out.Results, outcome)
+		} else {
+			outcome.Action = "commented"
+			outcome.setReason(mergeCode, mergeDetail)
 			cb.RecordSuccess(pr.URL)
-			continue
+			notifyAuthor(ctx, opts, pr.Author.Login, fmt.Sprintf("⚠️ PR %s has a merge conflict with the base branch. Action needed: resolve the conflicts and push.", view.URL))
 		}
+		return outcome
+	}
 
-		// Handle CONFLICTING mergeable state: try auto-update, then post dedup'd comment.
-		if mergeReason == "mergeable_conflicting" {
-			if *dryRun {
-				outcome.Action = "skipped"
-				outcome.Reason = "dry_run_" + mergeReason
-				out.Results = append(out.Results, outcome)
-				cb.RecordSuccess(pr.URL)
-				continue
+	// Flaky failures (cancelled/timed out jobs) get a re-run rather than a comment.
+	var rerunIDs []string
+	if mergeCode == reasonChecksFailure && opts.RerunFlaky {
+		rerunIDs = flakyRerunRunIDs(view.StatusCheckRollup, opts.flakyRe)
+	}
+
+	// Check names didn't say what broke; look at annotations and logs.
+	var diag *ciDiagnosis
+	if strings.HasPrefix(string(mergeCode), "checks_") {
+		outcome.CIFailureType = classifyCIFailure(view.StatusCheckRollup)
+		if outcome.CIFailureType == "unknown" && mergeCode == reasonChecksFailure && len(rerunIDs) == 0 && opts.CILogLines > 0 {
+			diag = diagnoseCIFailure(ctx, pr.Repository.NameWithOwner, view.StatusCheckRollup, opts.CILogLines)
+			if diag != nil {
+				outcome.CIFailureType = diag.Category
+				run.findings = append(run.findings, ciFinding{Repo: pr.Repository.NameWithOwner, Number: pr.Number, URL: pr.URL, HeadSHA: view.HeadRefOid, Diag: *diag})
 			}
+		}
+	}
+
+	// Skip archived repos - they're read-only and can't accept comments.
+	// Uses batch-fetched archived repo set (fetched once at startup).
+	// If batch fetch failed (archivedRepos == nil), allow pipeline to continue.
+	repoName := pr.Repository.NameWithOwner
+	archived := false
+	if run.archivedRepos != nil {
+		archived = run.archivedRepos[repoName]
+		if opts.DryRun && archived {
+			fmt.Fprintf(os.Stderr, "[archived-repos] skipped %s (batch check)\n", repoName)
+		}
+	}
+	if archived {
+		outcome.Action = "skipped"
+		outcome.setReason(reasonRepoArchived, "")
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
 
-			// Check for an existing conflict comment BEFORE calling update-branch.
-			// This avoids a redundant update-branch call on every pipeline loop once
-			// we've already flagged the conflict and are awaiting manual resolution.
-			comments, commentsErr := ghPRComments(view.URL)
-			if commentsErr == nil && hasConflictComment(comments) {
+	// Changes were requested on an older head by a reviewer the config lets
+	// us dismiss: clear the stale review so the PR isn't stuck on it.
+	if mergeCode == reasonReviewChangesRequested && !hold {
+		if stale := staleChangeRequests(view, opts.config); len(stale) > 0 {
+			if opts.DryRun {
 				outcome.Action = "skipped"
-				outcome.Reason = mergeReason + "_already_commented"
-				out.Results = append(out.Results, outcome)
+				outcome.setDryRunReason(reasonReviewDismissed, "")
 				cb.RecordSuccess(pr.URL)
-				continue
+				return outcome
 			}
-
-			// No existing conflict comment — attempt to auto-resolve by merging base into PR branch.
-			updateErr := ghPRUpdateBranch(view.URL)
-			if updateErr == nil {
-				// Success! Branch updated, conflicts may be resolved.
-				outcome.Action = "conflict_resolved"
-				outcome.Reason = mergeReason
-				out.Results = append(out.Results, outcome)
+			if opts.plan.refuses(&outcome, "dismiss_review") {
 				cb.RecordSuccess(pr.URL)
-				continue
+				return outcome
 			}
-
-			// Update failed — post a conflict comment.
-			commentBody := buildCommentBody(view, mergeReason)
-			commentErr := Retryable(func() error {
-				return ghPRComment(view.URL, commentBody)
-			}, retryCfg)
-			if commentErr != nil {
-				if IsArchivedError(commentErr) {
-					outcome.Action = "skipped"
-					outcome.Reason = "repo_archived"
-				} else if IsPermanent(commentErr) {
-					outcome.Action = "error"
-					outcome.Reason = "conflict comment failed (permanent): " + commentErr.Error()
-				} else {
+			for _, r := range stale {
+				dismissErr := Retryable(ctx, func() error {
+					return ghDismissReview(ctx, r.ID, staleReviewMessage(r, view))
+				}, retryCfg)
+				if dismissErr != nil {
 					outcome.Action = "error"
-					outcome.Reason = "conflict comment failed (after retries): " + commentErr.Error()
-					cb.RecordFailure(pr.URL)
+					if IsPermanent(dismissErr) {
+						outcome.setFailure(reasonDismissReviewFailed, "permanent", dismissErr)
+					} else {
+						outcome.setFailure(reasonDismissReviewFailed, "after retries", dismissErr)
+						cb.RecordFailure(pr.URL)
+					}
+					return outcome
 				}
-			} else {
-				outcome.Action = "commented"
-				outcome.Reason = mergeReason
-				cb.RecordSuccess(pr.URL)
+				outcome.DismissedReviews = append(outcome.DismissedReviews, r.Author.Login)
 			}
-			out.Results = append(out.Results, outcome)
-			continue
+			outcome.Action = "review_dismissed"
+			outcome.setReason(mergeCode, mergeDetail)
+			cb.RecordSuccess(pr.URL)
+			return outcome
 		}
+	}
 
-		if strings.HasPrefix(mergeReason, "checks_") {
-			outcome.CIFailureType = classifyCIFailure(view.StatusCheckRollup)
-			if outcome.CIFailureType == "lint" && *discordAlertsTo != "" {
-				token := strings.TrimSpace(discordBotToken())
-				if token != "" {
-					alertsTo := normalizeDiscordTarget(*discordAlertsTo)
-					msg := fmt.Sprintf("🧹 Lint failure on PR %s (%s#%d). Dispatch lint-fix agent.", view.URL, pr.Repository.NameWithOwner, pr.Number)
-					if err := discordSendMessage(token, alertsTo, msg); err != nil {
-						fmt.Fprintf(os.Stderr, "lint alert send failed: %v\n", err)
-					}
-				}
+	// Blocked on review: ask someone for one rather than only commenting.
+	if opts.RequestReviews && mergeCode == reasonReviewRequired {
+		if len(view.ReviewRequests) > 0 {
+			outcome.Action = "skipped"
+			outcome.setReason(reasonReviewAlreadyRequested, "")
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		pool := policy.Reviewers
+		if len(pool) == 0 {
+			pool = opts.reviewerPool
+		}
+		reviewer, findErr := findReviewer(ctx, repoName, view, pr.Number, pool)
+		if findErr != nil {
+			fmt.Fprintf(os.Stderr, "[reviewers] %s: lookup failed: %v\n", view.URL, findErr)
+		}
+		if reviewer != "" {
+			if opts.DryRun {
+				outcome.Action = "skipped"
+				outcome.setDryRunReason(reasonReviewRequested, "")
+				outcome.Reviewer = reviewer
+				cb.RecordSuccess(pr.URL)
+				return outcome
+			}
+			if opts.plan.refuses(&outcome, "request_review") {
+				cb.RecordSuccess(pr.URL)
+				return outcome
+			}
+			reqErr := Retryable(ctx, func() error {
+				return ghRequestReview(ctx, view.URL, reviewer)
+			}, retryCfg)
+			if reqErr == nil {
+				outcome.Action = "review_requested"
+				outcome.setReason(mergeCode, mergeDetail)
+				outcome.Reviewer = reviewer
+				cb.RecordSuccess(pr.URL)
+				return outcome
 			}
+			// Couldn't request (e.g. reviewer lacks access); comment instead.
+			fmt.Fprintf(os.Stderr, "[reviewers] %s: requesting %s failed: %v\n", view.URL, reviewer, reqErr)
 		}
+	}
 
-		// Skip archived repos - they're read-only and can't accept comments.
-		// Uses batch-fetched archived repo set (fetched once at startup).
-		// If batch fetch failed (archivedRepos == nil), allow pipeline to continue.
-		repoName := pr.Repository.NameWithOwner
-		archived := false
-		if archivedRepos != nil {
-			archived = archivedRepos[repoName]
-			if *dryRun && archived {
-				fmt.Fprintf(os.Stderr, "[archived-repos] skipped %s (batch check)\n", repoName)
+	// Workflows waiting on approval: approve them for a trusted author
+	// rather than asking a maintainer to.
+	if mergeCode == reasonWorkflowsAwaiting && trustedWorkflowAuthor(opts, pr.Author.Login) {
+		if opts.DryRun {
+			outcome.Action = "skipped"
+			outcome.setDryRunReason(reasonWorkflowsApproved, "")
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		if opts.plan.refuses(&outcome, "approve_workflows") {
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		approved, approveErr := approveWorkflowRuns(ctx, repoName, view.HeadRefOid)
+		if approveErr != nil {
+			outcome.Action = "error"
+			if IsPermanent(approveErr) {
+				outcome.setFailure(reasonApproveWorkflowsFailed, "permanent", approveErr)
+			} else {
+				outcome.setFailure(reasonApproveWorkflowsFailed, "after retries", approveErr)
+				cb.RecordFailure(pr.URL)
 			}
+			return outcome
 		}
-		if archived {
-			outcome.Action = "skipped"
-			outcome.Reason = "repo_archived"
-			out.Results = append(out.Results, outcome)
+		if approved > 0 {
+			outcome.Action = "workflows_approved"
+			outcome.setReason(mergeCode, mergeDetail)
 			cb.RecordSuccess(pr.URL)
-			continue
+			return outcome
 		}
+		// Nothing left to approve (someone beat us to it); comment.
+	}
 
-		// Not mergeable: comment a bounded next action so this run is still end-to-end.
-		if *dryRun {
+	if len(rerunIDs) > 0 {
+		if opts.DryRun {
 			outcome.Action = "skipped"
-			outcome.Reason = "dry_run_" + mergeReason
-			out.Results = append(out.Results, outcome)
+			outcome.setDryRunReason(reasonCIRerun, "")
 			cb.RecordSuccess(pr.URL)
-			continue
+			return outcome
 		}
-
-		commentBody := buildCommentBody(view, mergeReason)
-		commentErr := Retryable(func() error {
-			return ghPRComment(view.URL, commentBody)
-		}, retryCfg)
-		if commentErr != nil {
-			if IsArchivedError(commentErr) {
-				// Defense-in-depth: batch pre-check missed this (e.g. batch fetch failed).
-				// Downgrade to a skip rather than an error so it doesn't page.
-				outcome.Action = "skipped"
-				outcome.Reason = "repo_archived"
-				fmt.Fprintf(os.Stderr, "[archived-repos] comment fallback detected archived repo %s: %v\n", repoName, commentErr)
-			} else if IsPermanent(commentErr) {
-				outcome.Action = "error"
-				outcome.Reason = "comment failed (permanent): " + commentErr.Error()
-			} else {
-				outcome.Action = "error"
-				outcome.Reason = "comment failed (after retries): " + commentErr.Error()
+		if opts.plan.refuses(&outcome, "rerun_ci") {
+			cb.RecordSuccess(pr.URL)
+			return outcome
+		}
+		rerun, rerunErr := rerunFlakyRuns(ctx, repoName, rerunIDs, opts.RerunMaxAttempts)
+		if rerunErr != nil {
+			outcome.Action = "error"
+			outcome.setFailure(reasonCIRerunFailed, "", rerunErr)
+			if !IsPermanent(rerunErr) {
 				cb.RecordFailure(pr.URL)
 			}
-		} else {
-			outcome.Reason = mergeReason
-			if outcome.CIFailureType == "lint" {
-				outcome.Action = "lint_dispatched"
-			} else {
-				outcome.Action = "commented"
-			}
-			if mergeReason == "review_changes_requested" {
-				comments, err := ghPRReviewComments(view.URL)
-				if err == nil {
-					outcome.ReviewComments = comments
-					if *discordAlertsTo != "" && comments != "" {
-						token := strings.TrimSpace(discordBotToken())
-						if token != "" {
-							alertsTo := normalizeDiscordTarget(*discordAlertsTo)
-							msg := fmt.Sprintf("🔧 PR %s has changes requested. Review comments:\n%s\nAction needed: address review feedback.", view.URL, comments)
-							_ = discordSendMessage(token, alertsTo, msg)
-						}
-					}
-				}
-				outcome.Action = "review_dispatched"
-			}
+			return outcome
 		}
-		out.Results = append(out.Results, outcome)
-		if commentErr == nil {
+		if rerun > 0 {
+			outcome.Action = "ci_rerun"
+			outcome.setReason(mergeCode, mergeDetail)
 			cb.RecordSuccess(pr.URL)
+			return outcome
 		}
+		// Every run already hit the attempt cap; fall through and comment.
 	}
 
-	// Post run summary + alerts if configured.
-	// First, check if we should skip due to deduplication.
-	statePath := resolveStatePath(*stateFile)
-	currentHash := hashResults(out.Results)
-	shouldPost, skipReason := shouldPostToDiscord(statePath, currentHash)
+	// Not mergeable: comment a bounded next action so this run is still end-to-end.
+	if opts.DryRun {
+		outcome.Action = "skipped"
+		outcome.setDryRunReason(mergeCode, mergeDetail)
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
+	if opts.plan.refuses(&outcome, "comment") {
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
 
-	if !shouldPost {
-		fmt.Fprintf(os.Stderr, "[dedup] skipping Discord post: %s\n", skipReason)
+	// Only update the status comment when the blocker changed since it
+	// was last written.
+	comments, commentsErr := RetryableWithResult(ctx, func() ([]issueComment, error) {
+		return ghIssueComments(ctx, repoName, pr.Number)
+	}, retryCfg)
+	sticky := findStickyComment(comments)
+	if commentsErr == nil && sticky != nil && hasNotMergedComment([]string{sticky.Body}, mergeReason, overallChecksState(view.StatusCheckRollup)) {
+		outcome.Action = "skipped"
+		outcome.setAlreadyCommentedReason(mergeCode, mergeDetail)
+		cb.RecordSuccess(pr.URL)
+		return outcome
+	}
+
+	// A comment an earlier run started and never saw finish may have been
+	// posted. The sticky comment check above settles that, unless the
+	// comments couldn't be fetched; then skip rather than risk posting it
+	// twice.
+	intent := intentComment + ":" + mergeReason
+	if commentsErr != nil && run.intents.unfinished(view.URL, view.HeadRefOid, intent) {
+		fmt.Fprintf(os.Stderr, "[intent] %s: an earlier run may have commented, and the comments can't be checked (%v); skipping\n", view.URL, commentsErr)
+		outcome.Action = "skipped"
+		outcome.setReason(reasonIntentUnconfirmed, "")
+		return outcome
+	}
+	commentBody := opts.comments.body(view, mergeReason, opts.config.commentToneFor(pr.Repository.NameWithOwner))
+	if diag != nil {
+		commentBody += "\n" + diag.commentSection()
+	}
+	run.intents.start(view.URL, view.HeadRefOid, intent, p.now())
+	commentErr := Retryable(ctx, func() error {
+		return upsertStickyComment(ctx, view.URL, repoName, sticky, commentBody, p.now())
+	}, retryCfg)
+	run.intents.finish(view.URL, view.HeadRefOid, intent)
+	if commentErr != nil {
+		if IsArchivedError(commentErr) {
+			// Defense-in-depth: batch pre-check missed this (e.g. batch fetch failed).
+			// Downgrade to a skip rather than an error so it doesn't page.
+			outcome.Action = "skipped"
+			outcome.setReason(reasonRepoArchived, "")
+			fmt.Fprintf(os.Stderr, "[archived-repos] comment fallback detected archived repo %s: %v\n", repoName, commentErr)
+		} else if IsPermanent(commentErr) {
+			outcome.Action = "error"
+			outcome.setFailure(reasonCommentFailed, "permanent", commentErr)
+		} else {
+			outcome.Action = "error"
+			outcome.setFailure(reasonCommentFailed, "after retries", commentErr)
+			cb.RecordFailure(pr.URL)
+		}
 	} else {
-		if err := maybePostDiscord(out, *discordReportTo, *discordAlertsTo, *postEmpty, *postDryRun); err != nil {
-			out.Ok = false
-			out.Error = err.Error()
-			emitJSON(out)
-			os.Exit(1)
+		outcome.setReason(mergeCode, mergeDetail)
+		switch outcome.CIFailureType {
+		case "lint":
+			outcome.Action = "lint_dispatched"
+			outcome.DispatchEvent = sendFixDispatch(ctx, opts.LintDispatchEvent, view, pr.Repository.NameWithOwner, pr.Number, outcome.CIFailureType)
+			notifyAlert(ctx, p.notifier, fmt.Sprintf("🧹 Lint failure on PR %s (%s#%d). Dispatch lint-fix agent.", view.URL, repoName, pr.Number))
+		case "test":
+			outcome.Action = "test_dispatched"
+			outcome.DispatchEvent = sendFixDispatch(ctx, opts.TestDispatchEvent, view, pr.Repository.NameWithOwner, pr.Number, outcome.CIFailureType)
+			notifyAlert(ctx, p.notifier, fmt.Sprintf("🧪 Test failure on PR %s (%s#%d). Failing jobs: %s. Dispatch test-fix agent.",
+				view.URL, repoName, pr.Number, strings.Join(failingCheckNames(view.StatusCheckRollup), ", ")))
+		default:
+			outcome.Action = "commented"
 		}
-		// Update state file after successful post
-		if err := saveState(statePath, currentHash); err != nil {
-			fmt.Fprintf(os.Stderr, "[dedup] failed to save state: %v\n", err)
-			// Don't fail the run, just log
+		if mergeCode == reasonPossibleSecret {
+			notifyAlert(ctx, p.notifier, fmt.Sprintf("🔐 Possible secret in PR %s (%s#%d), not merging: %s",
+				view.URL, repoName, pr.Number, strings.Join(view.SecretFindings, ", ")))
+		}
+		if mergeCode == reasonReviewChangesRequested {
+			comments, err := ghPRReviewComments(ctx, view.URL)
+			if err == nil {
+				outcome.ReviewComments = comments
+			}
+			// Inline threads usually hold the actionable feedback.
+			threads, threadsErr := ghUnresolvedReviewThreads(ctx, repoName, pr.Number)
+			if threadsErr != nil {
+				fmt.Fprintf(os.Stderr, "[review-threads] %s: %v\n", view.URL, threadsErr)
+			}
+			outcome.ReviewThreads = threads
+			if outcome.ReviewComments != "" || len(threads) > 0 {
+				msg := reviewAlertMessage(view.URL, outcome.ReviewComments, threads)
+				if opts.ReviewArtifact != "" {
+					artifact := newReviewArtifact(view, repoName, pr.Number, outcome.ReviewComments, threads, p.now())
+					if outcome.ReviewArtifact = saveReviewArtifact(ctx, opts.ReviewArtifact, artifact); outcome.ReviewArtifact != "" {
+						msg += "\nFull feedback: " + outcome.ReviewArtifact
+					}
+				}
+				notifyAuthor(ctx, opts, pr.Author.Login, msg)
+			}
+			outcome.Action = "review_dispatched"
 		}
 	}
-
-	emitJSON(out)
+	if commentErr == nil {
+		cb.RecordSuccess(pr.URL)
+	}
+	return outcome
 }
 
-func fatalJSON(err error) {
-	emitJSON(map[string]any{
-		"ok":    false,
-		"error": err.Error(),
-	})
-	os.Exit(1)
+func emitJSON(v any) {
+	writeJSON(os.Stdout, v)
 }
 
-func emitJSON(v any) {
-	enc := json.NewEncoder(os.Stdout)
+// writeJSON writes v as a line of JSON, with secrets redacted.
+func writeJSON(w io.Writer, v any) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
 	enc.SetEscapeHTML(false)
 	_ = enc.Encode(v)
+	_, _ = io.WriteString(w, redact(buf.String()))
 }
 
-func maybePostDiscord(out runOutput, reportToRaw string, alertsToRaw string, postEmpty bool, postDryRun bool) error {
-	reportTo := normalizeDiscordTarget(reportToRaw)
-	alertsTo := normalizeDiscordTarget(alertsToRaw)
-	if reportTo == "" && alertsTo == "" {
-		return nil
-	}
-	if out.DryRun && !postDryRun {
-		return nil
-	}
-	if len(out.Results) == 0 && !postEmpty {
+// maybePostDiscord posts a run to Discord alone, for the report subcommand.
+func maybePostDiscord(ctx context.Context, out runOutput, reportToRaw string, alertsToRaw string, postEmpty bool, postDryRun bool) error {
+	n, _ := newDiscordNotifier(&runOptions{DiscordReportTo: reportToRaw, DiscordAlertsTo: alertsToRaw})
+	if n == nil || !shouldReport(out, postEmpty, postDryRun) {
 		return nil
 	}
-
-	token := strings.TrimSpace(discordBotToken())
-	if token == "" {
-		return errors.New("DISCORD_BOT_TOKEN missing (needed for Discord posting)")
-	}
-
-	merged, commented, skipped, errs := summarize(out.Results)
-	summary := renderDiscordSummary(out, merged, commented, skipped, errs)
-
-	var postErr error
-	if reportTo != "" {
-		postErr = discordSendMessage(token, reportTo, summary)
-	}
-	if postErr != nil {
-		// Best-effort alert.
-		if alertsTo != "" && alertsTo != reportTo {
-			_ = discordSendMessage(token, alertsTo, "PR pipeline: failed to post report: "+postErr.Error())
-		}
-		return postErr
-	}
-
-	// Separate alert ping on errors (avoid duplication if report already includes it in same channel).
-	if errs > 0 && alertsTo != "" && alertsTo != reportTo {
-		alert := renderDiscordAlert(out, errs)
-		if err := discordSendMessage(token, alertsTo, alert); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
-func postDiscordAlertIfConfigured(alertsToRaw string, msg string) {
-	alertsTo := normalizeDiscordTarget(alertsToRaw)
-	if alertsTo == "" {
-		return
-	}
-	token := strings.TrimSpace(discordBotToken())
-	if token == "" {
-		return
-	}
-	_ = discordSendMessage(token, alertsTo, "PR pipeline error: "+msg)
+	return n.Post(ctx, out)
 }
 
 func normalizeDiscordTarget(raw string) string {
@@ -647,12 +2291,36 @@ func normalizeDiscordTarget(raw string) string {
 	return strings.TrimSpace(s)
 }
 
+// countRepoActions counts PRs in repo that the pipeline acted on this run
+// (anything other than a skip or an error).
+func countRepoActions(results []prOutcome, repo string) int {
+	n := 0
+	for _, r := range results {
+		if r.Repo != repo || r.Action == "skipped" || r.Action == "error" {
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// countActions counts PRs the pipeline acted on this run across all repos.
+func countActions(results []prOutcome) int {
+	n := 0
+	for _, r := range results {
+		if r.Action != "skipped" && r.Action != "error" {
+			n++
+		}
+	}
+	return n
+}
+
 func summarize(results []prOutcome) (merged int, commented int, skipped int, errs int) {
 	for _, r := range results {
-		switch r.Action {
+		switch outcomeBucket(r.Action) {
 		case "merged":
 			merged++
-		case "commented", "review_dispatched", "lint_dispatched":
+		case "commented":
 			commented++
 		case "skipped":
 			skipped++
@@ -663,59 +2331,43 @@ func summarize(results []prOutcome) (merged int, commented int, skipped int, err
 	return
 }
 
-func renderDiscordSummary(out runOutput, merged int, commented int, skipped int, errs int) string {
-	lines := []string{
-		"PR pipeline run",
-		fmt.Sprintf("- startedAt: `%s`", out.StartedAt),
-		fmt.Sprintf("- org: `%s` | maxPRs: `%d` | staleHours(phaedrus-only): `%d` | dryRun: `%t`", out.Org, out.MaxPRs, out.StaleHours, out.DryRun),
-		fmt.Sprintf("- results: merged=`%d` commented=`%d` skipped=`%d` errors=`%d`", merged, commented, skipped, errs),
-	}
-	if len(out.Results) == 0 {
-		lines = append(lines, "", "No PRs selected.")
-		return strings.Join(lines, "\n")
-	}
-	lines = append(lines, "", "Per PR:")
-	for _, r := range out.Results {
-		suffix := ""
-		if r.Reason != "" {
-			suffix = " (" + r.Reason + ")"
-		}
-		if r.Action == "merged" && r.MergeCommitOID != "" {
-			suffix = suffix + " commit:" + r.MergeCommitOID
-		}
-		lines = append(lines, fmt.Sprintf("- %s %s%s", r.Action, r.URL, suffix))
-	}
-	msg := strings.Join(lines, "\n")
-	// Discord max is 2000 chars.
-	if len(msg) <= 1900 {
-		return msg
+// runBudget describes what bounded the run.
+func runBudget(out runOutput) string {
+	if out.MaxActions > 0 {
+		return fmt.Sprintf("maxActions: `%d`", out.MaxActions)
 	}
-	return msg[:1890] + "\n(truncated)"
+	return fmt.Sprintf("maxPRs: `%d`", out.MaxPRs)
 }
 
-func renderDiscordAlert(out runOutput, errs int) string {
-	lines := []string{
-		"PR pipeline: errors detected",
-		fmt.Sprintf("- startedAt: `%s`", out.StartedAt),
-		fmt.Sprintf("- errors: `%d`", errs),
-		"",
-		"Error PRs:",
-	}
-	for _, r := range out.Results {
-		if r.Action != "error" {
-			continue
-		}
-		reason := r.Reason
-		if reason == "" {
-			reason = "unknown"
+// orgTotals is one org's share of a multi-org run.
+type orgTotals struct {
+	Org       string `json:"org"`
+	Merged    int    `json:"merged"`
+	Commented int    `json:"commented"`
+	Skipped   int    `json:"skipped"`
+	Errors    int    `json:"errors"`
+}
+
+// totalsByOrg splits the run's results by org, in the order orgs were given.
+func totalsByOrg(orgs []string, results []prOutcome) []orgTotals {
+	totals := make([]orgTotals, 0, len(orgs))
+	for _, org := range orgs {
+		var mine []prOutcome
+		for _, r := range results {
+			if strings.EqualFold(repoOwner(r.Repo), org) {
+				mine = append(mine, r)
+			}
 		}
-		lines = append(lines, fmt.Sprintf("- %s (%s)", r.URL, reason))
+		merged, commented, skipped, errs := summarize(mine)
+		totals = append(totals, orgTotals{Org: org, Merged: merged, Commented: commented, Skipped: skipped, Errors: errs})
 	}
-	msg := strings.Join(lines, "\n")
-	if len(msg) <= 1900 {
-		return msg
-	}
-	return msg[:1890] + "\n(truncated)"
+	return totals
+}
+
+// repoOwner returns the owner half of an owner/name repo.
+func repoOwner(repo string) string {
+	owner, _, _ := strings.Cut(repo, "/")
+	return owner
 }
 
 // discordBotToken returns the bot token to use for Discord posting.
@@ -727,26 +2379,126 @@ func discordBotToken() string {
 	return strings.TrimSpace(os.Getenv("DISCORD_BOT_TOKEN"))
 }
 
-func discordSendMessage(token string, channelID string, content string) error {
+// discordAPIBase is the Discord REST API root (overridden in tests).
+var discordAPIBase = "https://discord.com/api/v10"
+
+// discordGet performs an authenticated GET against the Discord API and
+// decodes the JSON response into v.
+func discordGet(ctx context.Context, token string, path string, v any) error {
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", discordAPIBase+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+strings.TrimSpace(token))
+	req.Header.Set("User-Agent", "misty-step/factory/pr-pipeline")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(raw))
+		if msg == "" {
+			msg = resp.Status
+		}
+		return fmt.Errorf("discord GET %s failed (%d): %s", path, resp.StatusCode, msg)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// discordCheckToken validates the bot token and returns the bot's username.
+func discordCheckToken(ctx context.Context, token string) (string, error) {
+	var me struct {
+		Username string `json:"username"`
+	}
+	if err := discordGet(ctx, token, "/users/@me", &me); err != nil {
+		return "", err
+	}
+	return me.Username, nil
+}
+
+// discordCheckChannel verifies the bot can see the channel and returns its name.
+func discordCheckChannel(ctx context.Context, token string, channelID string) (string, error) {
+	var ch struct {
+		Name string `json:"name"`
+	}
+	if err := discordGet(ctx, token, "/channels/"+strings.TrimSpace(channelID), &ch); err != nil {
+		return "", err
+	}
+	return "#" + ch.Name, nil
+}
+
+// discordSendEmbeds posts each embed as its own message, in order, so a long
+// summary is split across messages rather than cut off. Messages are paced by
+// Discord's rate-limit headers, and a part that still fails doesn't stop the
+// parts after it.
+func discordSendEmbeds(ctx context.Context, token string, channelID string, embeds []discordEmbed) error {
+	var errs []error
+	for i, e := range embeds {
+		pause, err := discordPost(ctx, token, channelID, discordMessage{Embeds: []discordEmbed{e}})
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			errs = append(errs, fmt.Errorf("part %d/%d: %w", i+1, len(embeds), err))
+			continue
+		}
+		if pause > 0 && i < len(embeds)-1 {
+			if err := discordRateLimitSleep(ctx, pause); err != nil {
+				return err
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func discordPostMessage(ctx context.Context, token string, channelID string, body discordMessage) error {
+	_, err := discordPost(ctx, token, channelID, body)
+	return err
+}
+
+// discordPost sends one message, waiting out and retrying 429 responses. It
+// returns how long to wait before the next message to the same channel.
+func discordPost(ctx context.Context, token string, channelID string, body discordMessage) (time.Duration, error) {
 	tok := strings.TrimSpace(token)
 	ch := strings.TrimSpace(channelID)
 	if tok == "" {
-		return errors.New("missing token")
+		return 0, errors.New("missing token")
 	}
 	if ch == "" {
-		return errors.New("missing channel id")
+		return 0, errors.New("missing channel id")
 	}
-	body := struct {
-		Content string `json:"content"`
-	}{Content: content}
 	b, err := json.Marshal(body)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	b = []byte(redact(string(b)))
+	for attempt := 0; ; attempt++ {
+		pause, retryAfter, err := discordPostOnce(ctx, tok, ch, b)
+		if retryAfter == 0 {
+			return pause, err
+		}
+		if attempt >= discordRateLimitRetries || retryAfter > discordMaxRetryAfter {
+			return 0, err
+		}
+		fmt.Fprintf(os.Stderr, "[discord] rate limited; retrying in %s\n", retryAfter)
+		if err := discordRateLimitSleep(ctx, retryAfter); err != nil {
+			return 0, err
+		}
 	}
+}
 
-	req, err := http.NewRequest("POST", "https://discord.com/api/v10/channels/"+ch+"/messages", bytes.NewReader(b))
+// discordPostOnce makes a single create-message request. On a 429 it also
+// returns how long Discord asked us to wait.
+func discordPostOnce(ctx context.Context, tok string, ch string, b []byte) (pause time.Duration, retryAfter time.Duration, err error) {
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", discordAPIBase+"/channels/"+ch+"/messages", bytes.NewReader(b))
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 	req.Header.Set("Authorization", "Bot "+tok)
 	req.Header.Set("Content-Type", "application/json")
@@ -754,7 +2506,7 @@ func discordSendMessage(token string, channelID string, content string) error {
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
@@ -763,18 +2515,24 @@ func discordSendMessage(token string, channelID string, content string) error {
 		if msg == "" {
 			msg = resp.Status
 		}
-		return fmt.Errorf("discord send failed (%d): %s", resp.StatusCode, msg)
+		err := fmt.Errorf("discord send failed (%d): %s", resp.StatusCode, msg)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return 0, discordRetryAfter(resp.Header, raw), err
+		}
+		return 0, 0, err
 	}
-	return nil
+	return discordBucketPause(resp.Header), 0, nil
 }
 
 func overallChecksState(entries []statusRollupEntry) string {
+	// Our own decision check would feed back into the decision.
+	entries = slices.DeleteFunc(slices.Clone(entries), isPipelineCheck)
 	if len(entries) == 0 {
 		return ""
 	}
 	// statusCheckRollup is a mixed array of CheckRun + StatusContext records.
 	// We compute a coarse overall state: SUCCESS, FAILURE, PENDING.
-	pending := false
+	pending, awaiting := false, false
 	for _, e := range entries {
 		typeName := strings.TrimSpace(e.Typename)
 		switch typeName {
@@ -792,6 +2550,9 @@ func overallChecksState(entries []statusRollupEntry) string {
 			switch conclusion {
 			case "SUCCESS", "NEUTRAL", "SKIPPED":
 				// ok
+			case "ACTION_REQUIRED":
+				// A workflow waiting for a maintainer to approve it.
+				awaiting = true
 			default:
 				return "FAILURE"
 			}
@@ -815,6 +2576,10 @@ func overallChecksState(entries []statusRollupEntry) string {
 			// Unknown type; ignore.
 		}
 	}
+	if awaiting {
+		// Pending checks may finish, but these won't start on their own.
+		return "ACTION_REQUIRED"
+	}
 	if pending {
 		return "PENDING"
 	}
@@ -857,48 +2622,122 @@ func classifyCIFailure(entries []statusRollupEntry) string {
 	return "unknown"
 }
 
-func ghSearchPRs(owner string, limit int) ([]searchPR, error) {
-	if strings.TrimSpace(owner) == "" {
-		return nil, errors.New("owner/org required")
-	}
-	if limit <= 0 {
-		limit = 30
+// flakyRerunRunIDs returns the workflow run IDs to re-run when every failing
+// check looks flaky: a cancelled or timed-out CheckRun, or one whose name
+// matches flakyRe. Returns nil if any failure looks real, since a re-run
+// won't fix it, or if a flaky check has no resolvable workflow run.
+func flakyRerunRunIDs(entries []statusRollupEntry, flakyRe *regexp.Regexp) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, e := range entries {
+		switch strings.TrimSpace(e.Typename) {
+		case "CheckRun":
+			conclusion := strings.ToUpper(strings.TrimSpace(e.Conclusion))
+			switch conclusion {
+			case "", "SUCCESS", "NEUTRAL", "SKIPPED":
+				continue
+			}
+			flaky := conclusion == "CANCELLED" || conclusion == "TIMED_OUT"
+			if !flaky && flakyRe != nil && flakyRe.MatchString(e.Name) {
+				flaky = true
+			}
+			if !flaky {
+				return nil
+			}
+			id := runIDFromDetailsURL(e.DetailsURL)
+			if id == "" {
+				return nil
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		case "StatusContext":
+			// Commit statuses can't be re-run through Actions.
+			switch strings.ToUpper(strings.TrimSpace(e.State)) {
+			case "FAILURE", "ERROR":
+				return nil
+			}
+		}
+	}
+	return ids
+}
+
+var actionsRunIDRe = regexp.MustCompile(`/actions/runs/(\d+)`)
+
+// runIDFromDetailsURL extracts the Actions workflow run ID from a check's
+// details URL (https://github.com/OWNER/REPO/actions/runs/RUN/job/JOB).
+func runIDFromDetailsURL(detailsURL string) string {
+	m := actionsRunIDRe.FindStringSubmatch(detailsURL)
+	if len(m) == 2 {
+		return m[1]
+	}
+	return ""
+}
+
+// rerunFlakyRuns re-runs the failed jobs of each workflow run that hasn't
+// reached maxAttempts yet. Returns how many runs were re-triggered.
+func rerunFlakyRuns(ctx context.Context, repo string, runIDs []string, maxAttempts int) (int, error) {
+	rerun := 0
+	for _, id := range runIDs {
+		attempt, err := RetryableWithResult(ctx, func() (int, error) {
+			return ghRunAttempt(ctx, repo, id)
+		}, retryCfg)
+		if err != nil {
+			return rerun, err
+		}
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			fmt.Fprintf(os.Stderr, "[ci-rerun] %s run %s already at attempt %d (max %d), not re-running\n", repo, id, attempt, maxAttempts)
+			continue
+		}
+		if err := Retryable(ctx, func() error {
+			return ghRunRerunFailed(ctx, repo, id)
+		}, retryCfg); err != nil {
+			return rerun, err
+		}
+		rerun++
 	}
+	return rerun, nil
+}
+
+func ghRunAttempt(ctx context.Context, repo string, runID string) (int, error) {
 	args := []string{
-		"search", "prs",
-		"--owner", owner,
-		"--state", "open",
-		"--sort", "updated",
-		"--order", "desc",
-		"--limit", fmt.Sprintf("%d", limit),
-		"--json", "url,title,body,updatedAt,isDraft,author,labels,number,repository",
-	}
-	stdout, err := runCmd("gh", args...)
+		"run", "view", runID,
+		"-R", repo,
+		"--json", "attempt",
+	}
+	stdout, err := runGh(ctx, args...)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	var prs []searchPR
-	if err := json.Unmarshal(stdout, &prs); err != nil {
-		return nil, fmt.Errorf("parse gh search json: %w", err)
+	var v struct {
+		Attempt int `json:"attempt"`
 	}
-	for i := range prs {
-		if prs[i].URL == "" || prs[i].Repository.NameWithOwner == "" {
-			// best-effort normalize
-			prs[i].Repository.NameWithOwner = repoFromPRURL(prs[i].URL)
-		}
+	if err := json.Unmarshal(stdout, &v); err != nil {
+		return 0, fmt.Errorf("parse gh run view json: %w", err)
+	}
+	return v.Attempt, nil
+}
+
+func ghRunRerunFailed(ctx context.Context, repo string, runID string) error {
+	args := []string{
+		"run", "rerun", runID,
+		"-R", repo,
+		"--failed",
 	}
-	return prs, nil
+	_, err := runGh(ctx, args...)
+	return err
 }
 
-func ghPRView(url string) (*prView, error) {
+func ghPRView(ctx context.Context, url string) (*prView, error) {
 	if strings.TrimSpace(url) == "" {
 		return nil, errors.New("pr url required")
 	}
 	args := []string{
 		"pr", "view", url,
-		"--json", "id,url,title,body,isDraft,mergeable,reviewDecision,mergeStateStatus,statusCheckRollup,author,labels",
+		"--json", "id,url,title,body,isDraft,mergeable,reviewDecision,mergeStateStatus,baseRefName,headRefName,headRefOid,isCrossRepository,autoMergeRequest,reviewRequests,statusCheckRollup,author,labels,latestReviews,additions,deletions,changedFiles,createdAt",
 	}
-	stdout, err := runCmd("gh", args...)
+	stdout, err := runGh(ctx, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -909,36 +2748,176 @@ func ghPRView(url string) (*prView, error) {
 	return &v, nil
 }
 
-func mergeAllowed(pr *prView) (bool, string) {
-	mergeable := strings.ToUpper(strings.TrimSpace(pr.Mergeable))
-	if mergeable != "MERGEABLE" {
-		return false, "mergeable_" + strings.ToLower(mergeable)
-	}
-	state := strings.ToUpper(strings.TrimSpace(overallChecksState(pr.StatusCheckRollup)))
-	if state == "" {
-		// Some repos don't report rollups; treat as not ready.
-		return false, "checks_unknown"
-	}
-	if state != "SUCCESS" {
-		return false, "checks_" + strings.ToLower(state)
-	}
+// reviewBlocker returns the review reason a PR can't merge, or "" if its
+// review state allows merging (APPROVED, or no decision when approval isn't
+// required).
+func reviewBlocker(pr *prView, policy repoPolicy) reasonCode {
 	decision := strings.ToUpper(strings.TrimSpace(pr.ReviewDecision))
 	if decision == "CHANGES_REQUESTED" {
-		return false, "review_changes_requested"
+		return reasonReviewChangesRequested
 	}
 	if decision == "REVIEW_REQUIRED" {
-		return false, "review_required"
+		return reasonReviewRequired
+	}
+	if policy.RequireApproval && decision != "APPROVED" {
+		return reasonReviewRequired
+	}
+	return ""
+}
+
+// effectiveReviewDecision is the PR's reviewDecision, except that
+// CHANGES_REQUESTED becomes APPROVED when every outstanding change request
+// comes from a reviewer the config marks ignorable and someone else has
+// approved.
+func effectiveReviewDecision(pr *prView, cfg *pipelineConfig) string {
+	if !strings.EqualFold(strings.TrimSpace(pr.ReviewDecision), "CHANGES_REQUESTED") {
+		return pr.ReviewDecision
+	}
+	approved := false
+	for _, r := range pr.LatestReviews {
+		ignorable := cfg.ignorableReviewer(r.Author.Login)
+		switch strings.ToUpper(r.State) {
+		case "CHANGES_REQUESTED":
+			if !ignorable {
+				return pr.ReviewDecision
+			}
+		case "APPROVED":
+			if !ignorable {
+				approved = true
+			}
+		}
+	}
+	if !approved || len(ignoredChangeRequests(pr, cfg)) == 0 {
+		return pr.ReviewDecision
+	}
+	return "APPROVED"
+}
+
+// ignoredChangeRequests returns the ignorable reviewers whose latest review
+// requests changes.
+func ignoredChangeRequests(pr *prView, cfg *pipelineConfig) []string {
+	var logins []string
+	for _, r := range pr.LatestReviews {
+		if strings.EqualFold(r.State, "CHANGES_REQUESTED") && cfg.ignorableReviewer(r.Author.Login) {
+			logins = append(logins, r.Author.Login)
+		}
+	}
+	return logins
+}
+
+// staleChangeRequests returns the change-request reviews to dismiss: those
+// left on an older head by a reviewer in the config's AutoDismissReviewers.
+// It returns nothing unless dismissing them clears every change request
+// that blocks the PR (ignorable reviewers' don't).
+func staleChangeRequests(pr *prView, cfg *pipelineConfig) []prReview {
+	var stale []prReview
+	for _, r := range pr.LatestReviews {
+		if !strings.EqualFold(r.State, "CHANGES_REQUESTED") {
+			continue
+		}
+		switch {
+		case r.ID != "" && r.Commit.Oid != "" && r.Commit.Oid != pr.HeadRefOid && cfg.autoDismissReviewer(r.Author.Login):
+			stale = append(stale, r)
+		case cfg.ignorableReviewer(r.Author.Login):
+		default:
+			return nil
+		}
+	}
+	return stale
+}
+
+// staleReviewMessage explains a dismissal to the reviewer.
+func staleReviewMessage(r prReview, pr *prView) string {
+	return fmt.Sprintf("Dismissed by fab-pr-pipeline: these changes were requested on %s, and the PR has new commits (now at %s). Please re-review if they still apply.",
+		shortSHA(r.Commit.Oid), shortSHA(pr.HeadRefOid))
+}
+
+// shortSHA abbreviates a commit SHA the way GitHub displays it.
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// prTooLarge reports whether the PR's diff is over either size limit (0
+// disables a limit). A large diff from an agent is exactly where a human
+// should look before it lands.
+func prTooLarge(pr *prView, maxLines int, maxFiles int) bool {
+	if maxLines > 0 && pr.Additions+pr.Deletions > maxLines {
+		return true
+	}
+	return maxFiles > 0 && pr.ChangedFiles > maxFiles
+}
+
+// autoMergeCandidate reports whether a PR is blocked only on pending checks,
+// so enabling auto-merge would let GitHub merge it once CI goes green.
+func autoMergeCandidate(pr *prView, policy repoPolicy, blocker reasonCode) bool {
+	return blocker == reasonChecksPending && reviewBlocker(pr, policy) == ""
+}
+
+// ghEnableAutoMerge turns on auto-merge for a PR with the given merge method.
+func ghEnableAutoMerge(ctx context.Context, pullRequestNodeID string, method string) error {
+	if strings.TrimSpace(pullRequestNodeID) == "" {
+		return errors.New("pull request node id required")
+	}
+	if method == "" {
+		method = "MERGE"
+	}
+	query := `mutation($pullRequestId: ID!, $mergeMethod: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: { pullRequestId: $pullRequestId, mergeMethod: $mergeMethod }) {
+    pullRequest { autoMergeRequest { enabledAt } }
+  }
+}`
+	args := []string{
+		"api", "graphql",
+		"-f", "query=" + query,
+		"-f", "pullRequestId=" + pullRequestNodeID,
+		"-f", "mergeMethod=" + method,
+	}
+	stdout, err := runGh(ctx, args...)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(stdout, &resp); err != nil {
+		return fmt.Errorf("parse auto-merge response: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return errors.New(resp.Errors[0].Message)
 	}
-	// APPROVED or empty => ok.
-	return true, ""
+	return nil
+}
+
+// isAutoMergeUnavailableError reports whether auto-merge was refused because
+// the repo doesn't allow it (or the branch has no protection to wait on).
+func isAutoMergeUnavailableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "auto merge is not allowed") ||
+		strings.Contains(msg, "auto-merge is not allowed") ||
+		strings.Contains(msg, "protected branch rules not configured") ||
+		strings.Contains(msg, "clean status")
 }
 
-func ghMergePR(pullRequestNodeID string) (string, error) {
+// ghMergePR merges the PR, and returns the merge commit's OID. With an
+// expectedHeadOid, GitHub refuses the merge if the PR's head has moved on
+// since (see isHeadMovedError).
+func ghMergePR(ctx context.Context, pullRequestNodeID string, method string, expectedHeadOid string) (string, error) {
 	if strings.TrimSpace(pullRequestNodeID) == "" {
 		return "", errors.New("pull request node id required")
 	}
-	query := `mutation($pullRequestId: ID!) {
-  mergePullRequest(input: { pullRequestId: $pullRequestId, mergeMethod: MERGE }) {
+	if method == "" {
+		method = "MERGE"
+	}
+	query := `mutation($pullRequestId: ID!, $mergeMethod: PullRequestMergeMethod!, $expectedHeadOid: GitObjectID) {
+  mergePullRequest(input: { pullRequestId: $pullRequestId, mergeMethod: $mergeMethod, expectedHeadOid: $expectedHeadOid }) {
     pullRequest {
       merged
       mergedAt
@@ -950,8 +2929,12 @@ func ghMergePR(pullRequestNodeID string) (string, error) {
 		"api", "graphql",
 		"-f", "query=" + query,
 		"-f", "pullRequestId=" + pullRequestNodeID,
+		"-f", "mergeMethod=" + method,
 	}
-	stdout, err := runCmd("gh", args...)
+	if expectedHeadOid != "" {
+		args = append(args, "-f", "expectedHeadOid="+expectedHeadOid)
+	}
+	stdout, err := runGh(ctx, args...)
 	if err != nil {
 		return "", err
 	}
@@ -969,7 +2952,130 @@ func ghMergePR(pullRequestNodeID string) (string, error) {
 	return oid, nil
 }
 
-func ghPRComment(url string, body string) error {
+// ghDismissReview dismisses a pull request review by node ID.
+func ghDismissReview(ctx context.Context, reviewNodeID string, message string) error {
+	if strings.TrimSpace(reviewNodeID) == "" {
+		return errors.New("review node id required")
+	}
+	query := `mutation($reviewId: ID!, $message: String!) {
+  dismissPullRequestReview(input: { pullRequestReviewId: $reviewId, message: $message }) {
+    pullRequestReview { state }
+  }
+}`
+	args := []string{
+		"api", "graphql",
+		"-f", "query=" + query,
+		"-f", "reviewId=" + reviewNodeID,
+		"-f", "message=" + message,
+	}
+	stdout, err := runGh(ctx, args...)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(stdout, &resp); err != nil {
+		return fmt.Errorf("parse dismiss review response: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return errors.New(resp.Errors[0].Message)
+	}
+	return nil
+}
+
+// ghMergeQueueEnabled reports whether the repo has a merge queue configured
+// for the given base branch. Queue-protected branches reject direct merges.
+func ghMergeQueueEnabled(ctx context.Context, repo string, branch string) (bool, error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" {
+		return false, fmt.Errorf("invalid repo %q", repo)
+	}
+	if strings.TrimSpace(branch) == "" {
+		return false, errors.New("base branch required")
+	}
+	query := `query($owner: String!, $name: String!, $branch: String!) {
+  repository(owner: $owner, name: $name) {
+    mergeQueue(branch: $branch) { id }
+  }
+}`
+	args := []string{
+		"api", "graphql",
+		"-f", "query=" + query,
+		"-f", "owner=" + owner,
+		"-f", "name=" + name,
+		"-f", "branch=" + branch,
+	}
+	stdout, err := runGh(ctx, args...)
+	if err != nil {
+		return false, err
+	}
+	var resp struct {
+		Data struct {
+			Repository struct {
+				MergeQueue *struct {
+					ID string `json:"id"`
+				} `json:"mergeQueue"`
+			} `json:"repository"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(stdout, &resp); err != nil {
+		return false, fmt.Errorf("parse merge queue response: %w", err)
+	}
+	return resp.Data.Repository.MergeQueue != nil, nil
+}
+
+// ghEnqueuePR adds the PR to its base branch's merge queue and returns the
+// queue position.
+func ghEnqueuePR(ctx context.Context, pullRequestNodeID string) (int, error) {
+	if strings.TrimSpace(pullRequestNodeID) == "" {
+		return 0, errors.New("pull request node id required")
+	}
+	query := `mutation($pullRequestId: ID!) {
+  enqueuePullRequest(input: { pullRequestId: $pullRequestId }) {
+    mergeQueueEntry { position }
+  }
+}`
+	args := []string{
+		"api", "graphql",
+		"-f", "query=" + query,
+		"-f", "pullRequestId=" + pullRequestNodeID,
+	}
+	stdout, err := runGh(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	var resp enqueueMutationResponse
+	if err := json.Unmarshal(stdout, &resp); err != nil {
+		return 0, fmt.Errorf("parse enqueue response: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return 0, errors.New(resp.Errors[0].Message)
+	}
+	return resp.Data.EnqueuePullRequest.MergeQueueEntry.Position, nil
+}
+
+// isMergeQueueRequiredError reports whether a merge was rejected because the
+// base branch only accepts changes through the merge queue.
+func isMergeQueueRequiredError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "merge queue")
+}
+
+// isHeadMovedError reports whether a merge was refused because the PR got
+// new commits after it was viewed, so its expectedHeadOid no longer matched.
+func isHeadMovedError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "head branch was modified")
+}
+
+func ghPRComment(ctx context.Context, url string, body string) error {
 	if strings.TrimSpace(url) == "" {
 		return errors.New("pr url required")
 	}
@@ -980,49 +3086,53 @@ func ghPRComment(url string, body string) error {
 		"pr", "comment", url,
 		"--body", body,
 	}
-	_, err := runCmd("gh", args...)
+	_, err := runGh(ctx, args...)
 	return err
 }
 
-// ghPRUpdateBranch attempts to update a PR branch from its base branch.
-// This can automatically resolve merge conflicts when the base has moved forward.
-func ghPRUpdateBranch(url string) error {
+// ghPRClose closes a PR, leaving the given comment explaining why.
+func ghPRClose(ctx context.Context, url string, comment string) error {
 	if strings.TrimSpace(url) == "" {
 		return errors.New("pr url required")
 	}
 	args := []string{
-		"pr", "update-branch", url,
+		"pr", "close", url,
+		"--comment", comment,
 	}
-	_, err := runCmd("gh", args...)
+	_, err := runGh(ctx, args...)
 	return err
 }
 
-// ghPRComments fetches the most recent 100 comment bodies from a PR, ordered newest first.
-// 100 is sufficient for dedup purposes and avoids unbounded fetching on high-traffic PRs.
-func ghPRComments(url string) ([]string, error) {
+// ghPRReady marks a draft PR ready for review.
+func ghPRReady(ctx context.Context, url string) error {
 	if strings.TrimSpace(url) == "" {
-		return nil, errors.New("pr url required")
-	}
-	args := []string{
-		"pr", "view", url,
-		"--json", "comments",
-		"--jq", ".comments | sort_by(.createdAt) | reverse | .[0:100] | .[].body",
+		return errors.New("pr url required")
 	}
-	stdout, err := runCmd("gh", args...)
-	if err != nil {
-		return nil, err
+	_, err := runGh(ctx, "pr", "ready", url)
+	return err
+}
+
+// promotableDraftAuthor reports whether --promote-drafts applies to a PR by
+// login: only the Kaylee bot's drafts are promoted.
+func promotableDraftAuthor(opts *runOptions, login string) bool {
+	kaylee := strings.TrimSpace(opts.Kaylee)
+	return opts.PromoteDrafts && kaylee != "" && strings.EqualFold(strings.TrimSpace(login), kaylee)
+}
+
+// ghPRUpdateBranch attempts to update a PR branch from its base branch.
+// This can automatically resolve merge conflicts when the base has moved forward.
+func ghPRUpdateBranch(ctx context.Context, url string) error {
+	if strings.TrimSpace(url) == "" {
+		return errors.New("pr url required")
 	}
-	bodies := strings.Split(string(stdout), "\n")
-	filtered := make([]string, 0, len(bodies))
-	for _, b := range bodies {
-		if trimmed := strings.TrimSpace(b); trimmed != "" {
-			filtered = append(filtered, trimmed)
-		}
+	args := []string{
+		"pr", "update-branch", url,
 	}
-	return filtered, nil
+	_, err := runGh(ctx, args...)
+	return err
 }
 
-func ghPRReviewComments(url string) (string, error) {
+func ghPRReviewComments(ctx context.Context, url string) (string, error) {
 	if strings.TrimSpace(url) == "" {
 		return "", errors.New("pr url required")
 	}
@@ -1031,7 +3141,7 @@ func ghPRReviewComments(url string) (string, error) {
 		"--json", "reviews",
 		"--jq", `.reviews[] | select(.state == "CHANGES_REQUESTED") | .body`,
 	}
-	stdout, err := runCmd("gh", args...)
+	stdout, err := runGh(ctx, args...)
 	if err != nil {
 		return "", err
 	}
@@ -1051,70 +3161,122 @@ func ghPRReviewComments(url string) (string, error) {
 	return strings.Join(filtered, "\n\n"), nil
 }
 
-type repoInfo struct {
-	Name          string `json:"name"`
-	NameWithOwner string `json:"nameWithOwner"`
-	IsArchived    bool   `json:"isArchived"`
-}
+// defaultCallTimeout bounds a single gh command or Discord request.
+const defaultCallTimeout = 2 * time.Minute
 
-// fetchArchivedRepos fetches all repos in the org and returns a set of archived repo names.
-// Uses: gh repo list <org> --json name,nameWithOwner,isArchived --limit 200
-func fetchArchivedRepos(org string) (map[string]bool, error) {
-	args := []string{
-		"repo", "list", org,
-		"--json", "name,nameWithOwner,isArchived",
-		"--limit", "200",
-	}
-	out, err := runCmd("gh", args...)
-	if err != nil {
-		return nil, err
-	}
-	var repos []repoInfo
-	if err := json.Unmarshal(out, &repos); err != nil {
-		return nil, fmt.Errorf("parse gh repo list json: %w", err)
+// callTimeout is the per-call deadline applied by runCmd and the Discord
+// client (set from --per-call-timeout; 0 disables).
+var callTimeout = defaultCallTimeout
+
+// withCallTimeout derives the context for one external call.
+func withCallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if callTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
-	archived := make(map[string]bool)
-	for _, r := range repos {
-		if r.IsArchived {
-			archived[r.NameWithOwner] = true
-		}
+	return context.WithTimeout(ctx, callTimeout)
+}
+
+// runCmd runs bin with args, killing it if ctx is done or the per-call
+// timeout passes. A run-level cancellation is returned wrapped so callers
+// can tell it apart (and stop retrying); a per-call timeout reads as a
+// transient "timed out" error.
+func runCmd(ctx context.Context, bin string, args ...string) ([]byte, error) {
+	return runCmdInput(ctx, nil, bin, args...)
+}
+
+// runCmdInput is runCmd with stdin (e.g. a JSON body for `gh api --input -`).
+func runCmdInput(ctx context.Context, stdin []byte, bin string, args ...string) ([]byte, error) {
+	if ghTokenPool != nil {
+		return ghTokenPool.run(func(token string) ([]byte, error) {
+			return runCmdToken(ctx, stdin, token, bin, args...)
+		})
 	}
-	return archived, nil
+	return runCmdToken(ctx, stdin, "", bin, args...)
 }
 
-func runCmd(bin string, args ...string) ([]byte, error) {
-	cmd := exec.Command(bin, args...)
+// runCmdToken is runCmdInput with GH_TOKEN set to token. An empty token
+// uses the GitHub App's, if there is one, or else gh's own login.
+func runCmdToken(ctx context.Context, stdin []byte, token string, bin string, args ...string) ([]byte, error) {
+	callCtx, cancel := withCallTimeout(ctx)
+	defer cancel()
+	runArgs, include := args, bin == "gh" && wantsResponseHeaders(args)
+	if include {
+		runArgs = append(slices.Clone(args), "--include")
+	}
+	cmd := exec.CommandContext(callCtx, bin, runArgs...)
 	cmd.Env = os.Environ()
+	noteAuditActor(ctx, token)
+	if token != "" {
+		cmd.Env = append(cmd.Env, "GH_TOKEN="+token)
+	} else if ghTokenSource != nil {
+		token, err := ghTokenSource.Token(callCtx)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: github app auth: %w", bin, strings.Join(args, " "), err)
+		}
+		cmd.Env = append(cmd.Env, "GH_TOKEN="+token)
+	}
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	// Don't wait forever on pipes held open by a killed command's children.
+	cmd.WaitDelay = 5 * time.Second
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
+	runErr := cmd.Run()
+	var headers http.Header
+	out := stdout.Bytes()
+	if include {
+		headers, out = splitResponseHeaders(out)
+	}
+	if err := runErr; err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("%s %s: %w", bin, strings.Join(args, " "), ctxErr)
+		}
+		if callCtx.Err() != nil {
+			return nil, fmt.Errorf("%s %s: timed out after %s", bin, strings.Join(args, " "), callTimeout)
+		}
 		msg := strings.TrimSpace(stderr.String())
 		if msg == "" {
-			msg = strings.TrimSpace(stdout.String())
+			msg = strings.TrimSpace(string(out))
 		}
 		if msg == "" {
 			msg = err.Error()
 		}
-		return nil, fmt.Errorf("%s %s: %s", bin, strings.Join(args, " "), msg)
+		return nil, &cmdError{
+			msg:     fmt.Sprintf("%s %s: %s", bin, strings.Join(args, " "), msg),
+			stdout:  out,
+			stderr:  stderr.String(),
+			headers: headers,
+		}
 	}
-	return stdout.Bytes(), nil
+	return out, nil
 }
 
 func isDoNotTouch(labelName string, title string, body string, labels []label) bool {
-	target := strings.ToLower(strings.TrimSpace(labelName))
-	if target != "" {
-		for _, l := range labels {
-			if strings.ToLower(strings.TrimSpace(l.Name)) == target {
-				return true
-			}
-		}
+	if hasLabel(labels, labelName) {
+		return true
 	}
 	needle := "do not touch"
 	hay := strings.ToLower(title + "\n" + body)
 	return strings.Contains(hay, needle)
 }
 
+// hasLabel reports whether labels include name, ignoring case and
+// surrounding space. An empty name never matches.
+func hasLabel(labels []label, name string) bool {
+	target := strings.ToLower(strings.TrimSpace(name))
+	if target == "" {
+		return false
+	}
+	for _, l := range labels {
+		if strings.ToLower(strings.TrimSpace(l.Name)) == target {
+			return true
+		}
+	}
+	return false
+}
+
 // conflictCommentMarker is the canonical substring we search for to detect a
 // previously-posted conflict comment (present in both the comment body and the
 // dedup check).
@@ -1132,42 +3294,109 @@ func hasConflictComment(comments []string) bool {
 	return false
 }
 
+// notMergedMarkerPrefix starts the hidden marker on every generic
+// not-merged comment.
+const notMergedMarkerPrefix = "<!-- pr-pipeline"
+
+// notMergedMarker is the hidden first line of a not-merged comment. It
+// records the blocker the comment was posted for, so later runs can tell
+// whether anything changed.
+func notMergedMarker(reason string, checks string) string {
+	return fmt.Sprintf("%s reason=%s checks=%s -->", notMergedMarkerPrefix, reason, checks)
+}
+
+// hasNotMergedComment reports whether our most recent not-merged comment
+// (comments are newest first) was posted for the same reason and checks
+// state. Older comments don't count: if the blocker changed and then changed
+// back, the PR gets a fresh comment.
+func hasNotMergedComment(comments []string, reason string, checks string) bool {
+	want := notMergedMarker(reason, checks)
+	for _, c := range comments {
+		for _, line := range strings.Split(c, "\n") {
+			if line = strings.TrimSpace(line); strings.HasPrefix(line, notMergedMarkerPrefix) {
+				return line == want
+			}
+		}
+	}
+	return false
+}
+
+// buildCommentBody renders the default comment for a PR not merged for
+// reason.
 func buildCommentBody(pr *prView, reason string) string {
-	// Distinct message for merge conflicts - auto-update failed, needs manual resolution.
-	if reason == "mergeable_conflicting" {
-		return "<!-- kaylee-pr-pipeline -->\n" +
-			"⚠️ This PR has merge conflict with the base branch. Automatic merge-in failed — please resolve conflicts manually and push."
+	return defaultCommentTemplates().body(pr, reason, "")
+}
+
+// failingChecks returns the rollup entries that failed: completed CheckRuns
+// with a non-passing conclusion and StatusContexts in FAILURE or ERROR.
+func failingChecks(entries []statusRollupEntry) []statusRollupEntry {
+	var failing []statusRollupEntry
+	for _, e := range entries {
+		switch strings.TrimSpace(e.Typename) {
+		case "CheckRun":
+			switch strings.ToUpper(strings.TrimSpace(e.Conclusion)) {
+			case "", "SUCCESS", "NEUTRAL", "SKIPPED", "ACTION_REQUIRED":
+				continue
+			}
+		case "StatusContext":
+			switch strings.ToUpper(strings.TrimSpace(e.State)) {
+			case "FAILURE", "ERROR":
+			default:
+				continue
+			}
+		default:
+			continue
+		}
+		failing = append(failing, e)
+	}
+	return failing
+}
+
+// failingCheckNames returns the names of the failing checks, for alerts.
+func failingCheckNames(entries []statusRollupEntry) []string {
+	var names []string
+	for _, e := range failingChecks(entries) {
+		names = append(names, rollupEntryName(e))
 	}
+	return names
+}
+
+// maxListedChecks bounds the failing-check list so the comment stays short.
+const maxListedChecks = 10
 
-	// Keep it short and deterministic; this is meant to be machine-run.
-	lines := []string{
-		"<!-- pr-pipeline -->",
-		"PR pipeline: not merged automatically.",
-		"",
-		fmt.Sprintf("- mergeable: `%s`", pr.Mergeable),
-		fmt.Sprintf("- checks: `%s`", overallChecksState(pr.StatusCheckRollup)),
-		fmt.Sprintf("- reviewDecision: `%s`", pr.ReviewDecision),
-		fmt.Sprintf("- reason: `%s`", reason),
-		"",
-		"Next action: make checks green and resolve review blockers; rerun pipeline.",
+// isCloseStaleCandidate reports whether a PR from one of the given bot authors
+// has gone untouched for at least days. days <= 0 disables stale closing.
+func isCloseStaleCandidate(author string, updatedAt time.Time, authors []string, days int, now time.Time) bool {
+	if days <= 0 || updatedAt.IsZero() {
+		return false
 	}
-	if strings.HasPrefix(reason, "checks_") {
-		ciType := classifyCIFailure(pr.StatusCheckRollup)
-		if ciType == "lint" {
-			lines = append(lines, "🧹 Lint-fix subagent dispatched via Discord for batch dispatch.")
+	if now.Sub(updatedAt) < time.Duration(days)*24*time.Hour {
+		return false
+	}
+	author = strings.TrimSpace(author)
+	for _, a := range authors {
+		if strings.EqualFold(a, author) {
+			return true
 		}
 	}
-	return strings.Join(lines, "\n")
+	return false
+}
+
+func buildStaleCloseComment(days int) string {
+	return "<!-- kaylee-pr-pipeline -->\n" +
+		fmt.Sprintf("Closing this PR because it has had no activity for %d days. ", days) +
+		"Thanks for the contribution — feel free to reopen it (or open a fresh PR) if the change is still wanted."
 }
 
-func repoFromPRURL(prURL string) string {
-	// https://github.com/OWNER/REPO/pull/123
-	re := regexp.MustCompile(`^https://github\\.com/([^/]+)/([^/]+)/pull/\\d+/?$`)
-	m := re.FindStringSubmatch(strings.TrimSpace(prURL))
-	if len(m) == 3 {
-		return m[1] + "/" + m[2]
+// splitList splits a comma-separated flag value into trimmed, non-empty items.
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-	return ""
+	return items
 }
 
 func sortByUpdatedAtDesc(prs []searchPR) {
@@ -1195,6 +3424,43 @@ func resolveStatePath(customPath string) string {
 	return filepath.Join(home, ".config", "fab-pr-pipeline", "state.json")
 }
 
+// lastRunPath returns where the most recent run output is saved, beside the
+// dedup state file.
+func lastRunPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "last-run.json")
+}
+
+// saveLastRun writes the run output so `report` can re-post it later.
+func saveLastRun(path string, out runOutput) error {
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadLastRun reads the run output saved by saveLastRun.
+func loadLastRun(path string) (runOutput, error) {
+	var out runOutput
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return out, fmt.Errorf("no saved run: %w", err)
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return out, fmt.Errorf("parse saved run %s: %w", path, err)
+	}
+	for i, r := range out.Results {
+		if r.ReasonCode == "" {
+			// Saved before outcomes carried codes.
+			out.Results[i] = r.withReasonCode()
+		}
+	}
+	return out, nil
+}
+
 // hashResults computes a deterministic SHA-256 hash of the outcome list.
 // For each result, we use PR URL + Action + Reason, sort them, and hash the joined list.
 func hashResults(results []prOutcome) string {
@@ -1248,16 +3514,41 @@ func saveState(path, hash string) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+// shouldPostRun is shouldPostToDiscord against org's state in the history
+// DB h, or the state file when there's no DB.
+func shouldPostRun(h *historyDB, org, statePath, currentHash string) (bool, string) {
+	if h == nil {
+		return shouldPostToDiscord(statePath, currentHash)
+	}
+	state, err := h.LoadState(org)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[dedup] failed to read the history db: %v\n", err)
+	}
+	return shouldPostState(state, currentHash)
+}
+
+// saveRunState is saveState to org's state in the history DB h, or the
+// state file when there's no DB.
+func saveRunState(h *historyDB, org, statePath, hash string) error {
+	if h == nil {
+		return saveState(statePath, hash)
+	}
+	return h.SaveState(org, hash, time.Now())
+}
+
 // shouldPostToDiscord determines whether we should post to Discord based on state.
 // Returns (true, "") if we should post, or (false, reason) if we should skip.
 func shouldPostToDiscord(statePath, currentHash string) (bool, string) {
+	return shouldPostState(loadState(statePath), currentHash)
+}
+
+// shouldPostState is shouldPostToDiscord against an already loaded state.
+func shouldPostState(state runState, currentHash string) (bool, string) {
 	// Always post if no results (empty hash)
 	if currentHash == "" {
 		return true, ""
 	}
 
-	state := loadState(statePath)
-
 	// No prior state - always post
 	if state.Hash == "" {
 		return true, ""
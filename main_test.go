@@ -42,6 +42,16 @@ func TestSummarize_lint_dispatched(t *testing.T) {
 	}
 }
 
+func TestSummarize_test_dispatched(t *testing.T) {
+	results := []prOutcome{
+		{Action: "test_dispatched"},
+	}
+	merged, commented, skipped, errs := summarize(results)
+	if merged != 0 || commented != 1 || skipped != 0 || errs != 0 {
+		t.Errorf("expected only commented=1, got merged=%d commented=%d skipped=%d errs=%d", merged, commented, skipped, errs)
+	}
+}
+
 func TestSummarize_ciFailureType(t *testing.T) {
 	// Tests that CIFailureType is populated (via classifyCIFailure integration)
 	entries := []statusRollupEntry{
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// GitHub computes mergeability in the background and reports UNKNOWN until
+// it's done. We re-fetch up to mergeablePollAttempts times, waiting
+// mergeablePollInterval before each, before treating UNKNOWN as a blocker.
+const (
+	mergeablePollAttempts = 3
+	mergeablePollInterval = 10 * time.Second
+)
+
+// mergeablePollSleep waits between re-fetches, returning early if ctx ends
+// (replaced in tests).
+var mergeablePollSleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// mergeableUnknown reports whether GitHub hasn't decided the PR's mergeability yet.
+func mergeableUnknown(pr *prView) bool {
+	return strings.EqualFold(strings.TrimSpace(pr.Mergeable), "UNKNOWN")
+}
+
+// awaitMergeable re-fetches a PR while its mergeability is UNKNOWN and
+// returns the freshest view. A failed re-fetch or an expired ctx stops the
+// poll early; the caller then decides on what it has.
+func awaitMergeable(ctx context.Context, view *prView, fetch func() (*prView, error)) *prView {
+	for i := 0; i < mergeablePollAttempts && mergeableUnknown(view); i++ {
+		if err := mergeablePollSleep(ctx, mergeablePollInterval); err != nil {
+			return view
+		}
+		next, err := fetch()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[mergeable] re-fetch of %s failed: %v\n", view.URL, err)
+			return view
+		}
+		view = next
+	}
+	return view
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func stubMergeablePollSleep(t *testing.T) *int {
+	t.Helper()
+	sleeps := 0
+	orig := mergeablePollSleep
+	mergeablePollSleep = func(ctx context.Context, d time.Duration) error {
+		sleeps++
+		return ctx.Err()
+	}
+	t.Cleanup(func() { mergeablePollSleep = orig })
+	return &sleeps
+}
+
+func TestAwaitMergeable(t *testing.T) {
+	t.Run("resolves after a re-fetch", func(t *testing.T) {
+		sleeps := stubMergeablePollSleep(t)
+		fetches := 0
+		got := awaitMergeable(context.Background(), &prView{Mergeable: "UNKNOWN"}, func() (*prView, error) {
+			fetches++
+			if fetches < 2 {
+				return &prView{Mergeable: "UNKNOWN"}, nil
+			}
+			return &prView{Mergeable: "MERGEABLE"}, nil
+		})
+		if got.Mergeable != "MERGEABLE" || fetches != 2 || *sleeps != 2 {
+			t.Errorf("mergeable=%s fetches=%d sleeps=%d", got.Mergeable, fetches, *sleeps)
+		}
+	})
+
+	t.Run("gives up after the attempt limit", func(t *testing.T) {
+		stubMergeablePollSleep(t)
+		fetches := 0
+		got := awaitMergeable(context.Background(), &prView{Mergeable: "UNKNOWN"}, func() (*prView, error) {
+			fetches++
+			return &prView{Mergeable: "UNKNOWN"}, nil
+		})
+		if got.Mergeable != "UNKNOWN" || fetches != mergeablePollAttempts {
+			t.Errorf("mergeable=%s fetches=%d", got.Mergeable, fetches)
+		}
+	})
+
+	t.Run("known mergeability isn't re-fetched", func(t *testing.T) {
+		stubMergeablePollSleep(t)
+		got := awaitMergeable(context.Background(), &prView{Mergeable: "CONFLICTING"}, func() (*prView, error) {
+			t.Fatal("unexpected fetch")
+			return nil, nil
+		})
+		if got.Mergeable != "CONFLICTING" {
+			t.Errorf("mergeable=%s", got.Mergeable)
+		}
+	})
+
+	t.Run("fetch error keeps the last view", func(t *testing.T) {
+		stubMergeablePollSleep(t)
+		view := &prView{Mergeable: "UNKNOWN", URL: "u"}
+		got := awaitMergeable(context.Background(), view, func() (*prView, error) {
+			return nil, errors.New("boom")
+		})
+		if got != view {
+			t.Errorf("got %+v; want the original view", got)
+		}
+	})
+
+	t.Run("cancelled context stops polling", func(t *testing.T) {
+		stubMergeablePollSleep(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		got := awaitMergeable(ctx, &prView{Mergeable: "UNKNOWN"}, func() (*prView, error) {
+			t.Fatal("unexpected fetch")
+			return nil, nil
+		})
+		if got.Mergeable != "UNKNOWN" {
+			t.Errorf("mergeable=%s", got.Mergeable)
+		}
+	})
+}
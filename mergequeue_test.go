@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestIsMergeQueueRequiredError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("Changes must be made through the merge queue"), true},
+		{errors.New("Pull request is not mergeable"), false},
+	}
+	for _, tt := range tests {
+		if got := isMergeQueueRequiredError(tt.err); got != tt.want {
+			t.Errorf("isMergeQueueRequiredError(%v) = %v; want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestEnqueueMutationResponse(t *testing.T) {
+	raw := `{"data":{"enqueuePullRequest":{"mergeQueueEntry":{"position":3}}}}`
+	var resp enqueueMutationResponse
+	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if got := resp.Data.EnqueuePullRequest.MergeQueueEntry.Position; got != 3 {
+		t.Errorf("expected position 3, got %d", got)
+	}
+}
+
+func TestSummarize_enqueued(t *testing.T) {
+	merged, _, _, _ := summarize([]prOutcome{{Action: "enqueued"}})
+	if merged != 1 {
+		t.Errorf("expected merged=1, got %d", merged)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// mergeWindow limits when the pipeline merges, e.g. weekdays 09:00–17:00
+// UTC. Empty Days means every day; empty Start and End mean all day. A
+// window whose End is before its Start runs overnight.
+type mergeWindow struct {
+	Days     []string `json:"days,omitempty"`     // "mon".."sun"
+	Start    string   `json:"start,omitempty"`    // "HH:MM"
+	End      string   `json:"end,omitempty"`      // "HH:MM", exclusive
+	Timezone string   `json:"timezone,omitempty"` // IANA name, default UTC
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseClock parses "HH:MM" as minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q (want HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w *mergeWindow) validate() error {
+	for _, d := range w.Days {
+		if _, ok := weekdayNames[strings.ToLower(strings.TrimSpace(d))]; !ok {
+			return fmt.Errorf("unknown day %q (want mon..sun)", d)
+		}
+	}
+	if (w.Start == "") != (w.End == "") {
+		return fmt.Errorf("start and end go together")
+	}
+	if w.Start != "" {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return err
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return err
+		}
+		if start == end {
+			return fmt.Errorf("start and end are both %s", w.Start)
+		}
+	}
+	if _, err := w.location(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// location returns the window's time zone.
+func (w *mergeWindow) location() (*time.Location, error) {
+	if w == nil || strings.TrimSpace(w.Timezone) == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(strings.TrimSpace(w.Timezone))
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", w.Timezone)
+	}
+	return loc, nil
+}
+
+// allows reports whether t falls inside the window. An overnight window
+// belongs to the day it starts on. A nil window allows any time.
+func (w *mergeWindow) allows(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	loc, err := w.location()
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+	day, now := t.Weekday(), t.Hour()*60+t.Minute()
+	if w.Start != "" {
+		start, _ := parseClock(w.Start)
+		end, _ := parseClock(w.End)
+		switch {
+		case start < end && (now < start || now >= end):
+			return false
+		case start > end && now < start && now >= end:
+			return false
+		case start > end && now < end:
+			day = (day + 6) % 7 // the early hours belong to yesterday's window
+		}
+	}
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdayNames[strings.ToLower(strings.TrimSpace(d))] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeWindowFor returns the merge window for repo: the repo policy's if it
+// has one, else the top-level one, else nil (merge any time).
+func (c *pipelineConfig) mergeWindowFor(repo string) *mergeWindow {
+	if c == nil {
+		return nil
+	}
+	if w := c.repoPolicyFor(repo).MergeWindow; w != nil {
+		return w
+	}
+	return c.MergeWindow
+}
+
+// inMergeWindow reports whether the PR may merge at now: inside the repo's
+// merge window and, for a PR with the no-weekend label, not on a Saturday
+// or Sunday in the window's time zone.
+func inMergeWindow(cfg *pipelineConfig, repo string, labels []label, noWeekendLabel string, now time.Time) bool {
+	w := cfg.mergeWindowFor(repo)
+	if !w.allows(now) {
+		return false
+	}
+	if hasLabel(labels, noWeekendLabel) {
+		loc, err := w.location()
+		if err != nil {
+			return false
+		}
+		if d := now.In(loc).Weekday(); d == time.Saturday || d == time.Sunday {
+			return false
+		}
+	}
+	return true
+}
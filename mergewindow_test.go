@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMergeWindowAllows(t *testing.T) {
+	weekdays := &mergeWindow{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00"}
+	overnight := &mergeWindow{Days: []string{"fri"}, Start: "22:00", End: "02:00"}
+	berlin := &mergeWindow{Start: "09:00", End: "17:00", Timezone: "Europe/Berlin"}
+	// 2025-01-15 is a Wednesday.
+	at := func(day int, hhmm string) time.Time {
+		t, _ := time.Parse("2006-01-02 15:04", "2025-01-"+[]string{"15", "16", "17", "18", "19"}[day-15]+" "+hhmm)
+		return t
+	}
+	tests := []struct {
+		name string
+		w    *mergeWindow
+		t    time.Time
+		want bool
+	}{
+		{"no window", nil, at(18, "03:00"), true},
+		{"weekday inside", weekdays, at(15, "09:00"), true},
+		{"weekday at end", weekdays, at(15, "17:00"), false},
+		{"weekday before start", weekdays, at(15, "08:59"), false},
+		{"saturday", weekdays, at(18, "12:00"), false},
+		{"overnight on its day", overnight, at(17, "23:00"), true},
+		{"overnight early hours belong to friday", overnight, at(18, "01:30"), true},
+		{"overnight early hours of friday belong to thursday", overnight, at(17, "01:30"), false},
+		{"overnight gap", overnight, at(18, "03:00"), false},
+		{"timezone", berlin, at(15, "08:30"), true}, // 09:30 in Berlin
+		{"timezone late", berlin, at(15, "16:30"), false},
+	}
+	for _, tt := range tests {
+		if got := tt.w.allows(tt.t); got != tt.want {
+			t.Errorf("%s: allows(%s) = %v; want %v", tt.name, tt.t.Format(time.RFC3339), got, tt.want)
+		}
+	}
+}
+
+func TestMergeWindowValidate(t *testing.T) {
+	for _, w := range []mergeWindow{
+		{Days: []string{"monday"}},
+		{Start: "09:00"},
+		{Start: "9am", End: "17:00"},
+		{Start: "09:00", End: "09:00"},
+		{Timezone: "Mars/Olympus"},
+	} {
+		if err := w.validate(); err == nil {
+			t.Errorf("validate(%+v) = nil; want an error", w)
+		}
+	}
+	if err := (&mergeWindow{Days: []string{"Mon", "fri"}, Start: "22:00", End: "02:00", Timezone: "America/New_York"}).validate(); err != nil {
+		t.Errorf("valid window: %v", err)
+	}
+}
+
+func TestInMergeWindow(t *testing.T) {
+	cfg := &pipelineConfig{
+		MergeWindow: &mergeWindow{Start: "09:00", End: "17:00"},
+		Repos:       map[string]repoPolicy{"misty-step/ops": {MergeWindow: &mergeWindow{Days: []string{"tue"}}}},
+	}
+	wednesdayNoon := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	if !inMergeWindow(cfg, "misty-step/api", nil, "no-weekend-merge", wednesdayNoon) {
+		t.Error("top-level window should allow Wednesday noon")
+	}
+	if inMergeWindow(cfg, "misty-step/ops", nil, "no-weekend-merge", wednesdayNoon) {
+		t.Error("repo window (Tuesdays) should replace the top-level one")
+	}
+	saturdayNoon := wednesdayNoon.Add(3 * 24 * time.Hour)
+	labels := []label{{Name: "no-weekend-merge"}}
+	if !inMergeWindow(cfg, "misty-step/api", nil, "no-weekend-merge", saturdayNoon) {
+		t.Error("weekend without the label should be allowed")
+	}
+	if inMergeWindow(cfg, "misty-step/api", labels, "no-weekend-merge", saturdayNoon) {
+		t.Error("weekend with the label should be blocked")
+	}
+	if !inMergeWindow(nil, "misty-step/api", labels, "no-weekend-merge", wednesdayNoon) {
+		t.Error("weekday with the label should be allowed")
+	}
+}
+
+func TestPipelineDefersOutsideMergeWindow(t *testing.T) {
+	ready := fakePR("misty-step/api", 1)
+	failing := fakePR("misty-step/api", 2)
+	failing.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "lint", Status: "COMPLETED", Conclusion: "FAILURE"}}
+	weekend := fakePR("misty-step/web", 3)
+	weekend.Labels = []label{{Name: "no-weekend-merge"}}
+	fake := newFakeGitHub(ready, failing, weekend)
+	now := time.Date(2025, 1, 18, 20, 0, 0, 0, time.UTC) // Saturday evening
+	for i, pr := range fake.prs {
+		fake.updatedAt[pr.URL] = now.Add(-time.Duration(i) * time.Minute)
+	}
+
+	opts := testPipelineOptions(t)
+	opts.config = &pipelineConfig{Repos: map[string]repoPolicy{"misty-step/api": {MergeWindow: &mergeWindow{Start: "09:00", End: "17:00"}}}}
+	p := newPipeline(opts)
+	p.client = fake
+	p.now = func() time.Time { return now }
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range out.Results {
+		got = append(got, r.Action+"/"+r.Reason)
+	}
+	want := []string{"skipped/outside_merge_window", "lint_dispatched/checks_failure", "skipped/outside_merge_window"}
+	if !slices.Equal(got, want) {
+		t.Errorf("results = %v; want %v", got, want)
+	}
+	if len(fake.merged) > 0 {
+		t.Errorf("merged %v outside the window", fake.merged)
+	}
+}
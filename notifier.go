@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Notifier is a destination for run reports and run-level alerts.
+type Notifier interface {
+	// Post sends the summary of a finished run.
+	Post(ctx context.Context, out runOutput) error
+	// Alert sends a run-level error, such as a failed scan.
+	Alert(ctx context.Context, msg string) error
+}
+
+// notifierRegistry lists the report backends, in the order they're posted
+// to. Each builder returns nil when its backend isn't configured, so any
+// combination can be enabled at once.
+var notifierRegistry = []struct {
+	name  string
+	build func(o *runOptions) (Notifier, error)
+}{
+	{"discord", newDiscordNotifier},
+	{"slack", newSlackNotifier},
+	{"webhook", newWebhookNotifier},
+	{"email", newEmailNotifier},
+	{"log", newLogNotifier},
+}
+
+// buildNotifiers returns a fan-out over every configured backend.
+func buildNotifiers(o *runOptions) (fanoutNotifier, error) {
+	var fan fanoutNotifier
+	for _, r := range notifierRegistry {
+		n, err := r.build(o)
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			fan = append(fan, namedNotifier{name: r.name, Notifier: n})
+		}
+	}
+	return fan, nil
+}
+
+type namedNotifier struct {
+	name string
+	Notifier
+}
+
+// fanoutNotifier sends to every backend, with secrets redacted. One
+// backend failing doesn't stop the others; the failures are joined, each
+// prefixed with its backend.
+type fanoutNotifier []namedNotifier
+
+func (f fanoutNotifier) Post(ctx context.Context, out runOutput) error {
+	out.Error, out.Aborted = redact(out.Error), redact(out.Aborted)
+	var errs []error
+	for _, n := range f {
+		if err := n.Post(ctx, out); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanoutNotifier) Alert(ctx context.Context, msg string) error {
+	msg = redact(msg)
+	var errs []error
+	for _, n := range f {
+		if err := n.Alert(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shouldReport applies the --post-empty and --post-dry-run rules. A run
+// frozen by the kill switch is reported regardless, so the channel shows the
+// freeze.
+func shouldReport(out runOutput, postEmpty bool, postDryRun bool) bool {
+	if out.DryRun && !postDryRun && out.Frozen == "" {
+		return false
+	}
+	return len(out.Results) > 0 || postEmpty
+}
+
+// notifyAlert sends a run-level alert, logging failures, and reports
+// whether it went out.
+func notifyAlert(ctx context.Context, n Notifier, msg string) bool {
+	if err := n.Alert(ctx, msg); err != nil {
+		fmt.Fprintf(os.Stderr, "[notify] alert failed: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// discordNotifier posts the summary to --discord-report-to and errors to
+// --discord-alerts-to.
+type discordNotifier struct {
+	reportTo string
+	alertsTo string
+}
+
+func newDiscordNotifier(o *runOptions) (Notifier, error) {
+	n := &discordNotifier{
+		reportTo: normalizeDiscordTarget(o.DiscordReportTo),
+		alertsTo: normalizeDiscordTarget(o.DiscordAlertsTo),
+	}
+	if n.reportTo == "" && n.alertsTo == "" {
+		return nil, nil
+	}
+	return n, nil
+}
+
+func (n *discordNotifier) Post(ctx context.Context, out runOutput) error {
+	token := strings.TrimSpace(discordBotToken())
+	if token == "" {
+		return errors.New("DISCORD_BOT_TOKEN missing (needed for Discord posting)")
+	}
+
+	merged, commented, skipped, errs := summarize(out.Results)
+
+	var postErr error
+	if n.reportTo != "" {
+		postErr = discordSendEmbeds(ctx, token, n.reportTo, renderDiscordEmbeds(out, merged, commented, skipped, errs))
+	}
+	if postErr != nil {
+		// Best-effort alert.
+		if n.alertsTo != "" && n.alertsTo != n.reportTo {
+			_ = discordSendMessage(ctx, token, n.alertsTo, "PR pipeline: failed to post report: "+postErr.Error())
+		}
+		return postErr
+	}
+
+	// Separate alert ping on errors (avoid duplication if report already includes it in same channel).
+	if errs > 0 && n.alertsTo != "" && n.alertsTo != n.reportTo {
+		if err := discordSendEmbeds(ctx, token, n.alertsTo, renderDiscordAlertEmbeds(out, errs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *discordNotifier) Alert(ctx context.Context, msg string) error {
+	token := strings.TrimSpace(discordBotToken())
+	if n.alertsTo == "" || token == "" {
+		return nil
+	}
+	return discordSendMessage(ctx, token, n.alertsTo, "PR pipeline error: "+msg)
+}
+
+// slackWebhookEnv holds the Slack incoming webhook URL; it's a secret, so
+// it isn't taken as a flag value.
+const slackWebhookEnv = "SLACK_WEBHOOK_URL"
+
+// slackMaxLines caps the PR lines in a Slack summary.
+const slackMaxLines = 50
+
+// slackNotifier posts to a Slack incoming webhook.
+type slackNotifier struct {
+	url string
+}
+
+func newSlackNotifier(o *runOptions) (Notifier, error) {
+	if !o.SlackReport {
+		return nil, nil
+	}
+	url := strings.TrimSpace(os.Getenv(slackWebhookEnv))
+	if url == "" {
+		return nil, fmt.Errorf("--slack-report requires %s", slackWebhookEnv)
+	}
+	return &slackNotifier{url: url}, nil
+}
+
+func (n *slackNotifier) Post(ctx context.Context, out runOutput) error {
+	return postJSON(ctx, n.url, map[string]string{"text": renderSlackSummary(out)})
+}
+
+func (n *slackNotifier) Alert(ctx context.Context, msg string) error {
+	return postJSON(ctx, n.url, map[string]string{"text": ":rotating_light: PR pipeline error: " + msg})
+}
+
+// renderSlackSummary renders the run as Slack mrkdwn: the totals, then one
+// linked line per PR.
+func renderSlackSummary(out runOutput) string {
+	merged, commented, skipped, errs := summarize(out.Results)
+	title := "*PR pipeline run*"
+	if out.DryRun {
+		title = "*PR pipeline run (dry run)*"
+	}
+	lines := []string{
+		title,
+		fmt.Sprintf("merged `%d` · commented `%d` · skipped `%d` · errors `%d`", merged, commented, skipped, errs),
+	}
+	if out.Error != "" {
+		lines = append(lines, "Run error: "+out.Error)
+	}
+	if out.Aborted != "" {
+		lines = append(lines, "Run aborted: "+out.Aborted)
+	}
+	if out.Frozen != "" {
+		lines = append(lines, "Frozen: "+out.Frozen)
+	}
+	for i, r := range out.Results {
+		if i == slackMaxLines {
+			lines = append(lines, fmt.Sprintf("…and %d more", len(out.Results)-slackMaxLines))
+			break
+		}
+		line := fmt.Sprintf("<%s|%s#%d> `%s`", r.URL, r.Repo, r.Number, r.Action)
+		if r.Reason != "" {
+			line += " " + r.Reason
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// webhookNotifier POSTs the run output as JSON to --notify-webhook.
+type webhookNotifier struct {
+	url string
+}
+
+func newWebhookNotifier(o *runOptions) (Notifier, error) {
+	url := strings.TrimSpace(o.NotifyWebhook)
+	if url == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, fmt.Errorf("--notify-webhook must be an http(s) URL, got %q", url)
+	}
+	return &webhookNotifier{url: url}, nil
+}
+
+func (n *webhookNotifier) Post(ctx context.Context, out runOutput) error {
+	return postJSON(ctx, n.url, map[string]any{"event": "run", "run": out})
+}
+
+func (n *webhookNotifier) Alert(ctx context.Context, msg string) error {
+	return postJSON(ctx, n.url, map[string]any{"event": "alert", "message": msg})
+}
+
+// postJSON POSTs v as JSON and fails on a non-2xx response.
+func postJSON(ctx context.Context, url string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "misty-step/factory/pr-pipeline")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		msg := strings.TrimSpace(string(raw))
+		if msg == "" {
+			msg = resp.Status
+		}
+		return fmt.Errorf("POST failed (%d): %s", resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// logNotifier writes a plain-text summary to stderr, for runs watched from
+// a terminal or a log collector. Stdout is reserved for the JSON output.
+type logNotifier struct {
+	w io.Writer
+}
+
+func newLogNotifier(o *runOptions) (Notifier, error) {
+	if !o.NotifyLog {
+		return nil, nil
+	}
+	return &logNotifier{w: os.Stderr}, nil
+}
+
+func (n *logNotifier) Post(ctx context.Context, out runOutput) error {
+	merged, commented, skipped, errs := summarize(out.Results)
+	fmt.Fprintf(n.w, "[report] merged=%d commented=%d skipped=%d errors=%d\n", merged, commented, skipped, errs)
+	for _, r := range out.Results {
+		line := fmt.Sprintf("[report] %s %s", r.URL, r.Action)
+		if r.Reason != "" {
+			line += " " + r.Reason
+		}
+		fmt.Fprintln(n.w, line)
+	}
+	return nil
+}
+
+func (n *logNotifier) Alert(ctx context.Context, msg string) error {
+	_, err := fmt.Fprintf(n.w, "[alert] PR pipeline error: %s\n", msg)
+	return err
+}
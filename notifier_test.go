@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeNotifier records what it was sent.
+type fakeNotifier struct {
+	posts  []runOutput
+	alerts []string
+	err    error
+}
+
+func (f *fakeNotifier) Post(ctx context.Context, out runOutput) error {
+	f.posts = append(f.posts, out)
+	return f.err
+}
+
+func (f *fakeNotifier) Alert(ctx context.Context, msg string) error {
+	f.alerts = append(f.alerts, msg)
+	return f.err
+}
+
+func TestFanoutNotifier(t *testing.T) {
+	broken := &fakeNotifier{err: errors.New("down")}
+	ok := &fakeNotifier{}
+	fan := fanoutNotifier{{name: "discord", Notifier: broken}, {name: "email", Notifier: ok}}
+
+	err := fan.Post(context.Background(), runOutput{Org: "misty-step"})
+	if err == nil || !strings.Contains(err.Error(), "discord: down") {
+		t.Errorf("err = %v; want the discord failure", err)
+	}
+	if len(ok.posts) != 1 {
+		t.Errorf("a failing backend should not stop the others")
+	}
+	if err := fan.Alert(context.Background(), "scan failed"); err == nil {
+		t.Error("expected the alert failure")
+	}
+	if len(ok.alerts) != 1 || ok.alerts[0] != "scan failed" {
+		t.Errorf("alerts = %v", ok.alerts)
+	}
+	if err := (fanoutNotifier(nil)).Post(context.Background(), runOutput{}); err != nil {
+		t.Errorf("empty fan-out: %v", err)
+	}
+}
+
+func TestBuildNotifiers(t *testing.T) {
+	t.Setenv("SLACK_WEBHOOK_URL", "")
+	fan, err := buildNotifiers(&runOptions{})
+	if err != nil || len(fan) != 0 {
+		t.Fatalf("nothing configured: %v, %v", fan, err)
+	}
+	if _, err := buildNotifiers(&runOptions{SlackReport: true}); err == nil {
+		t.Error("--slack-report without SLACK_WEBHOOK_URL should fail")
+	}
+	if _, err := buildNotifiers(&runOptions{NotifyWebhook: "ftp://x"}); err == nil {
+		t.Error("non-http webhook should fail")
+	}
+
+	t.Setenv("SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/x")
+	fan, err = buildNotifiers(&runOptions{DiscordReportTo: "channel:1", SlackReport: true, NotifyWebhook: "https://example.com/hook", NotifyLog: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, n := range fan {
+		names = append(names, n.name)
+	}
+	if got := strings.Join(names, ","); got != "discord,slack,webhook,log" {
+		t.Errorf("backends = %s", got)
+	}
+}
+
+func TestShouldReport(t *testing.T) {
+	some := runOutput{Results: []prOutcome{{Action: "merged"}}}
+	dry := runOutput{DryRun: true, Results: some.Results}
+	cases := []struct {
+		out                   runOutput
+		postEmpty, postDryRun bool
+		want                  bool
+	}{
+		{some, false, false, true},
+		{runOutput{}, false, false, false},
+		{runOutput{}, true, false, true},
+		{dry, false, false, false},
+		{dry, false, true, true},
+	}
+	for i, c := range cases {
+		if got := shouldReport(c.out, c.postEmpty, c.postDryRun); got != c.want {
+			t.Errorf("case %d: got %v; want %v", i, got, c.want)
+		}
+	}
+}
+
+func TestSlackAndWebhookNotifiers(t *testing.T) {
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var v map[string]any
+		_ = json.Unmarshal(raw, &v)
+		bodies = append(bodies, v)
+	}))
+	defer srv.Close()
+
+	out := runOutput{Ok: true, Results: []prOutcome{{Repo: "misty-step/a", Number: 3, URL: "https://github.com/misty-step/a/pull/3", Action: "merged"}}}
+	if err := (&slackNotifier{url: srv.URL}).Post(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&webhookNotifier{url: srv.URL}).Post(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&webhookNotifier{url: srv.URL}).Alert(context.Background(), "scan failed"); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 3 {
+		t.Fatalf("got %d requests; want 3", len(bodies))
+	}
+	if text, _ := bodies[0]["text"].(string); !strings.Contains(text, "<https://github.com/misty-step/a/pull/3|misty-step/a#3> `merged`") {
+		t.Errorf("slack text = %q", text)
+	}
+	if bodies[1]["event"] != "run" || bodies[1]["run"] == nil {
+		t.Errorf("webhook run body = %v", bodies[1])
+	}
+	if bodies[2]["event"] != "alert" || bodies[2]["message"] != "scan failed" {
+		t.Errorf("webhook alert body = %v", bodies[2])
+	}
+}
+
+func TestLogNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	n := &logNotifier{w: &buf}
+	_ = n.Post(context.Background(), runOutput{Results: []prOutcome{{URL: "https://github.com/o/r/pull/1", Action: "skipped", Reason: "draft"}}})
+	_ = n.Alert(context.Background(), "boom")
+	got := buf.String()
+	for _, want := range []string{"[report] merged=0 commented=0 skipped=1 errors=0", "[report] https://github.com/o/r/pull/1 skipped draft", "[alert] PR pipeline error: boom"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// notifyAuthor sends an alert about a PR that needs its author's attention.
+// Authors mapped in the config's discordUsers are mentioned in the alerts
+// channel, or with --discord-dm-authors sent the alert as a DM; anyone else
+// gets the plain alert in the alerts channel.
+func notifyAuthor(ctx context.Context, opts *runOptions, login string, msg string) {
+	token := strings.TrimSpace(discordBotToken())
+	if token == "" {
+		return
+	}
+	userID := opts.config.discordUserFor(login)
+	if userID != "" && opts.DiscordDMAuthors {
+		channelID, err := discordDMChannel(ctx, token, userID)
+		if err == nil {
+			err = discordSendMessage(ctx, token, channelID, msg)
+		}
+		if err == nil {
+			return
+		}
+		// Fall back to a mention in the shared channel.
+		fmt.Fprintf(os.Stderr, "[notify] DM to %s failed: %v\n", login, err)
+	}
+	alertsTo := normalizeDiscordTarget(opts.DiscordAlertsTo)
+	if alertsTo == "" {
+		return
+	}
+	if userID != "" {
+		msg = "<@" + userID + "> " + msg
+	}
+	if err := discordSendMessage(ctx, token, alertsTo, msg); err != nil {
+		fmt.Fprintf(os.Stderr, "[notify] alert for %s failed: %v\n", login, err)
+	}
+}
+
+// discordDMChannel opens (or reuses) the bot's DM channel with a user and
+// returns its ID.
+func discordDMChannel(ctx context.Context, token string, userID string) (string, error) {
+	b, err := json.Marshal(map[string]string{"recipient_id": userID})
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", discordAPIBase+"/users/@me/channels", bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bot "+strings.TrimSpace(token))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "misty-step/factory/pr-pipeline")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(raw))
+		if msg == "" {
+			msg = resp.Status
+		}
+		return "", fmt.Errorf("discord DM channel failed (%d): %s", resp.StatusCode, msg)
+	}
+	var ch struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(raw, &ch); err != nil {
+		return "", fmt.Errorf("parse discord DM channel: %w", err)
+	}
+	if ch.ID == "" {
+		return "", errors.New("discord DM channel has no id")
+	}
+	return ch.ID, nil
+}
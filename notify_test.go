@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDiscord records messages posted per channel; DM channels are "dm-<user>".
+type fakeDiscord struct {
+	mu       sync.Mutex
+	messages map[string][]string
+	failDM   bool
+}
+
+func newFakeDiscord(t *testing.T) *fakeDiscord {
+	t.Helper()
+	f := &fakeDiscord{messages: make(map[string][]string)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/@me/channels" {
+			if f.failDM {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"message":"Cannot send messages to this user"}`))
+				return
+			}
+			var body struct {
+				RecipientID string `json:"recipient_id"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			_, _ = w.Write([]byte(`{"id":"dm-` + body.RecipientID + `"}`))
+			return
+		}
+		channel, ok := strings.CutPrefix(r.URL.Path, "/channels/")
+		channel, ok2 := strings.CutSuffix(channel, "/messages")
+		if !ok || !ok2 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var body struct {
+			Content string `json:"content"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		f.messages[channel] = append(f.messages[channel], body.Content)
+		f.mu.Unlock()
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(srv.Close)
+	old := discordAPIBase
+	discordAPIBase = srv.URL
+	t.Cleanup(func() { discordAPIBase = old })
+	t.Setenv("DISCORD_BOT_TOKEN_AMOS", "")
+	t.Setenv("DISCORD_BOT_TOKEN", "tok")
+	return f
+}
+
+func TestNotifyAuthor(t *testing.T) {
+	cfg := &pipelineConfig{DiscordUsers: map[string]string{"Alice": "111"}}
+
+	t.Run("mapped author is mentioned in the alerts channel", func(t *testing.T) {
+		f := newFakeDiscord(t)
+		notifyAuthor(context.Background(), &runOptions{config: cfg, DiscordAlertsTo: "channel:999"}, "alice", "PR needs work")
+		if got := f.messages["999"]; len(got) != 1 || got[0] != "<@111> PR needs work" {
+			t.Errorf("alerts channel got %q", got)
+		}
+	})
+
+	t.Run("unmapped author gets the plain alert", func(t *testing.T) {
+		f := newFakeDiscord(t)
+		notifyAuthor(context.Background(), &runOptions{config: cfg, DiscordAlertsTo: "999", DiscordDMAuthors: true}, "bob", "PR needs work")
+		if got := f.messages["999"]; len(got) != 1 || got[0] != "PR needs work" {
+			t.Errorf("alerts channel got %q", got)
+		}
+	})
+
+	t.Run("DM mode messages the author directly", func(t *testing.T) {
+		f := newFakeDiscord(t)
+		notifyAuthor(context.Background(), &runOptions{config: cfg, DiscordAlertsTo: "999", DiscordDMAuthors: true}, "alice", "PR needs work")
+		if got := f.messages["dm-111"]; len(got) != 1 || got[0] != "PR needs work" {
+			t.Errorf("DM got %q", got)
+		}
+		if got := f.messages["999"]; len(got) != 0 {
+			t.Errorf("alerts channel should be quiet, got %q", got)
+		}
+	})
+
+	t.Run("failed DM falls back to a mention", func(t *testing.T) {
+		f := newFakeDiscord(t)
+		f.failDM = true
+		notifyAuthor(context.Background(), &runOptions{config: cfg, DiscordAlertsTo: "999", DiscordDMAuthors: true}, "alice", "PR needs work")
+		if got := f.messages["999"]; len(got) != 1 || got[0] != "<@111> PR needs work" {
+			t.Errorf("alerts channel got %q", got)
+		}
+	})
+}
+
+func TestConfigDiscordUsers(t *testing.T) {
+	cfg := &pipelineConfig{DiscordUsers: map[string]string{"alice": " 111 "}}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if got := cfg.discordUserFor("ALICE"); got != "111" {
+		t.Errorf("discordUserFor = %q; want 111", got)
+	}
+	if got := (*pipelineConfig)(nil).discordUserFor("alice"); got != "" {
+		t.Errorf("nil config: %q", got)
+	}
+	bad := &pipelineConfig{DiscordUsers: map[string]string{"alice": "@alice"}}
+	if err := bad.validate(); err == nil {
+		t.Error("expected an error for a non-numeric Discord user ID")
+	}
+}
+
+func TestPipelineFixAlertFollowsTheComment(t *testing.T) {
+	discord := newFakeDiscord(t)
+	pr := fakePR("misty-step/api", 1)
+	pr.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "test", Status: "COMPLETED", Conclusion: "FAILURE"}}
+	fake := newFakeGitHub(pr)
+	useFakeGitHub(t, fake)
+
+	run := func(args ...string) (prOutcome, int) {
+		t.Helper()
+		opts := testPipelineOptions(t, append([]string{"-discord-alerts-to", "channel:999"}, args...)...)
+		out, err := newPipeline(opts).Run(context.Background())
+		if err != nil || len(out.Results) != 1 {
+			t.Fatalf("Run() = %+v, %v", out.Results, err)
+		}
+		alerts := 0
+		for _, m := range discord.messages["999"] {
+			if strings.Contains(m, "Test failure") {
+				alerts++
+			}
+		}
+		return out.Results[0], alerts
+	}
+
+	if r, alerts := run("-dry-run"); r.Action != "skipped" || alerts != 0 {
+		t.Errorf("dry run: %s, %d alerts; want a skip and none", r.Action, alerts)
+	}
+	if r, alerts := run(); r.Action != "test_dispatched" || alerts != 1 {
+		t.Errorf("first run: %s, %d alerts; want test_dispatched and one", r.Action, alerts)
+	}
+	fake.comments[pr.URL] = []issueComment{{ID: 1, Body: fake.posted[pr.URL][0]}}
+	if r, alerts := run(); r.Action != "skipped" || alerts != 1 {
+		t.Errorf("already commented: %s/%s, %d alerts; want a skip and no new alert", r.Action, r.Reason, alerts)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestPrepareSplitsOrgs(t *testing.T) {
+	o := &runOptions{Org: "misty-step, misty-labs", MaxPRs: 1}
+	if err := o.prepare(); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if len(o.orgs) != 2 || o.orgs[0] != "misty-step" || o.orgs[1] != "misty-labs" {
+		t.Errorf("orgs = %q", o.orgs)
+	}
+
+	if err := (&runOptions{Org: " , ", MaxPRs: 1}).prepare(); err == nil {
+		t.Error("expected an error for an empty --org")
+	}
+	if err := (&runOptions{Org: "a,b", MaxPRs: 1, AppID: 1, AppKeyFile: "key.pem"}).prepare(); err == nil {
+		t.Error("expected an error for --app-id with several orgs")
+	}
+}
+
+func TestTotalsByOrg(t *testing.T) {
+	results := []prOutcome{
+		{Repo: "misty-step/a", Action: "merged"},
+		{Repo: "misty-step/b", Action: "skipped"},
+		{Repo: "Misty-Labs/c", Action: "commented"},
+		{Repo: "misty-labs/d", Action: "error"},
+	}
+	got := totalsByOrg([]string{"misty-step", "misty-labs", "idle"}, results)
+	want := []orgTotals{
+		{Org: "misty-step", Merged: 1, Skipped: 1},
+		{Org: "misty-labs", Commented: 1, Errors: 1},
+		{Org: "idle"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d orgs, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("orgs[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRenderDiscordEmbedsPerOrg(t *testing.T) {
+	out := runOutput{
+		Ok:  true,
+		Org: "misty-step,misty-labs",
+		Results: []prOutcome{
+			{URL: "https://github.com/misty-step/a/pull/1", Repo: "misty-step/a", Number: 1, Action: "merged"},
+			{URL: "https://github.com/misty-labs/b/pull/2", Repo: "misty-labs/b", Number: 2, Action: "skipped", Reason: "draft"},
+		},
+	}
+	out.Orgs = totalsByOrg([]string{"misty-step", "misty-labs"}, out.Results)
+	head := renderDiscordEmbeds(out, 1, 0, 1, 0)[0]
+	want := map[string]string{
+		"misty-step": "merged 1 · commented 0 · skipped 0 · errors 0",
+		"misty-labs": "merged 0 · commented 0 · skipped 1 · errors 0",
+	}
+	for _, f := range head.Fields {
+		if v, ok := want[f.Name]; ok {
+			if f.Value != v {
+				t.Errorf("%s totals = %q; want %q", f.Name, f.Value, v)
+			}
+			delete(want, f.Name)
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("missing per-org fields: %v", want)
+	}
+}
+
+func TestWebhookTargetsAnyOrg(t *testing.T) {
+	body := `{"action":"synchronize","repository":{"full_name":"misty-labs/app"},"pull_request":{"number":5}}`
+	targets, ignored, err := webhookTargets("pull_request", []byte(body), []string{"misty-step", "misty-labs"})
+	if err != nil || ignored != "" || len(targets) != 1 {
+		t.Errorf("targets=%v ignored=%q err=%v", targets, ignored, err)
+	}
+}
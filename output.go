@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Formats for --output. JSON stays the default so existing consumers keep
+// working; markdown and table are for people reading the result.
+const (
+	outputJSON     = "json"
+	outputMarkdown = "markdown"
+	outputTable    = "table"
+)
+
+// validOutput reports whether format is a --output value we can render.
+func validOutput(format string) bool {
+	switch format {
+	case outputJSON, outputMarkdown, outputTable:
+		return true
+	}
+	return false
+}
+
+// writeRunOutput renders a finished run to w in the --output format.
+func writeRunOutput(w io.Writer, format string, out runOutput) {
+	switch format {
+	case outputMarkdown:
+		_, _ = io.WriteString(w, renderMarkdownReport(out))
+	case outputTable:
+		writeRunTable(w, out)
+	default:
+		writeJSON(w, out)
+	}
+}
+
+// writeRunTable renders the run as an aligned terminal table: one row per
+// selected PR, then the totals.
+func writeRunTable(w io.Writer, out runOutput) {
+	if out.Error != "" {
+		fmt.Fprintf(w, "error: %s\n\n", out.Error)
+	}
+	if out.Aborted != "" {
+		fmt.Fprintf(w, "aborted: %s\n\n", out.Aborted)
+	}
+	if out.Frozen != "" {
+		fmt.Fprintf(w, "frozen: %s\n\n", out.Frozen)
+	}
+	if len(out.Results) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "PR\tAUTHOR\tACTION\tREASON")
+		for _, r := range out.Results {
+			fmt.Fprintf(tw, "%s#%d\t%s\t%s\t%s\n", r.Repo, r.Number, r.Author, r.Action, truncateEmbedLine(markdownCell(r.Reason)))
+		}
+		_ = tw.Flush()
+		fmt.Fprintln(w)
+	}
+	merged, commented, skipped, errs := summarize(out.Results)
+	dry := ""
+	if out.DryRun {
+		dry = " (dry run)"
+	}
+	fmt.Fprintf(w, "scanned %d, merged %d, commented %d, skipped %d, errors %d%s\n", out.Scanned, merged, commented, skipped, errs, dry)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteRunOutput(t *testing.T) {
+	out := runOutput{Ok: true, Org: "misty-step", Scanned: 4, Results: []prOutcome{
+		{Repo: "misty-step/api", Number: 12, Author: "kaylee", Action: "merged"},
+		{Repo: "misty-step/web", Number: 3, Author: "phaedrus", Action: "skipped", Reason: "checks_pending"},
+	}}
+
+	var buf bytes.Buffer
+	writeRunOutput(&buf, outputJSON, out)
+	var back runOutput
+	if err := json.Unmarshal(buf.Bytes(), &back); err != nil || len(back.Results) != 2 {
+		t.Errorf("json output didn't round-trip: %v\n%s", err, buf.String())
+	}
+
+	buf.Reset()
+	writeRunOutput(&buf, outputMarkdown, out)
+	if !strings.Contains(buf.String(), "| PR | Author | Action | Reason |") {
+		t.Errorf("markdown output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	writeRunOutput(&buf, outputTable, out)
+	want := "PR                 AUTHOR    ACTION   REASON\n" +
+		"misty-step/api#12  kaylee    merged   \n" +
+		"misty-step/web#3   phaedrus  skipped  checks_pending\n" +
+		"\n" +
+		"scanned 4, merged 1, commented 0, skipped 1, errors 0\n"
+	if got := buf.String(); got != want {
+		t.Errorf("table output:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestPrepareRejectsUnknownOutput(t *testing.T) {
+	o := &runOptions{Org: "misty-step", Output: "yaml"}
+	if err := o.prepare(); err == nil || !strings.Contains(err.Error(), "--output") {
+		t.Errorf("err = %v; want an --output error", err)
+	}
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ghFunc adapts a function to GitHubClient.
+type ghFunc func(ctx context.Context, stdin []byte, args ...string) ([]byte, error)
+
+func (f ghFunc) Run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	return f(ctx, stdin, args...)
+}
+
+// unusableClient fails the test if a gh call reaches it.
+type unusableClient struct{ t *testing.T }
+
+func (c unusableClient) Run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	c.t.Errorf("gh %s went to the package client, not the pipeline's", strings.Join(args, " "))
+	return nil, errors.New("unusable client (HTTP 404)")
+}
+
+func TestPipelineUsesInjectedDependencies(t *testing.T) {
+	old := githubClient
+	githubClient = unusableClient{t}
+	t.Cleanup(func() { githubClient = old })
+
+	unreviewed := fakePR("misty-step/api", 1)
+	unreviewed.ReviewDecision = "REVIEW_REQUIRED"
+	fake := newFakeGitHub(unreviewed)
+	clock := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	p := newPipeline(testPipelineOptions(t))
+	p.client = fake
+	p.now = func() time.Time { return clock }
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.StartedAt != "2025-06-01T12:00:00Z" {
+		t.Errorf("startedAt = %q; want the injected clock", out.StartedAt)
+	}
+	if len(out.Results) != 1 || out.Results[0].Action != "commented" {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	if body := fake.posted[unreviewed.URL]; len(body) != 1 || !strings.Contains(body[0], "2025-06-01") {
+		t.Errorf("comment = %q; want it stamped with the injected clock", body)
+	}
+}
+
+func TestPipelineAlertsScanFailureToNotifier(t *testing.T) {
+	alerts := &fakeNotifier{}
+	p := newPipeline(testPipelineOptions(t))
+	p.client = ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+		return nil, errors.New("gh api graphql: Could not resolve to an Organization (HTTP 404)")
+	})
+	p.notifier = alerts
+
+	if _, err := p.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "scan failed (permanent)") {
+		t.Fatalf("err = %v; want a permanent scan failure", err)
+	}
+	if len(alerts.alerts) != 1 || !strings.Contains(alerts.alerts[0], "scan failed") {
+		t.Errorf("alerts = %q", alerts.alerts)
+	}
+}
+
+func TestPipelinePRTimeout(t *testing.T) {
+	slow := fakePR("misty-step/api", 1)
+	fast := fakePR("misty-step/api", 2)
+	fake := newFakeGitHub(slow, fast)
+	now := time.Now()
+	fake.updatedAt[slow.URL] = now
+	fake.updatedAt[fast.URL] = now.Add(-time.Minute)
+
+	p := newPipeline(testPipelineOptions(t, "-pr-timeout", "50ms", "-cb-failures", "1"))
+	p.client = ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+		if len(args) > 2 && args[0] == "pr" && args[1] == "view" && args[2] == slow.URL {
+			<-ctx.Done() // a hung gh call
+			return nil, ctx.Err()
+		}
+		return fake.Run(ctx, stdin, args...)
+	})
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 2 {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	if r := out.Results[0]; r.URL != slow.URL || r.Action != "error" || !strings.HasPrefix(r.Reason, "timeout") {
+		t.Errorf("slow PR = %s/%s; want error/timeout", r.Action, r.Reason)
+	}
+	if r := out.Results[1]; r.Action != "merged" {
+		t.Errorf("fast PR = %s/%s; the run should move on and merge it", r.Action, r.Reason)
+	}
+	if !p.breaker.IsOpen(slow.URL) {
+		t.Error("the timeout should count against the slow PR's circuit breaker")
+	}
+}
+
+func TestPipelineCircuitBreakerSpansRuns(t *testing.T) {
+	t.Run("state file", func(t *testing.T) {
+		testBreakerSpansRuns(t, testPipelineOptions(t, "-pr-timeout", "20ms", "-cb-failures", "1", "-cb-skip-runs", "1"))
+	})
+	t.Run("history db", func(t *testing.T) {
+		db := filepath.Join(t.TempDir(), "history.db")
+		opts := testPipelineOptions(t, "-pr-timeout", "20ms", "-cb-failures", "1", "-cb-skip-runs", "1", "-history-db", db)
+		testBreakerSpansRuns(t, opts)
+		if _, err := os.Stat(breakerStatePath(resolveStatePath(opts.StateFile), "circuit-breaker")); !os.IsNotExist(err) {
+			t.Errorf("breaker file stat = %v; want the state kept only in the history db", err)
+		}
+	})
+}
+
+func testBreakerSpansRuns(t *testing.T, opts *runOptions) {
+	hung := fakePR("misty-step/api", 1)
+	fake := newFakeGitHub(hung)
+
+	var got []string
+	for range 6 {
+		// A fresh Pipeline per run, as the CLI and --serve both build.
+		p := newPipeline(opts)
+		p.client = ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+			if len(args) > 2 && args[0] == "pr" && args[1] == "view" {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return fake.Run(ctx, stdin, args...)
+		})
+		out, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Results) != 1 {
+			t.Fatalf("results = %+v", out.Results)
+		}
+		label := out.Results[0].Action
+		if label == "skipped" {
+			label = out.Results[0].Reason
+		}
+		got = append(got, label)
+	}
+	// The second opening skips twice as many runs as the first.
+	want := []string{"error", "circuit_breaker", "error", "circuit_breaker", "circuit_breaker", "error"}
+	if !slices.Equal(got, want) {
+		t.Errorf("runs = %q; want %q", got, want)
+	}
+}
+
+func TestPipelineRepoCircuitBreaker(t *testing.T) {
+	var prs []*prView
+	for n := 1; n <= 4; n++ {
+		prs = append(prs, fakePR("misty-step/locked", n))
+	}
+	other := fakePR("misty-step/api", 5)
+	fake := newFakeGitHub(append(prs, other)...)
+	now := time.Now()
+	for i, pr := range fake.prs {
+		fake.updatedAt[pr.URL] = now.Add(-time.Duration(i) * time.Minute)
+	}
+	for _, pr := range prs {
+		fake.mergeErrors[pr.ID] = errors.New("Resource not accessible by integration (HTTP 403)")
+	}
+
+	p := newPipeline(testPipelineOptions(t, "-max-prs", "10", "-repo-cb-failures", "2"))
+	p.client = fake
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range out.Results {
+		label := r.Action
+		if r.Action == "skipped" {
+			label = r.Reason
+		}
+		got = append(got, fmt.Sprintf("%s#%d %s", r.Repo, r.Number, label))
+	}
+	want := []string{
+		"misty-step/locked#1 error",
+		"misty-step/locked#2 error",
+		"misty-step/locked#3 repo_circuit_breaker",
+		"misty-step/locked#4 repo_circuit_breaker",
+		"misty-step/api#5 merged",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("results:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Later runs, each with a fresh Pipeline, skip the repo for
+	// -repo-cb-skip-runs (3) runs, then try it again.
+	for run := 1; run <= 4; run++ {
+		next := newPipeline(p.opts)
+		next.client = fake
+		out, err := next.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := out.Results[0]
+		if r.Repo != "misty-step/locked" {
+			t.Fatalf("run %d: first result = %+v", run, r)
+		}
+		if skipped := r.Reason == "repo_circuit_breaker"; skipped != (run <= 3) {
+			t.Errorf("run %d: %s#%d %s/%s", run, r.Repo, r.Number, r.Action, r.Reason)
+		}
+	}
+}
+
+func TestPipelineMaxRunErrors(t *testing.T) {
+	fake := newFakeGitHub()
+	now := time.Now()
+	for n := 1; n <= 6; n++ {
+		pr := fakePR(fmt.Sprintf("misty-step/repo%d", n), n)
+		fake.prs = append(fake.prs, pr)
+		fake.updatedAt[pr.URL] = now.Add(-time.Duration(n) * time.Minute)
+		fake.mergeErrors[pr.ID] = errors.New("Bad credentials (HTTP 401)")
+	}
+	alerts := &fakeNotifier{}
+
+	p := newPipeline(testPipelineOptions(t, "-max-prs", "10", "-max-run-errors", "2"))
+	p.client = fake
+	p.notifier = alerts
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 3 {
+		t.Errorf("processed %d PRs; want the run to stop at the third error", len(out.Results))
+	}
+	if !strings.Contains(out.Aborted, "3 PRs errored") {
+		t.Errorf("aborted = %q", out.Aborted)
+	}
+	if len(alerts.alerts) != 1 || !strings.Contains(alerts.alerts[0], "Bad credentials") {
+		t.Errorf("alerts = %q; want one alert with the last error", alerts.alerts)
+	}
+	if code := runExitCode(failOnNone, out); code != exitDegraded {
+		t.Errorf("exit code = %d; want %d", code, exitDegraded)
+	}
+}
+
+func TestPipelineIgnoresBotChangeRequests(t *testing.T) {
+	pr := fakePR("misty-step/api", 1)
+	pr.ReviewDecision = "CHANGES_REQUESTED"
+	pr.LatestReviews = make([]prReview, 2)
+	pr.LatestReviews[0].Author.Login, pr.LatestReviews[0].State = "coderabbitai", "CHANGES_REQUESTED"
+	pr.LatestReviews[1].Author.Login, pr.LatestReviews[1].State = "phaedrus", "APPROVED"
+	fake := newFakeGitHub(pr)
+
+	opts := testPipelineOptions(t)
+	opts.config = &pipelineConfig{IgnorableReviewers: []string{"coderabbitai"}}
+	p := newPipeline(opts)
+	p.client = fake
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 1 || out.Results[0].Action != "merged" || out.Results[0].ReviewDecision != "APPROVED" {
+		t.Errorf("results = %+v; want a merge with the effective APPROVED decision", out.Results)
+	}
+}
+
+func TestPipelineDismissesStaleReview(t *testing.T) {
+	pr := fakePR("misty-step/api", 1)
+	pr.ReviewDecision = "CHANGES_REQUESTED"
+	pr.LatestReviews = make([]prReview, 1)
+	pr.LatestReviews[0].ID, pr.LatestReviews[0].State = "PRR_1", "CHANGES_REQUESTED"
+	pr.LatestReviews[0].Author.Login = "phaedrus"
+	pr.LatestReviews[0].Commit.Oid = "0ld5ha0"
+	fake := newFakeGitHub(pr)
+
+	opts := testPipelineOptions(t)
+	opts.config = &pipelineConfig{AutoDismissReviewers: []string{"phaedrus"}}
+	p := newPipeline(opts)
+	p.client = fake
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 1 || out.Results[0].Action != "review_dismissed" || !slices.Equal(out.Results[0].DismissedReviews, []string{"phaedrus"}) {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	if !slices.Equal(fake.dismissed, []string{"PRR_1"}) || len(fake.posted) > 0 {
+		t.Errorf("dismissed = %v, posted = %v; want only the stale review dismissed", fake.dismissed, fake.posted)
+	}
+
+	// Once the reviewer looks at the new head, their review stands.
+	pr.LatestReviews[0].Commit.Oid = pr.HeadRefOid
+	fake.dismissed = nil
+	if out, err = p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.dismissed) > 0 || out.Results[0].Action != "review_dispatched" {
+		t.Errorf("action = %s, dismissed = %v; a current review shouldn't be dismissed", out.Results[0].Action, fake.dismissed)
+	}
+}
+
+func TestPipelineReportsUnresolvedThreads(t *testing.T) {
+	pr := fakePR("misty-step/api", 1)
+	pr.ReviewDecision = "CHANGES_REQUESTED"
+	fake := newFakeGitHub(pr)
+	fake.threads[pr.URL] = []reviewThread{{Path: "main.go", Line: 42, Author: "phaedrus", Body: "Handle the error."}}
+
+	p := newPipeline(testPipelineOptions(t))
+	p.client = fake
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 1 || out.Results[0].Action != "review_dispatched" {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	if got := out.Results[0].ReviewThreads; len(got) != 1 || got[0] != fake.threads[pr.URL][0] {
+		t.Errorf("reviewThreads = %+v", got)
+	}
+}
+
+func TestPipelineRequireThreadsResolved(t *testing.T) {
+	open := fakePR("misty-step/api", 1)
+	resolved := fakePR("misty-step/api", 2)
+	fake := newFakeGitHub(open, resolved)
+	now := time.Now()
+	fake.updatedAt[open.URL] = now
+	fake.updatedAt[resolved.URL] = now.Add(-time.Minute)
+	fake.threads[open.URL] = []reviewThread{{Path: "main.go", Line: 42, Author: "phaedrus", Body: "Handle the error."}}
+
+	p := newPipeline(testPipelineOptions(t, "-require-threads-resolved"))
+	p.client = fake
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 2 {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	if r := out.Results[0]; r.Action != "commented" || r.Reason != "review_threads_unresolved" || len(r.ReviewThreads) != 1 {
+		t.Errorf("PR with an open thread = %s/%s threads=%v", r.Action, r.Reason, r.ReviewThreads)
+	}
+	if body := fake.posted[open.URL]; len(body) != 1 || !strings.Contains(body[0], "`main.go:42` (@phaedrus)") {
+		t.Errorf("comment = %q; want the thread listed", body)
+	}
+	if r := out.Results[1]; r.Action != "merged" {
+		t.Errorf("PR without open threads = %s/%s; want merged", r.Action, r.Reason)
+	}
+
+	// The gate fails closed.
+	p.client = ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+		if slices.ContainsFunc(args, func(a string) bool { return strings.Contains(a, "reviewThreads(") }) {
+			return nil, errors.New("Resource not accessible by integration (HTTP 403)")
+		}
+		return fake.Run(ctx, stdin, args...)
+	})
+	fake.merged = nil
+	if out, err = p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r := out.Results[1]; r.Action != "error" || !strings.Contains(r.Reason, "review threads lookup failed") {
+		t.Errorf("lookup failure = %s/%s; want an error", r.Action, r.Reason)
+	}
+	if len(fake.merged) > 0 {
+		t.Errorf("merged %v without checking threads", fake.merged)
+	}
+}
+
+func TestPipelineSizeGate(t *testing.T) {
+	huge := fakePR("misty-step/api", 1)
+	huge.Additions, huge.Deletions, huge.ChangedFiles = 1800, 400, 12
+	wide := fakePR("misty-step/api", 2)
+	wide.Additions, wide.ChangedFiles = 60, 51
+	small := fakePR("misty-step/api", 3)
+	small.Additions, small.Deletions, small.ChangedFiles = 1500, 500, 50
+	fake := newFakeGitHub(huge, wide, small)
+	now := time.Now()
+	for i, pr := range fake.prs {
+		fake.updatedAt[pr.URL] = now.Add(-time.Duration(i) * time.Minute)
+	}
+
+	p := newPipeline(testPipelineOptions(t, "-max-merge-lines", "2000", "-max-merge-files", "50"))
+	p.client = fake
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range out.Results {
+		got = append(got, r.Action+"/"+r.Reason)
+	}
+	want := []string{"commented/pr_too_large", "commented/pr_too_large", "merged/"}
+	if !slices.Equal(got, want) {
+		t.Errorf("results = %v; want %v", got, want)
+	}
+	if body := fake.posted[huge.URL]; len(body) != 1 || !strings.Contains(body[0], "`+1800 -400` across 12 files") || !strings.Contains(body[0], "a human needs to review and merge it") {
+		t.Errorf("comment = %q", body)
+	}
+}
+
+func TestPipelineProtectedPaths(t *testing.T) {
+	workflow := fakePR("misty-step/api", 1)
+	code := fakePR("misty-step/api", 2)
+	fake := newFakeGitHub(workflow, code)
+	now := time.Now()
+	fake.updatedAt[workflow.URL] = now
+	fake.updatedAt[code.URL] = now.Add(-time.Minute)
+	fake.files[workflow.URL] = []string{"main.go", ".github/workflows/release.yml"}
+	fake.files[code.URL] = []string{"main.go"}
+
+	opts := testPipelineOptions(t)
+	opts.config = &pipelineConfig{ProtectedPaths: []string{".github/workflows/**"}}
+	p := newPipeline(opts)
+	p.client = fake
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 2 {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	if r := out.Results[0]; r.Action != "commented" || r.Reason != "protected_paths" || !slices.Equal(r.ProtectedFiles, []string{".github/workflows/release.yml"}) {
+		t.Errorf("workflow PR = %s/%s files=%v", r.Action, r.Reason, r.ProtectedFiles)
+	}
+	if body := fake.posted[workflow.URL]; len(body) != 1 || !strings.Contains(body[0], "- `.github/workflows/release.yml`") {
+		t.Errorf("comment = %q; want the protected file listed", body)
+	}
+	if r := out.Results[1]; r.Action != "merged" {
+		t.Errorf("code PR = %s/%s; want merged", r.Action, r.Reason)
+	}
+}
+
+func TestPipelineVerifyCommits(t *testing.T) {
+	hijacked := fakePR("misty-step/api", 1)
+	clean := fakePR("misty-step/api", 2)
+	fake := newFakeGitHub(hijacked, clean)
+	now := time.Now()
+	fake.updatedAt[hijacked.URL] = now
+	fake.updatedAt[clean.URL] = now.Add(-time.Minute)
+	fake.commits[hijacked.URL] = []prCommit{
+		{SHA: "1111111aaa", Author: hijacked.Author.Login, Committer: hijacked.Author.Login, Verified: true},
+		{SHA: "2222222bbb", Author: "mallory", Committer: "mallory"},
+	}
+
+	p := newPipeline(testPipelineOptions(t, "-verify-commits"))
+	p.client = fake
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 2 {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	want := []string{"2222222 (unsigned, author mallory, committer mallory)"}
+	if r := out.Results[0]; r.Action != "commented" || r.Reason != "untrusted_commits" || !slices.Equal(r.UntrustedCommits, want) {
+		t.Errorf("hijacked PR = %s/%s commits=%q", r.Action, r.Reason, r.UntrustedCommits)
+	}
+	if body := fake.posted[hijacked.URL]; len(body) != 1 || !strings.Contains(body[0], "- "+want[0]) {
+		t.Errorf("comment = %q; want the commit listed", body)
+	}
+	if r := out.Results[1]; r.Action != "merged" {
+		t.Errorf("clean PR = %s/%s; want merged", r.Action, r.Reason)
+	}
+}
+
+func TestPipelineScanSecrets(t *testing.T) {
+	old := gitleaksBin
+	gitleaksBin = "gitleaks-not-installed"
+	t.Cleanup(func() { gitleaksBin = old })
+
+	leaky := fakePR("misty-step/api", 1)
+	clean := fakePR("misty-step/api", 2)
+	fake := newFakeGitHub(leaky, clean)
+	now := time.Now()
+	fake.updatedAt[leaky.URL] = now
+	fake.updatedAt[clean.URL] = now.Add(-time.Minute)
+	fake.diffs[leaky.URL] = testDiff()
+	alerts := &fakeNotifier{}
+
+	p := newPipeline(testPipelineOptions(t, "-scan-secrets"))
+	p.client = fake
+	p.notifier = alerts
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 2 {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	if r := out.Results[0]; r.Action != "commented" || r.Reason != "possible_secret" || len(r.SecretFindings) != 2 {
+		t.Errorf("leaky PR = %s/%s findings=%q", r.Action, r.Reason, r.SecretFindings)
+	}
+	if body := fake.posted[leaky.URL]; len(body) != 1 || !strings.Contains(body[0], "`config.env:2 (aws-access-key-id)`") {
+		t.Errorf("comment = %q", body)
+	}
+	if len(alerts.alerts) != 1 || !strings.Contains(alerts.alerts[0], leaky.URL) {
+		t.Errorf("alerts = %q; want one alert for the leaky PR", alerts.alerts)
+	}
+	if r := out.Results[1]; r.Action != "merged" {
+		t.Errorf("clean PR = %s/%s; want merged", r.Action, r.Reason)
+	}
+
+	// The next run finds its comment in place and doesn't alert again.
+	fake.comments[leaky.URL] = []issueComment{{ID: 1, Body: fake.posted[leaky.URL][0]}}
+	if _, err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts.alerts) != 1 {
+		t.Errorf("alerts = %q; want no repeat alert", alerts.alerts)
+	}
+}
+
+func TestPipelineDependencyMode(t *testing.T) {
+	bot := func(n int, title string) *prView {
+		pr := fakePR("misty-step/api", n)
+		pr.Title = title
+		pr.Author.Login = "app/dependabot"
+		return pr
+	}
+	patch := bot(1, "Bump lodash from 4.17.20 to 4.17.21")
+	major := bot(2, "Bump react from 18.3.1 to 19.0.0")
+	fake := newFakeGitHub(patch, major)
+	now := time.Now()
+	fake.updatedAt[patch.URL] = now
+	fake.updatedAt[major.URL] = now.Add(-time.Minute)
+	alerts := &fakeNotifier{}
+
+	p := newPipeline(testPipelineOptions(t, "-authors", "dependabot=dependency", "-group-major-bumps"))
+	p.client = fake
+	p.notifier = alerts
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range out.Results {
+		got = append(got, r.Action+"/"+r.Reason+"/"+r.DependencyUpdate)
+	}
+	want := []string{"merged//patch", "skipped/dependency_major/major"}
+	if !slices.Equal(got, want) {
+		t.Errorf("results = %v; want %v", got, want)
+	}
+	if !slices.ContainsFunc(fake.calls, func(c string) bool { return strings.Contains(c, "mergeMethod=SQUASH") }) {
+		t.Error("dependency updates should be squash-merged")
+	}
+	if len(fake.posted) > 0 {
+		t.Errorf("posted %v; grouped mode shouldn't comment", fake.posted)
+	}
+	if len(alerts.alerts) != 1 || !strings.Contains(alerts.alerts[0], major.URL) {
+		t.Errorf("alerts = %q; want one grouped alert for the major bump", alerts.alerts)
+	}
+}
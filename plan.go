@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// planFile is the output of `plan` and the input of `apply`: the actions a
+// dry run decided on, plus the PR state each decision was based on.
+type planFile struct {
+	CreatedAt string     `json:"createdAt"`
+	Org       string     `json:"org"`
+	Steps     []planStep `json:"steps"`
+}
+
+// planStep is one planned action on a PR. Action is what apply will do:
+// merge, comment, close, enable_auto_merge, request_review, dismiss_review,
+// rerun_ci, approve_workflows, or resolve_conflict.
+type planStep struct {
+	URL            string `json:"url"`
+	Repo           string `json:"repo"`
+	Number         int    `json:"number"`
+	Author         string `json:"author"`
+	Action         string `json:"action"`
+	Reason         string `json:"reason,omitempty"`
+	HeadSHA        string `json:"headSha"`
+	Mergeable      string `json:"mergeable"`
+	ChecksState    string `json:"checksState"`
+	ReviewDecision string `json:"reviewDecision"`
+}
+
+// plannedAction maps a dry-run outcome to the action a real run would take,
+// or "" when the PR would be left alone.
+func plannedAction(o prOutcome) string {
+	if _, ok := o.dryRun(); !ok {
+		return ""
+	}
+	switch o.ReasonCode {
+	case reasonMergeable:
+		return "merge"
+	case reasonClosedStale:
+		return "close"
+	case reasonAutoMerge:
+		return "enable_auto_merge"
+	case reasonReviewRequested:
+		return "request_review"
+	case reasonReviewDismissed:
+		return "dismiss_review"
+	case reasonCIRerun:
+		return "rerun_ci"
+	case reasonWorkflowsApproved:
+		return "approve_workflows"
+	case reasonMergeableConflicting:
+		return "resolve_conflict"
+	case reasonBranchBehind:
+		return "update_branch"
+	case reasonMarkedReady:
+		return "mark_ready"
+	}
+	return "comment"
+}
+
+// newPlan builds a plan from a dry run's outcomes, keeping only PRs a real
+// run would act on.
+func newPlan(out runOutput) planFile {
+	p := planFile{CreatedAt: out.StartedAt, Org: out.Org, Steps: []planStep{}}
+	for _, o := range out.Results {
+		action := plannedAction(o)
+		if action == "" || o.HeadSHA == "" {
+			continue
+		}
+		detail, _ := o.dryRun()
+		p.Steps = append(p.Steps, planStep{
+			URL:            o.URL,
+			Repo:           o.Repo,
+			Number:         o.Number,
+			Author:         o.Author,
+			Action:         action,
+			Reason:         reasonText(o.ReasonCode, detail),
+			HeadSHA:        o.HeadSHA,
+			Mergeable:      o.Mergeable,
+			ChecksState:    o.ChecksState,
+			ReviewDecision: o.ReviewDecision,
+		})
+	}
+	return p
+}
+
+// step returns the planned step for a PR, or nil if it isn't in the plan.
+func (p *planFile) step(url string) *planStep {
+	for i := range p.Steps {
+		if p.Steps[i].URL == url {
+			return &p.Steps[i]
+		}
+	}
+	return nil
+}
+
+// searchPRs returns the planned PRs in plan order, as the pipeline's input.
+func (p *planFile) searchPRs() []searchPR {
+	prs := make([]searchPR, 0, len(p.Steps))
+	for _, s := range p.Steps {
+		var pr searchPR
+		pr.URL = s.URL
+		pr.Number = s.Number
+		pr.Author.Login = s.Author
+		pr.Repository.NameWithOwner = s.Repo
+		prs = append(prs, pr)
+	}
+	return prs
+}
+
+// matches reports whether the PR is still in the state it was planned
+// against: same head commit, mergeability, checks, and review decision.
+func (s *planStep) matches(o prOutcome) bool {
+	return s.HeadSHA == o.HeadSHA &&
+		s.Mergeable == o.Mergeable &&
+		s.ChecksState == o.ChecksState &&
+		s.ReviewDecision == o.ReviewDecision
+}
+
+// refuses reports whether apply has to turn down taking action on the PR
+// because it isn't the step planned for it, marking the outcome
+// plan_stale. A nil plan (any run but apply) refuses nothing.
+func (p *planFile) refuses(o *prOutcome, action string) bool {
+	if p == nil {
+		return false
+	}
+	step := p.step(o.URL)
+	if step != nil && step.Action == action {
+		return false
+	}
+	planned := "nothing"
+	if step != nil {
+		planned = step.Action
+	}
+	fmt.Fprintf(os.Stderr, "[plan] %s: planned %s, but the run would %s; skipping\n", o.URL, planned, action)
+	o.Action = "skipped"
+	o.setReason(reasonPlanStale, fmt.Sprintf("planned %s, now %s", planned, action))
+	return true
+}
+
+// defaultPlanPath is where plan and apply keep the plan, beside the state file.
+func defaultPlanPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "plan.json")
+}
+
+func savePlan(path string, p planFile) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func loadPlan(path string) (planFile, error) {
+	var p planFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, fmt.Errorf("no plan: %w", err)
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("parse plan %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// runPlanCommand implements `fab-pr-pipeline plan`: a dry run whose
+// intended actions are written to the plan file for a human to review.
+func runPlanCommand(args []string) int {
+	opts, code := parseRunFlags("plan", args)
+	if opts == nil {
+		return code
+	}
+	opts.DryRun = true
+
+	ctx, cancel := opts.runContext()
+	out, err := newPipeline(opts).Run(ctx)
+	cancel()
+	if err != nil {
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return 1
+	}
+
+	path := opts.planFile
+	if path == "" {
+		path = defaultPlanPath(resolveStatePath(opts.StateFile))
+	}
+	plan := newPlan(out)
+	if err := savePlan(path, plan); err != nil {
+		emitJSON(map[string]any{"ok": false, "error": "save plan: " + err.Error()})
+		return 1
+	}
+	emitJSON(map[string]any{"ok": true, "planFile": path, "plan": plan})
+	return 0
+}
+
+// runApplyCommand implements `fab-pr-pipeline apply`: run the pipeline on
+// exactly the planned PRs. A PR whose head commit, mergeability, checks, or
+// review decision changed since planning, or that the run would now do
+// something else to, is skipped with reason plan_stale.
+func runApplyCommand(args []string) int {
+	opts, code := parseRunFlags("apply", args)
+	if opts == nil {
+		return code
+	}
+	path := opts.planFile
+	if path == "" {
+		path = defaultPlanPath(resolveStatePath(opts.StateFile))
+	}
+	plan, err := loadPlan(path)
+	if err != nil {
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return 1
+	}
+	if !strings.EqualFold(plan.Org, opts.Org) {
+		emitJSON(map[string]any{"ok": false, "error": fmt.Sprintf("plan is for org %q, not %q", plan.Org, opts.Org)})
+		return 1
+	}
+	opts.plan = &plan
+	opts.MaxPRs = max(opts.MaxPRs, len(plan.Steps))
+	fmt.Fprintf(os.Stderr, "[plan] applying %d step(s) planned %s ago\n", len(plan.Steps), planAge(plan, time.Now()))
+
+	ctx, cancel := opts.runContext()
+	out, err := newPipeline(opts).Run(ctx)
+	cancel()
+	if err != nil {
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return 1
+	}
+	return finishRun(opts, out)
+}
+
+// planAge is how long ago a plan was made, for the apply log.
+func planAge(p planFile, now time.Time) time.Duration {
+	created, err := time.Parse(time.RFC3339, p.CreatedAt)
+	if err != nil {
+		return 0
+	}
+	return now.Sub(created).Round(time.Second)
+}
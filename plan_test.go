@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// dryRunOutcome is a dry run's outcome for code.
+func dryRunOutcome(code reasonCode) prOutcome {
+	o := prOutcome{Action: "skipped"}
+	o.setDryRunReason(code, "")
+	return o
+}
+
+func TestPlannedAction(t *testing.T) {
+	draft := prOutcome{Action: "skipped"}
+	draft.setReason(reasonDraft, "")
+	failed := prOutcome{Action: "error"}
+	failed.setFailure(reasonPRViewFailed, "permanent", errors.New("boom"))
+	condition := prOutcome{Action: "skipped"}
+	condition.setDryRunReason(reasonMergeCondition, "small")
+
+	tests := []struct {
+		outcome prOutcome
+		want    string
+	}{
+		{dryRunOutcome(reasonMergeable), "merge"},
+		{dryRunOutcome(reasonClosedStale), "close"},
+		{dryRunOutcome(reasonAutoMerge), "enable_auto_merge"},
+		{dryRunOutcome(reasonReviewRequested), "request_review"},
+		{dryRunOutcome(reasonReviewDismissed), "dismiss_review"},
+		{dryRunOutcome(reasonCIRerun), "rerun_ci"},
+		{dryRunOutcome(reasonWorkflowsApproved), "approve_workflows"},
+		{dryRunOutcome(reasonMergeableConflicting), "resolve_conflict"},
+		{dryRunOutcome(reasonBranchBehind), "update_branch"},
+		{dryRunOutcome(reasonMarkedReady), "mark_ready"},
+		{dryRunOutcome(reasonChecksFailure), "comment"},
+		{condition, "comment"},
+		{draft, ""},
+		{failed, ""},
+		// A reason that merely reads like a dry run's.
+		{prOutcome{Action: "skipped", Reason: "dry_run_mergeable"}, ""},
+	}
+	for _, tt := range tests {
+		if got := plannedAction(tt.outcome); got != tt.want {
+			t.Errorf("plannedAction(%s/%s) = %q; want %q", tt.outcome.Action, tt.outcome.Reason, got, tt.want)
+		}
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	out := runOutput{
+		StartedAt: "2025-06-01T12:00:00Z",
+		Org:       "misty-step",
+		Results: []prOutcome{
+			{URL: "u/1", Repo: "misty-step/a", Number: 1, HeadSHA: "abc", Mergeable: "MERGEABLE", ChecksState: "SUCCESS", ReviewDecision: "APPROVED"},
+			{URL: "u/2", Repo: "misty-step/a", Number: 2, Action: "skipped", HeadSHA: "def"},
+			{URL: "u/3", Repo: "misty-step/b", Number: 3, HeadSHA: "123", ChecksState: "FAILURE"},
+		},
+	}
+	out.Results[0].Action = "skipped"
+	out.Results[0].setDryRunReason(reasonMergeable, "")
+	out.Results[1].setReason(reasonDraft, "")
+	out.Results[2].Action = "skipped"
+	out.Results[2].setDryRunReason(reasonChecksFailure, "")
+	p := newPlan(out)
+	if p.Org != "misty-step" || p.CreatedAt != out.StartedAt {
+		t.Errorf("plan header = %+v", p)
+	}
+	if len(p.Steps) != 2 {
+		t.Fatalf("got %d steps; want 2: %+v", len(p.Steps), p.Steps)
+	}
+	if s := p.Steps[0]; s.Action != "merge" || s.Reason != "mergeable" || s.HeadSHA != "abc" {
+		t.Errorf("step 0 = %+v", s)
+	}
+	if s := p.Steps[1]; s.Action != "comment" || s.Reason != "checks_failure" {
+		t.Errorf("step 1 = %+v", s)
+	}
+
+	prs := p.searchPRs()
+	if len(prs) != 2 || prs[1].URL != "u/3" || prs[1].Repository.NameWithOwner != "misty-step/b" || prs[1].Number != 3 {
+		t.Errorf("searchPRs = %+v", prs)
+	}
+	if p.step("u/2") != nil {
+		t.Error("draft PR should not be in the plan")
+	}
+}
+
+func TestPlanStepMatches(t *testing.T) {
+	step := planStep{HeadSHA: "abc", Mergeable: "MERGEABLE", ChecksState: "SUCCESS", ReviewDecision: "APPROVED"}
+	same := prOutcome{HeadSHA: "abc", Mergeable: "MERGEABLE", ChecksState: "SUCCESS", ReviewDecision: "APPROVED"}
+	if !step.matches(same) {
+		t.Error("identical state should match")
+	}
+	for name, o := range map[string]prOutcome{
+		"new commit":      {HeadSHA: "def", Mergeable: "MERGEABLE", ChecksState: "SUCCESS", ReviewDecision: "APPROVED"},
+		"now conflicting": {HeadSHA: "abc", Mergeable: "CONFLICTING", ChecksState: "SUCCESS", ReviewDecision: "APPROVED"},
+		"checks failing":  {HeadSHA: "abc", Mergeable: "MERGEABLE", ChecksState: "FAILURE", ReviewDecision: "APPROVED"},
+		"review changed":  {HeadSHA: "abc", Mergeable: "MERGEABLE", ChecksState: "SUCCESS", ReviewDecision: "CHANGES_REQUESTED"},
+	} {
+		if step.matches(o) {
+			t.Errorf("%s: should not match", name)
+		}
+	}
+}
+
+func TestSaveLoadPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "plan.json")
+	want := planFile{CreatedAt: "2025-06-01T12:00:00Z", Org: "o", Steps: []planStep{{URL: "u/1", Action: "merge", HeadSHA: "abc"}}}
+	if err := savePlan(path, want); err != nil {
+		t.Fatalf("savePlan: %v", err)
+	}
+	got, err := loadPlan(path)
+	if err != nil {
+		t.Fatalf("loadPlan: %v", err)
+	}
+	if got.Org != want.Org || len(got.Steps) != 1 || got.Steps[0] != want.Steps[0] {
+		t.Errorf("loadPlan = %+v; want %+v", got, want)
+	}
+	if _, err := loadPlan(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing plan")
+	}
+	if d := planAge(want, time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)); d != 30*time.Minute {
+		t.Errorf("planAge = %s; want 30m", d)
+	}
+}
+
+func TestApplyRefusesUnplannedAction(t *testing.T) {
+	held := fakePR("misty-step/api", 1)
+	held.Labels = []label{{Name: "hold"}}
+	green := fakePR("misty-step/api", 2)
+	fake := newFakeGitHub(held, green)
+	useFakeGitHub(t, fake)
+
+	out, err := newPipeline(testPipelineOptions(t, "-hold-label", "hold", "-dry-run")).Run(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := newPlan(out)
+	if s := plan.step(held.URL); s == nil || s.Action != "comment" {
+		t.Fatalf("held PR step = %+v; want a comment planned", s)
+	}
+
+	// Approved as a comment, but with the label gone the run would merge it.
+	held.Labels = nil
+	opts := testPipelineOptions(t, "-hold-label", "hold")
+	opts.plan = &plan
+	out, err = newPipeline(opts).Run(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]prOutcome{}
+	for _, r := range out.Results {
+		got[r.URL] = r
+	}
+	if r := got[held.URL]; r.Action != "skipped" || r.ReasonCode != reasonPlanStale || r.ReasonDetail != "planned comment, now merge" {
+		t.Errorf("held PR = %s %s (%s); want plan_stale", r.Action, r.ReasonCode, r.ReasonDetail)
+	}
+	if r := got[green.URL]; r.Action != "merged" {
+		t.Errorf("green PR = %s %s; want merged as planned", r.Action, r.Reason)
+	}
+	if !slices.Equal(fake.merged, []string{green.ID}) {
+		t.Errorf("merged = %v; want only the planned merge", fake.merged)
+	}
+}
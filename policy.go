@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ruleVerdict is a merge rule's answer for a PR.
+type ruleVerdict int
+
+const (
+	ruleAbstain ruleVerdict = iota // no objection; the next rule decides
+	ruleAllow                      // merge, without asking the rules after it
+	ruleDeny                       // don't merge, for the rule's reason
+)
+
+// ruleResult is what a merge rule decided, and what it saw (for explain).
+type ruleResult struct {
+	Verdict ruleVerdict
+	Code    reasonCode // the blocker, on a deny
+	Detail  string     // e.g. the merge condition's name
+	Seen    string
+}
+
+func abstain(seen string) ruleResult { return ruleResult{Verdict: ruleAbstain, Seen: seen} }
+
+func deny(code reasonCode, seen string) ruleResult {
+	return ruleResult{Verdict: ruleDeny, Code: code, Seen: seen}
+}
+
+// ruleInput is what merge rules look at: the PR, its repo's policy, and the
+// run. Rules that look things up record what they found on view and
+// outcome, for the status comment and the run output.
+type ruleInput struct {
+	ctx     context.Context
+	opts    *runOptions
+	pr      searchPR
+	view    *prView
+	policy  repoPolicy
+	outcome *prOutcome
+	now     time.Time
+	// heldUpdate is the dependency update blocker that waits for a human
+	// (reasonDependencyMajor), or "".
+	heldUpdate reasonCode
+}
+
+// mergeRule is one gate in the merge policy chain.
+type mergeRule struct {
+	name  string
+	check func(in *ruleInput) (ruleResult, error)
+	// autoMerge rules are also asked before enabling auto-merge on a PR
+	// that's only waiting on its checks.
+	autoMerge bool
+	// lookupFailed is the reason when what the rule fetches can't be.
+	lookupFailed reasonCode
+	// required rules run whatever the config's mergeRules says: without
+	// them the pipeline would merge red, conflicting, or unsafe PRs.
+	required bool
+	// active reports whether a flag or the config turned the rule on for
+	// this PR; an active rule is required, so leaving it out of mergeRules
+	// can't switch off a gate that was asked for.
+	active func(in *ruleInput) bool
+}
+
+// mergeRules is every merge rule, in the default order. The config's
+// mergeRules orders them per repo by name, and picks the ones that aren't
+// required.
+var mergeRules = []mergeRule{
+	{name: "mergeable", check: ruleMergeable, required: true},
+	{name: "checks", check: ruleChecks, required: true},
+	{name: "review", check: ruleReview, required: true},
+	{name: "up_to_date", check: ruleUpToDate, required: true},
+	{name: "size", check: ruleSize, autoMerge: true, active: sizeLimited},
+	{name: "review_threads", check: ruleReviewThreads, lookupFailed: reasonReviewThreadsLookupFailed, active: threadsRequired},
+	{name: "commits", check: ruleCommits, autoMerge: true, lookupFailed: reasonCommitsLookupFailed, required: true},
+	{name: "secrets", check: ruleSecrets, autoMerge: true, lookupFailed: reasonDiffLookupFailed, required: true},
+	{name: "protected_paths", check: ruleProtectedPaths, autoMerge: true, lookupFailed: reasonChangedFilesLookupFailed, active: hasProtectedPaths},
+	{name: "conditions", check: ruleConditions, autoMerge: true, active: hasMergeConditions},
+	{name: "hold_label", check: ruleHoldLabel, autoMerge: true, required: true},
+	{name: "author_mode", check: ruleAuthorMode, autoMerge: true, required: true},
+	{name: "fork_policy", check: ruleForkPolicy, autoMerge: true, required: true},
+	{name: "dependency", check: ruleDependency, autoMerge: true, active: isDependencyUpdate},
+	{name: "merge_window", check: ruleMergeWindow, autoMerge: true, active: hasMergeWindow},
+}
+
+// stateRules judge the PR's own state, without lookups or the run's
+// settings; mergeAllowed runs just these.
+var stateRules = mergeRules[:4]
+
+// mergeRuleNames returns the names of every merge rule.
+func mergeRuleNames() []string {
+	names := make([]string, len(mergeRules))
+	for i, r := range mergeRules {
+		names[i] = r.name
+	}
+	return names
+}
+
+// mergeRulesNamed returns the rules named, in that order, after any
+// required or active rules the names leave out (in the default order); no
+// names means every rule in the default order.
+func mergeRulesNamed(names []string, in *ruleInput) []mergeRule {
+	if len(names) == 0 {
+		return mergeRules
+	}
+	var rules []mergeRule
+	for _, r := range mergeRules {
+		if (r.required || (r.active != nil && r.active(in))) && !slices.Contains(names, r.name) {
+			rules = append(rules, r)
+		}
+	}
+	for _, name := range names {
+		if i := slices.IndexFunc(mergeRules, func(r mergeRule) bool { return r.name == name }); i >= 0 {
+			rules = append(rules, mergeRules[i])
+		}
+	}
+	return rules
+}
+
+// validateMergeRules rejects unknown and repeated rule names.
+func validateMergeRules(names []string) error {
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !slices.Contains(mergeRuleNames(), name) {
+			return fmt.Errorf("unknown rule %q (want one of %s)", name, strings.Join(mergeRuleNames(), ", "))
+		}
+		if seen[name] {
+			return fmt.Errorf("rule %q listed twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func sizeLimited(in *ruleInput) bool {
+	return in.opts.MaxMergeLines > 0 || in.opts.MaxMergeFiles > 0
+}
+
+func threadsRequired(in *ruleInput) bool {
+	return in.opts.RequireResolved
+}
+
+func hasProtectedPaths(in *ruleInput) bool {
+	return len(in.opts.config.protectedPathsFor(in.pr.Repository.NameWithOwner)) > 0
+}
+
+func hasMergeConditions(in *ruleInput) bool {
+	return len(in.opts.config.mergeConditionsFor(in.pr.Repository.NameWithOwner)) > 0
+}
+
+func isDependencyUpdate(in *ruleInput) bool {
+	return in.outcome.DependencyUpdate != ""
+}
+
+func hasMergeWindow(in *ruleInput) bool {
+	return in.opts.config.mergeWindowFor(in.pr.Repository.NameWithOwner) != nil || hasLabel(in.view.Labels, in.opts.NoWeekendLabel)
+}
+
+// chainResult is the merge decision.
+type chainResult struct {
+	ok     bool
+	code   reasonCode
+	detail string
+	// autoMergeBlocked is set when the PR is only waiting on its checks
+	// and a later auto-merge rule denied it.
+	autoMergeBlocked bool
+	// failed is the rule whose lookup failed with err; the PR's outcome is
+	// an error, since a gate that couldn't be checked can't be passed.
+	failed *mergeRule
+	err    error
+}
+
+// runMergeRules asks each rule in turn. The first deny decides; an allow
+// ends the chain with a merge. When --enable-auto-merge is on and the PR is
+// only waiting on checks, the autoMerge rules after the deny are asked too,
+// so auto-merge isn't turned on for a PR that couldn't merge once green.
+func runMergeRules(in *ruleInput, rules []mergeRule) chainResult {
+	res := chainResult{ok: true}
+	for i := range rules {
+		r := &rules[i]
+		if !res.ok && !r.autoMerge {
+			continue
+		}
+		v, err := r.check(in)
+		if err != nil {
+			res.failed, res.err = r, err
+			return res
+		}
+		seen := v.Seen
+		if v.Verdict == ruleDeny && seen == "" {
+			seen = reasonText(v.Code, v.Detail)
+		}
+		in.opts.explain.gate(r.name, v.Verdict != ruleDeny, seen)
+		switch {
+		case v.Verdict == ruleAllow:
+			return res
+		case v.Verdict == ruleDeny && !res.ok:
+			res.autoMergeBlocked = true
+			return res
+		case v.Verdict == ruleDeny:
+			res.ok, res.code, res.detail = false, v.Code, v.Detail
+			if !in.opts.EnableAutoMerge || !autoMergeCandidate(in.view, in.policy, v.Code) {
+				return res
+			}
+		}
+	}
+	return res
+}
+
+// mergeAllowed judges the PR's own state (mergeable, checks, review, up to
+// date), without the run's other gates.
+func mergeAllowed(pr *prView, policy repoPolicy) (bool, reasonCode) {
+	in := &ruleInput{opts: &runOptions{}, view: pr, policy: policy}
+	res := runMergeRules(in, stateRules)
+	return res.ok, res.code
+}
+
+func ruleMergeable(in *ruleInput) (ruleResult, error) {
+	switch mergeable := strings.ToUpper(strings.TrimSpace(in.view.Mergeable)); mergeable {
+	case "MERGEABLE":
+		return abstain(mergeable), nil
+	case "CONFLICTING":
+		return deny(reasonMergeableConflicting, mergeable), nil
+	default:
+		return deny(reasonMergeableUnknown, mergeable), nil
+	}
+}
+
+func ruleChecks(in *ruleInput) (ruleResult, error) {
+	state := strings.ToUpper(strings.TrimSpace(overallChecksState(in.view.StatusCheckRollup)))
+	seen := fmt.Sprintf("%s over %d checks", state, len(in.view.StatusCheckRollup))
+	switch state {
+	case "":
+		// Some repos don't report rollups; treat as not ready.
+		return deny(reasonChecksUnknown, "no checks reported"), nil
+	case "ACTION_REQUIRED":
+		return deny(reasonWorkflowsAwaiting, seen), nil
+	case "SUCCESS":
+		return abstain(seen), nil
+	case "PENDING":
+		return deny(reasonChecksPending, seen), nil
+	}
+	return deny(reasonChecksFailure, seen), nil
+}
+
+func ruleReview(in *ruleInput) (ruleResult, error) {
+	seen := fmt.Sprintf("%s (approval required: %t)", in.view.ReviewDecision, in.policy.RequireApproval)
+	if code := reviewBlocker(in.view, in.policy); code != "" {
+		return deny(code, seen), nil
+	}
+	return abstain(seen), nil
+}
+
+func ruleUpToDate(in *ruleInput) (ruleResult, error) {
+	// Strict protection ("require branches to be up to date") rejects the merge.
+	if strings.EqualFold(strings.TrimSpace(in.view.MergeStateStatus), "BEHIND") {
+		return deny(reasonBranchBehind, "merge state BEHIND"), nil
+	}
+	return abstain("merge state " + in.view.MergeStateStatus), nil
+}
+
+func ruleSize(in *ruleInput) (ruleResult, error) {
+	v, opts := in.view, in.opts
+	seen := fmt.Sprintf("+%d -%d in %d files (limits: %d lines, %d files; 0 = none)",
+		v.Additions, v.Deletions, v.ChangedFiles, opts.MaxMergeLines, opts.MaxMergeFiles)
+	if prTooLarge(v, opts.MaxMergeLines, opts.MaxMergeFiles) {
+		return deny(reasonPRTooLarge, seen), nil
+	}
+	return abstain(seen), nil
+}
+
+func ruleReviewThreads(in *ruleInput) (ruleResult, error) {
+	if !in.opts.RequireResolved {
+		return abstain("off"), nil
+	}
+	threads, err := RetryableWithResult(in.ctx, func() ([]reviewThread, error) {
+		return ghUnresolvedReviewThreads(in.ctx, in.pr.Repository.NameWithOwner, in.pr.Number)
+	}, retryCfg)
+	if err != nil {
+		return ruleResult{}, err
+	}
+	seen := fmt.Sprintf("%d unresolved", len(threads))
+	if len(threads) > 0 {
+		in.view.UnresolvedThreads = threads
+		in.outcome.ReviewThreads = threads
+		return deny(reasonReviewThreadsUnresolved, seen), nil
+	}
+	return abstain(seen), nil
+}
+
+// ruleCommits holds back a PR with a commit from someone unexpected, or
+// unsigned: the branch may have been hijacked.
+func ruleCommits(in *ruleInput) (ruleResult, error) {
+	if !in.opts.VerifyCommits {
+		return abstain("off"), nil
+	}
+	commits, err := RetryableWithResult(in.ctx, func() ([]prCommit, error) {
+		return ghPRCommits(in.ctx, in.pr.Repository.NameWithOwner, in.pr.Number)
+	}, retryCfg)
+	if err != nil {
+		return ruleResult{}, err
+	}
+	in.view.UntrustedCommits = untrustedCommits(commits, in.pr.Author.Login, in.opts.trusted)
+	in.outcome.UntrustedCommits = in.view.UntrustedCommits
+	seen := fmt.Sprintf("%d commits, %d untrusted", len(commits), len(in.view.UntrustedCommits))
+	if len(in.view.UntrustedCommits) > 0 {
+		return deny(reasonUntrustedCommits, seen), nil
+	}
+	return abstain(seen), nil
+}
+
+// ruleSecrets never merges credentials into the base branch.
+func ruleSecrets(in *ruleInput) (ruleResult, error) {
+	if !in.opts.ScanSecrets {
+		return abstain("off"), nil
+	}
+	diff, err := RetryableWithResult(in.ctx, func() (string, error) {
+		return ghPRDiff(in.ctx, in.view.URL)
+	}, retryCfg)
+	if err != nil {
+		return ruleResult{}, err
+	}
+	in.view.SecretFindings = scanDiffForSecrets(in.ctx, diff)
+	in.outcome.SecretFindings = in.view.SecretFindings
+	seen := fmt.Sprintf("%d findings", len(in.view.SecretFindings))
+	if len(in.view.SecretFindings) > 0 {
+		return deny(reasonPossibleSecret, seen), nil
+	}
+	return abstain(seen), nil
+}
+
+// ruleProtectedPaths leaves changes under protected paths for a human merge.
+func ruleProtectedPaths(in *ruleInput) (ruleResult, error) {
+	patterns := in.opts.config.protectedPathsFor(in.pr.Repository.NameWithOwner)
+	if len(patterns) == 0 {
+		return abstain("no protected paths"), nil
+	}
+	files, err := RetryableWithResult(in.ctx, func() ([]string, error) {
+		return ghPRChangedFiles(in.ctx, in.pr.Repository.NameWithOwner, in.pr.Number)
+	}, retryCfg)
+	if err != nil {
+		return ruleResult{}, err
+	}
+	in.view.ProtectedFiles = protectedFiles(patterns, files)
+	in.outcome.ProtectedFiles = in.view.ProtectedFiles
+	seen := fmt.Sprintf("%d of %d files protected", len(in.view.ProtectedFiles), len(files))
+	if len(in.view.ProtectedFiles) > 0 {
+		return deny(reasonProtectedPaths, seen), nil
+	}
+	return abstain(seen), nil
+}
+
+// ruleConditions holds a PR back until each of the config's merge
+// conditions comes out true. One that can't be evaluated against this PR
+// holds it back too, naming the error.
+func ruleConditions(in *ruleInput) (ruleResult, error) {
+	conds := in.opts.config.mergeConditionsFor(in.pr.Repository.NameWithOwner)
+	if len(conds) == 0 {
+		return abstain("no merge conditions"), nil
+	}
+	env := conditionEnv(in.view, in.pr.Repository.NameWithOwner, in.now)
+	var seen []string
+	for _, c := range conds {
+		ok, err := evalBool(c.prog, env)
+		switch {
+		case err != nil:
+			seen = append(seen, fmt.Sprintf("%s: %v", c.Name, err))
+		case !ok:
+			seen = append(seen, c.Name+": false")
+		default:
+			seen = append(seen, c.Name+": true")
+			continue
+		}
+		in.view.FailedCondition = &c
+		res := deny(reasonMergeCondition, strings.Join(seen, ", "))
+		res.Detail = c.Name
+		return res, nil
+	}
+	return abstain(strings.Join(seen, ", ")), nil
+}
+
+func ruleHoldLabel(in *ruleInput) (ruleResult, error) {
+	hold := hasLabel(in.view.Labels, in.opts.HoldLabel)
+	seen := fmt.Sprintf("%q present: %t", in.opts.HoldLabel, hold)
+	if hold {
+		return deny(reasonHoldLabel, seen), nil
+	}
+	return abstain(seen), nil
+}
+
+func ruleAuthorMode(in *ruleInput) (ruleResult, error) {
+	mode := in.opts.authors.policyFor(in.pr.Author.Login, in.pr.Author.IsBot).Mode
+	if mode == authorModeCommentOnly {
+		return deny(reasonAuthorCommentOnly, mode), nil
+	}
+	return abstain(mode), nil
+}
+
+func ruleForkPolicy(in *ruleInput) (ruleResult, error) {
+	seen := fmt.Sprintf("cross-repo %t, --fork-policy %s", in.view.IsCrossRepository, in.opts.ForkPolicy)
+	if in.view.IsCrossRepository && in.opts.ForkPolicy == forkPolicyCommentOnly {
+		return deny(reasonForkCommentOnly, seen), nil
+	}
+	return abstain(seen), nil
+}
+
+// ruleDependency holds dependency updates other than patch and minor for a
+// human.
+func ruleDependency(in *ruleInput) (ruleResult, error) {
+	if in.outcome.DependencyUpdate == "" {
+		return abstain("not a dependency update"), nil
+	}
+	seen := in.outcome.DependencyUpdate + " update"
+	if in.heldUpdate != "" {
+		return deny(in.heldUpdate, seen), nil
+	}
+	return abstain(seen), nil
+}
+
+func ruleMergeWindow(in *ruleInput) (ruleResult, error) {
+	seen := "at " + in.now.UTC().Format(time.RFC3339)
+	if !inMergeWindow(in.opts.config, in.pr.Repository.NameWithOwner, in.view.Labels, in.opts.NoWeekendLabel, in.now) {
+		return deny(reasonOutsideMergeWindow, seen), nil
+	}
+	return abstain(seen), nil
+}
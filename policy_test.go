@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunMergeRules(t *testing.T) {
+	rule := func(name string, verdict ruleVerdict, autoMerge bool, asked *[]string) mergeRule {
+		return mergeRule{name: name, autoMerge: autoMerge, check: func(*ruleInput) (ruleResult, error) {
+			*asked = append(*asked, name)
+			return ruleResult{Verdict: verdict, Code: reasonCode(name + "_denied")}, nil
+		}}
+	}
+	pending := fakePR("misty-step/api", 1)
+	pending.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "test", Status: "IN_PROGRESS"}}
+	pendingRule := mergeRule{name: "checks", check: ruleChecks}
+
+	tests := []struct {
+		name      string
+		autoMerge bool
+		rules     func(asked *[]string) []mergeRule
+		want      chainResult
+		wantAsked []string
+	}{
+		{
+			name: "every rule abstains",
+			rules: func(asked *[]string) []mergeRule {
+				return []mergeRule{rule("a", ruleAbstain, false, asked), rule("b", ruleAbstain, false, asked)}
+			},
+			want:      chainResult{ok: true},
+			wantAsked: []string{"a", "b"},
+		},
+		{
+			name: "first deny decides",
+			rules: func(asked *[]string) []mergeRule {
+				return []mergeRule{rule("a", ruleDeny, false, asked), rule("b", ruleDeny, false, asked)}
+			},
+			want:      chainResult{code: "a_denied"},
+			wantAsked: []string{"a"},
+		},
+		{
+			name: "allow ends the chain",
+			rules: func(asked *[]string) []mergeRule {
+				return []mergeRule{rule("a", ruleAllow, false, asked), rule("b", ruleDeny, false, asked)}
+			},
+			want:      chainResult{ok: true},
+			wantAsked: []string{"a"},
+		},
+		{
+			name:      "pending checks ask the auto-merge rules",
+			autoMerge: true,
+			rules: func(asked *[]string) []mergeRule {
+				return []mergeRule{pendingRule, rule("b", ruleDeny, false, asked), rule("c", ruleAbstain, true, asked), rule("d", ruleDeny, true, asked), rule("e", ruleDeny, true, asked)}
+			},
+			want:      chainResult{code: "checks_pending", autoMergeBlocked: true},
+			wantAsked: []string{"c", "d"},
+		},
+		{
+			name: "pending checks without auto-merge stop",
+			rules: func(asked *[]string) []mergeRule {
+				return []mergeRule{pendingRule, rule("c", ruleDeny, true, asked)}
+			},
+			want: chainResult{code: "checks_pending"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked []string
+			in := &ruleInput{opts: &runOptions{EnableAutoMerge: tt.autoMerge}, view: pending, outcome: &prOutcome{}}
+			got := runMergeRules(in, tt.rules(&asked))
+			if got != tt.want {
+				t.Errorf("runMergeRules() = %+v; want %+v", got, tt.want)
+			}
+			if !slices.Equal(asked, tt.wantAsked) {
+				t.Errorf("asked %v; want %v", asked, tt.wantAsked)
+			}
+		})
+	}
+}
+
+func TestRunMergeRulesLookupFailure(t *testing.T) {
+	boom := errors.New("boom")
+	rules := []mergeRule{{name: "commits", lookupFailed: reasonCommitsLookupFailed, check: func(*ruleInput) (ruleResult, error) {
+		return ruleResult{}, boom
+	}}}
+	got := runMergeRules(&ruleInput{opts: &runOptions{}, view: fakePR("misty-step/api", 1)}, rules)
+	if got.err != boom || got.failed == nil || got.failed.lookupFailed != reasonCommitsLookupFailed {
+		t.Errorf("runMergeRules() = %+v; want the commits lookup failure", got)
+	}
+}
+
+func TestMergeRulesConfig(t *testing.T) {
+	cfg := &pipelineConfig{
+		MergeRules: []string{"checks", "mergeable"},
+		Repos:      map[string]repoPolicy{"sandbox/*": {MergeRules: []string{"mergeable"}}},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	var pr searchPR
+	pr.Repository.NameWithOwner = "misty-step/api"
+	in := &ruleInput{opts: &runOptions{config: cfg}, pr: pr, view: &prView{}, outcome: &prOutcome{}}
+	ruleNames := func() []string {
+		var names []string
+		for _, r := range mergeRulesNamed(cfg.mergeRulesFor("misty-step/api"), in) {
+			names = append(names, r.name)
+		}
+		return names
+	}
+	want := []string{"review", "up_to_date", "commits", "secrets", "hold_label", "author_mode", "fork_policy", "checks", "mergeable"}
+	if names := ruleNames(); !slices.Equal(names, want) {
+		t.Errorf("misty-step/api rules = %v; want the required rules left out, then the top-level order", names)
+	}
+	if got := cfg.mergeRulesFor("sandbox/web"); !slices.Equal(got, []string{"mergeable"}) {
+		t.Errorf("sandbox/web rules = %v; want the repo's list", got)
+	}
+
+	// A gate a flag or the config turned on runs even when left out.
+	cfg.ProtectedPaths = []string{".github/**"}
+	cfg.MergeWindow = &mergeWindow{}
+	in.opts.MaxMergeLines, in.opts.RequireResolved = 500, true
+	in.outcome.DependencyUpdate = dependencyMajor
+	want = []string{"review", "up_to_date", "size", "review_threads", "commits", "secrets", "protected_paths", "hold_label", "author_mode", "fork_policy", "dependency", "merge_window", "checks", "mergeable"}
+	if names := ruleNames(); !slices.Equal(names, want) {
+		t.Errorf("rules with every gate set = %v; want %v", names, want)
+	}
+	if got := mergeRulesNamed(nil, in); len(got) != len(mergeRules) {
+		t.Errorf("no names gave %d rules; want all %d", len(got), len(mergeRules))
+	}
+
+	for _, bad := range [][]string{{"mergeable", "nope"}, {"checks", "checks"}} {
+		cfg := &pipelineConfig{Repos: map[string]repoPolicy{"o/r": {MergeRules: bad}}}
+		if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "mergeRules") {
+			t.Errorf("mergeRules %v: validate() = %v; want a mergeRules error", bad, err)
+		}
+	}
+}
+
+func TestMergeRulesKeepActiveRules(t *testing.T) {
+	large := fakePR("misty-step/api", 1)
+	large.Additions = 5000
+	fake := newFakeGitHub(large)
+	useFakeGitHub(t, fake)
+
+	opts := testPipelineOptions(t, "-max-merge-lines", "100")
+	opts.config = &pipelineConfig{MergeRules: []string{"mergeable", "checks", "review"}}
+	out, err := newPipeline(opts).Run(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 1 || out.Results[0].ReasonCode != reasonPRTooLarge {
+		t.Errorf("results = %+v; want -max-merge-lines to hold the large PR with size left out of the list", out.Results)
+	}
+}
+
+func TestMergeRulesKeepRequiredRules(t *testing.T) {
+	held := fakePR("misty-step/api", 1)
+	held.Labels = []label{{Name: "hold"}}
+	conflicting := fakePR("misty-step/api", 2)
+	conflicting.Mergeable = "CONFLICTING"
+	useFakeGitHub(t, newFakeGitHub(held, conflicting))
+
+	opts := testPipelineOptions(t, "-hold-label", "hold", "-dry-run")
+	opts.config = &pipelineConfig{MergeRules: []string{"size"}}
+	out, err := newPipeline(opts).Run(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]reasonCode{1: reasonHoldLabel, 2: reasonMergeableConflicting}
+	if len(out.Results) != len(want) {
+		t.Fatalf("results = %+v; want %d", out.Results, len(want))
+	}
+	for _, r := range out.Results {
+		if r.ReasonCode != want[r.Number] {
+			t.Errorf("#%d reason = %q; want %q, a mergeRules list can't drop the required rules", r.Number, r.ReasonCode, want[r.Number])
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ghPRChangedFiles lists every path the PR touches, from the REST files
+// API (which, unlike `gh pr view --json files`, pages past 100 files). A
+// renamed file contributes its old path too, so moving a file out of a
+// protected directory still counts.
+func ghPRChangedFiles(ctx context.Context, repo string, number int) ([]string, error) {
+	if strings.TrimSpace(repo) == "" {
+		return nil, errors.New("repo required")
+	}
+	stdout, err := runGh(ctx, "api", "--paginate", fmt.Sprintf("repos/%s/pulls/%d/files?per_page=100", repo, number))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	dec := json.NewDecoder(bytes.NewReader(stdout))
+	for {
+		var page []struct {
+			Filename         string `json:"filename"`
+			PreviousFilename string `json:"previous_filename"`
+		}
+		if err := dec.Decode(&page); err != nil {
+			if errors.Is(err, io.EOF) {
+				return files, nil
+			}
+			return nil, fmt.Errorf("parse pr files json: %w", err)
+		}
+		for _, f := range page {
+			files = append(files, f.Filename)
+			if f.PreviousFilename != "" {
+				files = append(files, f.PreviousFilename)
+			}
+		}
+	}
+}
+
+// protectedFiles returns the files matching any of patterns, which use the
+// same gitignore-style syntax as CODEOWNERS ("infra/", ".github/workflows/**",
+// "**/secrets*").
+func protectedFiles(patterns []string, files []string) []string {
+	var matched []string
+	for _, f := range files {
+		for _, p := range patterns {
+			if codeownersMatch(p, f) {
+				matched = append(matched, f)
+				break
+			}
+		}
+	}
+	return matched
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestProtectedFiles(t *testing.T) {
+	patterns := []string{".github/workflows/**", "infra/", "**/secrets*"}
+	files := []string{
+		"main.go",
+		".github/workflows/ci.yml",
+		".github/dependabot.yml",
+		"infra/terraform/main.tf",
+		"deploy/infra.md",
+		"secrets.env",
+		"config/prod/secrets.yaml",
+	}
+	want := []string{".github/workflows/ci.yml", "infra/terraform/main.tf", "secrets.env", "config/prod/secrets.yaml"}
+	if got := protectedFiles(patterns, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("protectedFiles = %v; want %v", got, want)
+	}
+	if got := protectedFiles(nil, files); got != nil {
+		t.Errorf("no patterns matched %v", got)
+	}
+}
+
+func TestGhPRChangedFiles(t *testing.T) {
+	pages := `[{"filename":"a.go"},{"filename":"infra/new.tf","previous_filename":"infra/old.tf"}]` + "\n" + `[{"filename":"b.go"}]`
+	ctx := withGitHubClient(context.Background(), ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+		return []byte(pages), nil
+	}))
+	files, err := ghPRChangedFiles(ctx, "misty-step/api", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.go", "infra/new.tf", "infra/old.tf", "b.go"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %v; want %v", files, want)
+	}
+}
+
+func TestValidateProtectedPaths(t *testing.T) {
+	if err := (&pipelineConfig{ProtectedPaths: []string{"infra/[", "ok/"}}).validate(); err == nil {
+		t.Error("a malformed pattern should be rejected")
+	}
+	cfg := &pipelineConfig{Repos: map[string]repoPolicy{"org/a": {ProtectedPaths: []string{"/"}}}}
+	if err := cfg.validate(); err == nil {
+		t.Error("an empty pattern should be rejected")
+	}
+	cfg = &pipelineConfig{
+		ProtectedPaths: []string{"infra/"},
+		Repos:          map[string]repoPolicy{"org/*": {ProtectedPaths: []string{"db/migrations/"}}},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.protectedPathsFor("org/a"), []string{"infra/", "db/migrations/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("protectedPathsFor = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// prRef is a pull request as named by its URL.
+type prRef struct {
+	Host   string
+	Owner  string
+	Repo   string
+	Number int
+}
+
+// nameWithOwner returns the PR's repo as OWNER/REPO.
+func (r prRef) nameWithOwner() string {
+	return r.Owner + "/" + r.Repo
+}
+
+// url returns the PR's canonical URL, without a trailing path, query, or
+// fragment.
+func (r prRef) url() string {
+	return fmt.Sprintf("https://%s/%s/%s/pull/%d", r.Host, r.Owner, r.Repo, r.Number)
+}
+
+// githubHosts returns the hosts PR URLs may point at: github.com, and the
+// GitHub Enterprise Server host in GH_HOST when gh is set up for one.
+func githubHosts() []string {
+	hosts := []string{"github.com"}
+	if h := strings.ToLower(strings.TrimSpace(os.Getenv("GH_HOST"))); h != "" && h != "github.com" {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// parsePRURL parses a PR URL on one of hosts:
+//
+//	https://HOST/OWNER/REPO/pull/123
+//
+// A trailing slash, a sub-page (/files, /commits), a query, and a fragment
+// are all allowed, as in a link copied from the browser.
+func parsePRURL(raw string, hosts []string) (prRef, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return prRef{}, false
+	}
+	host := strings.ToLower(u.Host)
+	if !slices.ContainsFunc(hosts, func(h string) bool { return strings.EqualFold(h, host) }) {
+		return prRef{}, false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 4 || parts[0] == "" || parts[1] == "" || parts[2] != "pull" {
+		return prRef{}, false
+	}
+	n, err := strconv.Atoi(parts[3])
+	if err != nil || n <= 0 {
+		return prRef{}, false
+	}
+	return prRef{Host: host, Owner: parts[0], Repo: parts[1], Number: n}, true
+}
+
+// repoFromPRURL returns OWNER/REPO for a PR URL on a known host, or "".
+func repoFromPRURL(prURL string) string {
+	if ref, ok := parsePRURL(prURL, githubHosts()); ok {
+		return ref.nameWithOwner()
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParsePRURL(t *testing.T) {
+	hosts := []string{"github.com", "ghe.example.com"}
+	tests := []struct {
+		raw  string
+		want prRef
+		ok   bool
+	}{
+		{"https://github.com/misty-step/api/pull/12", prRef{"github.com", "misty-step", "api", 12}, true},
+		{"https://github.com/misty-step/api/pull/12/", prRef{"github.com", "misty-step", "api", 12}, true},
+		{"https://github.com/misty-step/api/pull/12/files", prRef{"github.com", "misty-step", "api", 12}, true},
+		{"https://github.com/misty-step/api/pull/12?notification_referrer_id=x#issuecomment-1", prRef{"github.com", "misty-step", "api", 12}, true},
+		{"  https://GitHub.com/misty-step/api/pull/12\n", prRef{"github.com", "misty-step", "api", 12}, true},
+		{"https://ghe.example.com/team/svc.go/pull/3", prRef{"ghe.example.com", "team", "svc.go", 3}, true},
+		{"http://github.com/misty-step/api/pull/12", prRef{"github.com", "misty-step", "api", 12}, true},
+
+		{"https://gitlab.com/misty-step/api/pull/12", prRef{}, false},
+		{"https://github.com.evil.test/misty-step/api/pull/12", prRef{}, false},
+		{"https://github.com/misty-step/api/issues/12", prRef{}, false},
+		{"https://github.com/misty-step/api/pull/0", prRef{}, false},
+		{"https://github.com/misty-step/api/pull/abc", prRef{}, false},
+		{"https://github.com/misty-step/api/pull", prRef{}, false},
+		{"https://github.com//api/pull/12", prRef{}, false},
+		{"ftp://github.com/misty-step/api/pull/12", prRef{}, false},
+		{"misty-step/api#12", prRef{}, false},
+		{"", prRef{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePRURL(tt.raw, hosts)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parsePRURL(%q) = %+v, %v; want %+v, %v", tt.raw, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	ref := prRef{"ghe.example.com", "team", "svc", 3}
+	if got := ref.url(); got != "https://ghe.example.com/team/svc/pull/3" {
+		t.Errorf("url() = %q", got)
+	}
+	if got := ref.nameWithOwner(); got != "team/svc" {
+		t.Errorf("nameWithOwner() = %q", got)
+	}
+}
+
+func TestRepoFromPRURL(t *testing.T) {
+	tests := []struct {
+		ghHost string
+		raw    string
+		want   string
+	}{
+		{"", "https://github.com/misty-step/api/pull/12", "misty-step/api"},
+		{"", "https://github.com/misty-step/api/pull/12?w=1", "misty-step/api"},
+		{"", "https://ghe.example.com/team/svc/pull/3", ""},
+		{"ghe.example.com", "https://ghe.example.com/team/svc/pull/3", "team/svc"},
+		{"ghe.example.com", "https://github.com/misty-step/api/pull/12", "misty-step/api"},
+	}
+	for _, tt := range tests {
+		t.Setenv("GH_HOST", tt.ghHost)
+		if got := repoFromPRURL(tt.raw); got != tt.want {
+			t.Errorf("GH_HOST=%q: repoFromPRURL(%q) = %q; want %q", tt.ghHost, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestDenyPRCanonicalizesURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.json")
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := denyPR(path, "https://github.com/o/r/pull/1/files?w=1", time.Hour, now); err != nil {
+		t.Fatal(err)
+	}
+	if d := loadDenylist(path, now); !d.has("https://github.com/o/r/pull/1", now) {
+		t.Errorf("denylist = %v; want the PR's canonical URL", d)
+	}
+}
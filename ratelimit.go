@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// rateLimitBucket is one resource's quota from GET /rate_limit.
+type rateLimitBucket struct {
+	Limit     int   `json:"limit"`
+	Remaining int   `json:"remaining"`
+	Reset     int64 `json:"reset"` // unix seconds
+}
+
+// rateLimitStatus holds the quotas the pipeline spends: REST (core) for
+// gh pr/repo commands and GraphQL for search, view, and merge mutations.
+type rateLimitStatus struct {
+	Resources struct {
+		Core    rateLimitBucket `json:"core"`
+		GraphQL rateLimitBucket `json:"graphql"`
+	} `json:"resources"`
+}
+
+// ghRateLimit fetches the current quotas. The rate_limit endpoint itself
+// does not count against the quota.
+func ghRateLimit(ctx context.Context) (rateLimitStatus, error) {
+	var status rateLimitStatus
+	stdout, err := runGh(ctx, "api", "rate_limit")
+	if err != nil {
+		return status, err
+	}
+	if err := json.Unmarshal(stdout, &status); err != nil {
+		return status, fmt.Errorf("parse rate_limit json: %w", err)
+	}
+	return status, nil
+}
+
+// belowFloor reports whether either quota has dropped below floor, with a
+// short description of the exhausted bucket. floor <= 0 disables the check.
+func (s rateLimitStatus) belowFloor(floor int) (string, bool) {
+	if floor <= 0 {
+		return "", false
+	}
+	buckets := []struct {
+		name string
+		b    rateLimitBucket
+	}{
+		{"core", s.Resources.Core},
+		{"graphql", s.Resources.GraphQL},
+	}
+	for _, bk := range buckets {
+		// A zero limit means the bucket wasn't reported; don't treat it as empty.
+		if bk.b.Limit > 0 && bk.b.Remaining < floor {
+			reset := time.Unix(bk.b.Reset, 0).UTC().Format(time.RFC3339)
+			return fmt.Sprintf("%s remaining %d < floor %d (resets %s)", bk.name, bk.b.Remaining, floor, reset), true
+		}
+	}
+	return "", false
+}
+
+// rateLimitBudget gates acting on PRs once the GitHub quota runs low. Once
+// exhausted it stays exhausted for the rest of the run.
+type rateLimitBudget struct {
+	floor     int
+	exhausted bool
+	fetch     func() (rateLimitStatus, error)
+}
+
+func newRateLimitBudget(ctx context.Context, floor int) *rateLimitBudget {
+	return &rateLimitBudget{floor: floor, fetch: func() (rateLimitStatus, error) {
+		return ghRateLimit(ctx)
+	}}
+}
+
+// Exhausted checks the live quota. Lookup failures are logged and treated
+// as "budget available" so a flaky rate_limit call can't stall the run.
+func (r *rateLimitBudget) Exhausted() bool {
+	if r.floor <= 0 {
+		return false
+	}
+	if r.exhausted {
+		return true
+	}
+	status, err := r.fetch()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[rate-limit] lookup failed: %v (continuing)\n", err)
+		return false
+	}
+	if desc, low := status.belowFloor(r.floor); low {
+		fmt.Fprintf(os.Stderr, "[rate-limit] budget exhausted: %s; skipping remaining PRs\n", desc)
+		r.exhausted = true
+	}
+	return r.exhausted
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestRateLimitStatus_belowFloor(t *testing.T) {
+	raw := `{"resources":{"core":{"limit":5000,"remaining":4000,"reset":1700000000},"graphql":{"limit":5000,"remaining":150,"reset":1700000000}}}`
+	var s rateLimitStatus
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if _, low := s.belowFloor(100); low {
+		t.Error("floor 100 should not be hit")
+	}
+	desc, low := s.belowFloor(200)
+	if !low {
+		t.Fatal("floor 200 should be hit by graphql")
+	}
+	if desc == "" {
+		t.Error("expected description of exhausted bucket")
+	}
+	if _, low := s.belowFloor(0); low {
+		t.Error("floor 0 disables the check")
+	}
+	var empty rateLimitStatus
+	if _, low := empty.belowFloor(200); low {
+		t.Error("unreported buckets should not count as exhausted")
+	}
+}
+
+func TestRateLimitBudget(t *testing.T) {
+	calls := 0
+	remaining := 1000
+	b := newRateLimitBudget(context.Background(), 200)
+	b.fetch = func() (rateLimitStatus, error) {
+		calls++
+		var s rateLimitStatus
+		s.Resources.Core = rateLimitBucket{Limit: 5000, Remaining: remaining}
+		return s, nil
+	}
+
+	if b.Exhausted() {
+		t.Fatal("budget should be available")
+	}
+	remaining = 100
+	if !b.Exhausted() {
+		t.Fatal("budget should be exhausted")
+	}
+	remaining = 5000
+	if !b.Exhausted() {
+		t.Error("exhaustion should stick for the rest of the run")
+	}
+	if calls != 2 {
+		t.Errorf("expected no lookups after exhaustion, got %d calls", calls)
+	}
+}
+
+func TestRateLimitBudget_lookupErrorContinues(t *testing.T) {
+	b := newRateLimitBudget(context.Background(), 200)
+	b.fetch = func() (rateLimitStatus, error) { return rateLimitStatus{}, errors.New("boom") }
+	if b.Exhausted() {
+		t.Error("lookup failure should not stop the run")
+	}
+}
+
+func TestRateLimitBudget_disabled(t *testing.T) {
+	b := newRateLimitBudget(context.Background(), 0)
+	b.fetch = func() (rateLimitStatus, error) {
+		t.Fatal("disabled budget should not query the API")
+		return rateLimitStatus{}, nil
+	}
+	if b.Exhausted() {
+		t.Error("disabled budget is never exhausted")
+	}
+}
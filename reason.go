@@ -0,0 +1,311 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// reasonCode is an outcome's reason as a stable code that tooling can
+// switch on. Reason keeps the wording people and older consumers read,
+// which can carry detail (an error message, a queue position); the code
+// never does, and the detail goes in ReasonDetail.
+type reasonCode string
+
+// Blockers: why a PR wasn't merged.
+const (
+	reasonChecksSuccess           reasonCode = "checks_success"
+	reasonChecksFailure           reasonCode = "checks_failure"
+	reasonChecksPending           reasonCode = "checks_pending"
+	reasonChecksUnknown           reasonCode = "checks_unknown"
+	reasonMergeableConflicting    reasonCode = "mergeable_conflicting"
+	reasonMergeableUnknown        reasonCode = "mergeable_unknown"
+	reasonReviewChangesRequested  reasonCode = "review_changes_requested"
+	reasonReviewRequired          reasonCode = "review_required"
+	reasonReviewThreadsUnresolved reasonCode = "review_threads_unresolved"
+	reasonBranchBehind            reasonCode = "branch_behind"
+	reasonPRTooLarge              reasonCode = "pr_too_large"
+	reasonUntrustedCommits        reasonCode = "untrusted_commits"
+	reasonPossibleSecret          reasonCode = "possible_secret"
+	reasonProtectedPaths          reasonCode = "protected_paths"
+	reasonHoldLabel               reasonCode = "hold_label"
+	reasonAuthorCommentOnly       reasonCode = "author_comment_only"
+	reasonForkCommentOnly         reasonCode = "fork_comment_only"
+	reasonDependencyMajor         reasonCode = "dependency_major"
+	reasonDependencyUnknown       reasonCode = "dependency_unknown"
+	reasonOutsideMergeWindow      reasonCode = "outside_merge_window"
+	reasonWorkflowsAwaiting       reasonCode = "workflows_awaiting_approval"
+	reasonMergeCondition          reasonCode = "merge_condition" // detail: the condition's name
+)
+
+// Skips: why a PR wasn't looked at, or was left alone.
+const (
+	reasonRunTimeout             reasonCode = "run_timeout"
+	reasonRateLimitBudget        reasonCode = "rate_limit_budget"
+	reasonRepoActionCap          reasonCode = "repo_action_cap"
+	reasonRepoRateLimited        reasonCode = "repo_rate_limited"
+	reasonCircuitBreaker         reasonCode = "circuit_breaker"
+	reasonRepoCircuitBreaker     reasonCode = "repo_circuit_breaker"
+	reasonRepoArchived           reasonCode = "repo_archived"
+	reasonPlanStale              reasonCode = "plan_stale"
+	reasonDraft                  reasonCode = "draft"
+	reasonDoNotTouch             reasonCode = "do_not_touch"
+	reasonReviewAlreadyRequested reasonCode = "review_already_requested"
+	reasonOperatorSkip           reasonCode = "operator_skip"
+	reasonOperatorQuit           reasonCode = "operator_quit"
+	reasonIntentUnconfirmed      reasonCode = "intent_unconfirmed"
+	reasonUnchangedSinceLastRun  reasonCode = "unchanged_since_last_run" // detail: the blocker
+	reasonHeadMoved              reasonCode = "head_moved"
+)
+
+// Actions: what the pipeline did (or, in a dry run, would have done).
+const (
+	reasonMergeable          reasonCode = "mergeable"
+	reasonMergeQueued        reasonCode = "merge_queued"
+	reasonMergeQueuePosition reasonCode = "merge_queue_position" // detail: the position
+	reasonAutoMerge          reasonCode = "auto_merge"
+	reasonAutoMergePending   reasonCode = "auto_merge_pending"
+	reasonAfterUpdate        reasonCode = "after_update" // detail: the update's action
+	reasonClosedStale        reasonCode = "closed_stale"
+	reasonUntouched          reasonCode = "untouched" // detail: how long, e.g. 30d
+	reasonMarkedReady        reasonCode = "marked_ready"
+	reasonReviewDismissed    reasonCode = "review_dismissed"
+	reasonReviewRequested    reasonCode = "review_requested"
+	reasonCIRerun            reasonCode = "ci_rerun"
+	reasonWorkflowsApproved  reasonCode = "workflows_approved"
+	reasonOperatorComment    reasonCode = "operator_comment"
+	reasonIntentConfirmed    reasonCode = "intent_confirmed"
+)
+
+// Errors: the step that failed. The detail is the error.
+const (
+	reasonPRTimeout                 reasonCode = "pr_timeout"
+	reasonPRViewFailed              reasonCode = "pr_view_failed"
+	reasonCloseFailed               reasonCode = "close_failed"
+	reasonMarkReadyFailed           reasonCode = "mark_ready_failed"
+	reasonReviewThreadsLookupFailed reasonCode = "review_threads_lookup_failed"
+	reasonCommitsLookupFailed       reasonCode = "commits_lookup_failed"
+	reasonDiffLookupFailed          reasonCode = "diff_lookup_failed"
+	reasonChangedFilesLookupFailed  reasonCode = "changed_files_lookup_failed"
+	reasonOperatorCommentFailed     reasonCode = "operator_comment_failed"
+	reasonEnqueueFailed             reasonCode = "enqueue_failed"
+	reasonMergeFailed               reasonCode = "merge_failed"
+	reasonEnableAutoMergeFailed     reasonCode = "enable_auto_merge_failed"
+	reasonUpdateBranchFailed        reasonCode = "update_branch_failed"
+	reasonConflictCommentFailed     reasonCode = "conflict_comment_failed"
+	reasonDismissReviewFailed       reasonCode = "dismiss_review_failed"
+	reasonCIRerunFailed             reasonCode = "ci_rerun_failed"
+	reasonApproveWorkflowsFailed    reasonCode = "approve_workflows_failed"
+	reasonCommentFailed             reasonCode = "comment_failed"
+)
+
+// failedSteps is how each failure code's Reason names the step, as in
+// "merge failed (after retries): HTTP 502".
+var failedSteps = map[reasonCode]string{
+	reasonPRViewFailed:              "pr view",
+	reasonCloseFailed:               "close",
+	reasonMarkReadyFailed:           "mark ready",
+	reasonReviewThreadsLookupFailed: "review threads lookup",
+	reasonCommitsLookupFailed:       "commits lookup",
+	reasonDiffLookupFailed:          "diff lookup",
+	reasonChangedFilesLookupFailed:  "changed files lookup",
+	reasonOperatorCommentFailed:     "operator comment",
+	reasonEnqueueFailed:             "enqueue",
+	reasonMergeFailed:               "merge",
+	reasonEnableAutoMergeFailed:     "enable auto-merge",
+	reasonUpdateBranchFailed:        "update branch",
+	reasonConflictCommentFailed:     "conflict comment",
+	reasonDismissReviewFailed:       "dismiss review",
+	reasonCIRerunFailed:             "ci rerun",
+	reasonApproveWorkflowsFailed:    "approve workflows",
+	reasonCommentFailed:             "comment",
+}
+
+// reasonOther is the code for a reason with no code of its own; the whole
+// reason is its detail.
+const reasonOther reasonCode = "other"
+
+// reasonText is the Reason for code and detail: the code itself, except
+// for the codes whose detail has always been part of the wording.
+func reasonText(code reasonCode, detail string) string {
+	switch code {
+	case reasonMergeQueuePosition, reasonUntouched, reasonMergeCondition:
+		return string(code) + "_" + detail
+	case reasonAfterUpdate:
+		return "after_" + detail
+	case reasonPRTimeout:
+		return "timeout: " + detail
+	}
+	return string(code)
+}
+
+// setReason sets the outcome's code and detail, and Reason to match.
+func (o *prOutcome) setReason(code reasonCode, detail string) {
+	o.ReasonCode, o.ReasonDetail, o.Reason = code, detail, reasonText(code, detail)
+}
+
+// dryRunDetail marks the detail of what a dry run would have done.
+const dryRunDetail = "dry run"
+
+// setDryRunReason is setReason for what a dry run would have done.
+func (o *prOutcome) setDryRunReason(code reasonCode, detail string) {
+	o.ReasonCode, o.ReasonDetail, o.Reason = code, joinDetail(dryRunDetail, detail), "dry_run_"+reasonText(code, detail)
+}
+
+// dryRun reports whether the outcome is what a dry run would have done
+// (set by setDryRunReason), and returns its detail without the mark.
+func (o prOutcome) dryRun() (string, bool) {
+	if o.Action != "skipped" {
+		return "", false
+	}
+	if o.ReasonDetail == dryRunDetail {
+		return "", true
+	}
+	return strings.CutPrefix(o.ReasonDetail, dryRunDetail+": ")
+}
+
+// alreadyCommentedDetail marks the detail of a blocker the PR's comment
+// already explains.
+const alreadyCommentedDetail = "already commented"
+
+// setAlreadyCommentedReason is setReason for a blocker the PR's comment
+// already explains.
+func (o *prOutcome) setAlreadyCommentedReason(code reasonCode, detail string) {
+	o.ReasonCode, o.ReasonDetail, o.Reason = code, joinDetail(alreadyCommentedDetail, detail), reasonText(code, detail)+"_already_commented"
+}
+
+// alreadyCommented reports whether the outcome is a blocker the PR's
+// comment already explains (set by setAlreadyCommentedReason).
+func (o prOutcome) alreadyCommented() bool {
+	return o.Action == "skipped" &&
+		(o.ReasonDetail == alreadyCommentedDetail || strings.HasPrefix(o.ReasonDetail, alreadyCommentedDetail+": "))
+}
+
+// blocker returns the outcome's reason without the dry run or already
+// commented marks, worded as Reason words it: the same for a dry run, the
+// run that comments, and the runs that find the comment.
+func (o prOutcome) blocker() string {
+	detail := o.ReasonDetail
+	for _, mark := range []string{dryRunDetail, alreadyCommentedDetail} {
+		if detail == mark {
+			detail = ""
+		} else if rest, ok := strings.CutPrefix(detail, mark+": "); ok {
+			detail = rest
+		}
+	}
+	if o.ReasonCode == reasonOther {
+		return detail
+	}
+	return reasonText(o.ReasonCode, detail)
+}
+
+// setFailure sets the outcome's reason to a step (one of failedSteps)
+// that failed with err. how is "permanent", "after retries", or "".
+func (o *prOutcome) setFailure(code reasonCode, how string, err error) {
+	o.ReasonCode, o.ReasonDetail = code, err.Error()
+	o.Reason = failedSteps[code] + " failed"
+	if how != "" {
+		o.ReasonDetail += " (" + how + ")"
+		o.Reason += " (" + how + ")"
+	}
+	o.Reason += ": " + err.Error()
+}
+
+// knownReasonCodes is every code but reasonOther.
+var knownReasonCodes = []reasonCode{
+	reasonChecksSuccess, reasonChecksFailure, reasonChecksPending, reasonChecksUnknown,
+	reasonMergeableConflicting, reasonMergeableUnknown, reasonReviewChangesRequested,
+	reasonReviewRequired, reasonReviewThreadsUnresolved, reasonBranchBehind, reasonPRTooLarge,
+	reasonUntrustedCommits, reasonPossibleSecret, reasonProtectedPaths, reasonHoldLabel,
+	reasonAuthorCommentOnly, reasonForkCommentOnly, reasonDependencyMajor, reasonDependencyUnknown, reasonOutsideMergeWindow,
+	reasonWorkflowsAwaiting, reasonMergeCondition,
+
+	reasonRunTimeout, reasonRateLimitBudget, reasonRepoActionCap, reasonRepoRateLimited,
+	reasonCircuitBreaker, reasonRepoCircuitBreaker, reasonRepoArchived, reasonPlanStale,
+	reasonDraft, reasonDoNotTouch, reasonReviewAlreadyRequested, reasonOperatorSkip, reasonOperatorQuit,
+	reasonIntentUnconfirmed, reasonUnchangedSinceLastRun, reasonHeadMoved,
+
+	reasonMergeable, reasonMergeQueued, reasonMergeQueuePosition, reasonAutoMerge,
+	reasonAutoMergePending, reasonAfterUpdate, reasonClosedStale, reasonUntouched,
+	reasonMarkedReady, reasonReviewDismissed, reasonReviewRequested, reasonCIRerun, reasonOperatorComment,
+	reasonIntentConfirmed, reasonWorkflowsApproved,
+
+	reasonPRTimeout, reasonPRViewFailed, reasonCloseFailed, reasonMarkReadyFailed,
+	reasonReviewThreadsLookupFailed, reasonCommitsLookupFailed, reasonDiffLookupFailed,
+	reasonChangedFilesLookupFailed, reasonOperatorCommentFailed, reasonEnqueueFailed,
+	reasonMergeFailed, reasonEnableAutoMergeFailed, reasonUpdateBranchFailed,
+	reasonConflictCommentFailed, reasonDismissReviewFailed, reasonCIRerunFailed, reasonCommentFailed,
+	reasonApproveWorkflowsFailed,
+}
+
+// parseReason splits a Reason into its code and detail, for outcomes
+// recorded before they carried codes:
+//
+//	checks_failure                          → checks_failure
+//	dry_run_mergeable                       → mergeable, "dry run"
+//	checks_failure_already_commented        → checks_failure, "already commented"
+//	merge_queue_position_3                  → merge_queue_position, "3"
+//	merge_condition_small                   → merge_condition, "small"
+//	merge failed (after retries): HTTP 502  → merge_failed, "HTTP 502 (after retries)"
+//
+// A reason it doesn't know is reasonOther, detailed by the reason itself.
+func parseReason(reason string) (reasonCode, string) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", ""
+	}
+	if code := reasonCode(reason); slices.Contains(knownReasonCodes, code) {
+		return code, ""
+	}
+	if rest, ok := strings.CutPrefix(reason, "dry_run_"); ok {
+		code, detail := parseReason(rest)
+		return code, joinDetail(dryRunDetail, detail)
+	}
+	if rest, ok := strings.CutSuffix(reason, "_already_commented"); ok {
+		code, detail := parseReason(rest)
+		return code, joinDetail(alreadyCommentedDetail, detail)
+	}
+	if step, rest, ok := strings.Cut(reason, " failed"); ok {
+		for code, s := range failedSteps {
+			if s != step {
+				continue
+			}
+			how, err, _ := strings.Cut(rest, ": ")
+			detail := err
+			if how = strings.TrimSpace(how); how != "" {
+				detail += " " + how
+			}
+			return code, strings.TrimSpace(detail)
+		}
+	}
+	if rest, ok := strings.CutPrefix(reason, "timeout: "); ok {
+		return reasonPRTimeout, rest
+	}
+	if rest, ok := strings.CutPrefix(reason, "after_"); ok {
+		return reasonAfterUpdate, rest
+	}
+	for _, c := range []reasonCode{reasonMergeQueuePosition, reasonUntouched, reasonMergeCondition} {
+		if rest, ok := strings.CutPrefix(reason, string(c)+"_"); ok {
+			return c, rest
+		}
+	}
+	return reasonOther, reason
+}
+
+// joinDetail prefixes a parsed reason's detail with more.
+func joinDetail(more string, detail string) string {
+	if detail == "" {
+		return more
+	}
+	return more + ": " + detail
+}
+
+// withReasonCode fills in ReasonCode and ReasonDetail from Reason, for an
+// outcome recorded before they existed.
+func (o prOutcome) withReasonCode() prOutcome {
+	code, detail := parseReason(o.Reason)
+	o.ReasonCode = code
+	if detail != "" {
+		o.ReasonDetail = detail
+	}
+	return o
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestParseReason(t *testing.T) {
+	tests := []struct {
+		reason string
+		code   reasonCode
+		detail string
+	}{
+		{"", "", ""},
+		{"checks_failure", reasonChecksFailure, ""},
+		{"repo_circuit_breaker", reasonRepoCircuitBreaker, ""},
+		{"dry_run_mergeable", reasonMergeable, "dry run"},
+		{"dry_run_branch_behind", reasonBranchBehind, "dry run"},
+		{"review_required_already_commented", reasonReviewRequired, "already commented"},
+		{"merge_queue_position_3", reasonMergeQueuePosition, "3"},
+		{"untouched_30d", reasonUntouched, "30d"},
+		{"merge_condition_small", reasonMergeCondition, "small"},
+		{"after_branch_updated", reasonAfterUpdate, "branch_updated"},
+		{"timeout: no result within 2m0s", reasonPRTimeout, "no result within 2m0s"},
+		{"merge failed (after retries): HTTP 502: bad gateway", reasonMergeFailed, "HTTP 502: bad gateway (after retries)"},
+		{"pr view failed (permanent): not found", reasonPRViewFailed, "not found (permanent)"},
+		{"enable auto-merge failed (permanent): nope", reasonEnableAutoMergeFailed, "nope (permanent)"},
+		{"ci rerun failed: boom", reasonCIRerunFailed, "boom"},
+		{"something new", reasonOther, "something new"},
+		{"launch failed: boom", reasonOther, "launch failed: boom"},
+	}
+	for _, tt := range tests {
+		code, detail := parseReason(tt.reason)
+		if code != tt.code || detail != tt.detail {
+			t.Errorf("parseReason(%q) = %q, %q; want %q, %q", tt.reason, code, detail, tt.code, tt.detail)
+		}
+	}
+}
+
+func TestParseReasonKnownCodes(t *testing.T) {
+	seen := map[reasonCode]bool{}
+	for _, c := range knownReasonCodes {
+		if seen[c] {
+			t.Errorf("%s listed twice", c)
+		}
+		seen[c] = true
+		if code, detail := parseReason(string(c)); code != c || detail != "" {
+			t.Errorf("parseReason(%q) = %q, %q", c, code, detail)
+		}
+	}
+}
+
+func TestPipelineSetsReasonCodes(t *testing.T) {
+	failing := fakePR("misty-step/api", 1)
+	failing.Mergeable = "CONFLICTING"
+	useFakeGitHub(t, newFakeGitHub(failing))
+	out, err := newPipeline(testPipelineOptions(t, "-dry-run")).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 1 {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	r := out.Results[0]
+	if r.Reason != "dry_run_mergeable_conflicting" || r.ReasonCode != reasonMergeableConflicting || r.ReasonDetail != "dry run" {
+		t.Errorf("outcome = %q, %q, %q", r.Reason, r.ReasonCode, r.ReasonDetail)
+	}
+}
+
+func TestPipelineReasonCodesMatchReason(t *testing.T) {
+	failing := fakePR("misty-step/api", 2)
+	failing.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "lint", Status: "COMPLETED", Conclusion: "FAILURE"}}
+	conflicting := fakePR("misty-step/api", 3)
+	conflicting.Mergeable = "CONFLICTING"
+	unreviewed := fakePR("misty-step/web", 4)
+	unreviewed.ReviewDecision = "REVIEW_REQUIRED"
+	held := fakePR("misty-step/web", 5)
+	held.Labels = []label{{Name: "hold"}}
+	prs := []*prView{fakePR("misty-step/api", 1), failing, conflicting, unreviewed, held}
+
+	for _, dryRun := range []bool{false, true} {
+		args := []string{"-max-prs", "10"}
+		if dryRun {
+			args = append(args, "-dry-run")
+		}
+		useFakeGitHub(t, newFakeGitHub(prs...))
+		out, err := newPipeline(testPipelineOptions(t, args...)).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Results) != len(prs) {
+			t.Fatalf("results = %+v", out.Results)
+		}
+		// Codes are set where each outcome is decided; the reason text
+		// must still say the same thing.
+		for _, r := range out.Results {
+			if r.Reason == "" {
+				continue // merged
+			}
+			if !slices.Contains(knownReasonCodes, r.ReasonCode) {
+				t.Errorf("%s: code %q isn't a known code (reason %q)", r.URL, r.ReasonCode, r.Reason)
+			}
+			if code, detail := parseReason(r.Reason); code != r.ReasonCode || detail != r.ReasonDetail {
+				t.Errorf("%s: reason %q reads as %q, %q; outcome has %q, %q", r.URL, r.Reason, code, detail, r.ReasonCode, r.ReasonDetail)
+			}
+		}
+	}
+}
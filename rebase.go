@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// rebaseFetchDepth is how much history each fetch pulls; rebaseMaxDeepen
+// bounds how many times we deepen looking for the merge base.
+const (
+	rebaseFetchDepth = 50
+	rebaseMaxDeepen  = 4
+)
+
+// rebaseCommitter is the committer identity on rebased commits (authors are kept).
+const (
+	rebaseCommitterName  = "fab-pr-pipeline"
+	rebaseCommitterEmail = "fab-pr-pipeline@users.noreply.github.com"
+)
+
+// errRebaseConflict means the rebase stopped on a conflict a human must resolve.
+var errRebaseConflict = errors.New("rebase hit conflicts")
+
+// repoCloneURL returns the HTTPS clone URL of the repo a PR is in, on the
+// PR's own host (github.com or the GH_HOST Enterprise Server). ok is false
+// for a URL on neither.
+func repoCloneURL(prURL string) (string, bool) {
+	ref, ok := parsePRURL(prURL, githubHosts())
+	if !ok {
+		return "", false
+	}
+	return "https://" + ref.Host + "/" + ref.nameWithOwner() + ".git", true
+}
+
+// rebasePRBranch rebases head onto base in a throwaway shallow clone of
+// remoteURL and force-pushes head if the rebase applies cleanly. The push
+// uses --force-with-lease against the fetched head, so commits pushed in the
+// meantime are never overwritten. Git authenticates through gh.
+func rebasePRBranch(ctx context.Context, remoteURL string, base string, head string) error {
+	dir, err := os.MkdirTemp("", "fab-pr-rebase-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	gitCtx := func(ctx context.Context, args ...string) (string, error) {
+		full := append([]string{
+			"-c", "credential.helper=",
+			"-c", "credential.helper=!gh auth git-credential",
+			"-c", "user.name=" + rebaseCommitterName,
+			"-c", "user.email=" + rebaseCommitterEmail,
+			"-C", dir,
+		}, args...)
+		out, err := runCmd(ctx, "git", full...)
+		return strings.TrimSpace(string(out)), err
+	}
+	git := func(args ...string) (string, error) { return gitCtx(ctx, args...) }
+
+	baseRef, headRef := "refs/remotes/origin/"+base, "refs/remotes/origin/"+head
+	refspecs := []string{"+refs/heads/" + base + ":" + baseRef, "+refs/heads/" + head + ":" + headRef}
+	if _, err := git("init", "--quiet"); err != nil {
+		return err
+	}
+	if _, err := git("remote", "add", "origin", remoteURL); err != nil {
+		return err
+	}
+	if _, err := git(append([]string{"fetch", "--quiet", "--no-tags", fmt.Sprintf("--depth=%d", rebaseFetchDepth), "origin"}, refspecs...)...); err != nil {
+		return err
+	}
+
+	// A shallow clone may not reach the merge base; rebasing without one would
+	// replay the branch's whole visible history, so deepen or give up.
+	for i := 0; ; i++ {
+		if _, err := git("merge-base", baseRef, headRef); err == nil {
+			break
+		}
+		if i == rebaseMaxDeepen {
+			return fmt.Errorf("no merge base within %d commits", rebaseFetchDepth*(rebaseMaxDeepen+1))
+		}
+		if _, err := git(append([]string{"fetch", "--quiet", "--no-tags", fmt.Sprintf("--deepen=%d", rebaseFetchDepth), "origin"}, refspecs...)...); err != nil {
+			return err
+		}
+	}
+
+	headOID, err := git("rev-parse", headRef)
+	if err != nil {
+		return err
+	}
+	if _, err := git("checkout", "--quiet", "-B", head, headRef); err != nil {
+		return err
+	}
+	if _, err := git("rebase", "--quiet", baseRef); err != nil {
+		_, _ = git("rebase", "--abort")
+		return fmt.Errorf("%w: %v", errRebaseConflict, err)
+	}
+	return auditGit(ctx, "force_push", remoteURL+" "+head, func(ctx context.Context) error {
+		_, err := gitCtx(ctx, "push", "--quiet", "--force-with-lease=refs/heads/"+head+":"+headOID, "origin", "HEAD:refs/heads/"+head)
+		return err
+	})
+}
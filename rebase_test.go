@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitRun runs git in dir with a fixed identity, failing the test on error.
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	full := append([]string{"-C", dir, "-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)
+	out, err := exec.Command("git", full...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newRebaseFixture creates a bare "origin" with main and a feature branch
+// forked from it, then adds commits to main. conflicting controls whether
+// main and feature edit the same file.
+func newRebaseFixture(t *testing.T, conflicting bool) (origin string, work string) {
+	t.Helper()
+	root := t.TempDir()
+	origin = filepath.Join(root, "origin.git")
+	work = filepath.Join(root, "work")
+	gitRun(t, root, "init", "--quiet", "--bare", "--initial-branch=main", origin)
+	gitRun(t, root, "clone", "--quiet", origin, work)
+
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(work, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("shared.txt", "base\n")
+	gitRun(t, work, "add", ".")
+	gitRun(t, work, "commit", "--quiet", "-m", "base")
+	gitRun(t, work, "push", "--quiet", "origin", "HEAD:main")
+
+	gitRun(t, work, "checkout", "--quiet", "-b", "feature")
+	write("feature.txt", "feature\n")
+	if conflicting {
+		write("shared.txt", "feature edit\n")
+	}
+	gitRun(t, work, "add", ".")
+	gitRun(t, work, "commit", "--quiet", "-m", "feature work")
+	gitRun(t, work, "push", "--quiet", "origin", "feature")
+
+	gitRun(t, work, "checkout", "--quiet", "main")
+	write("shared.txt", "main edit\n")
+	gitRun(t, work, "commit", "--quiet", "-am", "main moves on")
+	gitRun(t, work, "push", "--quiet", "origin", "main")
+	return origin, work
+}
+
+func TestRebasePRBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	ctx := context.Background()
+
+	t.Run("clean rebase is pushed", func(t *testing.T) {
+		origin, _ := newRebaseFixture(t, false)
+		if err := rebasePRBranch(ctx, origin, "main", "feature"); err != nil {
+			t.Fatalf("rebasePRBranch: %v", err)
+		}
+		mainOID := gitRun(t, origin, "rev-parse", "refs/heads/main")
+		parent := gitRun(t, origin, "rev-parse", "refs/heads/feature~1")
+		if parent != mainOID {
+			t.Errorf("feature should sit on top of main: parent %s, main %s", parent, mainOID)
+		}
+		if subject := gitRun(t, origin, "log", "-1", "--format=%s", "refs/heads/feature"); subject != "feature work" {
+			t.Errorf("unexpected head commit %q", subject)
+		}
+	})
+
+	t.Run("conflicting rebase leaves the branch alone", func(t *testing.T) {
+		origin, _ := newRebaseFixture(t, true)
+		before := gitRun(t, origin, "rev-parse", "refs/heads/feature")
+		err := rebasePRBranch(ctx, origin, "main", "feature")
+		if !errors.Is(err, errRebaseConflict) {
+			t.Fatalf("expected errRebaseConflict, got %v", err)
+		}
+		if after := gitRun(t, origin, "rev-parse", "refs/heads/feature"); after != before {
+			t.Errorf("feature branch moved on a failed rebase: %s -> %s", before, after)
+		}
+	})
+}
+
+func TestRepoCloneURL(t *testing.T) {
+	t.Setenv("GH_HOST", "ghe.example.com")
+	tests := map[string]string{
+		"https://github.com/misty-step/api/pull/12": "https://github.com/misty-step/api.git",
+		"https://ghe.example.com/team/svc/pull/3":   "https://ghe.example.com/team/svc.git",
+		"https://elsewhere.example.com/a/b/pull/1":  "",
+	}
+	for prURL, want := range tests {
+		if got, _ := repoCloneURL(prURL); got != want {
+			t.Errorf("repoCloneURL(%q) = %q; want %q", prURL, got, want)
+		}
+	}
+}
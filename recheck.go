@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// recheckInterval is how often --recheck-after-update re-fetches a PR while
+// CI runs on its updated branch.
+const recheckInterval = 30 * time.Second
+
+// awaitUpdatedChecks polls a PR after its branch was updated from oldHead,
+// for up to wait, until CI on the new head has finished. It reports whether
+// the PR came out green and mergeable.
+func awaitUpdatedChecks(ctx context.Context, oldHead string, wait time.Duration, fetch func() (*prView, error)) bool {
+	for i := time.Duration(0); i < wait/recheckInterval; i++ {
+		if err := mergeablePollSleep(ctx, recheckInterval); err != nil {
+			return false
+		}
+		view, err := fetch()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[recheck] re-fetch failed: %v\n", err)
+			return false
+		}
+		if view.HeadRefOid == oldHead {
+			continue // the update hasn't landed yet
+		}
+		switch state := strings.ToUpper(overallChecksState(view.StatusCheckRollup)); state {
+		case "", "PENDING":
+			continue // CI hasn't started or is still running
+		case "SUCCESS":
+			if mergeableUnknown(view) {
+				continue
+			}
+			return strings.EqualFold(view.Mergeable, "MERGEABLE")
+		default:
+			fmt.Fprintf(os.Stderr, "[recheck] %s: checks %s after the update; leaving it for the next run\n", view.URL, strings.ToLower(state))
+			return false
+		}
+	}
+	return false
+}
+
+// recheckAfterUpdate follows a successful branch update: with
+// --recheck-after-update it waits for CI on the new head and, once green,
+// processes the PR again so it merges this run instead of the next. The
+// update's outcome stands if the PR doesn't end up merged. Each PR is
+// rechecked at most once per run.
+func (p *Pipeline) recheckAfterUpdate(ctx context.Context, run *pipelineRun, results []prOutcome, pr searchPR, updated prOutcome, oldHead string) prOutcome {
+	if p.opts.RecheckAfterUpdate <= 0 || run.rechecked[pr.URL] {
+		return updated
+	}
+	run.rechecked[pr.URL] = true
+	fmt.Fprintf(os.Stderr, "[recheck] %s: %s; waiting up to %s for CI\n", pr.URL, updated.Action, p.opts.RecheckAfterUpdate)
+	green := awaitUpdatedChecks(ctx, oldHead, p.opts.RecheckAfterUpdate, func() (*prView, error) {
+		return ghPRView(ctx, pr.URL)
+	})
+	if !green {
+		return updated
+	}
+	again := p.processPR(ctx, run, results, pr)
+	if again.Action != "merged" && again.Action != "enqueued" {
+		return updated
+	}
+	again.setReason(reasonAfterUpdate, updated.Action)
+	return again
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubRecheckSleep replaces the poll sleep with one that runs step on each
+// call, standing in for CI progressing on the updated branch.
+func stubRecheckSleep(t *testing.T, fake *fakeGitHub, step func(i int, pr *prView)) {
+	t.Helper()
+	orig := mergeablePollSleep
+	i := 0
+	mergeablePollSleep = func(ctx context.Context, d time.Duration) error {
+		fake.mu.Lock()
+		step(i, fake.prs[0])
+		fake.mu.Unlock()
+		i++
+		return ctx.Err()
+	}
+	t.Cleanup(func() { mergeablePollSleep = orig })
+}
+
+func TestPipelineRecheckAfterUpdate(t *testing.T) {
+	ci := func(pass bool) func(i int, pr *prView) {
+		return func(i int, pr *prView) {
+			switch i {
+			case 0: // the update landed; CI starts on the new head
+				pr.HeadRefOid = "def456"
+				pr.MergeStateStatus = "CLEAN"
+				pr.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "test", Status: "IN_PROGRESS"}}
+			case 2:
+				conclusion := "FAILURE"
+				if pass {
+					conclusion = "SUCCESS"
+				}
+				pr.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "test", Status: "COMPLETED", Conclusion: conclusion}}
+			}
+		}
+	}
+	tests := []struct {
+		name   string
+		args   []string
+		step   func(i int, pr *prView)
+		want   string
+		merged bool
+	}{
+		{"green merges this run", []string{"-recheck-after-update", "3m"}, ci(true), "merged/after_branch_updated", true},
+		{"red leaves the update", []string{"-recheck-after-update", "3m"}, ci(false), "branch_updated/branch_behind", false},
+		{"no CI within the wait", []string{"-recheck-after-update", "1m"}, ci(true), "branch_updated/branch_behind", false},
+		{"off by default", nil, ci(true), "branch_updated/branch_behind", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := fakePR("misty-step/api", 1)
+			pr.MergeStateStatus = "BEHIND"
+			fake := newFakeGitHub(pr)
+			stubRecheckSleep(t, fake, tt.step)
+
+			p := newPipeline(testPipelineOptions(t, tt.args...))
+			p.client = fake
+			out, err := p.Run(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out.Results) != 1 {
+				t.Fatalf("results = %+v", out.Results)
+			}
+			if got := out.Results[0].Action + "/" + out.Results[0].Reason; got != tt.want {
+				t.Errorf("result = %s; want %s", got, tt.want)
+			}
+			if got := len(fake.merged) > 0; got != tt.merged {
+				t.Errorf("merged = %v; want %v", fake.merged, tt.merged)
+			}
+			if !fake.called("pr update-branch") {
+				t.Error("branch was not updated")
+			}
+		})
+	}
+}
+
+func TestPrepareRecheckAfterUpdate(t *testing.T) {
+	for _, o := range []*runOptions{
+		{Org: "misty-step", MaxPRs: 5, RecheckAfterUpdate: -time.Minute},
+		{Org: "misty-step", MaxPRs: 5, RecheckAfterUpdate: 5 * time.Minute, PRTimeout: 5 * time.Minute},
+	} {
+		if err := o.prepare(); err == nil || !strings.Contains(err.Error(), "--recheck-after-update") {
+			t.Errorf("prepare(recheck %s, pr-timeout %s) = %v; want a --recheck-after-update error", o.RecheckAfterUpdate, o.PRTimeout, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// redactedMark replaces each secret redact finds.
+const redactedMark = "[REDACTED]"
+
+// secretEnvVars hold credentials whose values never belong in output.
+var secretEnvVars = []string{
+	"GH_TOKEN", "GITHUB_TOKEN", "DISCORD_BOT_TOKEN", "PIPELINE_API_TOKEN",
+	"GITHUB_WEBHOOK_SECRET", "SMTP_PASSWORD", "SLACK_WEBHOOK_URL",
+}
+
+// redactPatterns match credentials by shape, for the ones the pipeline
+// was never told about: a token in a git remote URL, a header gh echoed
+// back with --include, another tool's webhook.
+var redactPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`\b(gh[pousr]_)[A-Za-z0-9]{20,}`), "${1}" + redactedMark},
+	{regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{20,}`), "github_pat_" + redactedMark},
+	{regexp.MustCompile(`(?i)\b(authorization:\s*(?:bearer|token|basic|bot)\s+)[^\s"']+`), "${1}" + redactedMark},
+	{regexp.MustCompile(`(://[^/\s:@]*:)[^@\s/]+@`), "${1}" + redactedMark + "@"},
+	{regexp.MustCompile(`(?i)([?&](?:access_token|token|client_secret|signature|sig)=)[^&\s"']+`), "${1}" + redactedMark},
+	{regexp.MustCompile(`(discord(?:app)?\.com/api/webhooks/\d+/)[\w-]+`), "${1}" + redactedMark},
+	{regexp.MustCompile(`(hooks\.slack\.com/services/)[\w/]+`), "${1}" + redactedMark},
+	{regexp.MustCompile(`\beyJ[\w-]{10,}\.eyJ[\w-]{10,}\.[\w-]+`), redactedMark}, // a JWT
+}
+
+// minSecretLen keeps short values (a test token, "x") from redacting
+// ordinary words.
+const minSecretLen = 8
+
+var (
+	redactMu     sync.Mutex
+	redactValues []string
+)
+
+// addRedaction registers values, such as tokens the pipeline minted or
+// was handed, for redact to remove wherever they turn up.
+func addRedaction(values ...string) {
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	for _, v := range values {
+		if v = strings.TrimSpace(v); len(v) >= minSecretLen {
+			redactValues = append(redactValues, v)
+		}
+	}
+}
+
+// redact removes credentials from s: the registered values, the secret
+// environment variables' values, and anything shaped like a token.
+func redact(s string) string {
+	redactMu.Lock()
+	known := append([]string{}, redactValues...)
+	redactMu.Unlock()
+	for _, name := range secretEnvVars {
+		if v := strings.TrimSpace(os.Getenv(name)); len(v) >= minSecretLen {
+			known = append(known, v)
+		}
+	}
+	for _, v := range known {
+		s = strings.ReplaceAll(s, v, redactedMark)
+	}
+	for _, p := range redactPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// redacted returns o with the secrets taken out of its reason, which can
+// carry a command's error message.
+func (o prOutcome) redacted() prOutcome {
+	o.Reason = redact(o.Reason)
+	o.ReasonDetail = redact(o.ReasonDetail)
+	return o
+}
+
+// redactStderr swaps os.Stderr for a pipe whose writes are redacted on the
+// way to the real stderr, so no log line can leak a credential. The
+// returned func puts stderr back and waits for what was written to drain;
+// call it before exiting.
+func redactStderr() func() {
+	stderr := os.Stderr
+	r, w, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+	os.Stderr = w
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		copyRedacted(stderr, r)
+	}()
+	return func() {
+		os.Stderr = stderr
+		_ = w.Close()
+		<-done
+	}
+}
+
+// copyRedacted copies src to dst a read at a time, redacting each. A log
+// line is one write, so it arrives in one read.
+func copyRedacted(dst io.Writer, src io.Reader) {
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			_, _ = io.WriteString(dst, redact(string(buf[:n])))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// repoActionWindow is the span the hourly per-repo action limit counts over.
+const repoActionWindow = time.Hour
+
+// repoActionLog is when the pipeline acted in each repo over the past
+// hour, across runs.
+type repoActionLog map[string][]time.Time
+
+// repoActionsPath returns where the log is kept, beside the dedup state
+// file.
+func repoActionsPath(statePath string) string {
+	return filepath.Join(filepath.Dir(statePath), "repo-actions.json")
+}
+
+// loadRepoActions reads the log at path, dropping actions older than the
+// window. A missing or unreadable file is an empty log.
+func loadRepoActions(path string, now time.Time) repoActionLog {
+	log := repoActionLog{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return log
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		fmt.Fprintf(os.Stderr, "[repo-rate-limit] ignoring unreadable %s: %v\n", path, err)
+		return repoActionLog{}
+	}
+	log.prune(now)
+	return log
+}
+
+// loadRunRepoActions returns the log from the history DB h, or from its
+// file beside statePath when there's no DB. With a DB there's nothing to
+// save: the run's outcomes, recorded there, are the log.
+func loadRunRepoActions(h *historyDB, statePath string, now time.Time) repoActionLog {
+	if h == nil {
+		return loadRepoActions(repoActionsPath(statePath), now)
+	}
+	log, err := h.RecentRepoActions(now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[repo-rate-limit] failed to read the history db: %v\n", err)
+		return repoActionLog{}
+	}
+	return log
+}
+
+// prune drops actions that have aged out of the window.
+func (l repoActionLog) prune(now time.Time) {
+	for repo, times := range l {
+		kept := times[:0]
+		for _, t := range times {
+			if now.Sub(t) < repoActionWindow {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == 0 {
+			delete(l, repo)
+		} else {
+			l[repo] = kept
+		}
+	}
+}
+
+// recent counts the logged actions in repo, not including this run's.
+func (l repoActionLog) recent(repo string) int {
+	return len(l[repo])
+}
+
+// record adds the run's actions (anything but a skip or an error) at now.
+func (l repoActionLog) record(results []prOutcome, now time.Time) {
+	for _, r := range results {
+		if r.Action == "skipped" || r.Action == "error" {
+			continue
+		}
+		l[r.Repo] = append(l[r.Repo], now.UTC())
+	}
+}
+
+// saveRepoActions writes the log to path, logging failures.
+func saveRepoActions(path string, l repoActionLog) {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[repo-rate-limit] failed to save %s: %v\n", path, err)
+	}
+}
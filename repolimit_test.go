@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRepoActionLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo-actions.json")
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	log := loadRepoActions(path, now)
+	log.record([]prOutcome{
+		{Repo: "misty-step/api", Action: "merged"},
+		{Repo: "misty-step/api", Action: "commented"},
+		{Repo: "misty-step/api", Action: "skipped"},
+		{Repo: "misty-step/web", Action: "error"},
+	}, now.Add(-50*time.Minute))
+	saveRepoActions(path, log)
+
+	if got := loadRepoActions(path, now).recent("misty-step/api"); got != 2 {
+		t.Errorf("recent = %d; want 2", got)
+	}
+	later := loadRepoActions(path, now.Add(15*time.Minute))
+	if len(later) != 0 {
+		t.Errorf("after the hour: %v; want an empty log", later)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadRepoActions(path, now); len(got) != 0 {
+		t.Errorf("unreadable file: %v", got)
+	}
+}
+
+func TestPipelineRepoRateLimited(t *testing.T) {
+	now := time.Now()
+	fake := newFakeGitHub(fakePR("misty-step/api", 1), fakePR("misty-step/api", 2), fakePR("misty-step/web", 3), fakePR("misty-step/web", 4))
+	for i, pr := range fake.prs {
+		fake.updatedAt[pr.URL] = now.Add(-time.Duration(i) * time.Minute)
+	}
+	opts := testPipelineOptions(t, "-repo-max-actions-per-hour", "1")
+	opts.config = &pipelineConfig{Repos: map[string]repoPolicy{"misty-step/web": {MaxActionsPerHour: 3}}}
+
+	// An earlier run merged one api PR 30 minutes ago, and one web PR two
+	// hours ago, which no longer counts.
+	path := repoActionsPath(resolveStatePath(opts.StateFile))
+	saveRepoActions(path, repoActionLog{
+		"misty-step/api": {now.Add(-30 * time.Minute)},
+		"misty-step/web": {now.Add(-2 * time.Hour)},
+	})
+
+	p := newPipeline(opts)
+	p.client = fake
+	p.now = func() time.Time { return now }
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range out.Results {
+		got = append(got, r.Action+"/"+r.Reason)
+	}
+	want := []string{"skipped/repo_rate_limited", "skipped/repo_rate_limited", "merged/", "merged/"}
+	if !slices.Equal(got, want) {
+		t.Errorf("results = %v; want %v", got, want)
+	}
+
+	log := loadRepoActions(path, now)
+	if log.recent("misty-step/api") != 1 || log.recent("misty-step/web") != 2 {
+		t.Errorf("saved log = %v; want 1 api and 2 web actions", log)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// rollupEntryName is the name GitHub matches required checks against: a
+// CheckRun's name or a StatusContext's context.
+func rollupEntryName(e statusRollupEntry) string {
+	if strings.TrimSpace(e.Typename) == "StatusContext" {
+		return e.Context
+	}
+	return e.Name
+}
+
+// splitRequiredChecks splits a status rollup into the entries that gate
+// merging (the required checks) and the optional rest. A required check
+// that hasn't reported yet is added as an EXPECTED status so it reads as
+// pending. With no required checks, every entry gates, as before.
+func splitRequiredChecks(entries []statusRollupEntry, required []string) (gating []statusRollupEntry, optional []statusRollupEntry) {
+	if len(required) == 0 {
+		return entries, nil
+	}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		name := rollupEntryName(e)
+		if slices.Contains(required, name) {
+			gating = append(gating, e)
+			seen[name] = true
+		} else {
+			optional = append(optional, e)
+		}
+	}
+	for _, name := range required {
+		if !seen[name] {
+			gating = append(gating, statusRollupEntry{Typename: "StatusContext", Context: name, State: "EXPECTED"})
+		}
+	}
+	return gating, optional
+}
+
+// ghRequiredChecks returns the status checks that branch protection and
+// rulesets require on base. Nil means nothing is required.
+func ghRequiredChecks(ctx context.Context, repo string, base string) ([]string, error) {
+	branch := url.PathEscape(base)
+	stdout, err := runGh(ctx, "api", fmt.Sprintf("repos/%s/branches/%s", repo, branch))
+	if err != nil {
+		return nil, err
+	}
+	required, err := parseProtectionChecks(stdout)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err = runGh(ctx, "api", "--paginate", fmt.Sprintf("repos/%s/rules/branches/%s", repo, branch))
+	if err != nil {
+		return nil, err
+	}
+	ruleChecks, err := parseRulesetChecks(stdout)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range ruleChecks {
+		if !slices.Contains(required, name) {
+			required = append(required, name)
+		}
+	}
+	return required, nil
+}
+
+// parseProtectionChecks reads the required status checks out of a branch's
+// classic protection, as returned by the branches API.
+func parseProtectionChecks(raw []byte) ([]string, error) {
+	var branch struct {
+		Protection struct {
+			Enabled              bool `json:"enabled"`
+			RequiredStatusChecks struct {
+				Contexts []string `json:"contexts"`
+				Checks   []struct {
+					Context string `json:"context"`
+				} `json:"checks"`
+			} `json:"required_status_checks"`
+		} `json:"protection"`
+	}
+	if err := json.Unmarshal(raw, &branch); err != nil {
+		return nil, fmt.Errorf("parse branch json: %w", err)
+	}
+	if !branch.Protection.Enabled {
+		return nil, nil
+	}
+	var required []string
+	add := func(name string) {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(required, name) {
+			required = append(required, name)
+		}
+	}
+	for _, c := range branch.Protection.RequiredStatusChecks.Contexts {
+		add(c)
+	}
+	for _, c := range branch.Protection.RequiredStatusChecks.Checks {
+		add(c.Context)
+	}
+	return required, nil
+}
+
+// parseRulesetChecks reads the required status checks out of the rules that
+// apply to a branch (gh api --paginate output, possibly several arrays).
+func parseRulesetChecks(raw []byte) ([]string, error) {
+	type rule struct {
+		Type       string `json:"type"`
+		Parameters struct {
+			RequiredStatusChecks []struct {
+				Context string `json:"context"`
+			} `json:"required_status_checks"`
+		} `json:"parameters"`
+	}
+	var required []string
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	for {
+		var page []rule
+		if err := dec.Decode(&page); err != nil {
+			if errors.Is(err, io.EOF) {
+				return required, nil
+			}
+			return nil, fmt.Errorf("parse branch rules json: %w", err)
+		}
+		for _, r := range page {
+			if r.Type != "required_status_checks" {
+				continue
+			}
+			for _, c := range r.Parameters.RequiredStatusChecks {
+				if name := strings.TrimSpace(c.Context); name != "" && !slices.Contains(required, name) {
+					required = append(required, name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitRequiredChecks(t *testing.T) {
+	entries := []statusRollupEntry{
+		{Typename: "CheckRun", Name: "test", Status: "COMPLETED", Conclusion: "SUCCESS"},
+		{Typename: "CheckRun", Name: "lint", Status: "COMPLETED", Conclusion: "FAILURE"},
+		{Typename: "StatusContext", Context: "ci/build", State: "SUCCESS"},
+	}
+
+	t.Run("no required checks gates on everything", func(t *testing.T) {
+		gating, optional := splitRequiredChecks(entries, nil)
+		if len(gating) != 3 || optional != nil {
+			t.Fatalf("gating=%d optional=%d", len(gating), len(optional))
+		}
+		if got := overallChecksState(gating); got != "FAILURE" {
+			t.Errorf("state = %s; want FAILURE", got)
+		}
+	})
+
+	t.Run("optional failure doesn't gate", func(t *testing.T) {
+		gating, optional := splitRequiredChecks(entries, []string{"test", "ci/build"})
+		if got := overallChecksState(gating); got != "SUCCESS" {
+			t.Errorf("state = %s; want SUCCESS", got)
+		}
+		if got := failingCheckNames(optional); !slices.Equal(got, []string{"lint"}) {
+			t.Errorf("optional failures = %v; want [lint]", got)
+		}
+	})
+
+	t.Run("missing required check is pending", func(t *testing.T) {
+		gating, _ := splitRequiredChecks(entries, []string{"test", "deploy-preview"})
+		if got := overallChecksState(gating); got != "PENDING" {
+			t.Errorf("state = %s; want PENDING", got)
+		}
+	})
+}
+
+func TestParseProtectionChecks(t *testing.T) {
+	raw := `{"name":"main","protection":{"enabled":true,"required_status_checks":{"contexts":["ci/build","test"],"checks":[{"context":"test","app_id":15368},{"context":"lint","app_id":null}]}}}`
+	got, err := parseProtectionChecks([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ci/build", "test", "lint"}; !slices.Equal(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+
+	got, err = parseProtectionChecks([]byte(`{"name":"dev","protection":{"enabled":false,"required_status_checks":{"contexts":["x"]}}}`))
+	if err != nil || got != nil {
+		t.Errorf("unprotected branch: got %v, %v", got, err)
+	}
+	if _, err := parseProtectionChecks([]byte("nope")); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func TestParseRulesetChecks(t *testing.T) {
+	raw := `[{"type":"deletion"},{"type":"required_status_checks","parameters":{"required_status_checks":[{"context":"test"},{"context":"e2e","integration_id":1}]}}]` +
+		`[{"type":"required_status_checks","parameters":{"required_status_checks":[{"context":"e2e"},{"context":"security"}]}}]`
+	got, err := parseRulesetChecks([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"test", "e2e", "security"}; !slices.Equal(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+	if got, err := parseRulesetChecks([]byte("[]")); err != nil || got != nil {
+		t.Errorf("no rules: got %v, %v", got, err)
+	}
+}
+
+func TestBuildCommentBodyOptionalChecks(t *testing.T) {
+	pr := &prView{
+		Mergeable:         "MERGEABLE",
+		ReviewDecision:    "REVIEW_REQUIRED",
+		StatusCheckRollup: []statusRollupEntry{{Typename: "CheckRun", Name: "test", Status: "COMPLETED", Conclusion: "SUCCESS"}},
+		OptionalChecks:    []statusRollupEntry{{Typename: "CheckRun", Name: "coverage", Status: "COMPLETED", Conclusion: "FAILURE"}},
+	}
+	body := buildCommentBody(pr, "review_required")
+	if !strings.Contains(body, "Failing optional checks") || !strings.Contains(body, "coverage") {
+		t.Errorf("comment should list the optional failure:\n%s", body)
+	}
+	if strings.Contains(body, "\nFailing checks:") {
+		t.Errorf("optional failure listed as blocking:\n%s", body)
+	}
+}
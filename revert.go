@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// revertOutput is the JSON printed by the revert subcommand.
+type revertOutput struct {
+	Ok  bool   `json:"ok"`
+	URL string `json:"url"`
+	// MergedAt and MergeRequestID are the audit log's record of the merge
+	// being undone.
+	MergedAt       string `json:"mergedAt,omitempty"`
+	MergeRequestID string `json:"mergeRequestId,omitempty"`
+	RevertURL      string `json:"revertUrl,omitempty"`
+	RevertNumber   int    `json:"revertNumber,omitempty"`
+	DryRun         bool   `json:"dryRun,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// runRevertCommand implements `fab-pr-pipeline revert <pr-url>`: undo a
+// merge the pipeline made by opening a PR that reverts it, then comment on
+// the original linking the two. Only merges in the audit log can be
+// reverted, so it never undoes a human's merge.
+func runRevertCommand(args []string) int {
+	var prURL string
+	var flags []string
+	for _, arg := range args {
+		if _, ok := parsePRURL(arg, githubHosts()); ok && prURL == "" {
+			prURL = arg
+			continue
+		}
+		flags = append(flags, arg)
+	}
+	opts, code := parseRunFlags("revert", flags)
+	if opts == nil {
+		return code
+	}
+	if prURL == "" {
+		fmt.Fprintln(os.Stderr, "usage: fab-pr-pipeline revert <pr-url> [-reason text] [-draft] [flags]")
+		return 2
+	}
+	ref, _ := parsePRURL(prURL, githubHosts())
+	ctx, cancel := opts.runContext()
+	defer cancel()
+	out := revertPR(ctx, opts, ref)
+	emitJSON(out)
+	if !out.Ok {
+		return 1
+	}
+	return 0
+}
+
+// revertPR reverts the pipeline's merge of the PR at ref. With --dry-run it
+// checks the merge can be reverted and stops there.
+func revertPR(ctx context.Context, opts *runOptions, ref prRef) revertOutput {
+	out := revertOutput{URL: ref.url(), DryRun: opts.DryRun}
+	fail := func(err error) revertOutput {
+		out.Error = err.Error()
+		return out
+	}
+	merge, err := auditedMerge(opts.auditLogFile(), out.URL)
+	if err != nil {
+		return fail(err)
+	}
+	out.MergedAt, out.MergeRequestID = merge.Time.UTC().Format(time.RFC3339), merge.RequestID
+
+	ctx = withAuditPR(withGitHubClient(ctx, opts.audit.wrap(githubClient)), out.URL, merge.HeadSHA)
+	pr, err := ghMergedPR(ctx, out.URL)
+	if err != nil {
+		return fail(err)
+	}
+	if pr.State != "MERGED" {
+		return fail(fmt.Errorf("%s is %s, not merged", out.URL, strings.ToLower(pr.State)))
+	}
+	if opts.DryRun {
+		out.Ok = true
+		return out
+	}
+
+	revert, err := ghRevertPR(ctx, pr.ID, revertTitle(pr), revertBody(pr, merge, opts.revertReason), opts.revertDraft)
+	if err != nil {
+		return fail(fmt.Errorf("open revert PR: %w", err))
+	}
+	out.RevertURL, out.RevertNumber = revert.URL, revert.Number
+	out.Ok = true
+	// The revert is open either way; a missing link back is only logged.
+	if err := ghPRComment(ctx, out.URL, revertLinkComment(revert.URL, opts.revertReason)); err != nil {
+		fmt.Fprintf(os.Stderr, "[revert] %s: revert PR %s opened, but the comment linking it failed: %v\n", out.URL, revert.URL, err)
+	}
+	return out
+}
+
+// auditedMerge returns the audit log's entry for the pipeline's merge of
+// url. It refuses a PR the pipeline never merged, and one it has already
+// opened a revert for.
+func auditedMerge(path string, url string) (auditEntry, error) {
+	entries, err := loadAuditLog(path)
+	if err != nil {
+		return auditEntry{}, fmt.Errorf("read audit log: %w", err)
+	}
+	var merge *auditEntry
+	for i, e := range entries {
+		if e.PR != url || !e.OK {
+			continue
+		}
+		switch e.Action {
+		case "merge":
+			merge = &entries[i]
+		case "revert":
+			return auditEntry{}, fmt.Errorf("%s was already reverted at %s (audit request %s)", url, e.Time.UTC().Format(time.RFC3339), e.RequestID)
+		}
+	}
+	if merge == nil {
+		return auditEntry{}, fmt.Errorf("no merge of %s in the audit log %s; revert only undoes the pipeline's own merges", url, path)
+	}
+	return *merge, nil
+}
+
+// mergedPR is what revert needs to know about the PR it undoes.
+type mergedPR struct {
+	ID     string `json:"id"`
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	State  string `json:"state"`
+}
+
+func ghMergedPR(ctx context.Context, url string) (*mergedPR, error) {
+	stdout, err := runGh(ctx, "pr", "view", url, "--json", "id,number,title,state")
+	if err != nil {
+		return nil, err
+	}
+	var pr mergedPR
+	if err := json.Unmarshal(stdout, &pr); err != nil {
+		return nil, fmt.Errorf("parse gh pr view json: %w", err)
+	}
+	return &pr, nil
+}
+
+// revertedPR is the revert PR GitHub opened.
+type revertedPR struct {
+	URL    string `json:"url"`
+	Number int    `json:"number"`
+}
+
+// ghRevertPR opens a PR reverting a merged one, the way GitHub's Revert
+// button does: a new branch off the base with the merge undone.
+func ghRevertPR(ctx context.Context, pullRequestNodeID string, title string, body string, draft bool) (*revertedPR, error) {
+	if strings.TrimSpace(pullRequestNodeID) == "" {
+		return nil, errors.New("pull request node id required")
+	}
+	query := `mutation($pullRequestId: ID!, $title: String!, $body: String!, $draft: Boolean!) {
+  revertPullRequest(input: { pullRequestId: $pullRequestId, title: $title, body: $body, draft: $draft }) {
+    revertPullRequest { url number }
+  }
+}`
+	args := []string{
+		"api", "graphql",
+		"-f", "query=" + query,
+		"-f", "pullRequestId=" + pullRequestNodeID,
+		"-f", "title=" + title,
+		"-f", "body=" + body,
+		"-F", fmt.Sprintf("draft=%t", draft),
+	}
+	stdout, err := runGh(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data struct {
+			RevertPullRequest struct {
+				RevertPullRequest revertedPR `json:"revertPullRequest"`
+			} `json:"revertPullRequest"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(stdout, &resp); err != nil {
+		return nil, fmt.Errorf("parse revert response: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return nil, errors.New(resp.Errors[0].Message)
+	}
+	revert := resp.Data.RevertPullRequest.RevertPullRequest
+	if revert.URL == "" {
+		return nil, errors.New("revert mutation returned no pull request")
+	}
+	return &revert, nil
+}
+
+// revertTitle follows GitHub's own: Revert "<original title>".
+func revertTitle(pr *mergedPR) string {
+	return "Revert \"" + pr.Title + "\""
+}
+
+func revertBody(pr *mergedPR, merge auditEntry, reason string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Reverts #%d.\n\n", pr.Number)
+	fmt.Fprintf(&b, "fab-pr-pipeline merged #%d at %s (head `%s`, audit request `%s`); this undoes that merge.\n",
+		pr.Number, merge.Time.UTC().Format(time.RFC3339), shortSHA(merge.HeadSHA), merge.RequestID)
+	if reason = strings.TrimSpace(reason); reason != "" {
+		fmt.Fprintf(&b, "\nReason: %s\n", reason)
+	}
+	return b.String()
+}
+
+// revertLinkComment is left on the original PR, pointing at its revert.
+func revertLinkComment(revertURL string, reason string) string {
+	msg := fmt.Sprintf("This PR's merge by fab-pr-pipeline is being reverted in %s.", revertURL)
+	if reason = strings.TrimSpace(reason); reason != "" {
+		msg += "\n\nReason: " + reason
+	}
+	return msg
+}
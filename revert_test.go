@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRevertPR(t *testing.T) {
+	const url = "https://github.com/misty-step/api/pull/42"
+	state := "MERGED"
+	var reverts, comments []string
+	old := githubClient
+	t.Cleanup(func() { githubClient = old })
+	githubClient = ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+		switch {
+		case args[0] == "pr" && args[1] == "view":
+			return []byte(`{"id":"PR_42","number":42,"title":"Bump lodash","state":"` + state + `"}`), nil
+		case args[0] == "pr" && args[1] == "comment":
+			comments = append(comments, args[4])
+			return nil, nil
+		case args[0] == "api" && args[1] == "graphql":
+			fields := ghFields(args)
+			if !strings.Contains(fields["query"], "revertPullRequest(") {
+				t.Fatalf("unexpected query %q", fields["query"])
+			}
+			reverts = append(reverts, fields["pullRequestId"]+" "+fields["title"]+" "+fields["draft"]+"\n"+fields["body"])
+			return []byte(`{"data":{"revertPullRequest":{"revertPullRequest":{"url":"https://github.com/misty-step/api/pull/43","number":43}}}}`), nil
+		}
+		t.Fatalf("unexpected gh %v", args)
+		return nil, nil
+	})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.jsonl")
+	ref, _ := parsePRURL(url, githubHosts())
+	opts := func(args ...string) *runOptions {
+		return testPipelineOptions(t, append([]string{"-audit-log", path}, args...)...)
+	}
+
+	if out := revertPR(t.Context(), opts(), ref); out.Ok || !strings.Contains(out.Error, "no merge of "+url) {
+		t.Fatalf("revert with no audited merge = %+v; want refused", out)
+	}
+
+	merged := time.Date(2025, 6, 2, 14, 3, 11, 0, time.UTC)
+	newAuditLog(path).record(auditEntry{Time: merged, RequestID: "9f2c41d0-3", Action: "merge", PR: url, HeadSHA: "abc1234def", Actor: "gh login", OK: true})
+
+	if out := revertPR(t.Context(), opts("-dry-run"), ref); !out.Ok || !out.DryRun || out.MergeRequestID != "9f2c41d0-3" || len(reverts) > 0 || len(comments) > 0 {
+		t.Fatalf("dry run = %+v, reverts %v, comments %v; want ok with nothing done", out, reverts, comments)
+	}
+
+	state = "OPEN"
+	if out := revertPR(t.Context(), opts(), ref); out.Ok || !strings.Contains(out.Error, "open, not merged") {
+		t.Fatalf("revert of an open PR = %+v; want refused", out)
+	}
+	state = "MERGED"
+
+	o := opts()
+	o.revertReason = "broke the build"
+	out := revertPR(t.Context(), o, ref)
+	if !out.Ok || out.RevertURL != "https://github.com/misty-step/api/pull/43" || out.RevertNumber != 43 || out.MergedAt != "2025-06-02T14:03:11Z" {
+		t.Fatalf("revert = %+v; want PR 43 opened", out)
+	}
+	if len(reverts) != 1 || !strings.HasPrefix(reverts[0], `PR_42 Revert "Bump lodash" false`) || !strings.Contains(reverts[0], "Reverts #42.") ||
+		!strings.Contains(reverts[0], "9f2c41d0-3") || !strings.Contains(reverts[0], "Reason: broke the build") {
+		t.Errorf("revert mutation = %q; want the original PR, its title, and the audited merge", reverts)
+	}
+	if len(comments) != 1 || !strings.Contains(comments[0], "pull/43") || !strings.Contains(comments[0], "broke the build") {
+		t.Errorf("comments = %q; want the original PR linked to its revert", comments)
+	}
+
+	var actions []string
+	for _, e := range readAuditLog(t, path) {
+		actions = append(actions, e.Action)
+		if e.Action != "merge" && (e.PR != url || e.HeadSHA != "abc1234def") {
+			t.Errorf("entry %+v; want it tied to the reverted PR", e)
+		}
+	}
+	if strings.Join(actions, ",") != "merge,revert,comment" {
+		t.Errorf("audit actions = %v; want the revert and the comment logged", actions)
+	}
+	if out := revertPR(t.Context(), opts(), ref); out.Ok || !strings.Contains(out.Error, "already reverted") {
+		t.Errorf("second revert = %+v; want refused", out)
+	}
+}
+
+func TestRevertTitle(t *testing.T) {
+	for title, want := range map[string]string{
+		"Bump lodash":          `Revert "Bump lodash"`,
+		`Use "strict" mode`:    `Revert "Use "strict" mode"`,
+		"Fix naïve date → UTC": `Revert "Fix naïve date → UTC"`,
+	} {
+		if got := revertTitle(&mergedPR{Title: title}); got != want {
+			t.Errorf("revertTitle(%q) = %q; want %q", title, got, want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// reviewArtifactGist as --review-artifact saves each artifact as a secret
+// gist instead of a file.
+const reviewArtifactGist = "gist"
+
+// reviewArtifact is the full review feedback on a PR with changes
+// requested, saved for a review-fix agent, which would otherwise only see
+// the truncated alert.
+type reviewArtifact struct {
+	PRURL          string         `json:"pr_url"`
+	Repo           string         `json:"repo"`
+	Number         int            `json:"number"`
+	Title          string         `json:"title"`
+	Author         string         `json:"author"`
+	BaseRef        string         `json:"base_ref,omitempty"`
+	HeadRef        string         `json:"head_ref,omitempty"`
+	HeadSHA        string         `json:"head_sha,omitempty"`
+	ReviewDecision string         `json:"review_decision"`
+	ReviewComments string         `json:"review_comments,omitempty"`
+	ReviewThreads  []reviewThread `json:"review_threads"`
+	GeneratedAt    string         `json:"generated_at"`
+}
+
+func newReviewArtifact(pr *prView, repo string, number int, comments string, threads []reviewThread, now time.Time) reviewArtifact {
+	a := reviewArtifact{
+		PRURL:          pr.URL,
+		Repo:           repo,
+		Number:         number,
+		Title:          pr.Title,
+		Author:         pr.Author.Login,
+		BaseRef:        pr.BaseRefName,
+		HeadRef:        pr.HeadRefName,
+		HeadSHA:        pr.HeadRefOid,
+		ReviewDecision: pr.ReviewDecision,
+		ReviewComments: comments,
+		ReviewThreads:  threads,
+		GeneratedAt:    now.UTC().Format(time.RFC3339),
+	}
+	if a.ReviewThreads == nil {
+		a.ReviewThreads = []reviewThread{}
+	}
+	return a
+}
+
+// reviewArtifactName is the artifact's file name: one per PR, replaced each
+// time the PR is dispatched.
+func reviewArtifactName(repo string, number int) string {
+	return fmt.Sprintf("review-%s-%d.json", strings.ReplaceAll(repo, "/", "-"), number)
+}
+
+// saveReviewArtifact writes the artifact to dest, a directory or
+// reviewArtifactGist, and returns where it went: the file path or the gist
+// URL. Failures are only logged and return ""; the alert still goes out.
+func saveReviewArtifact(ctx context.Context, dest string, a reviewArtifact) string {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[review-artifact] %s: %v\n", a.PRURL, err)
+		return ""
+	}
+	data = append(data, '\n')
+	name := reviewArtifactName(a.Repo, a.Number)
+	if dest == reviewArtifactGist {
+		url, err := RetryableWithResult(ctx, func() (string, error) {
+			return ghCreateGist(ctx, name, data)
+		}, retryCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[review-artifact] gist for %s failed: %v\n", a.PRURL, err)
+			return ""
+		}
+		return url
+	}
+	path := filepath.Join(dest, name)
+	err = os.MkdirAll(dest, 0755)
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[review-artifact] %s: %v\n", a.PRURL, err)
+		return ""
+	}
+	return path
+}
+
+// ghCreateGist creates a secret gist holding data as name and returns its
+// URL.
+func ghCreateGist(ctx context.Context, name string, data []byte) (string, error) {
+	stdout, err := runGhInput(ctx, data, "gist", "create", "--filename", name, "-")
+	if err != nil {
+		return "", err
+	}
+	url := strings.TrimSpace(string(stdout))
+	if url == "" {
+		return "", fmt.Errorf("gh gist create printed no URL")
+	}
+	return url, nil
+}
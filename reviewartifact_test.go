@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipelineSavesReviewArtifact(t *testing.T) {
+	discord := newFakeDiscord(t)
+	pr := fakePR("misty-step/api", 7)
+	pr.ReviewDecision = "CHANGES_REQUESTED"
+	fake := newFakeGitHub(pr)
+	fake.threads[pr.URL] = []reviewThread{{Path: "main.go", Line: 42, Author: "phaedrus", Body: "Handle the error."}}
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	dir := filepath.Join(t.TempDir(), "artifacts")
+	p := newPipeline(testPipelineOptions(t, "-review-artifact", dir, "-discord-alerts-to", "channel:999"))
+	p.client = fake
+	p.now = func() time.Time { return now }
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "review-misty-step-api-7.json")
+	if len(out.Results) != 1 || out.Results[0].Action != "review_dispatched" || out.Results[0].ReviewArtifact != path {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got reviewArtifact
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.PRURL != pr.URL || got.Number != 7 || got.HeadSHA != "abc123" || got.ReviewDecision != "CHANGES_REQUESTED" ||
+		got.GeneratedAt != "2025-01-15T12:00:00Z" || !slices.Equal(got.ReviewThreads, fake.threads[pr.URL]) {
+		t.Errorf("artifact = %+v", got)
+	}
+	if msgs := discord.messages["999"]; len(msgs) != 1 || !strings.HasSuffix(msgs[0], "\nFull feedback: "+path) {
+		t.Errorf("alerts = %q; want one linking the artifact", msgs)
+	}
+}
+
+func TestSaveReviewArtifactGist(t *testing.T) {
+	var gotArgs []string
+	var gotBody []byte
+	ctx := withGitHubClient(context.Background(), ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+		gotArgs, gotBody = args, stdin
+		return []byte("https://gist.github.com/kaylee/abc\n"), nil
+	}))
+	a := newReviewArtifact(fakePR("misty-step/api", 7), "misty-step/api", 7, "Please split this up.", nil, time.Now())
+	if got := saveReviewArtifact(ctx, reviewArtifactGist, a); got != "https://gist.github.com/kaylee/abc" {
+		t.Errorf("location = %q", got)
+	}
+	if want := []string{"gist", "create", "--filename", "review-misty-step-api-7.json", "-"}; !slices.Equal(gotArgs, want) {
+		t.Errorf("args = %q; want %q", gotArgs, want)
+	}
+	if !strings.Contains(string(gotBody), `"review_comments": "Please split this up."`) || !strings.Contains(string(gotBody), `"review_threads": []`) {
+		t.Errorf("gist body = %s", gotBody)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// codeownersRule is one CODEOWNERS line: a path pattern and its owners.
+type codeownersRule struct {
+	Pattern string
+	Owners  []string
+}
+
+// codeownersPaths are the locations GitHub reads CODEOWNERS from, in order.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// parseCodeowners parses a CODEOWNERS file, skipping comments, blank lines,
+// and rules without owners.
+func parseCodeowners(content string) []codeownersRule {
+	var rules []codeownersRule
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		rules = append(rules, codeownersRule{Pattern: fields[0], Owners: fields[1:]})
+	}
+	return rules
+}
+
+// codeownersMatch reports whether a CODEOWNERS pattern matches file (a repo
+// path without a leading slash). Supports the common gitignore-style forms:
+// "*", "*.ext", "dir/", "/anchored/path", and "**" segments.
+func codeownersMatch(pattern string, file string) bool {
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	parts := strings.Split(file, "/")
+	if !anchored {
+		// Unanchored patterns match any path component (or trailing run of them).
+		for i := range parts {
+			if matchPathPrefix(pattern, parts[i:], dirOnly) {
+				return true
+			}
+		}
+		return false
+	}
+	return matchPathPrefix(pattern, parts, dirOnly)
+}
+
+// matchPathPrefix matches pattern against the leading segments of parts. A
+// match on a directory also covers everything beneath it.
+func matchPathPrefix(pattern string, parts []string, dirOnly bool) bool {
+	pat := strings.Split(pattern, "/")
+	var match func(p, f int) bool
+	match = func(p, f int) bool {
+		if p == len(pat) {
+			if f == len(parts) {
+				return !dirOnly
+			}
+			// A directory match covers the rest of the path, but as on
+			// GitHub, a trailing wildcard ("docs/*") doesn't recurse.
+			return !strings.Contains(pat[len(pat)-1], "*")
+		}
+		if pat[p] == "**" {
+			for k := f; k <= len(parts); k++ {
+				if match(p+1, k) {
+					return true
+				}
+			}
+			return false
+		}
+		if f == len(parts) {
+			return false
+		}
+		ok, _ := path.Match(pat[p], parts[f])
+		return ok && match(p+1, f+1)
+	}
+	return match(0, 0)
+}
+
+// codeownersFor returns the owners of files, using GitHub's rule that the
+// last matching pattern wins, in first-seen order without duplicates.
+func codeownersFor(rules []codeownersRule, files []string) []string {
+	var owners []string
+	seen := make(map[string]bool)
+	for _, f := range files {
+		for i := len(rules) - 1; i >= 0; i-- {
+			if !codeownersMatch(rules[i].Pattern, f) {
+				continue
+			}
+			for _, o := range rules[i].Owners {
+				if !seen[o] {
+					seen[o] = true
+					owners = append(owners, o)
+				}
+			}
+			break
+		}
+	}
+	return owners
+}
+
+// pickReviewer chooses one reviewer from candidates, never the PR author.
+// The choice rotates with the PR number so load spreads across the pool.
+// Returns "" if nobody is eligible. Owners are returned without the "@".
+func pickReviewer(candidates []string, author string, number int) string {
+	var eligible []string
+	for _, c := range candidates {
+		c = strings.TrimPrefix(strings.TrimSpace(c), "@")
+		if c == "" || strings.EqualFold(c, author) || strings.Contains(c, "@") {
+			// Email owners can't be requested through the API.
+			continue
+		}
+		eligible = append(eligible, c)
+	}
+	if len(eligible) == 0 {
+		return ""
+	}
+	if number < 0 {
+		number = -number
+	}
+	return eligible[number%len(eligible)]
+}
+
+// findReviewer picks a reviewer for a PR from pool, or from the CODEOWNERS of
+// the files it changes when pool is empty.
+func findReviewer(ctx context.Context, repo string, pr *prView, number int, pool []string) (string, error) {
+	if len(pool) > 0 {
+		return pickReviewer(pool, pr.Author.Login, number), nil
+	}
+	content, err := ghCodeowners(ctx, repo, pr.BaseRefName)
+	if err != nil || content == "" {
+		return "", err
+	}
+	files, err := RetryableWithResult(ctx, func() ([]string, error) {
+		return ghPRFiles(ctx, pr.URL)
+	}, retryCfg)
+	if err != nil {
+		return "", err
+	}
+	return pickReviewer(codeownersFor(parseCodeowners(content), files), pr.Author.Login, number), nil
+}
+
+// ghCodeowners returns the repo's CODEOWNERS file at ref, or "" if it has none.
+func ghCodeowners(ctx context.Context, repo string, ref string) (string, error) {
+	for _, p := range codeownersPaths {
+		endpoint := fmt.Sprintf("repos/%s/contents/%s", repo, p)
+		if ref != "" {
+			endpoint += "?ref=" + ref
+		}
+		stdout, err := runGh(ctx, "api", "-H", "Accept: application/vnd.github.raw", endpoint)
+		if err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "not found") {
+				continue
+			}
+			return "", err
+		}
+		return string(stdout), nil
+	}
+	return "", nil
+}
+
+func ghPRFiles(ctx context.Context, url string) ([]string, error) {
+	stdout, err := runGh(ctx, "pr", "view", url, "--json", "files")
+	if err != nil {
+		return nil, err
+	}
+	var v struct {
+		Files []struct {
+			Path string `json:"path"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(stdout, &v); err != nil {
+		return nil, fmt.Errorf("parse gh pr files json: %w", err)
+	}
+	files := make([]string, 0, len(v.Files))
+	for _, f := range v.Files {
+		files = append(files, f.Path)
+	}
+	return files, nil
+}
+
+// ghRequestReview requests a review from a user or an org/team slug.
+func ghRequestReview(ctx context.Context, url string, reviewer string) error {
+	_, err := runGh(ctx, "pr", "edit", url, "--add-reviewer", reviewer)
+	return err
+}
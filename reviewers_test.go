@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCodeowners(t *testing.T) {
+	content := `# Owners
+*       @org/core
+/docs/  @writer  # docs team
+
+*.go @gopher @org/backend
+orphan
+`
+	want := []codeownersRule{
+		{Pattern: "*", Owners: []string{"@org/core"}},
+		{Pattern: "/docs/", Owners: []string{"@writer"}},
+		{Pattern: "*.go", Owners: []string{"@gopher", "@org/backend"}},
+	}
+	if got := parseCodeowners(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCodeowners = %+v; want %+v", got, want)
+	}
+}
+
+func TestCodeownersMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{"*", "main.go", true},
+		{"*", "a/b/c.txt", true},
+		{"*.go", "cmd/tool/main.go", true},
+		{"*.go", "README.md", false},
+		{"/docs/", "docs/guide.md", true},
+		{"/docs/", "src/docs/guide.md", false},
+		{"docs/", "src/docs/guide.md", true},
+		{"build/", "src/build/out.txt", true},
+		{"/build/logs/", "build/logs/a/b.log", true},
+		{"apps/", "apps", false},
+		{"/scripts/deploy.sh", "scripts/deploy.sh", true},
+		{"docs/*", "docs/getting-started.md", true},
+		{"docs/*", "docs/build-app/troubleshooting.md", false},
+		{"**/logs", "deep/nested/logs/x.log", true},
+		{"/api/**/handlers/", "api/v1/handlers/user.go", true},
+		{"/api/**/handlers/", "api/handlers/user.go", true},
+	}
+	for _, tt := range tests {
+		if got := codeownersMatch(tt.pattern, tt.file); got != tt.want {
+			t.Errorf("codeownersMatch(%q, %q) = %v; want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestCodeownersFor_lastMatchWins(t *testing.T) {
+	rules := parseCodeowners(`
+* @default
+*.go @gopher
+/docs/ @writer
+`)
+	got := codeownersFor(rules, []string{"main.go", "docs/a.md", "Makefile", "util.go"})
+	want := []string{"@gopher", "@writer", "@default"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("codeownersFor = %v; want %v", got, want)
+	}
+}
+
+func TestPickReviewer(t *testing.T) {
+	pool := []string{"@alice", "bob", "carol@example.com", "org/team"}
+
+	t.Run("rotates by PR number", func(t *testing.T) {
+		got := []string{pickReviewer(pool, "", 0), pickReviewer(pool, "", 1), pickReviewer(pool, "", 2)}
+		want := []string{"alice", "bob", "org/team"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("pickReviewer = %v; want %v", got, want)
+		}
+	})
+
+	t.Run("never picks the author", func(t *testing.T) {
+		for n := 0; n < 4; n++ {
+			if got := pickReviewer([]string{"Alice", "bob"}, "alice", n); got != "bob" {
+				t.Errorf("pickReviewer(n=%d) = %q; want bob", n, got)
+			}
+		}
+	})
+
+	t.Run("empty when only the author is eligible", func(t *testing.T) {
+		if got := pickReviewer([]string{"@alice", "a@b.c"}, "alice", 3); got != "" {
+			t.Errorf("pickReviewer = %q; want empty", got)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxAlertThreads caps how many review threads an author alert lists.
+const maxAlertThreads = 10
+
+// reviewThread is an unresolved inline review thread: where it is and its
+// opening comment, which usually carries the actionable feedback.
+type reviewThread struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line,omitempty"`
+	Author string `json:"author,omitempty"`
+	Body   string `json:"body"`
+}
+
+// location renders the thread's file and line as path:line.
+func (t reviewThread) location() string {
+	if t.Line > 0 {
+		return t.Path + ":" + strconv.Itoa(t.Line)
+	}
+	return t.Path
+}
+
+// ghUnresolvedReviewThreads returns the PR's unresolved review threads.
+// Outdated threads (on lines the PR no longer changes) report no line.
+func ghUnresolvedReviewThreads(ctx context.Context, repo string, number int) ([]reviewThread, error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" {
+		return nil, fmt.Errorf("invalid repo %q", repo)
+	}
+	query := `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      reviewThreads(first: 100) {
+        nodes {
+          isResolved
+          path
+          line
+          comments(first: 1) { nodes { body author { login } } }
+        }
+      }
+    }
+  }
+}`
+	args := []string{
+		"api", "graphql",
+		"-f", "query=" + query,
+		"-f", "owner=" + owner,
+		"-f", "name=" + name,
+		"-F", "number=" + strconv.Itoa(number),
+	}
+	stdout, err := runGh(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data struct {
+			Repository struct {
+				PullRequest struct {
+					ReviewThreads struct {
+						Nodes []struct {
+							IsResolved bool   `json:"isResolved"`
+							Path       string `json:"path"`
+							Line       int    `json:"line"`
+							Comments   struct {
+								Nodes []struct {
+									Body   string `json:"body"`
+									Author struct {
+										Login string `json:"login"`
+									} `json:"author"`
+								} `json:"nodes"`
+							} `json:"comments"`
+						} `json:"nodes"`
+					} `json:"reviewThreads"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(stdout, &resp); err != nil {
+		return nil, fmt.Errorf("parse review threads response: %w", err)
+	}
+	var threads []reviewThread
+	for _, n := range resp.Data.Repository.PullRequest.ReviewThreads.Nodes {
+		if n.IsResolved || len(n.Comments.Nodes) == 0 {
+			continue
+		}
+		c := n.Comments.Nodes[0]
+		threads = append(threads, reviewThread{
+			Path:   n.Path,
+			Line:   n.Line,
+			Author: c.Author.Login,
+			Body:   strings.TrimSpace(c.Body),
+		})
+	}
+	return threads, nil
+}
+
+// reviewAlertMessage is the author alert for a PR with changes requested:
+// the review bodies, then the unresolved threads one line each.
+func reviewAlertMessage(url string, comments string, threads []reviewThread) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔧 PR %s has changes requested.", url)
+	if comments != "" {
+		fmt.Fprintf(&b, " Review comments:\n%s", comments)
+	}
+	if len(threads) > 0 {
+		fmt.Fprintf(&b, "\nUnresolved review threads (%d):", len(threads))
+		for i, t := range threads {
+			if i == maxAlertThreads {
+				fmt.Fprintf(&b, "\n…and %d more", len(threads)-i)
+				break
+			}
+			body := strings.Join(strings.Fields(t.Body), " ")
+			b.WriteString("\n" + truncateEmbedLine(fmt.Sprintf("- `%s` %s", t.location(), body)))
+		}
+	}
+	b.WriteString("\nAction needed: address review feedback.")
+	return b.String()
+}
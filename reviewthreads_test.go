@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGhUnresolvedReviewThreads(t *testing.T) {
+	resp := `{"data":{"repository":{"pullRequest":{"reviewThreads":{"nodes":[
+		{"isResolved":false,"path":"main.go","line":42,"comments":{"nodes":[{"body":" Handle the error. ","author":{"login":"phaedrus"}}]}},
+		{"isResolved":true,"path":"main.go","line":7,"comments":{"nodes":[{"body":"typo","author":{"login":"phaedrus"}}]}},
+		{"isResolved":false,"path":"old.go","line":0,"comments":{"nodes":[{"body":"outdated","author":{"login":"kaylee"}}]}}
+	]}}}}}`
+	var gotArgs []string
+	ctx := withGitHubClient(context.Background(), ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+		gotArgs = args
+		return []byte(resp), nil
+	}))
+	threads, err := ghUnresolvedReviewThreads(ctx, "misty-step/api", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []reviewThread{
+		{Path: "main.go", Line: 42, Author: "phaedrus", Body: "Handle the error."},
+		{Path: "old.go", Author: "kaylee", Body: "outdated"},
+	}
+	if !reflect.DeepEqual(threads, want) {
+		t.Errorf("threads = %+v; want %+v", threads, want)
+	}
+	if f := ghFields(gotArgs); f["owner"] != "misty-step" || f["name"] != "api" || f["number"] != "7" {
+		t.Errorf("fields = %v", f)
+	}
+}
+
+func TestReviewAlertMessage(t *testing.T) {
+	const url = "https://github.com/misty-step/api/pull/7"
+	if got, want := reviewAlertMessage(url, "Please split this up.", nil),
+		"🔧 PR "+url+" has changes requested. Review comments:\nPlease split this up.\nAction needed: address review feedback."; got != want {
+		t.Errorf("comments only:\n%s\nwant:\n%s", got, want)
+	}
+
+	var threads []reviewThread
+	for i := 1; i <= maxAlertThreads+2; i++ {
+		threads = append(threads, reviewThread{Path: "main.go", Line: i, Body: "Rename\nthis."})
+	}
+	threads[1].Line = 0
+	got := reviewAlertMessage(url, "", threads)
+	for _, want := range []string{
+		"Unresolved review threads (12):",
+		"- `main.go:1` Rename this.",
+		"- `main.go` Rename this.",
+		"…and 2 more",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("message missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "main.go:11") || strings.Contains(got, "Review comments") {
+		t.Errorf("message lists too much:\n%s", got)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runDiff is what changed between two runs, keyed by PR.
+type runDiff struct {
+	// Since is when the run being compared against started.
+	Since            string     `json:"since,omitempty"`
+	NewlyMergeable   []prChange `json:"newlyMergeable"`
+	NewlyConflicting []prChange `json:"newlyConflicting"`
+	Recovered        []prChange `json:"recovered"`
+	Changed          []prChange `json:"changed"`
+	Unchanged        int        `json:"unchanged"`
+}
+
+// prChange is one PR whose state moved between runs. Was is empty for PRs
+// the previous run didn't look at.
+type prChange struct {
+	URL  string `json:"url"`
+	Repo string `json:"repo"`
+	Was  string `json:"was,omitempty"`
+	Now  string `json:"now"`
+}
+
+// outcomeState reduces an outcome to the state compared across runs: the
+// blocking reason, or "mergeable". Dry-run and dedup decorations are
+// stripped so a dry run compares equal to the real run it previews.
+func outcomeState(o prOutcome) string {
+	switch o.Action {
+	case "merged", "enqueued", "auto_merge_enabled":
+		return "mergeable"
+	case "error":
+		return "error"
+	}
+	switch o.ReasonCode {
+	case reasonMergeable, reasonAuthorCommentOnly, reasonHoldLabel, reasonMergeQueued, reasonMergeQueuePosition, reasonAutoMerge:
+		return "mergeable"
+	case "":
+		return o.Action
+	}
+	return o.blocker()
+}
+
+// isFailingState reports whether a state means CI or the pipeline itself failed.
+func isFailingState(o prOutcome) bool {
+	return o.Action == "error" || strings.EqualFold(o.ChecksState, "FAILURE")
+}
+
+// diffRuns compares cur against prev. PRs only in prev (merged or closed
+// since) aren't reported.
+func diffRuns(prev []prOutcome, cur []prOutcome) runDiff {
+	before := make(map[string]prOutcome, len(prev))
+	for _, o := range prev {
+		before[o.URL] = o
+	}
+	d := runDiff{
+		NewlyMergeable:   []prChange{},
+		NewlyConflicting: []prChange{},
+		Recovered:        []prChange{},
+		Changed:          []prChange{},
+	}
+	for _, o := range cur {
+		now := outcomeState(o)
+		old, seen := before[o.URL]
+		was := ""
+		if seen {
+			was = outcomeState(old)
+		}
+		change := prChange{URL: o.URL, Repo: o.Repo, Was: was, Now: now}
+		switch {
+		case seen && was == now && isFailingState(old) == isFailingState(o):
+			d.Unchanged++
+		case now == "mergeable" && was != "mergeable":
+			d.NewlyMergeable = append(d.NewlyMergeable, change)
+		case now == "mergeable_conflicting" && was != "mergeable_conflicting":
+			d.NewlyConflicting = append(d.NewlyConflicting, change)
+		case seen && isFailingState(old) && !isFailingState(o):
+			d.Recovered = append(d.Recovered, change)
+		default:
+			d.Changed = append(d.Changed, change)
+		}
+	}
+	return d
+}
+
+// writeRunDiff prints the diff for a human, one line per changed PR.
+func writeRunDiff(w io.Writer, d runDiff) {
+	since := d.Since
+	if since == "" {
+		since = "no previous run"
+	}
+	fmt.Fprintf(w, "[dry-run-diff] vs %s: %d newly mergeable, %d newly conflicting, %d recovered, %d other changes, %d unchanged\n",
+		since, len(d.NewlyMergeable), len(d.NewlyConflicting), len(d.Recovered), len(d.Changed), d.Unchanged)
+	groups := []struct {
+		label   string
+		changes []prChange
+	}{
+		{"mergeable", d.NewlyMergeable},
+		{"conflicting", d.NewlyConflicting},
+		{"recovered", d.Recovered},
+		{"changed", d.Changed},
+	}
+	for _, g := range groups {
+		for _, c := range g.changes {
+			was := c.Was
+			if was == "" {
+				was = "new"
+			}
+			fmt.Fprintf(w, "[dry-run-diff] %-11s %s (%s -> %s)\n", g.label, c.URL, was, c.Now)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOutcomeState(t *testing.T) {
+	tests := []struct {
+		outcome prOutcome
+		want    string
+	}{
+		{prOutcome{Action: "merged"}, "mergeable"},
+		{prOutcome{Action: "skipped", Reason: "dry_run_mergeable"}, "mergeable"},
+		{prOutcome{Action: "commented", Reason: "author_comment_only"}, "mergeable"},
+		{prOutcome{Action: "enqueued", Reason: "merge_queue_position_2"}, "mergeable"},
+		{prOutcome{Action: "skipped", Reason: "dry_run_checks_failure"}, "checks_failure"},
+		{prOutcome{Action: "commented", Reason: "checks_failure"}, "checks_failure"},
+		{prOutcome{Action: "skipped", Reason: "checks_failure_already_commented"}, "checks_failure"},
+		{prOutcome{Action: "skipped", Reason: "dry_run_mergeable_conflicting"}, "mergeable_conflicting"},
+		{prOutcome{Action: "error", Reason: "pr view failed (permanent): boom"}, "error"},
+		{prOutcome{Action: "skipped"}, "skipped"},
+	}
+	for _, tt := range tests {
+		if got := outcomeState(tt.outcome.withReasonCode()); got != tt.want {
+			t.Errorf("outcomeState(%s/%s) = %q; want %q", tt.outcome.Action, tt.outcome.Reason, got, tt.want)
+		}
+	}
+}
+
+func TestDiffRuns(t *testing.T) {
+	prev := []prOutcome{
+		{URL: "u/1", Action: "commented", Reason: "checks_failure", ChecksState: "FAILURE"},
+		{URL: "u/2", Action: "commented", Reason: "review_required", ChecksState: "SUCCESS"},
+		{URL: "u/3", Action: "commented", Reason: "checks_failure", ChecksState: "FAILURE"},
+		{URL: "u/4", Action: "commented", Reason: "review_required", ChecksState: "SUCCESS"},
+		{URL: "u/5", Action: "commented", Reason: "checks_pending", ChecksState: "PENDING"},
+		{URL: "u/gone", Action: "commented", Reason: "review_required"},
+	}
+	cur := []prOutcome{
+		{URL: "u/1", Action: "skipped", Reason: "dry_run_mergeable", ChecksState: "SUCCESS"},
+		{URL: "u/2", Action: "skipped", Reason: "dry_run_mergeable_conflicting", ChecksState: "SUCCESS"},
+		{URL: "u/3", Action: "skipped", Reason: "dry_run_review_required", ChecksState: "SUCCESS"},
+		{URL: "u/4", Action: "skipped", Reason: "dry_run_review_required", ChecksState: "SUCCESS"},
+		{URL: "u/5", Action: "skipped", Reason: "dry_run_checks_failure", ChecksState: "FAILURE"},
+		{URL: "u/new", Action: "skipped", Reason: "dry_run_mergeable"},
+	}
+	for i := range prev {
+		prev[i] = prev[i].withReasonCode()
+	}
+	for i := range cur {
+		cur[i] = cur[i].withReasonCode()
+	}
+	d := diffRuns(prev, cur)
+
+	urls := func(changes []prChange) string {
+		var out []string
+		for _, c := range changes {
+			out = append(out, c.URL)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := urls(d.NewlyMergeable); got != "u/1,u/new" {
+		t.Errorf("NewlyMergeable = %s; want u/1,u/new", got)
+	}
+	if got := urls(d.NewlyConflicting); got != "u/2" {
+		t.Errorf("NewlyConflicting = %s; want u/2", got)
+	}
+	if got := urls(d.Recovered); got != "u/3" {
+		t.Errorf("Recovered = %s; want u/3", got)
+	}
+	if got := urls(d.Changed); got != "u/5" {
+		t.Errorf("Changed = %s; want u/5", got)
+	}
+	if d.Unchanged != 1 {
+		t.Errorf("Unchanged = %d; want 1", d.Unchanged)
+	}
+	if c := d.NewlyMergeable[0]; c.Was != "checks_failure" || c.Now != "mergeable" {
+		t.Errorf("change = %+v; want checks_failure -> mergeable", c)
+	}
+}
+
+func TestWriteRunDiff(t *testing.T) {
+	d := runDiff{
+		Since:          "2025-06-01T12:00:00Z",
+		NewlyMergeable: []prChange{{URL: "https://github.com/o/r/pull/1", Now: "mergeable"}},
+		Unchanged:      3,
+	}
+	var buf bytes.Buffer
+	writeRunDiff(&buf, d)
+	got := buf.String()
+	for _, want := range []string{
+		"vs 2025-06-01T12:00:00Z: 1 newly mergeable, 0 newly conflicting, 0 recovered, 0 other changes, 3 unchanged",
+		"https://github.com/o/r/pull/1 (new -> mergeable)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q; got:\n%s", want, got)
+		}
+	}
+}
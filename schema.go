@@ -0,0 +1,16 @@
+package main
+
+import (
+	_ "embed"
+)
+
+// runOutputSchemaVersion is runOutput's schemaVersion. Within a version the
+// format only grows: fields are added, and none is removed, renamed, or
+// retyped. A change that breaks that bumps the version.
+const runOutputSchemaVersion = 1
+
+// runOutputSchema is the JSON Schema for runOutput, printed by
+// --print-schema.
+//
+//go:embed schema/run-output.schema.json
+var runOutputSchema []byte
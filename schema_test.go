@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// jsonSchema is the subset of JSON Schema that schema/run-output.schema.json
+// uses.
+type jsonSchema struct {
+	Ref        string                 `json:"$ref"`
+	Type       string                 `json:"type"`
+	Const      any                    `json:"const"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	Defs       map[string]*jsonSchema `json:"$defs"`
+}
+
+func loadRunOutputSchema(t *testing.T) *jsonSchema {
+	t.Helper()
+	var s jsonSchema
+	if err := json.Unmarshal(runOutputSchema, &s); err != nil {
+		t.Fatal(err)
+	}
+	return &s
+}
+
+// resolve follows s's $ref, if any, into root's $defs.
+func (root *jsonSchema) resolve(t *testing.T, s *jsonSchema) *jsonSchema {
+	t.Helper()
+	if s.Ref == "" {
+		return s
+	}
+	def := root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+	if def == nil {
+		t.Fatalf("unresolved $ref %s", s.Ref)
+	}
+	return def
+}
+
+// schemaFields flattens the schema to "path type" lines, e.g.
+// "results[].reasonCode string".
+func (root *jsonSchema) schemaFields(t *testing.T, prefix string, s *jsonSchema, into map[string]string) {
+	s = root.resolve(t, s)
+	for name, p := range s.Properties {
+		path := prefix + name
+		p = root.resolve(t, p)
+		into[path] = p.Type
+		for p.Type == "array" {
+			path += "[]"
+			p = root.resolve(t, p.Items)
+		}
+		if p.Type == "object" {
+			root.schemaFields(t, path+".", p, into)
+		}
+	}
+}
+
+// goFields flattens a Go type's JSON encoding the same way.
+func goFields(prefix string, rt reflect.Type, into map[string]string, required map[string]bool) {
+	for rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + name
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		into[path] = jsonType(ft)
+		required[path] = !strings.Contains(opts, "omitempty")
+		for ft.Kind() == reflect.Slice {
+			path += "[]"
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			goFields(path+".", ft, into, required)
+		}
+	}
+}
+
+func jsonType(rt reflect.Type) string {
+	switch rt.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "array"
+	case reflect.Struct:
+		return "object"
+	}
+	return rt.Kind().String()
+}
+
+// The published schema describes exactly what runOutput encodes to.
+func TestRunOutputSchemaMatchesTypes(t *testing.T) {
+	root := loadRunOutputSchema(t)
+	got := map[string]string{}
+	root.schemaFields(t, "", root, got)
+	want, required := map[string]string{}, map[string]bool{}
+	goFields("", reflect.TypeOf(runOutput{}), want, required)
+
+	for path, typ := range want {
+		if got[path] != typ {
+			t.Errorf("%s: schema has type %q, runOutput encodes %q", path, got[path], typ)
+		}
+	}
+	for path := range got {
+		if _, ok := want[path]; !ok {
+			t.Errorf("%s: in the schema but not in runOutput", path)
+		}
+	}
+	// Required: properties without omitempty, per object.
+	var checkRequired func(prefix string, s *jsonSchema)
+	checkRequired = func(prefix string, s *jsonSchema) {
+		s = root.resolve(t, s)
+		for name, p := range s.Properties {
+			path := prefix + name
+			if slices.Contains(s.Required, name) != required[path] {
+				t.Errorf("%s: required in schema = %v, want %v", path, !required[path], required[path])
+			}
+			p = root.resolve(t, p)
+			for p.Type == "array" {
+				path += "[]"
+				p = root.resolve(t, p.Items)
+			}
+			if p.Type == "object" {
+				checkRequired(path+".", p)
+			}
+		}
+	}
+	checkRequired("", root)
+
+	if v := root.Properties["schemaVersion"].Const; v != float64(runOutputSchemaVersion) {
+		t.Errorf("schema's schemaVersion const = %v, want %d", v, runOutputSchemaVersion)
+	}
+}
+
+// Within a schema version, fields are only added: every field recorded for
+// the version must still be in the schema with the same type, and every
+// field in the schema must be recorded. Removing, renaming, or retyping a
+// field means bumping runOutputSchemaVersion and starting a new file.
+func TestRunOutputSchemaCompatibility(t *testing.T) {
+	root := loadRunOutputSchema(t)
+	current := map[string]string{}
+	root.schemaFields(t, "", root, current)
+
+	path := filepath.Join("testdata", "schema", fmt.Sprintf("run-output.v%d.fields", runOutputSchemaVersion))
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	recorded := map[string]bool{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		field, typ, _ := strings.Cut(line, " ")
+		recorded[field] = true
+		if current[field] != typ {
+			t.Errorf("%s: was %s in schema version %d, now %q; bump runOutputSchemaVersion for a breaking change", field, typ, runOutputSchemaVersion, current[field])
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	var added []string
+	for field, typ := range current {
+		if !recorded[field] {
+			added = append(added, field+" "+typ)
+		}
+	}
+	slices.Sort(added)
+	if len(added) > 0 {
+		t.Errorf("new fields; append them to %s:\n%s", path, strings.Join(added, "\n"))
+	}
+}
+
+// validate checks v against s: types, required properties, and consts.
+func (root *jsonSchema) validate(t *testing.T, path string, s *jsonSchema, v any) {
+	t.Helper()
+	s = root.resolve(t, s)
+	if s.Const != nil && !reflect.DeepEqual(s.Const, v) {
+		t.Errorf("%s = %v, want %v", path, v, s.Const)
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			t.Errorf("%s: not an object", path)
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				t.Errorf("%s: missing %s", path, name)
+			}
+		}
+		for name, val := range obj {
+			if p := s.Properties[name]; p != nil {
+				root.validate(t, path+"."+name, p, val)
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			t.Errorf("%s: not an array", path)
+			return
+		}
+		for i, val := range arr {
+			root.validate(t, fmt.Sprintf("%s[%d]", path, i), s.Items, val)
+		}
+	case "string", "boolean", "number", "integer":
+		var ok bool
+		switch s.Type {
+		case "string":
+			_, ok = v.(string)
+		case "boolean":
+			_, ok = v.(bool)
+		case "number":
+			_, ok = v.(float64)
+		case "integer":
+			n, isNum := v.(float64)
+			ok = isNum && n == float64(int64(n))
+		}
+		if !ok {
+			t.Errorf("%s = %v: not a %s", path, v, s.Type)
+		}
+	}
+}
+
+// The end-to-end runs' outputs conform to the schema.
+func TestRunOutputGoldensMatchSchema(t *testing.T) {
+	root := loadRunOutputSchema(t)
+	goldens, err := filepath.Glob(filepath.Join("testdata", "e2e", "*.golden.json"))
+	if err != nil || len(goldens) == 0 {
+		t.Fatalf("no goldens: %v", err)
+	}
+	for _, golden := range goldens {
+		data, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Fatalf("%s: %v", golden, err)
+		}
+		root.validate(t, filepath.Base(golden), root, v)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// searchPageSize is the largest page the GraphQL search connection allows.
+const searchPageSize = 100
+
+// searchResultCap is the most results GitHub search will return for a
+// single query, however it is paginated.
+const searchResultCap = 1000
+
+// searchResult is a scan of open PRs. Total is GitHub's count of matching
+// PRs, which can exceed len(PRs) when the scan limit cut it short.
+type searchResult struct {
+	PRs   []searchPR
+	Total int
+}
+
+// Truncated reports whether some matching PRs were not scanned.
+func (r searchResult) Truncated() bool {
+	return r.Total > len(r.PRs)
+}
+
+// searchPage is one page of the GraphQL PR search.
+type searchPage struct {
+	PRs       []searchPR
+	Total     int
+	EndCursor string
+	HasNext   bool
+}
+
+type searchPRNode struct {
+	URL       string    `json:"url"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	IsDraft   bool      `json:"isDraft"`
+	Number    int       `json:"number"`
+	Author    *struct {
+		Login    string `json:"login"`
+		Typename string `json:"__typename"`
+	} `json:"author"`
+	Repository struct {
+		NameWithOwner string `json:"nameWithOwner"`
+	} `json:"repository"`
+	Labels struct {
+		Nodes []label `json:"nodes"`
+	} `json:"labels"`
+	HeadRefOid string `json:"headRefOid"`
+	// The head commit's checks rollup; a list of one.
+	Commits struct {
+		Nodes []struct {
+			Commit struct {
+				StatusCheckRollup *struct {
+					State string `json:"state"`
+				} `json:"statusCheckRollup"`
+			} `json:"commit"`
+		} `json:"nodes"`
+	} `json:"commits"`
+	// Asked for by the digest query only.
+	CreatedAt      time.Time `json:"createdAt"`
+	Mergeable      string    `json:"mergeable"`
+	ReviewDecision string    `json:"reviewDecision"`
+	LatestReviews  struct {
+		Nodes []prReview `json:"nodes"`
+	} `json:"latestReviews"`
+}
+
+type searchResponse struct {
+	Data struct {
+		Search struct {
+			IssueCount int `json:"issueCount"`
+			PageInfo   struct {
+				EndCursor   string `json:"endCursor"`
+				HasNextPage bool   `json:"hasNextPage"`
+			} `json:"pageInfo"`
+			Nodes []searchPRNode `json:"nodes"`
+		} `json:"search"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+const searchPRsQuery = `query($q: String!, $first: Int!, $after: String) {
+  search(query: $q, type: ISSUE, first: $first, after: $after) {
+    issueCount
+    pageInfo { endCursor hasNextPage }
+    nodes {
+      ... on PullRequest {
+        url title body updatedAt isDraft number
+        author { login __typename }
+        repository { nameWithOwner }
+        labels(first: 50) { nodes { name } }
+        headRefOid
+        commits(last: 1) { nodes { commit { statusCheckRollup { state } } } }
+      }
+    }
+  }
+}`
+
+// ghSearchPRs returns up to limit open PRs owned by owner, most recently
+// updated first, paging through the GraphQL search API.
+func ghSearchPRs(ctx context.Context, owner string, limit int) (searchResult, error) {
+	return searchOpenPRs(ctx, searchPRsQuery, owner, limit)
+}
+
+// searchOpenPRs runs the GraphQL search gql (searchPRsQuery or a variant
+// asking for more fields) over owner's open PRs.
+func searchOpenPRs(ctx context.Context, gql string, owner string, limit int) (searchResult, error) {
+	if strings.TrimSpace(owner) == "" {
+		return searchResult{}, errors.New("owner/org required")
+	}
+	query := fmt.Sprintf("user:%s is:pr is:open sort:updated-desc", owner)
+	return paginateSearch(ctx, limit, func(after string, first int) (searchPage, error) {
+		return ghSearchPRsPage(ctx, gql, query, after, first)
+	})
+}
+
+// paginateSearch calls fetch until limit PRs are collected or the results
+// run out. Exposed separately from ghSearchPRs for testing.
+func paginateSearch(ctx context.Context, limit int, fetch func(after string, first int) (searchPage, error)) (searchResult, error) {
+	if limit <= 0 {
+		limit = 30
+	}
+	if limit > searchResultCap {
+		limit = searchResultCap
+	}
+	var res searchResult
+	after := ""
+	for len(res.PRs) < limit {
+		first := min(searchPageSize, limit-len(res.PRs))
+		page, err := RetryableWithResult(ctx, func() (searchPage, error) {
+			return fetch(after, first)
+		}, retryCfg)
+		if err != nil {
+			return res, err
+		}
+		res.Total = page.Total
+		res.PRs = append(res.PRs, page.PRs...)
+		if !page.HasNext || page.EndCursor == "" || len(page.PRs) == 0 {
+			break
+		}
+		after = page.EndCursor
+	}
+	if len(res.PRs) > limit {
+		res.PRs = res.PRs[:limit]
+	}
+	return res, nil
+}
+
+func ghSearchPRsPage(ctx context.Context, gql string, query string, after string, first int) (searchPage, error) {
+	args := []string{
+		"api", "graphql",
+		"-f", "query=" + gql,
+		"-f", "q=" + query,
+		"-F", fmt.Sprintf("first=%d", first),
+	}
+	if after != "" {
+		args = append(args, "-f", "after="+after)
+	}
+	stdout, err := runGh(ctx, args...)
+	if err != nil {
+		return searchPage{}, err
+	}
+	return parseSearchPage(stdout)
+}
+
+// parseSearchPage decodes a GraphQL search response into a page of PRs.
+func parseSearchPage(raw []byte) (searchPage, error) {
+	var resp searchResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return searchPage{}, fmt.Errorf("parse gh search json: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return searchPage{}, fmt.Errorf("search failed: %s", resp.Errors[0].Message)
+	}
+	s := resp.Data.Search
+	page := searchPage{
+		Total:     s.IssueCount,
+		EndCursor: s.PageInfo.EndCursor,
+		HasNext:   s.PageInfo.HasNextPage,
+	}
+	for _, n := range s.Nodes {
+		if n.URL == "" {
+			// Non-PR node (the fragment didn't match).
+			continue
+		}
+		var pr searchPR
+		pr.URL = n.URL
+		pr.Title = n.Title
+		pr.Body = n.Body
+		pr.UpdatedAt = n.UpdatedAt
+		pr.IsDraft = n.IsDraft
+		pr.Number = n.Number
+		if n.Author != nil {
+			// Deleted accounts ("ghost") come back as a null author.
+			pr.Author.Login = n.Author.Login
+			pr.Author.IsBot = n.Author.Typename == "Bot"
+		}
+		pr.Repository.NameWithOwner = n.Repository.NameWithOwner
+		if pr.Repository.NameWithOwner == "" {
+			// best-effort normalize
+			pr.Repository.NameWithOwner = repoFromPRURL(pr.URL)
+		}
+		pr.Labels = n.Labels.Nodes
+		pr.HeadRefOid = n.HeadRefOid
+		if len(n.Commits.Nodes) > 0 && n.Commits.Nodes[0].Commit.StatusCheckRollup != nil {
+			pr.ChecksState = n.Commits.Nodes[0].Commit.StatusCheckRollup.State
+		}
+		pr.CreatedAt = n.CreatedAt
+		pr.Mergeable = n.Mergeable
+		pr.ReviewDecision = n.ReviewDecision
+		pr.LatestReviews = n.LatestReviews.Nodes
+		page.PRs = append(page.PRs, pr)
+	}
+	return page, nil
+}
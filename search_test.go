@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseSearchPage(t *testing.T) {
+	raw := `{"data":{"search":{"issueCount":250,"pageInfo":{"endCursor":"Y3Vyc29yOjI=","hasNextPage":true},"nodes":[
+		{"url":"https://github.com/misty-step/app/pull/7","title":"Fix","body":"","updatedAt":"2025-01-10T12:00:00Z","isDraft":false,"number":7,
+		 "author":{"login":"kaylee-mistystep"},"repository":{"nameWithOwner":"misty-step/app"},"labels":{"nodes":[{"name":"do not touch"}]}},
+		{"url":"https://github.com/misty-step/lib/pull/3","title":"Ghost","body":"","updatedAt":"2025-01-09T12:00:00Z","isDraft":true,"number":3,
+		 "author":null,"repository":{"nameWithOwner":"misty-step/lib"},"labels":{"nodes":[]}},
+		{"url":"https://github.com/misty-step/lib/pull/4","title":"Bump","body":"","updatedAt":"2025-01-08T12:00:00Z","isDraft":false,"number":4,
+		 "author":{"login":"dependabot","__typename":"Bot"},"repository":{"nameWithOwner":"misty-step/lib"},"labels":{"nodes":[]}},
+		{}
+	]}}}`
+	page, err := parseSearchPage([]byte(raw))
+	if err != nil {
+		t.Fatalf("parseSearchPage: %v", err)
+	}
+	if page.Total != 250 || !page.HasNext || page.EndCursor != "Y3Vyc29yOjI=" {
+		t.Errorf("unexpected page info: %+v", page)
+	}
+	if len(page.PRs) != 3 {
+		t.Fatalf("expected 3 PRs (non-PR node dropped), got %d", len(page.PRs))
+	}
+	first := page.PRs[0]
+	if first.Author.Login != "kaylee-mistystep" || first.Number != 7 || len(first.Labels) != 1 || first.Labels[0].Name != "do not touch" {
+		t.Errorf("unexpected first PR: %+v", first)
+	}
+	second := page.PRs[1]
+	if second.Author.Login != "" || !second.IsDraft {
+		t.Errorf("unexpected second PR: %+v", second)
+	}
+	if first.Author.IsBot || !page.PRs[2].Author.IsBot {
+		t.Errorf("IsBot = %t, %t; want only the Bot author flagged", first.Author.IsBot, page.PRs[2].Author.IsBot)
+	}
+
+	if _, err := parseSearchPage([]byte(`{"errors":[{"message":"boom"}]}`)); err == nil {
+		t.Error("expected GraphQL errors to surface")
+	}
+}
+
+// fakeSearch serves total PRs in pages, recording each request size.
+func fakeSearch(total int, sizes *[]int) func(after string, first int) (searchPage, error) {
+	return func(after string, first int) (searchPage, error) {
+		*sizes = append(*sizes, first)
+		start := 0
+		if after != "" {
+			fmt.Sscanf(after, "c%d", &start)
+		}
+		end := min(start+first, total)
+		var page searchPage
+		page.Total = total
+		for i := start; i < end; i++ {
+			var pr searchPR
+			pr.URL = fmt.Sprintf("https://github.com/o/r/pull/%d", i+1)
+			page.PRs = append(page.PRs, pr)
+		}
+		page.HasNext = end < total
+		page.EndCursor = fmt.Sprintf("c%d", end)
+		return page, nil
+	}
+}
+
+func TestPaginateSearch(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     int
+		limit     int
+		wantPRs   int
+		wantSizes []int
+		truncated bool
+	}{
+		{"single page", 42, 200, 42, []int{100}, false},
+		{"several pages", 250, 1000, 250, []int{100, 100, 100}, false},
+		{"limit stops early", 250, 150, 150, []int{100, 50}, true},
+		{"limit capped at search maximum", 5000, 5000, 1000, []int{100, 100, 100, 100, 100, 100, 100, 100, 100, 100}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sizes []int
+			res, err := paginateSearch(t.Context(), tt.limit, fakeSearch(tt.total, &sizes))
+			if err != nil {
+				t.Fatalf("paginateSearch: %v", err)
+			}
+			if len(res.PRs) != tt.wantPRs {
+				t.Errorf("got %d PRs; want %d", len(res.PRs), tt.wantPRs)
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.wantSizes) {
+				t.Errorf("page sizes = %v; want %v", sizes, tt.wantSizes)
+			}
+			if res.Truncated() != tt.truncated {
+				t.Errorf("Truncated() = %v; want %v", res.Truncated(), tt.truncated)
+			}
+			if len(res.PRs) > 0 && res.PRs[len(res.PRs)-1].URL != fmt.Sprintf("https://github.com/o/r/pull/%d", tt.wantPRs) {
+				t.Errorf("pages out of order: last = %s", res.PRs[len(res.PRs)-1].URL)
+			}
+		})
+	}
+}
+
+func TestPaginateSearch_permanentError(t *testing.T) {
+	calls := 0
+	_, err := paginateSearch(t.Context(), 200, func(after string, first int) (searchPage, error) {
+		calls++
+		return searchPage{}, errors.New("HTTP 404: Not Found")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("expected one call and an error, got %d calls, err %v", calls, err)
+	}
+}
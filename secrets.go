@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// secretPatterns are the built-in checks for credentials that are obvious
+// from their shape alone.
+var secretPatterns = []struct {
+	rule string
+	re   *regexp.Regexp
+}{
+	{"aws-access-key-id", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"aws-secret-access-key", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`)},
+	{"github-token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"private-key", regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )?PRIVATE KEY(?: BLOCK)?-----`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"stripe-live-key", regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+}
+
+// gitleaksBin is the gitleaks executable; when it's on PATH its findings
+// are added to the built-in ones.
+var gitleaksBin = "gitleaks"
+
+// diffLine is a line of a unified diff, with the file and new-side line
+// number it falls on. Only added lines carry a File.
+type diffLine struct {
+	File string
+	Line int
+	Text string
+}
+
+// parseDiff indexes a unified diff by line (0-based). Context, removed, and
+// header lines are kept as placeholders so indexes match the input.
+func parseDiff(diff string) []diffLine {
+	lines := strings.Split(diff, "\n")
+	out := make([]diffLine, len(lines))
+	file, next := "", 0
+	for i, l := range lines {
+		switch {
+		case strings.HasPrefix(l, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(l, "+++ "), "b/")
+			if file == "/dev/null" {
+				file = ""
+			}
+		case strings.HasPrefix(l, "@@ "):
+			// @@ -a,b +c,d @@: the new side starts at line c.
+			if _, rest, ok := strings.Cut(l, "+"); ok {
+				n, _, _ := strings.Cut(rest, ",")
+				n, _, _ = strings.Cut(n, " ")
+				next, _ = strconv.Atoi(n)
+			}
+		case strings.HasPrefix(l, "+"):
+			if file != "" {
+				out[i] = diffLine{File: file, Line: next, Text: l[1:]}
+			}
+			next++
+		case strings.HasPrefix(l, " "):
+			next++
+		}
+	}
+	return out
+}
+
+// scanDiffForSecrets returns "path:line (rule)" for each added line that
+// looks like it holds a credential. The matched text itself is never
+// reported.
+func scanDiffForSecrets(ctx context.Context, diff string) []string {
+	lines := parseDiff(diff)
+	var findings []string
+	seen := make(map[string]bool)
+	add := func(l diffLine, rule string) {
+		f := fmt.Sprintf("%s:%d (%s)", l.File, l.Line, rule)
+		if !seen[f] {
+			seen[f] = true
+			findings = append(findings, f)
+		}
+	}
+	for _, l := range lines {
+		if l.File == "" {
+			continue
+		}
+		for _, p := range secretPatterns {
+			if p.re.MatchString(l.Text) {
+				add(l, p.rule)
+			}
+		}
+	}
+	if _, err := exec.LookPath(gitleaksBin); err == nil {
+		leaks, err := runGitleaks(ctx, diff)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[secrets] gitleaks failed, using the built-in patterns only: %v\n", err)
+		}
+		for _, leak := range leaks {
+			// gitleaks numbers the diff's lines from 1; only added lines count.
+			if i := leak.StartLine - 1; i >= 0 && i < len(lines) && lines[i].File != "" {
+				add(lines[i], "gitleaks:"+leak.RuleID)
+			}
+		}
+	}
+	return findings
+}
+
+// gitleaksFinding is the part of a gitleaks JSON report entry we use.
+type gitleaksFinding struct {
+	RuleID    string `json:"RuleID"`
+	StartLine int    `json:"StartLine"`
+}
+
+// runGitleaks scans diff with `gitleaks stdin` and returns its findings.
+func runGitleaks(ctx context.Context, diff string) ([]gitleaksFinding, error) {
+	dir, err := os.MkdirTemp("", "fab-gitleaks-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	report := filepath.Join(dir, "report.json")
+	if _, err := runCmdInput(ctx, []byte(diff), gitleaksBin, "stdin",
+		"--no-banner", "--redact", "--log-level", "error", "--exit-code", "0",
+		"--report-format", "json", "--report-path", report); err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(report)
+	if err != nil {
+		return nil, err
+	}
+	var leaks []gitleaksFinding
+	if err := json.Unmarshal(raw, &leaks); err != nil {
+		return nil, fmt.Errorf("parse gitleaks report: %w", err)
+	}
+	return leaks, nil
+}
+
+// ghPRDiff returns the PR's unified diff.
+func ghPRDiff(ctx context.Context, url string) (string, error) {
+	stdout, err := runGh(ctx, "pr", "diff", url)
+	if err != nil {
+		return "", err
+	}
+	return string(stdout), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Why a scanned PR wasn't processed.
+const (
+	filterDenylisted     = "denylisted"        // /pipeline skip
+	filterRepoOutOfScope = "repo_out_of_scope" // --only-repos or --skip-repos
+	filterRepoExcluded   = "repo_excluded"     // the config's exclude
+	filterDraft          = "draft"
+	filterDoNotTouch     = "do_not_touch"
+	filterNoAuthor       = "no_author" // a deleted account
+	filterAuthorSkip     = "author_skip"
+	filterStaleWait      = "stale_wait"  // a stale-mode author's PR, updated too recently
+	filterLimit          = "limit"       // selected, but past --max-prs or --max-actions
+	filterRunAborted     = "run_aborted" // selected, but --max-run-errors stopped the run first
+)
+
+// filteredPR is a scanned PR the run didn't process, and why, so selection
+// can be audited from the run output alone.
+type filteredPR struct {
+	URL    string `json:"url"`
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+	Author string `json:"author,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func newFilteredPR(pr searchPR, reason string) filteredPR {
+	return filteredPR{URL: pr.URL, Repo: pr.Repository.NameWithOwner, Number: pr.Number, Author: pr.Author.Login, Reason: reason}
+}
+
+// exclusionReason returns why the selection policy leaves pr out, or "" if
+// it's selected.
+func exclusionReason(opts *runOptions, pr searchPR, now time.Time) string {
+	if opts.denied.has(pr.URL, now) {
+		return filterDenylisted
+	}
+	if !repoInScope(pr.Repository.NameWithOwner, opts.onlyRepos, opts.skipRepos) {
+		return filterRepoOutOfScope
+	}
+	if opts.config.repoPolicyFor(pr.Repository.NameWithOwner).Exclude {
+		return filterRepoExcluded
+	}
+	// Stale bot PRs are closed regardless of draft state or stale wait.
+	if isCloseStaleCandidate(pr.Author.Login, pr.UpdatedAt, opts.staleAuthors, opts.CloseStaleDays, now) &&
+		!isDoNotTouch(opts.DoNotTouchLabel, pr.Title, pr.Body, pr.Labels) {
+		return ""
+	}
+	if pr.IsDraft && !promotableDraftAuthor(opts, pr.Author.Login) {
+		return filterDraft
+	}
+	if isDoNotTouch(opts.DoNotTouchLabel, pr.Title, pr.Body, pr.Labels) {
+		return filterDoNotTouch
+	}
+	author := strings.TrimSpace(pr.Author.Login)
+	if author == "" {
+		return filterNoAuthor
+	}
+	ap := opts.authors.policyFor(author, pr.Author.IsBot)
+	if ap.Mode == authorModeSkip {
+		return filterAuthorSkip
+	}
+	if ap.Mode == authorModeStale && now.Sub(pr.UpdatedAt) < time.Duration(ap.StaleHours)*time.Hour {
+		return filterStaleWait
+	}
+	return ""
+}
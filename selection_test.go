@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPartitionPRs(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	opts := testRunOptions(t, "--skip-repos", "org/skipped", "--authors", "bot=skip,*=immediate,phrazzld=stale:72")
+	opts.denied = denylist{"denied": now.Add(time.Hour)}
+
+	draft := makeSearchPR("draft", "org/a", "kaylee-mistystep", now)
+	draft.IsDraft = true
+	dnt := makeSearchPR("dnt", "org/a", "kaylee-mistystep", now)
+	dnt.Labels = []label{{Name: "Do Not Touch"}}
+
+	want := map[string]string{
+		"ready":        "",
+		"denied":       filterDenylisted,
+		"skipped-repo": filterRepoOutOfScope,
+		"draft":        filterDraft,
+		"dnt":          filterDoNotTouch,
+		"no-author":    filterNoAuthor,
+		"bot":          filterAuthorSkip,
+		"fresh":        filterStaleWait,
+	}
+	prs := []searchPR{
+		makeSearchPR("ready", "org/a", "kaylee-mistystep", now),
+		makeSearchPR("denied", "org/a", "kaylee-mistystep", now),
+		makeSearchPR("skipped-repo", "org/skipped", "kaylee-mistystep", now),
+		draft,
+		dnt,
+		makeSearchPR("no-author", "org/a", "", now),
+		makeSearchPR("bot", "org/a", "bot", now),
+		makeSearchPR("fresh", "org/a", "phrazzld", now.Add(-time.Hour)),
+	}
+
+	selected, filtered := partitionPRs(opts, prs, now)
+	if len(selected) != 1 || selected[0].URL != "ready" {
+		t.Errorf("selected = %+v; want only ready", selected)
+	}
+	if len(filtered) != len(prs)-1 {
+		t.Errorf("filtered %d PRs; want %d", len(filtered), len(prs)-1)
+	}
+	for _, f := range filtered {
+		if f.Reason != want[f.URL] {
+			t.Errorf("%s: reason %q; want %q", f.URL, f.Reason, want[f.URL])
+		}
+	}
+}
+
+func TestRunReportsFilteredPRs(t *testing.T) {
+	first, second, third := fakePR("misty-step/api", 1), fakePR("misty-step/api", 2), fakePR("misty-step/api", 3)
+	third.IsDraft = true
+	fake := newFakeGitHub(first, second, third)
+	fake.updatedAt[first.URL] = time.Now()
+	fake.updatedAt[second.URL] = time.Now().Add(-time.Hour)
+	useFakeGitHub(t, fake)
+
+	out, err := newPipeline(testPipelineOptions(t, "-max-prs", "1")).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Scanned != 3 || len(out.Results) != 1 || out.Results[0].URL != first.URL {
+		t.Fatalf("scanned %d, results %+v; want 3 scanned and the newest PR processed", out.Scanned, out.Results)
+	}
+	got := map[string]string{}
+	for _, f := range out.Filtered {
+		got[f.URL] = f.Reason
+	}
+	if len(got) != 2 || got[second.URL] != filterLimit || got[third.URL] != filterDraft {
+		t.Errorf("filtered = %+v; want the second PR past the limit and the draft", out.Filtered)
+	}
+}
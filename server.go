@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverTokenEnv names the optional bearer token that POST /run requires.
+const serverTokenEnv = "PIPELINE_API_TOKEN"
+
+// pipelineServer runs the pipeline on demand over HTTP. At most one run is
+// in flight at a time.
+type pipelineServer struct {
+	opts          *runOptions
+	token         string
+	webhookSecret string
+	// discordKey verifies slash command interactions (nil disables them).
+	discordKey ed25519.PublicKey
+	// runFn runs one pipeline pass (Pipeline.Run plus recordRun; replaced in tests).
+	runFn func(opts *runOptions) (runOutput, error)
+
+	mu      sync.Mutex
+	running bool
+	last    *runOutput
+	lastErr string
+	// pending holds webhook PRs that arrived mid-run, keyed by URL.
+	pending map[string]webhookTarget
+}
+
+// serverStatus is the GET /status response.
+type serverStatus struct {
+	Running bool       `json:"running"`
+	Error   string     `json:"error,omitempty"`
+	Last    *runOutput `json:"last"`
+}
+
+func newPipelineServer(opts *runOptions) *pipelineServer {
+	s := &pipelineServer{
+		opts:          opts,
+		token:         strings.TrimSpace(os.Getenv(serverTokenEnv)),
+		webhookSecret: os.Getenv(webhookSecretEnv),
+		runFn:         serveRunOnce,
+		pending:       make(map[string]webhookTarget),
+	}
+	if raw := os.Getenv(discordPublicKeyEnv); strings.TrimSpace(raw) != "" {
+		key, err := parseDiscordPublicKey(raw)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[serve] %v; Discord commands disabled\n", err)
+		}
+		s.discordKey = key
+	}
+	// Pick up the last run from before a restart, so /status isn't empty.
+	if last, err := loadLastRun(lastRunPath(resolveStatePath(opts.StateFile))); err == nil {
+		s.last = &last
+	}
+	return s
+}
+
+func (s *pipelineServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSONStatus(w, http.StatusOK, map[string]any{"ok": true})
+	})
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("POST /run", s.handleRun)
+	mux.HandleFunc("POST /webhook", s.handleWebhook)
+	mux.HandleFunc("POST /discord/interactions", s.handleDiscordInteraction)
+	return mux
+}
+
+func (s *pipelineServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status := serverStatus{Running: s.running, Error: s.lastErr, Last: s.last}
+	s.mu.Unlock()
+	writeJSONStatus(w, http.StatusOK, status)
+}
+
+// handleRun starts a run in the background and returns 202. The optional
+// repo query parameter (owner/name or a glob) scopes the run like
+// --only-repos. Returns 409 while another run is in progress.
+func (s *pipelineServer) handleRun(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+		writeJSONStatus(w, http.StatusUnauthorized, map[string]any{"ok": false, "error": "missing or invalid bearer token"})
+		return
+	}
+	opts, err := s.scopedOptions(r.URL.Query().Get("repo"))
+	if err != nil {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]any{"ok": false, "error": err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		writeJSONStatus(w, http.StatusConflict, map[string]any{"ok": false, "error": "a run is already in progress"})
+		return
+	}
+	s.startLocked(opts)
+	writeJSONStatus(w, http.StatusAccepted, map[string]any{"ok": true, "started": true, "repos": opts.onlyRepos})
+}
+
+// startLocked starts a run in the background. The caller holds s.mu and has
+// checked that no run is in progress. When the run ends, any webhook PRs
+// queued meanwhile get a run of their own.
+func (s *pipelineServer) startLocked(opts *runOptions) {
+	s.running = true
+	go func() {
+		for opts != nil {
+			out, err := s.runFn(opts)
+			s.mu.Lock()
+			if err != nil {
+				s.lastErr = err.Error()
+				fmt.Fprintf(os.Stderr, "[serve] run failed: %v\n", err)
+			} else {
+				s.lastErr = ""
+				s.last = &out
+			}
+			opts = nil
+			if len(s.pending) > 0 {
+				targets := make([]webhookTarget, 0, len(s.pending))
+				for _, t := range s.pending {
+					targets = append(targets, t)
+				}
+				clear(s.pending)
+				opts = s.targetOptions(targets)
+			} else {
+				s.running = false
+			}
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// scopedOptions returns a copy of the server's options for one run, limited
+// to repo when it is set.
+func (s *pipelineServer) scopedOptions(repo string) (*runOptions, error) {
+	opts := *s.opts
+	if repo = strings.TrimSpace(repo); repo != "" {
+		if err := validateRepoPatterns([]string{repo}); err != nil {
+			return nil, err
+		}
+		opts.onlyRepos = []string{repo}
+	}
+	return &opts, nil
+}
+
+// serveRunOnce runs one pipeline pass for the server and records it the
+// same way a CLI run does.
+func serveRunOnce(opts *runOptions) (runOutput, error) {
+	ctx, cancel := opts.runContext()
+	defer cancel()
+	out, err := newPipeline(opts).Run(ctx)
+	if err != nil {
+		return out, err
+	}
+	return recordRun(opts, out), nil
+}
+
+func writeJSONStatus(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	writeJSON(w, v)
+}
+
+// checkServeAddr refuses an address beyond loopback when no token is set:
+// without one, anyone who can reach the port could start a run that merges.
+func checkServeAddr(addr, token string) error {
+	if token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid --serve address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("--serve %s listens beyond localhost; set %s so POST /run needs a bearer token, or bind to 127.0.0.1", addr, serverTokenEnv)
+}
+
+// runServer implements --serve: listen and run the pipeline on POST /run
+// until the process is stopped.
+func runServer(opts *runOptions) int {
+	s := newPipelineServer(opts)
+	if err := checkServeAddr(opts.Serve, s.token); err != nil {
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return 1
+	}
+	srv := &http.Server{
+		Addr:              opts.Serve,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	setupDiscordCommands(context.Background())
+	fmt.Fprintf(os.Stderr, "[serve] listening on %s\n", opts.Serve)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return 1
+	}
+	return 0
+}
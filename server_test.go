@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, runFn func(*runOptions) (runOutput, error)) *pipelineServer {
+	t.Helper()
+	t.Setenv(serverTokenEnv, "")
+	s := newPipelineServer(&runOptions{Org: "misty-step", orgs: []string{"misty-step"}, StateFile: filepath.Join(t.TempDir(), "state.json")})
+	s.runFn = runFn
+	return s
+}
+
+func doRequest(t *testing.T, h http.Handler, method string, target string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func waitIdle(t *testing.T, s *pipelineServer) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		running := s.running
+		s.mu.Unlock()
+		if !running {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("run did not finish")
+}
+
+func TestPipelineServer_healthz(t *testing.T) {
+	s := newTestServer(t, nil)
+	if rec := doRequest(t, s.handler(), "GET", "/healthz", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /healthz = %d; want 200", rec.Code)
+	}
+}
+
+func TestPipelineServer_runAndStatus(t *testing.T) {
+	gotRepos := make(chan []string, 1)
+	s := newTestServer(t, func(opts *runOptions) (runOutput, error) {
+		gotRepos <- opts.onlyRepos
+		return runOutput{Ok: true, Org: opts.Org, StartedAt: "2025-06-01T12:00:00Z"}, nil
+	})
+	h := s.handler()
+
+	rec := doRequest(t, h, "POST", "/run?repo=misty-step/fab-cli", nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /run = %d; want 202 (%s)", rec.Code, rec.Body)
+	}
+	if repos := <-gotRepos; len(repos) != 1 || repos[0] != "misty-step/fab-cli" {
+		t.Errorf("run scoped to %v; want [misty-step/fab-cli]", repos)
+	}
+	waitIdle(t, s)
+
+	rec = doRequest(t, h, "GET", "/status", nil)
+	var status serverStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode status: %v (%s)", err, rec.Body)
+	}
+	if status.Running || status.Last == nil || status.Last.StartedAt != "2025-06-01T12:00:00Z" {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestPipelineServer_rejectsConcurrentRun(t *testing.T) {
+	release := make(chan struct{})
+	s := newTestServer(t, func(opts *runOptions) (runOutput, error) {
+		<-release
+		return runOutput{Ok: true}, nil
+	})
+	h := s.handler()
+	if rec := doRequest(t, h, "POST", "/run", nil); rec.Code != http.StatusAccepted {
+		t.Fatalf("first POST /run = %d; want 202", rec.Code)
+	}
+	if rec := doRequest(t, h, "POST", "/run", nil); rec.Code != http.StatusConflict {
+		t.Errorf("second POST /run = %d; want 409", rec.Code)
+	}
+	close(release)
+	waitIdle(t, s)
+}
+
+func TestPipelineServer_badRepo(t *testing.T) {
+	s := newTestServer(t, nil)
+	if rec := doRequest(t, s.handler(), "POST", "/run?repo=%5Bbad", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /run with bad pattern = %d; want 400", rec.Code)
+	}
+}
+
+func TestPipelineServer_token(t *testing.T) {
+	s := newTestServer(t, func(opts *runOptions) (runOutput, error) { return runOutput{Ok: true}, nil })
+	s.token = "secret"
+	h := s.handler()
+	if rec := doRequest(t, h, "POST", "/run", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("POST /run without token = %d; want 401", rec.Code)
+	}
+	if rec := doRequest(t, h, "POST", "/run", map[string]string{"Authorization": "Bearer secret"}); rec.Code != http.StatusAccepted {
+		t.Errorf("POST /run with token = %d; want 202", rec.Code)
+	}
+	waitIdle(t, s)
+	if rec := doRequest(t, h, "GET", "/status", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /status = %d; want 200 without a token", rec.Code)
+	}
+}
+
+func TestCheckServeAddr(t *testing.T) {
+	for _, tc := range []struct {
+		addr, token string
+		ok          bool
+	}{
+		{"127.0.0.1:8080", "", true},
+		{"localhost:8080", "", true},
+		{"[::1]:8080", "", true},
+		{":8080", "", false},
+		{"0.0.0.0:8080", "", false},
+		{"10.0.0.5:8080", "", false},
+		{":8080", "secret", true},
+		{"8080", "", false},
+	} {
+		if err := checkServeAddr(tc.addr, tc.token); (err == nil) != tc.ok {
+			t.Errorf("checkServeAddr(%q, %q) = %v; want ok=%v", tc.addr, tc.token, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// simulation is a --simulate fixtures file: a snapshot of the org's open
+// PRs to run the pipeline against instead of GitHub.
+type simulation struct {
+	// Now is the time the run pretends it is, so stale waits and merge
+	// windows come out as they would have then. Zero means the real time.
+	Now time.Time      `json:"now,omitzero"`
+	PRs []*simulatedPR `json:"prs"`
+}
+
+// simulatedPR is one PR in a fixtures file: the fields of gh pr view --json
+// (so its output can be pasted in as is), plus what the gates look up
+// separately.
+type simulatedPR struct {
+	prView
+	Number    int       `json:"number"`
+	State     string    `json:"state"` // default OPEN
+	UpdatedAt time.Time `json:"updatedAt"`
+	Files     []struct {
+		Path string `json:"path"`
+	} `json:"files,omitempty"`
+	IssueComments []issueComment    `json:"issueComments,omitempty"`
+	ReviewThreads []reviewThread    `json:"reviewThreads,omitempty"` // unresolved
+	Commits       []simulatedCommit `json:"commits,omitempty"`       // default: one signed commit by the author
+	Diff          string            `json:"diff,omitempty"`
+	WorkflowRuns  []int64           `json:"workflowRunsAwaitingApproval,omitempty"`
+
+	ref prRef
+}
+
+type simulatedCommit struct {
+	SHA       string `json:"sha"`
+	Author    string `json:"author,omitempty"`
+	Committer string `json:"committer,omitempty"`
+	Verified  bool   `json:"verified"`
+}
+
+// loadSimulation reads a --simulate fixtures file.
+func loadSimulation(path string) (*simulation, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("--simulate: %w", err)
+	}
+	var sim simulation
+	if err := json.Unmarshal(raw, &sim); err != nil {
+		return nil, fmt.Errorf("--simulate %s: %w", path, err)
+	}
+	for i, pr := range sim.PRs {
+		ref, ok := parsePRURL(pr.URL, githubHosts())
+		if !ok {
+			return nil, fmt.Errorf("--simulate %s: prs[%d]: %q is not a PR URL", path, i, pr.URL)
+		}
+		pr.ref, pr.URL, pr.Number = ref, ref.url(), ref.Number
+		if pr.ID == "" {
+			pr.ID = fmt.Sprintf("PR_%s_%s_%d", ref.Owner, ref.Repo, ref.Number)
+		}
+		if pr.State == "" {
+			pr.State = "OPEN"
+		}
+		if pr.Commits == nil {
+			pr.Commits = []simulatedCommit{{SHA: pr.HeadRefOid, Author: pr.Author.Login, Committer: pr.Author.Login, Verified: true}}
+		}
+	}
+	return &sim, nil
+}
+
+// simulatedClient is a GitHubClient that answers from a simulation. Reads
+// come from the snapshots; writes succeed without going anywhere, and
+// merges and closes change the PR's state so later lookups see them. A
+// call the simulation has no answer for fails as not found.
+type simulatedClient struct {
+	mu  sync.Mutex
+	sim *simulation
+}
+
+func newSimulatedClient(sim *simulation) *simulatedClient {
+	return &simulatedClient{sim: sim}
+}
+
+func (c *simulatedClient) Run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out, err, ok := c.answer(args)
+	if !ok {
+		return nil, fmt.Errorf("gh %s: not in the simulation (not found)", strings.Join(args, " "))
+	}
+	return out, err
+}
+
+// pr finds a snapshot by URL (in any form parsePRURL takes) or node ID.
+func (c *simulatedClient) pr(urlOrID string) *simulatedPR {
+	ref, isURL := parsePRURL(urlOrID, githubHosts())
+	for _, p := range c.sim.PRs {
+		if isURL && p.ref == ref || !isURL && p.ID == urlOrID {
+			return p
+		}
+	}
+	return nil
+}
+
+// prIn finds a snapshot by repo and number.
+func (c *simulatedClient) prIn(repo string, number string) *simulatedPR {
+	for _, p := range c.sim.PRs {
+		if strings.EqualFold(p.ref.nameWithOwner(), repo) && strconv.Itoa(p.Number) == number {
+			return p
+		}
+	}
+	return nil
+}
+
+func (c *simulatedClient) answer(args []string) ([]byte, error, bool) {
+	if len(args) < 2 {
+		return nil, nil, false
+	}
+	switch args[0] + " " + args[1] {
+	case "api graphql":
+		return c.graphql(ghFields(args))
+	case "pr view":
+		p := c.pr(args[2])
+		if p == nil {
+			return nil, nil, false
+		}
+		if slices.Contains(args, "--jq") {
+			// Review bodies, filtered by jq; snapshots don't carry them.
+			return nil, nil, true
+		}
+		b, err := json.Marshal(p)
+		return b, err, true
+	case "pr diff":
+		if p := c.pr(args[2]); p != nil {
+			return []byte(p.Diff), nil, true
+		}
+		return nil, nil, false
+	case "pr close":
+		if p := c.pr(args[2]); p != nil {
+			p.State = "CLOSED"
+		}
+		return nil, nil, true
+	case "pr comment", "pr ready", "pr update-branch", "pr edit", "issue close":
+		return nil, nil, true
+	}
+	if args[0] == "api" {
+		return c.rest(args[1:])
+	}
+	return nil, nil, false
+}
+
+func (c *simulatedClient) graphql(fields map[string]string) ([]byte, error, bool) {
+	query := fields["query"]
+	switch {
+	case strings.Contains(query, "search(query"):
+		return c.search(fields["q"])
+	case strings.Contains(query, "repositoryOwner"):
+		return []byte(`{"data":{"repositoryOwner":{"repositories":{"pageInfo":{"hasNextPage":false},"nodes":[]}}}}`), nil, true
+	case strings.Contains(query, "mergeQueue("):
+		return []byte(`{"data":{"repository":{"mergeQueue":null}}}`), nil, true
+	case strings.Contains(query, "reviewThreads("):
+		p := c.prIn(fields["owner"]+"/"+fields["name"], fields["number"])
+		if p == nil {
+			return nil, nil, false
+		}
+		nodes := []map[string]any{}
+		for _, t := range p.ReviewThreads {
+			nodes = append(nodes, map[string]any{
+				"isResolved": false, "path": t.Path, "line": t.Line,
+				"comments": map[string]any{"nodes": []map[string]any{{"body": t.Body, "author": map[string]string{"login": t.Author}}}},
+			})
+		}
+		b, err := json.Marshal(map[string]any{"data": map[string]any{"repository": map[string]any{"pullRequest": map[string]any{
+			"reviewThreads": map[string]any{"nodes": nodes},
+		}}}})
+		return b, err, true
+	case strings.Contains(query, "mergePullRequest("):
+		p := c.pr(fields["pullRequestId"])
+		if p == nil {
+			return nil, nil, false
+		}
+		if head := fields["expectedHeadOid"]; head != "" && head != p.HeadRefOid {
+			return nil, errors.New("Head branch was modified. Review and try the merge again."), true
+		}
+		p.State = "MERGED"
+		return []byte(`{"data":{"mergePullRequest":{"pullRequest":{"merged":true,"mergeCommit":{"oid":"simulated-` + p.HeadRefOid + `"}}}}}`), nil, true
+	case strings.HasPrefix(strings.TrimSpace(query), "mutation"):
+		// Auto-merge, review dismissals: nothing to show for them.
+		return []byte(`{"data":{}}`), nil, true
+	}
+	return nil, nil, false
+}
+
+// search answers the org search with the owner's open PRs, most recently
+// updated first, in one page.
+func (c *simulatedClient) search(q string) ([]byte, error, bool) {
+	var owner string
+	for _, term := range strings.Fields(q) {
+		if v, ok := strings.CutPrefix(term, "user:"); ok {
+			owner = v
+		}
+	}
+	var prs []*simulatedPR
+	for _, p := range c.sim.PRs {
+		if p.State == "OPEN" && strings.EqualFold(p.ref.Owner, owner) {
+			prs = append(prs, p)
+		}
+	}
+	sort.SliceStable(prs, func(i, j int) bool { return prs[i].UpdatedAt.After(prs[j].UpdatedAt) })
+	nodes := []map[string]any{}
+	for _, p := range prs {
+		nodes = append(nodes, map[string]any{
+			"url": p.URL, "title": p.Title, "body": p.Body, "isDraft": p.IsDraft, "number": p.Number,
+			"updatedAt":  p.UpdatedAt,
+			"author":     map[string]string{"login": p.Author.Login},
+			"repository": map[string]string{"nameWithOwner": p.ref.nameWithOwner()},
+			"labels":     map[string]any{"nodes": p.Labels},
+			"createdAt":  p.CreatedAt, "mergeable": p.Mergeable, "reviewDecision": p.ReviewDecision,
+			"latestReviews": map[string]any{"nodes": p.LatestReviews},
+			"headRefOid":    p.HeadRefOid,
+			"commits": map[string]any{"nodes": []map[string]any{{"commit": map[string]any{
+				"statusCheckRollup": map[string]string{"state": overallChecksState(p.StatusCheckRollup)},
+			}}}},
+		})
+	}
+	b, err := json.Marshal(map[string]any{"data": map[string]any{"search": map[string]any{
+		"issueCount": len(nodes), "pageInfo": map[string]any{"hasNextPage": false}, "nodes": nodes,
+	}}})
+	return b, err, true
+}
+
+var simulatedPRPathRe = regexp.MustCompile(`^repos/([^/]+/[^/]+)/(?:issues|pulls)/(\d+)/(comments|files|commits)`)
+
+func (c *simulatedClient) rest(args []string) ([]byte, error, bool) {
+	method, path := "GET", ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-X":
+			method = args[i+1]
+			i++
+		case "--paginate", "--include":
+		case "-f", "-F", "-H", "--input":
+			i++
+		default:
+			if path == "" {
+				path = args[i]
+			}
+		}
+	}
+	if method != "GET" {
+		// Comment edits, check runs, branch deletes, run approvals.
+		return []byte(`{}`), nil, true
+	}
+	if m := simulatedPRPathRe.FindStringSubmatch(path); m != nil {
+		p := c.prIn(m[1], m[2])
+		if p == nil {
+			return nil, nil, false
+		}
+		var v any
+		switch m[3] {
+		case "comments":
+			v = p.IssueComments
+		case "files":
+			files := []map[string]string{}
+			for _, f := range p.Files {
+				files = append(files, map[string]string{"filename": f.Path})
+			}
+			v = files
+		case "commits":
+			account := func(login string) any {
+				if login == "" {
+					return nil
+				}
+				return map[string]string{"login": login}
+			}
+			page := []map[string]any{}
+			for _, cm := range p.Commits {
+				page = append(page, map[string]any{
+					"sha": cm.SHA, "author": account(cm.Author), "committer": account(cm.Committer),
+					"commit": map[string]any{"verification": map[string]any{"verified": cm.Verified}},
+				})
+			}
+			v = page
+		}
+		if v == nil {
+			v = []any{}
+		}
+		b, err := json.Marshal(v)
+		return b, err, true
+	}
+	switch {
+	case strings.Contains(path, "/rules/branches/"):
+		return []byte(`[]`), nil, true
+	case strings.Contains(path, "/branches/"):
+		return []byte(`{"protection":{"enabled":false}}`), nil, true
+	case strings.Contains(path, "/actions/runs?"):
+		runs := []map[string]int64{}
+		for _, p := range c.sim.PRs {
+			if strings.Contains(path, "head_sha="+p.HeadRefOid+"&") && strings.HasPrefix(path, "repos/"+p.ref.nameWithOwner()+"/") {
+				for _, id := range p.WorkflowRuns {
+					runs = append(runs, map[string]int64{"id": id})
+				}
+			}
+		}
+		b, err := json.Marshal(map[string]any{"workflow_runs": runs})
+		return b, err, true
+	case strings.Contains(path, "/check-runs?"):
+		return []byte(`{"check_runs":[]}`), nil, true
+	}
+	return nil, nil, false
+}
+
+// ghFields collects the -f/-F name=value arguments of a gh api call.
+func ghFields(args []string) map[string]string {
+	fields := map[string]string{}
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-f" || args[i] == "-F" {
+			name, value, _ := strings.Cut(args[i+1], "=")
+			fields[name] = value
+			i++
+		}
+	}
+	return fields
+}
+
+// runSimulation runs the pipeline against opts.simulation and prints the run
+// output. It reads and writes no live state: the state file and its
+// neighbors live in a scratch directory for the run, and notifiers, the
+// history DB, the kill switch, and the rate limit floor are all off.
+func runSimulation(opts *runOptions) int {
+	dir, err := os.MkdirTemp("", "fab-pr-pipeline-simulate-")
+	if err != nil {
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return 1
+	}
+	defer os.RemoveAll(dir)
+	opts.StateFile = filepath.Join(dir, "state.json")
+	opts.HistoryDB = ""
+	opts.notifiers = nil
+	opts.freeze = freezeSwitch{}
+	opts.RateLimitFloor = 0
+
+	p := newPipeline(opts)
+	if now := opts.simulation.Now; !now.IsZero() {
+		p.now = func() time.Time { return now }
+	}
+	ctx, cancel := opts.runContext()
+	out, err := p.Run(ctx)
+	cancel()
+	if err != nil {
+		emitJSON(map[string]any{"ok": false, "error": err.Error()})
+		return 1
+	}
+	writeRunOutput(os.Stdout, opts.Output, out)
+	return runExitCode(opts.FailOn, out)
+}
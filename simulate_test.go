@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunSimulation(t *testing.T) {
+	sim, err := loadSimulation(filepath.Join("testdata", "simulate", "fixtures.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newPipeline(testPipelineOptions(t))
+	p.client = newSimulatedClient(sim)
+	p.now = func() time.Time { return sim.Now }
+
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.StartedAt != "2025-06-02T15:00:00Z" {
+		t.Errorf("startedAt = %s; want the fixtures' now", out.StartedAt)
+	}
+	// The merged PR and the other org's aren't in the search.
+	if out.Scanned != 3 || len(out.Results) != 2 {
+		t.Fatalf("scanned %d, results %+v; want 3 scanned and 2 processed", out.Scanned, out.Results)
+	}
+	if r := out.Results[0]; r.URL != "https://github.com/misty-step/api/pull/1" || r.Action != "merged" {
+		t.Errorf("results[0] = %+v; want the green PR merged", r)
+	}
+	if r := out.Results[1]; r.URL != "https://github.com/misty-step/api/pull/2" || r.Action == "merged" || r.Action == "error" || r.Reason != "checks_failure" {
+		t.Errorf("results[1] = %+v; want the failing PR held back on its checks", r)
+	}
+	if len(out.Filtered) != 1 || out.Filtered[0].Reason != filterDraft {
+		t.Errorf("filtered = %+v; want the draft", out.Filtered)
+	}
+	if sim.PRs[0].State != "MERGED" {
+		t.Errorf("merged PR's state = %s; want MERGED", sim.PRs[0].State)
+	}
+}
+
+func TestLoadSimulationRejectsBadURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := os.WriteFile(path, []byte(`{"prs":[{"url":"https://github.com/o/r/issues/1"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSimulation(path); err == nil || !strings.Contains(err.Error(), "prs[0]") {
+		t.Errorf("loadSimulation = %v; want an error naming prs[0]", err)
+	}
+}
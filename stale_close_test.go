@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsCloseStaleCandidate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	authors := []string{"kaylee-mistystep", "renovate[bot]"}
+
+	tests := []struct {
+		name      string
+		author    string
+		updatedAt time.Time
+		days      int
+		want      bool
+	}{
+		{"disabled", "kaylee-mistystep", now.AddDate(0, 0, -90), 0, false},
+		{"old bot PR", "kaylee-mistystep", now.AddDate(0, 0, -31), 30, true},
+		{"exactly at threshold", "renovate[bot]", now.AddDate(0, 0, -30), 30, true},
+		{"fresh bot PR", "kaylee-mistystep", now.AddDate(0, 0, -3), 30, false},
+		{"case insensitive author", "Kaylee-MistyStep", now.AddDate(0, 0, -40), 30, true},
+		{"human author", "phrazzld", now.AddDate(0, 0, -90), 30, false},
+		{"zero updatedAt", "kaylee-mistystep", time.Time{}, 30, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCloseStaleCandidate(tt.author, tt.updatedAt, authors, tt.days, now); got != tt.want {
+				t.Errorf("isCloseStaleCandidate() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildStaleCloseComment(t *testing.T) {
+	body := buildStaleCloseComment(30)
+	if !strings.HasPrefix(body, "<!-- kaylee-pr-pipeline -->") {
+		t.Errorf("stale close comment should start with pipeline tag; got:\n%s", body)
+	}
+	if !strings.Contains(body, "30 days") {
+		t.Errorf("stale close comment should mention the threshold; got:\n%s", body)
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" a, b,,c ,")
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitList() = %v; want %v", got, want)
+	}
+	if got := splitList(""); got != nil {
+		t.Errorf("splitList(\"\") = %v; want nil", got)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runStats are a run's aggregate metrics. Rates are over the PRs the run
+// didn't skip. Week is set when a history DB is configured.
+type runStats struct {
+	Outcomes  int     `json:"outcomes"`
+	Merged    int     `json:"merged"`
+	Errors    int     `json:"errors"`
+	MergeRate float64 `json:"mergeRate"`
+	ErrorRate float64 `json:"errorRate"`
+	// MedianTimeToMergeSeconds is over the PRs this run merged.
+	MedianTimeToMergeSeconds int64      `json:"medianTimeToMergeSeconds,omitempty"`
+	Week                     *weekTrend `json:"week,omitempty"`
+}
+
+// weekTrend compares the last 7 days of non-dry runs, this one included,
+// with the 7 days before. Errors count distinct PRs.
+type weekTrend struct {
+	Merged                       int   `json:"merged"`
+	PrevMerged                   int   `json:"prevMerged"`
+	Errors                       int   `json:"errors"`
+	PrevErrors                   int   `json:"prevErrors"`
+	MedianTimeToMergeSeconds     int64 `json:"medianTimeToMergeSeconds,omitempty"`
+	PrevMedianTimeToMergeSeconds int64 `json:"prevMedianTimeToMergeSeconds,omitempty"`
+}
+
+// computeRunStats aggregates a run's outcomes.
+func computeRunStats(results []prOutcome) runStats {
+	var s runStats
+	var ttm []int64
+	for _, r := range results {
+		switch outcomeBucket(r.Action) {
+		case "skipped":
+			continue
+		case "merged":
+			s.Merged++
+		case "error":
+			s.Errors++
+		}
+		s.Outcomes++
+		if r.TimeToMergeSeconds > 0 {
+			ttm = append(ttm, r.TimeToMergeSeconds)
+		}
+	}
+	if s.Outcomes > 0 {
+		s.MergeRate = float64(s.Merged) / float64(s.Outcomes)
+		s.ErrorRate = float64(s.Errors) / float64(s.Outcomes)
+	}
+	s.MedianTimeToMergeSeconds = median(ttm)
+	return s
+}
+
+// median returns the middle value (the lower one for an even count), or 0
+// for none.
+func median(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted[(len(sorted)-1)/2]
+}
+
+// runStatsFor computes the run's stats and, for a real run with a history
+// DB at path, its weekly trend. The run itself isn't in the DB yet, so its
+// merges and errors are added to this week's counts. A failed history
+// lookup is logged and leaves the trend out.
+func runStatsFor(path string, out runOutput, now time.Time) *runStats {
+	stats := computeRunStats(out.Results)
+	if strings.TrimSpace(path) == "" || out.DryRun {
+		return &stats
+	}
+	h, err := openHistoryDB(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[stats] %v\n", err)
+		return &stats
+	}
+	defer func() { _ = h.Close() }()
+	week, err := h.WeekTrend(now, out.Results)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[stats] weekly trend failed: %v\n", err)
+		return &stats
+	}
+	stats.Week = &week
+	return &stats
+}
+
+// trendPhrase renders a count against last week's, e.g. "merged 12 this
+// week, up from 7".
+func trendPhrase(what string, n int, prev int) string {
+	switch {
+	case n > prev:
+		return fmt.Sprintf("%s %d this week, up from %d", what, n, prev)
+	case n < prev:
+		return fmt.Sprintf("%s %d this week, down from %d", what, n, prev)
+	}
+	return fmt.Sprintf("%s %d this week, same as last week", what, n)
+}
+
+// formatSeconds renders a duration coarsely: minutes under an hour, hours
+// under two days, days beyond.
+func formatSeconds(secs int64) string {
+	d := time.Duration(secs) * time.Second
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// statsLines renders the stats for the run report: this run's rates, then
+// the weekly trend if there is one.
+func statsLines(s runStats) []string {
+	run := fmt.Sprintf("merge rate %.0f%% · error rate %.0f%%", 100*s.MergeRate, 100*s.ErrorRate)
+	if s.MedianTimeToMergeSeconds > 0 {
+		run += " · median time to merge " + formatSeconds(s.MedianTimeToMergeSeconds)
+	}
+	lines := []string{run}
+	if w := s.Week; w != nil {
+		lines = append(lines, trendPhrase("merged", w.Merged, w.PrevMerged), trendPhrase("errors", w.Errors, w.PrevErrors))
+		if w.MedianTimeToMergeSeconds > 0 {
+			ttm := "median time to merge " + formatSeconds(w.MedianTimeToMergeSeconds) + " this week"
+			if w.PrevMedianTimeToMergeSeconds > 0 {
+				ttm += ", was " + formatSeconds(w.PrevMedianTimeToMergeSeconds)
+			}
+			lines = append(lines, ttm)
+		}
+	}
+	return lines
+}
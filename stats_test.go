@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeRunStats(t *testing.T) {
+	s := computeRunStats([]prOutcome{
+		{URL: "1", Action: "merged", TimeToMergeSeconds: 3600},
+		{URL: "2", Action: "merged", TimeToMergeSeconds: 7200},
+		{URL: "3", Action: "enqueued"},
+		{URL: "4", Action: "error"},
+		{URL: "5", Action: "skipped"},
+	})
+	want := runStats{Outcomes: 4, Merged: 3, Errors: 1, MergeRate: 0.75, ErrorRate: 0.25, MedianTimeToMergeSeconds: 3600}
+	if s != want {
+		t.Errorf("computeRunStats = %+v; want %+v", s, want)
+	}
+	if s := computeRunStats([]prOutcome{{Action: "skipped"}}); s != (runStats{}) {
+		t.Errorf("all skipped: %+v; want zero stats", s)
+	}
+}
+
+func TestStatsLines(t *testing.T) {
+	s := runStats{Outcomes: 5, Merged: 2, Errors: 1, MergeRate: 0.4, ErrorRate: 0.2, MedianTimeToMergeSeconds: 90 * 60}
+	s.Week = &weekTrend{Merged: 12, PrevMerged: 7, Errors: 2, PrevErrors: 2, MedianTimeToMergeSeconds: 3 * 86400, PrevMedianTimeToMergeSeconds: 1800}
+	want := []string{
+		"merge rate 40% · error rate 20% · median time to merge 1h",
+		"merged 12 this week, up from 7",
+		"errors 2 this week, same as last week",
+		"median time to merge 3d this week, was 30m",
+	}
+	if got := statsLines(s); !slices.Equal(got, want) {
+		t.Errorf("statsLines =\n%q\nwant\n%q", got, want)
+	}
+	if got := trendPhrase("merged", 3, 9); got != "merged 3 this week, down from 9" {
+		t.Errorf("trendPhrase = %q", got)
+	}
+}
+
+func TestHistoryDB_WeekTrend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	h, err := openHistoryDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = h.Close() }()
+
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	record := func(daysAgo int, dryRun bool, results ...prOutcome) {
+		t.Helper()
+		if err := h.RecordRun(runOutput{StartedAt: "x", Org: "o", DryRun: dryRun, Results: results}, now.Add(-time.Duration(daysAgo)*24*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record(10, false, prOutcome{URL: "a", Action: "merged", TimeToMergeSeconds: 7200}, prOutcome{URL: "b", Action: "error"})
+	record(9, false, prOutcome{URL: "b", Action: "error"}, prOutcome{URL: "c", Action: "error"})
+	record(3, false, prOutcome{URL: "d", Action: "merged", TimeToMergeSeconds: 600}, prOutcome{URL: "e", Action: "merged", TimeToMergeSeconds: 1200})
+	record(2, true, prOutcome{URL: "f", Action: "merged"}, prOutcome{URL: "g", Action: "error"})
+	record(20, false, prOutcome{URL: "h", Action: "merged"})
+
+	got, err := h.WeekTrend(now, []prOutcome{{URL: "i", Action: "merged", TimeToMergeSeconds: 300}, {URL: "j", Action: "error"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := weekTrend{Merged: 3, PrevMerged: 1, Errors: 1, PrevErrors: 2, MedianTimeToMergeSeconds: 600, PrevMedianTimeToMergeSeconds: 7200}
+	if got != want {
+		t.Errorf("WeekTrend = %+v; want %+v", got, want)
+	}
+
+	var merged int
+	var rate float64
+	if err := h.db.QueryRow(`SELECT merged, merge_rate FROM runs ORDER BY id LIMIT 1`).Scan(&merged, &rate); err != nil {
+		t.Fatal(err)
+	}
+	if merged != 1 || rate != 0.5 {
+		t.Errorf("first run stats = merged %d, rate %v; want 1, 0.5", merged, rate)
+	}
+}
+
+func TestOpenHistoryDB_migratesOldSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	// Reopening applies each migration once; the second open skips them all.
+	for i := 0; i < 2; i++ {
+		h, err := openHistoryDB(path)
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		_ = h.Close()
+	}
+	recordHistory(path, runOutput{Org: "o", Results: []prOutcome{{URL: "u", Action: "merged", TimeToMergeSeconds: 60}}})
+}
+
+func TestRunStatsFor(t *testing.T) {
+	out := runOutput{Results: []prOutcome{{URL: "u", Action: "merged"}}}
+	if s := runStatsFor("", out, time.Now()); s.Merged != 1 || s.Week != nil {
+		t.Errorf("without history: %+v", s)
+	}
+	path := filepath.Join(t.TempDir(), "history.db")
+	if s := runStatsFor(path, out, time.Now()); s.Week == nil || s.Week.Merged != 1 {
+		t.Errorf("with history: %+v", s)
+	}
+	out.DryRun = true
+	if s := runStatsFor(path, out, time.Now()); s.Week != nil {
+		t.Errorf("dry run got a weekly trend: %+v", s.Week)
+	}
+}
+
+func TestRenderDiscordEmbedsStats(t *testing.T) {
+	out := runOutput{Ok: true, Org: "misty-step", Results: []prOutcome{{URL: "u", Action: "merged"}}}
+	out.Stats = &runStats{Outcomes: 1, Merged: 1, MergeRate: 1, Week: &weekTrend{Merged: 12, PrevMerged: 7}}
+	var stats string
+	for _, f := range renderDiscordEmbeds(out, 1, 0, 0, 0)[0].Fields {
+		if f.Name == "Stats" {
+			stats = f.Value
+		}
+	}
+	if !strings.Contains(stats, "merged 12 this week, up from 7") {
+		t.Errorf("Stats field = %q", stats)
+	}
+}
+
+func TestPipelineRecordsTimeToMerge(t *testing.T) {
+	now := time.Now()
+	pr := fakePR("misty-step/api", 1)
+	pr.CreatedAt = now.Add(-26 * time.Hour)
+	fake := newFakeGitHub(pr)
+
+	p := newPipeline(testPipelineOptions(t))
+	p.client = fake
+	p.now = func() time.Time { return now }
+	out, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Results) != 1 || out.Results[0].Action != "merged" {
+		t.Fatalf("results = %+v", out.Results)
+	}
+	if got := out.Results[0].TimeToMergeSeconds; got != 26*3600 {
+		t.Errorf("TimeToMergeSeconds = %d; want %d", got, 26*3600)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// stickyCommentMarker opens the pipeline's status comment on a PR. There is
+// at most one per PR; each run edits it rather than posting a new comment.
+const stickyCommentMarker = "<!-- kaylee-pr-pipeline -->"
+
+type issueComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+// findStickyComment returns the newest pipeline status comment, or nil.
+func findStickyComment(comments []issueComment) *issueComment {
+	for i := len(comments) - 1; i >= 0; i-- {
+		if strings.HasPrefix(strings.TrimSpace(comments[i].Body), stickyCommentMarker) {
+			return &comments[i]
+		}
+	}
+	return nil
+}
+
+// commentBodies returns the comment bodies, oldest first.
+func commentBodies(comments []issueComment) []string {
+	bodies := make([]string, 0, len(comments))
+	for _, c := range comments {
+		bodies = append(bodies, c.Body)
+	}
+	return bodies
+}
+
+// stickyCommentBody stamps a status comment body with when it was written.
+func stickyCommentBody(body string, now time.Time) string {
+	return fmt.Sprintf("%s\n\n_Last updated %s._", body, now.UTC().Format(time.RFC3339))
+}
+
+// upsertStickyComment writes body as the PR's status comment: editing
+// existing in place when there is one, otherwise posting a new comment.
+func upsertStickyComment(ctx context.Context, url string, repo string, existing *issueComment, body string, now time.Time) error {
+	body = stickyCommentBody(body, now)
+	if existing != nil {
+		return ghEditIssueComment(ctx, repo, existing.ID, body)
+	}
+	return ghPRComment(ctx, url, body)
+}
+
+// ghIssueComments fetches every comment on a PR (or issue), oldest first.
+func ghIssueComments(ctx context.Context, repo string, number int) ([]issueComment, error) {
+	if strings.TrimSpace(repo) == "" {
+		return nil, errors.New("repo required")
+	}
+	stdout, err := runGh(ctx, "api", "--paginate", fmt.Sprintf("repos/%s/issues/%d/comments?per_page=100", repo, number))
+	if err != nil {
+		return nil, err
+	}
+	return parseIssueComments(stdout)
+}
+
+// parseIssueComments decodes `gh api --paginate` output, which is one JSON
+// array per page written back to back.
+func parseIssueComments(raw []byte) ([]issueComment, error) {
+	var comments []issueComment
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	for {
+		var page []issueComment
+		if err := dec.Decode(&page); err != nil {
+			if errors.Is(err, io.EOF) {
+				return comments, nil
+			}
+			return nil, fmt.Errorf("parse issue comments json: %w", err)
+		}
+		comments = append(comments, page...)
+	}
+}
+
+func ghEditIssueComment(ctx context.Context, repo string, id int64, body string) error {
+	if strings.TrimSpace(body) == "" {
+		return errors.New("comment body required")
+	}
+	_, err := runGh(ctx, "api", "-X", "PATCH", fmt.Sprintf("repos/%s/issues/comments/%d", repo, id), "-f", "body="+body)
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseIssueComments_pages(t *testing.T) {
+	raw := []byte(`[{"id":1,"body":"first"},{"id":2,"body":"second"}]
+[{"id":3,"body":"third"}]
+`)
+	comments, err := parseIssueComments(raw)
+	if err != nil {
+		t.Fatalf("parseIssueComments: %v", err)
+	}
+	if len(comments) != 3 || comments[0].ID != 1 || comments[2].Body != "third" {
+		t.Errorf("parseIssueComments = %+v", comments)
+	}
+
+	if _, err := parseIssueComments([]byte(`{"message":"nope"}`)); err == nil {
+		t.Error("expected error for non-array JSON")
+	}
+	if comments, err := parseIssueComments(nil); err != nil || len(comments) != 0 {
+		t.Errorf("empty output = %+v, %v; want none", comments, err)
+	}
+}
+
+func TestFindStickyComment(t *testing.T) {
+	comments := []issueComment{
+		{ID: 1, Body: stickyCommentMarker + "\nold status"},
+		{ID: 2, Body: "LGTM"},
+		{ID: 3, Body: stickyCommentMarker + "\nnew status"},
+		{ID: 4, Body: "quoting <!-- kaylee-pr-pipeline --> inline"},
+	}
+	if got := findStickyComment(comments); got == nil || got.ID != 3 {
+		t.Errorf("findStickyComment = %+v; want ID 3", got)
+	}
+	if got := findStickyComment([]issueComment{{ID: 9, Body: "hi"}}); got != nil {
+		t.Errorf("findStickyComment = %+v; want nil", got)
+	}
+}
+
+func TestStickyCommentBody(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+	pr := &prView{Mergeable: "MERGEABLE", ReviewDecision: "REVIEW_REQUIRED"}
+	body := stickyCommentBody(buildCommentBody(pr, "review_required"), now)
+
+	if !strings.HasPrefix(body, stickyCommentMarker+"\n") {
+		t.Errorf("status comment should start with the sticky marker; got:\n%s", body)
+	}
+	if !strings.HasSuffix(body, "_Last updated 2025-06-01T12:30:00Z._") {
+		t.Errorf("status comment should end with its timestamp; got:\n%s", body)
+	}
+	if findStickyComment([]issueComment{{ID: 1, Body: body}}) == nil {
+		t.Error("findStickyComment should find a comment built by stickyCommentBody")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// outcomeStream writes each PR outcome as a JSON line the moment it's
+// decided, so long runs can be watched live and a run that dies halfway
+// still leaves its outcomes behind. A nil stream writes nothing.
+type outcomeStream struct {
+	w io.Writer
+}
+
+// newOutcomeStream returns the stream for --stream / --stream-file, or nil
+// when neither is set. The file is appended to, so a server's runs accumulate.
+func newOutcomeStream(toStdout bool, path string) (*outcomeStream, error) {
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("open --stream-file: %w", err)
+		}
+		return &outcomeStream{w: f}, nil
+	}
+	if toStdout {
+		return &outcomeStream{w: os.Stdout}, nil
+	}
+	return nil, nil
+}
+
+// emit writes o as one JSON line and returns it unchanged, so callers can
+// stream and record an outcome in one step.
+func (s *outcomeStream) emit(o prOutcome) prOutcome {
+	if s == nil {
+		return o
+	}
+	b, err := json.Marshal(o)
+	if err != nil {
+		return o
+	}
+	if _, err := s.w.Write(append(b, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "[stream] write failed: %v\n", err)
+	}
+	return o
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutcomeStreamEmit(t *testing.T) {
+	var nilStream *outcomeStream
+	if got := nilStream.emit(prOutcome{Action: "merged"}); got.Action != "merged" {
+		t.Errorf("nil stream changed the outcome: %+v", got)
+	}
+
+	var buf bytes.Buffer
+	s := &outcomeStream{w: &buf}
+	s.emit(prOutcome{URL: "https://github.com/o/r/pull/1", Action: "merged"})
+	s.emit(prOutcome{URL: "https://github.com/o/r/pull/2", Action: "skipped", Reason: "draft"})
+
+	sc := bufio.NewScanner(&buf)
+	var got []prOutcome
+	for sc.Scan() {
+		var o prOutcome
+		if err := json.Unmarshal(sc.Bytes(), &o); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		got = append(got, o)
+	}
+	if len(got) != 2 || got[1].Reason != "draft" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestNewOutcomeStreamFileAppends(t *testing.T) {
+	if s, err := newOutcomeStream(false, ""); s != nil || err != nil {
+		t.Fatalf("unset: %v, %v", s, err)
+	}
+	path := filepath.Join(t.TempDir(), "outcomes.jsonl")
+	for range 2 {
+		s, err := newOutcomeStream(true, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.emit(prOutcome{Action: "merged"})
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != 2 {
+		t.Errorf("file has %d lines; want 2 (appended across runs)", n)
+	}
+	if _, err := newOutcomeStream(false, filepath.Join(t.TempDir(), "missing", "x.jsonl")); err == nil {
+		t.Error("expected an error for an unwritable path")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ghTokenPool, when set (GH_TOKENS or --gh-token-file), supplies the
+// GH_TOKEN for every gh and git command runCmd starts, in place of gh's
+// own login.
+var ghTokenPool *tokenPool
+
+// tokenRateLimitCooldown is how long a rate-limited token sits out when
+// GitHub didn't say when its limit resets.
+const tokenRateLimitCooldown = 15 * time.Minute
+
+// tokenPool hands out GitHub tokens round-robin. A token that hits a rate
+// limit sits out until the limit resets; one GitHub rejects (HTTP 401)
+// sits out for the rest of the process. A command that fails either way
+// is run again with the next token.
+type tokenPool struct {
+	mu     sync.Mutex
+	tokens []*poolToken
+	next   int
+	now    func() time.Time
+}
+
+type poolToken struct {
+	value string
+	n     int // 1-based, for logs; the token itself is never printed
+	// until is when a rate-limited token can be used again.
+	until    time.Time
+	rejected bool
+}
+
+func newTokenPool(tokens []string) *tokenPool {
+	addRedaction(tokens...)
+	p := &tokenPool{now: time.Now}
+	for i, t := range tokens {
+		p.tokens = append(p.tokens, &poolToken{value: t, n: i + 1})
+	}
+	return p
+}
+
+// loadGHTokens collects the pool's tokens: those in GH_TOKENS (separated
+// by commas or whitespace), then those in the file at path, one per line,
+// skipping blanks and # comments. Repeats are dropped.
+func loadGHTokens(env string, path string) ([]string, error) {
+	var tokens []string
+	add := func(t string) {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(tokens, t) {
+			tokens = append(tokens, t)
+		}
+	}
+	for _, t := range strings.FieldsFunc(env, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' }) {
+		add(t)
+	}
+	if strings.TrimSpace(path) != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("--gh-token-file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); !strings.HasPrefix(line, "#") {
+				add(line)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// run calls fn with a token, and again with the next one while the token
+// it used is rate limited or rejected. It returns the last failure once
+// every token has been tried.
+func (p *tokenPool) run(fn func(token string) ([]byte, error)) ([]byte, error) {
+	var lastErr error
+	for range p.tokens {
+		t, err := p.pick()
+		if err != nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, err
+		}
+		out, err := fn(t.value)
+		if err == nil || !p.benched(t, err) {
+			return out, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// pick returns the next usable token after the last one handed out.
+func (p *tokenPool) pick() (*poolToken, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	var soonest time.Time
+	for i := range p.tokens {
+		t := p.tokens[(p.next+i)%len(p.tokens)]
+		if t.rejected {
+			continue
+		}
+		if now.Before(t.until) {
+			if soonest.IsZero() || t.until.Before(soonest) {
+				soonest = t.until
+			}
+			continue
+		}
+		p.next = (p.next + i + 1) % len(p.tokens)
+		return t, nil
+	}
+	if soonest.IsZero() {
+		return nil, errors.New("every token in the pool was rejected by GitHub (HTTP 401)")
+	}
+	return nil, fmt.Errorf("every token in the pool is rate limited until at least %s", soonest.UTC().Format(time.RFC3339))
+}
+
+// benched takes t out of rotation if err says it's rate limited or
+// rejected, and reports whether it did.
+func (p *tokenPool) benched(t *poolToken, err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	switch {
+	case isRateLimitError(err):
+		wait := retryAfter(err, now)
+		if wait <= 0 {
+			wait = tokenRateLimitCooldown
+		}
+		t.until = now.Add(wait)
+		fmt.Fprintf(os.Stderr, "[tokens] token %d rate limited; resting it until %s\n", t.n, t.until.UTC().Format(time.RFC3339))
+		return true
+	case isBadCredentials(err):
+		t.rejected = true
+		fmt.Fprintf(os.Stderr, "[tokens] token %d rejected by GitHub (HTTP 401); dropping it until restart\n", t.n)
+		return true
+	}
+	return false
+}
+
+// isBadCredentials reports whether GitHub refused the token itself: a 401,
+// or a status-less failure whose output (not the command's arguments) says
+// so.
+func isBadCredentials(err error) bool {
+	switch status, _ := githubErrorDetails(err); status {
+	case 401:
+		return true
+	case 0:
+		return strings.Contains(strings.ToLower(errorOutput(err)), "bad credentials")
+	}
+	return false
+}
+
+// label names token by its place in the pool, as the logs do.
+func (p *tokenPool) label(token string) string {
+	for _, t := range p.tokens {
+		if t.value == token {
+			return fmt.Sprintf("token %d", t.n)
+		}
+	}
+	return "token"
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenPoolRoundRobin(t *testing.T) {
+	p := newTokenPool([]string{"a", "b", "c"})
+	var used []string
+	for range 4 {
+		if _, err := p.run(func(token string) ([]byte, error) {
+			used = append(used, token)
+			return nil, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(used, []string{"a", "b", "c", "a"}) {
+		t.Errorf("tokens used = %v; want round-robin", used)
+	}
+}
+
+func TestTokenPoolFailover(t *testing.T) {
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	p := newTokenPool([]string{"limited", "revoked", "good"})
+	p.now = func() time.Time { return now }
+	fail := map[string]error{
+		"limited": errors.New("gh api graphql: API rate limit exceeded for user ID 1. (HTTP 403)"),
+		"revoked": errors.New("gh api user: Bad credentials (HTTP 401)"),
+	}
+	var used []string
+	call := func(token string) ([]byte, error) {
+		used = append(used, token)
+		return []byte(token), fail[token]
+	}
+
+	out, err := p.run(call)
+	if err != nil || string(out) != "good" {
+		t.Fatalf("run() = %q, %v; want the good token's output", out, err)
+	}
+	if !slices.Equal(used, []string{"limited", "revoked", "good"}) {
+		t.Errorf("tokens tried = %v; want each in turn", used)
+	}
+
+	// Only the good token is left in rotation until the limit resets.
+	used = nil
+	p.run(call)
+	p.run(call)
+	if !slices.Equal(used, []string{"good", "good"}) {
+		t.Errorf("tokens used = %v; want only the good one", used)
+	}
+	now = now.Add(tokenRateLimitCooldown)
+	delete(fail, "limited")
+	used = nil
+	p.run(call)
+	p.run(call)
+	if !slices.Equal(used, []string{"limited", "good"}) {
+		t.Errorf("after the cooldown, tokens used = %v; want the limited one back", used)
+	}
+}
+
+func TestTokenPoolExhausted(t *testing.T) {
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	p := newTokenPool([]string{"a", "b"})
+	p.now = func() time.Time { return now }
+	limited := errors.New("gh api graphql: HTTP 429: too many requests")
+	if _, err := p.run(func(string) ([]byte, error) { return nil, limited }); err != limited {
+		t.Errorf("run() = %v; want the last rate limit error", err)
+	}
+	_, err := p.run(func(string) ([]byte, error) { return nil, nil })
+	if err == nil || !strings.Contains(err.Error(), "rate limited until") || !IsTransient(err) {
+		t.Errorf("run() with every token resting = %v; want a transient rate limit error", err)
+	}
+
+	// Other failures don't bench the token.
+	p = newTokenPool([]string{"a", "b"})
+	notFound := errors.New("gh pr view: not found (HTTP 404)")
+	var used []string
+	if _, err := p.run(func(token string) ([]byte, error) { used = append(used, token); return nil, notFound }); err != notFound || len(used) != 1 {
+		t.Errorf("run() = %v after %v; want the 404 from one token", err, used)
+	}
+
+	// Nor does a rate limit or bad credentials quoted in the arguments.
+	quoted := &cmdError{
+		msg:    "gh pr comment https://github.com/o/r/pull/1 --body rate limit? bad credentials?: gh: Validation Failed (HTTP 422)",
+		stderr: "gh: Validation Failed (HTTP 422)",
+	}
+	used = nil
+	if _, err := p.run(func(token string) ([]byte, error) { used = append(used, token); return nil, quoted }); err != quoted || len(used) != 1 {
+		t.Errorf("run() = %v after %v; want the 422 from one token", err, used)
+	}
+}
+
+func TestLoadGHTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(path, []byte("# ops bot\nghp_c\n\nghp_a\n  ghp_d  \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadGHTokens("ghp_a, ghp_b\nghp_c", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []string{"ghp_a", "ghp_b", "ghp_c", "ghp_d"}) {
+		t.Errorf("loadGHTokens() = %v", got)
+	}
+	if _, err := loadGHTokens("", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("loadGHTokens() with a missing file succeeded")
+	}
+}
+
+func TestTokenPoolRejectsAppAuth(t *testing.T) {
+	t.Setenv("GH_TOKENS", "ghp_a,ghp_b")
+	opts, code := parseRunFlags("run", []string{"-app-id", "1", "-app-key-file", "key.pem"})
+	if opts != nil || code == 0 {
+		t.Errorf("parseRunFlags with --app-id and GH_TOKENS = %v, %d; want it refused", opts, code)
+	}
+}
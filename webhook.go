@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// webhookSecretEnv names the secret GitHub signs webhook deliveries with.
+const webhookSecretEnv = "GITHUB_WEBHOOK_SECRET"
+
+// maxWebhookBody is GitHub's cap on webhook payload size.
+const maxWebhookBody = 25 << 20
+
+// webhookTarget is a PR a webhook delivery asked us to look at. RepoURL is
+// the repo's web URL from the delivery, so PRs on a GitHub Enterprise
+// Server link to it; without one the PR is taken to be on github.com.
+type webhookTarget struct {
+	Repo    string
+	Number  int
+	RepoURL string
+}
+
+func (t webhookTarget) URL() string {
+	if t.RepoURL != "" {
+		return fmt.Sprintf("%s/pull/%d", strings.TrimSuffix(t.RepoURL, "/"), t.Number)
+	}
+	return fmt.Sprintf("https://github.com/%s/pull/%d", t.Repo, t.Number)
+}
+
+// webhookPayload is the subset of the check_suite, pull_request, and
+// pull_request_review payloads we read.
+type webhookPayload struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+	PullRequest *struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	CheckSuite *struct {
+		PullRequests []struct {
+			Number int `json:"number"`
+		} `json:"pull_requests"`
+	} `json:"check_suite"`
+}
+
+// verifyWebhookSignature checks the X-Hub-Signature-256 header against the
+// HMAC-SHA256 of body.
+func verifyWebhookSignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok || secret == "" {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// webhookTargets returns the PRs a delivery affects. Events we don't act on
+// return no targets and a reason, which is reported back to GitHub.
+func webhookTargets(event string, body []byte, orgs []string) ([]webhookTarget, string, error) {
+	var p webhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, "", fmt.Errorf("parse webhook payload: %w", err)
+	}
+	repo := p.Repository.FullName
+	if event != "ping" && !slices.ContainsFunc(orgs, func(org string) bool { return strings.EqualFold(repoOwner(repo), org) }) {
+		return nil, "repo outside " + strings.Join(orgs, ", "), nil
+	}
+
+	var numbers []int
+	switch {
+	case event == "ping":
+		return nil, "ping", nil
+	case event == "check_suite" && p.Action == "completed" && p.CheckSuite != nil:
+		for _, pr := range p.CheckSuite.PullRequests {
+			numbers = append(numbers, pr.Number)
+		}
+	case event == "pull_request_review" && p.Action == "submitted" && p.PullRequest != nil:
+		numbers = append(numbers, p.PullRequest.Number)
+	case event == "pull_request" && p.Action == "synchronize" && p.PullRequest != nil:
+		numbers = append(numbers, p.PullRequest.Number)
+	default:
+		return nil, fmt.Sprintf("ignored event %s/%s", event, p.Action), nil
+	}
+	if len(numbers) == 0 {
+		// e.g. a check suite on a branch with no open PR.
+		return nil, "no pull requests", nil
+	}
+	targets := make([]webhookTarget, 0, len(numbers))
+	for _, n := range numbers {
+		targets = append(targets, webhookTarget{Repo: repo, Number: n, RepoURL: p.Repository.HTMLURL})
+	}
+	return targets, "", nil
+}
+
+// handleWebhook verifies a GitHub delivery and runs the pipeline on just the
+// affected PRs. While a run is in progress the PRs are queued for the next one.
+func (s *pipelineServer) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.webhookSecret == "" {
+		writeJSONStatus(w, http.StatusServiceUnavailable, map[string]any{"ok": false, "error": webhookSecretEnv + " is not set"})
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		writeJSONStatus(w, http.StatusRequestEntityTooLarge, map[string]any{"ok": false, "error": err.Error()})
+		return
+	}
+	if !verifyWebhookSignature(s.webhookSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		writeJSONStatus(w, http.StatusUnauthorized, map[string]any{"ok": false, "error": "invalid signature"})
+		return
+	}
+	targets, ignored, err := webhookTargets(r.Header.Get("X-GitHub-Event"), body, s.opts.orgs)
+	if err != nil {
+		writeJSONStatus(w, http.StatusBadRequest, map[string]any{"ok": false, "error": err.Error()})
+		return
+	}
+	if len(targets) == 0 {
+		writeJSONStatus(w, http.StatusOK, map[string]any{"ok": true, "ignored": ignored})
+		return
+	}
+
+	urls := make([]string, 0, len(targets))
+	for _, t := range targets {
+		urls = append(urls, t.URL())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		for _, t := range targets {
+			s.pending[t.URL()] = t
+		}
+		writeJSONStatus(w, http.StatusAccepted, map[string]any{"ok": true, "queued": urls})
+		return
+	}
+	s.startLocked(s.targetOptions(targets))
+	writeJSONStatus(w, http.StatusAccepted, map[string]any{"ok": true, "started": urls})
+}
+
+// targetOptions returns a copy of the server's options that runs on exactly
+// the given PRs instead of searching the org.
+func (s *pipelineServer) targetOptions(targets []webhookTarget) *runOptions {
+	opts := *s.opts
+	opts.targets = targets
+	opts.MaxPRs = max(opts.MaxPRs, len(targets))
+	return &opts
+}
+
+// targetPRs fetches the webhook-targeted PRs and applies the usual selection
+// policy to them (repo scope, excluded repos, author stale waits, drafts),
+// returning the PRs to act on and the ones it left out. Closed PRs are
+// dropped.
+func targetPRs(ctx context.Context, opts *runOptions, now time.Time) ([]searchPR, []filteredPR) {
+	var prs []searchPR
+	for _, t := range opts.targets {
+		pr, err := RetryableWithResult(ctx, func() (targetPR, error) {
+			return ghTargetPR(ctx, t)
+		}, retryCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[webhook] %s: %v\n", t.URL(), err)
+			continue
+		}
+		if pr.State != "OPEN" {
+			opts.explain.note("state", "%s; only open PRs are processed", pr.State)
+			continue
+		}
+		prs = append(prs, pr.searchPR)
+	}
+	return partitionPRs(opts, prs, now)
+}
+
+// targetPR is a searchPR plus the open/closed state the search filters on,
+// and the checks its ChecksState is worked out from.
+type targetPR struct {
+	searchPR
+	State             string              `json:"state"`
+	StatusCheckRollup []statusRollupEntry `json:"statusCheckRollup"`
+}
+
+func ghTargetPR(ctx context.Context, t webhookTarget) (targetPR, error) {
+	var pr targetPR
+	stdout, err := runGh(ctx, "pr", "view", t.URL(), "--json", "url,title,body,updatedAt,isDraft,number,author,labels,state,headRefOid,statusCheckRollup")
+	if err != nil {
+		return pr, err
+	}
+	if err := json.Unmarshal(stdout, &pr); err != nil {
+		return pr, fmt.Errorf("parse gh pr view json: %w", err)
+	}
+	pr.Repository.NameWithOwner = t.Repo
+	pr.ChecksState = overallChecksState(pr.StatusCheckRollup)
+	return pr, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"action":"completed"}`)
+	good := signWebhook("s3cret", body)
+	if !verifyWebhookSignature("s3cret", body, good) {
+		t.Error("valid signature rejected")
+	}
+	for name, header := range map[string]string{
+		"wrong secret": signWebhook("other", body),
+		"no prefix":    good[len("sha256="):],
+		"not hex":      "sha256=zz",
+		"empty":        "",
+	} {
+		if verifyWebhookSignature("s3cret", body, header) {
+			t.Errorf("%s: signature accepted", name)
+		}
+	}
+	if verifyWebhookSignature("", body, signWebhook("", body)) {
+		t.Error("empty secret must never verify")
+	}
+}
+
+func TestWebhookTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   string
+		body    string
+		want    []int
+		ignored bool
+	}{
+		{"check suite completed", "check_suite",
+			`{"action":"completed","repository":{"full_name":"misty-step/fab"},"check_suite":{"pull_requests":[{"number":3},{"number":5}]}}`,
+			[]int{3, 5}, false},
+		{"check suite requested", "check_suite",
+			`{"action":"requested","repository":{"full_name":"misty-step/fab"},"check_suite":{"pull_requests":[{"number":3}]}}`,
+			nil, true},
+		{"check suite without PRs", "check_suite",
+			`{"action":"completed","repository":{"full_name":"misty-step/fab"},"check_suite":{"pull_requests":[]}}`,
+			nil, true},
+		{"review submitted", "pull_request_review",
+			`{"action":"submitted","repository":{"full_name":"misty-step/fab"},"pull_request":{"number":7}}`,
+			[]int{7}, false},
+		{"synchronize", "pull_request",
+			`{"action":"synchronize","repository":{"full_name":"Misty-Step/fab"},"pull_request":{"number":9}}`,
+			[]int{9}, false},
+		{"pull request opened", "pull_request",
+			`{"action":"opened","repository":{"full_name":"misty-step/fab"},"pull_request":{"number":9}}`,
+			nil, true},
+		{"other org", "pull_request",
+			`{"action":"synchronize","repository":{"full_name":"elsewhere/fab"},"pull_request":{"number":9}}`,
+			nil, true},
+		{"ping", "ping", `{"zen":"Keep it logically awesome."}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, ignored, err := webhookTargets(tt.event, []byte(tt.body), []string{"misty-step"})
+			if err != nil {
+				t.Fatalf("webhookTargets: %v", err)
+			}
+			if (ignored != "") != tt.ignored {
+				t.Errorf("ignored = %q; want ignored %v", ignored, tt.ignored)
+			}
+			var got []int
+			for _, tg := range targets {
+				got = append(got, tg.Number)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("targets = %v; want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("targets = %v; want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, _, err := webhookTargets("pull_request", []byte("nope"), []string{"misty-step"}); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+	if got := (webhookTarget{Repo: "o/r", Number: 4}).URL(); got != "https://github.com/o/r/pull/4" {
+		t.Errorf("URL = %q", got)
+	}
+
+	// The PR links to the repo's host, e.g. a GitHub Enterprise Server.
+	body := `{"action":"synchronize","repository":{"full_name":"misty-step/fab","html_url":"https://ghe.example.com/misty-step/fab"},"pull_request":{"number":9}}`
+	targets, _, err := webhookTargets("pull_request", []byte(body), []string{"misty-step"})
+	if err != nil || len(targets) != 1 || targets[0].URL() != "https://ghe.example.com/misty-step/fab/pull/9" {
+		t.Errorf("targets = %+v, %v; want the PR on ghe.example.com", targets, err)
+	}
+}
+
+func postWebhook(t *testing.T, h http.Handler, secret string, event string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader([]byte(body)))
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-Hub-Signature-256", signWebhook(secret, []byte(body)))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPipelineServer_webhook(t *testing.T) {
+	review := `{"action":"submitted","repository":{"full_name":"misty-step/fab"},"pull_request":{"number":7}}`
+	suite := `{"action":"completed","repository":{"full_name":"misty-step/fab"},"check_suite":{"pull_requests":[{"number":8}]}}`
+
+	t.Run("requires a configured secret", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		if rec := postWebhook(t, s.handler(), "", "pull_request_review", review); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("status = %d; want 503", rec.Code)
+		}
+	})
+
+	t.Run("rejects bad signatures", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		s.webhookSecret = "s3cret"
+		if rec := postWebhook(t, s.handler(), "wrong", "pull_request_review", review); rec.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d; want 401", rec.Code)
+		}
+	})
+
+	t.Run("ignores other events", func(t *testing.T) {
+		s := newTestServer(t, nil)
+		s.webhookSecret = "s3cret"
+		if rec := postWebhook(t, s.handler(), "s3cret", "issues", `{"action":"opened","repository":{"full_name":"misty-step/fab"}}`); rec.Code != http.StatusOK {
+			t.Errorf("status = %d; want 200", rec.Code)
+		}
+	})
+
+	t.Run("runs the affected PR and queues arrivals mid-run", func(t *testing.T) {
+		release := make(chan struct{})
+		runs := make(chan []webhookTarget, 2)
+		s := newTestServer(t, func(opts *runOptions) (runOutput, error) {
+			runs <- opts.targets
+			<-release
+			return runOutput{Ok: true}, nil
+		})
+		s.webhookSecret = "s3cret"
+		h := s.handler()
+
+		if rec := postWebhook(t, h, "s3cret", "pull_request_review", review); rec.Code != http.StatusAccepted {
+			t.Fatalf("first delivery = %d; want 202 (%s)", rec.Code, rec.Body)
+		}
+		if first := <-runs; len(first) != 1 || first[0].Number != 7 {
+			t.Errorf("first run targets = %+v; want #7", first)
+		}
+		if rec := postWebhook(t, h, "s3cret", "check_suite", suite); rec.Code != http.StatusAccepted || !bytes.Contains(rec.Body.Bytes(), []byte("queued")) {
+			t.Errorf("mid-run delivery = %d %s; want 202 queued", rec.Code, rec.Body)
+		}
+		release <- struct{}{}
+		second := <-runs
+		sort.Slice(second, func(i, j int) bool { return second[i].Number < second[j].Number })
+		if len(second) != 1 || second[0].Number != 8 {
+			t.Errorf("second run targets = %+v; want #8", second)
+		}
+		release <- struct{}{}
+		waitIdle(t, s)
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Workflows on a PR from a fork (or a first-time contributor) don't run
+// until a maintainer approves them; until then their checks conclude
+// ACTION_REQUIRED, and the PR is blocked with workflows_awaiting_approval
+// rather than looking like failed or slow CI.
+
+// trustedWorkflowAuthor reports whether --approve-workflows-for lists the
+// PR's author, so the pipeline may approve their workflow runs.
+func trustedWorkflowAuthor(opts *runOptions, login string) bool {
+	return login != "" && slices.Contains(opts.workflowAuthors, authorKey(login))
+}
+
+// approveWorkflowRuns approves every workflow run on the PR's head commit
+// that's waiting for approval, and returns how many it approved.
+func approveWorkflowRuns(ctx context.Context, repo string, headSHA string) (int, error) {
+	ids, err := RetryableWithResult(ctx, func() ([]int64, error) {
+		return ghRunsAwaitingApproval(ctx, repo, headSHA)
+	}, retryCfg)
+	if err != nil {
+		return 0, err
+	}
+	approved := 0
+	for _, id := range ids {
+		if err := Retryable(ctx, func() error {
+			return ghApproveRun(ctx, repo, id)
+		}, retryCfg); err != nil {
+			return approved, err
+		}
+		approved++
+	}
+	return approved, nil
+}
+
+// ghRunsAwaitingApproval lists the IDs of the workflow runs on headSHA that
+// are waiting for a maintainer's approval.
+func ghRunsAwaitingApproval(ctx context.Context, repo string, headSHA string) ([]int64, error) {
+	if strings.TrimSpace(repo) == "" || strings.TrimSpace(headSHA) == "" {
+		return nil, errors.New("repo and head sha required")
+	}
+	stdout, err := runGh(ctx, "api", fmt.Sprintf("repos/%s/actions/runs?head_sha=%s&status=action_required&per_page=100", repo, headSHA))
+	if err != nil {
+		return nil, err
+	}
+	var v struct {
+		WorkflowRuns []struct {
+			ID int64 `json:"id"`
+		} `json:"workflow_runs"`
+	}
+	if err := json.Unmarshal(stdout, &v); err != nil {
+		return nil, fmt.Errorf("parse workflow runs json: %w", err)
+	}
+	var ids []int64
+	for _, r := range v.WorkflowRuns {
+		ids = append(ids, r.ID)
+	}
+	return ids, nil
+}
+
+// ghApproveRun approves a fork PR's workflow run so it can start.
+func ghApproveRun(ctx context.Context, repo string, runID int64) error {
+	_, err := runGh(ctx, "api", "-X", "POST", fmt.Sprintf("repos/%s/actions/runs/%d/approve", repo, runID))
+	return err
+}
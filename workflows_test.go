@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestChecksAwaitingApproval(t *testing.T) {
+	awaiting := statusRollupEntry{Typename: "CheckRun", Name: "ci", Status: "COMPLETED", Conclusion: "ACTION_REQUIRED"}
+	passed := statusRollupEntry{Typename: "CheckRun", Name: "lint", Status: "COMPLETED", Conclusion: "SUCCESS"}
+	running := statusRollupEntry{Typename: "CheckRun", Name: "e2e", Status: "IN_PROGRESS"}
+	failed := statusRollupEntry{Typename: "CheckRun", Name: "test", Status: "COMPLETED", Conclusion: "FAILURE"}
+
+	tests := []struct {
+		entries []statusRollupEntry
+		want    string
+	}{
+		{[]statusRollupEntry{awaiting, passed}, "ACTION_REQUIRED"},
+		{[]statusRollupEntry{awaiting, running}, "ACTION_REQUIRED"},
+		{[]statusRollupEntry{awaiting, failed}, "FAILURE"},
+	}
+	for _, tt := range tests {
+		if got := overallChecksState(tt.entries); got != tt.want {
+			t.Errorf("overallChecksState(%+v) = %q; want %q", tt.entries, got, tt.want)
+		}
+	}
+	if got := failingChecks([]statusRollupEntry{awaiting, failed}); len(got) != 1 || got[0].Name != "test" {
+		t.Errorf("failingChecks = %+v; want only the failed check", got)
+	}
+
+	pr := &prView{Mergeable: "MERGEABLE", ReviewDecision: "APPROVED", StatusCheckRollup: []statusRollupEntry{awaiting, passed}}
+	if ok, reason := mergeAllowed(pr, repoPolicy{}); ok || reason != "workflows_awaiting_approval" {
+		t.Errorf("mergeAllowed = %v, %q; want workflows_awaiting_approval", ok, reason)
+	}
+}
+
+func TestPipelineApprovesTrustedWorkflows(t *testing.T) {
+	for _, trusted := range []bool{false, true} {
+		fork := fakePR("misty-step/api", 1)
+		fork.StatusCheckRollup = []statusRollupEntry{{Typename: "CheckRun", Name: "ci", Status: "COMPLETED", Conclusion: "ACTION_REQUIRED"}}
+		fake := newFakeGitHub(fork)
+		var args []string
+		if trusted {
+			args = []string{"-approve-workflows-for", "someone-else,Kaylee-MistyStep[bot]"}
+		}
+		p := newPipeline(testPipelineOptions(t, args...))
+
+		var approved []string
+		p.client = ghFunc(func(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+			call := strings.Join(args, " ")
+			switch {
+			case strings.Contains(call, "/actions/runs?head_sha=abc123&status=action_required"):
+				return []byte(`{"workflow_runs":[{"id":11},{"id":12}]}`), nil
+			case strings.HasSuffix(call, "/approve"):
+				approved = append(approved, args[len(args)-1])
+				return nil, nil
+			}
+			return fake.Run(ctx, stdin, args...)
+		})
+		out, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Results) != 1 {
+			t.Fatalf("results = %+v", out.Results)
+		}
+		r := out.Results[0]
+		switch {
+		case trusted:
+			want := []string{"repos/misty-step/api/actions/runs/11/approve", "repos/misty-step/api/actions/runs/12/approve"}
+			if r.Action != "workflows_approved" || r.Reason != "workflows_awaiting_approval" || !slices.Equal(approved, want) {
+				t.Errorf("trusted: %s/%s, approved %q; want both runs approved", r.Action, r.Reason, approved)
+			}
+		default:
+			if r.Action != "commented" || r.Reason != "workflows_awaiting_approval" || len(approved) != 0 {
+				t.Errorf("untrusted: %s/%s, approved %q; want a comment and no approvals", r.Action, r.Reason, approved)
+			}
+			if posted := fake.posted[fork.URL]; len(posted) != 1 || !strings.Contains(posted[0], "approve them") {
+				t.Errorf("untrusted: posted %q; want the approval next action", posted)
+			}
+		}
+	}
+}